@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/workers"
+	"os"
+)
+
+type Options struct {
+	PathToConfigFile string
+	Institution      string
+	IdentifierLike   string
+	Format           string
+	Limit            int
+	SampleRate       float64
+	Concurrency      int
+	ShowAll          bool
+}
+
+func main() {
+	opts := parseCommandLine()
+	config, err := models.LoadConfigFile(opts.PathToConfigFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	_context := context.NewContext(config)
+
+	checksumAudit, err := workers.NewAPTChecksumAudit(_context,
+		opts.Institution, opts.IdentifierLike, opts.Format,
+		opts.Limit, opts.SampleRate, opts.Concurrency, opts.ShowAll)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	checked, divergent, err := checksumAudit.Run()
+	fmt.Fprintf(os.Stderr, "Checked %d files. %d had divergent checksums.\n",
+		checked, divergent)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	if divergent > 0 {
+		os.Exit(2)
+	}
+}
+
+// See if you can figure out from the function name what this does.
+func parseCommandLine() Options {
+	var pathToConfigFile string
+	var institution string
+	var identifierLike string
+	var format string
+	var limit int
+	var sampleRate float64
+	var concurrency int
+	var showAll bool
+	flag.StringVar(&pathToConfigFile, "config", "", "Path to APTrust config file (required)")
+	flag.StringVar(&institution, "institution", "", "Audit only files belonging to this institution")
+	flag.StringVar(&identifierLike, "like", "", "Audit only files that have this string in identifier")
+	flag.StringVar(&format, "format", "tsv", "Output data in this format")
+	flag.IntVar(&limit, "limit", 100, "Audit no more than this many files (0 for no limit)")
+	flag.Float64Var(&sampleRate, "sample", 1.0, "Fraction of files to audit")
+	flag.IntVar(&concurrency, "concurrency", 4, "Use this many concurrent HTTP connections")
+	flag.BoolVar(&showAll, "all", false, "Print results for all files, not just divergent ones")
+
+	flag.Parse()
+	if pathToConfigFile == "" {
+		fmt.Fprintln(os.Stderr, "Param config is required")
+		printUsage()
+		os.Exit(1)
+	}
+	options := Options{
+		PathToConfigFile: pathToConfigFile,
+		Institution:      institution,
+		IdentifierLike:   identifierLike,
+		Format:           format,
+		Limit:            limit,
+		SampleRate:       sampleRate,
+		Concurrency:      concurrency,
+		ShowAll:          showAll,
+	}
+	return options
+}
+
+// Tell the user about the program.
+func printUsage() {
+	message := `
+apt_checksum_audit: Compares the latest md5 and sha256 checksums that
+Pharos has for each GenericFile with the checksums stored in the metadata
+of the file's object in long-term storage, and with the object's ETag
+where the ETag is a plain md5 digest. This issues only HEAD requests, so
+it does not download any content. It prints divergent records to STDOUT
+and errors to STDERR. It exits with status 2 if it finds any divergent
+records.
+
+Usage: apt_checksum_audit -config=<path> \
+                          -institution=<institution identifier> \
+                          -like=<identifier substring> \
+                          -format=<output format> \
+                          -limit=<max files to audit> \
+                          -sample=<fraction of files to audit> \
+                          -concurrency=<max simultaneous clients> \
+                          -all
+
+Starred (*) params are required.
+
+Param -config (*) is the path to the APTrust config file. It can be an
+       absolute path, or config/<file.json> if it's in the config directory
+       of $EXCHANGE_HOME.
+Param -institution limits the audit to files belonging to the institution
+       with this identifier. E.g. "virginia.edu"
+Param -like limits the audit to files whose identifiers contain this string.
+Param -format specifies the output format. The default is "tsv",
+       but any of the following are valid:
+       "json" - JSON data
+       "csv"  - Comma-separated values
+       "tsv"  - Tab-separated values
+Param -limit is the maximum number of files to audit. The default is 100.
+       Set this to zero to audit all files.
+Param -sample is the fraction of files to audit, from greater than zero to
+       1.0. The default, 1.0, audits every file. A value of 0.01 audits
+       about one file in a hundred.
+Param -concurrency is the number of concurrent HTTP requests to issue.
+       The default is 4, and the max is 32.
+Param -all prints results for every file audited, not just for those whose
+       records diverge.
+
+Examples
+--------
+
+Audit a one percent sample of all of virginia.edu's files:
+
+apt_checksum_audit -config=config/production.json -institution=virginia.edu \
+                   -sample=0.01 -limit=0
+
+`
+	fmt.Println(message)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/workers"
+	"os"
+	"time"
+)
+
+type Options struct {
+	PathToConfigFile string
+	Institution      string
+	Target           string
+	Since            time.Time
+	Request          bool
+	DryRun           bool
+}
+
+func main() {
+	opts := parseCommandLine()
+	config, err := models.LoadConfigFile(opts.PathToConfigFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	_context := context.NewContext(config)
+	exporter, err := workers.NewAPTExporter(_context, opts.Institution, opts.Target, opts.Since)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	exporter.DryRun = opts.DryRun
+
+	if opts.Request {
+		items, err := exporter.RequestRestores()
+		for _, item := range items {
+			fmt.Printf("%d\t%s\t%s\n", item.Id, item.Action, item.ObjectIdentifier)
+		}
+		fmt.Fprintf(os.Stderr, "Requested restoration of %d objects.\n", len(items))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	inventory, err := exporter.Assemble()
+	if inventory != nil {
+		fmt.Fprintf(os.Stderr, "Exported %d of %d objects to %s.\n",
+			len(inventory.Exported()), len(inventory.Bags), opts.Target)
+		for _, bag := range inventory.Failed() {
+			fmt.Printf("%s\t%s\n", bag.ObjectIdentifier, bag.Error)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+}
+
+// See if you can figure out from the function name what this does.
+func parseCommandLine() Options {
+	var pathToConfigFile string
+	var institution string
+	var target string
+	var since string
+	var request bool
+	var dryRun bool
+	flag.StringVar(&pathToConfigFile, "config", "", "Path to APTrust config file (required)")
+	flag.StringVar(&institution, "institution", "", "Identifier of the institution to export (required)")
+	flag.StringVar(&target, "target", "", "s3://bucket/prefix or absolute path of the package (required)")
+	flag.StringVar(&since, "since", "", "Use only restorations since this RFC3339 time (required)")
+	flag.BoolVar(&request, "request", false, "Request restoration of all objects, instead of assembling the package")
+	flag.BoolVar(&dryRun, "dry-run", false, "Report what would be done, without doing it")
+	flag.Parse()
+
+	if pathToConfigFile == "" || institution == "" || target == "" || since == "" {
+		fmt.Fprintln(os.Stderr, "Params config, institution, target and since are required")
+		printUsage()
+		os.Exit(1)
+	}
+	sinceTime, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Param since is not a valid RFC3339 time: %v\n", err)
+		os.Exit(1)
+	}
+	return Options{
+		PathToConfigFile: pathToConfigFile,
+		Institution:      institution,
+		Target:           target,
+		Since:            sinceTime,
+		Request:          request,
+		DryRun:           dryRun,
+	}
+}
+
+// Tell the user about the program.
+func printUsage() {
+	message := `
+apt_export: Builds an exit package of all of an institution's active
+objects. The package is a BagIt bag whose payload is the institution's
+objects, each restored as a validated, tarred bag. Its tag files include
+aptrust-export-inventory.json, which lists every object and its digests.
+
+This runs in two passes. First, run with -request to create a restore
+WorkItem for each object. apt_restore rebuilds and validates each bag and
+delivers it to the institution's restoration bucket. When those restores
+have finished, run without -request to copy the bags into the package and
+check each one against its restore receipt. Use the same -since both times.
+
+If any object can't be exported, apt_export writes only the inventory,
+prints the failed objects to STDOUT, and exits with status 2. Fix the
+problems (e.g. by requesting restores again) and run it again. It does not
+copy bags that are already in a local target.
+
+Usage: apt_export -config=<path> \
+                  -institution=<institution identifier> \
+                  -target=<s3://bucket/prefix or /absolute/path> \
+                  -since=<RFC3339 time> \
+                  [-request] [-dry-run]
+
+Param -config is the path to the APTrust config file. It can be an
+       absolute path, or config/<file.json> if it's in the config directory
+       of $EXCHANGE_HOME.
+Param -institution is the institution identifier. E.g. "virginia.edu"
+Param -target is where to write the package. Use s3://bucket/prefix for
+       an S3 bucket, or an absolute path for attached storage.
+Param -since is the time you started the export, such as
+       2018-06-01T00:00:00Z. apt_export ignores restorations older
+       than this.
+Param -request requests restoration of all objects that haven't been
+       restored since -since.
+Param -dry-run reports what apt_export would do, without doing it.
+
+`
+	fmt.Println(message)
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"flag"
 	"fmt"
+	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/context"
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/workers"
 	"os"
+	"strings"
 )
 
 // apt_file_delete deletes individual files from long-term storage.
@@ -18,7 +20,8 @@ func main() {
 		os.Exit(1)
 	}
 	_context := context.NewContext(config)
-	_context.MessageLog.Info("Connecting to NSQLookupd at %s", _context.Config.NsqLookupd)
+	_context.MessageLog.Info("Connecting to NSQLookupd at %s",
+		strings.Join(_context.Config.NsqLookupdAddresses(), ", "))
 	_context.MessageLog.Info("NSQDHttpAddress is %s", _context.Config.NsqdHttpAddress)
 	consumer, err := workers.CreateNsqConsumer(_context.Config, &_context.Config.FileDeleteWorker)
 	if err != nil {
@@ -27,8 +30,28 @@ func main() {
 	_context.MessageLog.Info("apt_file_delete started")
 
 	deleter := workers.NewAPTFileDeleter(_context)
-	consumer.AddHandler(deleter)
-	consumer.ConnectToNSQLookupd(_context.Config.NsqLookupd)
+	// Clean up items this host left in Started state if the
+	// worker crashed or was killed on its last run.
+	_, err = workers.ReconcileStartedWorkItems(_context,
+		_context.Config.FileDeleteWorker.NsqTopic, constants.ActionDelete)
+	if err != nil {
+		_context.MessageLog.Warning(err.Error())
+	}
+
+	err = workers.StartCloudWatchMetrics(_context, &_context.Config.FileDeleteWorker)
+	if err != nil {
+		_context.MessageLog.Warning("Cannot publish CloudWatch metrics: %v", err)
+	}
+	tunedConsumer := workers.StartInFlightTuner(_context, &_context.Config.FileDeleteWorker, consumer)
+	err = workers.StartAdminServer(_context, &_context.Config.FileDeleteWorker, tunedConsumer, deleter)
+	if err != nil {
+		_context.MessageLog.Warning("Cannot start admin API: %v", err)
+	}
+	consumer.AddHandler(workers.NewMeteredHandler(deleter, _context.WorkerMetrics))
+	_, err = workers.ConnectToLookupds(_context, consumer)
+	if err != nil {
+		_context.MessageLog.Fatalf(err.Error())
+	}
 
 	// This reader blocks until we get an interrupt, so our program does not exit.
 	<-consumer.StopChan
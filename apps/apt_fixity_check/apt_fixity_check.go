@@ -7,6 +7,7 @@ import (
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/workers"
 	"os"
+	"strings"
 )
 
 // apt_fixity_check is a service that performs ongoing fixity
@@ -19,7 +20,8 @@ func main() {
 		os.Exit(1)
 	}
 	_context := context.NewContext(config)
-	_context.MessageLog.Info("Connecting to NSQLookupd at %s", _context.Config.NsqLookupd)
+	_context.MessageLog.Info("Connecting to NSQLookupd at %s",
+		strings.Join(_context.Config.NsqLookupdAddresses(), ", "))
 	_context.MessageLog.Info("NSQDHttpAddress is %s", _context.Config.NsqdHttpAddress)
 	consumer, err := workers.CreateNsqConsumer(_context.Config, &_context.Config.FixityWorker)
 	if err != nil {
@@ -28,8 +30,20 @@ func main() {
 	_context.MessageLog.Info("apt_fixity_check started")
 
 	worker := workers.NewAPTFixityChecker(_context)
-	consumer.AddHandler(worker)
-	consumer.ConnectToNSQLookupd(_context.Config.NsqLookupd)
+	err = workers.StartCloudWatchMetrics(_context, &_context.Config.FixityWorker)
+	if err != nil {
+		_context.MessageLog.Warning("Cannot publish CloudWatch metrics: %v", err)
+	}
+	tunedConsumer := workers.StartInFlightTuner(_context, &_context.Config.FixityWorker, consumer)
+	err = workers.StartAdminServer(_context, &_context.Config.FixityWorker, tunedConsumer, worker)
+	if err != nil {
+		_context.MessageLog.Warning("Cannot start admin API: %v", err)
+	}
+	consumer.AddHandler(workers.NewMeteredHandler(worker, _context.WorkerMetrics))
+	_, err = workers.ConnectToLookupds(_context, consumer)
+	if err != nil {
+		_context.MessageLog.Fatalf(err.Error())
+	}
 
 	// This reader blocks until we get an interrupt, so our program does not exit.
 	<-consumer.StopChan
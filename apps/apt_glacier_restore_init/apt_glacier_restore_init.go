@@ -7,6 +7,7 @@ import (
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/workers"
 	"os"
+	"strings"
 )
 
 // apt_glacier_restore_init sends requests to AWS asking
@@ -21,7 +22,8 @@ func main() {
 		os.Exit(1)
 	}
 	_context := context.NewContext(config)
-	_context.MessageLog.Info("Connecting to NSQLookupd at %s", _context.Config.NsqLookupd)
+	_context.MessageLog.Info("Connecting to NSQLookupd at %s",
+		strings.Join(_context.Config.NsqLookupdAddresses(), ", "))
 	_context.MessageLog.Info("NSQDHttpAddress is %s", _context.Config.NsqdHttpAddress)
 	consumer, err := workers.CreateNsqConsumer(_context.Config, &_context.Config.GlacierRestoreWorker)
 	if err != nil {
@@ -30,8 +32,21 @@ func main() {
 	_context.MessageLog.Info("apt_glacier_restore_init started")
 
 	restorer := workers.NewGlacierRestore(_context)
-	consumer.AddHandler(restorer)
-	consumer.ConnectToNSQLookupd(_context.Config.NsqLookupd)
+	workers.EnablePharosCache(_context, &_context.Config.GlacierRestoreWorker)
+	err = workers.StartCloudWatchMetrics(_context, &_context.Config.GlacierRestoreWorker)
+	if err != nil {
+		_context.MessageLog.Warning("Cannot publish CloudWatch metrics: %v", err)
+	}
+	tunedConsumer := workers.StartInFlightTuner(_context, &_context.Config.GlacierRestoreWorker, consumer)
+	err = workers.StartAdminServer(_context, &_context.Config.GlacierRestoreWorker, tunedConsumer, restorer)
+	if err != nil {
+		_context.MessageLog.Warning("Cannot start admin API: %v", err)
+	}
+	consumer.AddHandler(workers.NewMeteredHandler(restorer, _context.WorkerMetrics))
+	_, err = workers.ConnectToLookupds(_context, consumer)
+	if err != nil {
+		_context.MessageLog.Fatalf(err.Error())
+	}
 
 	// This reader blocks until we get an interrupt, so our program does not exit.
 	<-consumer.StopChan
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/workers"
+	"os"
+)
+
+type Options struct {
+	PathToConfigFile string
+	Institution      string
+	Concurrency      int
+	DryRun           bool
+}
+
+func main() {
+	opts := parseCommandLine()
+	config, err := models.LoadConfigFile(opts.PathToConfigFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	_context := context.NewContext(config)
+
+	reconciler, err := workers.NewAPTReconciler(_context,
+		opts.Institution, opts.Concurrency, opts.DryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	checked, discrepancies, err := reconciler.Run()
+	fmt.Fprintf(os.Stderr, "Checked %d files. Found %d discrepancies.\n",
+		checked, discrepancies)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	if discrepancies > 0 {
+		os.Exit(2)
+	}
+}
+
+// See if you can figure out from the function name what this does.
+func parseCommandLine() Options {
+	var pathToConfigFile string
+	var institution string
+	var concurrency int
+	var dryRun bool
+	flag.StringVar(&pathToConfigFile, "config", "", "Path to APTrust config file (required)")
+	flag.StringVar(&institution, "institution", "", "Identifier of institution whose files to check (required)")
+	flag.IntVar(&concurrency, "concurrency", 4, "Check this many files at once")
+	flag.BoolVar(&dryRun, "dry-run", false, "Print discrepancies without creating WorkItems")
+
+	flag.Parse()
+	if pathToConfigFile == "" {
+		fmt.Fprintln(os.Stderr, "Param config is required")
+		printUsage()
+		os.Exit(1)
+	}
+	if institution == "" {
+		fmt.Fprintln(os.Stderr, "Param institution is required")
+		printUsage()
+		os.Exit(1)
+	}
+	options := Options{
+		PathToConfigFile: pathToConfigFile,
+		Institution:      institution,
+		Concurrency:      concurrency,
+		DryRun:           dryRun,
+	}
+	return options
+}
+
+// Tell the user about the program.
+func printUsage() {
+	message := `
+apt_reconcile: Checks that every active GenericFile Pharos has on record
+for an institution has an object of the same size in long-term storage,
+and, for files with the Standard storage option, a replica of the same size
+in the Glacier replication bucket. This issues only HEAD requests, so it
+does not download any content.
+
+For each discrepancy, it creates a WorkItem with action Reconcile that
+needs admin review, unless an unresolved WorkItem already describes the
+same problem. It prints one tab-separated line for each discrepancy to
+STDOUT, with the file identifier, the problem, and the WorkItem id, and
+prints errors to STDERR. It exits with status 2 if it finds any
+discrepancies.
+
+Usage: apt_reconcile -config=<path> \
+                     -institution=<institution identifier> \
+                     -concurrency=<max files to check at once> \
+                     -dry-run
+
+Starred (*) params are required.
+
+Param -config (*) is the path to the APTrust config file. It can be an
+       absolute path, or config/<file.json> if it's in the config directory
+       of $EXCHANGE_HOME.
+Param -institution (*) is the identifier of the institution whose files
+       to check. E.g. "virginia.edu"
+Param -concurrency is the number of files to check at once. The default
+       is 4, and the max is 32.
+Param -dry-run prints discrepancies without creating WorkItems. The
+       WorkItem id in the output will be zero.
+
+Examples
+--------
+
+Check all of virginia.edu's files without creating WorkItems:
+
+apt_reconcile -config=config/production.json -institution=virginia.edu -dry-run
+
+`
+	fmt.Println(message)
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"flag"
 	"fmt"
+	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/context"
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/workers"
 	"os"
+	"strings"
 )
 
 // apt_record records IntellectualObjects, GenericFiles, PremisEvents
@@ -21,7 +23,8 @@ func main() {
 		os.Exit(1)
 	}
 	_context := context.NewContext(config)
-	_context.MessageLog.Info("Connecting to NSQLookupd at %s", _context.Config.NsqLookupd)
+	_context.MessageLog.Info("Connecting to NSQLookupd at %s",
+		strings.Join(_context.Config.NsqLookupdAddresses(), ", "))
 	_context.MessageLog.Info("NSQDHttpAddress is %s", _context.Config.NsqdHttpAddress)
 	consumer, err := workers.CreateNsqConsumer(_context.Config, &_context.Config.RecordWorker)
 	if err != nil {
@@ -31,8 +34,31 @@ func main() {
 	_context.MessageLog.Info("DeleteOnSuccess is set to %t", _context.Config.DeleteOnSuccess)
 
 	recorder := workers.NewAPTRecorder(_context)
-	consumer.AddHandler(recorder)
-	consumer.ConnectToNSQLookupd(_context.Config.NsqLookupd)
+	// Clean up items this host left in Started state if the
+	// worker crashed or was killed on its last run.
+	_, err = workers.ReconcileStartedWorkItems(_context,
+		_context.Config.RecordWorker.NsqTopic, constants.ActionIngest, constants.StageRecord)
+	if err != nil {
+		_context.MessageLog.Warning(err.Error())
+	}
+
+	err = workers.StartCloudWatchMetrics(_context, &_context.Config.RecordWorker)
+	if err != nil {
+		_context.MessageLog.Warning("Cannot publish CloudWatch metrics: %v", err)
+	}
+	// Ingest items record their progress in Pharos, so hold off
+	// on new ones while Pharos is struggling.
+	guardedConsumer := workers.StartPharosBackpressure(_context, &_context.Config.RecordWorker, consumer)
+	tunedConsumer := workers.StartInFlightTuner(_context, &_context.Config.RecordWorker, guardedConsumer)
+	err = workers.StartAdminServer(_context, &_context.Config.RecordWorker, tunedConsumer, recorder)
+	if err != nil {
+		_context.MessageLog.Warning("Cannot start admin API: %v", err)
+	}
+	consumer.AddHandler(workers.NewMeteredHandler(recorder, _context.WorkerMetrics))
+	_, err = workers.ConnectToLookupds(_context, consumer)
+	if err != nil {
+		_context.MessageLog.Fatalf(err.Error())
+	}
 
 	// This reader blocks until we get an interrupt, so our program does not exit.
 	<-consumer.StopChan
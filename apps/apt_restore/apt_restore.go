@@ -3,10 +3,12 @@ package main
 import (
 	"flag"
 	"fmt"
+	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/context"
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/workers"
 	"os"
+	"strings"
 )
 
 // apt_restore reassembles IntellectualObjects into tarred bags
@@ -19,7 +21,8 @@ func main() {
 		os.Exit(1)
 	}
 	_context := context.NewContext(config)
-	_context.MessageLog.Info("Connecting to NSQLookupd at %s", _context.Config.NsqLookupd)
+	_context.MessageLog.Info("Connecting to NSQLookupd at %s",
+		strings.Join(_context.Config.NsqLookupdAddresses(), ", "))
 	_context.MessageLog.Info("NSQDHttpAddress is %s", _context.Config.NsqdHttpAddress)
 	consumer, err := workers.CreateNsqConsumer(_context.Config, &_context.Config.RestoreWorker)
 	if err != nil {
@@ -28,8 +31,28 @@ func main() {
 	_context.MessageLog.Info("apt_restore started")
 
 	restorer := workers.NewAPTRestorer(_context)
-	consumer.AddHandler(restorer)
-	consumer.ConnectToNSQLookupd(_context.Config.NsqLookupd)
+	// Reset items this host left in Started state if the worker
+	// crashed or was killed, so they resume from their last checkpoint.
+	_, err = workers.ReconcileStartedWorkItems(_context,
+		_context.Config.RestoreWorker.NsqTopic, constants.ActionRestore)
+	if err != nil {
+		_context.MessageLog.Warning(err.Error())
+	}
+	workers.EnablePharosCache(_context, &_context.Config.RestoreWorker)
+	err = workers.StartCloudWatchMetrics(_context, &_context.Config.RestoreWorker)
+	if err != nil {
+		_context.MessageLog.Warning("Cannot publish CloudWatch metrics: %v", err)
+	}
+	tunedConsumer := workers.StartInFlightTuner(_context, &_context.Config.RestoreWorker, consumer)
+	err = workers.StartAdminServer(_context, &_context.Config.RestoreWorker, tunedConsumer, restorer)
+	if err != nil {
+		_context.MessageLog.Warning("Cannot start admin API: %v", err)
+	}
+	consumer.AddHandler(workers.NewMeteredHandler(restorer, _context.WorkerMetrics))
+	_, err = workers.ConnectToLookupds(_context, consumer)
+	if err != nil {
+		_context.MessageLog.Fatalf(err.Error())
+	}
 
 	// This reader blocks until we get an interrupt, so our program does not exit.
 	<-consumer.StopChan
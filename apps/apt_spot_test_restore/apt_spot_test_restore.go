@@ -6,6 +6,7 @@ import (
 	"github.com/APTrust/exchange/context"
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/workers"
+	"io/ioutil"
 	"os"
 	"time"
 )
@@ -16,7 +17,7 @@ const DAYS_SINCE_INGEST = 180
 const DAYS_SINCE_LAST_RESTORE = 180
 
 func main() {
-	pathToConfigFile, dryRun := parseCommandLine()
+	pathToConfigFile, pathToReport, dryRun := parseCommandLine()
 	config, err := models.LoadConfigFile(pathToConfigFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, err.Error())
@@ -33,6 +34,25 @@ func main() {
 	} else {
 		printResults(items)
 	}
+	if pathToReport != "" {
+		err = writeReport(worker.Report, pathToReport)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing report: ", err.Error())
+			os.Exit(1)
+		}
+	}
+}
+
+// writeReport writes the JSON report of the run to the specified file.
+func writeReport(report *models.SpotTestReport, pathToReport string) error {
+	if report == nil {
+		return fmt.Errorf("Spot test did not produce a report")
+	}
+	jsonString, err := report.ToJson()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(pathToReport, []byte(jsonString), 0644)
 }
 
 func printResults(items []*models.WorkItem) {
@@ -43,16 +63,18 @@ func printResults(items []*models.WorkItem) {
 }
 
 // See if you can figure out from the function name what this does.
-func parseCommandLine() (string, bool) {
+func parseCommandLine() (string, string, bool) {
 	var pathToConfigFile string
+	var pathToReport string
 	dryRun := flag.Bool("dryrun", false, "List which bags would be chosen, but don't queue any WorkItems")
 	flag.StringVar(&pathToConfigFile, "config", "", "Path to APTrust config file")
+	flag.StringVar(&pathToReport, "report", "", "Path to which to write the JSON report of this run")
 	flag.Parse()
 	if pathToConfigFile == "" {
 		printUsage()
 		os.Exit(1)
 	}
-	return pathToConfigFile, *dryRun
+	return pathToConfigFile, pathToReport, *dryRun
 }
 
 // Tell the user about the program.
@@ -75,9 +97,14 @@ Usage:
 
     apt_spot_test_restore -config=<absolute path to APTrust config file>
     apt_spot_test_restore -config=<absolute path to APTrust config file> -dryrun
+    apt_spot_test_restore -config=<absolute path to APTrust config file> -report=<path>
 
 Param -config is required.
 
+If param -report is present, the program writes a JSON report to that path,
+describing the criteria it used, the bag it chose for each institution, and
+the resulting WorkItem ids.
+
 If param -dryrun is present, the program will return a list of bags that would be
 queued for restoration, but it won't actually queue them.
 
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/workers"
+	"os"
+)
+
+func main() {
+	pathToConfigFile, dryRun := parseCommandLine()
+	config, err := models.LoadConfigFile(pathToConfigFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	_context := context.NewContext(config)
+	monitor := workers.NewAPTStarvationMonitor(_context, dryRun)
+	starved, err := monitor.Run()
+	if len(starved) > 0 {
+		data, jsonErr := json.MarshalIndent(starved, "", "  ")
+		if jsonErr != nil {
+			fmt.Fprintln(os.Stderr, jsonErr.Error())
+		} else {
+			fmt.Println(string(data))
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	if len(starved) > 0 {
+		os.Exit(2)
+	}
+}
+
+// See if you can figure out from the function name what this does.
+func parseCommandLine() (configFile string, dryRun bool) {
+	var pathToConfigFile string
+	var isDryRun bool
+	flag.StringVar(&pathToConfigFile, "config", "", "Path to APTrust config file (required)")
+	flag.BoolVar(&isDryRun, "dry-run", false, "Report starved queues without alerting Pharos")
+	flag.Parse()
+	if pathToConfigFile == "" {
+		fmt.Fprintln(os.Stderr, "Param config is required")
+		printUsage()
+		os.Exit(1)
+	}
+	return pathToConfigFile, isDryRun
+}
+
+// Tell the user about the program.
+func printUsage() {
+	message := `
+apt_starvation_monitor: Finds WorkItems that have been in Pending status
+longer than the config's StarvationThresholds allow, and asks Pharos to
+alert APTrust admins. It tracks the oldest pending item for each action
+and institution, and prints the starved queues as JSON to STDOUT. It exits
+with status 2 if any queue is starved.
+
+Usage: apt_starvation_monitor -config=<path> [-dry-run]
+
+Param -config is required. It can be an absolute path, or
+config/<file.json> if it's in the config directory of $EXCHANGE_HOME.
+Param -dry-run prints starved queues without sending an alert.
+
+`
+	fmt.Println(message)
+}
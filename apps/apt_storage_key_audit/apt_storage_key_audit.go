@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/workers"
+	"os"
+)
+
+type Options struct {
+	PathToConfigFile string
+	Institution      string
+	IdentifierLike   string
+	Format           string
+	Limit            int
+	Concurrency      int
+	ShowAll          bool
+}
+
+func main() {
+	opts := parseCommandLine()
+	config, err := models.LoadConfigFile(opts.PathToConfigFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	_context := context.NewContext(config)
+
+	keyAudit, err := workers.NewAPTStorageKeyAudit(_context,
+		opts.Institution, opts.IdentifierLike, opts.Format,
+		opts.Limit, opts.Concurrency, opts.ShowAll)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	checked, collisions, err := keyAudit.Run()
+	fmt.Fprintf(os.Stderr, "Checked %d files. %d had storage key problems.\n",
+		checked, collisions)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	if collisions > 0 {
+		os.Exit(2)
+	}
+}
+
+// See if you can figure out from the function name what this does.
+func parseCommandLine() Options {
+	var pathToConfigFile string
+	var institution string
+	var identifierLike string
+	var format string
+	var limit int
+	var concurrency int
+	var showAll bool
+	flag.StringVar(&pathToConfigFile, "config", "", "Path to APTrust config file (required)")
+	flag.StringVar(&institution, "institution", "", "Audit only files belonging to this institution")
+	flag.StringVar(&identifierLike, "like", "", "Audit only files that have this string in identifier")
+	flag.StringVar(&format, "format", "tsv", "Output data in this format")
+	flag.IntVar(&limit, "limit", 0, "Audit no more than this many files (0 for no limit)")
+	flag.IntVar(&concurrency, "concurrency", 4, "Use this many concurrent HTTP connections")
+	flag.BoolVar(&showAll, "all", false, "Print results for all files, not just those with problems")
+
+	flag.Parse()
+	if pathToConfigFile == "" {
+		fmt.Fprintln(os.Stderr, "Param config is required")
+		printUsage()
+		os.Exit(1)
+	}
+	options := Options{
+		PathToConfigFile: pathToConfigFile,
+		Institution:      institution,
+		IdentifierLike:   identifierLike,
+		Format:           format,
+		Limit:            limit,
+		Concurrency:      concurrency,
+		ShowAll:          showAll,
+	}
+	return options
+}
+
+// Tell the user about the program.
+func printUsage() {
+	message := `
+apt_storage_key_audit: Checks that each GenericFile's key in preservation
+storage belongs to that file alone. It reports files whose key is also used
+by another file in Pharos, and files whose stored object has bag and bagpath
+metadata naming some other file. This issues only HEAD requests, so it does
+not download any content. It prints problem records to STDOUT and errors to
+STDERR. It exits with status 2 if it finds any problems.
+
+Usage: apt_storage_key_audit -config=<path> \
+                             -institution=<institution identifier> \
+                             -like=<identifier substring> \
+                             -format=<output format> \
+                             -limit=<max files to audit> \
+                             -concurrency=<max simultaneous clients> \
+                             -all
+
+Starred (*) params are required.
+
+Param -config (*) is the path to the APTrust config file. It can be an
+       absolute path, or config/<file.json> if it's in the config directory
+       of $EXCHANGE_HOME.
+Param -institution limits the audit to files belonging to the institution
+       with this identifier. E.g. "virginia.edu"
+Param -like limits the audit to files whose identifiers contain this string.
+Param -format specifies the output format. The default is "tsv",
+       but any of the following are valid:
+       "json" - JSON data
+       "csv"  - Comma-separated values
+       "tsv"  - Tab-separated values
+Param -limit is the maximum number of files to audit. The default, zero,
+       audits all files.
+Param -concurrency is the number of concurrent HTTP requests to issue.
+       The default is 4, and the max is 32.
+Param -all prints results for every file audited, not just for those with
+       problems.
+
+Duplicate keys are found only among the files this run reads. To audit the
+whole storage namespace, run without -institution, -like or -limit.
+
+Examples
+--------
+
+Audit the storage keys of all files:
+
+apt_storage_key_audit -config=config/production.json
+
+`
+	fmt.Println(message)
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"flag"
 	"fmt"
+	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/context"
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/workers"
 	"os"
+	"strings"
 )
 
 // apt_store copies GenericFiles to long-term storage
@@ -19,8 +21,14 @@ func main() {
 		fmt.Fprintf(os.Stderr, err.Error())
 		os.Exit(1)
 	}
+	err = config.EnsureStorageOptionChangePolicy()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
 	_context := context.NewContext(config)
-	_context.MessageLog.Info("Connecting to NSQLookupd at %s", _context.Config.NsqLookupd)
+	_context.MessageLog.Info("Connecting to NSQLookupd at %s",
+		strings.Join(_context.Config.NsqLookupdAddresses(), ", "))
 	_context.MessageLog.Info("NSQDHttpAddress is %s", _context.Config.NsqdHttpAddress)
 	consumer, err := workers.CreateNsqConsumer(_context.Config, &_context.Config.StoreWorker)
 	if err != nil {
@@ -29,8 +37,31 @@ func main() {
 	_context.MessageLog.Info("apt_store started")
 
 	storer := workers.NewAPTStorer(_context)
-	consumer.AddHandler(storer)
-	consumer.ConnectToNSQLookupd(_context.Config.NsqLookupd)
+	// Clean up items this host left in Started state if the
+	// worker crashed or was killed on its last run.
+	_, err = workers.ReconcileStartedWorkItems(_context,
+		_context.Config.StoreWorker.NsqTopic, constants.ActionIngest, constants.StageStore)
+	if err != nil {
+		_context.MessageLog.Warning(err.Error())
+	}
+
+	err = workers.StartCloudWatchMetrics(_context, &_context.Config.StoreWorker)
+	if err != nil {
+		_context.MessageLog.Warning("Cannot publish CloudWatch metrics: %v", err)
+	}
+	// Ingest items record their progress in Pharos, so hold off
+	// on new ones while Pharos is struggling.
+	guardedConsumer := workers.StartPharosBackpressure(_context, &_context.Config.StoreWorker, consumer)
+	tunedConsumer := workers.StartInFlightTuner(_context, &_context.Config.StoreWorker, guardedConsumer)
+	err = workers.StartAdminServer(_context, &_context.Config.StoreWorker, tunedConsumer, storer)
+	if err != nil {
+		_context.MessageLog.Warning("Cannot start admin API: %v", err)
+	}
+	consumer.AddHandler(workers.NewMeteredHandler(storer, _context.WorkerMetrics))
+	_, err = workers.ConnectToLookupds(_context, consumer)
+	if err != nil {
+		_context.MessageLog.Fatalf(err.Error())
+	}
 
 	// This reader blocks until we get an interrupt, so our program does not exit.
 	<-consumer.StopChan
@@ -42,7 +42,10 @@ const (
 	ReceiveBucketPrefix     = "aptrust.receiving."
 	ReceiveTestBucketPrefix = "aptrust.receiving.test."
 	RestoreBucketPrefix     = "aptrust.restore."
+	RestoreTestBucketPrefix = "aptrust.restore.test."
 	S3DateFormat            = "2006-01-02T15:04:05.000Z"
+	// Depositors write the Embargo-Until tag in this format.
+	EmbargoDateFormat = "2006-01-02"
 	// All S3 urls begin with this.
 	S3UriPrefix = "https://s3.amazonaws.com/"
 )
@@ -106,6 +109,7 @@ const (
 	ActionGlacierRestore = "Glacier Restore"
 	ActionRestore        = "Restore"
 	ActionDelete         = "Delete"
+	ActionReconcile      = "Reconcile"
 )
 
 var ActionTypes []string = []string{
@@ -114,6 +118,21 @@ var ActionTypes []string = []string{
 	ActionGlacierRestore,
 	ActionRestore,
 	ActionDelete,
+	ActionReconcile,
+}
+
+// WorkItem priorities. A WorkItem with no priority is treated as
+// PriorityNormal. See Config.PriorityDelays.
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+var Priorities []string = []string{
+	PriorityHigh,
+	PriorityNormal,
+	PriorityLow,
 }
 
 // Storage options
@@ -138,6 +157,72 @@ var StorageOptions []string = []string{
 	StorageGlacierDeepOR,
 }
 
+// Policies for handling a re-ingested bag whose Storage-Option differs
+// from that of the existing object. See Config.StorageOptionChangePolicy.
+const (
+	// StorageOptionChangeKeep stores the new version with the
+	// existing object's storage option, and adds a warning to
+	// the WorkItem note.
+	StorageOptionChangeKeep = "keep"
+	// StorageOptionChangeReject rejects the bag. This is the
+	// default.
+	StorageOptionChangeReject = "reject"
+)
+
+var StorageOptionChangePolicies []string = []string{
+	StorageOptionChangeKeep,
+	StorageOptionChangeReject,
+}
+
+// Glacier retrieval tiers, from fastest and most expensive to slowest
+// and cheapest. Glacier Deep Archive does not offer Expedited.
+const (
+	RestoreTierExpedited = "Expedited"
+	RestoreTierStandard  = "Standard"
+	RestoreTierBulk      = "Bulk"
+)
+
+var RestoreTiers []string = []string{
+	RestoreTierExpedited,
+	RestoreTierStandard,
+	RestoreTierBulk,
+}
+
+// What the bucket reader does with a new bag that would put its
+// institution over its storage quota. See Config.QuotaExceededPolicy.
+const (
+	// QuotaExceededHold leaves the WorkItem pending, without queueing
+	// it, until the institution has room for the bag. The WorkItem's
+	// Retry is false and its Outcome is QuotaHeldOutcome while it's
+	// held, so apt_queue leaves it alone.
+	QuotaExceededHold = "hold"
+	// QuotaExceededReject marks the WorkItem failed.
+	QuotaExceededReject = "reject"
+	// QuotaHeldOutcome is the Outcome of a WorkItem the bucket reader
+	// is holding under QuotaExceededHold.
+	QuotaHeldOutcome = "Held for storage quota"
+)
+
+var QuotaExceededPolicies []string = []string{
+	QuotaExceededHold,
+	QuotaExceededReject,
+}
+
+// Ways to resolve the logical service names in config settings.
+// See Config.ServiceDiscovery.
+const (
+	// ServiceDiscoveryDNS looks up DNS SRV records.
+	ServiceDiscoveryDNS = "dns"
+	// ServiceDiscoveryConsul asks the Consul agent for healthy
+	// instances of the service.
+	ServiceDiscoveryConsul = "consul"
+)
+
+var ServiceDiscoveryMethods []string = []string{
+	ServiceDiscoveryDNS,
+	ServiceDiscoveryConsul,
+}
+
 // GlacierStandardOptions lists all of the standard Glacier
 // storage options (NOT Glacier Deep Archive).
 var GlacierStandardOptions []string = []string{
@@ -146,7 +231,7 @@ var GlacierStandardOptions []string = []string{
 	StorageGlacierOR,
 }
 
-// GlacierStandardOptions lists all of the Glacier Deep
+// GlacierDeepOptions lists all of the Glacier Deep
 // Archive storage options (excludes Glacier standard options).
 var GlacierDeepOptions []string = []string{
 	StorageGlacierDeepVA,
@@ -157,9 +242,38 @@ var GlacierDeepOptions []string = []string{
 const (
 	AlgMd5    = "md5"
 	AlgSha256 = "sha256"
+	AlgSha512 = "sha512"
+	// AlgBlake2b is the 512-bit BLAKE2b digest, which some
+	// partners use in place of sha512.
+	AlgBlake2b = "blake2b"
+	// AlgSha1 is for validating legacy bags only. It's not
+	// in ChecksumAlgorithms, because we don't preserve sha1
+	// digests. See BagValidationConfig.FixityAlgorithms.
+	AlgSha1 = "sha1"
+	// AlgCrc32c and AlgXxh64 are fast, non-cryptographic digests
+	// that the validator can check in addition to, but never
+	// instead of, the algorithms above. See
+	// BagValidationConfig.FixityAlgorithms.
+	AlgCrc32c = "crc32c"
+	AlgXxh64  = "xxh64"
 )
 
-var ChecksumAlgorithms = []string{AlgMd5, AlgSha256}
+var ChecksumAlgorithms = []string{AlgMd5, AlgSha256, AlgSha512, AlgBlake2b}
+
+// Values for Checksum.Source, which says where a digest came from.
+const (
+	// ChecksumSourceManifest means the depositor supplied the
+	// digest in the bag's manifest, and we verified it at ingest.
+	ChecksumSourceManifest = "manifest"
+	// ChecksumSourceIngest means we calculated the digest at
+	// ingest, and the bag had no manifest entry to verify it against.
+	ChecksumSourceIngest = "computed-at-ingest"
+	// ChecksumSourceFixity means we calculated the digest during
+	// a fixity check of the preserved file.
+	ChecksumSourceFixity = "computed-at-fixity"
+)
+
+var ChecksumSources = []string{ChecksumSourceManifest, ChecksumSourceIngest, ChecksumSourceFixity}
 
 const (
 	IdTypeStorageURL = "url"
@@ -219,6 +333,10 @@ const (
 	// The process of removing an object from repository storage.
 	EventDeletion = "deletion"
 
+	// The process of coding data so that only holders of the key
+	// can read it.
+	EventEncryption = "encryption"
+
 	// The process by which a message digest ("hash") is created.
 	// This was fixity_generation in the first iteration of APTrust's
 	// software.
@@ -265,6 +383,7 @@ var EventTypes []string = []string{
 	EventDecryption,
 	EventDeletion,
 	EventDigestCalculation,
+	EventEncryption,
 	EventFixityCheck,
 	EventIngestion,
 	EventIdentifierAssignment,
@@ -280,6 +399,47 @@ var EventTypes []string = []string{
 const OutcomeSuccess = "Success"
 const OutcomeFailure = "Failure"
 
+// Compression algorithms we record in compression events.
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+var CompressionAlgorithms []string = []string{
+	CompressionGzip,
+	CompressionZstd,
+}
+
+// Encryption algorithms we record in encryption events. These are
+// the values of S3's x-amz-server-side-encryption header.
+const (
+	EncryptionAES256 = "AES256"
+	EncryptionKMS    = "aws:kms"
+)
+
+var EncryptionAlgorithms []string = []string{
+	EncryptionAES256,
+	EncryptionKMS,
+}
+
+// FormatConfidence values describe how Siegfried identified a
+// file's PRONOM format.
+const (
+	// FormatConfidenceHigh means the file's bytes matched the
+	// format's signature, with no warnings.
+	FormatConfidenceHigh = "high"
+	// FormatConfidenceMedium means Siegfried chose a format, but
+	// warned that the match was uncertain, for example because the
+	// file extension doesn't agree with the signature.
+	FormatConfidenceMedium = "medium"
+	// FormatConfidenceLow means the match was based only on the
+	// file's extension or name.
+	FormatConfidenceLow = "low"
+	// FormatConfidenceNone means Siegfried could not identify
+	// the format.
+	FormatConfidenceNone = "none"
+)
+
 // Hack for new AWS SDK requiring a non-empty bucket name
 // for S3 operations. Tests in the network and workers packages
 // connect to a local mock service running on 127.0.0.1.
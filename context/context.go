@@ -9,6 +9,7 @@ import (
 	"github.com/op/go-logging"
 	stdlog "log"
 	"os"
+	"sync"
 	"sync/atomic"
 )
 
@@ -25,10 +26,23 @@ type Context struct {
 	NSQClient     *network.NSQClient
 	PharosClient  *network.PharosClient
 	VolumeClient  *network.VolumeClient
-	pathToLogFile string
-	pathToJsonLog string
-	succeeded     int64
-	failed        int64
+	S3SessionPool *network.S3SessionPool
+	WorkerMetrics *models.WorkerMetrics
+	// PharosMetrics records the latency and outcome of each
+	// request the PharosClient sends.
+	PharosMetrics *models.PharosMetrics
+	// ServiceResolver resolves the service names in the config,
+	// if the config uses service discovery. See RefreshServices.
+	ServiceResolver network.ServiceResolver
+	pathToLogFile   string
+	pathToJsonLog   string
+	stateBlobs      network.StateBlobStore
+	succeeded       int64
+	failed          int64
+
+	serviceTemplates    *serviceSettings
+	nsqLookupdAddresses []string
+	servicesMutex       sync.Mutex
 }
 
 /*
@@ -48,11 +62,22 @@ func NewContext(config *models.Config) (context *Context) {
 		failed:    int64(0),
 	}
 	context.Config = config
+	models.SetPremisAgent(models.PremisAgent{
+		Name:    config.PremisAgentName,
+		URI:     config.PremisAgentURI,
+		Version: config.PremisAgentVersion,
+	})
 	context.MessageLog, context.pathToLogFile = logger.InitLogger(config)
 	context.JsonLog, context.pathToJsonLog = logger.InitJsonLogger(config)
+	context.initServiceDiscovery()
 	context.VolumeClient = network.NewVolumeClient(context.Config.VolumeServicePort)
 	context.NSQClient = network.NewNSQClient(context.Config.NsqdHttpAddress)
+	context.S3SessionPool = network.NewS3SessionPool()
+	context.WorkerMetrics = models.NewWorkerMetrics()
+	context.PharosMetrics = models.NewPharosMetrics()
 	context.initPharosClient()
+	context.initStateBlobs()
+	context.startServiceRefresh()
 	return context
 }
 
@@ -68,9 +93,47 @@ func (context *Context) initPharosClient() {
 		fmt.Fprintln(os.Stderr, message)
 		context.MessageLog.Fatal(message)
 	}
+	retryDelay, err := context.Config.PharosRetryBaseDelay()
+	if err != nil {
+		message := fmt.Sprintf("Exiting. Cannot initialize Pharos Client: %v", err)
+		fmt.Fprintln(os.Stderr, message)
+		context.MessageLog.Fatal(message)
+	}
+	pharosClient.SetRetryPolicy(network.PharosRetryPolicy{
+		MaxAttempts: context.Config.PharosMaxAttempts,
+		BaseDelay:   retryDelay,
+		Jitter:      context.Config.PharosRetryJitter,
+	})
+	pharosClient.SetRateLimit(context.Config.PharosRequestsPerSecond,
+		context.Config.PharosRequestBurst)
+	pharosClient.SetRequestObserver(context.PharosMetrics)
 	context.PharosClient = pharosClient
 }
 
+// Initializes the S3 store for oversized WorkItemStates, if the
+// config names a bucket for them.
+func (context *Context) initStateBlobs() {
+	if context.Config.WorkItemStateBucket != "" {
+		context.stateBlobs = network.NewS3StateBlobStore(
+			os.Getenv("AWS_ACCESS_KEY_ID"),
+			os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			context.Config.APTrustS3Region,
+			context.S3SessionPool)
+	}
+}
+
+// StateStore returns a WorkItemStateStore that saves and loads
+// WorkItemStates through this context's PharosClient, moving
+// oversized states out of Pharos. Workers should use it instead
+// of calling the PharosClient's WorkItemState methods directly.
+func (context *Context) StateStore() *network.WorkItemStateStore {
+	store := network.NewWorkItemStateStore(context.PharosClient, context.stateBlobs,
+		context.Config.WorkItemStateBucket, context.Config.WorkItemStateMaxSize)
+	store.KeepHistory = context.Config.WorkItemStateHistory
+	store.CompressAbove = context.Config.WorkItemStateCompressAbove
+	return store
+}
+
 // Returns the number of work items that succeeded.
 func (context *Context) Succeeded() int64 {
 	return context.succeeded
@@ -1,6 +1,7 @@
 package context_test
 
 import (
+	"fmt"
 	"github.com/APTrust/exchange/context"
 	"github.com/APTrust/exchange/models"
 	"github.com/stretchr/testify/assert"
@@ -28,6 +29,8 @@ func TestNewContext(t *testing.T) {
 
 	assert.NotNil(t, _context.Config)
 	assert.NotNil(t, _context.NSQClient)
+	assert.NotNil(t, _context.S3SessionPool)
+	assert.NotNil(t, _context.WorkerMetrics)
 	assert.NotNil(t, _context.PharosClient)
 	assert.NotNil(t, _context.MessageLog)
 	assert.NotNil(t, _context.JsonLog)
@@ -35,6 +38,7 @@ func TestNewContext(t *testing.T) {
 	assert.Equal(t, expectedPathToJsonLog, _context.PathToJsonLog())
 	assert.Equal(t, int64(0), _context.Succeeded())
 	assert.Equal(t, int64(0), _context.Failed())
+	assert.Equal(t, models.PremisAgent{}, models.CurrentPremisAgent())
 
 	assert.NotPanics(t, func() { _context.MessageLog.Info("Test INFO log message") })
 	assert.NotPanics(t, func() { _context.MessageLog.Debug("Test DEBUG log message") })
@@ -63,3 +67,75 @@ func TestGetS3Client(t *testing.T) {
 	assert.Nil(t, err)
 	assert.NotNil(t, client)
 }
+
+// testResolver resolves service names from a map.
+type testResolver map[string][]string
+
+func (resolver testResolver) Resolve(service string) ([]string, error) {
+	addresses, ok := resolver[service]
+	if !ok {
+		return nil, fmt.Errorf("No such service '%s'", service)
+	}
+	return addresses, nil
+}
+
+func TestRefreshServices(t *testing.T) {
+	configFile := filepath.Join("config", "test.json")
+	appConfig, err := models.LoadConfigFile(configFile)
+	require.Nil(t, err)
+	appConfig.LogToStderr = false
+	appConfig.NsqdHttpAddress = "http://{nsqd-http}"
+	appConfig.NsqLookupd = "{nsqlookupd}"
+	appConfig.NsqLookupds = nil
+	appConfig.PharosURL = "https://{pharos}"
+	_context := context.NewContext(appConfig)
+
+	// Without a resolver, there's nothing to refresh.
+	assert.Nil(t, _context.RefreshServices())
+	assert.Equal(t, []string{"{nsqlookupd}"}, _context.NsqLookupdAddresses())
+
+	resolver := testResolver{
+		"nsqd-http":  []string{"10.0.0.5:4151"},
+		"nsqlookupd": []string{"10.0.0.5:4161", "10.0.0.6:4161"},
+		"pharos":     []string{"pharos.example.com:443"},
+	}
+	_context.ServiceResolver = resolver
+	require.Nil(t, _context.RefreshServices())
+	assert.Equal(t, "http://10.0.0.5:4151", _context.NSQClient.URL)
+	assert.Equal(t, "https://pharos.example.com:443", _context.PharosClient.HostUrl())
+	assert.Equal(t, []string{"10.0.0.5:4161", "10.0.0.6:4161"}, _context.NsqLookupdAddresses())
+
+	// Services move.
+	resolver["nsqd-http"] = []string{"10.0.0.7:4151"}
+	resolver["nsqlookupd"] = []string{"10.0.0.7:4161"}
+	require.Nil(t, _context.RefreshServices())
+	assert.Equal(t, "http://10.0.0.7:4151", _context.NSQClient.URL)
+	assert.Equal(t, []string{"10.0.0.7:4161"}, _context.NsqLookupdAddresses())
+
+	// If a service disappears, we keep the addresses we had.
+	delete(resolver, "pharos")
+	assert.NotNil(t, _context.RefreshServices())
+	assert.Equal(t, "https://pharos.example.com:443", _context.PharosClient.HostUrl())
+	assert.Equal(t, "http://10.0.0.7:4151", _context.NSQClient.URL)
+
+	os.Remove(_context.PathToLogFile())
+	os.Remove(_context.PathToJsonLog())
+}
+
+func TestNewContextPremisAgent(t *testing.T) {
+	appConfig, err := models.LoadConfigFile(filepath.Join("config", "test.json"))
+	require.Nil(t, err)
+	appConfig.LogToStderr = false
+	appConfig.PremisAgentName = "Test Preservation"
+	appConfig.PremisAgentURI = "https://example.com/preservation"
+	appConfig.PremisAgentVersion = "v0.1"
+	defer models.SetPremisAgent(models.PremisAgent{})
+
+	_context := context.NewContext(appConfig)
+	require.NotNil(t, _context)
+	assert.Equal(t, models.PremisAgent{
+		Name:    "Test Preservation",
+		URI:     "https://example.com/preservation",
+		Version: "v0.1",
+	}, models.CurrentPremisAgent())
+}
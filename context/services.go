@@ -0,0 +1,179 @@
+package context
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/network"
+	"github.com/APTrust/exchange/util"
+	"os"
+	"time"
+)
+
+// DEFAULT_SERVICE_REFRESH_INTERVAL is how often the context resolves
+// service names again if Config.ServiceRefreshInterval is not set.
+const DEFAULT_SERVICE_REFRESH_INTERVAL = time.Minute
+
+// DEFAULT_CONSUL_ADDRESS is where we find the Consul agent if
+// Config.ConsulAddress is not set.
+const DEFAULT_CONSUL_ADDRESS = "http://127.0.0.1:8500"
+
+// serviceSettings are the config settings that may name services.
+type serviceSettings struct {
+	nsqdHttpAddress string
+	nsqLookupds     []string
+	pharosURL       string
+}
+
+// initServiceDiscovery sets up the service resolver the config calls
+// for, and replaces the service names in the config with the addresses
+// they resolve to, so everything that reads the config at startup sees
+// real addresses. It exits if the config is invalid or the names don't
+// resolve, since the worker can't do anything without its services.
+func (context *Context) initServiceDiscovery() {
+	config := context.Config
+	context.serviceTemplates = &serviceSettings{
+		nsqdHttpAddress: config.NsqdHttpAddress,
+		nsqLookupds:     config.NsqLookupdAddresses(),
+		pharosURL:       config.PharosURL,
+	}
+	if config.ServiceDiscovery == "" {
+		return
+	}
+	err := config.EnsureServiceDiscovery()
+	if err == nil {
+		context.ServiceResolver = newServiceResolver(config.ServiceDiscovery,
+			config.ServiceDiscoveryDomain, config.ConsulAddress)
+		var resolved *serviceSettings
+		resolved, err = context.resolveServices()
+		if err == nil {
+			config.NsqdHttpAddress = resolved.nsqdHttpAddress
+			config.NsqLookupd = ""
+			config.NsqLookupds = resolved.nsqLookupds
+			config.PharosURL = resolved.pharosURL
+			context.nsqLookupdAddresses = resolved.nsqLookupds
+		}
+	}
+	if err != nil {
+		message := fmt.Sprintf("Exiting. Cannot resolve services: %v", err)
+		fmt.Fprintln(os.Stderr, message)
+		context.MessageLog.Fatal(message)
+	}
+}
+
+// startServiceRefresh starts a goroutine that calls RefreshServices
+// every Config.ServiceRefreshInterval, if the config uses service
+// discovery.
+func (context *Context) startServiceRefresh() {
+	if context.ServiceResolver == nil {
+		return
+	}
+	interval := DEFAULT_SERVICE_REFRESH_INTERVAL
+	if context.Config.ServiceRefreshInterval != "" {
+		// EnsureServiceDiscovery already checked this.
+		interval, _ = time.ParseDuration(context.Config.ServiceRefreshInterval)
+	}
+	context.MessageLog.Info("Resolving services through %s every %s",
+		context.Config.ServiceDiscovery, interval.String())
+	go func() {
+		for range time.Tick(interval) {
+			context.RefreshServices()
+		}
+	}()
+}
+
+// newServiceResolver returns a resolver for the specified method,
+// which must be one of constants.ServiceDiscoveryMethods.
+func newServiceResolver(method, domain, consulAddress string) network.ServiceResolver {
+	if method == constants.ServiceDiscoveryConsul {
+		if consulAddress == "" {
+			consulAddress = DEFAULT_CONSUL_ADDRESS
+		}
+		return network.NewConsulResolver(consulAddress)
+	}
+	return network.NewDNSResolver(domain)
+}
+
+// RefreshServices resolves the service names in the config again, and
+// points NSQClient and PharosClient at the services' current addresses.
+// If a name doesn't resolve, this logs a warning, keeps the addresses
+// we had, and returns the error. It does nothing if ServiceResolver is
+// nil. The context calls this periodically when the config uses
+// service discovery. See Config.ServiceDiscovery.
+func (context *Context) RefreshServices() error {
+	if context.ServiceResolver == nil {
+		return nil
+	}
+	resolved, err := context.resolveServices()
+	if err != nil {
+		context.MessageLog.Warning("Cannot refresh services. Keeping current addresses. %v", err)
+		return err
+	}
+	context.servicesMutex.Lock()
+	defer context.servicesMutex.Unlock()
+	if context.NSQClient != nil && context.NSQClient.URL != resolved.nsqdHttpAddress {
+		context.MessageLog.Info("NSQ moved to %s", resolved.nsqdHttpAddress)
+		context.NSQClient.SetURL(resolved.nsqdHttpAddress)
+	}
+	if context.PharosClient != nil && context.PharosClient.HostUrl() != resolved.pharosURL {
+		context.MessageLog.Info("Pharos moved to %s", resolved.pharosURL)
+		context.PharosClient.SetHostUrl(resolved.pharosURL)
+	}
+	context.nsqLookupdAddresses = resolved.nsqLookupds
+	return nil
+}
+
+// NsqLookupdAddresses returns the current addresses of the NSQ lookup
+// daemons. When the config uses service discovery, these may change
+// as RefreshServices runs. Otherwise, they're the addresses in the
+// config.
+func (context *Context) NsqLookupdAddresses() []string {
+	context.servicesMutex.Lock()
+	defer context.servicesMutex.Unlock()
+	if context.nsqLookupdAddresses == nil {
+		return context.Config.NsqLookupdAddresses()
+	}
+	addresses := make([]string, len(context.nsqLookupdAddresses))
+	copy(addresses, context.nsqLookupdAddresses)
+	return addresses
+}
+
+// resolveServices returns the service settings with each service name
+// replaced by the service's address. A lookup daemon setting expands
+// to every instance of its service, since workers can use them all.
+func (context *Context) resolveServices() (*serviceSettings, error) {
+	templates := context.serviceTemplates
+	resolved := &serviceSettings{
+		nsqLookupds: make([]string, 0),
+	}
+	var err error
+	resolved.nsqdHttpAddress, err = context.resolveOne(templates.nsqdHttpAddress)
+	if err != nil {
+		return nil, err
+	}
+	resolved.pharosURL, err = context.resolveOne(templates.pharosURL)
+	if err != nil {
+		return nil, err
+	}
+	for _, template := range templates.nsqLookupds {
+		addresses, err := network.ExpandServiceName(context.ServiceResolver, template)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addresses {
+			if !util.StringListContains(resolved.nsqLookupds, addr) {
+				resolved.nsqLookupds = append(resolved.nsqLookupds, addr)
+			}
+		}
+	}
+	return resolved, nil
+}
+
+// resolveOne returns template with its service name replaced by the
+// service's most preferred address.
+func (context *Context) resolveOne(template string) (string, error) {
+	addresses, err := network.ExpandServiceName(context.ServiceResolver, template)
+	if err != nil {
+		return "", err
+	}
+	return addresses[0], nil
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/APTrust/exchange/constants"
 	"time"
 )
 
@@ -15,6 +16,12 @@ when we serialize an object to JSON for Pharos.
 For example:
 1994-11-05T08:15:30-05:00     (Local Time)
 1994-11-05T08:15:30Z          (UTC)
+
+Source tells whether the depositor supplied the digest or we
+calculated it. It's one of the constants.ChecksumSource* values,
+or empty for checksums recorded before we tracked provenance.
+VerifiedAt is when we confirmed the digest matches the file. It's
+the zero time if we never had anything to compare it against.
 */
 type Checksum struct {
 	Id            int       `json:"id,omitempty"` // Do not serialize zero to JSON!
@@ -22,6 +29,8 @@ type Checksum struct {
 	Algorithm     string    `json:"algorithm"`
 	DateTime      time.Time `json:"datetime"`
 	Digest        string    `json:"digest"`
+	Source        string    `json:"source,omitempty"`
+	VerifiedAt    time.Time `json:"verified_at,omitempty"`
 	CreatedAt     time.Time `json:"created_at,omitempty"`
 	UpdatedAt     time.Time `json:"updated_at,omitempty"`
 }
@@ -42,6 +51,19 @@ func (checksum *Checksum) MergeAttributes(savedChecksum *Checksum) error {
 	return nil
 }
 
+// setIngestSource sets Source and VerifiedAt for a checksum we
+// calculated at ingest. Param manifestDigest is the digest from the
+// bag's manifest, if there was one, and verifiedAt is when we
+// confirmed that it matches the digest we calculated.
+func (checksum *Checksum) setIngestSource(manifestDigest string, verifiedAt time.Time) {
+	if manifestDigest != "" {
+		checksum.Source = constants.ChecksumSourceManifest
+		checksum.VerifiedAt = verifiedAt
+	} else {
+		checksum.Source = constants.ChecksumSourceIngest
+	}
+}
+
 // SerializeForPharos serializes a Checksum into a JSON format that
 // the Pharos server will accept for PUT and POST calls.
 func (checksum *Checksum) SerializeForPharos() ([]byte, error) {
@@ -59,7 +81,20 @@ func (checksum *Checksum) Clone() *Checksum {
 		Algorithm:     checksum.Algorithm,
 		DateTime:      checksum.DateTime,
 		Digest:        checksum.Digest,
+		Source:        checksum.Source,
+		VerifiedAt:    checksum.VerifiedAt,
 		CreatedAt:     checksum.CreatedAt,
 		UpdatedAt:     checksum.UpdatedAt,
 	}
 }
+
+// Validate returns a ModelError if the checksum is missing a required
+// field, or has an algorithm or source Pharos doesn't accept.
+func (checksum *Checksum) Validate() error {
+	checker := newModelChecker("Checksum", checksum.Algorithm+":"+checksum.Digest)
+	checker.requireOneOf("Algorithm", checksum.Algorithm, constants.ChecksumAlgorithms, true)
+	checker.require("Digest", checksum.Digest)
+	checker.requireTime("DateTime", checksum.DateTime)
+	checker.requireOneOf("Source", checksum.Source, constants.ChecksumSources, false)
+	return checker.result()
+}
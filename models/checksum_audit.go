@@ -0,0 +1,126 @@
+package models
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"strings"
+	"time"
+)
+
+// ChecksumAudit describes the result of comparing the checksums
+// Pharos has on record for a GenericFile with the checksums stored
+// in the metadata of the file's object in long-term storage. This
+// lets us find divergent records without downloading any content.
+// Note that this does not verify the content itself. That's the
+// job of the fixity checker.
+type ChecksumAudit struct {
+	// GenericFileIdentifier is the identifier of the file we audited.
+	GenericFileIdentifier string `json:"generic_file_identifier"`
+	// URI is the file's storage URL, according to Pharos.
+	URI string `json:"uri"`
+	// PharosMd5 is the latest md5 digest Pharos has for the file.
+	PharosMd5 string `json:"pharos_md5"`
+	// PharosSha256 is the latest sha256 digest Pharos has for the file.
+	PharosSha256 string `json:"pharos_sha256"`
+	// StoredMd5 is the md5 digest in the storage object's metadata.
+	StoredMd5 string `json:"stored_md5"`
+	// StoredSha256 is the sha256 digest in the storage object's metadata.
+	StoredSha256 string `json:"stored_sha256"`
+	// StoredETag is the storage object's ETag.
+	StoredETag string `json:"stored_etag"`
+	// Problems describes each divergence we found. If this is
+	// empty, the records agree.
+	Problems []string `json:"problems"`
+	// CheckedAt is when we ran the audit.
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// NewChecksumAudit returns a new ChecksumAudit for the specified
+// GenericFile, which should include its Checksums.
+func NewChecksumAudit(gf *GenericFile) *ChecksumAudit {
+	audit := &ChecksumAudit{
+		GenericFileIdentifier: gf.Identifier,
+		URI:                   gf.URI,
+		Problems:              make([]string, 0),
+		CheckedAt:             time.Now().UTC(),
+	}
+	if md5 := gf.GetChecksumByAlgorithm(constants.AlgMd5); md5 != nil {
+		audit.PharosMd5 = md5.Digest
+	}
+	if sha256 := gf.GetChecksumByAlgorithm(constants.AlgSha256); sha256 != nil {
+		audit.PharosSha256 = sha256.Digest
+	}
+	return audit
+}
+
+// Compare compares the checksums Pharos has on record with the
+// metadata of the stored file, recording any divergence in
+// audit.Problems. The ETag is compared with the md5 digest only
+// when it's a plain md5, which is the case for objects uploaded
+// in a single part. The ETags of multipart uploads contain a dash
+// and are not digests of the file's content.
+func (audit *ChecksumAudit) Compare(storedFile *StoredFile) {
+	audit.StoredMd5 = storedFile.Md5
+	audit.StoredSha256 = storedFile.Sha256
+	audit.StoredETag = storedFile.ETag
+	audit.compareDigests(constants.AlgMd5, audit.PharosMd5, audit.StoredMd5)
+	audit.compareDigests(constants.AlgSha256, audit.PharosSha256, audit.StoredSha256)
+	if audit.PharosMd5 != "" && audit.StoredETag != "" &&
+		!strings.Contains(audit.StoredETag, "-") &&
+		audit.StoredETag != audit.PharosMd5 {
+		audit.AddProblem("ETag '%s' does not match Pharos md5 '%s'",
+			audit.StoredETag, audit.PharosMd5)
+	}
+}
+
+func (audit *ChecksumAudit) compareDigests(algorithm, pharosDigest, storedDigest string) {
+	if pharosDigest == "" {
+		audit.AddProblem("Pharos has no %s checksum", algorithm)
+	} else if storedDigest == "" {
+		audit.AddProblem("Stored object has no %s metadata", algorithm)
+	} else if pharosDigest != storedDigest {
+		audit.AddProblem("Stored %s '%s' does not match Pharos %s '%s'",
+			algorithm, storedDigest, algorithm, pharosDigest)
+	}
+}
+
+// AddProblem adds a problem to the audit.
+func (audit *ChecksumAudit) AddProblem(format string, a ...interface{}) {
+	audit.Problems = append(audit.Problems, fmt.Sprintf(format, a...))
+}
+
+// HasProblems returns true if the audit found any divergence.
+func (audit *ChecksumAudit) HasProblems() bool {
+	return len(audit.Problems) > 0
+}
+
+// ToJson converts this object to JSON.
+func (audit *ChecksumAudit) ToJson() (string, error) {
+	jsonString, err := json.Marshal(audit)
+	return string(jsonString), err
+}
+
+// ToCSV converts this object to a CSV record. Multiple problems
+// are joined with semicolons in the last column. Param delimiter
+// is the field delimiter (comma, tab, pipe, etc).
+func (audit *ChecksumAudit) ToCSV(delimiter rune) (string, error) {
+	buffer := bytes.NewBuffer(make([]byte, 0))
+	writer := csv.NewWriter(buffer)
+	writer.Comma = delimiter
+	writer.Write([]string{
+		audit.GenericFileIdentifier,
+		audit.URI,
+		audit.PharosMd5,
+		audit.StoredMd5,
+		audit.PharosSha256,
+		audit.StoredSha256,
+		audit.StoredETag,
+		audit.CheckedAt.Format(time.RFC3339),
+		strings.Join(audit.Problems, "; "),
+	})
+	writer.Flush()
+	return buffer.String(), writer.Error()
+}
@@ -0,0 +1,90 @@
+package models_test
+
+import (
+	"encoding/json"
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func getStoredFileForAudit() *models.StoredFile {
+	return &models.StoredFile{
+		Md5:    md5sum,
+		Sha256: sha256sum,
+		ETag:   md5sum,
+	}
+}
+
+func TestNewChecksumAudit(t *testing.T) {
+	gf := getGenericFile()
+	gf.Identifier = "test.edu/bag/data/file.txt"
+	audit := models.NewChecksumAudit(gf)
+	require.NotNil(t, audit)
+	assert.Equal(t, gf.Identifier, audit.GenericFileIdentifier)
+	assert.Equal(t, gf.URI, audit.URI)
+	assert.Equal(t, md5sum, audit.PharosMd5)
+	assert.Equal(t, sha256sum, audit.PharosSha256)
+	assert.False(t, audit.CheckedAt.IsZero())
+	assert.False(t, audit.HasProblems())
+}
+
+func TestChecksumAuditCompareMatching(t *testing.T) {
+	audit := models.NewChecksumAudit(getGenericFile())
+	audit.Compare(getStoredFileForAudit())
+	assert.False(t, audit.HasProblems(), strings.Join(audit.Problems, "; "))
+
+	// Multipart ETags are not md5 digests, so we don't compare them.
+	storedFile := getStoredFileForAudit()
+	storedFile.ETag = "0123456789abcdef-12"
+	audit = models.NewChecksumAudit(getGenericFile())
+	audit.Compare(storedFile)
+	assert.False(t, audit.HasProblems(), strings.Join(audit.Problems, "; "))
+}
+
+func TestChecksumAuditCompareDivergent(t *testing.T) {
+	storedFile := getStoredFileForAudit()
+	storedFile.Md5 = "bad-md5"
+	storedFile.Sha256 = ""
+	storedFile.ETag = "badetag"
+	audit := models.NewChecksumAudit(getGenericFile())
+	audit.Compare(storedFile)
+	require.Equal(t, 3, len(audit.Problems))
+	assert.Equal(t, "Stored md5 'bad-md5' does not match Pharos md5 '1234567890'", audit.Problems[0])
+	assert.Equal(t, "Stored object has no sha256 metadata", audit.Problems[1])
+	assert.Equal(t, "ETag 'badetag' does not match Pharos md5 '1234567890'", audit.Problems[2])
+}
+
+func TestChecksumAuditCompareMissingPharosChecksums(t *testing.T) {
+	gf := getGenericFile()
+	gf.Checksums = nil
+	audit := models.NewChecksumAudit(gf)
+	audit.Compare(getStoredFileForAudit())
+	require.Equal(t, 2, len(audit.Problems))
+	assert.Equal(t, "Pharos has no md5 checksum", audit.Problems[0])
+	assert.Equal(t, "Pharos has no sha256 checksum", audit.Problems[1])
+}
+
+func TestChecksumAuditToJson(t *testing.T) {
+	audit := models.NewChecksumAudit(getGenericFile())
+	audit.AddProblem("Problem %d", 1)
+	jsonString, err := audit.ToJson()
+	require.Nil(t, err)
+	copy := &models.ChecksumAudit{}
+	require.Nil(t, json.Unmarshal([]byte(jsonString), copy))
+	assert.Equal(t, audit.URI, copy.URI)
+	assert.Equal(t, []string{"Problem 1"}, copy.Problems)
+}
+
+func TestChecksumAuditToCSV(t *testing.T) {
+	audit := models.NewChecksumAudit(getGenericFile())
+	audit.AddProblem("Problem 1")
+	audit.AddProblem("Problem 2")
+	csv, err := audit.ToCSV('\t')
+	require.Nil(t, err)
+	fields := strings.Split(strings.TrimRight(csv, "\n"), "\t")
+	require.Equal(t, 9, len(fields))
+	assert.Equal(t, md5sum, fields[2])
+	assert.Equal(t, "Problem 1; Problem 2", fields[8])
+}
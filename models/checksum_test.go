@@ -1,11 +1,13 @@
 package models_test
 
 import (
+	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/util/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestChecksumMergeAttributes(t *testing.T) {
@@ -33,6 +35,13 @@ func TestChecksumSerializeForPharos(t *testing.T) {
 	require.Nil(t, err)
 	expected := `{"checksum":{"generic_file_id":0,"algorithm":"md5","datetime":"2014-04-25T18:05:51Z","digest":"8d7b0e3a24fc899b1d92a73537401805"}}`
 	assert.Equal(t, expected, string(jsonData))
+
+	checksum.Source = constants.ChecksumSourceManifest
+	checksum.VerifiedAt = time.Date(2014, 4, 25, 18, 6, 0, 0, time.UTC)
+	jsonData, err = checksum.SerializeForPharos()
+	require.Nil(t, err)
+	expected = `{"checksum":{"generic_file_id":0,"algorithm":"md5","datetime":"2014-04-25T18:05:51Z","digest":"8d7b0e3a24fc899b1d92a73537401805","source":"manifest","verified_at":"2014-04-25T18:06:00Z"}}`
+	assert.Equal(t, expected, string(jsonData))
 }
 
 func TestChecksumClone(t *testing.T) {
@@ -43,6 +52,8 @@ func TestChecksumClone(t *testing.T) {
 	assert.Equal(t, clone.Algorithm, checksum.Algorithm)
 	assert.Equal(t, clone.DateTime, checksum.DateTime)
 	assert.Equal(t, clone.Digest, checksum.Digest)
+	assert.Equal(t, clone.Source, checksum.Source)
+	assert.Equal(t, clone.VerifiedAt, checksum.VerifiedAt)
 	assert.Equal(t, clone.CreatedAt, checksum.CreatedAt)
 	assert.Equal(t, clone.UpdatedAt, checksum.UpdatedAt)
 }
@@ -1,17 +1,94 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/util"
 	"github.com/APTrust/exchange/util/fileutil"
 	"github.com/op/go-logging"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 )
 
 type WorkerConfig struct {
+	// AdminPort is the port, on localhost, where the worker serves
+	// its admin API. Zero means the worker has no admin API. Each
+	// worker on a host needs its own port.
+	AdminPort int
+
+	// AutotuneMaxInFlight tells the worker to adjust its NSQ max in
+	// flight as it runs, based on how long it takes to process each
+	// item and how much staging space is free, instead of always
+	// using MaxInFlight. MaxInFlight is the starting value.
+	// See workers.InFlightTuner.
+	AutotuneMaxInFlight bool
+
+	// AutotuneInterval is how often the worker adjusts its max in
+	// flight. The format is the same as for HeartbeatInterval.
+	// Defaults to five minutes.
+	AutotuneInterval string
+
+	// MinInFlight and MaxInFlightCeiling are the bounds within which
+	// autotuning adjusts max in flight. MinInFlight defaults to one,
+	// and MaxInFlightCeiling defaults to MaxInFlight.
+	MinInFlight        int
+	MaxInFlightCeiling int
+
+	// StagingDirectory is where the worker downloads and assembles
+	// files, such as the TarDirectory or RestoreDirectory. If it's
+	// set, autotuning drops max in flight to MinInFlight whenever
+	// the volume has less than MinFreeStagingSpace bytes free.
+	StagingDirectory    string
+	MinFreeStagingSpace uint64
+
+	// PharosBackpressure tells the worker to probe Pharos every
+	// PharosHealthInterval, and to take fewer messages from NSQ
+	// while Pharos is slow or failing, so a struggling Pharos
+	// doesn't cause thousands of items to fail. The worker slows
+	// to MinInFlight when recent probes average more than
+	// PharosSlowLatency or any of them fail, and stops taking
+	// messages when PharosMaxErrorRate or more of them fail. It
+	// resumes on its own when Pharos recovers.
+	// See workers.PharosBackpressure.
+	PharosBackpressure bool
+
+	// PharosHealthInterval is how often to probe Pharos. The format
+	// is the same as for HeartbeatInterval. Defaults to 30 seconds.
+	PharosHealthInterval string
+
+	// PharosSlowLatency is the average probe time above which we
+	// consider Pharos slow. The format is the same as for
+	// HeartbeatInterval. Defaults to two seconds.
+	PharosSlowLatency string
+
+	// PharosMaxErrorRate is the fraction of recent probes, between
+	// zero and one, that must fail before we stop taking messages.
+	// Defaults to 0.5.
+	PharosMaxErrorRate float64
+
+	// PharosCacheTTL tells the worker to cache the institution and
+	// object records it gets from Pharos for this long, so it doesn't
+	// fetch the same records over and over. The format is the same
+	// as for HeartbeatInterval. Leave this empty to turn off the
+	// cache. Use it only for workers that don't need to see changes
+	// other services make to those records right away.
+	// See network.PharosClient.EnableCache.
+	PharosCacheTTL string
+
+	// PharosBatchSize is the number of records the worker sends to
+	// Pharos in each batch request. apt_record uses it when creating
+	// new GenericFiles, along with their checksums and events.
+	// Defaults to network.DefaultGenericFileBatchSize.
+	PharosBatchSize int
+
 	// This describes how often the NSQ client should ping
 	// the NSQ server to let it know it's still there. The
 	// setting must be formatted like so:
@@ -78,6 +155,65 @@ type WorkerConfig struct {
 	WriteTimeout string
 }
 
+// GlobusDestination is a depositor's Globus collection, to which
+// apt_restore delivers restored bags instead of the depositor's S3
+// restoration bucket.
+type GlobusDestination struct {
+	// EndpointId is the UUID of the depositor's Globus collection.
+	EndpointId string
+
+	// Path is the directory in the collection where restored
+	// bags go, such as "/aptrust/restorations".
+	Path string
+}
+
+// InstitutionConfig overrides some of the global settings in Config
+// for a single institution. Empty and zero values mean "use the
+// global setting." Use Config.ForInstitution to get the settings that
+// apply to an institution.
+type InstitutionConfig struct {
+	// AllowedStorageOptions limits the Storage-Option values the
+	// institution's bags may have. Empty means any of
+	// constants.StorageOptions.
+	AllowedStorageOptions []string
+
+	// MaxBagSize replaces MaxFileSize for the institution's bags.
+	MaxBagSize int64
+
+	// ReceivingBucketPatterns are patterns, in the format of
+	// path.Match, matching the names of receiving buckets in
+	// ReceivingBuckets that belong to this institution, such as
+	// "aptrust.receiving.*.example.edu". Use this for buckets whose
+	// names don't tell us who owns them. The bucket reader checks
+	// these buckets in addition to the ones in Pharos.
+	ReceivingBucketPatterns []string
+
+	// RestoreBucket replaces the institution's usual restoration
+	// bucket. It does not affect storage options listed in
+	// RestorationBucketPrefixes.
+	RestoreBucket string
+}
+
+// AllowsStorageOption returns true if the institution may deposit
+// bags with the specified storage option.
+func (instConfig *InstitutionConfig) AllowsStorageOption(storageOption string) bool {
+	if len(instConfig.AllowedStorageOptions) == 0 {
+		return util.StringListContains(constants.StorageOptions, storageOption)
+	}
+	return util.StringListContains(instConfig.AllowedStorageOptions, storageOption)
+}
+
+// MatchesReceivingBucket returns true if bucket matches one of the
+// institution's ReceivingBucketPatterns.
+func (instConfig *InstitutionConfig) MatchesReceivingBucket(bucket string) bool {
+	for _, pattern := range instConfig.ReceivingBucketPatterns {
+		if matched, _ := path.Match(pattern, bucket); matched {
+			return true
+		}
+	}
+	return false
+}
+
 type Config struct {
 	// ActiveConfig is the configuration currently
 	// in use.
@@ -116,6 +252,25 @@ type Config struct {
 	// load, this will save the server a lot of work.
 	BucketReaderCacheHours int
 
+	// CloudWatchMetricsInterval describes how often workers publish
+	// metrics to CloudWatch. The format is the same as for
+	// WorkerConfig.HeartbeatInterval. Defaults to one minute.
+	CloudWatchMetricsInterval string
+
+	// CloudWatchMetricsNamespace is the CloudWatch namespace to which
+	// workers publish metrics, such as "APTrust/Exchange". If this is
+	// empty, workers do not publish metrics to CloudWatch.
+	CloudWatchMetricsNamespace string
+
+	// CloudWatchMetricsRegion is the AWS region to which workers
+	// publish metrics. Defaults to APTrustS3Region.
+	CloudWatchMetricsRegion string
+
+	// ConsulAddress is the HTTP address of the Consul agent that
+	// resolves service names when ServiceDiscovery is "consul".
+	// Defaults to "http://127.0.0.1:8500".
+	ConsulAddress string
+
 	// Should we delete the uploaded tar file from the receiving
 	// bucket after successfully processing this bag?
 	DeleteOnSuccess bool
@@ -168,9 +323,58 @@ type Config struct {
 	// Glacier-only storage bucket is located.
 	GlacierRegionOR string
 
+	// GlacierRestoreMaxRequestsPerInstitution is the maximum number
+	// of Glacier retrieval requests that apt_glacier_restore_init will
+	// keep in flight at once for any single institution. Retrieval
+	// capacity is shared, so one institution restoring a huge object
+	// should not starve everyone else. Requests beyond this limit are
+	// held in the GlacierRestoreState and issued on later requeue
+	// cycles as earlier requests complete. Zero means no limit.
+	GlacierRestoreMaxRequestsPerInstitution int
+
+	// GlacierRestoreRequestDB is the path to the BoltDB file in which
+	// apt_glacier_restore_init saves the number of retrieval requests
+	// each institution has in flight, so that it still honors
+	// GlacierRestoreMaxRequestsPerInstitution after a restart. The
+	// worker creates the file if it doesn't exist. The worker holds
+	// the file open and locked while it runs, so this should be a
+	// per-host path, not one on shared storage like TarDirectory. If
+	// this is empty, or the worker can't open the file, it keeps the
+	// counts only in memory.
+	GlacierRestoreRequestDB string
+
 	// Configuration options for apt_glacier_restore
 	GlacierRestoreWorker WorkerConfig
 
+	// GlobusDestinations maps institution identifiers to the Globus
+	// collections where apt_restore should deliver those institutions'
+	// restored bags. Institutions not listed here get their bags in
+	// their S3 restoration buckets. Globus delivery requires
+	// GlobusSourceEndpoint and GlobusSourcePath, and the Globus client
+	// credentials in the GLOBUS_CLIENT_ID and GLOBUS_CLIENT_SECRET
+	// environment variables.
+	GlobusDestinations map[string]*GlobusDestination
+
+	// GlobusSourceEndpoint is the UUID of our own Globus collection,
+	// which shares RestoreDirectory, so Globus can copy restored
+	// bags from it.
+	GlobusSourceEndpoint string
+
+	// GlobusSourcePath is the path of RestoreDirectory within the
+	// GlobusSourceEndpoint collection, such as "/restore".
+	GlobusSourcePath string
+
+	// IngestReceiptInstitutions lists the identifiers of institutions
+	// whose depositors get an email receipt when ingest succeeds, such
+	// as "virginia.edu". Use "*" to send receipts for all institutions.
+	// Pharos sends the email. See models.IngestReceipt.
+	IngestReceiptInstitutions []string
+
+	// InstitutionOverrides maps institution identifiers to settings
+	// that replace the global ones for that institution. Institutions
+	// not listed here use the global settings. See ForInstitution.
+	InstitutionOverrides map[string]*InstitutionConfig
+
 	// LogDirectory is where we'll write our log files.
 	LogDirectory string
 
@@ -189,6 +393,12 @@ type Config struct {
 	// to do this in development.
 	LogToStderr bool
 
+	// LowPriorityIngestSize is the size in bytes above which the bucket
+	// reader gives new ingest WorkItems constants.PriorityLow, so they
+	// don't hold up restores and smaller ingests. Zero means ingests
+	// always get normal priority.
+	LowPriorityIngestSize int64
+
 	// Maximum number of days allowed between scheduled
 	// fixity checks. The fixity_reader periodically
 	// queries Pharos for GenericFiles whose last
@@ -217,19 +427,93 @@ type Config struct {
 	// typically something like "localhost:4161"
 	NsqLookupd string
 
+	// NsqLookupdCheckInterval describes how often workers check
+	// the health of the lookup daemons in NsqLookupd and
+	// NsqLookupds. The format is the same as for
+	// WorkerConfig.HeartbeatInterval. Defaults to 30 seconds.
+	NsqLookupdCheckInterval string
+
+	// NsqLookupds is an optional list of additional NSQ Lookup
+	// daemons, in the same format as NsqLookupd. When this is set,
+	// workers query every healthy lookup daemon, and stop querying
+	// any that fail their health check until they recover, so
+	// losing one lookup daemon does not stall consumption.
+	NsqLookupds []string
+
 	// The version of the Pharos API we're using. This should
 	// start with a v, like v1, v2.2, etc.
 	PharosAPIVersion string
 
+	// PharosMaxAttempts is the most times the PharosClient sends
+	// each request, including the first, when Pharos returns a
+	// 5xx status or the connection fails. Zero or one means
+	// don't retry. See network.PharosRetryPolicy.
+	PharosMaxAttempts int
+
+	// PharosRequestBurst is the most requests a worker may send to
+	// Pharos at once, when it hasn't sent any for a while. See
+	// PharosRequestsPerSecond. Defaults to one.
+	PharosRequestBurst int
+
+	// PharosRequestsPerSecond limits how many requests each worker
+	// process sends to Pharos per second, on average, so a burst of
+	// NSQ messages doesn't overload Pharos. Requests over the limit
+	// wait their turn. Zero means no limit.
+	PharosRequestsPerSecond float64
+
+	// PharosRetryDelay is how long to wait before the first retry
+	// of a failed Pharos request. The delay doubles with each retry
+	// after that. The format is the same as for
+	// WorkerConfig.HeartbeatInterval. Defaults to one second.
+	PharosRetryDelay string
+
+	// PharosRetryJitter is the fraction, between zero and one, by
+	// which to randomly shorten each retry delay, so workers that
+	// failed at the same time don't all retry at the same time.
+	PharosRetryJitter float64
+
 	// PharosURL is the URL of the Pharos server where
 	// we will be recording results and metadata. This should
 	// start with http:// or https://
 	PharosURL string
 
+	// PremisAgentName is the name of the software that records PREMIS
+	// events, such as "Acme Preservation". If set, it replaces "APTrust
+	// Exchange" in the Object of the events our own services create.
+	// Leave this empty to use the APTrust defaults. See models.PremisAgent.
+	PremisAgentName string
+
+	// PremisAgentURI is where to find out more about the software
+	// named in PremisAgentName. If set, it replaces the APTrust
+	// Exchange URL in the Agent of the events our own services create.
+	PremisAgentURI string
+
+	// PremisAgentVersion, if set, is appended to the Object of the
+	// events our own services create, so we can tell which release
+	// recorded an event.
+	PremisAgentVersion string
+
 	// The name of the preservation bucket to which we should
 	// copy files for long-term storage.
 	PreservationBucket string
 
+	// PriorityDelays tells apt_queue and the bucket reader how long NSQ
+	// should hold back WorkItems of each priority before delivering
+	// them, so that higher-priority items reach workers first. The key
+	// is one of constants.Priorities, and the value is a duration, such
+	// as "20m". Priorities not listed here are delivered at once.
+	// nsqd rejects delays longer than its -max-req-timeout, which
+	// defaults to one hour.
+	PriorityDelays map[string]string
+
+	// QuotaExceededPolicy tells the bucket reader what to do with a new
+	// bag that would put its institution over its storage quota. Use
+	// constants.QuotaExceededHold to leave the WorkItem pending until
+	// the institution has room, or constants.QuotaExceededReject to
+	// mark it failed. Either way, the WorkItem's Note says why.
+	// Defaults to "hold". See Institution.QuotaBytes.
+	QuotaExceededPolicy string
+
 	// ReceivingBuckets is a list of S3 receiving buckets to check
 	// for incoming tar files.
 	ReceivingBuckets []string
@@ -249,6 +533,24 @@ type Config struct {
 	// in US East to the replication bucket in USWest2.
 	ReplicationDirectory string
 
+	// RestorationBucketPrefixes sends restorations of content in some
+	// storage options to a separate set of restoration buckets, which
+	// can have their own lifecycle rules. Glacier restorations are
+	// often large, and depositors need more time to download them than
+	// the usual restoration bucket allows. The key is a storage option,
+	// such as "Glacier-Deep-VA", and the value is a bucket name prefix,
+	// such as "aptrust.restore-glacier.". We append the institution
+	// identifier to the prefix to get the bucket name. Storage options
+	// not listed here restore to the institution's usual restoration
+	// bucket. See RestorationBucketFor.
+	RestorationBucketPrefixes map[string]string
+
+	// RestoreCheckpointInterval describes how often apt_restore saves
+	// its progress to Pharos while packaging a bag, so that a restarted
+	// worker can resume where it left off. The format is the same as
+	// for WorkerConfig.HeartbeatInterval. Defaults to five minutes.
+	RestoreCheckpointInterval string
+
 	// RestoreDirectory is the directory in which we will
 	// rebuild IntellectualObject before sending them
 	// off to the S3 restoration bucket.
@@ -263,6 +565,35 @@ type Config struct {
 	// Configuration options for apt_restore
 	RestoreWorker WorkerConfig
 
+	// ServiceDiscovery tells workers how to resolve logical service
+	// names in NsqdHttpAddress, NsqLookupd, NsqLookupds and PharosURL.
+	// A service name appears in braces where the host and port would
+	// be, as in "http://{nsqd-http}" or "https://{pharos}". Use
+	// constants.ServiceDiscoveryDNS to look up DNS SRV records, or
+	// constants.ServiceDiscoveryConsul to ask the Consul agent at
+	// ConsulAddress. Leave this empty to use the settings as they are.
+	// Workers resolve the names at startup, and again every
+	// ServiceRefreshInterval, so services can move without config
+	// edits or restarts. See context.Context.RefreshServices.
+	ServiceDiscovery string
+
+	// ServiceDiscoveryDomain is the domain of the DNS SRV records,
+	// when ServiceDiscovery is "dns". The record for service name
+	// "nsqd-http" is _nsqd-http._tcp.<domain>. If this is empty,
+	// service names must be full SRV record names.
+	ServiceDiscoveryDomain string
+
+	// ServiceRefreshInterval describes how often workers resolve
+	// service names again. The format is the same as for
+	// WorkerConfig.HeartbeatInterval. Defaults to one minute.
+	ServiceRefreshInterval string
+
+	// SiegfriedURL is the base URL of a Siegfried server (sf -serve),
+	// such as "http://localhost:5138". If it's set, apt_fetch asks
+	// Siegfried to identify the PRONOM format of each payload file
+	// as it validates the bag. Leave it empty to skip identification.
+	SiegfriedURL string
+
 	// SkipAlreadyProcessed indicates whether or not the
 	// bucket_reader should  put successfully-processed items into
 	// NSQ for re-processing. This is amost always set to false.
@@ -270,6 +601,27 @@ type Config struct {
 	// items to test code changes.
 	SkipAlreadyProcessed bool
 
+	// StarvationThresholds tells apt_starvation_monitor how long a
+	// WorkItem may sit in Pending status before we alert APTrust
+	// admins. The key is an action from constants.ActionTypes, and the
+	// value is a duration, such as "72h". Actions not listed here are
+	// not monitored.
+	StarvationThresholds map[string]string
+
+	// StorageOptionChangePolicy describes what to do when a depositor
+	// re-deposits an existing object with a different Storage-Option.
+	// We can't store a new version of an object in a different place
+	// from the original, so the options are constants.StorageOptionChangeKeep,
+	// which stores the new version with the original storage option and
+	// notes that on the WorkItem, and constants.StorageOptionChangeReject,
+	// which rejects the bag with a message explaining why. Defaults to
+	// "reject". There is no policy to honor the change, because that
+	// would mean migrating the object's existing files to the new
+	// storage, and we have no workflow for that. Depositors who want
+	// to change an object's storage option must delete the object
+	// and deposit it again.
+	StorageOptionChangePolicy string
+
 	// Configuration options for apt_store
 	StoreWorker WorkerConfig
 
@@ -299,6 +651,31 @@ type Config struct {
 	// volumes and mounts as the locally running
 	// services.
 	VolumeServicePort int
+
+	// WorkItemStateBucket is the S3 bucket, in APTrustS3Region, in
+	// which we keep WorkItemState payloads that are too large to
+	// store in Pharos. See WorkItemStateMaxSize.
+	WorkItemStateBucket string
+
+	// WorkItemStateCompressAbove is the size, in bytes, above which
+	// workers gzip WorkItemState.State before saving it. Compression
+	// happens before the WorkItemStateMaxSize check, so compressed
+	// states are less likely to need offloading. Zero means never
+	// compress.
+	WorkItemStateCompressAbove int
+
+	// WorkItemStateHistory tells workers to keep a copy of every
+	// WorkItemState they save in WorkItemStateBucket, so we can see
+	// how an item's state changed across requeues. Pharos keeps only
+	// the latest state. This has no effect if WorkItemStateBucket
+	// is empty.
+	WorkItemStateHistory bool
+
+	// WorkItemStateMaxSize is the largest WorkItemState.State, in
+	// bytes, that we store in Pharos. Larger states go into
+	// WorkItemStateBucket, and Pharos gets a pointer to them.
+	// Zero means always store states in Pharos.
+	WorkItemStateMaxSize int
 }
 
 // This returns the configuration that the user requested,
@@ -356,6 +733,270 @@ func (config *Config) EnsurePharosConfig() error {
 	if os.Getenv("PHAROS_API_KEY") == "" {
 		return fmt.Errorf("Environment variable PHAROS_API_KEY is not set")
 	}
+	if _, err := config.PharosRetryBaseDelay(); err != nil {
+		return err
+	}
+	if config.PharosRetryJitter < 0 || config.PharosRetryJitter > 1 {
+		return fmt.Errorf("PharosRetryJitter must be between zero and one")
+	}
+	if config.PharosRequestsPerSecond < 0 || config.PharosRequestBurst < 0 {
+		return fmt.Errorf("PharosRequestsPerSecond and PharosRequestBurst cannot be negative")
+	}
+	return nil
+}
+
+// PharosRetryBaseDelay returns PharosRetryDelay as a duration, or
+// zero if PharosRetryDelay is empty.
+func (config *Config) PharosRetryBaseDelay() (time.Duration, error) {
+	if config.PharosRetryDelay == "" {
+		return 0, nil
+	}
+	delay, err := time.ParseDuration(config.PharosRetryDelay)
+	if err != nil || delay < 0 {
+		return 0, fmt.Errorf("PharosRetryDelay '%s' is not a valid duration", config.PharosRetryDelay)
+	}
+	return delay, nil
+}
+
+// EnsureStorageOptionChangePolicy returns an error if
+// StorageOptionChangePolicy is set to an unknown value.
+func (config *Config) EnsureStorageOptionChangePolicy() error {
+	if config.StorageOptionChangePolicy != "" &&
+		!util.StringListContains(constants.StorageOptionChangePolicies, config.StorageOptionChangePolicy) {
+		return fmt.Errorf("StorageOptionChangePolicy '%s' is not valid. Use one of: %s",
+			config.StorageOptionChangePolicy, strings.Join(constants.StorageOptionChangePolicies, ", "))
+	}
+	return nil
+}
+
+// EnsureQuotaExceededPolicy returns an error if QuotaExceededPolicy
+// is set to an unknown value.
+func (config *Config) EnsureQuotaExceededPolicy() error {
+	if config.QuotaExceededPolicy != "" &&
+		!util.StringListContains(constants.QuotaExceededPolicies, config.QuotaExceededPolicy) {
+		return fmt.Errorf("QuotaExceededPolicy '%s' is not valid. Use one of: %s",
+			config.QuotaExceededPolicy, strings.Join(constants.QuotaExceededPolicies, ", "))
+	}
+	return nil
+}
+
+// EnsureServiceDiscovery returns an error if ServiceDiscovery
+// or ServiceRefreshInterval is set to an invalid value.
+func (config *Config) EnsureServiceDiscovery() error {
+	if config.ServiceDiscovery != "" &&
+		!util.StringListContains(constants.ServiceDiscoveryMethods, config.ServiceDiscovery) {
+		return fmt.Errorf("ServiceDiscovery '%s' is not valid. Use one of: %s",
+			config.ServiceDiscovery, strings.Join(constants.ServiceDiscoveryMethods, ", "))
+	}
+	if config.ServiceRefreshInterval != "" {
+		if _, err := time.ParseDuration(config.ServiceRefreshInterval); err != nil {
+			return fmt.Errorf("ServiceRefreshInterval '%s' is not valid: %v",
+				config.ServiceRefreshInterval, err)
+		}
+	}
+	return nil
+}
+
+// EnsureGlobusConfig returns an error if GlobusDestinations lists any
+// destinations, but the settings we need to send bags to them are
+// missing.
+func (config *Config) EnsureGlobusConfig() error {
+	if len(config.GlobusDestinations) == 0 {
+		return nil
+	}
+	if config.GlobusSourceEndpoint == "" || config.GlobusSourcePath == "" {
+		return fmt.Errorf("GlobusDestinations requires GlobusSourceEndpoint and GlobusSourcePath")
+	}
+	for institution, destination := range config.GlobusDestinations {
+		if destination == nil || destination.EndpointId == "" {
+			return fmt.Errorf("GlobusDestinations entry for %s has no EndpointId", institution)
+		}
+	}
+	return nil
+}
+
+// NsqLookupdAddresses returns NsqLookupd followed by NsqLookupds,
+// without blanks or duplicates.
+func (config *Config) NsqLookupdAddresses() []string {
+	addresses := make([]string, 0)
+	for _, addr := range append([]string{config.NsqLookupd}, config.NsqLookupds...) {
+		addr = strings.TrimSpace(addr)
+		if addr != "" && !util.StringListContains(addresses, addr) {
+			addresses = append(addresses, addr)
+		}
+	}
+	return addresses
+}
+
+// SendsIngestReceiptTo returns true if depositors from the institution
+// with the specified identifier should get an ingest receipt.
+func (config *Config) SendsIngestReceiptTo(institution string) bool {
+	for _, identifier := range config.IngestReceiptInstitutions {
+		if identifier == "*" || identifier == institution {
+			return true
+		}
+	}
+	return false
+}
+
+// StarvationThreshold returns the threshold in StarvationThresholds
+// for the specified action, or zero if the action is not monitored.
+func (config *Config) StarvationThreshold(action string) time.Duration {
+	threshold, err := time.ParseDuration(config.StarvationThresholds[action])
+	if err != nil || threshold < 0 {
+		return 0
+	}
+	return threshold
+}
+
+// EnsureStarvationThresholds returns an error if StarvationThresholds
+// lists an unknown action or a threshold that isn't a valid duration.
+func (config *Config) EnsureStarvationThresholds() error {
+	for action, threshold := range config.StarvationThresholds {
+		if !util.StringListContains(constants.ActionTypes, action) {
+			return fmt.Errorf("StarvationThresholds has unknown action '%s'. Use one of: %s",
+				action, strings.Join(constants.ActionTypes, ", "))
+		}
+		if _, err := time.ParseDuration(threshold); err != nil {
+			return fmt.Errorf("StarvationThresholds entry '%s' for %s is not valid: %v",
+				threshold, action, err)
+		}
+	}
+	return nil
+}
+
+// ForInstitution returns the settings that apply to the institution
+// with the specified identifier: its InstitutionOverrides, with the
+// global settings filled in where it has none. The result is a copy,
+// so changing it does not change the config.
+func (config *Config) ForInstitution(identifier string) *InstitutionConfig {
+	instConfig := &InstitutionConfig{}
+	if override := config.InstitutionOverrides[identifier]; override != nil {
+		*instConfig = *override
+	}
+	if instConfig.MaxBagSize == 0 {
+		instConfig.MaxBagSize = config.MaxFileSize
+	}
+	if len(instConfig.AllowedStorageOptions) == 0 {
+		instConfig.AllowedStorageOptions = constants.StorageOptions
+	}
+	if instConfig.RestoreBucket == "" {
+		instConfig.RestoreBucket = util.RestorationBucketFor(identifier, config.RestoreToTestBuckets)
+	}
+	return instConfig
+}
+
+// ReceivingBucketOwners returns the buckets in ReceivingBuckets that
+// match an institution's ReceivingBucketPatterns, mapped to the
+// identifier of that institution.
+func (config *Config) ReceivingBucketOwners() map[string]string {
+	owners := make(map[string]string)
+	for _, bucket := range config.ReceivingBuckets {
+		for institution, override := range config.InstitutionOverrides {
+			if override != nil && override.MatchesReceivingBucket(bucket) {
+				owners[bucket] = institution
+				break
+			}
+		}
+	}
+	return owners
+}
+
+// EnsureInstitutionOverrides returns an error if InstitutionOverrides
+// has an unknown storage option, a bad bucket pattern, a negative
+// MaxBagSize, or a bucket that matches more than one institution's
+// patterns.
+func (config *Config) EnsureInstitutionOverrides() error {
+	for institution, override := range config.InstitutionOverrides {
+		if override == nil {
+			return fmt.Errorf("InstitutionOverrides entry for %s is empty", institution)
+		}
+		for _, storageOption := range override.AllowedStorageOptions {
+			if !util.StringListContains(constants.StorageOptions, storageOption) {
+				return fmt.Errorf("InstitutionOverrides entry for %s has unknown storage option '%s'",
+					institution, storageOption)
+			}
+		}
+		if override.MaxBagSize < 0 {
+			return fmt.Errorf("InstitutionOverrides entry for %s has negative MaxBagSize", institution)
+		}
+		for _, pattern := range override.ReceivingBucketPatterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("InstitutionOverrides entry for %s has bad bucket pattern '%s': %v",
+					institution, pattern, err)
+			}
+		}
+	}
+	for _, bucket := range config.ReceivingBuckets {
+		owners := make([]string, 0)
+		for institution, override := range config.InstitutionOverrides {
+			if override.MatchesReceivingBucket(bucket) {
+				owners = append(owners, institution)
+			}
+		}
+		if len(owners) > 1 {
+			sort.Strings(owners)
+			return fmt.Errorf("Receiving bucket %s matches the patterns of more than one institution: %s",
+				bucket, strings.Join(owners, ", "))
+		}
+	}
+	return nil
+}
+
+// RestorationBucketFor returns the name of the bucket to which we
+// restore the specified institution's content that is stored under
+// storageOption. See RestorationBucketPrefixes and InstitutionOverrides.
+func (config *Config) RestorationBucketFor(institution, storageOption string) string {
+	prefix := config.RestorationBucketPrefixes[storageOption]
+	if prefix == "" {
+		return config.ForInstitution(institution).RestoreBucket
+	}
+	if config.RestoreToTestBuckets {
+		return prefix + "test." + institution
+	}
+	return prefix + institution
+}
+
+// EnsureRestorationBuckets returns an error if RestorationBucketPrefixes
+// lists an unknown storage option, or a prefix that doesn't end with
+// a period.
+func (config *Config) EnsureRestorationBuckets() error {
+	for storageOption, prefix := range config.RestorationBucketPrefixes {
+		if !util.StringListContains(constants.StorageOptions, storageOption) {
+			return fmt.Errorf("RestorationBucketPrefixes has unknown storage option '%s'", storageOption)
+		}
+		if !strings.HasSuffix(prefix, ".") {
+			return fmt.Errorf("RestorationBucketPrefixes entry '%s' for %s must end with a period",
+				prefix, storageOption)
+		}
+	}
+	return nil
+}
+
+// PriorityDelay returns the delay in PriorityDelays for the specified
+// priority, or zero if there is none. Call EnsurePriorityDelays at
+// startup to catch delays that won't parse.
+func (config *Config) PriorityDelay(priority string) time.Duration {
+	delay, err := time.ParseDuration(config.PriorityDelays[priority])
+	if err != nil || delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// EnsurePriorityDelays returns an error if PriorityDelays lists an
+// unknown priority or a delay that isn't a valid duration.
+func (config *Config) EnsurePriorityDelays() error {
+	for priority, delay := range config.PriorityDelays {
+		if !util.StringListContains(constants.Priorities, priority) {
+			return fmt.Errorf("PriorityDelays has unknown priority '%s'. Use one of: %s",
+				priority, strings.Join(constants.Priorities, ", "))
+		}
+		if _, err := time.ParseDuration(delay); err != nil {
+			return fmt.Errorf("PriorityDelays entry '%s' for %s is not valid: %v",
+				delay, priority, err)
+		}
+	}
 	return nil
 }
 
@@ -378,6 +1019,10 @@ func (config *Config) ExpandFilePaths() {
 	if err == nil {
 		config.ReplicationDirectory = expanded
 	}
+	expanded, err = fileutil.ExpandTilde(config.GlacierRestoreRequestDB)
+	if err == nil {
+		config.GlacierRestoreRequestDB = expanded
+	}
 
 	// Convert bag validation config files from relative to absolute paths.
 	absPath, _ := filepath.Abs(config.BagValidationConfigFile)
@@ -488,6 +1133,18 @@ func (config *Config) AWSGlacierBuckets() map[string]string {
 	}
 }
 
+// Fingerprint returns a sha256 digest of the config settings,
+// so ops can tell whether workers on different hosts, or a worker
+// and the config file on disk, are using the same settings.
+func (config *Config) Fingerprint() string {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return ""
+	}
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
+
 // TestsAreRunning returns true if we're running unit or integration
 // tests; false otherwise.
 func (config *Config) TestsAreRunning() bool {
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 // Returns a simple config object with only directory names filled in.
@@ -71,10 +72,195 @@ func TestEnsurePharosConfig(t *testing.T) {
 	err = config.EnsurePharosConfig()
 	assert.Equal(t, "Environment variable PHAROS_API_KEY is not set", err.Error())
 
+	os.Setenv("PHAROS_API_KEY", "Bogus value set by config_test.go")
+	config.PharosRetryDelay = "soon"
+	err = config.EnsurePharosConfig()
+	assert.Equal(t, "PharosRetryDelay 'soon' is not a valid duration", err.Error())
+
+	config.PharosRetryDelay = "2s"
+	config.PharosRetryJitter = 1.5
+	err = config.EnsurePharosConfig()
+	assert.Equal(t, "PharosRetryJitter must be between zero and one", err.Error())
+
+	config.PharosRetryJitter = 0.5
+	config.PharosRequestsPerSecond = -1
+	err = config.EnsurePharosConfig()
+	assert.Equal(t, "PharosRequestsPerSecond and PharosRequestBurst cannot be negative", err.Error())
+
+	config.PharosRequestsPerSecond = 20
+	config.PharosRequestBurst = 5
+	assert.Nil(t, config.EnsurePharosConfig())
+	delay, err := config.PharosRetryBaseDelay()
+	require.Nil(t, err)
+	assert.Equal(t, 2*time.Second, delay)
+
 	os.Setenv("PHAROS_API_USER", apiUser)
 	os.Setenv("PHAROS_API_KEY", apiKey)
 }
 
+func TestEnsureStorageOptionChangePolicy(t *testing.T) {
+	config := &models.Config{}
+	assert.Nil(t, config.EnsureStorageOptionChangePolicy())
+	config.StorageOptionChangePolicy = constants.StorageOptionChangeKeep
+	assert.Nil(t, config.EnsureStorageOptionChangePolicy())
+	config.StorageOptionChangePolicy = constants.StorageOptionChangeReject
+	assert.Nil(t, config.EnsureStorageOptionChangePolicy())
+	config.StorageOptionChangePolicy = "migrate"
+	err := config.EnsureStorageOptionChangePolicy()
+	require.NotNil(t, err)
+	assert.Equal(t, "StorageOptionChangePolicy 'migrate' is not valid. Use one of: keep, reject", err.Error())
+}
+
+func TestEnsureQuotaExceededPolicy(t *testing.T) {
+	config := &models.Config{}
+	assert.Nil(t, config.EnsureQuotaExceededPolicy())
+	config.QuotaExceededPolicy = constants.QuotaExceededHold
+	assert.Nil(t, config.EnsureQuotaExceededPolicy())
+	config.QuotaExceededPolicy = constants.QuotaExceededReject
+	assert.Nil(t, config.EnsureQuotaExceededPolicy())
+	config.QuotaExceededPolicy = "ignore"
+	err := config.EnsureQuotaExceededPolicy()
+	require.NotNil(t, err)
+	assert.Equal(t, "QuotaExceededPolicy 'ignore' is not valid. Use one of: hold, reject", err.Error())
+}
+
+func TestEnsureServiceDiscovery(t *testing.T) {
+	config := &models.Config{}
+	assert.Nil(t, config.EnsureServiceDiscovery())
+	config.ServiceDiscovery = constants.ServiceDiscoveryConsul
+	config.ServiceRefreshInterval = "30s"
+	assert.Nil(t, config.EnsureServiceDiscovery())
+	config.ServiceRefreshInterval = "often"
+	assert.NotNil(t, config.EnsureServiceDiscovery())
+	config.ServiceRefreshInterval = ""
+	config.ServiceDiscovery = "zookeeper"
+	err := config.EnsureServiceDiscovery()
+	require.NotNil(t, err)
+	assert.Equal(t, "ServiceDiscovery 'zookeeper' is not valid. Use one of: dns, consul", err.Error())
+}
+
+func TestEnsureGlobusConfig(t *testing.T) {
+	config := &models.Config{}
+	assert.Nil(t, config.EnsureGlobusConfig())
+	config.GlobusDestinations = map[string]*models.GlobusDestination{
+		"test.edu": &models.GlobusDestination{EndpointId: "dest-ep", Path: "/incoming"},
+	}
+	err := config.EnsureGlobusConfig()
+	require.NotNil(t, err)
+	assert.Equal(t, "GlobusDestinations requires GlobusSourceEndpoint and GlobusSourcePath", err.Error())
+	config.GlobusSourceEndpoint = "source-ep"
+	config.GlobusSourcePath = "/restore"
+	assert.Nil(t, config.EnsureGlobusConfig())
+	config.GlobusDestinations["example.edu"] = &models.GlobusDestination{Path: "/incoming"}
+	err = config.EnsureGlobusConfig()
+	require.NotNil(t, err)
+	assert.Equal(t, "GlobusDestinations entry for example.edu has no EndpointId", err.Error())
+}
+
+func TestRestorationBucketFor(t *testing.T) {
+	config := &models.Config{
+		RestorationBucketPrefixes: map[string]string{
+			constants.StorageGlacierDeepVA: "aptrust.restore-glacier.",
+		},
+	}
+	assert.Nil(t, config.EnsureRestorationBuckets())
+	assert.Equal(t, "aptrust.restore.unc.edu", config.RestorationBucketFor("unc.edu", constants.StorageStandard))
+	assert.Equal(t, "aptrust.restore.unc.edu", config.RestorationBucketFor("unc.edu", ""))
+	assert.Equal(t, "aptrust.restore-glacier.unc.edu", config.RestorationBucketFor("unc.edu", constants.StorageGlacierDeepVA))
+	config.RestoreToTestBuckets = true
+	assert.Equal(t, "aptrust.restore.test.unc.edu", config.RestorationBucketFor("unc.edu", constants.StorageStandard))
+	assert.Equal(t, "aptrust.restore-glacier.test.unc.edu", config.RestorationBucketFor("unc.edu", constants.StorageGlacierDeepVA))
+
+	config.RestorationBucketPrefixes["Glacier-Mars"] = "aptrust.restore-mars."
+	err := config.EnsureRestorationBuckets()
+	require.NotNil(t, err)
+	assert.Equal(t, "RestorationBucketPrefixes has unknown storage option 'Glacier-Mars'", err.Error())
+	delete(config.RestorationBucketPrefixes, "Glacier-Mars")
+	config.RestorationBucketPrefixes[constants.StorageGlacierOH] = "aptrust.restore-glacier"
+	err = config.EnsureRestorationBuckets()
+	require.NotNil(t, err)
+	assert.Equal(t, "RestorationBucketPrefixes entry 'aptrust.restore-glacier' for Glacier-OH must end with a period", err.Error())
+}
+
+func TestForInstitution(t *testing.T) {
+	config := &models.Config{
+		MaxFileSize:      5000,
+		ReceivingBuckets: []string{"aptrust.receiving.unc.edu", "aptrust.receiving.east.example.edu", "uva-drop"},
+		InstitutionOverrides: map[string]*models.InstitutionConfig{
+			"example.edu": &models.InstitutionConfig{
+				AllowedStorageOptions:   []string{constants.StorageStandard, constants.StorageGlacierDeepOR},
+				MaxBagSize:              9000,
+				ReceivingBucketPatterns: []string{"aptrust.receiving.*.example.edu"},
+				RestoreBucket:           "example-restorations",
+			},
+			"virginia.edu": &models.InstitutionConfig{
+				ReceivingBucketPatterns: []string{"uva-*"},
+			},
+		},
+	}
+	require.Nil(t, config.EnsureInstitutionOverrides())
+
+	example := config.ForInstitution("example.edu")
+	assert.EqualValues(t, 9000, example.MaxBagSize)
+	assert.Equal(t, "example-restorations", example.RestoreBucket)
+	assert.True(t, example.AllowsStorageOption(constants.StorageGlacierDeepOR))
+	assert.False(t, example.AllowsStorageOption(constants.StorageGlacierVA))
+	assert.True(t, example.MatchesReceivingBucket("aptrust.receiving.east.example.edu"))
+	assert.False(t, example.MatchesReceivingBucket("aptrust.receiving.unc.edu"))
+
+	// Institutions without overrides get the global settings.
+	unc := config.ForInstitution("unc.edu")
+	assert.EqualValues(t, 5000, unc.MaxBagSize)
+	assert.Equal(t, "aptrust.restore.unc.edu", unc.RestoreBucket)
+	assert.Equal(t, constants.StorageOptions, unc.AllowedStorageOptions)
+	assert.True(t, unc.AllowsStorageOption(constants.StorageGlacierVA))
+
+	// Changing the result doesn't change the config.
+	example.MaxBagSize = 1
+	assert.EqualValues(t, 9000, config.InstitutionOverrides["example.edu"].MaxBagSize)
+
+	assert.Equal(t, "example-restorations", config.RestorationBucketFor("example.edu", constants.StorageStandard))
+	assert.Equal(t, map[string]string{
+		"aptrust.receiving.east.example.edu": "example.edu",
+		"uva-drop":                           "virginia.edu",
+	}, config.ReceivingBucketOwners())
+}
+
+func TestEnsureInstitutionOverrides(t *testing.T) {
+	config := &models.Config{
+		ReceivingBuckets: []string{"uva-drop"},
+		InstitutionOverrides: map[string]*models.InstitutionConfig{
+			"virginia.edu": &models.InstitutionConfig{
+				AllowedStorageOptions: []string{"Glacier-Mars"},
+			},
+		},
+	}
+	err := config.EnsureInstitutionOverrides()
+	require.NotNil(t, err)
+	assert.Equal(t, "InstitutionOverrides entry for virginia.edu has unknown storage option 'Glacier-Mars'", err.Error())
+
+	config.InstitutionOverrides["virginia.edu"] = &models.InstitutionConfig{MaxBagSize: -1}
+	assert.NotNil(t, config.EnsureInstitutionOverrides())
+
+	config.InstitutionOverrides["virginia.edu"] = &models.InstitutionConfig{
+		ReceivingBucketPatterns: []string{"uva-["},
+	}
+	assert.NotNil(t, config.EnsureInstitutionOverrides())
+
+	config.InstitutionOverrides["virginia.edu"] = &models.InstitutionConfig{
+		ReceivingBucketPatterns: []string{"uva-*"},
+	}
+	config.InstitutionOverrides["example.edu"] = &models.InstitutionConfig{
+		ReceivingBucketPatterns: []string{"*-drop"},
+	}
+	err = config.EnsureInstitutionOverrides()
+	require.NotNil(t, err)
+	assert.Equal(t, "Receiving bucket uva-drop matches the patterns of more than one institution: example.edu, virginia.edu", err.Error())
+
+	config.InstitutionOverrides["example.edu"] = nil
+	assert.NotNil(t, config.EnsureInstitutionOverrides())
+}
+
 func TestExpandFilePaths(t *testing.T) {
 	config := getSimpleDirConfig()
 	config.ExpandFilePaths()
@@ -135,6 +321,18 @@ func TestStorageRegionAndBucketFor(t *testing.T) {
 	assert.True(t, strings.Contains(err.Error(), "Unknown Storage Option"))
 }
 
+func TestFingerprint(t *testing.T) {
+	configFile := filepath.Join("config", "test.json")
+	config, err := models.LoadConfigFile(configFile)
+	require.Nil(t, err)
+	fingerprint := config.Fingerprint()
+	assert.Equal(t, 64, len(fingerprint))
+	assert.Equal(t, fingerprint, config.Fingerprint())
+
+	config.RestoreWorker.MaxInFlight += 1
+	assert.NotEqual(t, fingerprint, config.Fingerprint())
+}
+
 func TestTestsAreRunning(t *testing.T) {
 	configFile := filepath.Join("config", "test.json")
 	config, err := models.LoadConfigFile(configFile)
@@ -206,3 +404,72 @@ func TestAWSGlacierBuckets(t *testing.T) {
 	assert.Equal(t, "aptrust.test.preservation.glacier-deep.oh", buckets[constants.StorageGlacierDeepOH])
 	assert.Equal(t, "aptrust.test.preservation.glacier-deep.or", buckets[constants.StorageGlacierDeepOR])
 }
+
+func TestNsqLookupdAddresses(t *testing.T) {
+	config := &models.Config{NsqLookupd: "localhost:4161"}
+	assert.Equal(t, []string{"localhost:4161"}, config.NsqLookupdAddresses())
+
+	config.NsqLookupds = []string{"lookupd2:4161", "", "localhost:4161", " lookupd3:4161 "}
+	assert.Equal(t, []string{"localhost:4161", "lookupd2:4161", "lookupd3:4161"},
+		config.NsqLookupdAddresses())
+
+	config.NsqLookupd = ""
+	assert.Equal(t, []string{"lookupd2:4161", "localhost:4161", "lookupd3:4161"},
+		config.NsqLookupdAddresses())
+}
+
+func TestSendsIngestReceiptTo(t *testing.T) {
+	config := &models.Config{}
+	assert.False(t, config.SendsIngestReceiptTo("test.edu"))
+
+	config.IngestReceiptInstitutions = []string{"test.edu", "example.edu"}
+	assert.True(t, config.SendsIngestReceiptTo("test.edu"))
+	assert.True(t, config.SendsIngestReceiptTo("example.edu"))
+	assert.False(t, config.SendsIngestReceiptTo("virginia.edu"))
+
+	config.IngestReceiptInstitutions = []string{"*"}
+	assert.True(t, config.SendsIngestReceiptTo("virginia.edu"))
+}
+
+func TestPriorityDelay(t *testing.T) {
+	config := &models.Config{}
+	assert.EqualValues(t, 0, config.PriorityDelay(constants.PriorityLow))
+	assert.Nil(t, config.EnsurePriorityDelays())
+
+	config.PriorityDelays = map[string]string{
+		constants.PriorityNormal: "5m",
+		constants.PriorityLow:    "45m",
+	}
+	assert.Nil(t, config.EnsurePriorityDelays())
+	assert.EqualValues(t, 0, config.PriorityDelay(constants.PriorityHigh))
+	assert.Equal(t, 5*time.Minute, config.PriorityDelay(constants.PriorityNormal))
+	assert.Equal(t, 45*time.Minute, config.PriorityDelay(constants.PriorityLow))
+
+	config.PriorityDelays[constants.PriorityLow] = "later"
+	assert.NotNil(t, config.EnsurePriorityDelays())
+	assert.EqualValues(t, 0, config.PriorityDelay(constants.PriorityLow))
+
+	config.PriorityDelays = map[string]string{"urgent": "1m"}
+	assert.NotNil(t, config.EnsurePriorityDelays())
+}
+
+func TestStarvationThreshold(t *testing.T) {
+	config := &models.Config{}
+	assert.EqualValues(t, 0, config.StarvationThreshold(constants.ActionIngest))
+	assert.Nil(t, config.EnsureStarvationThresholds())
+
+	config.StarvationThresholds = map[string]string{
+		constants.ActionIngest:  "72h",
+		constants.ActionRestore: "6h",
+	}
+	assert.Nil(t, config.EnsureStarvationThresholds())
+	assert.Equal(t, 72*time.Hour, config.StarvationThreshold(constants.ActionIngest))
+	assert.Equal(t, 6*time.Hour, config.StarvationThreshold(constants.ActionRestore))
+	assert.EqualValues(t, 0, config.StarvationThreshold(constants.ActionDelete))
+
+	config.StarvationThresholds[constants.ActionDelete] = "a while"
+	assert.NotNil(t, config.EnsureStarvationThresholds())
+
+	config.StarvationThresholds = map[string]string{"Frobnicate": "1h"}
+	assert.NotNil(t, config.EnsureStarvationThresholds())
+}
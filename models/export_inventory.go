@@ -0,0 +1,156 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExportInventory describes an exit package: every active object
+// belonging to one institution, restored as a validated, tarred bag,
+// and gathered by apt_export into a single "bag of bags". Each restored
+// bag is a payload file of the outer bag. apt_export writes this
+// inventory into the outer bag as a tag file.
+type ExportInventory struct {
+	Institution string `json:"institution"`
+	// Target is the bucket ("s3://bucket/prefix") or directory to
+	// which apt_export wrote the package.
+	Target string `json:"target"`
+	// RestoredSince is the earliest restoration we accept. Bags
+	// restored before this are considered stale.
+	RestoredSince time.Time      `json:"restored_since"`
+	StartedAt     time.Time      `json:"started_at"`
+	FinishedAt    time.Time      `json:"finished_at"`
+	Bags          []*ExportedBag `json:"bags"`
+}
+
+// ExportedBag describes one restored bag in an ExportInventory.
+// The digests come from the restore receipt, and apt_export checks
+// them against the bag it copies into the package.
+type ExportedBag struct {
+	ObjectIdentifier string `json:"object_identifier"`
+	StorageOption    string `json:"storage_option"`
+	// Path is the bag's path within the outer bag, such as
+	// "data/photos.tar".
+	Path              string `json:"path"`
+	Size              int64  `json:"size"`
+	Md5               string `json:"md5"`
+	Sha256            string `json:"sha256"`
+	FileCount         int    `json:"file_count"`
+	RestoreWorkItemId int    `json:"restore_work_item_id"`
+	// ReceiptSignature is the signature of the restore receipt,
+	// if apt_restorer signed it.
+	ReceiptSignature string    `json:"receipt_signature,omitempty"`
+	ExportedAt       time.Time `json:"exported_at,omitempty"`
+	// Error describes why the bag is not in the package.
+	Error string `json:"error,omitempty"`
+}
+
+// NewExportInventory returns a new, empty ExportInventory.
+func NewExportInventory(institution, target string, restoredSince time.Time) *ExportInventory {
+	return &ExportInventory{
+		Institution:   institution,
+		Target:        target,
+		RestoredSince: restoredSince,
+		StartedAt:     time.Now().UTC(),
+		Bags:          make([]*ExportedBag, 0),
+	}
+}
+
+// NewExportedBag returns an ExportedBag for obj, with its size,
+// digests and file count taken from receipt. Pass a nil receipt for
+// an object that has no usable restoration, and set Error.
+func NewExportedBag(obj *IntellectualObject, receipt *RestoreReceipt) *ExportedBag {
+	bag := &ExportedBag{
+		ObjectIdentifier: obj.Identifier,
+		StorageOption:    obj.StorageOption,
+		Path:             fmt.Sprintf("data/%s.tar", obj.BagName),
+	}
+	if receipt != nil {
+		bag.Size = receipt.Size
+		bag.Md5 = receipt.Md5
+		bag.Sha256 = receipt.Sha256
+		bag.FileCount = receipt.FileCount
+		bag.RestoreWorkItemId = receipt.WorkItemId
+		bag.ReceiptSignature = receipt.Signature
+	}
+	return bag
+}
+
+// Add adds bag to the inventory.
+func (inventory *ExportInventory) Add(bag *ExportedBag) {
+	inventory.Bags = append(inventory.Bags, bag)
+}
+
+// Failed returns the bags that could not be exported.
+func (inventory *ExportInventory) Failed() []*ExportedBag {
+	failed := make([]*ExportedBag, 0)
+	for _, bag := range inventory.Bags {
+		if bag.Error != "" {
+			failed = append(failed, bag)
+		}
+	}
+	return failed
+}
+
+// Exported returns the bags that are in the package, sorted by path.
+func (inventory *ExportInventory) Exported() []*ExportedBag {
+	exported := make([]*ExportedBag, 0)
+	for _, bag := range inventory.Bags {
+		if bag.Error == "" {
+			exported = append(exported, bag)
+		}
+	}
+	sort.Slice(exported, func(i, j int) bool { return exported[i].Path < exported[j].Path })
+	return exported
+}
+
+// Complete returns true if the inventory has at least one bag, and
+// every bag was exported. apt_export writes the outer bag's bagit.txt
+// and manifests only for a complete package, so that an incomplete
+// one can't pass for a valid bag.
+func (inventory *ExportInventory) Complete() bool {
+	return len(inventory.Bags) > 0 && len(inventory.Failed()) == 0
+}
+
+// PayloadOxum returns the Payload-Oxum of the outer bag, which is
+// the total size and number of exported bags.
+func (inventory *ExportInventory) PayloadOxum() string {
+	var size int64
+	exported := inventory.Exported()
+	for _, bag := range exported {
+		size += bag.Size
+	}
+	return fmt.Sprintf("%d.%d", size, len(exported))
+}
+
+// Manifest returns the contents of the outer bag's payload manifest
+// for algorithm, which must be md5 or sha256.
+func (inventory *ExportInventory) Manifest(algorithm string) (string, error) {
+	lines := make([]string, 0)
+	for _, bag := range inventory.Exported() {
+		var digest string
+		switch algorithm {
+		case constants.AlgMd5:
+			digest = bag.Md5
+		case constants.AlgSha256:
+			digest = bag.Sha256
+		default:
+			return "", fmt.Errorf("Export manifests do not support algorithm '%s'", algorithm)
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s\n", digest, bag.Path))
+	}
+	return strings.Join(lines, ""), nil
+}
+
+// ToJson returns the inventory as indented JSON.
+func (inventory *ExportInventory) ToJson() (string, error) {
+	data, err := json.MarshalIndent(inventory, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
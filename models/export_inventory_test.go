@@ -0,0 +1,83 @@
+package models_test
+
+import (
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func makeExportInventory() *models.ExportInventory {
+	inventory := models.NewExportInventory("test.edu", "s3://aptrust.export.test.edu",
+		time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	for _, name := range []string{"photos", "letters"} {
+		obj := &models.IntellectualObject{
+			Identifier:    "test.edu/" + name,
+			BagName:       name,
+			StorageOption: constants.StorageStandard,
+		}
+		receipt := makeRestoreReceipt()
+		receipt.Key = name + ".tar"
+		inventory.Add(models.NewExportedBag(obj, receipt))
+	}
+	return inventory
+}
+
+func TestNewExportedBag(t *testing.T) {
+	inventory := makeExportInventory()
+	bag := inventory.Bags[0]
+	assert.Equal(t, "test.edu/photos", bag.ObjectIdentifier)
+	assert.Equal(t, "data/photos.tar", bag.Path)
+	assert.EqualValues(t, 8192, bag.Size)
+	assert.Equal(t, 12, bag.FileCount)
+	assert.Equal(t, 1234, bag.RestoreWorkItemId)
+
+	noReceipt := models.NewExportedBag(&models.IntellectualObject{BagName: "x"}, nil)
+	assert.EqualValues(t, 0, noReceipt.Size)
+	assert.Equal(t, "data/x.tar", noReceipt.Path)
+}
+
+func TestExportInventoryComplete(t *testing.T) {
+	inventory := models.NewExportInventory("test.edu", "/mnt/export", time.Now())
+	assert.False(t, inventory.Complete())
+
+	inventory = makeExportInventory()
+	assert.True(t, inventory.Complete())
+	assert.Empty(t, inventory.Failed())
+
+	inventory.Bags[1].Error = "Restoration failed"
+	assert.False(t, inventory.Complete())
+	require.Equal(t, 1, len(inventory.Failed()))
+	assert.Equal(t, "test.edu/letters", inventory.Failed()[0].ObjectIdentifier)
+	require.Equal(t, 1, len(inventory.Exported()))
+	assert.Equal(t, "test.edu/photos", inventory.Exported()[0].ObjectIdentifier)
+}
+
+func TestExportInventoryPayloadOxum(t *testing.T) {
+	inventory := makeExportInventory()
+	assert.Equal(t, "16384.2", inventory.PayloadOxum())
+	inventory.Bags[0].Error = "Size mismatch"
+	assert.Equal(t, "8192.1", inventory.PayloadOxum())
+}
+
+func TestExportInventoryManifest(t *testing.T) {
+	inventory := makeExportInventory()
+	manifest, err := inventory.Manifest(constants.AlgSha256)
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(manifest), "\n")
+	require.Equal(t, 2, len(lines))
+	// Sorted by path
+	assert.True(t, strings.HasSuffix(lines[0], "  data/letters.tar"))
+	assert.True(t, strings.HasSuffix(lines[1], "  data/photos.tar"))
+	assert.True(t, strings.HasPrefix(lines[0], inventory.Bags[0].Sha256))
+
+	manifest, err = inventory.Manifest(constants.AlgMd5)
+	require.Nil(t, err)
+	assert.True(t, strings.HasPrefix(manifest, inventory.Bags[0].Md5))
+
+	_, err = inventory.Manifest("sha1")
+	assert.NotNil(t, err)
+}
@@ -2,8 +2,8 @@ package models
 
 import (
 	"fmt"
+	"github.com/APTrust/exchange/constants"
 	"github.com/nsqio/go-nsq"
-	"strings"
 )
 
 // FixityResult descibes the results of fetching a file from S3
@@ -21,6 +21,10 @@ type FixityResult struct {
 	// Sha256 contains sha256 digest we calculated after downloading
 	// the file. This will be empty initially.
 	Sha256 string
+	// Blake2b contains the blake2b digest we calculated after
+	// downloading the file. We calculate this only if Pharos has
+	// a blake2b checksum for the file.
+	Blake2b string
 	// Error records the error (if any) that occured while trying to
 	// check fixity.
 	Error error
@@ -44,14 +48,7 @@ func (result *FixityResult) BucketAndKey() (string, string, error) {
 	if result.GenericFile == nil {
 		return "", "", fmt.Errorf("FixityResult.GenericFile is nil")
 	}
-	parts := strings.Split(result.GenericFile.URI, "/")
-	length := len(parts)
-	if length < 4 {
-		return "", "", fmt.Errorf("GenericFile URI '%s' is invalid", result.GenericFile.URI)
-	}
-	bucket := parts[length-2]
-	key := parts[length-1]
-	return bucket, key, nil
+	return result.GenericFile.StorageBucketAndKey()
 }
 
 // PharosSha256 returns the SHA256 checksum that Pharos has on record.
@@ -65,3 +62,26 @@ func (result *FixityResult) PharosSha256() string {
 	}
 	return checksum.Digest
 }
+
+// PharosBlake2b returns the blake2b checksum that Pharos has on record,
+// or an empty string if the file has no blake2b checksum.
+func (result *FixityResult) PharosBlake2b() string {
+	if result.GenericFile == nil {
+		return ""
+	}
+	checksum := result.GenericFile.GetChecksumByAlgorithm(constants.AlgBlake2b)
+	if checksum == nil {
+		return ""
+	}
+	return checksum.Digest
+}
+
+// FixityMatches returns true if the digests we calculated match
+// the digests in Pharos. That's always the sha256 digest, plus the
+// blake2b digest if Pharos has one.
+func (result *FixityResult) FixityMatches() bool {
+	if result.Sha256 != result.PharosSha256() {
+		return false
+	}
+	return result.PharosBlake2b() == "" || result.Blake2b == result.PharosBlake2b()
+}
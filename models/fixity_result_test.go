@@ -1,9 +1,11 @@
 package models_test
 
 import (
+	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/util/testutil"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 	"time"
 )
@@ -68,3 +70,24 @@ func TestPharosSha256(t *testing.T) {
 		t.Errorf("FedoraSha256() should have returned %s", sha256sum)
 	}
 }
+
+func TestFixityMatchesBlake2b(t *testing.T) {
+	result := models.NewFixityResult(testutil.MakeNsqMessage("999"))
+	result.GenericFile = getGenericFile()
+	result.Sha256 = sha256sum
+	assert.Equal(t, "", result.PharosBlake2b())
+	assert.True(t, result.FixityMatches())
+
+	blake2bsum := strings.Repeat("b", 128)
+	result.GenericFile.Checksums = append(result.GenericFile.Checksums, &models.Checksum{
+		Algorithm: constants.AlgBlake2b,
+		DateTime:  time.Now().UTC(),
+		Digest:    blake2bsum,
+	})
+	assert.Equal(t, blake2bsum, result.PharosBlake2b())
+	assert.False(t, result.FixityMatches())
+	result.Blake2b = blake2bsum
+	assert.True(t, result.FixityMatches())
+	result.Sha256 = "0000"
+	assert.False(t, result.FixityMatches())
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"github.com/APTrust/exchange/constants"
+	"strings"
+)
+
+// FormatIdentification describes the PRONOM format that Siegfried
+// matched to a file. The fields mirror the "matches" records in
+// Siegfried's JSON output.
+type FormatIdentification struct {
+	// PronomPuid is the PRONOM id of the format, such as "fmt/43",
+	// or "UNKNOWN" if Siegfried could not identify the file.
+	PronomPuid    string `json:"id"`
+	FormatName    string `json:"format"`
+	FormatVersion string `json:"version"`
+	MimeType      string `json:"mime"`
+	// Basis describes the evidence for the match, such as
+	// "extension match jpg; byte match at [[0 14]]".
+	Basis string `json:"basis"`
+	// Warning explains why the match may be wrong. It's empty
+	// for a clean signature match.
+	Warning string `json:"warning"`
+}
+
+// IsKnown returns true if Siegfried matched the file to a format.
+func (id *FormatIdentification) IsKnown() bool {
+	return id.PronomPuid != "" && id.PronomPuid != "UNKNOWN"
+}
+
+// Confidence returns one of the constants.FormatConfidence values,
+// based on whether Siegfried identified the file and the warning
+// it gave.
+func (id *FormatIdentification) Confidence() string {
+	if !id.IsKnown() {
+		return constants.FormatConfidenceNone
+	}
+	warning := strings.ToLower(id.Warning)
+	if strings.Contains(warning, "extension only") || strings.Contains(warning, "filename only") {
+		return constants.FormatConfidenceLow
+	}
+	if warning != "" {
+		return constants.FormatConfidenceMedium
+	}
+	return constants.FormatConfidenceHigh
+}
+
+// ApplyTo copies the identification to gf. It replaces gf.FileFormat,
+// which we guess from the file extension, only when Siegfried's
+// match is a clean signature match with a mime type.
+func (id *FormatIdentification) ApplyTo(gf *GenericFile) {
+	gf.FormatConfidence = id.Confidence()
+	if !id.IsKnown() {
+		gf.PronomPuid = ""
+		return
+	}
+	gf.PronomPuid = id.PronomPuid
+	if gf.FormatConfidence == constants.FormatConfidenceHigh && id.MimeType != "" {
+		gf.FileFormat = id.MimeType
+	}
+}
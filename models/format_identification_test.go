@@ -0,0 +1,51 @@
+package models_test
+
+import (
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFormatIdentificationConfidence(t *testing.T) {
+	id := &models.FormatIdentification{PronomPuid: "fmt/43", Basis: "byte match at [[0 14]]"}
+	assert.True(t, id.IsKnown())
+	assert.Equal(t, constants.FormatConfidenceHigh, id.Confidence())
+
+	id.Warning = "extension mismatch"
+	assert.Equal(t, constants.FormatConfidenceMedium, id.Confidence())
+
+	id.Warning = "match on extension only"
+	assert.Equal(t, constants.FormatConfidenceLow, id.Confidence())
+
+	id.Warning = "match on filename only"
+	assert.Equal(t, constants.FormatConfidenceLow, id.Confidence())
+
+	id = &models.FormatIdentification{PronomPuid: "UNKNOWN", Warning: "no match"}
+	assert.False(t, id.IsKnown())
+	assert.Equal(t, constants.FormatConfidenceNone, id.Confidence())
+}
+
+func TestFormatIdentificationApplyTo(t *testing.T) {
+	gf := models.NewGenericFile()
+	gf.FileFormat = "application/octet-stream"
+	id := &models.FormatIdentification{PronomPuid: "fmt/43", MimeType: "image/jpeg"}
+	id.ApplyTo(gf)
+	assert.Equal(t, "fmt/43", gf.PronomPuid)
+	assert.Equal(t, constants.FormatConfidenceHigh, gf.FormatConfidence)
+	assert.Equal(t, "image/jpeg", gf.FileFormat)
+
+	// Uncertain matches don't replace the format.
+	gf.FileFormat = "application/octet-stream"
+	id = &models.FormatIdentification{PronomPuid: "x-fmt/111", MimeType: "text/plain",
+		Warning: "match on extension only"}
+	id.ApplyTo(gf)
+	assert.Equal(t, "x-fmt/111", gf.PronomPuid)
+	assert.Equal(t, constants.FormatConfidenceLow, gf.FormatConfidence)
+	assert.Equal(t, "application/octet-stream", gf.FileFormat)
+
+	id = &models.FormatIdentification{PronomPuid: "UNKNOWN"}
+	id.ApplyTo(gf)
+	assert.Equal(t, "", gf.PronomPuid)
+	assert.Equal(t, constants.FormatConfidenceNone, gf.FormatConfidence)
+}
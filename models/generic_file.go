@@ -56,6 +56,16 @@ type GenericFile struct {
 	// The file's mime type. E.g. "application/xml"
 	FileFormat string `json:"file_format,omitempty"`
 
+	// PronomPuid is the PRONOM persistent unique identifier of the
+	// file's format, such as "fmt/43" for JPEG 1.01. It comes from
+	// Siegfried at ingest, and is empty if we could not identify the
+	// format, or if Config.SiegfriedURL was not set.
+	PronomPuid string `json:"pronom_puid,omitempty"`
+
+	// FormatConfidence describes how Siegfried identified the format:
+	// constants.FormatConfidenceHigh, Medium, Low or None.
+	FormatConfidence string `json:"format_confidence,omitempty"`
+
 	// The location of this file in our primary s3 long-term storage bucket.
 	URI string `json:"uri,omitempty"`
 
@@ -95,6 +105,19 @@ type GenericFile struct {
 	// "Glacier-Deep-OH", "Glacier-Deep-OR", "Glacier-Deep-VA".
 	StorageOption string `json:"storage_option"`
 
+	// StorageOptionHistory lists every storage option this file has
+	// had, oldest first. Use RecordStorageOption to add to it.
+	StorageOptionHistory []*StorageOptionHistory `json:"storage_option_history,omitempty"`
+
+	// StorageRecords lists each stored copy of this file, one per role.
+	// Files stored before we had StorageRecords have only URI and
+	// IngestReplicationURL, so use GetStorageRecord and
+	// GetStorageRecords, which fall back to those, rather than
+	// reading this directly. Use SetStorageRecord to add to it.
+	// Pharos doesn't store these yet, so files retrieved from
+	// Pharos rely on the fallback.
+	StorageRecords []*StorageRecord `json:"storage_records,omitempty"`
+
 	// ----------------------------------------------------
 	// The fields below are for internal housekeeping
 	// during the ingest process. We don't send this data
@@ -141,6 +164,71 @@ type GenericFile struct {
 	// matches what's in the manifest.
 	IngestSha256VerifiedAt time.Time `json:"ingest_sha_256_verified_at,omitempty"`
 
+	// The sha512 checksum for this file, as reported in the payload manifest.
+	// This may be empty if the bag had no sha512 manifest, or if this file
+	// was not listed in the manifest.
+	IngestManifestSha512 string `json:"ingest_manifest_sha512,omitempty"`
+
+	// The sha512 checksum we calculated when we read the actual file.
+	// We calculate this only if the bag validation config lists sha512
+	// among its FixityAlgorithms.
+	IngestSha512 string `json:"ingest_sha_512,omitempty"`
+
+	// Timestamp of when we calculated the sha512 checksum.
+	IngestSha512GeneratedAt time.Time `json:"ingest_sha_512_generated_at,omitempty"`
+
+	// Timestamp of when we verified that the sha512 checksum we calculated
+	// matches what's in the manifest.
+	IngestSha512VerifiedAt time.Time `json:"ingest_sha_512_verified_at,omitempty"`
+
+	// The blake2b checksum for this file, as reported in the payload
+	// manifest. This may be empty if the bag had no blake2b manifest,
+	// or if this file was not listed in the manifest.
+	IngestManifestBlake2b string `json:"ingest_manifest_blake2b,omitempty"`
+
+	// The 512-bit blake2b checksum we calculated when we read the actual
+	// file. We calculate this only if the bag validation config lists
+	// blake2b among its FixityAlgorithms.
+	IngestBlake2b string `json:"ingest_blake2b,omitempty"`
+
+	// Timestamp of when we calculated the blake2b checksum.
+	IngestBlake2bGeneratedAt time.Time `json:"ingest_blake2b_generated_at,omitempty"`
+
+	// Timestamp of when we verified that the blake2b checksum we calculated
+	// matches what's in the manifest.
+	IngestBlake2bVerifiedAt time.Time `json:"ingest_blake2b_verified_at,omitempty"`
+
+	// The sha1 checksum for this file, as reported in the payload manifest.
+	// Some legacy bags have sha1 manifests. The validator parses them only
+	// if the bag validation config lists sha1 among its FixityAlgorithms.
+	IngestManifestSha1 string `json:"ingest_manifest_sha1,omitempty"`
+
+	// The sha1 checksum we calculated when we read the actual file.
+	// We calculate this only if the bag validation config lists sha1
+	// among its FixityAlgorithms.
+	IngestSha1 string `json:"ingest_sha_1,omitempty"`
+
+	// Timestamp of when we calculated the sha1 checksum.
+	IngestSha1GeneratedAt time.Time `json:"ingest_sha_1_generated_at,omitempty"`
+
+	// Timestamp of when we verified that the sha1 checksum we calculated
+	// matches what's in the manifest.
+	IngestSha1VerifiedAt time.Time `json:"ingest_sha_1_verified_at,omitempty"`
+
+	// The crc32c and xxh64 checksums for this file, as reported in the
+	// payload manifest. These are fast, non-cryptographic checksums.
+	// The validator parses them only if the bag validation config lists
+	// them among its FixityAlgorithms, and it never accepts them as the
+	// only fixity for a file.
+	IngestManifestCrc32c string `json:"ingest_manifest_crc32c,omitempty"`
+	IngestManifestXxh64  string `json:"ingest_manifest_xxh64,omitempty"`
+
+	// The crc32c and xxh64 checksums we calculated when we read the
+	// actual file. We calculate these only if the bag validation config
+	// lists them among its FixityAlgorithms.
+	IngestCrc32c string `json:"ingest_crc32c,omitempty"`
+	IngestXxh64  string `json:"ingest_xxh64,omitempty"`
+
 	// The UUID assigned to this file. This will be its S3 key when we store it.
 	IngestUUID string `json:"ingest_uuid,omitempty"`
 
@@ -225,6 +313,8 @@ func NewGenericFile() *GenericFile {
 	return &GenericFile{
 		Checksums:                   make([]*Checksum, 0),
 		PremisEvents:                make([]*PremisEvent, 0),
+		StorageOptionHistory:        make([]*StorageOptionHistory, 0),
+		StorageRecords:              make([]*StorageRecord, 0),
 		IngestPreviousVersionExists: false,
 		IngestNeedsSave:             true,
 		StorageOption:               constants.StorageStandard,
@@ -238,6 +328,8 @@ func (gf *GenericFile) Clone() *GenericFile {
 	newFile.IntellectualObjectId = gf.IntellectualObjectId
 	newFile.IntellectualObjectIdentifier = gf.IntellectualObjectIdentifier
 	newFile.FileFormat = gf.FileFormat
+	newFile.PronomPuid = gf.PronomPuid
+	newFile.FormatConfidence = gf.FormatConfidence
 	newFile.URI = gf.URI
 	newFile.Size = gf.Size
 	newFile.FileCreated = gf.FileCreated
@@ -257,6 +349,22 @@ func (gf *GenericFile) Clone() *GenericFile {
 	newFile.IngestSha256 = gf.IngestSha256
 	newFile.IngestSha256GeneratedAt = gf.IngestSha256GeneratedAt
 	newFile.IngestSha256VerifiedAt = gf.IngestSha256VerifiedAt
+	newFile.IngestManifestSha512 = gf.IngestManifestSha512
+	newFile.IngestSha512 = gf.IngestSha512
+	newFile.IngestSha512GeneratedAt = gf.IngestSha512GeneratedAt
+	newFile.IngestSha512VerifiedAt = gf.IngestSha512VerifiedAt
+	newFile.IngestManifestBlake2b = gf.IngestManifestBlake2b
+	newFile.IngestBlake2b = gf.IngestBlake2b
+	newFile.IngestBlake2bGeneratedAt = gf.IngestBlake2bGeneratedAt
+	newFile.IngestBlake2bVerifiedAt = gf.IngestBlake2bVerifiedAt
+	newFile.IngestManifestSha1 = gf.IngestManifestSha1
+	newFile.IngestSha1 = gf.IngestSha1
+	newFile.IngestSha1GeneratedAt = gf.IngestSha1GeneratedAt
+	newFile.IngestSha1VerifiedAt = gf.IngestSha1VerifiedAt
+	newFile.IngestManifestCrc32c = gf.IngestManifestCrc32c
+	newFile.IngestManifestXxh64 = gf.IngestManifestXxh64
+	newFile.IngestCrc32c = gf.IngestCrc32c
+	newFile.IngestXxh64 = gf.IngestXxh64
 	newFile.IngestUUID = gf.IngestUUID
 	newFile.IngestUUIDGeneratedAt = gf.IngestUUIDGeneratedAt
 	newFile.IngestStorageURL = gf.IngestStorageURL
@@ -286,6 +394,16 @@ func (gf *GenericFile) Clone() *GenericFile {
 		newFile.Checksums[i] = checksum.Clone()
 	}
 
+	newFile.StorageOptionHistory = make([]*StorageOptionHistory, len(gf.StorageOptionHistory))
+	for i, history := range gf.StorageOptionHistory {
+		newFile.StorageOptionHistory[i] = history.Clone()
+	}
+
+	newFile.StorageRecords = make([]*StorageRecord, len(gf.StorageRecords))
+	for i, record := range gf.StorageRecords {
+		newFile.StorageRecords[i] = record.Clone()
+	}
+
 	return newFile
 }
 
@@ -361,6 +479,18 @@ func (gf *GenericFile) FindEventsByType(eventType string) []*PremisEvent {
 	return events
 }
 
+// UpdateLastFixityCheck sets LastFixityCheck to the time of this
+// file's most recent successful fixity check event, if that is later
+// than the current value. It returns the resulting LastFixityCheck.
+func (gf *GenericFile) UpdateLastFixityCheck() time.Time {
+	for _, event := range gf.FindEventsByType(constants.EventFixityCheck) {
+		if event.Outcome == string(constants.StatusSuccess) && event.DateTime.After(gf.LastFixityCheck) {
+			gf.LastFixityCheck = event.DateTime
+		}
+	}
+	return gf.LastFixityCheck
+}
+
 // Returns the event with the matching identifier (UUID)
 func (gf *GenericFile) FindEventByIdentifier(identifier string) *PremisEvent {
 	var matchingEvent *PremisEvent
@@ -373,6 +503,58 @@ func (gf *GenericFile) FindEventByIdentifier(identifier string) *PremisEvent {
 	return matchingEvent
 }
 
+// RecordStorageOption sets this file's StorageOption and, if that
+// differs from the option in the latest StorageOptionHistory entry,
+// appends a new entry. Returns true if it added an entry. Recording
+// the same option twice adds only one entry, so callers that retry
+// after an error won't clutter the history.
+func (gf *GenericFile) RecordStorageOption(option, reason string, workItemId int, changedAt time.Time) bool {
+	gf.StorageOption = option
+	latest := gf.LatestStorageOptionHistory()
+	if latest != nil && latest.Option == option {
+		return false
+	}
+	gf.StorageOptionHistory = append(gf.StorageOptionHistory, &StorageOptionHistory{
+		GenericFileId: gf.Id,
+		Option:        option,
+		ChangedAt:     changedAt,
+		Reason:        reason,
+		WorkItemId:    workItemId,
+	})
+	return true
+}
+
+// LatestStorageOptionHistory returns the most recent StorageOptionHistory
+// entry for this file, or nil if the file has no history.
+func (gf *GenericFile) LatestStorageOptionHistory() *StorageOptionHistory {
+	var latest *StorageOptionHistory
+	for _, history := range gf.StorageOptionHistory {
+		if history != nil && (latest == nil || !history.ChangedAt.Before(latest.ChangedAt)) {
+			latest = history
+		}
+	}
+	return latest
+}
+
+// StorageOptionAt returns the storage option this file had at the
+// specified time, according to its StorageOptionHistory. Returns an
+// empty string if the history has no entry on or before that time.
+func (gf *GenericFile) StorageOptionAt(when time.Time) string {
+	var match *StorageOptionHistory
+	for _, history := range gf.StorageOptionHistory {
+		if history == nil || history.ChangedAt.After(when) {
+			continue
+		}
+		if match == nil || !history.ChangedAt.Before(match.ChangedAt) {
+			match = history
+		}
+	}
+	if match == nil {
+		return ""
+	}
+	return match.Option
+}
+
 // Merge attributes from a recently-saved GenericFile into this one.
 // When we save a GenericFile in Pharos, it assigns attributes Id,
 // CreatedAt, and UpdatedAt. This function will save those attributes
@@ -428,6 +610,74 @@ func (gf *GenericFile) PreservationStorageFileName() (string, error) {
 	return parts[len(parts)-1], nil
 }
 
+// StorageBucketAndKey returns the name of the bucket and the key
+// under which this file's primary copy is stored, as described by its
+// primary StorageRecord or, if it has none, its URI.
+func (gf *GenericFile) StorageBucketAndKey() (string, string, error) {
+	if record := gf.findStorageRecord(StorageRolePrimary); record != nil {
+		return record.Bucket, record.Key, nil
+	}
+	parts := strings.Split(gf.URI, "/")
+	length := len(parts)
+	if length < 4 {
+		return "", "", fmt.Errorf("GenericFile URI '%s' is invalid", gf.URI)
+	}
+	return parts[length-2], parts[length-1], nil
+}
+
+// SetStorageRecord adds record to StorageRecords, replacing any
+// existing record with the same role.
+func (gf *GenericFile) SetStorageRecord(record *StorageRecord) {
+	for i, existing := range gf.StorageRecords {
+		if existing.Role == record.Role {
+			gf.StorageRecords[i] = record
+			return
+		}
+	}
+	gf.StorageRecords = append(gf.StorageRecords, record)
+}
+
+// GetStorageRecord returns the StorageRecord for the copy with the
+// specified role, or nil if there is no such copy. For files without
+// StorageRecords, this builds the primary record from URI and the
+// replica record from IngestReplicationURL.
+func (gf *GenericFile) GetStorageRecord(role string) *StorageRecord {
+	if record := gf.findStorageRecord(role); record != nil {
+		return record
+	}
+	var record *StorageRecord
+	if role == StorageRolePrimary && gf.URI != "" {
+		record, _ = NewStorageRecordFromURL(role, "", gf.URI, gf.IngestStoredAt)
+	} else if role == StorageRoleReplica && gf.IngestReplicationURL != "" {
+		record, _ = NewStorageRecordFromURL(role, "", gf.IngestReplicationURL, gf.IngestReplicatedAt)
+	}
+	return record
+}
+
+// GetStorageRecords returns a StorageRecord for each stored copy of
+// this file, primary first, then replica, then tertiary. See
+// GetStorageRecord.
+func (gf *GenericFile) GetStorageRecords() []*StorageRecord {
+	records := make([]*StorageRecord, 0)
+	for _, role := range []string{StorageRolePrimary, StorageRoleReplica, StorageRoleTertiary} {
+		if record := gf.GetStorageRecord(role); record != nil {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// findStorageRecord returns the StorageRecord with the specified
+// role from StorageRecords, or nil.
+func (gf *GenericFile) findStorageRecord(role string) *StorageRecord {
+	for _, record := range gf.StorageRecords {
+		if record != nil && record.Role == role {
+			return record
+		}
+	}
+	return nil
+}
+
 // BuildIngestEvents creates all of the ingest events for
 // this GenericFile. See the notes for IntellectualObject.BuildIngestEvents,
 // as they all apply here. This call is idempotent, so
@@ -645,6 +895,10 @@ func (gf *GenericFile) BuildIngestChecksums() error {
 	if err != nil {
 		return err
 	}
+	err = gf.buildIngestBlake2b()
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -667,6 +921,7 @@ func (gf *GenericFile) buildIngestMd5() error {
 			Digest:        gf.IngestMd5,
 			GenericFileId: gf.Id,
 		}
+		md5.setIngestSource(gf.IngestManifestMd5, gf.IngestMd5VerifiedAt)
 		gf.Checksums = append(gf.Checksums, md5)
 	}
 	return nil
@@ -691,11 +946,41 @@ func (gf *GenericFile) buildIngestSha256() error {
 			Digest:        gf.IngestSha256,
 			GenericFileId: gf.Id,
 		}
+		sha256.setIngestSource(gf.IngestManifestSha256, gf.IngestSha256VerifiedAt)
 		gf.Checksums = append(gf.Checksums, sha256)
 	}
 	return nil
 }
 
+// Creates the initial blake2b Checksum record for this file, if we
+// calculated a blake2b digest at ingest and the record does not
+// already exist. Unlike md5 and sha256, blake2b is optional.
+func (gf *GenericFile) buildIngestBlake2b() error {
+	if gf.IngestBlake2b == "" {
+		return nil
+	}
+	blake2b := gf.GetChecksumByAlgorithm(constants.AlgBlake2b)
+	if blake2b == nil {
+		if len(gf.IngestBlake2b) != 128 {
+			return fmt.Errorf("Cannot create blake2b Checksum object: "+
+				"IngestBlake2b '%s' is invalid.", gf.IngestBlake2b)
+		}
+		if gf.IngestBlake2bGeneratedAt.IsZero() {
+			return fmt.Errorf("Cannot create blake2b Checksum object: " +
+				"IngestBlake2bGeneratedAt is missing.")
+		}
+		blake2b = &Checksum{
+			Algorithm:     constants.AlgBlake2b,
+			DateTime:      gf.IngestBlake2bGeneratedAt,
+			Digest:        gf.IngestBlake2b,
+			GenericFileId: gf.Id,
+		}
+		blake2b.setIngestSource(gf.IngestManifestBlake2b, gf.IngestBlake2bVerifiedAt)
+		gf.Checksums = append(gf.Checksums, blake2b)
+	}
+	return nil
+}
+
 // Copy this GenericFile's Id and Identifier to the GenericFileId
 // and GenericFileIdentifier properties of all child objects,
 // including Checksums and Premis Events. This call exists because
@@ -711,4 +996,41 @@ func (gf *GenericFile) PropagateIdsToChildren() {
 	for _, checksum := range gf.Checksums {
 		checksum.GenericFileId = gf.Id
 	}
+	for _, history := range gf.StorageOptionHistory {
+		history.GenericFileId = gf.Id
+	}
+}
+
+// Validate returns a ModelError if the file is missing a field Pharos
+// requires, has a malformed identifier or an unknown storage option or
+// state, or if any of its checksums or events is not valid. Pharos
+// saves a file's checksums and events along with the file, so we
+// check them here, too.
+func (gf *GenericFile) Validate() error {
+	checker := newModelChecker("GenericFile", gf.Identifier)
+	checker.require("Identifier", gf.Identifier)
+	checker.requireSlashes("Identifier", gf.Identifier, 2, "institution/bag/path")
+	checker.requirePrefix("Identifier", gf.Identifier,
+		"IntellectualObjectIdentifier", gf.IntellectualObjectIdentifier)
+	if gf.IntellectualObjectId <= 0 {
+		checker.addProblem("IntellectualObjectId is required")
+	}
+	checker.require("FileFormat", gf.FileFormat)
+	checker.require("URI", gf.URI)
+	if gf.Size < 0 {
+		checker.addProblem("Size cannot be negative")
+	}
+	checker.requireOneOf("StorageOption", gf.StorageOption, constants.StorageOptions, false)
+	checker.requireOneOf("State", gf.State, validStates, false)
+	for _, checksum := range gf.Checksums {
+		if err := checksum.Validate(); err != nil {
+			checker.addProblem(err.Error())
+		}
+	}
+	for _, event := range gf.PremisEvents {
+		if err := event.Validate(); err != nil {
+			checker.addProblem(err.Error())
+		}
+	}
+	return checker.result()
 }
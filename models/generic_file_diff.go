@@ -0,0 +1,133 @@
+package models
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"sort"
+)
+
+// FieldDiff describes a field that has different values in two
+// GenericFiles. Value comes from the file whose Diff method was
+// called, and OtherValue comes from the file passed in to Diff.
+type FieldDiff struct {
+	// Field is the name of the field, as in the GenericFile's
+	// JSON, such as "size" or "uri". Checksums are named
+	// "checksum:" plus the algorithm, as in "checksum:sha256".
+	Field      string `json:"field"`
+	Value      string `json:"value"`
+	OtherValue string `json:"other_value"`
+}
+
+// String returns a description of the difference, such as
+// "size: 100 != 200".
+func (diff *FieldDiff) String() string {
+	return fmt.Sprintf("%s: %s != %s", diff.Field, diff.Value, diff.OtherValue)
+}
+
+// Diff returns the fields of gf that have different values in other.
+// It compares size, file format, storage URL, storage option, and the
+// latest md5, sha256, sha512 and blake2b checksums. A file that was just
+// ingested may not have Checksum records yet, so for checksums, Diff
+// falls back to the digests calculated at ingest. It does not compare
+// ids, timestamps or events, since Pharos assigns those. Returns an
+// empty list if the files match.
+func (gf *GenericFile) Diff(other *GenericFile) []*FieldDiff {
+	diffs := make([]*FieldDiff, 0)
+	compare := func(field, value, otherValue string) {
+		if value != otherValue {
+			diffs = append(diffs, &FieldDiff{
+				Field:      field,
+				Value:      value,
+				OtherValue: otherValue,
+			})
+		}
+	}
+	compare("size", fmt.Sprintf("%d", gf.Size), fmt.Sprintf("%d", other.Size))
+	compare("file_format", gf.FileFormat, other.FileFormat)
+	compare("uri", gf.URI, other.URI)
+	compare("storage_option", gf.StorageOption, other.StorageOption)
+	for _, alg := range constants.ChecksumAlgorithms {
+		compare("checksum:"+alg, gf.latestDigest(alg), other.latestDigest(alg))
+	}
+	return diffs
+}
+
+// latestDigest returns the digest of the file's latest checksum for
+// the specified algorithm. If the file has no such checksum, it returns
+// the digest calculated at ingest, which may be empty.
+func (gf *GenericFile) latestDigest(algorithm string) string {
+	if checksum := gf.GetChecksumByAlgorithm(algorithm); checksum != nil {
+		return checksum.Digest
+	}
+	switch algorithm {
+	case constants.AlgMd5:
+		return gf.IngestMd5
+	case constants.AlgSha256:
+		return gf.IngestSha256
+	case constants.AlgSha512:
+		return gf.IngestSha512
+	case constants.AlgBlake2b:
+		return gf.IngestBlake2b
+	}
+	return ""
+}
+
+// GenericFileListDiff describes how two lists of GenericFiles differ.
+// Files are matched by identifier.
+type GenericFileListDiff struct {
+	// OnlyInThis lists the identifiers of files that are only in
+	// the first list, sorted.
+	OnlyInThis []string `json:"only_in_this"`
+	// OnlyInOther lists the identifiers of files that are only in
+	// the second list, sorted.
+	OnlyInOther []string `json:"only_in_other"`
+	// Changed maps the identifier of each file that is in both
+	// lists to the fields that differ, if any fields differ.
+	Changed map[string][]*FieldDiff `json:"changed"`
+}
+
+// HasDifferences returns true if the lists differ in any way.
+func (listDiff *GenericFileListDiff) HasDifferences() bool {
+	return len(listDiff.OnlyInThis) > 0 || len(listDiff.OnlyInOther) > 0 ||
+		len(listDiff.Changed) > 0
+}
+
+// DiffGenericFiles compares this object's GenericFiles with otherFiles,
+// such as the files that Pharos has recorded for this object, and
+// returns a description of the differences.
+func (obj *IntellectualObject) DiffGenericFiles(otherFiles []*GenericFile) *GenericFileListDiff {
+	listDiff := &GenericFileListDiff{
+		OnlyInThis:  make([]string, 0),
+		OnlyInOther: make([]string, 0),
+		Changed:     make(map[string][]*FieldDiff),
+	}
+	others := make(map[string]*GenericFile, len(otherFiles))
+	for _, gf := range otherFiles {
+		if gf != nil {
+			others[gf.Identifier] = gf
+		}
+	}
+	seen := make(map[string]bool, len(obj.GenericFiles))
+	for _, gf := range obj.GenericFiles {
+		if gf == nil {
+			continue
+		}
+		seen[gf.Identifier] = true
+		other, ok := others[gf.Identifier]
+		if !ok {
+			listDiff.OnlyInThis = append(listDiff.OnlyInThis, gf.Identifier)
+			continue
+		}
+		if diffs := gf.Diff(other); len(diffs) > 0 {
+			listDiff.Changed[gf.Identifier] = diffs
+		}
+	}
+	for identifier := range others {
+		if !seen[identifier] {
+			listDiff.OnlyInOther = append(listDiff.OnlyInOther, identifier)
+		}
+	}
+	sort.Strings(listDiff.OnlyInThis)
+	sort.Strings(listDiff.OnlyInOther)
+	return listDiff
+}
@@ -0,0 +1,92 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sha256Digest = "1234567890123456789012345678901234567890123456789012345678901234"
+
+func diffTestFile(identifier string) *models.GenericFile {
+	gf := models.NewGenericFile()
+	gf.Identifier = identifier
+	gf.Size = 100
+	gf.FileFormat = "text/plain"
+	gf.URI = "https://s3.amazonaws.com/aptrust.test.preservation/some-uuid"
+	gf.StorageOption = constants.StorageStandard
+	gf.Checksums = append(gf.Checksums,
+		&models.Checksum{Algorithm: constants.AlgMd5, Digest: digest, DateTime: time.Now().UTC()},
+		&models.Checksum{Algorithm: constants.AlgSha256, Digest: sha256Digest, DateTime: time.Now().UTC()})
+	return gf
+}
+
+func TestGenericFileDiff(t *testing.T) {
+	gf := diffTestFile("test.edu/bag/data/file.txt")
+	other := diffTestFile("test.edu/bag/data/file.txt")
+	assert.Empty(t, gf.Diff(other))
+
+	other.Size = 200
+	other.FileFormat = "application/pdf"
+	other.URI = "https://s3.amazonaws.com/aptrust.test.preservation/other-uuid"
+	other.Checksums = append(other.Checksums, &models.Checksum{
+		Algorithm: constants.AlgSha256,
+		Digest:    "9999567890123456789012345678901234567890123456789012345678901234",
+		DateTime:  time.Now().UTC().Add(time.Hour),
+	})
+	diffs := gf.Diff(other)
+	require.Equal(t, 4, len(diffs))
+	assert.Equal(t, "size: 100 != 200", diffs[0].String())
+	assert.Equal(t, "file_format", diffs[1].Field)
+	assert.Equal(t, "uri", diffs[2].Field)
+	assert.Equal(t, "checksum:sha256", diffs[3].Field)
+	assert.Equal(t, sha256Digest, diffs[3].Value)
+	assert.Equal(t, "9999567890123456789012345678901234567890123456789012345678901234", diffs[3].OtherValue)
+}
+
+func TestGenericFileDiffUsesIngestDigests(t *testing.T) {
+	// A freshly ingested file has no Checksum records yet.
+	ingested := diffTestFile("test.edu/bag/data/file.txt")
+	ingested.Checksums = nil
+	ingested.IngestMd5 = digest
+	ingested.IngestSha256 = sha256Digest
+	recorded := diffTestFile("test.edu/bag/data/file.txt")
+	assert.Empty(t, ingested.Diff(recorded))
+
+	ingested.IngestMd5 = "00000000000000000000000000000000"
+	diffs := ingested.Diff(recorded)
+	require.Equal(t, 1, len(diffs))
+	assert.Equal(t, "checksum:md5", diffs[0].Field)
+}
+
+func TestIntellectualObjectDiffGenericFiles(t *testing.T) {
+	obj := models.NewIntellectualObject()
+	obj.GenericFiles = append(obj.GenericFiles,
+		diffTestFile("test.edu/bag/data/same.txt"),
+		diffTestFile("test.edu/bag/data/changed.txt"),
+		diffTestFile("test.edu/bag/data/only_ingested.txt"))
+	changed := diffTestFile("test.edu/bag/data/changed.txt")
+	changed.Size = 5
+	recorded := []*models.GenericFile{
+		diffTestFile("test.edu/bag/data/same.txt"),
+		changed,
+		diffTestFile("test.edu/bag/data/z_only_recorded.txt"),
+		diffTestFile("test.edu/bag/data/a_only_recorded.txt"),
+	}
+
+	listDiff := obj.DiffGenericFiles(recorded)
+	assert.True(t, listDiff.HasDifferences())
+	assert.Equal(t, []string{"test.edu/bag/data/only_ingested.txt"}, listDiff.OnlyInThis)
+	assert.Equal(t, []string{"test.edu/bag/data/a_only_recorded.txt",
+		"test.edu/bag/data/z_only_recorded.txt"}, listDiff.OnlyInOther)
+	require.Equal(t, 1, len(listDiff.Changed))
+	require.Equal(t, 1, len(listDiff.Changed["test.edu/bag/data/changed.txt"]))
+	assert.Equal(t, "size", listDiff.Changed["test.edu/bag/data/changed.txt"][0].Field)
+
+	listDiff = obj.DiffGenericFiles(obj.GenericFiles)
+	assert.False(t, listDiff.HasDifferences())
+}
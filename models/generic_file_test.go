@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -190,6 +191,86 @@ func TestPreservationStorageFileName(t *testing.T) {
 	assert.Equal(t, "a58a7c00-392f-11e4-916c-0800200c9a66", fileName)
 }
 
+func TestStorageBucketAndKey(t *testing.T) {
+	genericFile := models.GenericFile{}
+	_, _, err := genericFile.StorageBucketAndKey()
+	assert.NotNil(t, err)
+	genericFile.URI = "https://s3.amazonaws.com/aptrust.test.preservation/a58a7c00-392f-11e4-916c-0800200c9a66"
+	bucket, key, err := genericFile.StorageBucketAndKey()
+	require.Nil(t, err)
+	assert.Equal(t, "aptrust.test.preservation", bucket)
+	assert.Equal(t, "a58a7c00-392f-11e4-916c-0800200c9a66", key)
+
+	// The primary StorageRecord, if there is one, wins.
+	genericFile.SetStorageRecord(&models.StorageRecord{
+		Role:   models.StorageRolePrimary,
+		Bucket: "aptrust.preservation.va",
+		Key:    "b58a7c00-392f-11e4-916c-0800200c9a66",
+	})
+	bucket, key, err = genericFile.StorageBucketAndKey()
+	require.Nil(t, err)
+	assert.Equal(t, "aptrust.preservation.va", bucket)
+	assert.Equal(t, "b58a7c00-392f-11e4-916c-0800200c9a66", key)
+}
+
+func TestGetStorageRecord(t *testing.T) {
+	storedAt := time.Date(2019, 3, 4, 12, 0, 0, 0, time.UTC)
+	replicatedAt := storedAt.Add(time.Hour)
+	gf := models.NewGenericFile()
+	assert.Nil(t, gf.GetStorageRecord(models.StorageRolePrimary))
+	assert.Empty(t, gf.GetStorageRecords())
+
+	// Files without StorageRecords fall back to URI and
+	// IngestReplicationURL.
+	gf.URI = "https://s3.amazonaws.com/aptrust.test.preservation/a58a7c00"
+	gf.IngestStoredAt = storedAt
+	gf.IngestReplicationURL = "https://s3.amazonaws.com/aptrust.test.replication/a58a7c00"
+	gf.IngestReplicatedAt = replicatedAt
+	primary := gf.GetStorageRecord(models.StorageRolePrimary)
+	require.NotNil(t, primary)
+	assert.Equal(t, "aptrust.test.preservation", primary.Bucket)
+	assert.Equal(t, "a58a7c00", primary.Key)
+	assert.Equal(t, storedAt, primary.StoredAt)
+	assert.Empty(t, primary.Region)
+	replica := gf.GetStorageRecord(models.StorageRoleReplica)
+	require.NotNil(t, replica)
+	assert.Equal(t, "aptrust.test.replication", replica.Bucket)
+	assert.Equal(t, replicatedAt, replica.StoredAt)
+	assert.Nil(t, gf.GetStorageRecord(models.StorageRoleTertiary))
+	assert.Equal(t, 2, len(gf.GetStorageRecords()))
+
+	// Explicit records take precedence, and SetStorageRecord
+	// replaces the record with the same role.
+	gf.SetStorageRecord(&models.StorageRecord{
+		Role:   models.StorageRoleTertiary,
+		Bucket: "offsite",
+		Key:    "a58a7c00",
+	})
+	gf.SetStorageRecord(&models.StorageRecord{
+		Role:   models.StorageRolePrimary,
+		Region: constants.AWSOhio,
+		Bucket: "aptrust.preservation.oh",
+		Key:    "a58a7c00",
+	})
+	gf.SetStorageRecord(&models.StorageRecord{
+		Role:   models.StorageRolePrimary,
+		Region: constants.AWSVirginia,
+		Bucket: "aptrust.preservation.va",
+		Key:    "a58a7c00",
+	})
+	assert.Equal(t, 2, len(gf.StorageRecords))
+	primary = gf.GetStorageRecord(models.StorageRolePrimary)
+	require.NotNil(t, primary)
+	assert.Equal(t, constants.AWSVirginia, primary.Region)
+	assert.Equal(t, "aptrust.preservation.va", primary.Bucket)
+
+	records := gf.GetStorageRecords()
+	require.Equal(t, 3, len(records))
+	assert.Equal(t, models.StorageRolePrimary, records[0].Role)
+	assert.Equal(t, models.StorageRoleReplica, records[1].Role)
+	assert.Equal(t, models.StorageRoleTertiary, records[2].Role)
+}
+
 func TestFindEventsByType(t *testing.T) {
 	filename := filepath.Join("testdata", "json_objects", "intel_obj.json")
 	intelObj, err := testutil.LoadIntelObjFixture(filename)
@@ -214,6 +295,32 @@ func TestFindEventsByType(t *testing.T) {
 	}
 }
 
+func TestUpdateLastFixityCheck(t *testing.T) {
+	gf := testutil.MakeGenericFile(0, 0, "test.edu/bag")
+	gf.LastFixityCheck = time.Time{}
+	assert.True(t, gf.UpdateLastFixityCheck().IsZero())
+
+	digest := "12345678901234567890123456789012"
+	older := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(48 * time.Hour)
+	newest := newer.Add(48 * time.Hour)
+	for _, check := range []struct {
+		at      time.Time
+		matched bool
+	}{{older, true}, {newer, true}, {newest, false}} {
+		event, err := models.NewEventGenericFileFixityCheck(check.at, constants.AlgMd5, digest, check.matched)
+		require.Nil(t, err)
+		gf.PremisEvents = append(gf.PremisEvents, event)
+	}
+	// Failed checks don't count.
+	assert.Equal(t, newer, gf.UpdateLastFixityCheck())
+	assert.Equal(t, newer, gf.LastFixityCheck)
+
+	// Never moves backward.
+	gf.LastFixityCheck = newest
+	assert.Equal(t, newest, gf.UpdateLastFixityCheck())
+}
+
 func TestFindEventByIdentifier(t *testing.T) {
 	filename := filepath.Join("testdata", "json_objects", "intel_obj.json")
 	intelObj, err := testutil.LoadIntelObjFixture(filename)
@@ -237,6 +344,8 @@ func TestSerializeFileForPharos(t *testing.T) {
 	filename := filepath.Join("testdata", "json_objects", "intel_obj.json")
 	intelObj, err := testutil.LoadIntelObjFixture(filename)
 	genericFile := intelObj.GenericFiles[1]
+	changedAt := time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC)
+	genericFile.RecordStorageOption(constants.StorageStandard, "Stored at ingest", 999, changedAt)
 	data, err := genericFile.SerializeForPharos()
 	if err != nil {
 		t.Errorf("Error serializing for Pharos: %v", err)
@@ -262,6 +371,10 @@ func TestSerializeFileForPharos(t *testing.T) {
 	assert.EqualValues(t, 741, hash["intellectual_object_id"])
 	assert.Equal(t, "application/xml", hash["file_format"])
 	assert.Equal(t, "uc.edu/cin.675812/data/metadata.xml", hash["identifier"])
+	_, hasPuid := hash["pronom_puid"]
+	assert.False(t, hasPuid)
+	_, hasConfidence := hash["format_confidence"]
+	assert.False(t, hasConfidence)
 
 	// Note the Rails 4 naming convention
 	checksums := hash["checksums_attributes"].([]interface{})
@@ -291,6 +404,16 @@ func TestSerializeFileForPharos(t *testing.T) {
 	assert.Equal(t, "fixity_check", event0["event_type"])
 	assert.Equal(t, "Fixity check against registered hash", event0["detail"])
 	assert.Equal(t, "uc.edu/cin.675812", event0["intellectual_object_identifier"])
+
+	// Note the Rails 4 naming convention
+	histories := hash["storage_option_histories_attributes"].([]interface{})
+	require.Equal(t, 1, len(histories))
+	history0 := histories[0].(map[string]interface{})
+	assert.EqualValues(t, nil, history0["id"]) // Don't serialize 0 ids. Pharos pukes.
+	assert.Equal(t, constants.StorageStandard, history0["option"])
+	assert.Equal(t, "2018-03-04T05:06:07Z", history0["changed_at"])
+	assert.Equal(t, "Stored at ingest", history0["reason"])
+	assert.EqualValues(t, 999, history0["work_item_id"])
 }
 
 func TestBuildIngestEvents(t *testing.T) {
@@ -492,6 +615,49 @@ func TestBuildIngestChecksums(t *testing.T) {
 	assert.Equal(t, 2, len(gf.Checksums))
 }
 
+func TestBuildIngestChecksumsBlake2b(t *testing.T) {
+	gf := testutil.MakeGenericFile(0, 0, "test.edu/test_bag/file.txt")
+	gf.IngestBlake2b = strings.Repeat("b", 128)
+	gf.IngestBlake2bGeneratedAt = time.Now().UTC()
+	gf.IngestManifestBlake2b = gf.IngestBlake2b
+	gf.IngestBlake2bVerifiedAt = gf.IngestBlake2bGeneratedAt
+	require.Nil(t, gf.BuildIngestChecksums())
+	assert.Equal(t, 3, len(gf.Checksums))
+	blake2b := gf.GetChecksumByAlgorithm(constants.AlgBlake2b)
+	require.NotNil(t, blake2b)
+	assert.Equal(t, gf.IngestBlake2b, blake2b.Digest)
+	assert.Equal(t, constants.ChecksumSourceManifest, blake2b.Source)
+	assert.Nil(t, blake2b.Validate())
+
+	// A bad digest is an error, not a skipped checksum.
+	gf = testutil.MakeGenericFile(0, 0, "test.edu/test_bag/file.txt")
+	gf.IngestBlake2b = "not a digest"
+	gf.IngestBlake2bGeneratedAt = time.Now().UTC()
+	assert.NotNil(t, gf.BuildIngestChecksums())
+}
+
+func TestBuildIngestChecksumsSource(t *testing.T) {
+	gf := testutil.MakeGenericFile(0, 0, "test.edu/test_bag/file.txt")
+	verifiedAt := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	gf.IngestManifestMd5 = gf.IngestMd5
+	gf.IngestMd5VerifiedAt = verifiedAt
+	gf.IngestManifestSha256 = ""
+	gf.IngestSha256VerifiedAt = time.Time{}
+	require.Nil(t, gf.BuildIngestChecksums())
+
+	// The depositor supplied the md5 digest, and we verified it.
+	md5 := gf.GetChecksumByAlgorithm(constants.AlgMd5)
+	require.NotNil(t, md5)
+	assert.Equal(t, constants.ChecksumSourceManifest, md5.Source)
+	assert.Equal(t, verifiedAt, md5.VerifiedAt)
+
+	// The bag had no sha256 manifest, so we calculated that one.
+	sha256 := gf.GetChecksumByAlgorithm(constants.AlgSha256)
+	require.NotNil(t, sha256)
+	assert.Equal(t, constants.ChecksumSourceIngest, sha256.Source)
+	assert.True(t, sha256.VerifiedAt.IsZero())
+}
+
 func TestPropagateIdsToChildren(t *testing.T) {
 	// Make a generic file with 6 events and 2 checksums
 	gf := testutil.MakeGenericFile(6, 2, "test.edu/test_bag/file.txt")
@@ -557,8 +723,52 @@ func TestGenericFileMergeAttributes(t *testing.T) {
 
 }
 
+func TestRecordStorageOption(t *testing.T) {
+	gf := models.NewGenericFile()
+	gf.Id = 100
+	t1 := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Nil(t, gf.LatestStorageOptionHistory())
+
+	assert.True(t, gf.RecordStorageOption(constants.StorageStandard, "Stored at ingest", 1, t1))
+	// Same option again should not add an entry.
+	assert.False(t, gf.RecordStorageOption(constants.StorageStandard, "Retry", 1, t1))
+	assert.True(t, gf.RecordStorageOption(constants.StorageGlacierOH, "Migrated", 2, t2))
+	assert.Equal(t, constants.StorageGlacierOH, gf.StorageOption)
+
+	require.Equal(t, 2, len(gf.StorageOptionHistory))
+	assert.Equal(t, 100, gf.StorageOptionHistory[0].GenericFileId)
+	assert.Equal(t, "Stored at ingest", gf.StorageOptionHistory[0].Reason)
+	latest := gf.LatestStorageOptionHistory()
+	require.NotNil(t, latest)
+	assert.Equal(t, constants.StorageGlacierOH, latest.Option)
+	assert.Equal(t, "Migrated", latest.Reason)
+	assert.Equal(t, 2, latest.WorkItemId)
+	assert.Equal(t, t2, latest.ChangedAt)
+}
+
+func TestStorageOptionAt(t *testing.T) {
+	gf := models.NewGenericFile()
+	t1 := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	gf.RecordStorageOption(constants.StorageStandard, "Stored at ingest", 1, t1)
+	gf.RecordStorageOption(constants.StorageGlacierOR, "Migrated", 2, t2)
+
+	assert.Equal(t, "", gf.StorageOptionAt(t1.Add(-1*time.Hour)))
+	assert.Equal(t, constants.StorageStandard, gf.StorageOptionAt(t1))
+	assert.Equal(t, constants.StorageStandard, gf.StorageOptionAt(t2.Add(-1*time.Hour)))
+	assert.Equal(t, constants.StorageGlacierOR, gf.StorageOptionAt(t2))
+	assert.Equal(t, constants.StorageGlacierOR, gf.StorageOptionAt(time.Now()))
+}
+
 func TestGenericFileClone(t *testing.T) {
 	gf := testutil.MakeGenericFile(3, 3, "test.edu/file1.txt")
+	gf.RecordStorageOption(constants.StorageGlacierVA, "Stored at ingest", 5, time.Now().UTC())
+	gf.SetStorageRecord(&models.StorageRecord{
+		Role:   models.StorageRolePrimary,
+		Bucket: "aptrust.preservation.va",
+		Key:    "a58a7c00",
+	})
 	clone := gf.Clone()
 	assert.Equal(t, clone.Id, gf.Id)
 	assert.Equal(t, clone.Identifier, gf.Identifier)
@@ -635,4 +845,12 @@ func TestGenericFileClone(t *testing.T) {
 		assert.Equal(t, clonedChecksum.CreatedAt, origChecksum.CreatedAt)
 		assert.Equal(t, clonedChecksum.UpdatedAt, origChecksum.UpdatedAt)
 	}
+
+	require.Equal(t, 1, len(clone.StorageOptionHistory))
+	assert.Equal(t, gf.StorageOptionHistory[0], clone.StorageOptionHistory[0])
+	assert.False(t, gf.StorageOptionHistory[0] == clone.StorageOptionHistory[0])
+
+	require.Equal(t, 1, len(clone.StorageRecords))
+	assert.Equal(t, gf.StorageRecords[0], clone.StorageRecords[0])
+	assert.False(t, gf.StorageRecords[0] == clone.StorageRecords[0])
 }
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"sync"
+)
+
+// GlacierRequestTracker keeps count of the Glacier retrieval requests
+// each institution has in flight, so apt_glacier_restore_init can cap
+// the number of simultaneous requests per institution. Counts are
+// kept per WorkItem, so that each WorkItem can update its own count
+// on every run without double-counting. This structure uses a mutex,
+// so it's safe to share across goroutines.
+//
+// A tracker created with NewPersistentGlacierRequestTracker saves its
+// counts to a GlacierRequestStore after every change, so a restarted
+// worker knows which requests are still in flight.
+type GlacierRequestTracker struct {
+	limit   int
+	counts  map[string]map[int]int
+	store   GlacierRequestStore
+	saveErr error
+	mutex   *sync.Mutex
+}
+
+// GlacierRequestStore saves and loads the counts of a
+// GlacierRequestTracker. storage.BoltDB implements this, so the
+// counts can live in a BoltDB file, like the validator's .valdb.
+type GlacierRequestStore interface {
+	GlacierRequestCounts() (map[string]map[int]int, error)
+	SaveGlacierRequestCounts(counts map[string]map[int]int) error
+}
+
+// NewGlacierRequestTracker creates a new GlacierRequestTracker.
+// Param limit is the maximum number of simultaneous requests per
+// institution. A limit of zero or less means no limit.
+func NewGlacierRequestTracker(limit int) *GlacierRequestTracker {
+	return &GlacierRequestTracker{
+		limit:  limit,
+		counts: make(map[string]map[int]int),
+		mutex:  &sync.Mutex{},
+	}
+}
+
+// NewPersistentGlacierRequestTracker creates a GlacierRequestTracker
+// that starts with the counts in store, and saves its counts back to
+// store whenever they change. It returns an error if it can't load
+// the counts.
+func NewPersistentGlacierRequestTracker(limit int, store GlacierRequestStore) (*GlacierRequestTracker, error) {
+	counts, err := store.GlacierRequestCounts()
+	if err != nil {
+		return nil, err
+	}
+	tracker := NewGlacierRequestTracker(limit)
+	for institution, items := range counts {
+		if len(items) > 0 {
+			tracker.counts[institution] = items
+		}
+	}
+	tracker.store = store
+	return tracker, nil
+}
+
+// Limit returns the per-institution limit. Zero means no limit.
+func (tracker *GlacierRequestTracker) Limit() int {
+	return tracker.limit
+}
+
+// SetInFlight records the number of requests WorkItem workItemId
+// currently has in flight for the specified institution.
+func (tracker *GlacierRequestTracker) SetInFlight(institution string, workItemId, count int) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if _, ok := tracker.counts[institution]; !ok {
+		tracker.counts[institution] = make(map[int]int)
+	}
+	tracker.counts[institution][workItemId] = count
+	tracker.save()
+}
+
+// Remove stops tracking WorkItem workItemId. Call this when all of
+// the WorkItem's files are in S3, or when the WorkItem has failed.
+func (tracker *GlacierRequestTracker) Remove(institution string, workItemId int) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if items, ok := tracker.counts[institution]; ok {
+		delete(items, workItemId)
+		if len(items) == 0 {
+			delete(tracker.counts, institution)
+		}
+	}
+	tracker.save()
+}
+
+// InFlight returns the total number of requests in flight for the
+// specified institution across all WorkItems.
+func (tracker *GlacierRequestTracker) InFlight(institution string) int {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	total := 0
+	for _, count := range tracker.counts[institution] {
+		total += count
+	}
+	return total
+}
+
+// Reserve tries to reserve a slot for one new request by WorkItem
+// workItemId on behalf of the specified institution. It returns true
+// and increments the WorkItem's count if the institution is under its
+// limit, or false if the request should be deferred.
+func (tracker *GlacierRequestTracker) Reserve(institution string, workItemId int) bool {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if _, ok := tracker.counts[institution]; !ok {
+		tracker.counts[institution] = make(map[int]int)
+	}
+	if tracker.limit > 0 {
+		total := 0
+		for _, count := range tracker.counts[institution] {
+			total += count
+		}
+		if total >= tracker.limit {
+			return false
+		}
+	}
+	tracker.counts[institution][workItemId] += 1
+	tracker.save()
+	return true
+}
+
+// Release gives back a slot reserved with Reserve. Call this when
+// Glacier did not accept the request.
+func (tracker *GlacierRequestTracker) Release(institution string, workItemId int) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if items, ok := tracker.counts[institution]; ok && items[workItemId] > 0 {
+		items[workItemId] -= 1
+	}
+	tracker.save()
+}
+
+// WorkItems returns the ids of the WorkItems the tracker is counting,
+// mapped to their institutions. A worker that loaded its counts from
+// a GlacierRequestStore can use this to drop WorkItems that finished
+// while it was down.
+func (tracker *GlacierRequestTracker) WorkItems() map[int]string {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	workItems := make(map[int]string)
+	for institution, items := range tracker.counts {
+		for workItemId := range items {
+			workItems[workItemId] = institution
+		}
+	}
+	return workItems
+}
+
+// SaveError returns the error from the most recent attempt to save
+// the counts to the tracker's GlacierRequestStore, or nil if that
+// attempt succeeded or the tracker has no store. The counts in memory
+// are correct either way.
+func (tracker *GlacierRequestTracker) SaveError() error {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	return tracker.saveErr
+}
+
+// save writes the counts to the tracker's store, if it has one.
+// Caller must hold the mutex.
+func (tracker *GlacierRequestTracker) save() {
+	if tracker.store != nil {
+		tracker.saveErr = tracker.store.SaveGlacierRequestCounts(tracker.counts)
+	}
+}
@@ -0,0 +1,57 @@
+package models_test
+
+import (
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewGlacierRequestTracker(t *testing.T) {
+	tracker := models.NewGlacierRequestTracker(10)
+	require.NotNil(t, tracker)
+	assert.Equal(t, 10, tracker.Limit())
+	assert.Equal(t, 0, tracker.InFlight("test.edu"))
+}
+
+func TestGlacierRequestTrackerReserve(t *testing.T) {
+	tracker := models.NewGlacierRequestTracker(3)
+	tracker.SetInFlight("test.edu", 100, 1)
+	assert.True(t, tracker.Reserve("test.edu", 200))
+	assert.True(t, tracker.Reserve("test.edu", 200))
+	assert.Equal(t, 3, tracker.InFlight("test.edu"))
+
+	// At limit for test.edu, but not for example.edu.
+	assert.False(t, tracker.Reserve("test.edu", 200))
+	assert.False(t, tracker.Reserve("test.edu", 300))
+	assert.True(t, tracker.Reserve("example.edu", 300))
+
+	// Release frees a slot.
+	tracker.Release("test.edu", 200)
+	assert.Equal(t, 2, tracker.InFlight("test.edu"))
+	assert.True(t, tracker.Reserve("test.edu", 300))
+
+	// Remove frees all of a WorkItem's slots.
+	tracker.Remove("test.edu", 100)
+	tracker.Remove("test.edu", 200)
+	assert.Equal(t, 1, tracker.InFlight("test.edu"))
+}
+
+func TestGlacierRequestTrackerNoLimit(t *testing.T) {
+	tracker := models.NewGlacierRequestTracker(0)
+	for i := 0; i < 1000; i++ {
+		assert.True(t, tracker.Reserve("test.edu", 100))
+	}
+	assert.Equal(t, 1000, tracker.InFlight("test.edu"))
+}
+
+func TestGlacierRequestTrackerSetInFlight(t *testing.T) {
+	tracker := models.NewGlacierRequestTracker(10)
+	tracker.SetInFlight("test.edu", 100, 4)
+	tracker.SetInFlight("test.edu", 200, 3)
+	assert.Equal(t, 7, tracker.InFlight("test.edu"))
+
+	// SetInFlight replaces, rather than adds to, a WorkItem's count.
+	tracker.SetInFlight("test.edu", 100, 1)
+	assert.Equal(t, 4, tracker.InFlight("test.edu"))
+}
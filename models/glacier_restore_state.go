@@ -40,6 +40,11 @@ type GlacierRestoreState struct {
 	// Requests are the requests we've made (or need to make)
 	// to Glacier to retrieve the objects we need to retrieve.
 	Requests []*GlacierRestoreRequest
+	// Deferred is a list of GenericFile identifiers whose retrieval
+	// requests we held back on the last run because the institution
+	// had reached its limit of simultaneous Glacier requests. We'll
+	// issue these on subsequent runs, as earlier requests complete.
+	Deferred []string
 }
 
 // NewGlacierRestoreState creates a new GlacierRestoreState object.
@@ -49,9 +54,46 @@ func NewGlacierRestoreState(message *nsq.Message, workItem *WorkItem) *GlacierRe
 		WorkItem:    workItem,
 		WorkSummary: NewWorkSummary(),
 		Requests:    make([]*GlacierRestoreRequest, 0),
+		Deferred:    make([]string, 0),
 	}
 }
 
+// DeferRequest records that we held back the retrieval request for
+// the specified GenericFile because of the per-institution limit.
+func (state *GlacierRestoreState) DeferRequest(gfIdentifier string) {
+	for _, identifier := range state.Deferred {
+		if identifier == gfIdentifier {
+			return
+		}
+	}
+	state.Deferred = append(state.Deferred, gfIdentifier)
+}
+
+// ClearDeferred clears the list of deferred requests. Call this at
+// the start of each run, since we'll rebuild the list as we go.
+func (state *GlacierRestoreState) ClearDeferred() {
+	state.Deferred = make([]string, 0)
+}
+
+// HasDeferredRequests returns true if any retrieval requests were
+// held back on the last run because of the per-institution limit.
+func (state *GlacierRestoreState) HasDeferredRequests() bool {
+	return len(state.Deferred) > 0
+}
+
+// InFlightCount returns the number of retrieval requests that Glacier
+// has accepted but that have not yet completed (i.e. the files are
+// not yet available in S3).
+func (state *GlacierRestoreState) InFlightCount() int {
+	count := 0
+	for _, req := range state.Requests {
+		if req.IsPending() {
+			count += 1
+		}
+	}
+	return count
+}
+
 // FindRequest returns the GlacierRestoreRequest for the specified
 // GenericFile identifier. If it returns nil, we have not yet submitted
 // a retrieval request to Glacier for that file. Be sure to check the
@@ -248,4 +290,62 @@ type GlacierRestoreRequest struct {
 	// LastChecked is the date/time we last checked to see whether
 	// this file had been retrieved from Glacier in to S3.
 	LastChecked time.Time
+	// AttemptCount is the number of times we've checked on this
+	// file with an S3 HEAD request or asked Glacier to restore it,
+	// whether or not the attempt succeeded.
+	AttemptCount int
+	// ErrorMessage describes why the last attempt failed. It's
+	// empty if the last attempt succeeded, so the requests with
+	// an ErrorMessage are the ones that are stuck.
+	ErrorMessage string
+	// LastErrorAt is when the last failed attempt occurred. Unlike
+	// ErrorMessage, this is not cleared when an attempt succeeds.
+	LastErrorAt time.Time
+	// RestoreTier is the retrieval tier of our request, one of
+	// constants.RestoreTiers. It's empty if someone else requested
+	// the retrieval, since S3 doesn't tell us the tier.
+	RestoreTier string
+	// OngoingRequest is true if the x-amz-restore header from our
+	// last S3 HEAD request said Glacier is still copying the file
+	// into S3.
+	OngoingRequest bool
+	// RestoreExpiresAt is the expiry-date from the x-amz-restore
+	// header: when S3 will delete the restored copy. It's zero
+	// until the restoration completes.
+	RestoreExpiresAt time.Time
+}
+
+// RecordRestoreStatus records what the x-amz-restore header of an S3
+// HEAD response said about the file: whether a retrieval is ongoing,
+// and when the restored copy expires. Pass a zero expiresAt if the
+// header had no expiry-date, and false and a zero expiresAt if there
+// was no header. It also sets RequestAccepted, IsAvailableInS3 and
+// EstimatedDeletionFromS3 to match, for code that still uses them.
+func (request *GlacierRestoreRequest) RecordRestoreStatus(ongoing bool, expiresAt time.Time) {
+	request.OngoingRequest = ongoing
+	request.RestoreExpiresAt = expiresAt
+	if ongoing {
+		request.RequestAccepted = true
+	} else if !expiresAt.IsZero() {
+		request.RequestAccepted = true
+		request.IsAvailableInS3 = true
+		request.EstimatedDeletionFromS3 = expiresAt
+	}
+}
+
+// IsPending returns true if Glacier accepted the retrieval request,
+// but the file is not yet available in S3.
+func (request *GlacierRestoreRequest) IsPending() bool {
+	return request.RequestAccepted && !request.IsAvailableInS3
+}
+
+// RecordAttempt notes that we checked on this file or asked Glacier
+// to restore it at the specified time. Param errMessage describes why
+// the attempt failed. Pass an empty string if it succeeded.
+func (request *GlacierRestoreRequest) RecordAttempt(errMessage string, attemptedAt time.Time) {
+	request.AttemptCount += 1
+	request.ErrorMessage = errMessage
+	if errMessage != "" {
+		request.LastErrorAt = attemptedAt
+	}
 }
@@ -225,3 +225,66 @@ func TestGetStorageOption(t *testing.T) {
 	assert.Equal(t, constants.StorageGlacierDeepVA, option)
 
 }
+
+func TestGlacierRestoreStateDeferred(t *testing.T) {
+	state := getGlacierRestoreState()
+	require.NotNil(t, state.Deferred)
+	assert.False(t, state.HasDeferredRequests())
+
+	state.DeferRequest("test.edu/bag/file1.txt")
+	state.DeferRequest("test.edu/bag/file2.txt")
+	state.DeferRequest("test.edu/bag/file1.txt")
+	assert.True(t, state.HasDeferredRequests())
+	assert.Equal(t, 2, len(state.Deferred))
+
+	state.ClearDeferred()
+	assert.False(t, state.HasDeferredRequests())
+}
+
+func TestGlacierRestoreStateInFlightCount(t *testing.T) {
+	state := getGlacierRestoreState()
+	assert.Equal(t, 0, state.InFlightCount())
+	state.Requests = append(state.Requests, getGlacierRestoreRequest("", true))
+	state.Requests = append(state.Requests, getGlacierRestoreRequest("", true))
+	state.Requests = append(state.Requests, getGlacierRestoreRequest("", false))
+	assert.Equal(t, 2, state.InFlightCount())
+	state.Requests[0].IsAvailableInS3 = true
+	assert.Equal(t, 1, state.InFlightCount())
+}
+
+func TestGlacierRestoreRequestRecordAttempt(t *testing.T) {
+	request := &models.GlacierRestoreRequest{}
+	failedAt := time.Now().UTC().Add(-1 * time.Hour)
+	request.RecordAttempt("S3 returned 503", failedAt)
+	assert.Equal(t, 1, request.AttemptCount)
+	assert.Equal(t, "S3 returned 503", request.ErrorMessage)
+	assert.Equal(t, failedAt, request.LastErrorAt)
+
+	request.RecordAttempt("", time.Now().UTC())
+	assert.Equal(t, 2, request.AttemptCount)
+	assert.Empty(t, request.ErrorMessage)
+	assert.Equal(t, failedAt, request.LastErrorAt)
+}
+
+func TestGlacierRestoreRequestRecordRestoreStatus(t *testing.T) {
+	req := getGlacierRestoreRequest("", false)
+	req.RecordRestoreStatus(false, time.Time{})
+	assert.False(t, req.RequestAccepted)
+	assert.False(t, req.OngoingRequest)
+	assert.False(t, req.IsPending())
+
+	req.RecordRestoreStatus(true, time.Time{})
+	assert.True(t, req.RequestAccepted)
+	assert.True(t, req.OngoingRequest)
+	assert.False(t, req.IsAvailableInS3)
+	assert.True(t, req.IsPending())
+
+	expiresAt := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+	req.RecordRestoreStatus(false, expiresAt)
+	assert.True(t, req.RequestAccepted)
+	assert.False(t, req.OngoingRequest)
+	assert.True(t, req.IsAvailableInS3)
+	assert.False(t, req.IsPending())
+	assert.Equal(t, expiresAt, req.RestoreExpiresAt)
+	assert.Equal(t, expiresAt, req.EstimatedDeletionFromS3)
+}
@@ -22,6 +22,9 @@ type IngestManifest struct {
 	RecordResult   *WorkSummary
 	CleanupResult  *WorkSummary
 	Object         *IntellectualObject
+	// Receipt is the ingest receipt for the depositor, or nil if the
+	// depositor's institution doesn't get receipts.
+	Receipt *IngestReceipt `json:",omitempty"`
 }
 
 func NewIngestManifest() *IngestManifest {
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/APTrust/exchange/constants"
+	"time"
+)
+
+// IngestReceipt summarizes a successful ingest for the depositor who
+// uploaded the bag. apt_record builds the receipt once the object and
+// its files are recorded, and Pharos emails it to Recipient, for
+// institutions listed in Config.IngestReceiptInstitutions.
+type IngestReceipt struct {
+	WorkItemId       int    `json:"work_item_id"`
+	ObjectIdentifier string `json:"object_identifier"`
+	Institution      string `json:"institution"`
+	// Recipient is the email address of the depositor.
+	Recipient string `json:"recipient"`
+	// FileCount and TotalBytes describe the payload files, which
+	// are the files in the bag's data directory.
+	FileCount     int    `json:"file_count"`
+	TotalBytes    int64  `json:"total_bytes"`
+	StorageOption string `json:"storage_option"`
+	// ManifestName is the name of the bag's payload manifest, and
+	// ManifestSha256 is the sha256 digest of that manifest file.
+	// We use the sha256 manifest if the bag has one, since it
+	// covers the same files with a stronger algorithm.
+	ManifestName   string    `json:"manifest_name"`
+	ManifestSha256 string    `json:"manifest_sha256"`
+	IngestedAt     time.Time `json:"ingested_at"`
+	// SentAt is when Pharos accepted the receipt for delivery. It's
+	// empty until then, so we don't send a receipt twice.
+	SentAt time.Time `json:"sent_at,omitempty"`
+}
+
+// NewIngestReceipt returns a new IngestReceipt for obj, which was
+// ingested by the WorkItem with the specified id at the request of
+// recipient. Call AddFile for each of the object's files to fill in
+// the file count, size and manifest digest.
+func NewIngestReceipt(obj *IntellectualObject, workItemId int, recipient string) *IngestReceipt {
+	storageOption := obj.StorageOption
+	if storageOption == "" {
+		storageOption = constants.StorageStandard
+	}
+	return &IngestReceipt{
+		WorkItemId:       workItemId,
+		ObjectIdentifier: obj.Identifier,
+		Institution:      obj.Institution,
+		Recipient:        recipient,
+		StorageOption:    storageOption,
+		IngestedAt:       time.Now().UTC(),
+	}
+}
+
+// AddFile adds gf to the receipt's payload totals if it's a payload
+// file, or records its digest if it's the bag's payload manifest.
+func (receipt *IngestReceipt) AddFile(gf *GenericFile) {
+	if gf.IngestFileType == constants.PAYLOAD_FILE {
+		receipt.FileCount++
+		receipt.TotalBytes += gf.Size
+		return
+	}
+	path := gf.OriginalPath()
+	sha256Manifest := "manifest-" + constants.AlgSha256 + ".txt"
+	md5Manifest := "manifest-" + constants.AlgMd5 + ".txt"
+	if path == sha256Manifest || (path == md5Manifest && receipt.ManifestName != sha256Manifest) {
+		receipt.ManifestName = path
+		receipt.ManifestSha256 = gf.IngestSha256
+	}
+}
+
+// WasSent returns true if Pharos has accepted this receipt.
+func (receipt *IngestReceipt) WasSent() bool {
+	return !receipt.SentAt.IsZero()
+}
+
+// ToJson converts this object to JSON.
+func (receipt *IngestReceipt) ToJson() (string, error) {
+	jsonString, err := json.Marshal(receipt)
+	return string(jsonString), err
+}
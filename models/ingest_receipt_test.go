@@ -0,0 +1,68 @@
+package models_test
+
+import (
+	"encoding/json"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func receiptFile(path, fileType string, size int64, sha256 string) *models.GenericFile {
+	return &models.GenericFile{
+		Identifier:                   "test.edu/bag/" + path,
+		IntellectualObjectIdentifier: "test.edu/bag",
+		IngestFileType:               fileType,
+		Size:                         size,
+		IngestSha256:                 sha256,
+	}
+}
+
+func TestNewIngestReceipt(t *testing.T) {
+	obj := models.NewIntellectualObject()
+	obj.Identifier = "test.edu/bag"
+	obj.Institution = "test.edu"
+	receipt := models.NewIngestReceipt(obj, 42, "user@test.edu")
+	assert.Equal(t, 42, receipt.WorkItemId)
+	assert.Equal(t, "test.edu/bag", receipt.ObjectIdentifier)
+	assert.Equal(t, "test.edu", receipt.Institution)
+	assert.Equal(t, "user@test.edu", receipt.Recipient)
+	assert.Equal(t, constants.StorageStandard, receipt.StorageOption)
+	assert.WithinDuration(t, time.Now(), receipt.IngestedAt, time.Minute)
+	assert.False(t, receipt.WasSent())
+
+	obj.StorageOption = constants.StorageGlacierOH
+	receipt = models.NewIngestReceipt(obj, 42, "user@test.edu")
+	assert.Equal(t, constants.StorageGlacierOH, receipt.StorageOption)
+}
+
+func TestIngestReceiptAddFile(t *testing.T) {
+	obj := models.NewIntellectualObject()
+	obj.Identifier = "test.edu/bag"
+	receipt := models.NewIngestReceipt(obj, 42, "user@test.edu")
+
+	receipt.AddFile(receiptFile("data/one.txt", constants.PAYLOAD_FILE, 100, "1111"))
+	receipt.AddFile(receiptFile("manifest-md5.txt", constants.PAYLOAD_MANIFEST, 60, "md5manifest"))
+	receipt.AddFile(receiptFile("data/two.txt", constants.PAYLOAD_FILE, 250, "2222"))
+	receipt.AddFile(receiptFile("bag-info.txt", constants.TAG_FILE, 80, "3333"))
+	assert.Equal(t, 2, receipt.FileCount)
+	assert.EqualValues(t, 350, receipt.TotalBytes)
+	assert.Equal(t, "manifest-md5.txt", receipt.ManifestName)
+	assert.Equal(t, "md5manifest", receipt.ManifestSha256)
+
+	// The sha256 manifest wins, whichever order we see them in.
+	receipt.AddFile(receiptFile("manifest-sha256.txt", constants.PAYLOAD_MANIFEST, 90, "sha256manifest"))
+	receipt.AddFile(receiptFile("manifest-md5.txt", constants.PAYLOAD_MANIFEST, 60, "md5manifest"))
+	assert.Equal(t, "manifest-sha256.txt", receipt.ManifestName)
+	assert.Equal(t, "sha256manifest", receipt.ManifestSha256)
+
+	jsonString, err := receipt.ToJson()
+	require.Nil(t, err)
+	data := make(map[string]interface{})
+	require.Nil(t, json.Unmarshal([]byte(jsonString), &data))
+	assert.EqualValues(t, 2, data["file_count"])
+	assert.EqualValues(t, 350, data["total_bytes"])
+	assert.Equal(t, "sha256manifest", data["manifest_sha256"])
+}
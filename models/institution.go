@@ -1,5 +1,10 @@
 package models
 
+import (
+	"fmt"
+	"time"
+)
+
 // Institution represents an institution in Fuctus.
 
 type Institution struct {
@@ -26,4 +31,39 @@ type Institution struct {
 
 	// The name of the institution's restore bucket.
 	RestoreBucket string `json:"restore_bucket"`
+
+	// QuotaBytes is the number of bytes the institution may have
+	// in preservation storage. Zero means the institution has no
+	// quota.
+	QuotaBytes int64 `json:"quota_bytes,omitempty"`
+
+	// BytesUsed is the number of bytes the institution had in
+	// preservation storage when Pharos last added it up, at
+	// BytesUsedAsOf.
+	BytesUsed     int64     `json:"bytes_used,omitempty"`
+	BytesUsedAsOf time.Time `json:"bytes_used_as_of,omitempty"`
+}
+
+// HasQuota returns true if the institution has a storage quota.
+func (inst *Institution) HasQuota() bool {
+	return inst.QuotaBytes > 0
+}
+
+// CheckQuota returns an error if adding size bytes to the institution's
+// storage would put it over its quota. Param pending is the number of
+// bytes already on their way into storage that BytesUsed doesn't yet
+// include, such as other bags queued for ingest. The error message is
+// suitable for a WorkItem Note.
+func (inst *Institution) CheckQuota(size, pending int64) error {
+	if !inst.HasQuota() || inst.BytesUsed+pending+size <= inst.QuotaBytes {
+		return nil
+	}
+	asOf := "an unknown date"
+	if !inst.BytesUsedAsOf.IsZero() {
+		asOf = inst.BytesUsedAsOf.Format(time.RFC3339)
+	}
+	return fmt.Errorf("Ingesting this bag (%d bytes) would put %s over its storage "+
+		"quota of %d bytes. Usage was %d bytes as of %s, and %d more bytes are "+
+		"awaiting ingest.", size, inst.Identifier, inst.QuotaBytes, inst.BytesUsed,
+		asOf, pending)
 }
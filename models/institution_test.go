@@ -0,0 +1,43 @@
+package models_test
+
+import (
+	"encoding/json"
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestInstitutionCheckQuota(t *testing.T) {
+	inst := &models.Institution{Identifier: "example.edu"}
+	assert.False(t, inst.HasQuota())
+	assert.Nil(t, inst.CheckQuota(1000000, 0))
+
+	inst.QuotaBytes = 10000
+	inst.BytesUsed = 6000
+	assert.True(t, inst.HasQuota())
+	assert.Nil(t, inst.CheckQuota(4000, 0))
+	assert.Nil(t, inst.CheckQuota(3000, 1000))
+
+	err := inst.CheckQuota(3000, 1500)
+	require.NotNil(t, err)
+	assert.Equal(t, "Ingesting this bag (3000 bytes) would put example.edu over its storage "+
+		"quota of 10000 bytes. Usage was 6000 bytes as of an unknown date, and 1500 more "+
+		"bytes are awaiting ingest.", err.Error())
+
+	inst.BytesUsedAsOf = time.Date(2030, 3, 1, 0, 0, 0, 0, time.UTC)
+	err = inst.CheckQuota(5000, 0)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "as of 2030-03-01T00:00:00Z")
+}
+
+func TestInstitutionQuotaJson(t *testing.T) {
+	data := `{"id": 5, "identifier": "example.edu", "quota_bytes": 10000,
+		"bytes_used": 6000, "bytes_used_as_of": "2030-03-01T00:00:00Z"}`
+	inst := &models.Institution{}
+	require.Nil(t, json.Unmarshal([]byte(data), inst))
+	assert.EqualValues(t, 10000, inst.QuotaBytes)
+	assert.EqualValues(t, 6000, inst.BytesUsed)
+	assert.Equal(t, time.Date(2030, 3, 1, 0, 0, 0, 0, time.UTC), inst.BytesUsedAsOf)
+}
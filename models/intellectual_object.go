@@ -128,6 +128,19 @@ type IntellectualObject struct {
 	// if it differs from this field.
 	SourceOrganization string `json:"source_organization,omitempty"`
 
+	// EmbargoUntil comes from the Embargo-Until tag of the
+	// aptrust-info.txt file. Until this date, we will not restore
+	// the object or any of its files unless an APTrust admin approves
+	// the restoration. A zero value means the object is not under
+	// embargo.
+	EmbargoUntil time.Time `json:"embargo_until,omitempty"`
+
+	// RightsStatement is the URI of a rights statement describing
+	// how the object may be used, such as one from rightsstatements.org
+	// or creativecommons.org. It comes from the Rights-Statement tag of
+	// the aptrust-info.txt file.
+	RightsStatement string `json:"rights_statement,omitempty"`
+
 	// IngestS3Bucket is the bucket to which the depositor uploaded
 	// this bag. We fetch it from there to a local staging area for
 	// processing.
@@ -270,10 +283,16 @@ func NewMissingFile(manifest string, lineNumber int, filePath, digest string) *M
 // This Tag struct is essentially the same as the bagins
 // TagField struct, but its properties are public and can
 // be easily serialized to / deserialized from JSON.
+//
+// Raw is the tag's original text, as parsed by ParseTagList,
+// including continuation lines, line endings, and any blank
+// lines that followed it. TagList uses it to regenerate tag
+// files byte for byte. It's empty for tags created in code.
 type Tag struct {
 	SourceFile string
 	Label      string
 	Value      string
+	Raw        string `json:",omitempty"`
 }
 
 func NewTag(sourceFile, label, value string) *Tag {
@@ -329,6 +348,12 @@ func (obj *IntellectualObject) AccessValid() bool {
 	return false
 }
 
+// IsEmbargoed returns true if the object is under embargo at time now.
+// An embargo lasts until the start of the EmbargoUntil date, in UTC.
+func (obj *IntellectualObject) IsEmbargoed(now time.Time) bool {
+	return !obj.EmbargoUntil.IsZero() && now.Before(obj.EmbargoUntil)
+}
+
 // Returns the GenericFile record for the specified path, or nil.
 // Param filePath should be the relative path of the file within
 // the bag. E.g. "data/images/myphoto.jpg"
@@ -561,3 +586,23 @@ func (obj *IntellectualObject) PropagateIdsToChildren() {
 		gf.PropagateIdsToChildren()
 	}
 }
+
+// Validate returns a ModelError if the object is missing a field Pharos
+// requires, has an identifier that doesn't belong to its institution,
+// or has an unknown access right, storage option or state. It does not
+// check the object's files, which we save separately.
+func (obj *IntellectualObject) Validate() error {
+	checker := newModelChecker("IntellectualObject", obj.Identifier)
+	checker.require("Identifier", obj.Identifier)
+	checker.require("Institution", obj.Institution)
+	checker.requireSlashes("Identifier", obj.Identifier, 1, "institution/bag")
+	checker.requirePrefix("Identifier", obj.Identifier, "Institution", obj.Institution)
+	checker.require("Title", obj.Title)
+	if obj.Access != "" && !obj.AccessValid() {
+		checker.addProblem("Access '%s' is not one of: %s", obj.Access,
+			strings.Join(constants.AccessRights, ", "))
+	}
+	checker.requireOneOf("StorageOption", obj.StorageOption, constants.StorageOptions, false)
+	checker.requireOneOf("State", obj.State, validStates, false)
+	return checker.result()
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"fmt"
+)
+
+// ObjectMergeReport describes what IntellectualObject.Merge could not
+// merge. Merge keeps the local value of every conflicting field, so
+// the report is the only record of what Pharos had.
+type ObjectMergeReport struct {
+	// Conflicts lists the object's own fields that have different
+	// values locally and in Pharos. Value is the local value and
+	// OtherValue is the Pharos value.
+	Conflicts []*FieldDiff `json:"conflicts"`
+	// FileConflicts maps the identifier of each GenericFile whose
+	// local and Pharos versions differ to the fields that differ.
+	// See GenericFile.Diff.
+	FileConflicts map[string][]*FieldDiff `json:"file_conflicts"`
+	// Errors lists problems merging the object's events and files,
+	// such as events Pharos returned that we don't have locally.
+	Errors []error `json:"-"`
+}
+
+// HasConflicts returns true if any field of the object or its files
+// differs between the local and Pharos versions.
+func (report *ObjectMergeReport) HasConflicts() bool {
+	return len(report.Conflicts) > 0 || len(report.FileConflicts) > 0
+}
+
+// Merge copies the attributes Pharos assigns (Id, CreatedAt and
+// UpdatedAt) from remote into this object, and into this object's
+// PremisEvents and GenericFiles, matching them by identifier. It's
+// the object-wide version of PremisEvent.MergeAttributes, for use
+// after saving an object to Pharos. Merge also fills in InstitutionId
+// if it's missing here.
+//
+// Merge does not overwrite any other field. If remote has a different,
+// non-empty value for one of the object's descriptive fields, or if
+// one of its files differs from ours, Merge keeps our value and lists
+// the difference in the report. Merge ignores files that exist only in
+// remote, since those may come from a prior ingest of the same bag. It
+// returns an error if remote is nil or describes a different object.
+func (obj *IntellectualObject) Merge(remote *IntellectualObject) (*ObjectMergeReport, error) {
+	if remote == nil {
+		return nil, fmt.Errorf("Param remote cannot be nil.")
+	}
+	if remote.Identifier != obj.Identifier {
+		return nil, fmt.Errorf("Cannot merge %s into %s, because they are different objects.",
+			remote.Identifier, obj.Identifier)
+	}
+	report := &ObjectMergeReport{
+		Conflicts:     make([]*FieldDiff, 0),
+		FileConflicts: make(map[string][]*FieldDiff),
+		Errors:        make([]error, 0),
+	}
+	compare := func(field, value, remoteValue string) {
+		if remoteValue != "" && value != remoteValue {
+			report.Conflicts = append(report.Conflicts, &FieldDiff{
+				Field:      field,
+				Value:      value,
+				OtherValue: remoteValue,
+			})
+		}
+	}
+	compare("bag_name", obj.BagName, remote.BagName)
+	compare("institution", obj.Institution, remote.Institution)
+	compare("title", obj.Title, remote.Title)
+	compare("description", obj.Description, remote.Description)
+	compare("access", obj.Access, remote.Access)
+	compare("alt_identifier", obj.AltIdentifier, remote.AltIdentifier)
+	compare("bag_group_identifier", obj.BagGroupIdentifier, remote.BagGroupIdentifier)
+	compare("bagit_profile_identifier", obj.BagItProfileIdentifier, remote.BagItProfileIdentifier)
+	compare("etag", obj.ETag, remote.ETag)
+	compare("storage_option", obj.StorageOption, remote.StorageOption)
+	if obj.InstitutionId == 0 {
+		obj.InstitutionId = remote.InstitutionId
+	} else if remote.InstitutionId != 0 {
+		compare("institution_id", fmt.Sprintf("%d", obj.InstitutionId),
+			fmt.Sprintf("%d", remote.InstitutionId))
+	}
+
+	obj.Id = remote.Id
+	obj.CreatedAt = remote.CreatedAt
+	obj.UpdatedAt = remote.UpdatedAt
+
+	for _, remoteEvent := range remote.PremisEvents {
+		event := obj.findEventByIdentifier(remoteEvent.Identifier)
+		if event == nil {
+			report.Errors = append(report.Errors, fmt.Errorf("Pharos has event '%s' "+
+				"for %s, but we don't.", remoteEvent.Identifier, obj.Identifier))
+			continue
+		}
+		if err := event.MergeAttributes(remoteEvent); err != nil {
+			report.Errors = append(report.Errors, err)
+		}
+	}
+
+	files := make(map[string]*GenericFile, len(obj.GenericFiles))
+	for _, gf := range obj.GenericFiles {
+		files[gf.Identifier] = gf
+	}
+	for _, remoteFile := range remote.GenericFiles {
+		gf := files[remoteFile.Identifier]
+		if gf == nil {
+			continue
+		}
+		if diffs := gf.Diff(remoteFile); len(diffs) > 0 {
+			report.FileConflicts[gf.Identifier] = diffs
+		}
+		report.Errors = append(report.Errors, gf.MergeAttributes(remoteFile)...)
+	}
+
+	obj.PropagateIdsToChildren()
+	return report, nil
+}
+
+// findEventByIdentifier returns the object-level event with the
+// specified identifier, or nil.
+func (obj *IntellectualObject) findEventByIdentifier(identifier string) *PremisEvent {
+	for _, event := range obj.PremisEvents {
+		if event != nil && event.Identifier == identifier {
+			return event
+		}
+	}
+	return nil
+}
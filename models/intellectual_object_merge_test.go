@@ -0,0 +1,122 @@
+package models_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mergeTestObjects returns a local object and a copy of it that looks
+// like what Pharos returns after a save.
+func mergeTestObjects(t *testing.T) (*models.IntellectualObject, *models.IntellectualObject) {
+	local := testutil.MakeIntellectualObject(2, 2, 2, 0)
+	local.Id = 0
+	data, err := json.Marshal(local)
+	require.Nil(t, err)
+	remote := &models.IntellectualObject{}
+	require.Nil(t, json.Unmarshal(data, remote))
+	remote.Id = 1234
+	remote.CreatedAt = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	remote.UpdatedAt = time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i, event := range remote.PremisEvents {
+		event.Id = 5000 + i
+	}
+	for i, gf := range remote.GenericFiles {
+		gf.Id = 6000 + i
+	}
+	return local, remote
+}
+
+func TestIntellectualObjectMerge(t *testing.T) {
+	local, remote := mergeTestObjects(t)
+	report, err := local.Merge(remote)
+	require.Nil(t, err)
+	assert.False(t, report.HasConflicts())
+	assert.Empty(t, report.Errors)
+
+	assert.Equal(t, 1234, local.Id)
+	assert.Equal(t, remote.CreatedAt, local.CreatedAt)
+	assert.Equal(t, remote.UpdatedAt, local.UpdatedAt)
+	for i, event := range local.PremisEvents {
+		assert.Equal(t, 5000+i, event.Id)
+		assert.Equal(t, 1234, event.IntellectualObjectId)
+	}
+	for i, gf := range local.GenericFiles {
+		assert.Equal(t, 6000+i, gf.Id)
+		assert.Equal(t, 1234, gf.IntellectualObjectId)
+	}
+}
+
+func TestIntellectualObjectMerge_BagItProfileIdentifier(t *testing.T) {
+	local, remote := mergeTestObjects(t)
+	local.BagItProfileIdentifier = "https://example.com/aptrust-v2.2.json"
+
+	// Pharos may not have recorded the profile of an earlier ingest.
+	remote.BagItProfileIdentifier = ""
+	report, err := local.Merge(remote)
+	require.Nil(t, err)
+	assert.False(t, report.HasConflicts())
+
+	local, remote = mergeTestObjects(t)
+	local.BagItProfileIdentifier = "https://example.com/aptrust-v2.2.json"
+	remote.BagItProfileIdentifier = "https://example.com/btr-v1.0.json"
+	report, err = local.Merge(remote)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(report.Conflicts))
+	assert.Equal(t, "bagit_profile_identifier", report.Conflicts[0].Field)
+	assert.Equal(t, "https://example.com/aptrust-v2.2.json", local.BagItProfileIdentifier)
+}
+
+func TestIntellectualObjectMerge_Conflicts(t *testing.T) {
+	local, remote := mergeTestObjects(t)
+	localTitle := local.Title
+	remote.Title = "A different title"
+	remote.Description = ""
+	remote.GenericFiles[0].Size = local.GenericFiles[0].Size + 1
+	remoteOnly := testutil.MakeGenericFile(0, 0, local.Identifier+"/remote_only.txt")
+	remote.GenericFiles = append(remote.GenericFiles, remoteOnly)
+
+	report, err := local.Merge(remote)
+	require.Nil(t, err)
+	assert.True(t, report.HasConflicts())
+
+	// Local values win, and the report says what Pharos had.
+	assert.Equal(t, localTitle, local.Title)
+	require.Equal(t, 1, len(report.Conflicts))
+	assert.Equal(t, "title", report.Conflicts[0].Field)
+	assert.Equal(t, localTitle, report.Conflicts[0].Value)
+	assert.Equal(t, "A different title", report.Conflicts[0].OtherValue)
+
+	gfIdentifier := local.GenericFiles[0].Identifier
+	require.Equal(t, 1, len(report.FileConflicts))
+	require.Equal(t, 1, len(report.FileConflicts[gfIdentifier]))
+	assert.Equal(t, "size", report.FileConflicts[gfIdentifier][0].Field)
+
+	// Ids still merge, and remote-only files are ignored.
+	assert.Equal(t, 1234, local.Id)
+	assert.Equal(t, 2, len(local.GenericFiles))
+	assert.Empty(t, report.Errors)
+}
+
+func TestIntellectualObjectMerge_Errors(t *testing.T) {
+	local, remote := mergeTestObjects(t)
+	_, err := local.Merge(nil)
+	assert.NotNil(t, err)
+
+	remote.Identifier = "test.edu/some_other_bag"
+	_, err = local.Merge(remote)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, local.Id)
+
+	local, remote = mergeTestObjects(t)
+	remote.PremisEvents[0].Identifier = "no-such-event"
+	report, err := local.Merge(remote)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(report.Errors))
+	assert.Contains(t, report.Errors[0].Error(), "no-such-event")
+}
@@ -85,6 +85,29 @@ func TestSerializeObjectForPharos(t *testing.T) {
 	assert.Equal(t, "institution", pharosObj["access"])
 	assert.Equal(t, "12345678", pharosObj["etag"])
 	assert.Equal(t, "US Photos, 1940-1945", pharosObj["bag_group_identifier"])
+	assert.Nil(t, pharosObj["embargo_until"])
+	assert.Equal(t, "", pharosObj["rights_statement"])
+
+	intelObj.EmbargoUntil = time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+	intelObj.RightsStatement = "http://rightsstatements.org/vocab/InC/1.0/"
+	data, err = intelObj.SerializeForPharos()
+	require.Nil(t, err)
+	hash = make(map[string]interface{})
+	require.Nil(t, json.Unmarshal(data, &hash))
+	pharosObj = hash["intellectual_object"].(map[string]interface{})
+	assert.Equal(t, "2030-01-31T00:00:00Z", pharosObj["embargo_until"])
+	assert.Equal(t, intelObj.RightsStatement, pharosObj["rights_statement"])
+}
+
+func TestObjIsEmbargoed(t *testing.T) {
+	obj := models.NewIntellectualObject()
+	now := time.Date(2030, 1, 30, 23, 59, 59, 0, time.UTC)
+	assert.False(t, obj.IsEmbargoed(now))
+
+	obj.EmbargoUntil = time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+	assert.True(t, obj.IsEmbargoed(now))
+	assert.False(t, obj.IsEmbargoed(obj.EmbargoUntil))
+	assert.False(t, obj.IsEmbargoed(now.AddDate(0, 0, 1)))
 }
 
 func TestFindGenericFile(t *testing.T) {
@@ -0,0 +1,228 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// JSON types for SchemaField.Type.
+const (
+	JsonString    = "string"
+	JsonNumber    = "number"
+	JsonBool      = "boolean"
+	JsonObject    = "object"
+	JsonArray     = "array"
+	JsonTimestamp = "timestamp"
+)
+
+/*
+SchemaField describes one field of a JSON record that we expect
+from Pharos.
+
+Name is the JSON field name. Type is one of the Json type constants
+above. JsonTimestamp is a string that must parse as RFC3339, since
+that's what encoding/json expects for time.Time.
+
+If Required is true, the field must be present and not null.
+Otherwise, null and missing values are fine, but other values must
+match Type.
+
+Items, if set, describes the records in a JsonArray field.
+*/
+type SchemaField struct {
+	Name     string
+	Type     string
+	Required bool
+	Items    []SchemaField
+}
+
+// SchemaError describes a JSON field that doesn't match its
+// SchemaField. Actual is the JSON type we found, or "missing"
+// or "null" for a required field that has no value.
+type SchemaError struct {
+	Model    string
+	Field    string
+	Expected string
+	Actual   string
+}
+
+// Error returns a message describing the bad field.
+func (schemaError *SchemaError) Error() string {
+	if schemaError.Actual == "missing" || schemaError.Actual == "null" {
+		return fmt.Sprintf("%s: required field '%s' (%s) is %s",
+			schemaError.Model, schemaError.Field, schemaError.Expected,
+			schemaError.Actual)
+	}
+	return fmt.Sprintf("%s: field '%s' should be %s, but is %s",
+		schemaError.Model, schemaError.Field, schemaError.Expected,
+		schemaError.Actual)
+}
+
+// WorkItemSchema describes the WorkItem JSON that Pharos returns.
+var WorkItemSchema = []SchemaField{
+	{Name: "id", Type: JsonNumber},
+	{Name: "object_identifier", Type: JsonString},
+	{Name: "generic_file_identifier", Type: JsonString},
+	{Name: "name", Type: JsonString, Required: true},
+	{Name: "bucket", Type: JsonString, Required: true},
+	{Name: "etag", Type: JsonString},
+	{Name: "size", Type: JsonNumber},
+	{Name: "bag_date", Type: JsonTimestamp},
+	{Name: "institution_id", Type: JsonNumber},
+	{Name: "work_item_state_id", Type: JsonNumber},
+	{Name: "user", Type: JsonString},
+	{Name: "date", Type: JsonTimestamp},
+	{Name: "note", Type: JsonString},
+	{Name: "action", Type: JsonString, Required: true},
+	{Name: "stage", Type: JsonString},
+	{Name: "stage_started_at", Type: JsonTimestamp},
+	{Name: "status", Type: JsonString},
+	{Name: "priority", Type: JsonString},
+	{Name: "outcome", Type: JsonString},
+	{Name: "retry", Type: JsonBool},
+	{Name: "node", Type: JsonString},
+	{Name: "pid", Type: JsonNumber},
+	{Name: "needs_admin_review", Type: JsonBool},
+	{Name: "queued_at", Type: JsonTimestamp},
+}
+
+// ChecksumSchema describes the Checksum JSON that Pharos returns
+// as part of a GenericFile.
+var ChecksumSchema = []SchemaField{
+	{Name: "id", Type: JsonNumber},
+	{Name: "generic_file_id", Type: JsonNumber},
+	{Name: "algorithm", Type: JsonString, Required: true},
+	{Name: "datetime", Type: JsonTimestamp},
+	{Name: "digest", Type: JsonString, Required: true},
+}
+
+// GenericFileSchema describes the GenericFile JSON that Pharos returns.
+var GenericFileSchema = []SchemaField{
+	{Name: "id", Type: JsonNumber},
+	{Name: "identifier", Type: JsonString, Required: true},
+	{Name: "intellectual_object_id", Type: JsonNumber},
+	{Name: "intellectual_object_identifier", Type: JsonString},
+	{Name: "file_format", Type: JsonString},
+	{Name: "pronom_puid", Type: JsonString},
+	{Name: "format_confidence", Type: JsonString},
+	{Name: "uri", Type: JsonString},
+	{Name: "size", Type: JsonNumber},
+	{Name: "file_created", Type: JsonTimestamp},
+	{Name: "file_modified", Type: JsonTimestamp},
+	{Name: "last_fixity_check", Type: JsonTimestamp},
+	{Name: "state", Type: JsonString},
+	{Name: "storage_option", Type: JsonString},
+	{Name: "checksums", Type: JsonArray, Items: ChecksumSchema},
+	{Name: "premis_events", Type: JsonArray},
+}
+
+// IntellectualObjectSchema describes the IntellectualObject JSON that
+// Pharos returns.
+var IntellectualObjectSchema = []SchemaField{
+	{Name: "id", Type: JsonNumber},
+	{Name: "identifier", Type: JsonString, Required: true},
+	{Name: "bag_name", Type: JsonString},
+	{Name: "bagit_profile_identifier", Type: JsonString},
+	{Name: "institution", Type: JsonString},
+	{Name: "institution_id", Type: JsonNumber},
+	{Name: "title", Type: JsonString},
+	{Name: "access", Type: JsonString},
+	{Name: "etag", Type: JsonString},
+	{Name: "state", Type: JsonString},
+	{Name: "storage_option", Type: JsonString},
+	{Name: "file_count", Type: JsonNumber},
+	{Name: "file_size", Type: JsonNumber},
+	{Name: "embargo_until", Type: JsonTimestamp},
+	{Name: "generic_files", Type: JsonArray, Items: GenericFileSchema},
+	{Name: "premis_events", Type: JsonArray},
+}
+
+// ValidateJson checks that data is a JSON object that matches schema.
+// Model is the name of the record type, for error messages. Returns
+// a SchemaError describing the first field that doesn't match, or
+// an error if data isn't a JSON object.
+func ValidateJson(model string, data []byte, schema []SchemaField) error {
+	record := make(map[string]interface{})
+	if err := json.Unmarshal(data, &record); err != nil {
+		return fmt.Errorf("%s: expected a JSON object: %v", model, err)
+	}
+	return ValidateJsonRecord(model, record, schema)
+}
+
+// ValidateJsonRecord checks that record, which came from decoding a
+// JSON object, matches schema. See ValidateJson.
+func ValidateJsonRecord(model string, record map[string]interface{}, schema []SchemaField) error {
+	for _, field := range schema {
+		value, present := record[field.Name]
+		if value == nil {
+			if field.Required {
+				actual := "null"
+				if !present {
+					actual = "missing"
+				}
+				return &SchemaError{Model: model, Field: field.Name,
+					Expected: field.Type, Actual: actual}
+			}
+			continue
+		}
+		actual := jsonTypeOf(value)
+		if field.Type == JsonTimestamp && actual == JsonString {
+			if _, err := time.Parse(time.RFC3339, value.(string)); err == nil {
+				continue
+			}
+			actual = fmt.Sprintf("string '%s'", value)
+		} else if actual == field.Type {
+			if field.Type == JsonArray && field.Items != nil {
+				if err := validateJsonArray(model, field, value.([]interface{})); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		return &SchemaError{Model: model, Field: field.Name,
+			Expected: field.Type, Actual: actual}
+	}
+	return nil
+}
+
+// validateJsonArray checks each record in a JsonArray field against
+// field.Items. Errors name the field as "field[index].subfield".
+func validateJsonArray(model string, field SchemaField, items []interface{}) error {
+	for i, item := range items {
+		itemName := fmt.Sprintf("%s[%d]", field.Name, i)
+		record, isObject := item.(map[string]interface{})
+		if !isObject {
+			return &SchemaError{Model: model, Field: itemName,
+				Expected: JsonObject, Actual: jsonTypeOf(item)}
+		}
+		err := ValidateJsonRecord(model, record, field.Items)
+		if schemaError, ok := err.(*SchemaError); ok {
+			schemaError.Field = itemName + "." + schemaError.Field
+			return schemaError
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonTypeOf returns the JSON type of a value decoded by encoding/json.
+func jsonTypeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return JsonString
+	case float64:
+		return JsonNumber
+	case bool:
+		return JsonBool
+	case map[string]interface{}:
+		return JsonObject
+	case []interface{}:
+		return JsonArray
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,65 @@
+package models_test
+
+import (
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestValidateJson(t *testing.T) {
+	data := []byte(`{"id": 8, "identifier": "test.edu/bag/data/file.txt", "size": 400,
+		"file_created": "2017-03-13T14:00:00Z", "last_fixity_check": null,
+		"checksums": [{"algorithm": "md5", "digest": "1234"}]}`)
+	assert.Nil(t, models.ValidateJson("GenericFile", data, models.GenericFileSchema))
+
+	// Wrong type
+	data = []byte(`{"identifier": "test.edu/bag/data/file.txt", "size": "400"}`)
+	err := models.ValidateJson("GenericFile", data, models.GenericFileSchema)
+	require.NotNil(t, err)
+	schemaError, ok := err.(*models.SchemaError)
+	require.True(t, ok)
+	assert.Equal(t, "size", schemaError.Field)
+	assert.Equal(t, models.JsonNumber, schemaError.Expected)
+	assert.Equal(t, models.JsonString, schemaError.Actual)
+	assert.Equal(t, "GenericFile: field 'size' should be number, but is string", err.Error())
+
+	// Missing and null required fields
+	data = []byte(`{"id": 8, "size": 400}`)
+	err = models.ValidateJson("GenericFile", data, models.GenericFileSchema)
+	require.NotNil(t, err)
+	assert.Equal(t, "GenericFile: required field 'identifier' (string) is missing", err.Error())
+	data = []byte(`{"identifier": null}`)
+	err = models.ValidateJson("GenericFile", data, models.GenericFileSchema)
+	require.NotNil(t, err)
+	assert.Equal(t, "GenericFile: required field 'identifier' (string) is null", err.Error())
+
+	// Bad timestamp
+	data = []byte(`{"identifier": "test.edu/bag/data/file.txt", "file_created": "yesterday"}`)
+	err = models.ValidateJson("GenericFile", data, models.GenericFileSchema)
+	require.NotNil(t, err)
+	assert.Equal(t, "GenericFile: field 'file_created' should be timestamp, but is string 'yesterday'", err.Error())
+
+	// Nested records
+	data = []byte(`{"identifier": "test.edu/bag", "generic_files": [
+		{"identifier": "test.edu/bag/data/file.txt"},
+		{"identifier": "test.edu/bag/data/file2.txt", "checksums": [{"algorithm": "md5"}]}]}`)
+	err = models.ValidateJson("IntellectualObject", data, models.IntellectualObjectSchema)
+	require.NotNil(t, err)
+	assert.Equal(t, "IntellectualObject: required field 'generic_files[1].checksums[0].digest' (string) is missing", err.Error())
+	data = []byte(`{"identifier": "test.edu/bag", "generic_files": ["file.txt"]}`)
+	err = models.ValidateJson("IntellectualObject", data, models.IntellectualObjectSchema)
+	require.NotNil(t, err)
+	assert.Equal(t, "IntellectualObject: field 'generic_files[0]' should be object, but is string", err.Error())
+
+	// Not an object
+	err = models.ValidateJson("WorkItem", []byte(`[1, 2, 3]`), models.WorkItemSchema)
+	assert.NotNil(t, err)
+}
+
+func TestValidateJsonRecordsFromModels(t *testing.T) {
+	// The JSON we send to Pharos should pass our own schemas.
+	data, err := SampleWorkItem().SerializeForPharos()
+	require.Nil(t, err)
+	assert.Nil(t, models.ValidateJson("WorkItem", data, models.WorkItemSchema))
+}
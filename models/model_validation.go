@@ -0,0 +1,109 @@
+package models
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/util"
+	"strings"
+	"time"
+)
+
+// ModelError describes the problems Validate found with a record we
+// were about to send to Pharos. PharosClient checks records before it
+// saves them, so we get a clear message here instead of a 422 from
+// Pharos that doesn't say what was wrong.
+type ModelError struct {
+	Model      string
+	Identifier string
+	Problems   []string
+}
+
+// Error returns a message listing all of the problems.
+func (modelError *ModelError) Error() string {
+	identifier := modelError.Identifier
+	if identifier == "" {
+		identifier = "(no identifier)"
+	}
+	return fmt.Sprintf("%s %s is not valid: %s", modelError.Model,
+		identifier, strings.Join(modelError.Problems, "; "))
+}
+
+// modelChecker collects the problems with one record.
+type modelChecker struct {
+	err *ModelError
+}
+
+func newModelChecker(model, identifier string) *modelChecker {
+	return &modelChecker{
+		err: &ModelError{
+			Model:      model,
+			Identifier: identifier,
+			Problems:   make([]string, 0),
+		},
+	}
+}
+
+func (checker *modelChecker) addProblem(format string, a ...interface{}) {
+	checker.err.Problems = append(checker.err.Problems, fmt.Sprintf(format, a...))
+}
+
+// require records a problem if value is empty.
+func (checker *modelChecker) require(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		checker.addProblem("%s is required", field)
+	}
+}
+
+// requireTime records a problem if value is the zero time.
+func (checker *modelChecker) requireTime(field string, value time.Time) {
+	if value.IsZero() {
+		checker.addProblem("%s is required", field)
+	}
+}
+
+// requireOneOf records a problem if value is not in list. An empty
+// value is a problem only if required is true.
+func (checker *modelChecker) requireOneOf(field, value string, list []string, required bool) {
+	if value == "" {
+		if required {
+			checker.require(field, value)
+		}
+		return
+	}
+	if !util.StringListContains(list, value) {
+		checker.addProblem("%s '%s' is not one of: %s", field, value, strings.Join(list, ", "))
+	}
+}
+
+// requirePrefix records a problem if identifier doesn't begin with
+// the identifier of its parent, followed by a slash. For example,
+// file identifiers begin with their object's identifier.
+func (checker *modelChecker) requirePrefix(field, identifier, parentField, parentIdentifier string) {
+	if identifier == "" || parentIdentifier == "" {
+		return
+	}
+	if !strings.HasPrefix(identifier, parentIdentifier+"/") {
+		checker.addProblem("%s '%s' does not begin with %s '%s'",
+			field, identifier, parentField, parentIdentifier)
+	}
+}
+
+// requireSlashes records a problem if identifier has fewer than min
+// slashes. Object identifiers look like "institution/bag", and file
+// identifiers look like "institution/bag/path".
+func (checker *modelChecker) requireSlashes(field, identifier string, min int, example string) {
+	if identifier != "" && strings.Count(identifier, "/") < min {
+		checker.addProblem("%s '%s' should look like '%s'", field, identifier, example)
+	}
+}
+
+// result returns the ModelError, or nil if there were no problems.
+func (checker *modelChecker) result() error {
+	if len(checker.err.Problems) == 0 {
+		return nil
+	}
+	return checker.err
+}
+
+// validStates are the values of IntellectualObject.State and
+// GenericFile.State: active and deleted.
+var validStates = []string{"A", "D"}
@@ -0,0 +1,139 @@
+package models_test
+
+import (
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestChecksumValidate(t *testing.T) {
+	checksum := testutil.MakeChecksum()
+	assert.Nil(t, checksum.Validate())
+	checksum.Algorithm = constants.AlgBlake2b
+	assert.Nil(t, checksum.Validate())
+	checksum.Algorithm = "sha1"
+	checksum.Digest = ""
+	checksum.DateTime = time.Time{}
+	checksum.Source = "guesswork"
+	err := checksum.Validate()
+	require.NotNil(t, err)
+	modelError, ok := err.(*models.ModelError)
+	require.True(t, ok)
+	assert.Equal(t, "Checksum", modelError.Model)
+	assert.Equal(t, []string{
+		"Algorithm 'sha1' is not one of: md5, sha256, sha512, blake2b",
+		"Digest is required",
+		"DateTime is required",
+		"Source 'guesswork' is not one of: manifest, computed-at-ingest, computed-at-fixity",
+	}, modelError.Problems)
+}
+
+func TestPremisEventValidate(t *testing.T) {
+	event := testutil.MakePremisEvent()
+	assert.Nil(t, event.Validate())
+	event.EventType = "party"
+	event.Agent = ""
+	event.IntellectualObjectIdentifier = "test.edu/bag"
+	event.GenericFileIdentifier = "test.edu/other_bag/data/file.txt"
+	err := event.Validate()
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "PremisEvent "+event.Identifier+" is not valid: ")
+	assert.Contains(t, err.Error(), "EventType 'party' is not one of: ")
+	assert.Contains(t, err.Error(), "Agent is required")
+	assert.Contains(t, err.Error(), "GenericFileIdentifier 'test.edu/other_bag/data/file.txt' "+
+		"does not begin with IntellectualObjectIdentifier 'test.edu/bag'")
+}
+
+func TestPremisEventConstructorsAreValid(t *testing.T) {
+	now := time.Now().UTC()
+	events := []*models.PremisEvent{
+		models.NewEventObjectCreation(),
+		models.NewEventFileDeletion("1234", "user@example.com", "inst@example.com", "admin@aptrust.org", now),
+		models.NewEventObjectDeletion("test.edu/bag", "user@example.com", "inst@example.com", "admin@aptrust.org", now),
+	}
+	add := func(event *models.PremisEvent, err error) {
+		require.Nil(t, err)
+		events = append(events, event)
+	}
+	add(models.NewEventObjectIngest(3))
+	add(models.NewEventObjectIdentifierAssignment("test.edu/bag"))
+	add(models.NewEventObjectRights("institution"))
+	add(models.NewEventGenericFileIngest(now, "c6d8080a39a0622f299750e13aa9c200", "3a9c4bb5-6e0d-4b1b-8f2d-6c09a2a5c3a1"))
+	add(models.NewEventGenericFileFixityCheck(now, constants.AlgMd5, "c6d8080a39a0622f299750e13aa9c200", true))
+	add(models.NewEventGenericFileDigestCalculation(now, constants.AlgMd5, "c6d8080a39a0622f299750e13aa9c200"))
+	add(models.NewEventGenericFileValidation(now, nil))
+	add(models.NewEventGenericFileIdentifierAssignment(now, constants.IdTypeBagAndPath, "test.edu/bag/data/file.txt"))
+	add(models.NewEventGenericFileReplication(now, "https://s3.amazonaws.com/bucket/1234"))
+	add(models.NewEventFileMigration(now, constants.StorageStandard, constants.StorageGlacierDeepOH, "https://s3.amazonaws.com/bucket/1234"))
+	add(models.NewEventCompression(now, constants.CompressionGzip, 2048, 1024))
+	add(models.NewEventEncryption(now, constants.EncryptionKMS, "alias/aptrust"))
+	for _, event := range events {
+		assert.Nil(t, event.Validate(), event.EventType)
+	}
+}
+
+func TestWorkItemValidate(t *testing.T) {
+	item := testutil.MakeWorkItem()
+	assert.Nil(t, item.Validate())
+	item.ETag = ""
+	item.Action = "Dance"
+	item.Stage = ""
+	item.ObjectIdentifier = "no-slashes"
+	err := item.Validate()
+	require.NotNil(t, err)
+	modelError := err.(*models.ModelError)
+	assert.Equal(t, 4, len(modelError.Problems), err.Error())
+	assert.Contains(t, err.Error(), "ETag is required")
+	assert.Contains(t, err.Error(), "Action 'Dance' is not one of: ")
+	assert.Contains(t, err.Error(), "Stage is required")
+	assert.Contains(t, err.Error(), "ObjectIdentifier 'no-slashes' should look like 'institution/bag'")
+}
+
+func TestGenericFileValidate(t *testing.T) {
+	gf := testutil.MakeGenericFile(2, 2, "test.edu/bag")
+	assert.Nil(t, gf.Validate())
+	gf.Identifier = "test.edu/other_bag/data/file.txt"
+	gf.IntellectualObjectId = 0
+	gf.StorageOption = "Under the bed"
+	gf.Checksums[1].Digest = ""
+	err := gf.Validate()
+	require.NotNil(t, err)
+	modelError := err.(*models.ModelError)
+	require.Equal(t, 4, len(modelError.Problems), err.Error())
+	assert.Equal(t, "Identifier 'test.edu/other_bag/data/file.txt' does not begin with "+
+		"IntellectualObjectIdentifier 'test.edu/bag'", modelError.Problems[0])
+	assert.Equal(t, "IntellectualObjectId is required", modelError.Problems[1])
+	assert.Contains(t, modelError.Problems[2], "StorageOption 'Under the bed' is not one of: Standard")
+	assert.Contains(t, modelError.Problems[3], "Digest is required")
+
+	gf = testutil.MakeGenericFile(0, 0, "test.edu/bag")
+	gf.Identifier = "file.txt"
+	gf.IntellectualObjectIdentifier = ""
+	err = gf.Validate()
+	require.NotNil(t, err)
+	assert.Equal(t, "GenericFile file.txt is not valid: Identifier 'file.txt' "+
+		"should look like 'institution/bag/path'", err.Error())
+}
+
+func TestIntellectualObjectValidate(t *testing.T) {
+	obj := testutil.MakeIntellectualObject(0, 0, 0, 0)
+	assert.Nil(t, obj.Validate())
+	obj.Access = "Consortia"
+	assert.Nil(t, obj.Validate())
+	obj.Identifier = "example.edu/bag"
+	obj.Institution = "test.edu"
+	obj.Title = ""
+	obj.Access = "everyone"
+	obj.State = "X"
+	err := obj.Validate()
+	require.NotNil(t, err)
+	assert.Equal(t, "IntellectualObject example.edu/bag is not valid: "+
+		"Identifier 'example.edu/bag' does not begin with Institution 'test.edu'; "+
+		"Title is required; "+
+		"Access 'everyone' is not one of: consortia, institution, restricted; "+
+		"State 'X' is not one of: A, D", err.Error())
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// PharosMetrics collects the number, outcome and latency of a
+// worker's requests to Pharos, by method and endpoint, for the
+// current reporting interval. It implements
+// network.PharosRequestObserver, so the Context can hand it to the
+// PharosClient. This structure uses a mutex, so it's safe to share
+// across goroutines.
+type PharosMetrics struct {
+	endpoints map[string]*PharosEndpointMetrics
+	mutex     *sync.Mutex
+}
+
+// PharosEndpointMetrics describes the requests a worker sent to one
+// Pharos endpoint with one HTTP method during a reporting interval.
+type PharosEndpointMetrics struct {
+	// Method is the HTTP method, such as "GET".
+	Method string
+
+	// Endpoint is the path of the request URL, with ids and
+	// identifiers replaced by ":id", such as "/api/v2/items/:id".
+	Endpoint string
+
+	// Requests is the number of requests sent, including retries.
+	Requests int64
+
+	// ClientErrors is the number of requests to which Pharos
+	// responded with a 4xx status. Some of these are expected,
+	// such as 404 when looking for a record that doesn't exist.
+	ClientErrors int64
+
+	// ServerErrors is the number of requests that got no response,
+	// or to which Pharos responded with a 5xx status.
+	ServerErrors int64
+
+	// TotalTime is the total time all of the requests took.
+	TotalTime time.Duration
+
+	// MaxTime is the time the slowest request took.
+	MaxTime time.Duration
+}
+
+// NewPharosMetrics creates a new PharosMetrics object.
+func NewPharosMetrics() *PharosMetrics {
+	return &PharosMetrics{
+		endpoints: make(map[string]*PharosEndpointMetrics),
+		mutex:     &sync.Mutex{},
+	}
+}
+
+// ObservePharosRequest records a request to Pharos. Param statusCode
+// is zero if the request got no response.
+func (metrics *PharosMetrics) ObservePharosRequest(method, endpoint string, statusCode int, duration time.Duration) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	key := method + " " + endpoint
+	endpointMetrics := metrics.endpoints[key]
+	if endpointMetrics == nil {
+		endpointMetrics = &PharosEndpointMetrics{
+			Method:   method,
+			Endpoint: endpoint,
+		}
+		metrics.endpoints[key] = endpointMetrics
+	}
+	endpointMetrics.add(statusCode, duration)
+}
+
+// Snapshot returns the metrics for each endpoint for the current
+// interval, sorted by endpoint and method, and resets everything
+// to start the next interval.
+func (metrics *PharosMetrics) Snapshot() []*PharosEndpointMetrics {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	snapshot := make([]*PharosEndpointMetrics, 0, len(metrics.endpoints))
+	for _, endpointMetrics := range metrics.endpoints {
+		snapshot = append(snapshot, endpointMetrics)
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].Endpoint == snapshot[j].Endpoint {
+			return snapshot[i].Method < snapshot[j].Method
+		}
+		return snapshot[i].Endpoint < snapshot[j].Endpoint
+	})
+	metrics.endpoints = make(map[string]*PharosEndpointMetrics)
+	return snapshot
+}
+
+// PharosMetricsTotals returns the combined metrics for all of the
+// endpoints in snapshot. Method and Endpoint are empty.
+func PharosMetricsTotals(snapshot []*PharosEndpointMetrics) *PharosEndpointMetrics {
+	totals := &PharosEndpointMetrics{}
+	for _, endpointMetrics := range snapshot {
+		totals.Requests += endpointMetrics.Requests
+		totals.ClientErrors += endpointMetrics.ClientErrors
+		totals.ServerErrors += endpointMetrics.ServerErrors
+		totals.TotalTime += endpointMetrics.TotalTime
+		if endpointMetrics.MaxTime > totals.MaxTime {
+			totals.MaxTime = endpointMetrics.MaxTime
+		}
+	}
+	return totals
+}
+
+// AverageTime returns the mean time the requests took, or zero
+// if there were no requests.
+func (endpointMetrics *PharosEndpointMetrics) AverageTime() time.Duration {
+	if endpointMetrics.Requests == 0 {
+		return 0
+	}
+	return endpointMetrics.TotalTime / time.Duration(endpointMetrics.Requests)
+}
+
+// ErrorRate returns the fraction of requests that got server errors,
+// or zero if there were no requests.
+func (endpointMetrics *PharosEndpointMetrics) ErrorRate() float64 {
+	if endpointMetrics.Requests == 0 {
+		return 0
+	}
+	return float64(endpointMetrics.ServerErrors) / float64(endpointMetrics.Requests)
+}
+
+func (endpointMetrics *PharosEndpointMetrics) add(statusCode int, duration time.Duration) {
+	endpointMetrics.Requests += 1
+	if statusCode == 0 || statusCode >= 500 {
+		endpointMetrics.ServerErrors += 1
+	} else if statusCode >= 400 {
+		endpointMetrics.ClientErrors += 1
+	}
+	endpointMetrics.TotalTime += duration
+	if duration > endpointMetrics.MaxTime {
+		endpointMetrics.MaxTime = duration
+	}
+}
@@ -0,0 +1,51 @@
+package models_test
+
+import (
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestPharosMetrics(t *testing.T) {
+	metrics := models.NewPharosMetrics()
+	assert.Empty(t, metrics.Snapshot())
+
+	metrics.ObservePharosRequest("GET", "/api/v2/items/:id", 200, 100*time.Millisecond)
+	metrics.ObservePharosRequest("GET", "/api/v2/items/:id", 404, 300*time.Millisecond)
+	metrics.ObservePharosRequest("GET", "/api/v2/items/:id", 0, 2*time.Second)
+	metrics.ObservePharosRequest("PUT", "/api/v2/items/:id", 503, 600*time.Millisecond)
+	metrics.ObservePharosRequest("GET", "/api/v2/files/:id", 200, 50*time.Millisecond)
+
+	snapshot := metrics.Snapshot()
+	require.Equal(t, 3, len(snapshot))
+
+	// Sorted by endpoint, then method.
+	assert.Equal(t, "/api/v2/files/:id", snapshot[0].Endpoint)
+	assert.Equal(t, "GET", snapshot[1].Method)
+	assert.Equal(t, "/api/v2/items/:id", snapshot[1].Endpoint)
+	assert.Equal(t, "PUT", snapshot[2].Method)
+
+	items := snapshot[1]
+	assert.EqualValues(t, 3, items.Requests)
+	assert.EqualValues(t, 1, items.ClientErrors)
+	assert.EqualValues(t, 1, items.ServerErrors)
+	assert.Equal(t, 2400*time.Millisecond, items.TotalTime)
+	assert.Equal(t, 800*time.Millisecond, items.AverageTime())
+	assert.Equal(t, 2*time.Second, items.MaxTime)
+	assert.InDelta(t, 0.333, items.ErrorRate(), 0.001)
+
+	totals := models.PharosMetricsTotals(snapshot)
+	assert.EqualValues(t, 5, totals.Requests)
+	assert.EqualValues(t, 1, totals.ClientErrors)
+	assert.EqualValues(t, 2, totals.ServerErrors)
+	assert.Equal(t, 3050*time.Millisecond, totals.TotalTime)
+	assert.Equal(t, 2*time.Second, totals.MaxTime)
+
+	// Snapshot starts a new interval.
+	assert.Empty(t, metrics.Snapshot())
+	empty := &models.PharosEndpointMetrics{}
+	assert.Equal(t, time.Duration(0), empty.AverageTime())
+	assert.Equal(t, float64(0), empty.ErrorRate())
+}
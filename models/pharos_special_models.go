@@ -8,23 +8,30 @@ import (
 // IntellectualObject in the format that Pharos accepts for
 // POST/create.
 type IntellectualObjectForPharos struct {
-	Identifier             string `json:"identifier"`
-	BagName                string `json:"bag_name"`
-	BagGroupIdentifier     string `json:"bag_group_identifier"`
-	InstitutionId          int    `json:"institution_id"`
-	Title                  string `json:"title"`
-	Description            string `json:"description"`
-	AltIdentifier          string `json:"alt_identifier"`
-	Access                 string `json:"access"`
-	DPNUUID                string `json:"dpn_uuid"`
-	ETag                   string `json:"etag"`
-	State                  string `json:"state"`
-	StorageOption          string `json:"storage_option"`
-	SourceOrganization     string `json:"source_organization"`
-	BagItProfileIdentifier string `json:"bagit_profile_identifier"`
+	Identifier             string     `json:"identifier"`
+	BagName                string     `json:"bag_name"`
+	BagGroupIdentifier     string     `json:"bag_group_identifier"`
+	InstitutionId          int        `json:"institution_id"`
+	Title                  string     `json:"title"`
+	Description            string     `json:"description"`
+	AltIdentifier          string     `json:"alt_identifier"`
+	Access                 string     `json:"access"`
+	DPNUUID                string     `json:"dpn_uuid"`
+	ETag                   string     `json:"etag"`
+	State                  string     `json:"state"`
+	StorageOption          string     `json:"storage_option"`
+	SourceOrganization     string     `json:"source_organization"`
+	BagItProfileIdentifier string     `json:"bagit_profile_identifier"`
+	EmbargoUntil           *time.Time `json:"embargo_until"`
+	RightsStatement        string     `json:"rights_statement"`
 }
 
 func NewIntellectualObjectForPharos(obj *IntellectualObject) *IntellectualObjectForPharos {
+	// Pharos wants null, not year 1, for objects without an embargo.
+	var embargoUntil *time.Time
+	if !obj.EmbargoUntil.IsZero() {
+		embargoUntil = &obj.EmbargoUntil
+	}
 	return &IntellectualObjectForPharos{
 		Identifier:             obj.Identifier,
 		BagName:                obj.BagName,
@@ -40,6 +47,8 @@ func NewIntellectualObjectForPharos(obj *IntellectualObject) *IntellectualObject
 		ETag:                   obj.ETag,
 		State:                  obj.State,
 		StorageOption:          obj.StorageOption,
+		EmbargoUntil:           embargoUntil,
+		RightsStatement:        obj.RightsStatement,
 	}
 }
 
@@ -54,12 +63,22 @@ type GenericFileForPharos struct {
 	URI                  string `json:"uri"`
 	Size                 int64  `json:"size"`
 	StorageOption        string `json:"storage_option"`
+	// LastFixityCheck is nil for files that have never had a
+	// successful fixity check. Pharos' fixity scheduling queries
+	// treat null as "due now".
+	LastFixityCheck *time.Time `json:"last_fixity_check"`
+	// PronomPuid and FormatConfidence are empty if we did not
+	// identify the file's format, so Pharos instances that don't
+	// know these fields won't see them.
+	PronomPuid       string `json:"pronom_puid,omitempty"`
+	FormatConfidence string `json:"format_confidence,omitempty"`
 	// TODO: Next two items are not part of Pharos model, but they should be.
 	// We need to add these to the Rails schema.
 	//	FileCreated                  time.Time      `json:"file_created"`
 	//	FileModified                 time.Time      `json:"file_modified"`
-	Checksums    []*ChecksumForPharos    `json:"checksums_attributes"`
-	PremisEvents []*PremisEventForPharos `json:"premis_events_attributes"`
+	Checksums            []*ChecksumForPharos             `json:"checksums_attributes"`
+	PremisEvents         []*PremisEventForPharos          `json:"premis_events_attributes"`
+	StorageOptionHistory []*StorageOptionHistoryForPharos `json:"storage_option_histories_attributes"`
 }
 
 func NewGenericFileForPharos(gf *GenericFile) *GenericFileForPharos {
@@ -71,6 +90,15 @@ func NewGenericFileForPharos(gf *GenericFile) *GenericFileForPharos {
 	for i, event := range gf.PremisEvents {
 		events[i] = NewPremisEventForPharos(event)
 	}
+	histories := make([]*StorageOptionHistoryForPharos, len(gf.StorageOptionHistory))
+	for i, history := range gf.StorageOptionHistory {
+		histories[i] = NewStorageOptionHistoryForPharos(history)
+	}
+	// As with EmbargoUntil on objects, Pharos wants null, not year 1.
+	var lastFixityCheck *time.Time
+	if !gf.LastFixityCheck.IsZero() {
+		lastFixityCheck = &gf.LastFixityCheck
+	}
 	return &GenericFileForPharos{
 		Identifier:           gf.Identifier,
 		IntellectualObjectId: gf.IntellectualObjectId,
@@ -78,11 +106,15 @@ func NewGenericFileForPharos(gf *GenericFile) *GenericFileForPharos {
 		URI:                  gf.URI,
 		Size:                 gf.Size,
 		StorageOption:        gf.StorageOption,
+		LastFixityCheck:      lastFixityCheck,
+		PronomPuid:           gf.PronomPuid,
+		FormatConfidence:     gf.FormatConfidence,
 		// TODO: See note above. Add these to Rails!
 		//		FileCreated:                    gf.FileCreated,
 		//		FileModified:                   gf.FileModified,
-		Checksums:    checksums,
-		PremisEvents: events,
+		Checksums:            checksums,
+		PremisEvents:         events,
+		StorageOptionHistory: histories,
 	}
 }
 
@@ -125,35 +157,68 @@ func NewPremisEventForPharos(event *PremisEvent) *PremisEventForPharos {
 
 // Same as Checksum, but without CreatedAt and UpdatedAt
 type ChecksumForPharos struct {
-	Id            int       `json:"id,omitempty"` // Do not serialize zero to JSON!
-	GenericFileId int       `json:"generic_file_id"`
-	Algorithm     string    `json:"algorithm"`
-	DateTime      time.Time `json:"datetime"`
-	Digest        string    `json:"digest"`
+	Id            int        `json:"id,omitempty"` // Do not serialize zero to JSON!
+	GenericFileId int        `json:"generic_file_id"`
+	Algorithm     string     `json:"algorithm"`
+	DateTime      time.Time  `json:"datetime"`
+	Digest        string     `json:"digest"`
+	Source        string     `json:"source,omitempty"`
+	VerifiedAt    *time.Time `json:"verified_at,omitempty"`
 }
 
 func NewChecksumForPharos(cs *Checksum) *ChecksumForPharos {
+	// As with EmbargoUntil, send nothing rather than year 1
+	// for checksums we never verified.
+	var verifiedAt *time.Time
+	if !cs.VerifiedAt.IsZero() {
+		verifiedAt = &cs.VerifiedAt
+	}
 	return &ChecksumForPharos{
 		Id:            cs.Id,
 		GenericFileId: cs.GenericFileId,
 		Algorithm:     cs.Algorithm,
 		DateTime:      cs.DateTime,
 		Digest:        cs.Digest,
+		Source:        cs.Source,
+		VerifiedAt:    verifiedAt,
+	}
+}
+
+// Same as StorageOptionHistory, but omits CreatedAt and UpdatedAt
+type StorageOptionHistoryForPharos struct {
+	Id            int       `json:"id,omitempty"` // Do not serialize zero to JSON!
+	GenericFileId int       `json:"generic_file_id"`
+	Option        string    `json:"option"`
+	ChangedAt     time.Time `json:"changed_at"`
+	Reason        string    `json:"reason"`
+	WorkItemId    int       `json:"work_item_id"`
+}
+
+func NewStorageOptionHistoryForPharos(history *StorageOptionHistory) *StorageOptionHistoryForPharos {
+	return &StorageOptionHistoryForPharos{
+		Id:            history.Id,
+		GenericFileId: history.GenericFileId,
+		Option:        history.Option,
+		ChangedAt:     history.ChangedAt,
+		Reason:        history.Reason,
+		WorkItemId:    history.WorkItemId,
 	}
 }
 
 type WorkItemStateForPharos struct {
-	Id         int    `json:"id"`
-	WorkItemId int    `json:"work_item_id"`
-	Action     string `json:"action"`
-	State      string `json:"state"`
+	Id              int    `json:"id"`
+	WorkItemId      int    `json:"work_item_id"`
+	Action          string `json:"action"`
+	State           string `json:"state"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
 }
 
 func NewWorkItemStateForPharos(workItemState *WorkItemState) *WorkItemStateForPharos {
 	return &WorkItemStateForPharos{
-		Id:         workItemState.Id,
-		WorkItemId: workItemState.WorkItemId,
-		Action:     workItemState.Action,
-		State:      workItemState.State,
+		Id:              workItemState.Id,
+		WorkItemId:      workItemState.WorkItemId,
+		Action:          workItemState.Action,
+		State:           workItemState.State,
+		ContentEncoding: workItemState.ContentEncoding,
 	}
 }
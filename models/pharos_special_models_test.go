@@ -1,6 +1,8 @@
 package models_test
 
 import (
+	"encoding/json"
+	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/util/testutil"
 	"github.com/stretchr/testify/assert"
@@ -8,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewGenericFileForPharos(t *testing.T) {
@@ -15,10 +18,14 @@ func TestNewGenericFileForPharos(t *testing.T) {
 	intelObj, err := testutil.LoadIntelObjFixture(filename)
 	require.Nil(t, err)
 	gf := intelObj.GenericFiles[1]
+	gf.PronomPuid = "fmt/101"
+	gf.FormatConfidence = constants.FormatConfidenceHigh
 	pharosGf := models.NewGenericFileForPharos(gf)
 	assert.Equal(t, gf.Identifier, pharosGf.Identifier)
 	assert.Equal(t, gf.IntellectualObjectId, pharosGf.IntellectualObjectId)
 	assert.Equal(t, gf.FileFormat, pharosGf.FileFormat)
+	assert.Equal(t, "fmt/101", pharosGf.PronomPuid)
+	assert.Equal(t, constants.FormatConfidenceHigh, pharosGf.FormatConfidence)
 	assert.Equal(t, gf.URI, pharosGf.URI)
 	assert.Equal(t, gf.Size, pharosGf.Size)
 	// TODO: Add these back when they're part of the Rails model
@@ -34,6 +41,27 @@ func TestNewGenericFileForPharos(t *testing.T) {
 	}
 }
 
+func TestNewGenericFileForPharosStorageAndFixity(t *testing.T) {
+	gf := testutil.MakeGenericFile(0, 0, "test.edu/bag")
+	gf.StorageOption = constants.StorageGlacierDeepOR
+	gf.LastFixityCheck = time.Time{}
+	pharosGf := models.NewGenericFileForPharos(gf)
+	assert.Equal(t, constants.StorageGlacierDeepOR, pharosGf.StorageOption)
+	assert.Nil(t, pharosGf.LastFixityCheck)
+	data, err := json.Marshal(pharosGf)
+	require.Nil(t, err)
+	assert.Contains(t, string(data), `"storage_option":"Glacier-Deep-OR"`)
+	assert.Contains(t, string(data), `"last_fixity_check":null`)
+
+	gf.LastFixityCheck = time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	pharosGf = models.NewGenericFileForPharos(gf)
+	require.NotNil(t, pharosGf.LastFixityCheck)
+	assert.Equal(t, gf.LastFixityCheck, *pharosGf.LastFixityCheck)
+	data, err = json.Marshal(pharosGf)
+	require.Nil(t, err)
+	assert.Contains(t, string(data), `"last_fixity_check":"2030-06-01T12:00:00Z"`)
+}
+
 func TestNewIntellectualObjectForPharos(t *testing.T) {
 	filename := filepath.Join("testdata", "json_objects", "intel_obj.json")
 	intelObj, err := testutil.LoadIntelObjFixture(filename)
@@ -87,6 +115,31 @@ func TestNewChecksumForPharos(t *testing.T) {
 	assert.Equal(t, cs.Algorithm, pharosChecksum.Algorithm)
 	assert.Equal(t, cs.DateTime, pharosChecksum.DateTime)
 	assert.Equal(t, cs.Digest, pharosChecksum.Digest)
+	assert.Equal(t, cs.Source, pharosChecksum.Source)
+	assert.Nil(t, pharosChecksum.VerifiedAt)
+
+	cs.VerifiedAt = time.Now().UTC()
+	pharosChecksum = models.NewChecksumForPharos(cs)
+	require.NotNil(t, pharosChecksum.VerifiedAt)
+	assert.Equal(t, cs.VerifiedAt, *pharosChecksum.VerifiedAt)
+}
+
+func TestNewStorageOptionHistoryForPharos(t *testing.T) {
+	history := &models.StorageOptionHistory{
+		Id:            12,
+		GenericFileId: 34,
+		Option:        "Glacier-OH",
+		ChangedAt:     testutil.RandomDateTime(),
+		Reason:        "Moved to Glacier",
+		WorkItemId:    56,
+	}
+	pharosHistory := models.NewStorageOptionHistoryForPharos(history)
+	assert.Equal(t, history.Id, pharosHistory.Id)
+	assert.Equal(t, history.GenericFileId, pharosHistory.GenericFileId)
+	assert.Equal(t, history.Option, pharosHistory.Option)
+	assert.Equal(t, history.ChangedAt, pharosHistory.ChangedAt)
+	assert.Equal(t, history.Reason, pharosHistory.Reason)
+	assert.Equal(t, history.WorkItemId, pharosHistory.WorkItemId)
 }
 
 func TestNewWorkItemStateForPharos(t *testing.T) {
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"sync"
+)
+
+// DefaultPremisAgentURI is the Agent of the PREMIS events our own
+// services create, unless the config says otherwise.
+const DefaultPremisAgentURI = "https://github.com/APTrust/exchange"
+
+// PremisAgent identifies the software that records PREMIS events.
+// The NewEvent* constructors use it for the Object and Agent of
+// events created by our own services. Events created by third-party
+// libraries, such as Go's crypto packages and the AWS SDK, keep
+// naming those libraries.
+//
+// The zero value means the APTrust defaults, which are the strings
+// we've always used. White-label and test deployments can set their
+// own identity through Config.PremisAgentName, Config.PremisAgentURI
+// and Config.PremisAgentVersion, which context.NewContext passes to
+// SetPremisAgent.
+type PremisAgent struct {
+	Name    string
+	URI     string
+	Version string
+}
+
+var currentPremisAgent = PremisAgent{}
+var premisAgentMutex = &sync.RWMutex{}
+
+// SetPremisAgent sets the identity the NewEvent* constructors use
+// in new events. Pass an empty PremisAgent to restore the defaults.
+func SetPremisAgent(agent PremisAgent) {
+	premisAgentMutex.Lock()
+	currentPremisAgent = agent
+	premisAgentMutex.Unlock()
+}
+
+// CurrentPremisAgent returns the identity the NewEvent* constructors
+// use in new events.
+func CurrentPremisAgent() PremisAgent {
+	premisAgentMutex.RLock()
+	defer premisAgentMutex.RUnlock()
+	return currentPremisAgent
+}
+
+// object returns the Object for an event created by our own service.
+// Param defaultObject is the Object we use when no Name is set, and
+// service describes the part of our software that created the event,
+// such as "bag validator". It may be empty.
+func (agent PremisAgent) object(defaultObject, service string) string {
+	object := defaultObject
+	if agent.Name != "" {
+		object = agent.Name
+		if service != "" {
+			object += " " + service
+		}
+	}
+	if agent.Version != "" {
+		object += " " + agent.Version
+	}
+	return object
+}
+
+// uri returns the Agent for an event created by our own service.
+func (agent PremisAgent) uri() string {
+	if agent.URI == "" {
+		return DefaultPremisAgentURI
+	}
+	return agent.URI
+}
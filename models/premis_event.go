@@ -93,6 +93,7 @@ func (premisEvent *PremisEvent) EventTypeValid() bool {
 
 func NewEventObjectCreation() *PremisEvent {
 	eventId := uuid.New()
+	agent := CurrentPremisAgent()
 	return &PremisEvent{
 		Identifier:         eventId.String(),
 		EventType:          constants.EventCreation,
@@ -100,8 +101,8 @@ func NewEventObjectCreation() *PremisEvent {
 		Detail:             "Object created.",
 		Outcome:            string(constants.StatusSuccess),
 		OutcomeDetail:      "Intellectual object created.",
-		Object:             "APTrust Exchange ingest services",
-		Agent:              "https://github.com/APTrust/exchange",
+		Object:             agent.object("APTrust Exchange ingest services", "ingest services"),
+		Agent:              agent.uri(),
 		OutcomeInformation: "Object created, files stored and replicated, awaiting recording of all files and events in Pharos.",
 	}
 }
@@ -129,6 +130,7 @@ func NewEventObjectIdentifierAssignment(objectIdentifier string) (*PremisEvent,
 		return nil, fmt.Errorf("Param objectIdentifier cannot be empty.")
 	}
 	eventId := uuid.New()
+	agent := CurrentPremisAgent()
 	return &PremisEvent{
 		Identifier:         eventId.String(),
 		EventType:          constants.EventIdentifierAssignment,
@@ -136,8 +138,8 @@ func NewEventObjectIdentifierAssignment(objectIdentifier string) (*PremisEvent,
 		Detail:             "Assigned bag identifier",
 		Outcome:            string(constants.StatusSuccess),
 		OutcomeDetail:      objectIdentifier,
-		Object:             "APTrust exchange",
-		Agent:              "https://github.com/APTrust/exchange",
+		Object:             agent.object("APTrust exchange", ""),
+		Agent:              agent.uri(),
 		OutcomeInformation: "Institution domain + tar file name",
 	}, nil
 }
@@ -147,6 +149,7 @@ func NewEventObjectRights(accessSetting string) (*PremisEvent, error) {
 		return nil, fmt.Errorf("Param accessSetting '%s' is not valid.", accessSetting)
 	}
 	eventId := uuid.New()
+	agent := CurrentPremisAgent()
 	return &PremisEvent{
 		Identifier:         eventId.String(),
 		EventType:          constants.EventAccessAssignment,
@@ -154,8 +157,8 @@ func NewEventObjectRights(accessSetting string) (*PremisEvent, error) {
 		Detail:             "Assigned bag access rights",
 		Outcome:            string(constants.StatusSuccess),
 		OutcomeDetail:      accessSetting,
-		Object:             "APTrust exchange",
-		Agent:              "https://github.com/APTrust/exchange",
+		Object:             agent.object("APTrust exchange", ""),
+		Agent:              agent.uri(),
 		OutcomeInformation: "Set access to " + accessSetting,
 	}, nil
 }
@@ -174,6 +177,7 @@ func NewEventGenericFileIngest(storedAt time.Time, md5Digest, _uuid string) (*Pr
 			_uuid)
 	}
 	eventId := uuid.New()
+	agent := CurrentPremisAgent()
 	return &PremisEvent{
 		Identifier:         eventId.String(),
 		EventType:          constants.EventIngestion,
@@ -181,7 +185,7 @@ func NewEventGenericFileIngest(storedAt time.Time, md5Digest, _uuid string) (*Pr
 		Detail:             fmt.Sprintf("Completed copy to S3 (%s)", _uuid),
 		Outcome:            string(constants.StatusSuccess),
 		OutcomeDetail:      fmt.Sprintf("md5:%s", md5Digest),
-		Object:             "exchange + AWS Go SDK S3 client",
+		Object:             agent.object("exchange", "") + " + AWS Go SDK S3 client",
 		Agent:              "https://github.com/aws/aws-sdk-go",
 		OutcomeInformation: "Put using md5 checksum",
 	}, nil
@@ -196,8 +200,8 @@ func NewEventGenericFileFixityCheck(checksumVerifiedAt time.Time, fixityAlg, dig
 	if !util.StringListContains(constants.ChecksumAlgorithms, fixityAlg) {
 		return nil, fmt.Errorf("Param fixityAlg '%s' is not valid.", fixityAlg)
 	}
-	if len(digest) != 32 && len(digest) != 64 {
-		return nil, fmt.Errorf("Param digest must have 32 or 64 characters. '%s' doesn't.",
+	if len(digest) != 32 && len(digest) != 64 && len(digest) != 128 {
+		return nil, fmt.Errorf("Param digest must have 32, 64 or 128 characters. '%s' doesn't.",
 			digest)
 	}
 	eventId := uuid.New()
@@ -208,6 +212,12 @@ func NewEventGenericFileFixityCheck(checksumVerifiedAt time.Time, fixityAlg, dig
 	if fixityAlg == constants.AlgSha256 {
 		object = "Go language crypto/sha256"
 		agent = "http://golang.org/pkg/crypto/sha256/"
+	} else if fixityAlg == constants.AlgSha512 {
+		object = "Go language crypto/sha512"
+		agent = "http://golang.org/pkg/crypto/sha512/"
+	} else if fixityAlg == constants.AlgBlake2b {
+		object = "Go language golang.org/x/crypto/blake2b"
+		agent = "https://pkg.go.dev/golang.org/x/crypto/blake2b"
 	}
 	if fixityMatched == false {
 		outcome = string(constants.StatusFailed)
@@ -234,8 +244,8 @@ func NewEventGenericFileDigestCalculation(checksumGeneratedAt time.Time, fixityA
 	if !util.StringListContains(constants.ChecksumAlgorithms, fixityAlg) {
 		return nil, fmt.Errorf("Param fixityAlg '%s' is not valid.", fixityAlg)
 	}
-	if len(digest) != 32 && len(digest) != 64 {
-		return nil, fmt.Errorf("Param digest must have 32 or 64 characters. '%s' doesn't.",
+	if len(digest) != 32 && len(digest) != 64 && len(digest) != 128 {
+		return nil, fmt.Errorf("Param digest must have 32, 64 or 128 characters. '%s' doesn't.",
 			digest)
 	}
 	eventId := uuid.New()
@@ -244,6 +254,12 @@ func NewEventGenericFileDigestCalculation(checksumGeneratedAt time.Time, fixityA
 	if fixityAlg == constants.AlgSha256 {
 		object = "Go language crypto/sha256"
 		agent = "http://golang.org/pkg/crypto/sha256/"
+	} else if fixityAlg == constants.AlgSha512 {
+		object = "Go language crypto/sha512"
+		agent = "http://golang.org/pkg/crypto/sha512/"
+	} else if fixityAlg == constants.AlgBlake2b {
+		object = "Go language golang.org/x/crypto/blake2b"
+		agent = "https://pkg.go.dev/golang.org/x/crypto/blake2b"
 	}
 	return &PremisEvent{
 		Identifier:         eventId.String(),
@@ -258,6 +274,36 @@ func NewEventGenericFileDigestCalculation(checksumGeneratedAt time.Time, fixityA
 	}, nil
 }
 
+// We validated a generic file against the bag's manifests and the
+// bag validation config. Param problems describes everything that
+// was wrong with the file. If it's empty, the file passed.
+func NewEventGenericFileValidation(validatedAt time.Time, problems []string) (*PremisEvent, error) {
+	if validatedAt.IsZero() {
+		return nil, fmt.Errorf("Param validatedAt cannot be empty.")
+	}
+	eventId := uuid.New()
+	outcome := string(constants.StatusSuccess)
+	outcomeDetail := "File matches its manifest entries"
+	outcomeInformation := "File is valid"
+	if len(problems) > 0 {
+		outcome = string(constants.StatusFailed)
+		outcomeDetail = strings.Join(problems, "; ")
+		outcomeInformation = "File is not valid"
+	}
+	agent := CurrentPremisAgent()
+	return &PremisEvent{
+		Identifier:         eventId.String(),
+		EventType:          constants.EventValidation,
+		DateTime:           validatedAt,
+		Detail:             "Validated file against bag manifests",
+		Outcome:            outcome,
+		OutcomeDetail:      outcomeDetail,
+		Object:             agent.object("APTrust exchange bag validator", "bag validator"),
+		Agent:              agent.uri(),
+		OutcomeInformation: outcomeInformation,
+	}, nil
+}
+
 // We assigned an identifier: either a generic file identifier
 // or a new storage URL. Note that when identifierType is
 // constants.IdTypeStorageURL, identifierGeneratedAt is the
@@ -273,8 +319,9 @@ func NewEventGenericFileIdentifierAssignment(identifierGeneratedAt time.Time, id
 		return nil, fmt.Errorf("Param identifier cannot be empty.")
 	}
 	eventId := uuid.New()
-	object := "APTrust exchange/ingest processor"
-	agent := "https://github.com/APTrust/exchange"
+	premisAgent := CurrentPremisAgent()
+	object := premisAgent.object("APTrust exchange/ingest processor", "ingest processor")
+	agent := premisAgent.uri()
 	detail := "Assigned new institution.bag/path identifier"
 	if identifierType == constants.IdTypeStorageURL {
 		object = "Go uuid library + AWS Go SDK S3 library"
@@ -319,6 +366,102 @@ func NewEventGenericFileReplication(replicatedAt time.Time, replicationUrl strin
 	}, nil
 }
 
+// We copied the file from one storage option to another, such as from
+// Standard to Glacier-Deep-OH. Param newURI is the file's URL in the
+// new storage.
+func NewEventFileMigration(migratedAt time.Time, fromStorageOption, toStorageOption, newURI string) (*PremisEvent, error) {
+	if migratedAt.IsZero() {
+		return nil, fmt.Errorf("Param migratedAt cannot be empty.")
+	}
+	if !util.StringListContains(constants.StorageOptions, fromStorageOption) {
+		return nil, fmt.Errorf("Param fromStorageOption '%s' is not valid.", fromStorageOption)
+	}
+	if !util.StringListContains(constants.StorageOptions, toStorageOption) {
+		return nil, fmt.Errorf("Param toStorageOption '%s' is not valid.", toStorageOption)
+	}
+	if fromStorageOption == toStorageOption {
+		return nil, fmt.Errorf("Params fromStorageOption and toStorageOption cannot both be '%s'.",
+			toStorageOption)
+	}
+	if newURI == "" {
+		return nil, fmt.Errorf("Param newURI cannot be empty.")
+	}
+	eventId := uuid.New()
+	return &PremisEvent{
+		Identifier:         eventId.String(),
+		EventType:          constants.EventMigration,
+		DateTime:           migratedAt,
+		Detail:             fmt.Sprintf("Moved file from %s to %s storage", fromStorageOption, toStorageOption),
+		Outcome:            string(constants.StatusSuccess),
+		OutcomeDetail:      newURI,
+		Object:             "AWS Go SDK S3 client",
+		Agent:              "https://github.com/aws/aws-sdk-go",
+		OutcomeInformation: fmt.Sprintf("Storage option changed to %s", toStorageOption),
+	}, nil
+}
+
+// We compressed the file with the specified algorithm, which must be
+// one of constants.CompressionAlgorithms.
+func NewEventCompression(compressedAt time.Time, algorithm string, originalSize, compressedSize int64) (*PremisEvent, error) {
+	if compressedAt.IsZero() {
+		return nil, fmt.Errorf("Param compressedAt cannot be empty.")
+	}
+	if !util.StringListContains(constants.CompressionAlgorithms, algorithm) {
+		return nil, fmt.Errorf("Param algorithm '%s' is not valid.", algorithm)
+	}
+	if originalSize < 0 {
+		return nil, fmt.Errorf("Param originalSize cannot be negative.")
+	}
+	if compressedSize <= 0 {
+		return nil, fmt.Errorf("Param compressedSize must be greater than zero.")
+	}
+	eventId := uuid.New()
+	agent := CurrentPremisAgent()
+	return &PremisEvent{
+		Identifier:         eventId.String(),
+		EventType:          constants.EventCompression,
+		DateTime:           compressedAt,
+		Detail:             fmt.Sprintf("Compressed file using %s", algorithm),
+		Outcome:            string(constants.StatusSuccess),
+		OutcomeDetail:      fmt.Sprintf("%s:%d bytes to %d bytes", algorithm, originalSize, compressedSize),
+		Object:             agent.object("APTrust exchange", ""),
+		Agent:              agent.uri(),
+		OutcomeInformation: "File compressed before storage",
+	}, nil
+}
+
+// We encrypted the file with the specified algorithm, which must be
+// one of constants.EncryptionAlgorithms. Param keyId identifies the
+// KMS key, and is required only for constants.EncryptionKMS. Never
+// pass key material here, since events are visible to depositors.
+func NewEventEncryption(encryptedAt time.Time, algorithm, keyId string) (*PremisEvent, error) {
+	if encryptedAt.IsZero() {
+		return nil, fmt.Errorf("Param encryptedAt cannot be empty.")
+	}
+	if !util.StringListContains(constants.EncryptionAlgorithms, algorithm) {
+		return nil, fmt.Errorf("Param algorithm '%s' is not valid.", algorithm)
+	}
+	if algorithm == constants.EncryptionKMS && keyId == "" {
+		return nil, fmt.Errorf("Param keyId cannot be empty when algorithm is %s.", algorithm)
+	}
+	outcomeDetail := algorithm
+	if keyId != "" {
+		outcomeDetail = fmt.Sprintf("%s:%s", algorithm, keyId)
+	}
+	eventId := uuid.New()
+	return &PremisEvent{
+		Identifier:         eventId.String(),
+		EventType:          constants.EventEncryption,
+		DateTime:           encryptedAt,
+		Detail:             "Encrypted file in preservation storage",
+		Outcome:            string(constants.StatusSuccess),
+		OutcomeDetail:      outcomeDetail,
+		Object:             "AWS S3 server-side encryption",
+		Agent:              "https://docs.aws.amazon.com/AmazonS3/latest/dev/serv-side-encryption.html",
+		OutcomeInformation: "Server-side encryption applied",
+	}, nil
+}
+
 // NewEventFileDeletion creates a new file deletion event.
 func NewEventFileDeletion(fileUUID, requestedBy, instApprover, aptrustApprover string, timestamp time.Time) *PremisEvent {
 	eventId := uuid.New()
@@ -330,6 +473,7 @@ func NewEventFileDeletion(fileUUID, requestedBy, instApprover, aptrustApprover s
 	if aptrustApprover != "" {
 		outcomeInfo += fmt.Sprintf(" APTrust approver: %s.", aptrustApprover)
 	}
+	agent := CurrentPremisAgent()
 	return &PremisEvent{
 		Identifier:         eventId.String(),
 		EventType:          constants.EventDeletion,
@@ -337,12 +481,39 @@ func NewEventFileDeletion(fileUUID, requestedBy, instApprover, aptrustApprover s
 		Detail:             fmt.Sprintf("File %s deleted from long-term storage.", fileUUID),
 		Outcome:            string(constants.StatusSuccess),
 		OutcomeDetail:      outcomeDetail,
-		Object:             "APTrust Exchange apt_delete service",
-		Agent:              "https://github.com/APTrust/exchange",
+		Object:             agent.object("APTrust Exchange apt_delete service", "apt_delete service"),
+		Agent:              agent.uri(),
 		OutcomeInformation: outcomeInfo,
 	}
 }
 
+// NewEventObjectDeletion creates a new object-level deletion event.
+// The deleter records this when the last active file belonging to
+// an IntellectualObject has been deleted.
+func NewEventObjectDeletion(objIdentifier, requestedBy, instApprover, aptrustApprover string, timestamp time.Time) *PremisEvent {
+	eventId := uuid.New()
+	outcomeInfo := fmt.Sprintf("Object deleted at the request of %s.", requestedBy)
+	if instApprover != "" {
+		outcomeInfo += fmt.Sprintf(" Institutional approver: %s.", instApprover)
+	}
+	if aptrustApprover != "" {
+		outcomeInfo += fmt.Sprintf(" APTrust approver: %s.", aptrustApprover)
+	}
+	agent := CurrentPremisAgent()
+	return &PremisEvent{
+		Identifier:                   eventId.String(),
+		EventType:                    constants.EventDeletion,
+		DateTime:                     timestamp,
+		Detail:                       fmt.Sprintf("Object %s and all of its files deleted from long-term storage.", objIdentifier),
+		Outcome:                      string(constants.StatusSuccess),
+		OutcomeDetail:                requestedBy,
+		Object:                       agent.object("APTrust Exchange apt_delete service", "apt_delete service"),
+		Agent:                        agent.uri(),
+		OutcomeInformation:           outcomeInfo,
+		IntellectualObjectIdentifier: objIdentifier,
+	}
+}
+
 // Sets the Id, CreatedAt and UpdatedAt properties of this event to
 // match those os savedEvent. We call this after saving a record to
 // Pharos, which sets all of those properties. Generally, savedEvent
@@ -385,3 +556,21 @@ func (event *PremisEvent) Clone() *PremisEvent {
 		UpdatedAt:                    event.UpdatedAt,
 	}
 }
+
+// Validate returns a ModelError if the event is missing a field Pharos
+// requires, has an unknown event type, or names a file that doesn't
+// belong to its object.
+func (event *PremisEvent) Validate() error {
+	checker := newModelChecker("PremisEvent", event.Identifier)
+	checker.require("Identifier", event.Identifier)
+	checker.requireOneOf("EventType", event.EventType, constants.EventTypes, true)
+	checker.requireTime("DateTime", event.DateTime)
+	checker.require("Detail", event.Detail)
+	checker.require("Outcome", event.Outcome)
+	checker.require("OutcomeDetail", event.OutcomeDetail)
+	checker.require("Object", event.Object)
+	checker.require("Agent", event.Agent)
+	checker.requirePrefix("GenericFileIdentifier", event.GenericFileIdentifier,
+		"IntellectualObjectIdentifier", event.IntellectualObjectIdentifier)
+	return checker.result()
+}
@@ -255,6 +255,36 @@ func TestNewEventGenericFileDigestCalculation(t *testing.T) {
 	assert.Equal(t, "Go language crypto/sha256", event.Object)
 	assert.Equal(t, "http://golang.org/pkg/crypto/sha256/", event.Agent)
 	assert.Equal(t, "Calculated fixity value", event.OutcomeInformation)
+
+	sha512Digest := strings.Repeat("a", 128)
+	event, err = models.NewEventGenericFileDigestCalculation(testutil.TEST_TIMESTAMP, constants.AlgSha512, sha512Digest)
+	require.Nil(t, err)
+	assert.Equal(t, "sha512:"+sha512Digest, event.OutcomeDetail)
+	assert.Equal(t, "Go language crypto/sha512", event.Object)
+	assert.Equal(t, "http://golang.org/pkg/crypto/sha512/", event.Agent)
+}
+
+func TestNewEventGenericFileValidation(t *testing.T) {
+	_, err := models.NewEventGenericFileValidation(time.Time{}, nil)
+	require.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "Param"))
+
+	event, err := models.NewEventGenericFileValidation(testutil.TEST_TIMESTAMP, nil)
+	require.Nil(t, err)
+	assert.Len(t, event.Identifier, 36)
+	assert.Equal(t, "validation", event.EventType)
+	assert.Equal(t, testutil.TEST_TIMESTAMP, event.DateTime)
+	assert.Equal(t, "Success", event.Outcome)
+	assert.Equal(t, "File matches its manifest entries", event.OutcomeDetail)
+	assert.Equal(t, "File is valid", event.OutcomeInformation)
+	assert.True(t, event.EventTypeValid())
+
+	event, err = models.NewEventGenericFileValidation(testutil.TEST_TIMESTAMP,
+		[]string{"bad md5 digest", "invalid file name"})
+	require.Nil(t, err)
+	assert.Equal(t, "Failed", event.Outcome)
+	assert.Equal(t, "bad md5 digest; invalid file name", event.OutcomeDetail)
+	assert.Equal(t, "File is not valid", event.OutcomeInformation)
 }
 
 func TestNewEventGenericFileIdentifierAssignment(t *testing.T) {
@@ -336,6 +366,81 @@ func TestNewEventGenericFileReplication(t *testing.T) {
 	assert.Equal(t, "Replicated to secondary storage", event.OutcomeInformation)
 }
 
+func TestNewEventFileMigration(t *testing.T) {
+	uri := "https://s3.amazonaws.com/aptrust.preservation.glacier-deep.oh/1234"
+	// Test with required params missing or invalid
+	_, err := models.NewEventFileMigration(time.Time{}, constants.StorageStandard, constants.StorageGlacierDeepOH, uri)
+	assert.NotNil(t, err)
+	_, err = models.NewEventFileMigration(testutil.TEST_TIMESTAMP, "Tape", constants.StorageGlacierDeepOH, uri)
+	assert.NotNil(t, err)
+	_, err = models.NewEventFileMigration(testutil.TEST_TIMESTAMP, constants.StorageStandard, "Tape", uri)
+	assert.NotNil(t, err)
+	_, err = models.NewEventFileMigration(testutil.TEST_TIMESTAMP, constants.StorageStandard, constants.StorageStandard, uri)
+	assert.NotNil(t, err)
+	_, err = models.NewEventFileMigration(testutil.TEST_TIMESTAMP, constants.StorageStandard, constants.StorageGlacierDeepOH, "")
+	require.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "Param"))
+
+	event, err := models.NewEventFileMigration(testutil.TEST_TIMESTAMP, constants.StorageStandard, constants.StorageGlacierDeepOH, uri)
+	require.Nil(t, err)
+	assert.Len(t, event.Identifier, 36)
+	assert.Equal(t, "migration", event.EventType)
+	assert.Equal(t, testutil.TEST_TIMESTAMP, event.DateTime)
+	assert.Equal(t, "Moved file from Standard to Glacier-Deep-OH storage", event.Detail)
+	assert.Equal(t, "Success", event.Outcome)
+	assert.Equal(t, uri, event.OutcomeDetail)
+	assert.Equal(t, "AWS Go SDK S3 client", event.Object)
+	assert.Equal(t, "https://github.com/aws/aws-sdk-go", event.Agent)
+	assert.Equal(t, "Storage option changed to Glacier-Deep-OH", event.OutcomeInformation)
+}
+
+func TestNewEventCompression(t *testing.T) {
+	// Test with required params missing or invalid
+	_, err := models.NewEventCompression(time.Time{}, constants.CompressionGzip, 2048, 1024)
+	assert.NotNil(t, err)
+	_, err = models.NewEventCompression(testutil.TEST_TIMESTAMP, "rar", 2048, 1024)
+	assert.NotNil(t, err)
+	_, err = models.NewEventCompression(testutil.TEST_TIMESTAMP, constants.CompressionGzip, -1, 1024)
+	assert.NotNil(t, err)
+	_, err = models.NewEventCompression(testutil.TEST_TIMESTAMP, constants.CompressionGzip, 2048, 0)
+	require.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "Param"))
+
+	event, err := models.NewEventCompression(testutil.TEST_TIMESTAMP, constants.CompressionZstd, 2048, 1024)
+	require.Nil(t, err)
+	assert.Len(t, event.Identifier, 36)
+	assert.Equal(t, "compression", event.EventType)
+	assert.Equal(t, testutil.TEST_TIMESTAMP, event.DateTime)
+	assert.Equal(t, "Compressed file using zstd", event.Detail)
+	assert.Equal(t, "Success", event.Outcome)
+	assert.Equal(t, "zstd:2048 bytes to 1024 bytes", event.OutcomeDetail)
+	assert.Equal(t, "File compressed before storage", event.OutcomeInformation)
+}
+
+func TestNewEventEncryption(t *testing.T) {
+	// Test with required params missing or invalid
+	_, err := models.NewEventEncryption(time.Time{}, constants.EncryptionAES256, "")
+	assert.NotNil(t, err)
+	_, err = models.NewEventEncryption(testutil.TEST_TIMESTAMP, "rot13", "")
+	assert.NotNil(t, err)
+	_, err = models.NewEventEncryption(testutil.TEST_TIMESTAMP, constants.EncryptionKMS, "")
+	require.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "Param"))
+
+	event, err := models.NewEventEncryption(testutil.TEST_TIMESTAMP, constants.EncryptionAES256, "")
+	require.Nil(t, err)
+	assert.Len(t, event.Identifier, 36)
+	assert.Equal(t, "encryption", event.EventType)
+	assert.Equal(t, testutil.TEST_TIMESTAMP, event.DateTime)
+	assert.Equal(t, "Encrypted file in preservation storage", event.Detail)
+	assert.Equal(t, "Success", event.Outcome)
+	assert.Equal(t, "AES256", event.OutcomeDetail)
+
+	event, err = models.NewEventEncryption(testutil.TEST_TIMESTAMP, constants.EncryptionKMS, "alias/aptrust")
+	require.Nil(t, err)
+	assert.Equal(t, "aws:kms:alias/aptrust", event.OutcomeDetail)
+}
+
 func TestNewEventFileDeletion(t *testing.T) {
 	fileUUID := uuid.New().String()
 	utcNow := time.Now().UTC()
@@ -366,6 +471,80 @@ func TestNewEventFileDeletion(t *testing.T) {
 	assert.Equal(t, "user@example.com", event.OutcomeDetail)
 }
 
+func TestNewEventObjectDeletion(t *testing.T) {
+	utcNow := time.Now().UTC()
+	event := models.NewEventObjectDeletion("test.edu/bag", "user@example.com", "admin@example.com", "", utcNow)
+	assert.Len(t, event.Identifier, 36)
+	assert.Equal(t, "deletion", event.EventType)
+	assert.Equal(t, utcNow, event.DateTime)
+	assert.Equal(t, "Object test.edu/bag and all of its files deleted from long-term storage.", event.Detail)
+	assert.Equal(t, "Success", event.Outcome)
+	assert.Equal(t, "Object deleted at the request of user@example.com. Institutional approver: admin@example.com.",
+		event.OutcomeInformation)
+	assert.Equal(t, "APTrust Exchange apt_delete service", event.Object)
+	assert.Equal(t, "https://github.com/APTrust/exchange", event.Agent)
+	assert.Equal(t, "user@example.com", event.OutcomeDetail)
+	assert.Equal(t, "test.edu/bag", event.IntellectualObjectIdentifier)
+	assert.Equal(t, 0, event.GenericFileId)
+	assert.Empty(t, event.GenericFileIdentifier)
+
+	event = models.NewEventObjectDeletion("test.edu/bag", "user@example.com",
+		"admin@example.com", "someone@aptrust.org", utcNow)
+	assert.Equal(t,
+		"Object deleted at the request of user@example.com. Institutional approver: admin@example.com. APTrust approver: someone@aptrust.org.",
+		event.OutcomeInformation)
+}
+
+func TestPremisAgent(t *testing.T) {
+	defer models.SetPremisAgent(models.PremisAgent{})
+	now := time.Now().UTC()
+
+	// Defaults
+	event, err := models.NewEventGenericFileValidation(now, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "APTrust exchange bag validator", event.Object)
+	assert.Equal(t, models.DefaultPremisAgentURI, event.Agent)
+
+	models.SetPremisAgent(models.PremisAgent{
+		Name:    "Test Preservation",
+		URI:     "https://example.com/preservation",
+		Version: "v2.0",
+	})
+	event, err = models.NewEventGenericFileValidation(now, nil)
+	require.Nil(t, err)
+	assert.Equal(t, "Test Preservation bag validator v2.0", event.Object)
+	assert.Equal(t, "https://example.com/preservation", event.Agent)
+
+	event = models.NewEventObjectCreation()
+	assert.Equal(t, "Test Preservation ingest services v2.0", event.Object)
+	assert.Equal(t, "https://example.com/preservation", event.Agent)
+
+	event, err = models.NewEventObjectRights("institution")
+	require.Nil(t, err)
+	assert.Equal(t, "Test Preservation v2.0", event.Object)
+
+	event = models.NewEventFileDeletion("1234", "user@example.com", "", "", now)
+	assert.Equal(t, "Test Preservation apt_delete service v2.0", event.Object)
+	assert.Equal(t, "https://example.com/preservation", event.Agent)
+
+	// Events from third-party libraries still name those libraries.
+	event, err = models.NewEventGenericFileIngest(now, digest, "f6a3c8b2-1c56-4b8e-9b7e-2e4d8f3a5c21")
+	require.Nil(t, err)
+	assert.Equal(t, "Test Preservation v2.0 + AWS Go SDK S3 client", event.Object)
+	assert.Equal(t, "https://github.com/aws/aws-sdk-go", event.Agent)
+
+	event, err = models.NewEventGenericFileFixityCheck(now, constants.AlgMd5, digest, true)
+	require.Nil(t, err)
+	assert.Equal(t, "Go language crypto/md5", event.Object)
+
+	// Version alone keeps the default name.
+	models.SetPremisAgent(models.PremisAgent{Version: "v2.0"})
+	event, err = models.NewEventGenericFileIdentifierAssignment(now, constants.IdTypeBagAndPath, "test.edu/bag/file.txt")
+	require.Nil(t, err)
+	assert.Equal(t, "APTrust exchange/ingest processor v2.0", event.Object)
+	assert.Equal(t, models.DefaultPremisAgentURI, event.Agent)
+}
+
 func TestPremisEventMergeAttributes(t *testing.T) {
 	event1 := testutil.MakePremisEvent()
 	event2 := testutil.MakePremisEvent()
@@ -0,0 +1,223 @@
+package models
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// PremisNamespace is the XML namespace of PREMIS 3.0.
+const PremisNamespace = "http://www.loc.gov/premis/v3"
+
+// PremisSchemaLocation is the URL of the PREMIS 3.0 XML schema.
+const PremisSchemaLocation = "https://www.loc.gov/standards/premis/premis.xsd"
+
+// The identifier types we use in PREMIS XML to say what kind of
+// identifier an identifierValue is.
+const (
+	PremisIdentifierTypeUUID   = "UUID"
+	PremisIdentifierTypeURI    = "URI"
+	PremisIdentifierTypeObject = "APTrust Intellectual Object Identifier"
+	PremisIdentifierTypeFile   = "APTrust Generic File Identifier"
+)
+
+const (
+	premisXsiNamespace          = "http://www.w3.org/2001/XMLSchema-instance"
+	premisAgentTypeSoftware     = "software"
+	premisAgentRoleExecutingApp = "executing program"
+)
+
+// premisDocument is the root element of a PREMIS 3.0 document.
+// The schema requires at least one object, and requires objects,
+// events and agents to appear in that order.
+type premisDocument struct {
+	XMLName        xml.Name          `xml:"premis"`
+	Xmlns          string            `xml:"xmlns,attr"`
+	XmlnsXsi       string            `xml:"xmlns:xsi,attr"`
+	SchemaLocation string            `xml:"xsi:schemaLocation,attr"`
+	Version        string            `xml:"version,attr"`
+	Objects        []*premisObject   `xml:"object"`
+	Events         []*premisEventXML `xml:"event"`
+	Agents         []*premisAgent    `xml:"agent"`
+}
+
+// premisObject is an intellectual entity. We describe only the
+// IntellectualObject itself, since file objects require format and
+// fixity details that belong in a full PREMIS object description.
+type premisObject struct {
+	XsiType    string            `xml:"xsi:type,attr"`
+	Identifier *premisIdentifier `xml:"objectIdentifier"`
+}
+
+// premisIdentifier holds the type and value of an identifier. PREMIS
+// uses a different element name for each kind of identifier, so the
+// element names come from the containing struct's tags.
+type premisIdentifier struct {
+	Type  string
+	Value string
+}
+
+// MarshalXML writes the identifier's type and value elements with
+// names derived from the enclosing element. E.g. an objectIdentifier
+// has objectIdentifierType and objectIdentifierValue.
+func (id *premisIdentifier) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	prefix := start.Name.Local
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(id.Type, xml.StartElement{Name: xml.Name{Local: prefix + "Type"}}); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(id.Value, xml.StartElement{Name: xml.Name{Local: prefix + "Value"}}); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// premisEventXML is a PREMIS 3.0 event. Elements are in the order
+// the schema requires.
+type premisEventXML struct {
+	XMLName        xml.Name              `xml:"event"`
+	Xmlns          string                `xml:"xmlns,attr,omitempty"`
+	Identifier     *premisIdentifier     `xml:"eventIdentifier"`
+	EventType      string                `xml:"eventType"`
+	DateTime       string                `xml:"eventDateTime"`
+	Detail         *premisEventDetail    `xml:"eventDetailInformation,omitempty"`
+	Outcome        *premisOutcome        `xml:"eventOutcomeInformation"`
+	LinkingAgents  []*premisLinkingAgent `xml:"linkingAgentIdentifier"`
+	LinkingObjects []*premisIdentifier   `xml:"linkingObjectIdentifier"`
+}
+
+type premisEventDetail struct {
+	Detail string `xml:"eventDetail"`
+}
+
+type premisOutcome struct {
+	Outcome string                 `xml:"eventOutcome"`
+	Details []*premisOutcomeDetail `xml:"eventOutcomeDetail"`
+}
+
+type premisOutcomeDetail struct {
+	Note string `xml:"eventOutcomeDetailNote"`
+}
+
+type premisLinkingAgent struct {
+	Type  string `xml:"linkingAgentIdentifierType"`
+	Value string `xml:"linkingAgentIdentifierValue"`
+	Role  string `xml:"linkingAgentRole"`
+}
+
+// premisAgent describes the software that carried out an event.
+type premisAgent struct {
+	Identifier *premisIdentifier `xml:"agentIdentifier"`
+	Name       string            `xml:"agentName,omitempty"`
+	AgentType  string            `xml:"agentType"`
+}
+
+// toPremisXML converts the event to its PREMIS 3.0 representation.
+func (event *PremisEvent) toPremisXML() *premisEventXML {
+	xmlEvent := &premisEventXML{
+		Identifier: &premisIdentifier{
+			Type:  PremisIdentifierTypeUUID,
+			Value: event.Identifier,
+		},
+		EventType: event.EventType,
+		DateTime:  event.DateTime.UTC().Format(time.RFC3339),
+		Outcome: &premisOutcome{
+			Outcome: event.Outcome,
+			Details: make([]*premisOutcomeDetail, 0),
+		},
+		LinkingAgents:  make([]*premisLinkingAgent, 0),
+		LinkingObjects: make([]*premisIdentifier, 0),
+	}
+	if event.Detail != "" {
+		xmlEvent.Detail = &premisEventDetail{Detail: event.Detail}
+	}
+	for _, note := range []string{event.OutcomeDetail, event.OutcomeInformation} {
+		if note != "" {
+			xmlEvent.Outcome.Details = append(xmlEvent.Outcome.Details,
+				&premisOutcomeDetail{Note: note})
+		}
+	}
+	if event.Agent != "" {
+		xmlEvent.LinkingAgents = append(xmlEvent.LinkingAgents, &premisLinkingAgent{
+			Type:  PremisIdentifierTypeURI,
+			Value: event.Agent,
+			Role:  premisAgentRoleExecutingApp,
+		})
+	}
+	if event.IntellectualObjectIdentifier != "" {
+		xmlEvent.LinkingObjects = append(xmlEvent.LinkingObjects, &premisIdentifier{
+			Type:  PremisIdentifierTypeObject,
+			Value: event.IntellectualObjectIdentifier,
+		})
+	}
+	if event.GenericFileIdentifier != "" {
+		xmlEvent.LinkingObjects = append(xmlEvent.LinkingObjects, &premisIdentifier{
+			Type:  PremisIdentifierTypeFile,
+			Value: event.GenericFileIdentifier,
+		})
+	}
+	return xmlEvent
+}
+
+// SerializeToPremisXML returns the event as a PREMIS 3.0 event element
+// in the PREMIS namespace. The event links to its agent by URI and to
+// its object and file by identifier. Use
+// IntellectualObject.SerializeToPremisXML for a complete PREMIS document.
+func (event *PremisEvent) SerializeToPremisXML() ([]byte, error) {
+	xmlEvent := event.toPremisXML()
+	xmlEvent.Xmlns = PremisNamespace
+	return xml.MarshalIndent(xmlEvent, "", "  ")
+}
+
+// SerializeToPremisXML returns a PREMIS 3.0 document describing the
+// object as an intellectual entity, along with all of its events and
+// all of its files' events, and the software agents that carried
+// out those events.
+func (obj *IntellectualObject) SerializeToPremisXML() ([]byte, error) {
+	doc := &premisDocument{
+		Xmlns:          PremisNamespace,
+		XmlnsXsi:       premisXsiNamespace,
+		SchemaLocation: PremisNamespace + " " + PremisSchemaLocation,
+		Version:        "3.0",
+		Objects: []*premisObject{
+			{
+				XsiType: "intellectualEntity",
+				Identifier: &premisIdentifier{
+					Type:  PremisIdentifierTypeObject,
+					Value: obj.Identifier,
+				},
+			},
+		},
+		Events: make([]*premisEventXML, 0),
+		Agents: make([]*premisAgent, 0),
+	}
+	events := make([]*PremisEvent, 0)
+	events = append(events, obj.PremisEvents...)
+	for _, gf := range obj.GenericFiles {
+		events = append(events, gf.PremisEvents...)
+	}
+	agents := make(map[string]bool)
+	for _, event := range events {
+		if event == nil {
+			continue
+		}
+		doc.Events = append(doc.Events, event.toPremisXML())
+		if event.Agent != "" && !agents[event.Agent] {
+			agents[event.Agent] = true
+			doc.Agents = append(doc.Agents, &premisAgent{
+				Identifier: &premisIdentifier{
+					Type:  PremisIdentifierTypeURI,
+					Value: event.Agent,
+				},
+				Name:      event.Object,
+				AgentType: premisAgentTypeSoftware,
+			})
+		}
+	}
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
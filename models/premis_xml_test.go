@@ -0,0 +1,113 @@
+package models_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPremisEventSerializeToPremisXML(t *testing.T) {
+	verifiedAt := time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC)
+	event, err := models.NewEventGenericFileFixityCheck(verifiedAt, constants.AlgMd5, digest, true)
+	require.Nil(t, err)
+	event.IntellectualObjectIdentifier = "test.edu/bag"
+	event.GenericFileIdentifier = "test.edu/bag/data/file.txt"
+
+	data, err := event.SerializeToPremisXML()
+	require.Nil(t, err)
+	xmlString := string(data)
+
+	assert.True(t, strings.HasPrefix(xmlString, `<event xmlns="http://www.loc.gov/premis/v3">`))
+	expected := []string{
+		"<eventIdentifierType>UUID</eventIdentifierType>",
+		"<eventIdentifierValue>" + event.Identifier + "</eventIdentifierValue>",
+		"<eventType>fixity check</eventType>",
+		"<eventDateTime>2019-03-04T05:06:07Z</eventDateTime>",
+		"<eventDetail>Fixity check against registered hash</eventDetail>",
+		"<eventOutcome>Success</eventOutcome>",
+		"<eventOutcomeDetailNote>md5:" + digest + "</eventOutcomeDetailNote>",
+		"<eventOutcomeDetailNote>Fixity matches</eventOutcomeDetailNote>",
+		"<linkingAgentIdentifierType>URI</linkingAgentIdentifierType>",
+		"<linkingAgentIdentifierValue>http://golang.org/pkg/crypto/md5/</linkingAgentIdentifierValue>",
+		"<linkingAgentRole>executing program</linkingAgentRole>",
+		"<linkingObjectIdentifierType>APTrust Intellectual Object Identifier</linkingObjectIdentifierType>",
+		"<linkingObjectIdentifierValue>test.edu/bag</linkingObjectIdentifierValue>",
+		"<linkingObjectIdentifierType>APTrust Generic File Identifier</linkingObjectIdentifierType>",
+		"<linkingObjectIdentifierValue>test.edu/bag/data/file.txt</linkingObjectIdentifierValue>",
+	}
+	for _, str := range expected {
+		assert.Contains(t, xmlString, str)
+	}
+
+	// The schema requires elements in this order.
+	order := []string{"<eventIdentifier>", "<eventType>", "<eventDateTime>",
+		"<eventDetailInformation>", "<eventOutcomeInformation>",
+		"<linkingAgentIdentifier>", "<linkingObjectIdentifier>"}
+	for i := 1; i < len(order); i++ {
+		assert.True(t, strings.Index(xmlString, order[i-1]) < strings.Index(xmlString, order[i]),
+			"%s should come before %s", order[i-1], order[i])
+	}
+}
+
+func TestIntellectualObjectSerializeToPremisXML(t *testing.T) {
+	obj := models.NewIntellectualObject()
+	obj.Identifier = "test.edu/bag"
+	objEvent := models.NewEventObjectCreation()
+	objEvent.IntellectualObjectIdentifier = obj.Identifier
+	obj.PremisEvents = append(obj.PremisEvents, objEvent)
+	for _, filePath := range []string{"data/one.txt", "data/two.txt"} {
+		gf := models.NewGenericFile()
+		gf.Identifier = obj.Identifier + "/" + filePath
+		event, err := models.NewEventGenericFileDigestCalculation(
+			time.Now().UTC(), constants.AlgMd5, digest)
+		require.Nil(t, err)
+		event.IntellectualObjectIdentifier = obj.Identifier
+		event.GenericFileIdentifier = gf.Identifier
+		gf.PremisEvents = append(gf.PremisEvents, event)
+		obj.GenericFiles = append(obj.GenericFiles, gf)
+	}
+
+	data, err := obj.SerializeToPremisXML()
+	require.Nil(t, err)
+	xmlString := string(data)
+	assert.True(t, strings.HasPrefix(xmlString, xml.Header))
+	assert.Contains(t, xmlString, `<premis xmlns="http://www.loc.gov/premis/v3" `+
+		`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" `+
+		`xsi:schemaLocation="http://www.loc.gov/premis/v3 https://www.loc.gov/standards/premis/premis.xsd" `+
+		`version="3.0">`)
+	assert.Contains(t, xmlString, `<object xsi:type="intellectualEntity">`)
+	assert.Contains(t, xmlString, "<objectIdentifierValue>test.edu/bag</objectIdentifierValue>")
+
+	// Parse it back to count events and agents. Two files share
+	// the same agent, so we should have only two agents.
+	parsed := struct {
+		Objects []struct{} `xml:"object"`
+		Events  []struct {
+			Type string `xml:"eventType"`
+		} `xml:"event"`
+		Agents []struct {
+			Value string `xml:"agentIdentifier>agentIdentifierValue"`
+			Name  string `xml:"agentName"`
+			Type  string `xml:"agentType"`
+		} `xml:"agent"`
+	}{}
+	require.Nil(t, xml.Unmarshal(data, &parsed))
+	assert.Equal(t, 1, len(parsed.Objects))
+	require.Equal(t, 3, len(parsed.Events))
+	assert.Equal(t, constants.EventCreation, parsed.Events[0].Type)
+	assert.Equal(t, constants.EventDigestCalculation, parsed.Events[1].Type)
+	require.Equal(t, 2, len(parsed.Agents))
+	assert.Equal(t, "https://github.com/APTrust/exchange", parsed.Agents[0].Value)
+	assert.Equal(t, "APTrust Exchange ingest services", parsed.Agents[0].Name)
+	assert.Equal(t, "software", parsed.Agents[0].Type)
+
+	// Objects, events and agents must come in that order.
+	assert.True(t, strings.Index(xmlString, "<object ") < strings.Index(xmlString, "<event>"))
+	assert.True(t, strings.LastIndex(xmlString, "<event>") < strings.Index(xmlString, "<agent>"))
+}
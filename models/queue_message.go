@@ -0,0 +1,144 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/nsqio/go-nsq"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueueMessageVersion is the current version of the QueueMessage format.
+// Bump this when making incompatible changes, so workers can reject
+// messages they don't know how to read.
+const QueueMessageVersion = 1
+
+// QueueMessage is the body of an NSQ message. It tells a worker which
+// WorkItem (or, for fixity checks, which GenericFile) to work on, and
+// carries enough metadata to route the message and to measure how long
+// it took to get through the system.
+//
+// Before we introduced QueueMessage, the body of an NSQ message was a
+// bare WorkItem.Id, or a GenericFile.Identifier for fixity checks.
+// ParseQueueMessage still understands those, so workers can process
+// messages queued by older code.
+type QueueMessage struct {
+	// Version is the version of the message format. Legacy messages
+	// have version zero.
+	Version int `json:"version"`
+
+	// WorkItemId is the id of the WorkItem to process.
+	WorkItemId int `json:"work_item_id,omitempty"`
+
+	// GenericFileIdentifier is the identifier of the file to check.
+	// This is used in fixity check messages, which have no WorkItem.
+	GenericFileIdentifier string `json:"generic_file_identifier,omitempty"`
+
+	// Action is the WorkItem action, such as "Ingest" or "Restore".
+	Action string `json:"action,omitempty"`
+
+	// Attempt is the number of times NSQ has delivered this message,
+	// starting at one. ParseNSQMessage sets this from the NSQ
+	// message's Attempts, which counts requeues and timeouts.
+	Attempt int `json:"attempt,omitempty"`
+
+	// EnqueuedAt is when the message was queued.
+	EnqueuedAt time.Time `json:"enqueued_at"`
+
+	// TraceId is a unique id that identifies this message in the logs
+	// of every worker that touches it.
+	TraceId string `json:"trace_id,omitempty"`
+}
+
+// NewQueueMessage returns a new QueueMessage for the specified WorkItem.
+// Param action may be empty if the caller doesn't know it.
+func NewQueueMessage(workItemId int, action string) *QueueMessage {
+	return &QueueMessage{
+		Version:    QueueMessageVersion,
+		WorkItemId: workItemId,
+		Action:     action,
+		Attempt:    1,
+		EnqueuedAt: time.Now().UTC(),
+		TraceId:    uuid.New().String(),
+	}
+}
+
+// NewFixityQueueMessage returns a new QueueMessage requesting a fixity
+// check on the GenericFile with the specified identifier.
+func NewFixityQueueMessage(gfIdentifier string) *QueueMessage {
+	return &QueueMessage{
+		Version:               QueueMessageVersion,
+		GenericFileIdentifier: gfIdentifier,
+		Attempt:               1,
+		EnqueuedAt:            time.Now().UTC(),
+		TraceId:               uuid.New().String(),
+	}
+}
+
+// ParseQueueMessage parses the body of an NSQ message. The body may be
+// a JSON QueueMessage, or a legacy body containing only a WorkItem.Id
+// or a GenericFile.Identifier. This returns an error if the body is
+// empty, or if it's a QueueMessage in a version newer than this code
+// understands.
+func ParseQueueMessage(body []byte) (*QueueMessage, error) {
+	msgBody := strings.TrimSpace(string(body))
+	if msgBody == "" {
+		return nil, fmt.Errorf("NSQ message body is empty")
+	}
+	if !strings.HasPrefix(msgBody, "{") {
+		queueMessage := &QueueMessage{}
+		if workItemId, err := strconv.Atoi(msgBody); err == nil {
+			queueMessage.WorkItemId = workItemId
+		} else {
+			queueMessage.GenericFileIdentifier = msgBody
+		}
+		return queueMessage, nil
+	}
+	queueMessage := &QueueMessage{}
+	err := json.Unmarshal([]byte(msgBody), queueMessage)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse NSQ message body: %v", err)
+	}
+	if queueMessage.Version < 1 || queueMessage.Version > QueueMessageVersion {
+		return nil, fmt.Errorf("NSQ message has unsupported version %d", queueMessage.Version)
+	}
+	return queueMessage, nil
+}
+
+// ParseNSQMessage parses the body of message, like ParseQueueMessage,
+// and sets Attempt to the number of times NSQ has delivered it.
+func ParseNSQMessage(message *nsq.Message) (*QueueMessage, error) {
+	queueMessage, err := ParseQueueMessage(message.Body)
+	if err != nil {
+		return nil, err
+	}
+	queueMessage.Attempt = int(message.Attempts)
+	return queueMessage, nil
+}
+
+// IsLegacy returns true if this message came from a legacy message
+// body, which has no metadata.
+func (queueMessage *QueueMessage) IsLegacy() bool {
+	return queueMessage.Version == 0
+}
+
+// TimeInQueue returns the time elapsed since the message was queued,
+// or zero for legacy messages, which don't say when they were queued.
+func (queueMessage *QueueMessage) TimeInQueue() time.Duration {
+	if queueMessage.EnqueuedAt.IsZero() {
+		return 0
+	}
+	return time.Since(queueMessage.EnqueuedAt)
+}
+
+// ToJson returns the message as JSON, suitable for use as
+// the body of an NSQ message.
+func (queueMessage *QueueMessage) ToJson() (string, error) {
+	data, err := json.Marshal(queueMessage)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
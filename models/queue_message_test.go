@@ -0,0 +1,101 @@
+package models_test
+
+import (
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/nsqio/go-nsq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNewQueueMessage(t *testing.T) {
+	msg := models.NewQueueMessage(1234, constants.ActionIngest)
+	assert.Equal(t, models.QueueMessageVersion, msg.Version)
+	assert.Equal(t, 1234, msg.WorkItemId)
+	assert.Equal(t, constants.ActionIngest, msg.Action)
+	assert.Equal(t, 1, msg.Attempt)
+	assert.False(t, msg.EnqueuedAt.IsZero())
+	assert.NotEmpty(t, msg.TraceId)
+	assert.False(t, msg.IsLegacy())
+
+	other := models.NewQueueMessage(1234, constants.ActionIngest)
+	assert.NotEqual(t, msg.TraceId, other.TraceId)
+}
+
+func TestNewFixityQueueMessage(t *testing.T) {
+	msg := models.NewFixityQueueMessage("test.edu/bag/data/file.txt")
+	assert.Equal(t, models.QueueMessageVersion, msg.Version)
+	assert.Equal(t, 0, msg.WorkItemId)
+	assert.Equal(t, "test.edu/bag/data/file.txt", msg.GenericFileIdentifier)
+	assert.NotEmpty(t, msg.TraceId)
+}
+
+func TestParseQueueMessage(t *testing.T) {
+	msg := models.NewQueueMessage(1234, constants.ActionRestore)
+	msg.EnqueuedAt = time.Now().UTC().Add(-5 * time.Minute)
+	jsonString, err := msg.ToJson()
+	require.Nil(t, err)
+
+	parsed, err := models.ParseQueueMessage([]byte(jsonString + "\n"))
+	require.Nil(t, err)
+	assert.Equal(t, msg.WorkItemId, parsed.WorkItemId)
+	assert.Equal(t, msg.Action, parsed.Action)
+	assert.Equal(t, msg.TraceId, parsed.TraceId)
+	assert.True(t, msg.EnqueuedAt.Equal(parsed.EnqueuedAt))
+	assert.True(t, parsed.TimeInQueue() >= 5*time.Minute)
+}
+
+func TestParseNSQMessage(t *testing.T) {
+	msg := models.NewQueueMessage(1234, constants.ActionIngest)
+	jsonString, err := msg.ToJson()
+	require.Nil(t, err)
+
+	// Attempt comes from NSQ, not from the message body.
+	message := nsq.NewMessage(nsq.MessageID{'1'}, []byte(jsonString))
+	message.Attempts = 3
+	parsed, err := models.ParseNSQMessage(message)
+	require.Nil(t, err)
+	assert.Equal(t, 1234, parsed.WorkItemId)
+	assert.Equal(t, 3, parsed.Attempt)
+
+	message = nsq.NewMessage(nsq.MessageID{'2'}, []byte("5678"))
+	message.Attempts = 2
+	parsed, err = models.ParseNSQMessage(message)
+	require.Nil(t, err)
+	assert.True(t, parsed.IsLegacy())
+	assert.Equal(t, 2, parsed.Attempt)
+
+	_, err = models.ParseNSQMessage(nsq.NewMessage(nsq.MessageID{'3'}, []byte("")))
+	assert.NotNil(t, err)
+}
+
+func TestParseQueueMessage_Legacy(t *testing.T) {
+	parsed, err := models.ParseQueueMessage([]byte(" 5678\n"))
+	require.Nil(t, err)
+	assert.True(t, parsed.IsLegacy())
+	assert.Equal(t, 5678, parsed.WorkItemId)
+	assert.Equal(t, time.Duration(0), parsed.TimeInQueue())
+
+	parsed, err = models.ParseQueueMessage([]byte("georgetown.edu/georgetown.edu.10822_707412"))
+	require.Nil(t, err)
+	assert.True(t, parsed.IsLegacy())
+	assert.Equal(t, 0, parsed.WorkItemId)
+	assert.Equal(t, "georgetown.edu/georgetown.edu.10822_707412", parsed.GenericFileIdentifier)
+}
+
+func TestParseQueueMessage_Errors(t *testing.T) {
+	_, err := models.ParseQueueMessage([]byte("  "))
+	assert.NotNil(t, err)
+
+	_, err = models.ParseQueueMessage([]byte("{ not json"))
+	assert.NotNil(t, err)
+
+	_, err = models.ParseQueueMessage([]byte(`{"version":99,"work_item_id":12}`))
+	require.NotNil(t, err)
+	assert.Equal(t, "NSQ message has unsupported version 99", err.Error())
+
+	_, err = models.ParseQueueMessage([]byte(`{"work_item_id":12}`))
+	assert.NotNil(t, err)
+}
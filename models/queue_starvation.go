@@ -0,0 +1,101 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// PendingQueue describes the Pending WorkItems for one action at one
+// institution, such as all of virginia.edu's pending restores, and
+// the oldest of those items.
+type PendingQueue struct {
+	Action       string `json:"action"`
+	Institution  string `json:"institution"`
+	PendingCount int    `json:"pending_count"`
+	// OldestWorkItemId, OldestName and OldestPendingSince describe the
+	// item that has been pending longest. PendingSince is the item's
+	// Date, which workers update each time they change its status.
+	OldestWorkItemId   int       `json:"oldest_work_item_id"`
+	OldestName         string    `json:"oldest_name"`
+	OldestPendingSince time.Time `json:"oldest_pending_since"`
+	// Age and Threshold are set only on starved queues. Both are
+	// durations, such as "72h0m0s".
+	Age       string `json:"age,omitempty"`
+	Threshold string `json:"threshold,omitempty"`
+}
+
+// StarvationReport collects the Pending WorkItems that
+// apt_starvation_monitor finds in Pharos, and reports which queues
+// have gone too long without progress. See Config.StarvationThresholds.
+type StarvationReport struct {
+	Host      string    `json:"host"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Starved lists the queues whose oldest item has been pending
+	// longer than the threshold for its action.
+	Starved []*PendingQueue `json:"starved"`
+	queues  map[string]*PendingQueue
+}
+
+// NewStarvationReport returns a new, empty StarvationReport. Ages are
+// measured from checkedAt.
+func NewStarvationReport(host string, checkedAt time.Time) *StarvationReport {
+	return &StarvationReport{
+		Host:      host,
+		CheckedAt: checkedAt,
+		Starved:   make([]*PendingQueue, 0),
+		queues:    make(map[string]*PendingQueue),
+	}
+}
+
+// Track adds item, which belongs to the specified institution, to the
+// queue for its action and institution.
+func (report *StarvationReport) Track(institution string, item *WorkItem) {
+	key := item.Action + "/" + institution
+	queue := report.queues[key]
+	if queue == nil {
+		queue = &PendingQueue{
+			Action:      item.Action,
+			Institution: institution,
+		}
+		report.queues[key] = queue
+	}
+	queue.PendingCount++
+	if queue.OldestWorkItemId == 0 || item.Date.Before(queue.OldestPendingSince) {
+		queue.OldestWorkItemId = item.Id
+		queue.OldestName = item.Name
+		queue.OldestPendingSince = item.Date
+	}
+}
+
+// Queues returns all of the queues tracked so far, sorted by action
+// and then by institution.
+func (report *StarvationReport) Queues() []*PendingQueue {
+	queues := make([]*PendingQueue, 0, len(report.queues))
+	for _, queue := range report.queues {
+		queues = append(queues, queue)
+	}
+	sort.Slice(queues, func(i, j int) bool {
+		if queues[i].Action != queues[j].Action {
+			return queues[i].Action < queues[j].Action
+		}
+		return queues[i].Institution < queues[j].Institution
+	})
+	return queues
+}
+
+// FindStarved sets report.Starved to the queues whose oldest item is
+// older than thresholdFor returns for the queue's action, and returns
+// that list. A threshold of zero means the action is not monitored.
+func (report *StarvationReport) FindStarved(thresholdFor func(action string) time.Duration) []*PendingQueue {
+	report.Starved = make([]*PendingQueue, 0)
+	for _, queue := range report.Queues() {
+		threshold := thresholdFor(queue.Action)
+		age := report.CheckedAt.Sub(queue.OldestPendingSince)
+		if threshold > 0 && age > threshold {
+			queue.Age = age.Round(time.Minute).String()
+			queue.Threshold = threshold.String()
+			report.Starved = append(report.Starved, queue)
+		}
+	}
+	return report.Starved
+}
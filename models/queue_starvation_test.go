@@ -0,0 +1,54 @@
+package models_test
+
+import (
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStarvationReport(t *testing.T) {
+	now := time.Date(2018, 3, 10, 12, 0, 0, 0, time.UTC)
+	report := models.NewStarvationReport("host1", now)
+	items := []*models.WorkItem{
+		{Id: 1, Name: "bag1.tar", Action: constants.ActionIngest, Date: now.Add(-2 * time.Hour)},
+		{Id: 2, Name: "bag2.tar", Action: constants.ActionIngest, Date: now.Add(-80 * time.Hour)},
+		{Id: 3, Name: "bag3.tar", Action: constants.ActionIngest, Date: now.Add(-1 * time.Hour)},
+		{Id: 4, Name: "bag4.tar", Action: constants.ActionRestore, Date: now.Add(-5 * time.Hour)},
+	}
+	report.Track("test.edu", items[0])
+	report.Track("test.edu", items[1])
+	report.Track("example.edu", items[2])
+	report.Track("test.edu", items[3])
+
+	queues := report.Queues()
+	require.Equal(t, 3, len(queues))
+	assert.Equal(t, "example.edu", queues[0].Institution)
+	assert.Equal(t, constants.ActionIngest, queues[1].Action)
+	assert.Equal(t, "test.edu", queues[1].Institution)
+	assert.Equal(t, 2, queues[1].PendingCount)
+	assert.Equal(t, 2, queues[1].OldestWorkItemId)
+	assert.Equal(t, "bag2.tar", queues[1].OldestName)
+	assert.Equal(t, constants.ActionRestore, queues[2].Action)
+
+	thresholds := map[string]time.Duration{
+		constants.ActionIngest:  72 * time.Hour,
+		constants.ActionRestore: 4 * time.Hour,
+	}
+	starved := report.FindStarved(func(action string) time.Duration {
+		return thresholds[action]
+	})
+	require.Equal(t, 2, len(starved))
+	assert.Equal(t, 2, starved[0].OldestWorkItemId)
+	assert.Equal(t, "80h0m0s", starved[0].Age)
+	assert.Equal(t, "72h0m0s", starved[0].Threshold)
+	assert.Equal(t, 4, starved[1].OldestWorkItemId)
+	assert.Equal(t, starved, report.Starved)
+	assert.Empty(t, queues[0].Age)
+
+	// Zero threshold means we don't monitor the action.
+	starved = report.FindStarved(func(action string) time.Duration { return 0 })
+	assert.Empty(t, starved)
+}
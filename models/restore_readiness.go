@@ -0,0 +1,108 @@
+package models
+
+import (
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/util"
+	"time"
+)
+
+// Values for RestoreReadiness.Status.
+const (
+	// RestoreReady means all of the object's files can be restored
+	// right away, because they're in S3.
+	RestoreReady = "Ready"
+	// RestorePartiallyRestored means some of the object's files have
+	// been retrieved from Glacier, or are being retrieved, but not
+	// all of them are in S3 yet.
+	RestorePartiallyRestored = "PartiallyRestored"
+	// RestoreNeedsGlacierRetrieval means none of the object's Glacier
+	// files are in S3 or on their way there.
+	RestoreNeedsGlacierRetrieval = "NeedsGlacierRetrieval"
+)
+
+// Standard Glacier retrievals take 3-5 hours, and Glacier Deep Archive
+// retrievals take up to 12. We use the upper bounds for estimates.
+const (
+	GlacierRetrievalTime     = 5 * time.Hour
+	GlacierDeepRetrievalTime = 12 * time.Hour
+)
+
+// RestoreReadiness describes how soon we could restore an object,
+// so depositors know what to expect before they request a restore.
+type RestoreReadiness struct {
+	ObjectIdentifier string `json:"object_identifier"`
+	// Status is one of the Restore* constants.
+	Status string `json:"status"`
+	// FileCount is the number of active files in the object.
+	FileCount int `json:"file_count"`
+	// ReadyCount is the number of files in S3, including files
+	// in standard storage and files retrieved from Glacier.
+	ReadyCount int `json:"ready_count"`
+	// InProgressCount is the number of files on their way from
+	// Glacier to S3.
+	InProgressCount int `json:"in_progress_count"`
+	// NeedsRetrievalCount is the number of Glacier files that
+	// no one has asked to retrieve yet.
+	NeedsRetrievalCount int `json:"needs_retrieval_count"`
+	// EstimatedReadyAt is when we expect all of the files to be in
+	// S3, if a restore were requested at CheckedAt. It's the zero
+	// time if the object is ready now. We can't tell when an earlier
+	// retrieval started, so files in progress get a full retrieval
+	// time, which makes this a conservative estimate.
+	EstimatedReadyAt time.Time `json:"estimated_ready_at"`
+	CheckedAt        time.Time `json:"checked_at"`
+}
+
+// NewRestoreReadiness returns a RestoreReadiness for the object with
+// the specified identifier, checked at checkedAt, with no files.
+func NewRestoreReadiness(objIdentifier string, checkedAt time.Time) *RestoreReadiness {
+	return &RestoreReadiness{
+		ObjectIdentifier: objIdentifier,
+		Status:           RestoreReady,
+		CheckedAt:        checkedAt.UTC(),
+	}
+}
+
+// AddFile adds a file to the tally. Param storageOption is the file's
+// storage option. Params inS3 and inProgress describe the state of
+// any Glacier retrieval, and are ignored for files in standard storage.
+func (readiness *RestoreReadiness) AddFile(storageOption string, inS3, inProgress bool) {
+	readiness.FileCount += 1
+	if storageOption == constants.StorageStandard || inS3 {
+		readiness.ReadyCount += 1
+	} else {
+		if inProgress {
+			readiness.InProgressCount += 1
+		} else {
+			readiness.NeedsRetrievalCount += 1
+		}
+		readyAt := readiness.CheckedAt.Add(GlacierRetrievalTimeFor(storageOption))
+		if readyAt.After(readiness.EstimatedReadyAt) {
+			readiness.EstimatedReadyAt = readyAt
+		}
+	}
+	readiness.setStatus()
+}
+
+// setStatus sets Status to match the file counts.
+func (readiness *RestoreReadiness) setStatus() {
+	if readiness.ReadyCount == readiness.FileCount {
+		readiness.Status = RestoreReady
+	} else if readiness.ReadyCount > 0 || readiness.InProgressCount > 0 {
+		readiness.Status = RestorePartiallyRestored
+	} else {
+		readiness.Status = RestoreNeedsGlacierRetrieval
+	}
+}
+
+// GlacierRetrievalTimeFor returns the longest we expect it to take to
+// retrieve a file with the specified storage option from Glacier.
+func GlacierRetrievalTimeFor(storageOption string) time.Duration {
+	if util.StringListContains(constants.GlacierDeepOptions, storageOption) {
+		return GlacierDeepRetrievalTime
+	}
+	if util.StringListContains(constants.GlacierStandardOptions, storageOption) {
+		return GlacierRetrievalTime
+	}
+	return 0
+}
@@ -0,0 +1,47 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestoreReadiness(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	readiness := models.NewRestoreReadiness("test.edu/bag", now)
+	readiness.AddFile(constants.StorageStandard, false, false)
+	readiness.AddFile(constants.StorageGlacierOH, true, false)
+	assert.Equal(t, models.RestoreReady, readiness.Status)
+	assert.Equal(t, 2, readiness.ReadyCount)
+	assert.True(t, readiness.EstimatedReadyAt.IsZero())
+
+	readiness = models.NewRestoreReadiness("test.edu/bag", now)
+	readiness.AddFile(constants.StorageGlacierOH, false, false)
+	readiness.AddFile(constants.StorageGlacierOH, false, false)
+	assert.Equal(t, models.RestoreNeedsGlacierRetrieval, readiness.Status)
+	assert.Equal(t, 2, readiness.NeedsRetrievalCount)
+	assert.Equal(t, now.Add(models.GlacierRetrievalTime), readiness.EstimatedReadyAt)
+
+	readiness.AddFile(constants.StorageGlacierDeepOR, false, true)
+	assert.Equal(t, models.RestorePartiallyRestored, readiness.Status)
+	assert.Equal(t, 1, readiness.InProgressCount)
+	assert.Equal(t, now.Add(models.GlacierDeepRetrievalTime), readiness.EstimatedReadyAt)
+
+	readiness = models.NewRestoreReadiness("test.edu/bag", now)
+	readiness.AddFile(constants.StorageGlacierVA, true, false)
+	readiness.AddFile(constants.StorageGlacierVA, false, false)
+	assert.Equal(t, models.RestorePartiallyRestored, readiness.Status)
+	assert.Equal(t, 2, readiness.FileCount)
+	assert.Equal(t, 1, readiness.ReadyCount)
+	assert.Equal(t, 1, readiness.NeedsRetrievalCount)
+}
+
+func TestGlacierRetrievalTimeFor(t *testing.T) {
+	assert.Equal(t, time.Duration(0), models.GlacierRetrievalTimeFor(constants.StorageStandard))
+	assert.Equal(t, models.GlacierRetrievalTime, models.GlacierRetrievalTimeFor(constants.StorageGlacierOR))
+	assert.Equal(t, models.GlacierDeepRetrievalTime, models.GlacierRetrievalTimeFor(constants.StorageGlacierDeepVA))
+}
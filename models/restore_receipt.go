@@ -0,0 +1,70 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// RestoreReceiptSuffix is added to the S3 key of a restored bag to
+// get the key of its receipt. E.g. "bag.tar" -> "bag.tar.receipt.json".
+const RestoreReceiptSuffix = ".receipt.json"
+
+// RestoreReceipt describes exactly what apt_restorer delivered to a
+// depositor's restoration bucket. apt_restorer saves it with the
+// RestoreState in Pharos and next to the bag in the restoration
+// bucket. If the restorer has a signing key, it signs the receipt,
+// so APTrust can later show that the receipt has not been altered.
+type RestoreReceipt struct {
+	ObjectIdentifier string    `json:"object_identifier"`
+	WorkItemId       int       `json:"work_item_id"`
+	Bucket           string    `json:"bucket"`
+	Key              string    `json:"key"`
+	Destination      string    `json:"destination"`
+	Size             int64     `json:"size"`
+	Md5              string    `json:"md5"`
+	Sha256           string    `json:"sha256"`
+	FileCount        int       `json:"file_count"`
+	DeliveredAt      time.Time `json:"delivered_at"`
+	Signature        string    `json:"signature,omitempty"`
+}
+
+// Sign sets the receipt's Signature to the hex-encoded HMAC-SHA256
+// of its other fields, using key.
+func (receipt *RestoreReceipt) Sign(key []byte) error {
+	signature, err := receipt.signature(key)
+	if err != nil {
+		return err
+	}
+	receipt.Signature = signature
+	return nil
+}
+
+// HasValidSignature returns true if the receipt is signed, and its
+// Signature matches its other fields, using key.
+func (receipt *RestoreReceipt) HasValidSignature(key []byte) bool {
+	if receipt.Signature == "" {
+		return false
+	}
+	expected, err := receipt.signature(key)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(expected), []byte(receipt.Signature))
+}
+
+// signature returns the signature of everything in the receipt but
+// the Signature itself.
+func (receipt *RestoreReceipt) signature(key []byte) (string, error) {
+	unsigned := *receipt
+	unsigned.Signature = ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,46 @@
+package models_test
+
+import (
+	"encoding/json"
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func makeRestoreReceipt() *models.RestoreReceipt {
+	return &models.RestoreReceipt{
+		ObjectIdentifier: "test.edu/bag",
+		WorkItemId:       1234,
+		Bucket:           "aptrust.restore.test.edu",
+		Key:              "bag.tar",
+		Destination:      "https://s3.amazonaws.com/aptrust.restore.test.edu/bag.tar",
+		Size:             8192,
+		Md5:              "12345678901234567890123456789012",
+		Sha256:           "1234567890123456789012345678901234567890123456789012345678901234",
+		FileCount:        12,
+		DeliveredAt:      time.Date(2030, 1, 31, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestRestoreReceipt_Sign(t *testing.T) {
+	key := []byte("secret")
+	receipt := makeRestoreReceipt()
+	assert.False(t, receipt.HasValidSignature(key))
+	require.Nil(t, receipt.Sign(key))
+	assert.Equal(t, 64, len(receipt.Signature))
+	assert.True(t, receipt.HasValidSignature(key))
+	assert.False(t, receipt.HasValidSignature([]byte("other secret")))
+
+	// Signature survives a round trip through JSON.
+	data, err := json.Marshal(receipt)
+	require.Nil(t, err)
+	copied := &models.RestoreReceipt{}
+	require.Nil(t, json.Unmarshal(data, copied))
+	assert.True(t, copied.HasValidSignature(key))
+
+	// Any change invalidates the signature.
+	copied.Sha256 = "0000000000000000000000000000000000000000000000000000000000000000"
+	assert.False(t, copied.HasValidSignature(key))
+}
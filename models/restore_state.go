@@ -62,10 +62,72 @@ type RestoreState struct {
 	TarFileDeletedAt time.Time
 	// If this restoration was cancelled, the reason goes here.
 	CancelReason string
+	// Receipt describes what we delivered to the restoration bucket.
+	// It's nil until the bag has been delivered.
+	Receipt *RestoreReceipt
+	// GlobusTaskId is the id of the Globus transfer task that is
+	// delivering this bag to the depositor's Globus collection. It's
+	// empty unless the depositor receives bags through Globus. We
+	// keep it so a restarted worker can check on the transfer instead
+	// of starting another.
+	GlobusTaskId string
+	// Checkpoint records how far we got in packaging the bag, so a
+	// restarted worker can resume a large restoration instead of
+	// starting over.
+	Checkpoint *RestoreCheckpoint
+	// ExpiredGlacierFiles lists the identifiers of Glacier-only
+	// GenericFiles whose temporary copies in S3 expired before we
+	// could download them. apt_restorer asks Glacier to retrieve
+	// these again, and requeues the restoration until they're back
+	// in S3. Files already downloaded stay in LocalBagDir.
+	ExpiredGlacierFiles []string
+}
+
+// RestoreCheckpoint describes the progress of the package stage of a
+// restoration. apt_restorer saves it periodically as part of the
+// RestoreState, so that after a crash or restart it can skip work
+// it has already done.
+type RestoreCheckpoint struct {
+	// FilesDownloaded is the number of the object's files present
+	// in LocalBagDir, whether downloaded on this run or a prior one.
+	FilesDownloaded int
+	// BytesDownloaded is the total size of FilesDownloaded.
+	BytesDownloaded int64
+	// TagFilesWritten is true once bagit.txt, bag-info.txt,
+	// aptrust-info.txt, the PREMIS events file and all manifests
+	// have been written to LocalBagDir. We don't regenerate these
+	// on resume, because they may already be in the tar file.
+	TagFilesWritten bool
+	// TarFilesWritten is the number of files from LocalBagDir
+	// that have been written to LocalTarFile.
+	TarFilesWritten int
+	// TarBytesWritten is the size of LocalTarFile after the
+	// last of TarFilesWritten was added. Anything past this
+	// offset is discarded when we resume tarring.
+	TarBytesWritten int64
+	// SavedAt is when this checkpoint was last saved to Pharos.
+	SavedAt time.Time
+}
+
+// ResetTar clears the tar file progress. Call this when the
+// local tar file is deleted or found to be unusable.
+func (checkpoint *RestoreCheckpoint) ResetTar() {
+	checkpoint.TarFilesWritten = 0
+	checkpoint.TarBytesWritten = 0
+}
+
+// Reset clears all progress. Call this when the local bag
+// directory is deleted.
+func (checkpoint *RestoreCheckpoint) Reset() {
+	checkpoint.FilesDownloaded = 0
+	checkpoint.BytesDownloaded = 0
+	checkpoint.TagFilesWritten = false
+	checkpoint.ResetTar()
 }
 
 // NewRestoreState creates a new RestoreState object with empty
-// PackageSummary, RestoreSummary, and ValidationSummary.
+// PackageSummary, RestoreSummary, and ValidationSummary,
+// and an empty Checkpoint.
 func NewRestoreState(message *nsq.Message) *RestoreState {
 	return &RestoreState{
 		NSQMessage:      message,
@@ -73,6 +135,7 @@ func NewRestoreState(message *nsq.Message) *RestoreState {
 		ValidateSummary: NewWorkSummary(),
 		RecordSummary:   NewWorkSummary(),
 		CopySummary:     NewWorkSummary(),
+		Checkpoint:      &RestoreCheckpoint{},
 	}
 }
 
@@ -125,6 +188,12 @@ func (restoreState *RestoreState) MostRecentSummary() *WorkSummary {
 	return summary
 }
 
+// HasExpiredGlacierFiles returns true if we're waiting for Glacier
+// to retrieve files whose temporary S3 copies expired.
+func (restoreState *RestoreState) HasExpiredGlacierFiles() bool {
+	return len(restoreState.ExpiredGlacierFiles) > 0
+}
+
 // TouchNSQ tells NSQ we're still working on this item.
 func (restoreState *RestoreState) TouchNSQ() {
 	if restoreState.NSQMessage != nil {
@@ -4,6 +4,7 @@ import (
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/util/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -90,3 +91,35 @@ func TestRestoreState_MostRecentSummary(t *testing.T) {
 	restoreState.RecordSummary.Start()
 	assert.Equal(t, restoreState.RecordSummary, restoreState.MostRecentSummary())
 }
+
+func TestRestoreCheckpoint_Reset(t *testing.T) {
+	restoreState := models.NewRestoreState(testutil.MakeNsqMessage("999"))
+	require.NotNil(t, restoreState.Checkpoint)
+	checkpoint := restoreState.Checkpoint
+	checkpoint.FilesDownloaded = 10
+	checkpoint.BytesDownloaded = 12000
+	checkpoint.TagFilesWritten = true
+	checkpoint.TarFilesWritten = 8
+	checkpoint.TarBytesWritten = 9216
+
+	checkpoint.ResetTar()
+	assert.Equal(t, 10, checkpoint.FilesDownloaded)
+	assert.Equal(t, int64(12000), checkpoint.BytesDownloaded)
+	assert.True(t, checkpoint.TagFilesWritten)
+	assert.Equal(t, 0, checkpoint.TarFilesWritten)
+	assert.Equal(t, int64(0), checkpoint.TarBytesWritten)
+
+	checkpoint.TarFilesWritten = 8
+	checkpoint.Reset()
+	assert.Equal(t, 0, checkpoint.FilesDownloaded)
+	assert.Equal(t, int64(0), checkpoint.BytesDownloaded)
+	assert.False(t, checkpoint.TagFilesWritten)
+	assert.Equal(t, 0, checkpoint.TarFilesWritten)
+}
+
+func TestRestoreState_HasExpiredGlacierFiles(t *testing.T) {
+	restoreState := models.NewRestoreState(testutil.MakeNsqMessage("999"))
+	assert.False(t, restoreState.HasExpiredGlacierFiles())
+	restoreState.ExpiredGlacierFiles = []string{"test.edu/bag/data/file.txt"}
+	assert.True(t, restoreState.HasExpiredGlacierFiles())
+}
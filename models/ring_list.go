@@ -49,3 +49,14 @@ func (list *RingList) Contains(item string) bool {
 	list.mutex.RUnlock()
 	return exists
 }
+
+// Del removes all occurrences of item from the RingList.
+func (list *RingList) Del(item string) {
+	list.mutex.Lock()
+	for i, value := range list.items {
+		if value == item {
+			list.items[i] = ""
+		}
+	}
+	list.mutex.Unlock()
+}
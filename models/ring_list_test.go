@@ -37,3 +37,18 @@ func TestAddAndContains(t *testing.T) {
 	assert.True(t, ringList.Contains("five"))
 	assert.True(t, ringList.Contains("six"))
 }
+
+func TestRingListDel(t *testing.T) {
+	ringList := models.NewRingList(4)
+	require.NotNil(t, ringList)
+
+	ringList.Add("one")
+	ringList.Add("two")
+	ringList.Del("one")
+	assert.False(t, ringList.Contains("one"))
+	assert.True(t, ringList.Contains("two"))
+
+	// Deleting an item that isn't there should be harmless.
+	ringList.Del("does not exist")
+	assert.True(t, ringList.Contains("two"))
+}
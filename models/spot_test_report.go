@@ -0,0 +1,151 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Outcomes for a SpotTestSelection.
+const (
+	// SpotTestQueued means we created a restore WorkItem.
+	SpotTestQueued = "Queued"
+	// SpotTestDryRun means we chose an object, but created no
+	// WorkItem because this was a dry run.
+	SpotTestDryRun = "Dry run"
+	// SpotTestNoCandidate means the institution had no object
+	// that met the criteria.
+	SpotTestNoCandidate = "No candidate"
+	// SpotTestFailed means we could not search for an object,
+	// or could not create its WorkItem. See Error.
+	SpotTestFailed = "Failed"
+)
+
+// SpotTestCriteria are the rules APTSpotTestRestore uses to choose
+// objects. It chooses the first active, unrestricted object from each
+// institution that is no larger than MaxSize, was created before
+// CreatedBefore, and has not been restored since NotRestoredSince.
+type SpotTestCriteria struct {
+	MaxSize          int64     `json:"max_size"`
+	CreatedBefore    time.Time `json:"created_before"`
+	NotRestoredSince time.Time `json:"not_restored_since"`
+}
+
+// SpotTestSelection describes what the restore spot test did for
+// one institution.
+type SpotTestSelection struct {
+	Institution      string    `json:"institution"`
+	ObjectIdentifier string    `json:"object_identifier,omitempty"`
+	ObjectSize       int64     `json:"object_size,omitempty"`
+	ObjectCreatedAt  time.Time `json:"object_created_at,omitempty"`
+	StorageOption    string    `json:"storage_option,omitempty"`
+	// Action is the WorkItem action, which is Glacier Restore for
+	// objects that are not in standard storage.
+	Action     string `json:"action,omitempty"`
+	WorkItemId int    `json:"work_item_id,omitempty"`
+	Outcome    string `json:"outcome"`
+	Error      string `json:"error,omitempty"`
+}
+
+// SpotTestReport describes one run of APTSpotTestRestore: the
+// criteria it used, which object it chose for each institution,
+// and the WorkItems it created.
+type SpotTestReport struct {
+	StartedAt  time.Time            `json:"started_at"`
+	FinishedAt time.Time            `json:"finished_at"`
+	DryRun     bool                 `json:"dry_run"`
+	Criteria   SpotTestCriteria     `json:"criteria"`
+	Selections []*SpotTestSelection `json:"selections"`
+}
+
+// NewSpotTestReport returns a new SpotTestReport for a run that
+// starts now.
+func NewSpotTestReport(criteria SpotTestCriteria, dryRun bool) *SpotTestReport {
+	return &SpotTestReport{
+		StartedAt:  time.Now().UTC(),
+		DryRun:     dryRun,
+		Criteria:   criteria,
+		Selections: make([]*SpotTestSelection, 0),
+	}
+}
+
+// AddSelection records what we did for one institution. Param obj is
+// the chosen object, or nil if there was none. Param workItem is the
+// restore WorkItem, or nil if we didn't create one. Param err is the
+// error that stopped us, if any.
+func (report *SpotTestReport) AddSelection(institution string, obj *IntellectualObject, workItem *WorkItem, err error) *SpotTestSelection {
+	selection := &SpotTestSelection{Institution: institution}
+	if obj != nil {
+		selection.ObjectIdentifier = obj.Identifier
+		selection.ObjectSize = obj.FileSize
+		selection.ObjectCreatedAt = obj.CreatedAt
+		selection.StorageOption = obj.StorageOption
+	}
+	if workItem != nil {
+		selection.Action = workItem.Action
+		selection.WorkItemId = workItem.Id
+	}
+	if err != nil {
+		selection.Outcome = SpotTestFailed
+		selection.Error = err.Error()
+	} else if obj == nil {
+		selection.Outcome = SpotTestNoCandidate
+	} else if report.DryRun {
+		selection.Outcome = SpotTestDryRun
+	} else {
+		selection.Outcome = SpotTestQueued
+	}
+	report.Selections = append(report.Selections, selection)
+	return selection
+}
+
+// Finish sets FinishedAt to the current time.
+func (report *SpotTestReport) Finish() {
+	report.FinishedAt = time.Now().UTC()
+}
+
+// WorkItemIds returns the ids of the WorkItems this run created.
+func (report *SpotTestReport) WorkItemIds() []int {
+	ids := make([]int, 0)
+	for _, selection := range report.Selections {
+		if selection.WorkItemId != 0 {
+			ids = append(ids, selection.WorkItemId)
+		}
+	}
+	return ids
+}
+
+// Count returns the number of selections with the specified outcome.
+func (report *SpotTestReport) Count(outcome string) int {
+	count := 0
+	for _, selection := range report.Selections {
+		if selection.Outcome == outcome {
+			count++
+		}
+	}
+	return count
+}
+
+// Summary returns a one-line description of the run, suitable for
+// the subject of an email.
+func (report *SpotTestReport) Summary() string {
+	return fmt.Sprintf("Restore spot test: %d queued, %d dry run, %d with no candidate, %d failed",
+		report.Count(SpotTestQueued), report.Count(SpotTestDryRun),
+		report.Count(SpotTestNoCandidate), report.Count(SpotTestFailed))
+}
+
+// ToJson converts this object to JSON.
+func (report *SpotTestReport) ToJson() (string, error) {
+	jsonString, err := json.MarshalIndent(report, "", "  ")
+	return string(jsonString), err
+}
+
+// SpotTestReportFromJson parses a SpotTestReport from JSON.
+func SpotTestReportFromJson(jsonString string) (*SpotTestReport, error) {
+	report := &SpotTestReport{}
+	err := json.Unmarshal([]byte(jsonString), report)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,76 @@
+package models_test
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func getSpotTestReport(dryRun bool) *models.SpotTestReport {
+	criteria := models.SpotTestCriteria{
+		MaxSize:          5000,
+		CreatedBefore:    testutil.TEST_TIMESTAMP,
+		NotRestoredSince: testutil.TEST_TIMESTAMP,
+	}
+	return models.NewSpotTestReport(criteria, dryRun)
+}
+
+func TestSpotTestReportAddSelection(t *testing.T) {
+	report := getSpotTestReport(false)
+	obj := testutil.MakeIntellectualObject(0, 0, 0, 0)
+	workItem := testutil.MakeWorkItem()
+	workItem.Action = constants.ActionGlacierRestore
+
+	selection := report.AddSelection("test.edu", obj, workItem, nil)
+	assert.Equal(t, models.SpotTestQueued, selection.Outcome)
+	assert.Equal(t, obj.Identifier, selection.ObjectIdentifier)
+	assert.Equal(t, obj.FileSize, selection.ObjectSize)
+	assert.Equal(t, workItem.Id, selection.WorkItemId)
+	assert.Equal(t, constants.ActionGlacierRestore, selection.Action)
+	assert.Empty(t, selection.Error)
+
+	selection = report.AddSelection("example.edu", nil, nil, nil)
+	assert.Equal(t, models.SpotTestNoCandidate, selection.Outcome)
+
+	selection = report.AddSelection("other.edu", obj, nil, fmt.Errorf("Pharos is down"))
+	assert.Equal(t, models.SpotTestFailed, selection.Outcome)
+	assert.Equal(t, "Pharos is down", selection.Error)
+
+	assert.Equal(t, []int{workItem.Id}, report.WorkItemIds())
+	assert.Equal(t, "Restore spot test: 1 queued, 0 dry run, 1 with no candidate, 1 failed",
+		report.Summary())
+
+	dryRunReport := getSpotTestReport(true)
+	selection = dryRunReport.AddSelection("test.edu", obj, &models.WorkItem{}, nil)
+	assert.Equal(t, models.SpotTestDryRun, selection.Outcome)
+	assert.Empty(t, dryRunReport.WorkItemIds())
+}
+
+func TestSpotTestReportJson(t *testing.T) {
+	report := getSpotTestReport(false)
+	report.AddSelection("test.edu", testutil.MakeIntellectualObject(0, 0, 0, 0),
+		testutil.MakeWorkItem(), nil)
+	report.AddSelection("example.edu", nil, nil, nil)
+	report.Finish()
+
+	jsonString, err := report.ToJson()
+	require.Nil(t, err)
+	assert.Contains(t, jsonString, `"not_restored_since"`)
+	assert.NotContains(t, jsonString, `"error"`)
+
+	parsed, err := models.SpotTestReportFromJson(jsonString)
+	require.Nil(t, err)
+	assert.Equal(t, report.Criteria.MaxSize, parsed.Criteria.MaxSize)
+	assert.True(t, report.Criteria.CreatedBefore.Equal(parsed.Criteria.CreatedBefore))
+	assert.True(t, report.FinishedAt.Equal(parsed.FinishedAt))
+	require.Equal(t, 2, len(parsed.Selections))
+	assert.Equal(t, *report.Selections[1], *parsed.Selections[1])
+	assert.Equal(t, report.WorkItemIds(), parsed.WorkItemIds())
+
+	_, err = models.SpotTestReportFromJson("{not json")
+	assert.NotNil(t, err)
+}
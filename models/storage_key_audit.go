@@ -0,0 +1,114 @@
+package models
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StorageKeyAudit describes whether a GenericFile's key in preservation
+// storage belongs to that file alone. Keys are UUIDs, so a collision
+// should never happen, but if it did, two files would overwrite each
+// other in storage, and we would not otherwise notice.
+type StorageKeyAudit struct {
+	// GenericFileIdentifier is the identifier of the file we audited.
+	GenericFileIdentifier string `json:"generic_file_identifier"`
+	// Bucket and Key describe where the file's primary copy is stored.
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	// StoredBag and StoredPath are the bag and bagpath metadata on
+	// the stored object, which name the file that was stored there.
+	StoredBag  string `json:"stored_bag"`
+	StoredPath string `json:"stored_path"`
+	// Problems describes each collision or mismatch we found. If this
+	// is empty, the key belongs to this file alone.
+	Problems []string `json:"problems"`
+	// CheckedAt is when we ran the audit.
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// NewStorageKeyAudit returns a new StorageKeyAudit for the specified
+// GenericFile, which is stored under key in bucket.
+func NewStorageKeyAudit(gf *GenericFile, bucket, key string) *StorageKeyAudit {
+	return &StorageKeyAudit{
+		GenericFileIdentifier: gf.Identifier,
+		Bucket:                bucket,
+		Key:                   key,
+		Problems:              make([]string, 0),
+		CheckedAt:             time.Now().UTC(),
+	}
+}
+
+// StoredFileBelongsTo returns true if the bag and bagpath metadata
+// of storedFile name gf. The storer writes this metadata on every
+// object it saves.
+func StoredFileBelongsTo(storedFile *StoredFile, gf *GenericFile) bool {
+	return storedFile.BagName == gf.IntellectualObjectIdentifier &&
+		storedFile.PathInBag == gf.OriginalPath()
+}
+
+// Compare checks that the object stored under the file's key belongs
+// to the file. Param gf is the file we're auditing. Param storedFile
+// describes the stored object, or is nil if there is no object with
+// that key. Objects stored without bag metadata can't be checked,
+// so we don't count them as problems.
+func (audit *StorageKeyAudit) Compare(gf *GenericFile, storedFile *StoredFile) {
+	if storedFile == nil {
+		audit.AddProblem("No object with key %s in %s", audit.Key, audit.Bucket)
+		return
+	}
+	audit.StoredBag = storedFile.BagName
+	audit.StoredPath = storedFile.PathInBag
+	if storedFile.BagName == "" && storedFile.PathInBag == "" {
+		return
+	}
+	if !StoredFileBelongsTo(storedFile, gf) {
+		audit.AddProblem("Object %s in %s belongs to %s/%s",
+			audit.Key, audit.Bucket, storedFile.BagName, storedFile.PathInBag)
+	}
+}
+
+// AddClaimant records that another GenericFile in Pharos uses the
+// same key.
+func (audit *StorageKeyAudit) AddClaimant(gfIdentifier string) {
+	audit.AddProblem("Key %s is also claimed by %s", audit.Key, gfIdentifier)
+}
+
+// AddProblem adds a problem to the audit.
+func (audit *StorageKeyAudit) AddProblem(format string, a ...interface{}) {
+	audit.Problems = append(audit.Problems, fmt.Sprintf(format, a...))
+}
+
+// HasProblems returns true if the audit found a collision or mismatch.
+func (audit *StorageKeyAudit) HasProblems() bool {
+	return len(audit.Problems) > 0
+}
+
+// ToJson converts this object to JSON.
+func (audit *StorageKeyAudit) ToJson() (string, error) {
+	jsonString, err := json.Marshal(audit)
+	return string(jsonString), err
+}
+
+// ToCSV converts this object to a CSV record. Multiple problems
+// are joined with semicolons in the last column. Param delimiter
+// is the field delimiter (comma, tab, pipe, etc).
+func (audit *StorageKeyAudit) ToCSV(delimiter rune) (string, error) {
+	buffer := bytes.NewBuffer(make([]byte, 0))
+	writer := csv.NewWriter(buffer)
+	writer.Comma = delimiter
+	writer.Write([]string{
+		audit.GenericFileIdentifier,
+		audit.Bucket,
+		audit.Key,
+		audit.StoredBag,
+		audit.StoredPath,
+		audit.CheckedAt.Format(time.RFC3339),
+		strings.Join(audit.Problems, "; "),
+	})
+	writer.Flush()
+	return buffer.String(), writer.Error()
+}
@@ -0,0 +1,68 @@
+package models_test
+
+import (
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+const auditKey = "c4de8b2a-6b3a-4a4e-9b5e-2c1a2b3c4d5e"
+
+func getGenericFileForKeyAudit() *models.GenericFile {
+	gf := getGenericFile()
+	gf.Identifier = "test.edu/bag/data/file.txt"
+	gf.IntellectualObjectIdentifier = "test.edu/bag"
+	return gf
+}
+
+func TestStorageKeyAuditCompare(t *testing.T) {
+	gf := getGenericFileForKeyAudit()
+	audit := models.NewStorageKeyAudit(gf, "preservation", auditKey)
+	assert.Equal(t, gf.Identifier, audit.GenericFileIdentifier)
+	assert.False(t, audit.CheckedAt.IsZero())
+
+	// Object belongs to this file.
+	storedFile := &models.StoredFile{BagName: "test.edu/bag", PathInBag: "data/file.txt"}
+	assert.True(t, models.StoredFileBelongsTo(storedFile, gf))
+	audit.Compare(gf, storedFile)
+	assert.False(t, audit.HasProblems())
+	assert.Equal(t, "data/file.txt", audit.StoredPath)
+
+	// Objects without metadata can't be checked.
+	audit.Compare(gf, &models.StoredFile{})
+	assert.False(t, audit.HasProblems())
+
+	// Object belongs to another file.
+	storedFile.PathInBag = "data/other.txt"
+	assert.False(t, models.StoredFileBelongsTo(storedFile, gf))
+	audit.Compare(gf, storedFile)
+	require.Equal(t, 1, len(audit.Problems))
+	assert.Equal(t, "Object "+auditKey+" in preservation belongs to test.edu/bag/data/other.txt",
+		audit.Problems[0])
+
+	// No object at all.
+	audit = models.NewStorageKeyAudit(gf, "preservation", auditKey)
+	audit.Compare(gf, nil)
+	assert.True(t, audit.HasProblems())
+}
+
+func TestStorageKeyAuditToCSV(t *testing.T) {
+	gf := getGenericFileForKeyAudit()
+	audit := models.NewStorageKeyAudit(gf, "preservation", auditKey)
+	audit.AddClaimant("test.edu/bag2/data/file.txt")
+	assert.True(t, audit.HasProblems())
+
+	record, err := audit.ToCSV('\t')
+	require.Nil(t, err)
+	fields := strings.Split(strings.TrimSpace(record), "\t")
+	require.Equal(t, 7, len(fields))
+	assert.Equal(t, gf.Identifier, fields[0])
+	assert.Equal(t, auditKey, fields[2])
+	assert.Equal(t, "Key "+auditKey+" is also claimed by test.edu/bag2/data/file.txt", fields[6])
+
+	jsonString, err := audit.ToJson()
+	require.Nil(t, err)
+	assert.Contains(t, jsonString, `"key":"`+auditKey+`"`)
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+/*
+StorageOptionHistory records one change in where a GenericFile is
+stored. A file gets its first entry when we store it at ingest, and
+another each time it moves to a different storage option. Together,
+a file's entries let auditors trace where the file has lived over time.
+
+Option is the storage option the file moved to, such as
+constants.StorageStandard or constants.StorageGlacierOH.
+
+ChangedAt is when the file moved. Reason is a human-readable
+description of why it moved, and WorkItemId is the id of the
+WorkItem that moved it.
+*/
+type StorageOptionHistory struct {
+	Id            int       `json:"id,omitempty"` // Do not serialize zero to JSON!
+	GenericFileId int       `json:"generic_file_id"`
+	Option        string    `json:"option"`
+	ChangedAt     time.Time `json:"changed_at"`
+	Reason        string    `json:"reason"`
+	WorkItemId    int       `json:"work_item_id"`
+	CreatedAt     time.Time `json:"created_at,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at,omitempty"`
+}
+
+// Clone returns an exact clone of this StorageOptionHistory, including the Id.
+func (history *StorageOptionHistory) Clone() *StorageOptionHistory {
+	return &StorageOptionHistory{
+		Id:            history.Id,
+		GenericFileId: history.GenericFileId,
+		Option:        history.Option,
+		ChangedAt:     history.ChangedAt,
+		Reason:        history.Reason,
+		WorkItemId:    history.WorkItemId,
+		CreatedAt:     history.CreatedAt,
+		UpdatedAt:     history.UpdatedAt,
+	}
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"strings"
+	"time"
+)
+
+// Roles for StorageRecord.Role.
+const (
+	// StorageRolePrimary is the copy we read from for fixity
+	// checks and restores. For files in standard storage, that's
+	// S3 Virginia. For Glacier-only files, it's the only copy.
+	StorageRolePrimary = "primary"
+	// StorageRoleReplica is the second copy, in Glacier Oregon
+	// for files in standard storage.
+	StorageRoleReplica = "replica"
+	// StorageRoleTertiary is a third copy, with any other provider.
+	StorageRoleTertiary = "tertiary"
+)
+
+// StorageProviderAWS is the StorageRecord.Provider for copies in
+// S3 and Glacier.
+const StorageProviderAWS = "aws"
+
+/*
+StorageRecord describes one stored copy of a GenericFile.
+
+Role says which copy this is, and is one of the StorageRole constants.
+
+Provider is the storage provider, such as StorageProviderAWS. Region,
+Bucket, and Key say where the provider keeps the copy. Region may be
+empty for records built from a legacy URL, which doesn't include it.
+
+StoredAt is when we stored the copy, and VerifiedAt is when we last
+confirmed that the copy is intact.
+*/
+type StorageRecord struct {
+	Role       string    `json:"role"`
+	Provider   string    `json:"provider"`
+	Region     string    `json:"region,omitempty"`
+	Bucket     string    `json:"bucket"`
+	Key        string    `json:"key"`
+	StoredAt   time.Time `json:"stored_at,omitempty"`
+	VerifiedAt time.Time `json:"verified_at,omitempty"`
+}
+
+// NewStorageRecordFromURL returns a StorageRecord for the copy at
+// storageURL, which should be an S3 URL such as
+// "https://s3.amazonaws.com/bucket/key". It returns an error if the
+// URL doesn't include a bucket and key.
+func NewStorageRecordFromURL(role, region, storageURL string, storedAt time.Time) (*StorageRecord, error) {
+	parts := strings.Split(storageURL, "/")
+	length := len(parts)
+	if length < 4 || parts[length-2] == "" || parts[length-1] == "" {
+		return nil, fmt.Errorf("Storage URL '%s' is invalid", storageURL)
+	}
+	return &StorageRecord{
+		Role:     role,
+		Provider: StorageProviderAWS,
+		Region:   region,
+		Bucket:   parts[length-2],
+		Key:      parts[length-1],
+		StoredAt: storedAt,
+	}, nil
+}
+
+// URL returns the URL of the stored copy.
+func (record *StorageRecord) URL() string {
+	return fmt.Sprintf("%s%s/%s", constants.S3UriPrefix, record.Bucket, record.Key)
+}
+
+// Clone returns an exact copy of this StorageRecord.
+func (record *StorageRecord) Clone() *StorageRecord {
+	return &StorageRecord{
+		Role:       record.Role,
+		Provider:   record.Provider,
+		Region:     record.Region,
+		Bucket:     record.Bucket,
+		Key:        record.Key,
+		StoredAt:   record.StoredAt,
+		VerifiedAt: record.VerifiedAt,
+	}
+}
@@ -0,0 +1,48 @@
+package models_test
+
+import (
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNewStorageRecordFromURL(t *testing.T) {
+	storedAt := time.Date(2019, 3, 4, 12, 0, 0, 0, time.UTC)
+	url := "https://s3.amazonaws.com/aptrust.test.preservation/a58a7c00-392f-11e4-916c-0800200c9a66"
+	record, err := models.NewStorageRecordFromURL(models.StorageRolePrimary,
+		constants.AWSVirginia, url, storedAt)
+	require.Nil(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, models.StorageRolePrimary, record.Role)
+	assert.Equal(t, models.StorageProviderAWS, record.Provider)
+	assert.Equal(t, constants.AWSVirginia, record.Region)
+	assert.Equal(t, "aptrust.test.preservation", record.Bucket)
+	assert.Equal(t, "a58a7c00-392f-11e4-916c-0800200c9a66", record.Key)
+	assert.Equal(t, storedAt, record.StoredAt)
+	assert.True(t, record.VerifiedAt.IsZero())
+	assert.Equal(t, url, record.URL())
+
+	for _, badUrl := range []string{"", "a58a7c00", "https://s3.amazonaws.com/", "https://s3.amazonaws.com/bucket/"} {
+		record, err = models.NewStorageRecordFromURL(models.StorageRolePrimary, "", badUrl, storedAt)
+		assert.NotNil(t, err, badUrl)
+		assert.Nil(t, record, badUrl)
+	}
+}
+
+func TestStorageRecordClone(t *testing.T) {
+	record := &models.StorageRecord{
+		Role:       models.StorageRoleReplica,
+		Provider:   models.StorageProviderAWS,
+		Region:     constants.AWSOregon,
+		Bucket:     "aptrust.test.replication",
+		Key:        "a58a7c00-392f-11e4-916c-0800200c9a66",
+		StoredAt:   time.Now().UTC(),
+		VerifiedAt: time.Now().UTC(),
+	}
+	clone := record.Clone()
+	assert.Equal(t, record, clone)
+	assert.False(t, record == clone)
+}
@@ -26,6 +26,9 @@ type StorageSummary struct {
 	// GenericFile is the file to be saved in S3/Glacier. The storage
 	// goroutine will update this object directly.
 	GenericFile *GenericFile
+	// WorkItemId is the id of the ingest WorkItem. The storage
+	// goroutine records it in the GenericFile's StorageOptionHistory.
+	WorkItemId int
 }
 
 // NewStorageSummary creates a new StorageSummary object.
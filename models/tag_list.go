@@ -0,0 +1,145 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tagLineRegex matches one line of a bagit tag file. The first group
+// is the label and its colon, which is empty on continuation lines.
+var tagLineRegex = regexp.MustCompile(`^(\S*\:)?(\s*.*)?$`)
+
+// TagList is the ordered list of tags in one tag file, such as
+// bag-info.txt. The BagIt spec says labels may repeat, and their
+// order must be preserved, so unlike the IntellectualObject fields
+// we copy tag values into, a TagList keeps every tag in its
+// original order.
+type TagList struct {
+	SourceFile string
+	Tags       []*Tag
+}
+
+// NewTagList returns an empty TagList for the specified tag file.
+func NewTagList(sourceFile string) *TagList {
+	return &TagList{
+		SourceFile: sourceFile,
+		Tags:       make([]*Tag, 0),
+	}
+}
+
+// ParseTagList parses the contents of a bagit tag file. Values that
+// span several lines are joined with single spaces. Each tag keeps
+// its original text in Tag.Raw, so Bytes() can reproduce the file
+// exactly. Text before the first tag, such as blank lines, becomes
+// part of the first tag's Raw text. A file with no tags at all
+// parses to an empty list.
+func ParseTagList(sourceFile string, data []byte) *TagList {
+	list := NewTagList(sourceFile)
+	var tag *Tag
+	pending := ""
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n') + 1
+		if end == 0 {
+			end = len(data)
+		}
+		rawLine := string(data[:end])
+		data = data[end:]
+		line := strings.TrimSuffix(strings.TrimSuffix(rawLine, "\n"), "\r")
+		label, value, isContinuation := parseTagLine(line)
+		if !isContinuation {
+			tag = NewTag(sourceFile, label, value)
+			tag.Raw = pending + rawLine
+			pending = ""
+			list.Tags = append(list.Tags, tag)
+			continue
+		}
+		if tag == nil {
+			pending += rawLine
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			tag.Value = strings.Join([]string{tag.Value, value}, " ")
+		}
+		tag.Raw += rawLine
+	}
+	return list
+}
+
+// parseTagLine returns the label and value of one line of a tag file.
+// It returns isContinuation = true for blank lines and for lines that
+// continue the value of the tag before them.
+func parseTagLine(line string) (label, value string, isContinuation bool) {
+	if strings.TrimSpace(line) == "" {
+		return "", "", true
+	}
+	data := tagLineRegex.FindStringSubmatch(line)
+	if data == nil {
+		return "", strings.TrimSpace(line), true
+	}
+	label = strings.Replace(strings.TrimSpace(data[1]), ":", "", 1)
+	return label, strings.TrimSpace(data[2]), label == ""
+}
+
+// Add appends a new tag to the end of the list, and returns it.
+func (list *TagList) Add(label, value string) *Tag {
+	tag := NewTag(list.SourceFile, label, value)
+	list.Tags = append(list.Tags, tag)
+	return tag
+}
+
+// Find returns all tags with the specified label, in order.
+// Labels are case-sensitive.
+func (list *TagList) Find(label string) []*Tag {
+	tags := make([]*Tag, 0)
+	for _, tag := range list.Tags {
+		if tag.Label == label {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Bytes returns the contents of the tag file. Tags whose Raw text
+// still describes their Label and Value are written exactly as they
+// were parsed. Other tags, including those that were added or changed
+// after parsing, are written as "Label: Value" on a single line.
+func (list *TagList) Bytes() []byte {
+	buf := bytes.Buffer{}
+	for _, tag := range list.Tags {
+		if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+			buf.WriteString("\n")
+		}
+		if tag.rawMatches() {
+			buf.WriteString(tag.Raw)
+		} else {
+			buf.WriteString(fmt.Sprintf("%s: %s\n", tag.Label, tag.Value))
+		}
+	}
+	return buf.Bytes()
+}
+
+// rawMatches returns true if parsing tag.Raw yields this tag's
+// Label and Value.
+func (tag *Tag) rawMatches() bool {
+	if tag.Raw == "" {
+		return false
+	}
+	parsed := ParseTagList(tag.SourceFile, []byte(tag.Raw))
+	return len(parsed.Tags) == 1 &&
+		parsed.Tags[0].Label == tag.Label &&
+		parsed.Tags[0].Value == tag.Value
+}
+
+// TagList returns the IngestTags that came from the specified tag
+// file, in their original order.
+func (obj *IntellectualObject) TagList(sourceFile string) *TagList {
+	list := NewTagList(sourceFile)
+	for _, tag := range obj.IngestTags {
+		if tag.SourceFile == sourceFile {
+			list.Tags = append(list.Tags, tag)
+		}
+	}
+	return list
+}
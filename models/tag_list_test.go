@@ -0,0 +1,80 @@
+package models_test
+
+import (
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// bagInfoWithQuirks has repeated labels, a value that spans three
+// lines, CRLF line endings, blank lines, and no final newline.
+const bagInfoWithQuirks = "\n" +
+	"Source-Organization: virginia.edu\n" +
+	"Contact-Name:   Homer Simpson\r\n" +
+	"External-Description: This description\n" +
+	"    spans three\n" +
+	"\tlines.\n" +
+	"\n" +
+	"Contact-Name: Marge Simpson\n" +
+	"Bag-Count:1 of 1"
+
+func TestParseTagList(t *testing.T) {
+	list := models.ParseTagList("bag-info.txt", []byte(bagInfoWithQuirks))
+	require.Equal(t, 5, len(list.Tags))
+	assert.Equal(t, "bag-info.txt", list.SourceFile)
+
+	expected := [][]string{
+		{"Source-Organization", "virginia.edu"},
+		{"Contact-Name", "Homer Simpson"},
+		{"External-Description", "This description spans three lines."},
+		{"Contact-Name", "Marge Simpson"},
+		{"Bag-Count", "1 of 1"},
+	}
+	for i, tag := range list.Tags {
+		assert.Equal(t, "bag-info.txt", tag.SourceFile)
+		assert.Equal(t, expected[i][0], tag.Label)
+		assert.Equal(t, expected[i][1], tag.Value)
+	}
+
+	contacts := list.Find("Contact-Name")
+	require.Equal(t, 2, len(contacts))
+	assert.Equal(t, "Homer Simpson", contacts[0].Value)
+	assert.Equal(t, "Marge Simpson", contacts[1].Value)
+	assert.Empty(t, list.Find("contact-name"))
+
+	assert.Empty(t, models.ParseTagList("bag-info.txt", []byte("\n\n")).Tags)
+}
+
+func TestTagListBytes(t *testing.T) {
+	list := models.ParseTagList("bag-info.txt", []byte(bagInfoWithQuirks))
+	assert.Equal(t, bagInfoWithQuirks, string(list.Bytes()))
+
+	// Changed and added tags are written on one line. The rest
+	// keep their original text.
+	list.Tags[2].Value = "Short description."
+	list.Add("Bag-Group-Identifier", "Group 1")
+	expected := "\n" +
+		"Source-Organization: virginia.edu\n" +
+		"Contact-Name:   Homer Simpson\r\n" +
+		"External-Description: Short description.\n" +
+		"Contact-Name: Marge Simpson\n" +
+		"Bag-Count:1 of 1\n" +
+		"Bag-Group-Identifier: Group 1\n"
+	assert.Equal(t, expected, string(list.Bytes()))
+
+	assert.Empty(t, models.NewTagList("bag-info.txt").Bytes())
+}
+
+func TestIntellectualObjectTagList(t *testing.T) {
+	obj := models.NewIntellectualObject()
+	obj.IngestTags = append(obj.IngestTags,
+		models.ParseTagList("bag-info.txt", []byte(bagInfoWithQuirks)).Tags...)
+	obj.IngestTags = append(obj.IngestTags, models.NewTag("aptrust-info.txt", "Title", "Title"))
+
+	list := obj.TagList("bag-info.txt")
+	assert.Equal(t, 5, len(list.Tags))
+	assert.Equal(t, bagInfoWithQuirks, string(list.Bytes()))
+	assert.Equal(t, 1, len(obj.TagList("aptrust-info.txt").Tags))
+	assert.Empty(t, obj.TagList("bagit.txt").Tags)
+}
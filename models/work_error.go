@@ -0,0 +1,72 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Error codes for WorkError.Code.
+const (
+	// ErrUnknown is the code for errors added through
+	// WorkSummary.AddError, which says nothing about
+	// where the error came from.
+	ErrUnknown = "Unknown"
+	// ErrInvalidData means the data we're working on is wrong,
+	// and will still be wrong next time.
+	ErrInvalidData = "InvalidData"
+	// ErrPharos means a request to Pharos failed.
+	ErrPharos = "Pharos"
+	// ErrS3 means a request to S3 failed.
+	ErrS3 = "S3"
+	// ErrGlacier means a Glacier retrieval request failed.
+	ErrGlacier = "Glacier"
+)
+
+// WorkError is an error recorded in a WorkSummary. Code and Retryable
+// let workers decide what to do about the error without parsing
+// Message. Identifier is the identifier of the file, object, or
+// request the error relates to, if any.
+type WorkError struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Retryable  bool   `json:"retryable"`
+	Identifier string `json:"identifier,omitempty"`
+}
+
+// NewWorkError returns a WorkError with the specified code, identifier,
+// and retryability, and a message built from format and a, as with
+// fmt.Sprintf.
+func NewWorkError(code, identifier string, retryable bool, format string, a ...interface{}) *WorkError {
+	return &WorkError{
+		Code:       code,
+		Message:    fmt.Sprintf(format, a...),
+		Retryable:  retryable,
+		Identifier: identifier,
+	}
+}
+
+// Error returns the error message, so a WorkError can be returned
+// as an error.
+func (workError *WorkError) Error() string {
+	return workError.Message
+}
+
+// UnmarshalJSON reads a WorkError from JSON. WorkSummaries saved before
+// we had WorkErrors store each error as a plain string, so this
+// accepts a string as well, and turns it into a non-retryable
+// WorkError with code ErrUnknown.
+func (workError *WorkError) UnmarshalJSON(data []byte) error {
+	var message string
+	if err := json.Unmarshal(data, &message); err == nil {
+		*workError = WorkError{Code: ErrUnknown, Message: message}
+		return nil
+	}
+	// Use an alias type so json doesn't call this method again.
+	type workErrorAlias WorkError
+	alias := workErrorAlias{}
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+	*workError = WorkError(alias)
+	return nil
+}
@@ -94,6 +94,12 @@ type WorkItem struct {
 	// all actions other than deletion. This is the email
 	// address of the APTrust admin who approved the deletion.
 	APTrustApprover *string `json:"aptrust_approver"`
+	// EmbargoApprover is for restorations only and will be null for
+	// all other actions. This is the email address of the APTrust
+	// admin who approved restoring an object or file that is still
+	// under embargo. Exchange services will not restore embargoed
+	// items where this field is nil.
+	EmbargoApprover *string `json:"embargo_approver"`
 	// Date is the timestamp describing when some worker process last
 	// touched this item.
 	Date time.Time `json:"date"`
@@ -114,6 +120,11 @@ type WorkItem struct {
 	// Status is the status of this WorkItem. See the values in
 	// constants.StatusTypes.
 	Status string `json:"status"`
+	// Priority is one of constants.Priorities. apt_queue and the
+	// bucket reader hold back lower-priority items when they add them
+	// to NSQ, so small restores don't wait behind huge ingests. Empty
+	// means normal priority. See EffectivePriority.
+	Priority string `json:"priority,omitempty"`
 	// Outcome describes the outcome of a completed WorkItem. For example,
 	// Success, Failure, Cancelled.
 	Outcome string `json:"outcome"`
@@ -157,7 +168,14 @@ type WorkItem struct {
 // Convert WorkItem to JSON, omitting id and other attributes that
 // Rails won't permit. For internal use, json.Marshal() works fine.
 func (item *WorkItem) SerializeForPharos() ([]byte, error) {
-	return json.Marshal(map[string]interface{}{
+	return json.Marshal(item.DataForPharos())
+}
+
+// DataForPharos returns the attributes of this item that Pharos
+// permits us to set, without the id. SerializeForPharos sends these
+// as JSON.
+func (item *WorkItem) DataForPharos() map[string]interface{} {
+	data := map[string]interface{}{
 		"name":                    item.Name,
 		"bucket":                  item.Bucket,
 		"etag":                    item.ETag,
@@ -181,7 +199,43 @@ func (item *WorkItem) SerializeForPharos() ([]byte, error) {
 		"user":                    item.User,
 		"inst_approver":           item.InstitutionalApprover,
 		"aptrust_approver":        item.APTrustApprover,
-	})
+		"embargo_approver":        item.EmbargoApprover,
+	}
+	// Send priority only when it's set, since most items don't have one.
+	if item.Priority != "" {
+		data["priority"] = item.Priority
+	}
+	return data
+}
+
+// EffectivePriority returns the item's Priority, or
+// constants.PriorityNormal if it has none.
+func (item *WorkItem) EffectivePriority() string {
+	if item.Priority == "" {
+		return constants.PriorityNormal
+	}
+	return item.Priority
+}
+
+// StatusForPharos returns the id of this item and the fields that
+// describe its progress, for PharosClient.WorkItemStatusUpdateBatch.
+// It leaves out fields that don't change as the item moves through
+// the pipeline, such as name, etag, and institution.
+func (item *WorkItem) StatusForPharos() map[string]interface{} {
+	return map[string]interface{}{
+		"id":                 item.Id,
+		"date":               item.Date,
+		"note":               item.Note,
+		"stage":              item.Stage,
+		"stage_started_at":   item.StageStartedAt,
+		"status":             item.Status,
+		"outcome":            item.Outcome,
+		"retry":              item.Retry,
+		"node":               item.Node,
+		"pid":                item.Pid,
+		"needs_admin_review": item.NeedsAdminReview,
+		"queued_at":          item.QueuedAt,
+	}
 }
 
 // Returns true if an object's files have been stored in S3 preservation bucket.
@@ -207,6 +261,12 @@ func (item *WorkItem) ShouldTryIngest() bool {
 	return item.HasBeenStored() == false && item.IsStoring() == false && item.Retry == true
 }
 
+// IsHeldForQuota returns true if the bucket reader is holding this
+// item because its institution is over its storage quota.
+func (item *WorkItem) IsHeldForQuota() bool {
+	return item.Outcome == constants.QuotaHeldOutcome
+}
+
 // Returns true if the WorkItem records include a delete
 // request that has not been completed.
 func HasPendingDeleteRequest(workItems []*WorkItem) bool {
@@ -263,6 +323,19 @@ func (item *WorkItem) BelongsToAnotherWorker() bool {
 	return item.Node != hostname || item.Pid != os.Getpid()
 }
 
+// IsOrphanedOnThisNode returns true if this item is marked as Started
+// by a worker on this host whose process id differs from ours. That
+// happens when a worker crashes or is killed in the middle of
+// processing, leaving the item in Started state with no one working
+// on it.
+func (item *WorkItem) IsOrphanedOnThisNode() bool {
+	if item.Status != constants.StatusStarted || item.Node == "" {
+		return false
+	}
+	hostname, _ := os.Hostname()
+	return item.Node == hostname && item.Pid != os.Getpid()
+}
+
 // IsInProgress returns true if any worker is currently
 // working on this item.
 func (item *WorkItem) IsInProgress() bool {
@@ -319,3 +392,21 @@ func (item *WorkItem) MsgGoingToValidation() string {
 func (item *WorkItem) MsgGoingToFetch() string {
 	return fmt.Sprintf("Bag %s is going into the fetch channel.", item.Name)
 }
+
+// Validate returns a ModelError if the WorkItem is missing a field
+// Pharos requires, has an unknown action, stage or status, or has
+// a malformed object or file identifier.
+func (item *WorkItem) Validate() error {
+	checker := newModelChecker("WorkItem", fmt.Sprintf("%d (%s/%s)", item.Id, item.Bucket, item.Name))
+	checker.require("Name", item.Name)
+	checker.require("Bucket", item.Bucket)
+	checker.require("ETag", item.ETag)
+	checker.requireOneOf("Action", item.Action, constants.ActionTypes, true)
+	checker.requireOneOf("Stage", item.Stage, constants.StageTypes, true)
+	checker.requireOneOf("Status", item.Status, constants.StatusTypes, true)
+	checker.requireOneOf("Priority", item.Priority, constants.Priorities, false)
+	checker.requireSlashes("ObjectIdentifier", item.ObjectIdentifier, 1, "institution/bag")
+	checker.requirePrefix("GenericFileIdentifier", item.GenericFileIdentifier,
+		"ObjectIdentifier", item.ObjectIdentifier)
+	return checker.result()
+}
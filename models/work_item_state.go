@@ -1,12 +1,21 @@
 package models
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"github.com/APTrust/exchange/constants"
+	"io/ioutil"
+	"strings"
 	"time"
 )
 
+// WorkItemStateEncodingGzip is the ContentEncoding of a WorkItemState
+// whose State is gzipped JSON, encoded as base64.
+const WorkItemStateEncodingGzip = "gzip"
+
 // WorkItemState contains information about what work has been completed,
 // and what work remains to be done, for the associated WorkItem. WorkItems
 // that have not yet started processing will have no associated WorkItemState.
@@ -38,6 +47,12 @@ type WorkItemState struct {
 	// after failures, and for forensics on failed items. Admin users can
 	// see the state JSON in the WorkItem detail view in Pharos.
 	State string `json:"state"`
+	// ContentEncoding describes how State is encoded. It's empty when
+	// State is plain JSON, or WorkItemStateEncodingGzip when State is
+	// compressed to fit states of very large bags into Pharos. Use
+	// StateJson, or the IngestManifest and GlacierRestoreState methods,
+	// to read State without worrying about the encoding.
+	ContentEncoding string `json:"content_encoding,omitempty"`
 	// CreatedAt is the Rails timestamp describing when this item was created.
 	CreatedAt time.Time `json:"created_at"`
 	// UpdatedAt is the Rails timestamp describing when this item was updated.
@@ -56,6 +71,68 @@ func (state *WorkItemState) HasData() bool {
 	return state.State != ""
 }
 
+// StateJson returns State as plain JSON, decoding it first if it's
+// compressed. It does not change State.
+func (state *WorkItemState) StateJson() ([]byte, error) {
+	switch state.ContentEncoding {
+	case "":
+		return []byte(state.State), nil
+	case WorkItemStateEncodingGzip:
+		compressed, err := base64.StdEncoding.DecodeString(state.State)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot decode base64 state of WorkItem %d: %v",
+				state.WorkItemId, err)
+		}
+		reader, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, fmt.Errorf("Cannot decompress state of WorkItem %d: %v",
+				state.WorkItemId, err)
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	}
+	return nil, fmt.Errorf("WorkItemState for WorkItem %d has unsupported content "+
+		"encoding '%s'", state.WorkItemId, state.ContentEncoding)
+}
+
+// Compress gzips State and sets ContentEncoding to say so. This is a
+// no-op if State is already compressed.
+func (state *WorkItemState) Compress() error {
+	if state.ContentEncoding == WorkItemStateEncodingGzip {
+		return nil
+	}
+	if state.ContentEncoding != "" {
+		return fmt.Errorf("Cannot compress WorkItemState with content encoding '%s'",
+			state.ContentEncoding)
+	}
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(state.State)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	state.State = base64.StdEncoding.EncodeToString(buf.Bytes())
+	state.ContentEncoding = WorkItemStateEncodingGzip
+	return nil
+}
+
+// Decompress replaces compressed State with plain JSON and clears
+// ContentEncoding. This is a no-op if State is not compressed.
+func (state *WorkItemState) Decompress() error {
+	if state.ContentEncoding == "" {
+		return nil
+	}
+	jsonData, err := state.StateJson()
+	if err != nil {
+		return err
+	}
+	state.State = string(jsonData)
+	state.ContentEncoding = ""
+	return nil
+}
+
 // IngestManifest converts the State string (JSON) to an IngestManifest
 // object. This works only if there's data in the State string, and the
 // Action is constants.ActionIngest. Other actions will have different
@@ -68,8 +145,12 @@ func (state *WorkItemState) IngestManifest() (*IngestManifest, error) {
 		return nil, fmt.Errorf("Cannot convert state to IngestManifest because action is '%s' "+
 			"and must be '%s'.", state.Action, constants.ActionIngest)
 	}
+	jsonData, err := state.StateJson()
+	if err != nil {
+		return nil, err
+	}
 	ingestManifest := NewIngestManifest()
-	err := json.Unmarshal([]byte(state.State), ingestManifest)
+	err = json.Unmarshal(jsonData, ingestManifest)
 	return ingestManifest, err
 }
 
@@ -83,6 +164,7 @@ func (state *WorkItemState) SetStateFromIngestManifest(manifest *IngestManifest)
 	jsonData, err := json.MarshalIndent(manifest, "", "  ")
 	if err == nil {
 		state.State = string(jsonData)
+		state.ContentEncoding = ""
 	}
 	return err
 }
@@ -95,7 +177,93 @@ func (state *WorkItemState) GlacierRestoreState() (*GlacierRestoreState, error)
 		return nil, fmt.Errorf("Cannot convert state to WorkSummary because action is '%s' "+
 			"and must be '%s'.", state.Action, constants.ActionGlacierRestore)
 	}
+	jsonData, err := state.StateJson()
+	if err != nil {
+		return nil, err
+	}
 	glacierRestoreState := &GlacierRestoreState{}
-	err := json.Unmarshal([]byte(state.State), glacierRestoreState)
+	err = json.Unmarshal(jsonData, glacierRestoreState)
 	return glacierRestoreState, err
 }
+
+// WorkItemStatePointer tells where to find a WorkItemState.State that
+// was too large to store in Pharos. When we offload a state to S3, the
+// WorkItemState record in Pharos holds a pointer in place of the state,
+// serialized as {"offloaded_state": {...}}. See Config.WorkItemStateMaxSize.
+type WorkItemStatePointer struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	// Sha256 is the hex-encoded sha256 digest of the offloaded state.
+	Sha256 string `json:"sha256"`
+	// Size is the size of the offloaded state, in bytes.
+	Size int `json:"size"`
+	// ContentEncoding is the ContentEncoding of the offloaded state.
+	ContentEncoding string `json:"content_encoding,omitempty"`
+}
+
+// workItemStatePointerWrapper gives the pointer a key that no real
+// state has, so we can tell pointers from states.
+type workItemStatePointerWrapper struct {
+	OffloadedState *WorkItemStatePointer `json:"offloaded_state"`
+}
+
+// StatePointer returns the pointer in State, or nil if State holds
+// an actual state rather than a pointer to one.
+func (state *WorkItemState) StatePointer() *WorkItemStatePointer {
+	if state.ContentEncoding != "" || !strings.HasPrefix(strings.TrimSpace(state.State), `{"offloaded_state"`) {
+		return nil
+	}
+	wrapper := &workItemStatePointerWrapper{}
+	if err := json.Unmarshal([]byte(state.State), wrapper); err != nil {
+		return nil
+	}
+	return wrapper.OffloadedState
+}
+
+// SetStatePointer replaces State with the specified pointer. The
+// pointer itself is never compressed. Its ContentEncoding says how
+// the offloaded state is encoded.
+func (state *WorkItemState) SetStatePointer(pointer *WorkItemStatePointer) error {
+	jsonData, err := json.Marshal(&workItemStatePointerWrapper{OffloadedState: pointer})
+	if err == nil {
+		state.State = string(jsonData)
+		state.ContentEncoding = ""
+	}
+	return err
+}
+
+// WorkItemStateVersion is a copy of a WorkItemState as a worker saved
+// it. Pharos keeps only the latest state of each WorkItem, so when
+// Config.WorkItemStateHistory is on, workers also keep an append-only
+// history of versions, which ops can use to see what happened to an
+// item across requeues.
+type WorkItemStateVersion struct {
+	// Version is the version number, starting at 1 for the oldest
+	// version. It's assigned when the history is loaded.
+	Version         int    `json:"version"`
+	WorkItemId      int    `json:"work_item_id"`
+	WorkItemStateId int    `json:"work_item_state_id"`
+	Action          string `json:"action"`
+	// State is the full state, never a WorkItemStatePointer.
+	State string `json:"state"`
+	// SavedAt is when the worker saved this version.
+	SavedAt time.Time `json:"saved_at"`
+	// Node and Pid are the hostname and process id of the worker
+	// that saved this version.
+	Node string `json:"node"`
+	Pid  int    `json:"pid"`
+}
+
+// NewWorkItemStateVersion returns a version of state saved by the
+// worker with the specified hostname and pid at savedAt.
+func NewWorkItemStateVersion(state *WorkItemState, node string, pid int, savedAt time.Time) *WorkItemStateVersion {
+	return &WorkItemStateVersion{
+		WorkItemId:      state.WorkItemId,
+		WorkItemStateId: state.Id,
+		Action:          state.Action,
+		State:           state.State,
+		SavedAt:         savedAt.UTC(),
+		Node:            node,
+		Pid:             pid,
+	}
+}
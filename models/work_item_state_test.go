@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestNewWorkItemState(t *testing.T) {
@@ -31,3 +32,80 @@ func TestNewWorkItemState_IngestManifest(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, manifest.WorkItemId, newManifest.WorkItemId)
 }
+
+func TestWorkItemStateCompression(t *testing.T) {
+	manifest := models.NewIngestManifest()
+	manifest.WorkItemId = 999
+	state := models.NewWorkItemState(999, constants.ActionIngest, "")
+	require.Nil(t, state.SetStateFromIngestManifest(manifest))
+	original := state.State
+
+	require.Nil(t, state.Compress())
+	assert.Equal(t, models.WorkItemStateEncodingGzip, state.ContentEncoding)
+	assert.NotEqual(t, original, state.State)
+	assert.True(t, len(state.State) < len(original))
+	require.Nil(t, state.Compress())
+	jsonData, err := state.StateJson()
+	require.Nil(t, err)
+	assert.Equal(t, original, string(jsonData))
+
+	// Accessors decode compressed states transparently.
+	newManifest, err := state.IngestManifest()
+	require.Nil(t, err)
+	assert.Equal(t, 999, newManifest.WorkItemId)
+
+	require.Nil(t, state.Decompress())
+	assert.Equal(t, "", state.ContentEncoding)
+	assert.Equal(t, original, state.State)
+
+	glacierState := models.NewWorkItemState(999, constants.ActionGlacierRestore, `{"Deferred": ["test.edu/bag/file.txt"]}`)
+	require.Nil(t, glacierState.Compress())
+	restoreState, err := glacierState.GlacierRestoreState()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"test.edu/bag/file.txt"}, restoreState.Deferred)
+
+	state.ContentEncoding = "brotli"
+	_, err = state.StateJson()
+	assert.NotNil(t, err)
+	_, err = state.IngestManifest()
+	assert.NotNil(t, err)
+	state.ContentEncoding = models.WorkItemStateEncodingGzip
+	state.State = "not base64!"
+	_, err = state.StateJson()
+	assert.NotNil(t, err)
+}
+
+func TestWorkItemStatePointer(t *testing.T) {
+	state := models.NewWorkItemState(999, constants.ActionRestore, `{"key": "value"}`)
+	assert.Nil(t, state.StatePointer())
+
+	pointer := &models.WorkItemStatePointer{
+		Bucket: "aptrust.states",
+		Key:    "work_item_states/999/abc.json",
+		Sha256: "abc",
+		Size:   1234,
+	}
+	require.Nil(t, state.SetStatePointer(pointer))
+	assert.Equal(t, pointer, state.StatePointer())
+
+	// A state that just happens to mention offloaded_state
+	// is not a pointer.
+	state.State = `{"note": "offloaded_state"}`
+	assert.Nil(t, state.StatePointer())
+}
+
+func TestNewWorkItemStateVersion(t *testing.T) {
+	state := models.NewWorkItemState(999, constants.ActionIngest, `{"key": "value"}`)
+	state.Id = 55
+	savedAt := time.Date(2020, 6, 1, 12, 0, 0, 0, time.FixedZone("EDT", -4*3600))
+	version := models.NewWorkItemStateVersion(state, "worker1", 4321, savedAt)
+	assert.Equal(t, 0, version.Version)
+	assert.Equal(t, 999, version.WorkItemId)
+	assert.Equal(t, 55, version.WorkItemStateId)
+	assert.Equal(t, constants.ActionIngest, version.Action)
+	assert.Equal(t, `{"key": "value"}`, version.State)
+	assert.Equal(t, time.UTC, version.SavedAt.Location())
+	assert.True(t, savedAt.Equal(version.SavedAt))
+	assert.Equal(t, "worker1", version.Node)
+	assert.Equal(t, 4321, version.Pid)
+}
@@ -44,10 +44,39 @@ func TestWorkItemSerializeForPharos(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	expected := `{"action":"Ingest","aptrust_approver":null,"bag_date":"2104-07-02T12:00:00Z","bucket":"aptrust.receiving.ncsu.edu","date":"2014-09-10T12:00:00Z","etag":"12345","generic_file_identifier":"ncsu.edu/some_object/data/doc.pdf","inst_approver":null,"institution_id":324,"name":"Sample Document","needs_admin_review":false,"node":"","note":"so many!","object_identifier":"ncsu.edu/some_object","outcome":"happy day!","pid":0,"queued_at":null,"retry":true,"size":31337,"stage":"Store","stage_started_at":null,"status":"Success","user":""}`
+	expected := `{"action":"Ingest","aptrust_approver":null,"bag_date":"2104-07-02T12:00:00Z","bucket":"aptrust.receiving.ncsu.edu","date":"2014-09-10T12:00:00Z","embargo_approver":null,"etag":"12345","generic_file_identifier":"ncsu.edu/some_object/data/doc.pdf","inst_approver":null,"institution_id":324,"name":"Sample Document","needs_admin_review":false,"node":"","note":"so many!","object_identifier":"ncsu.edu/some_object","outcome":"happy day!","pid":0,"queued_at":null,"retry":true,"size":31337,"stage":"Store","stage_started_at":null,"status":"Success","user":""}`
 	assert.Equal(t, expected, string(bytes))
 }
 
+func TestWorkItemSerializePriorityForPharos(t *testing.T) {
+	workItem := SampleWorkItem()
+	workItem.Priority = constants.PriorityHigh
+	bytes, err := workItem.SerializeForPharos()
+	assert.Nil(t, err)
+	assert.Contains(t, string(bytes), `"priority":"high"`)
+}
+
+func TestWorkItemEffectivePriority(t *testing.T) {
+	workItem := SampleWorkItem()
+	assert.Equal(t, constants.PriorityNormal, workItem.EffectivePriority())
+	workItem.Priority = constants.PriorityLow
+	assert.Equal(t, constants.PriorityLow, workItem.EffectivePriority())
+}
+
+func TestWorkItemStatusForPharos(t *testing.T) {
+	workItem := SampleWorkItem()
+	status := workItem.StatusForPharos()
+	assert.Equal(t, 9000, status["id"])
+	assert.Equal(t, "Store", status["stage"])
+	assert.Equal(t, "Success", status["status"])
+	assert.Equal(t, "so many!", status["note"])
+	assert.Equal(t, true, status["retry"])
+	_, hasName := status["name"]
+	assert.False(t, hasName)
+	_, hasInstitution := status["institution_id"]
+	assert.False(t, hasInstitution)
+}
+
 func TestWorkItemHasBeenStored(t *testing.T) {
 	workItem := models.WorkItem{
 		Action: "Ingest",
@@ -231,6 +260,32 @@ func TestIsInProgress(t *testing.T) {
 	assert.True(t, item.IsInProgress())
 }
 
+func TestIsOrphanedOnThisNode(t *testing.T) {
+	item := SampleWorkItem()
+	item.Status = constants.StatusStarted
+	item.Node = ""
+	item.Pid = 0
+	assert.False(t, item.IsOrphanedOnThisNode())
+
+	// Started by this very process: not orphaned.
+	item.SetNodeAndPid()
+	assert.False(t, item.IsOrphanedOnThisNode())
+
+	// Started by an earlier process on this host: orphaned.
+	item.Pid = item.Pid + 1
+	assert.True(t, item.IsOrphanedOnThisNode())
+
+	// Started on another host: not ours to reconcile.
+	item.Node = "some.other.host.kom"
+	assert.False(t, item.IsOrphanedOnThisNode())
+
+	// Not in Started state.
+	item.SetNodeAndPid()
+	item.Pid = item.Pid + 1
+	item.Status = constants.StatusPending
+	assert.False(t, item.IsOrphanedOnThisNode())
+}
+
 func TestIsPastIngest(t *testing.T) {
 	item := SampleWorkItem()
 	item.Stage = constants.StageReceive
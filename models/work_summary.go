@@ -21,11 +21,16 @@ type WorkSummary struct {
 	// case, we should not try to reprocess the item.
 	ErrorIsFatal bool
 
-	// Errors is a list of strings describing errors that occurred
-	// during bag validation. Don't write to this. It's public so
-	// we can serialize it to/from JSON, but access is locked internally
-	// with a mutex. Hmm...
-	Errors []string
+	// Errors is a list of errors that occurred during processing.
+	// Don't write to this. It's public so we can serialize it to/from
+	// JSON, but access is locked internally with a mutex. Hmm...
+	Errors []*WorkError
+
+	// Warnings describe problems that don't stop processing, such
+	// as junk files in a bag that is otherwise valid, but that the
+	// depositor should know about. Like Errors, this is public so
+	// we can serialize it. Use AddWarning to add to it.
+	Warnings []string
 
 	// StartedAt describes when the attempt to read the bag started.
 	// If StartedAt.IsZero(), we have not yet attempted to read the
@@ -47,7 +52,19 @@ type WorkSummary struct {
 	// give up on transient errors. Just requeue and try again.
 	Retry bool
 
-	mutex *sync.RWMutex
+	// Stage is the name of the stage this summary describes, such as
+	// constants.StageFetch, if it's one of the Stages of another
+	// WorkSummary. It's empty otherwise.
+	Stage string `json:",omitempty"`
+
+	// Stages holds a sub-summary for each stage of the work, in the
+	// order the stages first started. Errors and warnings added to a
+	// stage are added to this summary as well, so HasErrors and the
+	// like cover all stages. Use StartStage to add to this.
+	Stages []*WorkSummary `json:",omitempty"`
+
+	parent *WorkSummary
+	mutex  *sync.RWMutex
 }
 
 func NewWorkSummary() *WorkSummary {
@@ -55,7 +72,8 @@ func NewWorkSummary() *WorkSummary {
 		Attempted:     false,
 		AttemptNumber: 0,
 		ErrorIsFatal:  false,
-		Errors:        make([]string, 0),
+		Errors:        make([]*WorkError, 0),
+		Warnings:      make([]string, 0),
 		StartedAt:     time.Time{},
 		FinishedAt:    time.Time{},
 		Retry:         true,
@@ -102,25 +120,91 @@ func (summary *WorkSummary) Succeeded() bool {
 // In rare cases, ingest server can encounter thousands of read
 // errors. If WorkSummary captures them all, the data becomes
 // too large to post to Pharos.
+//
+// AddError adds a non-retryable error with code ErrUnknown. Use
+// AddWorkError when you know more about the error.
 func (summary *WorkSummary) AddError(format string, a ...interface{}) {
-	if len(summary.Errors) > 29 {
-		return
+	summary.addWorkError(NewWorkError(ErrUnknown, "", false, format, a...))
+}
+
+// AddWorkError adds err to the list of errors. If err is a *WorkError,
+// it keeps its code, identifier and retryability. Any other error is
+// added as with AddError.
+func (summary *WorkSummary) AddWorkError(err error) {
+	workError, ok := err.(*WorkError)
+	if !ok {
+		workError = NewWorkError(ErrUnknown, "", false, "%s", err.Error())
 	}
+	summary.addWorkError(workError)
+}
+
+// addWorkError adds workError to this summary and, if this summary
+// is a stage, to its parent.
+func (summary *WorkSummary) addWorkError(workError *WorkError) {
 	summary.getMutex().Lock()
 	if len(summary.Errors) == 29 {
-		summary.Errors = append(summary.Errors, "Too many errors")
-	} else {
-		summary.Errors = append(summary.Errors, fmt.Sprintf(format, a...))
+		summary.Errors = append(summary.Errors, NewWorkError(ErrUnknown, "", false, "Too many errors"))
+	} else if len(summary.Errors) < 29 {
+		summary.Errors = append(summary.Errors, workError)
 	}
+	parent := summary.parent
 	summary.getMutex().Unlock()
+	if parent != nil {
+		parent.addWorkError(workError)
+	}
 }
 
+// AddWarning adds a warning built from format and a, as with
+// fmt.Sprintf. Like errors, warnings are capped at 30. Warnings
+// added to a stage are added to its parent as well.
+func (summary *WorkSummary) AddWarning(format string, a ...interface{}) {
+	warning := fmt.Sprintf(format, a...)
+	summary.getMutex().Lock()
+	if len(summary.Warnings) == 29 {
+		summary.Warnings = append(summary.Warnings, "Too many warnings")
+	} else if len(summary.Warnings) < 29 {
+		summary.Warnings = append(summary.Warnings, warning)
+	}
+	parent := summary.parent
+	summary.getMutex().Unlock()
+	if parent != nil {
+		parent.AddWarning("%s", warning)
+	}
+}
+
+// HasWarnings returns true if the summary has any warnings.
+func (summary *WorkSummary) HasWarnings() bool {
+	summary.getMutex().RLock()
+	defer summary.getMutex().RUnlock()
+	return len(summary.Warnings) > 0
+}
+
+// WarningMessages returns a copy of the warnings, in the order
+// they were added.
+func (summary *WorkSummary) WarningMessages() []string {
+	summary.getMutex().RLock()
+	defer summary.getMutex().RUnlock()
+	warnings := make([]string, len(summary.Warnings))
+	copy(warnings, summary.Warnings)
+	return warnings
+}
+
+// AllWarningsAsString returns all warnings, one per line.
+func (summary *WorkSummary) AllWarningsAsString() string {
+	return strings.Join(summary.WarningMessages(), "\n")
+}
+
+// ClearErrors clears the errors on this summary and all of its stages.
 func (summary *WorkSummary) ClearErrors() {
 	summary.getMutex().Lock()
 	summary.Errors = nil
 	summary.ErrorIsFatal = false
-	summary.Errors = make([]string, 0)
+	summary.Errors = make([]*WorkError, 0)
+	stages := summary.Stages
 	summary.getMutex().Unlock()
+	for _, stage := range stages {
+		stage.ClearErrors()
+	}
 }
 
 func (summary *WorkSummary) HasErrors() bool {
@@ -134,22 +218,121 @@ func (summary *WorkSummary) FirstError() string {
 	summary.getMutex().RLock()
 	firstError := ""
 	if len(summary.Errors) > 0 {
-		firstError = summary.Errors[0]
+		firstError = summary.Errors[0].Message
 	}
 	summary.getMutex().RUnlock()
 	return firstError
 }
 
 func (summary *WorkSummary) AllErrorsAsString() string {
+	return strings.Join(summary.ErrorMessages(), "\n")
+}
+
+// ErrorMessages returns the messages of all errors, in the order
+// they were added.
+func (summary *WorkSummary) ErrorMessages() []string {
 	summary.getMutex().RLock()
 	defer summary.getMutex().RUnlock()
-	if len(summary.Errors) > 0 {
-		return strings.Join(summary.Errors, "\n")
+	messages := make([]string, len(summary.Errors))
+	for i, workError := range summary.Errors {
+		messages[i] = workError.Message
+	}
+	return messages
+}
+
+// AllErrorsRetryable returns true if the summary has errors, all of
+// them are retryable, and none of them is fatal. Workers can use this
+// to decide whether to requeue an item or give up on it.
+func (summary *WorkSummary) AllErrorsRetryable() bool {
+	summary.getMutex().RLock()
+	defer summary.getMutex().RUnlock()
+	if summary.ErrorIsFatal || len(summary.Errors) == 0 {
+		return false
+	}
+	for _, workError := range summary.Errors {
+		if !workError.Retryable {
+			return false
+		}
+	}
+	return true
+}
+
+// StartStage starts the stage with the specified name, and returns
+// its sub-summary. If the stage already ran, as on an earlier attempt,
+// this clears its errors and restarts its clock. Errors and warnings
+// added to the sub-summary roll up to this summary. Call FinishStage
+// when the stage is done.
+func (summary *WorkSummary) StartStage(name string) *WorkSummary {
+	stage := summary.GetStage(name)
+	if stage == nil {
+		stage = NewWorkSummary()
+		stage.Stage = name
+		stage.parent = summary
+		summary.getMutex().Lock()
+		summary.Stages = append(summary.Stages, stage)
+		summary.getMutex().Unlock()
+	}
+	stage.ClearErrors()
+	stage.Retry = true
+	stage.Attempted = true
+	stage.AttemptNumber = summary.AttemptNumber
+	stage.FinishedAt = time.Time{}
+	stage.Start()
+	if !summary.Started() {
+		summary.Start()
+	}
+	return stage
+}
+
+// GetStage returns the sub-summary for the stage with the specified
+// name, or nil if that stage hasn't started.
+func (summary *WorkSummary) GetStage(name string) *WorkSummary {
+	summary.getMutex().Lock()
+	defer summary.getMutex().Unlock()
+	for _, stage := range summary.Stages {
+		if stage.Stage == name {
+			// Stages restored from JSON don't know their parent.
+			stage.parent = summary
+			return stage
+		}
+	}
+	return nil
+}
+
+// FinishStage finishes the stage with the specified name. If the
+// stage's errors are fatal or not worth retrying, that goes for this
+// summary as well. This is a no-op if the stage hasn't started.
+func (summary *WorkSummary) FinishStage(name string) {
+	stage := summary.GetStage(name)
+	if stage == nil {
+		return
+	}
+	stage.Finish()
+	if stage.ErrorIsFatal {
+		summary.ErrorIsFatal = true
+	}
+	if !stage.Retry {
+		summary.Retry = false
+	}
+}
+
+// StageReport describes how long each stage ran and how many errors
+// it had, in the order the stages started. For example,
+// "Validate: 2ms, 0 errors; Store: 1.5s, 1 errors". Stages that
+// haven't finished report their run time so far.
+func (summary *WorkSummary) StageReport() string {
+	summary.getMutex().RLock()
+	stages := summary.Stages
+	summary.getMutex().RUnlock()
+	report := make([]string, len(stages))
+	for i, stage := range stages {
+		report[i] = fmt.Sprintf("%s: %s, %d errors", stage.Stage,
+			stage.RunTime().Round(time.Millisecond), len(stage.ErrorMessages()))
 	}
-	return ""
+	return strings.Join(report, "; ")
 }
 
-// getMutex returns the mutex that guards the Errors list.
+// getMutex returns the mutex that guards the Errors and Warnings lists.
 // When we're restoring a WorkSummary from JSON, we have
 // no guarantee the constructor is called, so this function
 // ensures the mutex is present before anything tries to
@@ -1,8 +1,12 @@
 package models_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 	"time"
 )
@@ -75,11 +79,11 @@ func TestAddError(t *testing.T) {
 	s := models.NewWorkSummary()
 	s.AddError("First error is number %d", 1)
 	assert.Equal(t, 1, len(s.Errors))
-	assert.Equal(t, "First error is number 1", s.Errors[0])
+	assert.Equal(t, "First error is number 1", s.Errors[0].Message)
 
 	s.AddError("%s error is number %d", "Second", 2)
 	assert.Equal(t, 2, len(s.Errors))
-	assert.Equal(t, "Second error is number 2", s.Errors[1])
+	assert.Equal(t, "Second error is number 2", s.Errors[1].Message)
 }
 
 func TestAddError_Limit(t *testing.T) {
@@ -88,7 +92,7 @@ func TestAddError_Limit(t *testing.T) {
 		s.AddError("Err %d", i)
 	}
 	assert.Equal(t, len(s.Errors), 30)
-	assert.Equal(t, s.Errors[len(s.Errors)-1], "Too many errors")
+	assert.Equal(t, s.Errors[len(s.Errors)-1].Message, "Too many errors")
 }
 
 func TestHasErrors(t *testing.T) {
@@ -123,3 +127,141 @@ func TestAllErrorsAsString(t *testing.T) {
 	s.AddError("Second error is number %d", 2)
 	assert.Equal(t, "First error is number 1\nSecond error is number 2", s.AllErrorsAsString())
 }
+
+func TestAddWorkError(t *testing.T) {
+	s := models.NewWorkSummary()
+	s.AddWorkError(models.NewWorkError(models.ErrPharos, "test.edu/bag", true, "Timeout after %d seconds", 30))
+	s.AddWorkError(fmt.Errorf("Plain error"))
+	require.Equal(t, 2, len(s.Errors))
+	assert.Equal(t, models.ErrPharos, s.Errors[0].Code)
+	assert.Equal(t, "test.edu/bag", s.Errors[0].Identifier)
+	assert.True(t, s.Errors[0].Retryable)
+	assert.Equal(t, models.ErrUnknown, s.Errors[1].Code)
+	assert.False(t, s.Errors[1].Retryable)
+	assert.Equal(t, []string{"Timeout after 30 seconds", "Plain error"}, s.ErrorMessages())
+	assert.Equal(t, "Timeout after 30 seconds\nPlain error", s.AllErrorsAsString())
+}
+
+func TestAllErrorsRetryable(t *testing.T) {
+	s := models.NewWorkSummary()
+	assert.False(t, s.AllErrorsRetryable())
+
+	s.AddWorkError(models.NewWorkError(models.ErrS3, "", true, "Slow down"))
+	assert.True(t, s.AllErrorsRetryable())
+
+	s.ErrorIsFatal = true
+	assert.False(t, s.AllErrorsRetryable())
+
+	s.ErrorIsFatal = false
+	s.AddError("Bad data")
+	assert.False(t, s.AllErrorsRetryable())
+}
+
+func TestWorkSummaryErrorsFromJson(t *testing.T) {
+	// Old summaries have plain strings in place of WorkErrors.
+	data := `{"Errors":["Error one",{"code":"Glacier","message":"Error two","retryable":true,"identifier":"test.edu/bag/file.txt"}]}`
+	s := &models.WorkSummary{}
+	require.Nil(t, json.Unmarshal([]byte(data), s))
+	require.Equal(t, 2, len(s.Errors))
+	assert.Equal(t, &models.WorkError{Code: models.ErrUnknown, Message: "Error one"}, s.Errors[0])
+	assert.Equal(t, &models.WorkError{
+		Code:       models.ErrGlacier,
+		Message:    "Error two",
+		Retryable:  true,
+		Identifier: "test.edu/bag/file.txt",
+	}, s.Errors[1])
+
+	jsonBytes, err := json.Marshal(s)
+	require.Nil(t, err)
+	copied := &models.WorkSummary{}
+	require.Nil(t, json.Unmarshal(jsonBytes, copied))
+	assert.Equal(t, s.Errors, copied.Errors)
+}
+
+func TestWorkSummaryWarnings(t *testing.T) {
+	summary := models.NewWorkSummary()
+	assert.False(t, summary.HasWarnings())
+	summary.AddWarning("Bag contains '%s'", ".DS_Store")
+	summary.AddWarning("Tag '%s' is deprecated", "Bag-Count")
+	assert.True(t, summary.HasWarnings())
+	assert.Equal(t, "Bag contains '.DS_Store'\nTag 'Bag-Count' is deprecated",
+		summary.AllWarningsAsString())
+
+	// Warnings don't count as errors.
+	summary.Finish()
+	assert.True(t, summary.Succeeded())
+	summary.ClearErrors()
+	assert.Equal(t, 2, len(summary.WarningMessages()))
+
+	// Warnings are capped at 30.
+	for i := 0; i < 40; i++ {
+		summary.AddWarning("Warning %d", i)
+	}
+	warnings := summary.WarningMessages()
+	assert.Equal(t, 30, len(warnings))
+	assert.Equal(t, "Too many warnings", warnings[29])
+}
+
+func TestWorkSummaryStages(t *testing.T) {
+	s := models.NewWorkSummary()
+	s.AttemptNumber = 2
+	assert.Nil(t, s.GetStage(constants.StageValidate))
+
+	validate := s.StartStage(constants.StageValidate)
+	require.NotNil(t, validate)
+	assert.True(t, s.Started())
+	assert.True(t, validate.Started())
+	assert.EqualValues(t, 2, validate.AttemptNumber)
+	validate.AddWarning("Junk file %s", ".DS_Store")
+	s.FinishStage(constants.StageValidate)
+	assert.True(t, validate.Finished())
+	assert.False(t, s.Finished())
+
+	store := s.StartStage(constants.StageStore)
+	store.AddError("Copy failed")
+	store.ErrorIsFatal = true
+	s.FinishStage(constants.StageStore)
+
+	// Errors and warnings roll up to the parent.
+	assert.Equal(t, []string{"Copy failed"}, s.ErrorMessages())
+	assert.Equal(t, []string{"Junk file .DS_Store"}, s.WarningMessages())
+	assert.True(t, s.ErrorIsFatal)
+	assert.False(t, validate.HasErrors())
+	assert.Equal(t, store, s.GetStage(constants.StageStore))
+	assert.Equal(t, 2, len(s.Stages))
+	assert.Contains(t, s.StageReport(), "Validate: ")
+	assert.Contains(t, s.StageReport(), "Store: ")
+	assert.Contains(t, s.StageReport(), "1 errors")
+
+	// Restarting a stage clears its old errors.
+	s.ClearErrors()
+	assert.False(t, store.HasErrors())
+	store = s.StartStage(constants.StageStore)
+	assert.False(t, store.Finished())
+	assert.Equal(t, 2, len(s.Stages))
+}
+
+func TestWorkSummaryStagesFromJson(t *testing.T) {
+	s := models.NewWorkSummary()
+	s.StartStage(constants.StageFetch).AddError("Bag not found")
+	s.FinishStage(constants.StageFetch)
+	data, err := json.Marshal(s)
+	require.Nil(t, err)
+
+	restored := &models.WorkSummary{}
+	require.Nil(t, json.Unmarshal(data, restored))
+	require.Equal(t, 1, len(restored.Stages))
+	fetch := restored.GetStage(constants.StageFetch)
+	require.NotNil(t, fetch)
+	assert.Equal(t, "Bag not found", fetch.FirstError())
+	assert.True(t, fetch.Finished())
+
+	// The restored stage still rolls up to its parent.
+	fetch.AddError("Still not found")
+	assert.Equal(t, 2, len(restored.ErrorMessages()))
+
+	// Summaries without stages don't serialize them.
+	data, err = json.Marshal(models.NewWorkSummary())
+	require.Nil(t, err)
+	assert.NotContains(t, string(data), "Stages")
+}
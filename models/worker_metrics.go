@@ -0,0 +1,175 @@
+package models
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// WorkerMetrics collects processing metrics for an NSQ worker: how
+// many messages it has in flight, and how many it finished and
+// requeued in the current reporting interval, along with how long
+// it took to process them. The metrics publisher calls Snapshot
+// once per interval. It also keeps a list of the items currently in
+// flight, for the admin API. This structure uses a mutex, so it's
+// safe to share across goroutines.
+type WorkerMetrics struct {
+	inFlight       int64
+	finished       int64
+	requeued       int64
+	processingTime time.Duration
+	maxProcessing  time.Duration
+	recentAverage  time.Duration
+	items          map[*InFlightItem]bool
+	mutex          *sync.Mutex
+}
+
+// InFlightItem describes a message a worker is currently processing.
+type InFlightItem struct {
+	// MessageId is the NSQ message id.
+	MessageId string
+	// Body is the body of the NSQ message, which is usually
+	// a WorkItem id or a JSON QueueMessage.
+	Body string
+	// Attempts is the number of times NSQ has delivered
+	// this message.
+	Attempts uint16
+	// StartedAt is when the worker received the message.
+	StartedAt time.Time
+}
+
+// WorkerMetricsSnapshot describes a worker's activity during a
+// single reporting interval.
+type WorkerMetricsSnapshot struct {
+	// InFlight is the number of messages the worker was processing
+	// at the end of the interval.
+	InFlight int64
+
+	// Finished is the number of messages the worker finished
+	// during the interval.
+	Finished int64
+
+	// Requeued is the number of messages the worker requeued
+	// during the interval.
+	Requeued int64
+
+	// AverageProcessingTime is the mean time between receiving
+	// and finishing or requeueing a message, for all messages
+	// finished or requeued during the interval.
+	AverageProcessingTime time.Duration
+
+	// MaxProcessingTime is the longest time any message
+	// finished or requeued during the interval took.
+	MaxProcessingTime time.Duration
+}
+
+// NewWorkerMetrics creates a new WorkerMetrics object.
+func NewWorkerMetrics() *WorkerMetrics {
+	return &WorkerMetrics{
+		items: make(map[*InFlightItem]bool),
+		mutex: &sync.Mutex{},
+	}
+}
+
+// MessageStarted records that the worker received a message.
+func (metrics *WorkerMetrics) MessageStarted() {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.inFlight += 1
+}
+
+// MessageFinished records that the worker finished a message,
+// which took the specified time to process.
+func (metrics *WorkerMetrics) MessageFinished(processingTime time.Duration) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.finished += 1
+	metrics.messageDone(processingTime)
+}
+
+// MessageRequeued records that the worker requeued a message,
+// after working on it for the specified time.
+func (metrics *WorkerMetrics) MessageRequeued(processingTime time.Duration) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.requeued += 1
+	metrics.messageDone(processingTime)
+}
+
+// ItemStarted adds item to the list of items in flight.
+func (metrics *WorkerMetrics) ItemStarted(item *InFlightItem) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.items[item] = true
+}
+
+// ItemDone removes item from the list of items in flight.
+func (metrics *WorkerMetrics) ItemDone(item *InFlightItem) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	delete(metrics.items, item)
+}
+
+// InFlightItems returns copies of the items currently in flight,
+// oldest first.
+func (metrics *WorkerMetrics) InFlightItems() []InFlightItem {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	items := make([]InFlightItem, 0, len(metrics.items))
+	for item := range metrics.items {
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].StartedAt.Before(items[j].StartedAt)
+	})
+	return items
+}
+
+// messageDone updates in-flight count and processing times.
+// Caller must hold the mutex.
+func (metrics *WorkerMetrics) messageDone(processingTime time.Duration) {
+	if metrics.inFlight > 0 {
+		metrics.inFlight -= 1
+	}
+	metrics.processingTime += processingTime
+	if processingTime > metrics.maxProcessing {
+		metrics.maxProcessing = processingTime
+	}
+	if metrics.recentAverage == 0 {
+		metrics.recentAverage = processingTime
+	} else {
+		metrics.recentAverage += (processingTime - metrics.recentAverage) / 5
+	}
+}
+
+// RecentProcessingTime returns a moving average of the time it took
+// to process recent messages, giving more weight to the most recent.
+// Unlike Snapshot, this does not reset anything. It returns zero if
+// the worker hasn't finished or requeued any messages yet.
+func (metrics *WorkerMetrics) RecentProcessingTime() time.Duration {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	return metrics.recentAverage
+}
+
+// Snapshot returns the metrics for the current interval, and resets
+// the finished and requeued counts and processing times to start the
+// next interval. The in-flight count carries over.
+func (metrics *WorkerMetrics) Snapshot() *WorkerMetricsSnapshot {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	snapshot := &WorkerMetricsSnapshot{
+		InFlight:          metrics.inFlight,
+		Finished:          metrics.finished,
+		Requeued:          metrics.requeued,
+		MaxProcessingTime: metrics.maxProcessing,
+	}
+	if done := metrics.finished + metrics.requeued; done > 0 {
+		snapshot.AverageProcessingTime = metrics.processingTime / time.Duration(done)
+	}
+	metrics.finished = 0
+	metrics.requeued = 0
+	metrics.processingTime = 0
+	metrics.maxProcessing = 0
+	return snapshot
+}
@@ -0,0 +1,80 @@
+package models_test
+
+import (
+	"github.com/APTrust/exchange/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWorkerMetrics(t *testing.T) {
+	metrics := models.NewWorkerMetrics()
+	snapshot := metrics.Snapshot()
+	assert.Equal(t, &models.WorkerMetricsSnapshot{}, snapshot)
+
+	for i := 0; i < 4; i++ {
+		metrics.MessageStarted()
+	}
+	metrics.MessageFinished(2 * time.Second)
+	metrics.MessageFinished(6 * time.Second)
+	metrics.MessageRequeued(1 * time.Second)
+
+	snapshot = metrics.Snapshot()
+	assert.EqualValues(t, 1, snapshot.InFlight)
+	assert.EqualValues(t, 2, snapshot.Finished)
+	assert.EqualValues(t, 1, snapshot.Requeued)
+	assert.Equal(t, 3*time.Second, snapshot.AverageProcessingTime)
+	assert.Equal(t, 6*time.Second, snapshot.MaxProcessingTime)
+
+	// Counts reset for the next interval, but in-flight carries over.
+	snapshot = metrics.Snapshot()
+	assert.EqualValues(t, 1, snapshot.InFlight)
+	assert.EqualValues(t, 0, snapshot.Finished)
+	assert.EqualValues(t, 0, snapshot.Requeued)
+	assert.EqualValues(t, 0, snapshot.AverageProcessingTime)
+	assert.EqualValues(t, 0, snapshot.MaxProcessingTime)
+
+	// In-flight count should not go negative.
+	metrics.MessageFinished(time.Second)
+	metrics.MessageFinished(time.Second)
+	assert.EqualValues(t, 0, metrics.Snapshot().InFlight)
+}
+
+func TestWorkerMetricsInFlightItems(t *testing.T) {
+	metrics := models.NewWorkerMetrics()
+	assert.Empty(t, metrics.InFlightItems())
+
+	now := time.Now().UTC()
+	newer := &models.InFlightItem{MessageId: "2", Body: "200", StartedAt: now}
+	older := &models.InFlightItem{MessageId: "1", Body: "100", StartedAt: now.Add(-time.Minute)}
+	metrics.ItemStarted(newer)
+	metrics.ItemStarted(older)
+
+	items := metrics.InFlightItems()
+	assert.Equal(t, 2, len(items))
+	assert.Equal(t, "100", items[0].Body)
+	assert.Equal(t, "200", items[1].Body)
+
+	metrics.ItemDone(older)
+	items = metrics.InFlightItems()
+	assert.Equal(t, 1, len(items))
+	assert.Equal(t, "200", items[0].Body)
+
+	// Removing an item that's not there is harmless.
+	metrics.ItemDone(older)
+	assert.Equal(t, 1, len(metrics.InFlightItems()))
+}
+
+func TestWorkerMetricsRecentProcessingTime(t *testing.T) {
+	metrics := models.NewWorkerMetrics()
+	assert.Equal(t, time.Duration(0), metrics.RecentProcessingTime())
+	metrics.MessageStarted()
+	metrics.MessageFinished(10 * time.Second)
+	assert.Equal(t, 10*time.Second, metrics.RecentProcessingTime())
+	metrics.MessageStarted()
+	metrics.MessageRequeued(20 * time.Second)
+	assert.Equal(t, 12*time.Second, metrics.RecentProcessingTime())
+	// Snapshot doesn't reset it.
+	metrics.Snapshot()
+	assert.Equal(t, 12*time.Second, metrics.RecentProcessingTime())
+}
@@ -0,0 +1,132 @@
+package network
+
+import (
+	"github.com/APTrust/exchange/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/nsqio/nsq/nsqd"
+	"sort"
+	"time"
+)
+
+// CloudWatchPublisher publishes worker metrics to AWS CloudWatch,
+// so that autoscaling policies can respond to queue backlog and
+// processing latency. Every metric carries the same dimensions,
+// which typically identify the worker and host.
+type CloudWatchPublisher struct {
+	// Namespace is the CloudWatch namespace, e.g. "APTrust/Exchange".
+	Namespace string
+
+	// Dimensions are attached to every metric we publish.
+	Dimensions []*cloudwatch.Dimension
+
+	// Client is the CloudWatch client. NewCloudWatchPublisher sets
+	// this up, but tests may replace it with a mock.
+	Client cloudwatchiface.CloudWatchAPI
+}
+
+// NewCloudWatchPublisher returns a new CloudWatchPublisher that
+// publishes to the specified region and namespace. Param dimensions
+// maps dimension names to values, e.g. {"Topic": "fetch_topic"}.
+// This uses the AWS credentials in the environment.
+func NewCloudWatchPublisher(region, namespace string, dimensions map[string]string) (*CloudWatchPublisher, error) {
+	_session, err := GetS3Session(region, "", "")
+	if err != nil {
+		return nil, err
+	}
+	// Sort dimension names so that metrics always have the
+	// same dimensions in the same order.
+	names := make([]string, 0, len(dimensions))
+	for name := range dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cwDimensions := make([]*cloudwatch.Dimension, len(names))
+	for i, name := range names {
+		cwDimensions[i] = &cloudwatch.Dimension{
+			Name:  aws.String(name),
+			Value: aws.String(dimensions[name]),
+		}
+	}
+	return &CloudWatchPublisher{
+		Namespace:  namespace,
+		Dimensions: cwDimensions,
+		Client:     cloudwatch.New(_session),
+	}, nil
+}
+
+// MetricData converts a WorkerMetricsSnapshot and the stats for the
+// worker's NSQ channel into CloudWatch metric data. Param queue may
+// be nil if the channel stats are not available, in which case the
+// queue metrics are omitted.
+func (publisher *CloudWatchPublisher) MetricData(snapshot *models.WorkerMetricsSnapshot, queue *nsqd.ChannelStats, timestamp time.Time) []*cloudwatch.MetricDatum {
+	data := []*cloudwatch.MetricDatum{
+		publisher.datum("MessagesInFlight", float64(snapshot.InFlight), cloudwatch.StandardUnitCount, timestamp),
+		publisher.datum("MessagesFinished", float64(snapshot.Finished), cloudwatch.StandardUnitCount, timestamp),
+		publisher.datum("MessagesRequeued", float64(snapshot.Requeued), cloudwatch.StandardUnitCount, timestamp),
+		publisher.datum("AverageProcessingTime", snapshot.AverageProcessingTime.Seconds(), cloudwatch.StandardUnitSeconds, timestamp),
+		publisher.datum("MaxProcessingTime", snapshot.MaxProcessingTime.Seconds(), cloudwatch.StandardUnitSeconds, timestamp),
+	}
+	if queue != nil {
+		data = append(data,
+			publisher.datum("QueueDepth", float64(queue.Depth), cloudwatch.StandardUnitCount, timestamp),
+			publisher.datum("QueueDeferred", float64(queue.DeferredCount), cloudwatch.StandardUnitCount, timestamp),
+			publisher.datum("QueueInFlight", float64(queue.InFlightCount), cloudwatch.StandardUnitCount, timestamp))
+	}
+	return data
+}
+
+// Publish sends the metrics in snapshot and queue to CloudWatch.
+// See MetricData.
+func (publisher *CloudWatchPublisher) Publish(snapshot *models.WorkerMetricsSnapshot, queue *nsqd.ChannelStats) error {
+	_, err := publisher.Client.PutMetricData(&cloudwatch.PutMetricDataInput{
+		Namespace:  aws.String(publisher.Namespace),
+		MetricData: publisher.MetricData(snapshot, queue, time.Now().UTC()),
+	})
+	return err
+}
+
+// PharosMetricData converts a snapshot of a worker's requests to
+// Pharos into CloudWatch metric data. The data cover all endpoints
+// together, since per-endpoint metrics would multiply the number of
+// CloudWatch metrics. Returns nil if the snapshot is empty, so idle
+// workers don't report zero latency.
+func (publisher *CloudWatchPublisher) PharosMetricData(snapshot []*models.PharosEndpointMetrics, timestamp time.Time) []*cloudwatch.MetricDatum {
+	if len(snapshot) == 0 {
+		return nil
+	}
+	totals := models.PharosMetricsTotals(snapshot)
+	return []*cloudwatch.MetricDatum{
+		publisher.datum("PharosRequests", float64(totals.Requests), cloudwatch.StandardUnitCount, timestamp),
+		publisher.datum("PharosClientErrors", float64(totals.ClientErrors), cloudwatch.StandardUnitCount, timestamp),
+		publisher.datum("PharosServerErrors", float64(totals.ServerErrors), cloudwatch.StandardUnitCount, timestamp),
+		publisher.datum("PharosAverageLatency", totals.AverageTime().Seconds(), cloudwatch.StandardUnitSeconds, timestamp),
+		publisher.datum("PharosMaxLatency", totals.MaxTime.Seconds(), cloudwatch.StandardUnitSeconds, timestamp),
+	}
+}
+
+// PublishPharosMetrics sends the Pharos request metrics in snapshot
+// to CloudWatch. See PharosMetricData. This is a no-op if snapshot
+// is empty.
+func (publisher *CloudWatchPublisher) PublishPharosMetrics(snapshot []*models.PharosEndpointMetrics) error {
+	data := publisher.PharosMetricData(snapshot, time.Now().UTC())
+	if len(data) == 0 {
+		return nil
+	}
+	_, err := publisher.Client.PutMetricData(&cloudwatch.PutMetricDataInput{
+		Namespace:  aws.String(publisher.Namespace),
+		MetricData: data,
+	})
+	return err
+}
+
+func (publisher *CloudWatchPublisher) datum(name string, value float64, unit string, timestamp time.Time) *cloudwatch.MetricDatum {
+	return &cloudwatch.MetricDatum{
+		MetricName: aws.String(name),
+		Dimensions: publisher.Dimensions,
+		Value:      aws.Float64(value),
+		Unit:       aws.String(unit),
+		Timestamp:  aws.Time(timestamp),
+	}
+}
@@ -0,0 +1,118 @@
+package network_test
+
+import (
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/nsqio/nsq/nsqd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type mockCloudWatch struct {
+	cloudwatchiface.CloudWatchAPI
+	inputs []*cloudwatch.PutMetricDataInput
+}
+
+func (mock *mockCloudWatch) PutMetricData(input *cloudwatch.PutMetricDataInput) (*cloudwatch.PutMetricDataOutput, error) {
+	mock.inputs = append(mock.inputs, input)
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func getCloudWatchPublisher(t *testing.T) *network.CloudWatchPublisher {
+	publisher, err := network.NewCloudWatchPublisher("us-east-1", "APTrust/Test",
+		map[string]string{"Topic": "fetch_topic", "Host": "worker1"})
+	require.Nil(t, err)
+	require.NotNil(t, publisher)
+	return publisher
+}
+
+func getMetricsSnapshot() *models.WorkerMetricsSnapshot {
+	return &models.WorkerMetricsSnapshot{
+		InFlight:              3,
+		Finished:              10,
+		Requeued:              2,
+		AverageProcessingTime: 1500 * time.Millisecond,
+		MaxProcessingTime:     4 * time.Second,
+	}
+}
+
+func TestNewCloudWatchPublisher(t *testing.T) {
+	publisher := getCloudWatchPublisher(t)
+	assert.Equal(t, "APTrust/Test", publisher.Namespace)
+	require.Equal(t, 2, len(publisher.Dimensions))
+	// Dimensions are sorted by name.
+	assert.Equal(t, "Host", *publisher.Dimensions[0].Name)
+	assert.Equal(t, "worker1", *publisher.Dimensions[0].Value)
+	assert.Equal(t, "Topic", *publisher.Dimensions[1].Name)
+	assert.Equal(t, "fetch_topic", *publisher.Dimensions[1].Value)
+	assert.NotNil(t, publisher.Client)
+}
+
+func TestCloudWatchPublisherMetricData(t *testing.T) {
+	publisher := getCloudWatchPublisher(t)
+	now := time.Now().UTC()
+
+	data := publisher.MetricData(getMetricsSnapshot(), nil, now)
+	require.Equal(t, 5, len(data))
+	assert.Equal(t, "MessagesInFlight", *data[0].MetricName)
+	assert.EqualValues(t, 3, *data[0].Value)
+	assert.Equal(t, cloudwatch.StandardUnitCount, *data[0].Unit)
+	assert.Equal(t, "AverageProcessingTime", *data[3].MetricName)
+	assert.EqualValues(t, 1.5, *data[3].Value)
+	assert.Equal(t, cloudwatch.StandardUnitSeconds, *data[3].Unit)
+	assert.Equal(t, now, *data[3].Timestamp)
+	assert.Equal(t, publisher.Dimensions, data[3].Dimensions)
+
+	queue := &nsqd.ChannelStats{Depth: 250, DeferredCount: 7, InFlightCount: 12}
+	data = publisher.MetricData(getMetricsSnapshot(), queue, now)
+	require.Equal(t, 8, len(data))
+	assert.Equal(t, "QueueDepth", *data[5].MetricName)
+	assert.EqualValues(t, 250, *data[5].Value)
+	assert.Equal(t, "QueueDeferred", *data[6].MetricName)
+	assert.EqualValues(t, 7, *data[6].Value)
+	assert.Equal(t, "QueueInFlight", *data[7].MetricName)
+	assert.EqualValues(t, 12, *data[7].Value)
+}
+
+func TestCloudWatchPublisherPublish(t *testing.T) {
+	publisher := getCloudWatchPublisher(t)
+	mock := &mockCloudWatch{}
+	publisher.Client = mock
+	err := publisher.Publish(getMetricsSnapshot(), &nsqd.ChannelStats{Depth: 1})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(mock.inputs))
+	assert.Equal(t, "APTrust/Test", *mock.inputs[0].Namespace)
+	assert.Equal(t, 8, len(mock.inputs[0].MetricData))
+}
+
+func TestCloudWatchPublisherPharosMetricData(t *testing.T) {
+	publisher := getCloudWatchPublisher(t)
+	now := time.Now().UTC()
+	assert.Nil(t, publisher.PharosMetricData(nil, now))
+
+	metrics := models.NewPharosMetrics()
+	metrics.ObservePharosRequest("GET", "/api/v2/items/:id", 200, time.Second)
+	metrics.ObservePharosRequest("PUT", "/api/v2/items/:id", 500, 3*time.Second)
+	data := publisher.PharosMetricData(metrics.Snapshot(), now)
+	require.Equal(t, 5, len(data))
+	assert.Equal(t, "PharosRequests", *data[0].MetricName)
+	assert.EqualValues(t, 2, *data[0].Value)
+	assert.Equal(t, "PharosServerErrors", *data[2].MetricName)
+	assert.EqualValues(t, 1, *data[2].Value)
+	assert.Equal(t, "PharosAverageLatency", *data[3].MetricName)
+	assert.EqualValues(t, 2, *data[3].Value)
+	assert.Equal(t, "PharosMaxLatency", *data[4].MetricName)
+	assert.EqualValues(t, 3, *data[4].Value)
+
+	mock := &mockCloudWatch{}
+	publisher.Client = mock
+	require.Nil(t, publisher.PublishPharosMetrics(nil))
+	assert.Empty(t, mock.inputs)
+	metrics.ObservePharosRequest("GET", "/api/v2/files/:id", 200, time.Second)
+	require.Nil(t, publisher.PublishPharosMetrics(metrics.Snapshot()))
+	assert.Equal(t, 1, len(mock.inputs))
+}
@@ -0,0 +1,202 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default URLs for the Globus Auth and Transfer APIs.
+const (
+	GlobusAuthURL       = "https://auth.globus.org"
+	GlobusTransferURL   = "https://transfer.api.globus.org/v0.10"
+	GlobusTransferScope = "urn:globus:auth:scope:transfer.api.globus.org:all"
+)
+
+// Statuses of a Globus transfer task.
+const (
+	GlobusTaskActive    = "ACTIVE"
+	GlobusTaskInactive  = "INACTIVE"
+	GlobusTaskSucceeded = "SUCCEEDED"
+	GlobusTaskFailed    = "FAILED"
+)
+
+// GlobusTask describes the status of a Globus transfer task.
+type GlobusTask struct {
+	TaskId           string `json:"task_id"`
+	Status           string `json:"status"`
+	NiceStatus       string `json:"nice_status"`
+	FilesTransferred int    `json:"files_transferred"`
+	BytesTransferred int64  `json:"bytes_transferred"`
+}
+
+// GlobusTransferClient submits transfers between Globus collections
+// and checks on their progress, using the Globus Transfer API. It
+// authenticates as a Globus Auth confidential client, and fetches a
+// new access token when the old one expires.
+type GlobusTransferClient struct {
+	// AuthURL is the base URL of the Globus Auth API.
+	AuthURL string
+	// TransferURL is the base URL of the Globus Transfer API,
+	// including the version.
+	TransferURL  string
+	ClientId     string
+	ClientSecret string
+	Client       *http.Client
+
+	token        string
+	tokenExpires time.Time
+	mutex        sync.Mutex
+}
+
+// NewGlobusTransferClient returns a client that talks to the Globus
+// APIs as the confidential client with the specified id and secret.
+func NewGlobusTransferClient(clientId, clientSecret string) *GlobusTransferClient {
+	return &GlobusTransferClient{
+		AuthURL:      GlobusAuthURL,
+		TransferURL:  GlobusTransferURL,
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		Client:       &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// SubmitTransfer asks Globus to copy sourcePath on the source collection
+// to destPath on the destination collection, and returns the id of the
+// transfer task. Globus verifies checksums after the transfer, and fails
+// the task if it has not finished by deadline.
+func (client *GlobusTransferClient) SubmitTransfer(sourceEndpoint, sourcePath, destEndpoint, destPath, label string, deadline time.Time) (string, error) {
+	submissionId := struct {
+		Value string `json:"value"`
+	}{}
+	err := client.doRequest("GET", "/submission_id", nil, &submissionId)
+	if err != nil {
+		return "", err
+	}
+	transfer := map[string]interface{}{
+		"DATA_TYPE":            "transfer",
+		"submission_id":        submissionId.Value,
+		"source_endpoint":      sourceEndpoint,
+		"destination_endpoint": destEndpoint,
+		"label":                label,
+		"verify_checksum":      true,
+		"deadline":             deadline.UTC().Format(time.RFC3339),
+		"DATA": []map[string]string{
+			{
+				"DATA_TYPE":        "transfer_item",
+				"source_path":      sourcePath,
+				"destination_path": destPath,
+			},
+		},
+	}
+	data, err := json.Marshal(transfer)
+	if err != nil {
+		return "", err
+	}
+	result := struct {
+		Code   string `json:"code"`
+		TaskId string `json:"task_id"`
+	}{}
+	err = client.doRequest("POST", "/transfer", bytes.NewReader(data), &result)
+	if err != nil {
+		return "", err
+	}
+	if result.TaskId == "" {
+		return "", fmt.Errorf("Globus did not return a task id for transfer of %s (code %s)",
+			sourcePath, result.Code)
+	}
+	return result.TaskId, nil
+}
+
+// TaskStatus returns the current status of the transfer task.
+func (client *GlobusTransferClient) TaskStatus(taskId string) (*GlobusTask, error) {
+	task := &GlobusTask{}
+	err := client.doRequest("GET", "/task/"+url.PathEscape(taskId), nil, task)
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// doRequest sends a request to the Transfer API and decodes the JSON
+// response into result.
+func (client *GlobusTransferClient) doRequest(method, path string, body io.Reader, result interface{}) error {
+	token, err := client.accessToken()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(client.TransferURL, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Globus %s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Cannot read Globus response to %s %s: %v", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Globus returned status %d for %s %s: %s",
+			resp.StatusCode, method, path, string(data))
+	}
+	if err = json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("Cannot parse Globus response to %s %s: %v", method, path, err)
+	}
+	return nil
+}
+
+// accessToken returns a Transfer API access token, requesting a new one
+// from Globus Auth if we have none or ours is about to expire.
+func (client *GlobusTransferClient) accessToken() (string, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.token != "" && time.Now().Add(time.Minute).Before(client.tokenExpires) {
+		return client.token, nil
+	}
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("scope", GlobusTransferScope)
+	req, err := http.NewRequest("POST", strings.TrimSuffix(client.AuthURL, "/")+"/v2/oauth2/token",
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(client.ClientId, client.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Cannot get Globus access token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Globus Auth returned status %d for token request", resp.StatusCode)
+	}
+	tokenResponse := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return "", fmt.Errorf("Cannot parse Globus token response: %v", err)
+	}
+	if tokenResponse.AccessToken == "" {
+		return "", fmt.Errorf("Globus Auth returned an empty access token")
+	}
+	client.token = tokenResponse.AccessToken
+	client.tokenExpires = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+	return client.token, nil
+}
@@ -0,0 +1,103 @@
+package network_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// globusTestServer fakes the Globus Auth and Transfer APIs, and
+// records the transfer requests it receives.
+func globusTestServer(t *testing.T, transfers *[]map[string]interface{}, tokenRequests *int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		*tokenRequests += 1
+		clientId, secret, ok := r.BasicAuth()
+		if !ok || clientId != "client-id" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "client_credentials", r.FormValue("grant_type"))
+		fmt.Fprint(w, `{"access_token": "token-1", "expires_in": 3600}`)
+	})
+	mux.HandleFunc("/transfer/submission_id", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token-1", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"value": "submission-1"}`)
+	})
+	mux.HandleFunc("/transfer/transfer", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		transfer := make(map[string]interface{})
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&transfer))
+		*transfers = append(*transfers, transfer)
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"code": "Accepted", "task_id": "task-1"}`)
+	})
+	mux.HandleFunc("/transfer/task/task-1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"task_id": "task-1", "status": "SUCCEEDED", "nice_status": "OK", `+
+			`"files_transferred": 1, "bytes_transferred": 2048}`)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestGlobusTransferClient(t *testing.T) {
+	transfers := make([]map[string]interface{}, 0)
+	tokenRequests := 0
+	server := globusTestServer(t, &transfers, &tokenRequests)
+	defer server.Close()
+
+	client := network.NewGlobusTransferClient("client-id", "secret")
+	client.AuthURL = server.URL
+	client.TransferURL = server.URL + "/transfer"
+
+	deadline := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	taskId, err := client.SubmitTransfer("source-ep", "/restore/test.edu/bag.tar",
+		"dest-ep", "/incoming/bag.tar", "Restore test.edu/bag", deadline)
+	require.Nil(t, err)
+	assert.Equal(t, "task-1", taskId)
+	require.Equal(t, 1, len(transfers))
+	transfer := transfers[0]
+	assert.Equal(t, "transfer", transfer["DATA_TYPE"])
+	assert.Equal(t, "submission-1", transfer["submission_id"])
+	assert.Equal(t, "source-ep", transfer["source_endpoint"])
+	assert.Equal(t, "dest-ep", transfer["destination_endpoint"])
+	assert.Equal(t, true, transfer["verify_checksum"])
+	assert.Equal(t, "2020-01-02T03:04:05Z", transfer["deadline"])
+	items := transfer["DATA"].([]interface{})
+	require.Equal(t, 1, len(items))
+	item := items[0].(map[string]interface{})
+	assert.Equal(t, "/restore/test.edu/bag.tar", item["source_path"])
+	assert.Equal(t, "/incoming/bag.tar", item["destination_path"])
+
+	task, err := client.TaskStatus("task-1")
+	require.Nil(t, err)
+	assert.Equal(t, network.GlobusTaskSucceeded, task.Status)
+	assert.Equal(t, int64(2048), task.BytesTransferred)
+
+	// Client should reuse its token until it expires.
+	assert.Equal(t, 1, tokenRequests)
+
+	_, err = client.TaskStatus("no-such-task")
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Globus returned status 404")
+}
+
+func TestGlobusTransferClientBadCredentials(t *testing.T) {
+	transfers := make([]map[string]interface{}, 0)
+	tokenRequests := 0
+	server := globusTestServer(t, &transfers, &tokenRequests)
+	defer server.Close()
+
+	client := network.NewGlobusTransferClient("client-id", "wrong")
+	client.AuthURL = server.URL
+	client.TransferURL = server.URL + "/transfer"
+	_, err := client.SubmitTransfer("source-ep", "/a", "dest-ep", "/b", "label", time.Now())
+	require.NotNil(t, err)
+	assert.Equal(t, "Globus Auth returned status 401 for token request", err.Error())
+	assert.Empty(t, transfers)
+}
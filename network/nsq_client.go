@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"github.com/APTrust/exchange/models"
 	"github.com/nsqio/nsq/nsqd"
 	"io/ioutil"
 	"net/http"
-	"strconv"
+	"sync"
+	"time"
 )
 
 // NSQStatsData contains the important info returned by a call
@@ -20,10 +22,28 @@ type NSQStatsData struct {
 	Topics    []nsqd.TopicStats `json:"topics"`
 }
 
+// ChannelStats returns the stats for the specified channel of the
+// specified topic, or nil if NSQ has no such topic or channel.
+func (data *NSQStatsData) ChannelStats(topicName, channelName string) *nsqd.ChannelStats {
+	for _, topic := range data.Topics {
+		if topic.TopicName != topicName {
+			continue
+		}
+		for i := range topic.Channels {
+			if topic.Channels[i].ChannelName == channelName {
+				return &topic.Channels[i]
+			}
+		}
+	}
+	return nil
+}
+
 // NSQClient provides methods for queueing items and querying
-// stats from the NSQ server at URL.
+// stats from the NSQ server at URL. Use SetURL to change the URL
+// while the client is in use.
 type NSQClient struct {
-	URL string
+	URL   string
+	mutex sync.RWMutex
 }
 
 // NewNSQClient returns a new NSQ client that will connect to the NSQ
@@ -39,18 +59,71 @@ func NewNSQClient(url string) *NSQClient {
 	return &NSQClient{URL: url}
 }
 
+// SetURL points the client at the NSQ server at url. It's safe to
+// call while other goroutines are using the client.
+func (client *NSQClient) SetURL(url string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.URL = url
+}
+
+// url returns the URL of the NSQ server.
+func (client *NSQClient) url() string {
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+	return client.URL
+}
+
 // Enqueue posts data to NSQ, which essentially means putting it into a work
 // topic. Param topic is the topic under which you want to queue something.
 // For example, prepare_topic, fixity_topic, etc.
 // Param workItemId is the id of the WorkItem record in Pharos we want to queue.
+// If you have the WorkItem, use EnqueueWorkItem instead, so the message
+// includes the WorkItem's action.
 func (client *NSQClient) Enqueue(topic string, workItemId int) error {
-	idAsString := strconv.Itoa(workItemId)
-	return client.EnqueueString(topic, idAsString)
+	return client.EnqueueMessage(topic, models.NewQueueMessage(workItemId, ""))
+}
+
+// EnqueueWorkItem posts a QueueMessage for the specified WorkItem
+// to the specified NSQ topic.
+func (client *NSQClient) EnqueueWorkItem(topic string, workItem *models.WorkItem) error {
+	return client.EnqueueMessage(topic, models.NewQueueMessage(workItem.Id, workItem.Action))
+}
+
+// EnqueueWorkItemDeferred posts a QueueMessage for the specified
+// WorkItem to the specified NSQ topic. NSQ holds the message for delay
+// before delivering it. See EnqueueStringDeferred.
+func (client *NSQClient) EnqueueWorkItemDeferred(topic string, workItem *models.WorkItem, delay time.Duration) error {
+	body, err := models.NewQueueMessage(workItem.Id, workItem.Action).ToJson()
+	if err != nil {
+		return err
+	}
+	return client.EnqueueStringDeferred(topic, body, delay)
+}
+
+// EnqueueMessage posts a QueueMessage to the specified NSQ topic.
+func (client *NSQClient) EnqueueMessage(topic string, queueMessage *models.QueueMessage) error {
+	body, err := queueMessage.ToJson()
+	if err != nil {
+		return err
+	}
+	return client.EnqueueString(topic, body)
 }
 
 // EnqueueString posts string data to the specified NSQ topic
 func (client *NSQClient) EnqueueString(topic string, data string) error {
-	url := fmt.Sprintf("%s/pub?topic=%s", client.URL, topic)
+	return client.EnqueueStringDeferred(topic, data, 0)
+}
+
+// EnqueueStringDeferred posts string data to the specified NSQ topic,
+// asking nsqd to hold the message for delay before delivering it.
+// Delays of zero or less deliver the message at once. nsqd rejects
+// delays longer than its -max-req-timeout setting.
+func (client *NSQClient) EnqueueStringDeferred(topic string, data string, delay time.Duration) error {
+	url := fmt.Sprintf("%s/pub?topic=%s", client.url(), topic)
+	if delay > 0 {
+		url = fmt.Sprintf("%s&defer=%d", url, delay.Nanoseconds()/int64(time.Millisecond))
+	}
 	resp, err := http.Post(url, "text/html", bytes.NewBuffer([]byte(data)))
 	if err != nil {
 		return fmt.Errorf("Nsqd returned an error when queuing data: %v", err)
@@ -84,7 +157,7 @@ func (client *NSQClient) EnqueueString(topic string, data string) error {
 // returning stats for all topics right now. Also note that requests to
 // /stats/ (with trailing slash) produce a 404.
 func (client *NSQClient) GetStats() (*NSQStatsData, error) {
-	url := fmt.Sprintf("%s/stats?format=json", client.URL)
+	url := fmt.Sprintf("%s/stats?format=json", client.url())
 	resp, err := http.Get(url)
 	if err != nil {
 		return nil, err
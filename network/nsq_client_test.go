@@ -2,18 +2,21 @@ package network_test
 
 import (
 	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/network"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
-	"strconv"
 	"testing"
+	"time"
 )
 
 var nsqTopic string
 var nsqId int
+var nsqAction string
 var nsqTester *testing.T
 
 func TestEnqueue(t *testing.T) {
@@ -33,6 +36,51 @@ func TestEnqueue(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestEnqueueWorkItem(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(nsqEnqueueHandler))
+	defer testServer.Close()
+
+	client := network.NewNSQClient(testServer.URL)
+	nsqTester = t
+	nsqTopic = "test_topic3"
+	nsqId = 4417
+	nsqAction = constants.ActionRestore
+	defer func() { nsqAction = "" }()
+	workItem := &models.WorkItem{
+		Id:     nsqId,
+		Action: nsqAction,
+	}
+	err := client.EnqueueWorkItem(nsqTopic, workItem)
+	assert.Nil(t, err)
+}
+
+func TestEnqueueWorkItemDeferred(t *testing.T) {
+	deferParam := "unset"
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deferParam = r.URL.Query().Get("defer")
+		nsqEnqueueHandler(w, r)
+	}))
+	defer testServer.Close()
+
+	client := network.NewNSQClient(testServer.URL)
+	nsqTester = t
+	nsqTopic = "test_topic4"
+	nsqId = 5120
+	nsqAction = constants.ActionIngest
+	defer func() { nsqAction = "" }()
+	workItem := &models.WorkItem{
+		Id:     nsqId,
+		Action: nsqAction,
+	}
+	err := client.EnqueueWorkItemDeferred(nsqTopic, workItem, 90*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, "90000", deferParam)
+
+	err = client.EnqueueWorkItemDeferred(nsqTopic, workItem, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "", deferParam)
+}
+
 func TestNSQStatsData(t *testing.T) {
 	testServer := httptest.NewServer(http.HandlerFunc(nsqStatsHandler))
 	defer testServer.Close()
@@ -44,16 +92,24 @@ func TestNSQStatsData(t *testing.T) {
 
 	require.Equal(t, 3, len(stats.Topics))
 	assert.Equal(t, "record_channel", stats.Topics[1].Channels[0].ChannelName)
+
+	channelStats := stats.ChannelStats("store_topic", "store_channel")
+	require.NotNil(t, channelStats)
+	assert.EqualValues(t, 11, channelStats.MessageCount)
+	assert.Nil(t, stats.ChannelStats("store_topic", "no_such_channel"))
+	assert.Nil(t, stats.ChannelStats("no_such_topic", "store_channel"))
 }
 
 func nsqEnqueueHandler(w http.ResponseWriter, r *http.Request) {
 	data, err := ioutil.ReadAll(r.Body)
 	require.Nil(nsqTester, err)
-	id, err := strconv.Atoi(string(data))
+	queueMessage, err := models.ParseQueueMessage(data)
 	require.Nil(nsqTester, err)
 	topic := r.URL.Query().Get("topic")
 	assert.Equal(nsqTester, nsqTopic, topic)
-	assert.Equal(nsqTester, nsqId, id)
+	assert.Equal(nsqTester, nsqId, queueMessage.WorkItemId)
+	assert.Equal(nsqTester, nsqAction, queueMessage.Action)
+	assert.Equal(nsqTester, models.QueueMessageVersion, queueMessage.Version)
 	fmt.Fprintln(w, "OK")
 }
 
@@ -0,0 +1,95 @@
+package network
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pharosCache holds the raw responses to GET requests for records
+// that change rarely, such as institutions and objects, so workers
+// that fetch the same records over and over don't have to keep
+// asking Pharos. Entries expire after ttl.
+//
+// The cache holds response bodies rather than parsed records, so each
+// caller gets its own copy of the record, which it can safely change.
+type pharosCache struct {
+	ttl       time.Duration
+	entries   map[string]*pharosCacheEntry
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+type pharosCacheEntry struct {
+	request  *http.Request
+	response *http.Response
+	data     []byte
+	expires  time.Time
+}
+
+func newPharosCache(ttl time.Duration) *pharosCache {
+	return &pharosCache{
+		ttl:       ttl,
+		entries:   make(map[string]*pharosCacheEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// get fills in resp from the cached response to absoluteUrl, and
+// returns true. It returns false if there's no unexpired response.
+func (cache *pharosCache) get(absoluteUrl string, resp *PharosResponse) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry := cache.entries[absoluteUrl]
+	if entry == nil {
+		return false
+	}
+	if time.Now().After(entry.expires) {
+		delete(cache.entries, absoluteUrl)
+		return false
+	}
+	resp.Request = entry.request
+	resp.Response = entry.response
+	resp.data = append([]byte{}, entry.data...)
+	resp.hasBeenRead = true
+	return true
+}
+
+// put caches resp as the response to absoluteUrl, if it succeeded.
+func (cache *pharosCache) put(absoluteUrl string, resp *PharosResponse) {
+	if resp.Error != nil || resp.Response == nil || resp.Response.StatusCode != http.StatusOK {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	now := time.Now()
+	cache.entries[absoluteUrl] = &pharosCacheEntry{
+		request:  resp.Request,
+		response: resp.Response,
+		data:     append([]byte{}, resp.data...),
+		expires:  now.Add(cache.ttl),
+	}
+	// Clear out expired entries now and then, so records we
+	// never ask for again don't stay in memory forever.
+	if now.Sub(cache.lastSweep) > cache.ttl {
+		for key, entry := range cache.entries {
+			if now.After(entry.expires) {
+				delete(cache.entries, key)
+			}
+		}
+		cache.lastSweep = now
+	}
+}
+
+// invalidate removes the cached responses to all URLs that begin
+// with urlPrefix.
+func (cache *pharosCache) invalidate(urlPrefix string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for key := range cache.entries {
+		if strings.HasPrefix(key, urlPrefix) {
+			delete(cache.entries, key)
+		}
+	}
+}
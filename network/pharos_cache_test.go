@@ -0,0 +1,141 @@
+package network_test
+
+import (
+	"github.com/APTrust/exchange/network"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingServer counts the requests it gets, and passes them to
+// the institution and object handlers. It returns 500 for any
+// institution named "broken.edu".
+type countingServer struct {
+	mutex sync.Mutex
+	count int
+}
+
+func (server *countingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	server.count++
+	server.mutex.Unlock()
+	if strings.Contains(r.URL.Path, "broken.edu") {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if strings.Contains(r.URL.Path, "/institutions/") {
+		institutionGetHandler(w, r)
+	} else if r.Method == "GET" {
+		intellectualObjectGetHandler(w, r)
+	} else {
+		intellectualObjectSaveHandler(w, r)
+	}
+}
+
+func (server *countingServer) requestCount() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.count
+}
+
+func TestPharosClientCache(t *testing.T) {
+	server := &countingServer{}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	client.EnableCache(time.Minute)
+
+	// The second request for the same record comes from the cache,
+	// and each caller gets its own copy of the record.
+	resp := client.InstitutionGet("college.edu")
+	require.Nil(t, resp.Error)
+	resp2 := client.InstitutionGet("college.edu")
+	require.Nil(t, resp2.Error)
+	assert.Equal(t, 1, server.requestCount())
+	require.NotNil(t, resp2.Institution())
+	assert.Equal(t, resp.Institution().Identifier, resp2.Institution().Identifier)
+	assert.False(t, resp.Institution() == resp2.Institution())
+	assert.Equal(t, 200, resp2.Response.StatusCode)
+
+	// Objects are cached separately for each set of relations.
+	resp = client.IntellectualObjectGet("college.edu/object", false, false)
+	require.Nil(t, resp.Error)
+	resp = client.IntellectualObjectGet("college.edu/object", true, false)
+	require.Nil(t, resp.Error)
+	assert.Equal(t, 3, server.requestCount())
+	resp = client.IntellectualObjectGet("college.edu/object", true, false)
+	require.Nil(t, resp.Error)
+	require.NotNil(t, resp.IntellectualObject())
+	assert.Equal(t, 3, server.requestCount())
+
+	// Saving the object removes it from the cache.
+	obj := testutil.MakeIntellectualObject(0, 0, 0, 0)
+	obj.Identifier = "college.edu/object"
+	obj.Institution = "college.edu"
+	obj.Id = 99
+	resp = client.IntellectualObjectSave(obj)
+	require.Nil(t, resp.Error)
+	assert.Equal(t, 4, server.requestCount())
+	resp = client.IntellectualObjectGet("college.edu/object", true, false)
+	require.Nil(t, resp.Error)
+	assert.Equal(t, 5, server.requestCount())
+
+	// Failed requests aren't cached.
+	resp = client.InstitutionGet("broken.edu")
+	assert.NotNil(t, resp.Error)
+	resp = client.InstitutionGet("broken.edu")
+	assert.NotNil(t, resp.Error)
+	assert.Equal(t, 7, server.requestCount())
+
+	// ClearCache clears everything.
+	client.ClearCache()
+	resp = client.InstitutionGet("college.edu")
+	require.Nil(t, resp.Error)
+	assert.Equal(t, 8, server.requestCount())
+}
+
+func TestPharosClientCacheExpires(t *testing.T) {
+	server := &countingServer{}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	client.EnableCache(20 * time.Millisecond)
+
+	client.InstitutionGet("college.edu")
+	client.InstitutionGet("college.edu")
+	assert.Equal(t, 1, server.requestCount())
+	time.Sleep(30 * time.Millisecond)
+	client.InstitutionGet("college.edu")
+	assert.Equal(t, 2, server.requestCount())
+}
+
+func TestPharosClientCacheOff(t *testing.T) {
+	server := &countingServer{}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	// No cache by default.
+	client.InstitutionGet("college.edu")
+	client.InstitutionGet("college.edu")
+	assert.Equal(t, 2, server.requestCount())
+
+	// Zero turns the cache off.
+	client.EnableCache(time.Minute)
+	client.EnableCache(0)
+	client.InstitutionGet("college.edu")
+	client.InstitutionGet("college.edu")
+	assert.Equal(t, 4, server.requestCount())
+}
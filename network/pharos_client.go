@@ -2,26 +2,42 @@ package network
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/APTrust/exchange/models"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 )
 
 // PharosClient supports basic calls to the Pharos Admin REST API.
 // This client does not support the Member API.
+//
+// A PharosClient created with NewInstitutionScopedPharosClient can
+// see and act on records belonging to only one institution. Partner
+// tools should always use a scoped client, so they can never operate
+// across institutions, even by accident.
 type PharosClient struct {
-	hostUrl    string
-	apiVersion string
-	apiUser    string
-	apiKey     string
-	httpClient *http.Client
-	transport  *http.Transport
+	hostUrl     string
+	apiVersion  string
+	apiUser     string
+	apiKey      string
+	institution string
+	httpClient  *http.Client
+	transport   *http.Transport
+	retryPolicy PharosRetryPolicy
+	cache       *pharosCache
+	limiter     *pharosRateLimiter
+	observer    PharosRequestObserver
+	ctx         context.Context
+	mutex       sync.RWMutex
 }
 
 // NewPharosClient creates a new pharos client. Param hostUrl should
@@ -56,6 +72,32 @@ func NewPharosClient(hostUrl, apiVersion, apiUser, apiKey string) (*PharosClient
 		transport:  transport}, nil
 }
 
+// NewInstitutionScopedPharosClient creates a new pharos client that
+// can see and act on only those records belonging to the institution
+// with the specified identifier (e.g. "virginia.edu"). The client
+// sends the institution identifier to Pharos in the
+// X-Pharos-API-Institution header, adds it as the
+// institution_identifier param on all GET requests, and refuses
+// to send any request whose URL or params refer to another
+// institution. Param institution is required.
+func NewInstitutionScopedPharosClient(hostUrl, apiVersion, apiUser, apiKey, institution string) (*PharosClient, error) {
+	if strings.TrimSpace(institution) == "" {
+		return nil, fmt.Errorf("Param institution cannot be empty.")
+	}
+	client, err := NewPharosClient(hostUrl, apiVersion, apiUser, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	client.institution = institution
+	return client, nil
+}
+
+// Institution returns the identifier of the institution to which this
+// client is scoped, or an empty string if the client is not scoped.
+func (client *PharosClient) Institution() string {
+	return client.institution
+}
+
 // InstitutionGet returns the institution with the specified identifier.
 func (client *PharosClient) InstitutionGet(identifier string) *PharosResponse {
 	// Set up the response object
@@ -66,8 +108,8 @@ func (client *PharosClient) InstitutionGet(identifier string) *PharosResponse {
 	relativeUrl := fmt.Sprintf("/api/%s/institutions/%s/", client.apiVersion, url.QueryEscape(identifier))
 	absoluteUrl := client.BuildUrl(relativeUrl)
 
-	// Run the request
-	client.DoRequest(resp, "GET", absoluteUrl, nil)
+	// Run the request, or get the response from the cache
+	client.doCachedGet(resp, absoluteUrl)
 	if resp.Error != nil {
 		return resp
 	}
@@ -128,15 +170,15 @@ func (client *PharosClient) IntellectualObjectGet(identifier string, includeFile
 	}
 	absoluteUrl := client.BuildUrl(relativeUrl)
 
-	// Run the request
-	client.DoRequest(resp, "GET", absoluteUrl, nil)
+	// Run the request, or get the response from the cache
+	client.doCachedGet(resp, absoluteUrl)
 	if resp.Error != nil {
 		return resp
 	}
 
 	// Parse the JSON from the response body
 	intelObj := &models.IntellectualObject{}
-	resp.Error = json.Unmarshal(resp.data, intelObj)
+	resp.Error = unmarshalPharosJson(resp.data, PharosIntellectualObject, intelObj)
 	if resp.Error == nil {
 		resp.objects[0] = intelObj
 	}
@@ -186,6 +228,9 @@ func (client *PharosClient) IntellectualObjectSave(obj *models.IntellectualObjec
 	// Set up the response object
 	resp := NewPharosResponse(PharosIntellectualObject)
 	resp.objects = make([]*models.IntellectualObject, 1)
+	if resp.Error = obj.Validate(); resp.Error != nil {
+		return resp
+	}
 
 	// URL and method
 	// Note that POST URL takes an institution identifier, while
@@ -196,6 +241,9 @@ func (client *PharosClient) IntellectualObjectSave(obj *models.IntellectualObjec
 		// PUT URL looks like /api/v2/objects/college.edu%2Fobject_name
 		relativeUrl = fmt.Sprintf("/api/%s/objects/%s", client.apiVersion, escapeFileIdentifier(obj.Identifier))
 		httpMethod = "PUT"
+	} else {
+		resp.recordExists = client.recordExistsAt(fmt.Sprintf("/api/%s/objects/%s",
+			client.apiVersion, escapeFileIdentifier(obj.Identifier)))
 	}
 	absoluteUrl := client.BuildUrl(relativeUrl)
 
@@ -207,13 +255,14 @@ func (client *PharosClient) IntellectualObjectSave(obj *models.IntellectualObjec
 
 	// Run the request
 	client.DoRequest(resp, httpMethod, absoluteUrl, bytes.NewBuffer(postData))
+	client.forgetObject(obj.Identifier)
 	if resp.Error != nil {
 		return resp
 	}
 
 	// Parse the JSON from the response body
 	intelObj := &models.IntellectualObject{}
-	resp.Error = json.Unmarshal(resp.data, intelObj)
+	resp.Error = unmarshalPharosJson(resp.data, PharosIntellectualObject, intelObj)
 	if resp.Error == nil {
 		resp.objects[0] = intelObj
 	}
@@ -234,13 +283,14 @@ func (client *PharosClient) IntellectualObjectRequestRestore(identifier string)
 
 	// Run the request.
 	client.DoRequest(resp, "PUT", absoluteUrl, nil)
+	client.forgetObject(identifier)
 	if resp.Error != nil {
 		return resp
 	}
 
 	// Note that we're getting a WorkItem back.
 	workItem := &models.WorkItem{}
-	resp.Error = json.Unmarshal(resp.data, workItem)
+	resp.Error = unmarshalPharosJson(resp.data, PharosWorkItem, workItem)
 	if resp.Error == nil {
 		resp.workItems[0] = workItem
 	}
@@ -263,6 +313,7 @@ func (client *PharosClient) IntellectualObjectRequestDelete(identifier string) *
 
 	// Run the request.
 	client.DoRequest(resp, "DELETE", absoluteUrl, nil)
+	client.forgetObject(identifier)
 	if resp.Error != nil {
 		return resp
 	}
@@ -288,6 +339,7 @@ func (client *PharosClient) IntellectualObjectFinishDelete(identifier string) *P
 
 	// Run the request
 	client.DoRequest(resp, "GET", absoluteUrl, nil)
+	client.forgetObject(identifier)
 	if resp.Error != nil {
 		return resp
 	}
@@ -325,7 +377,7 @@ func (client *PharosClient) GenericFileGet(identifier string, includeRelations b
 
 	// Parse the JSON from the response body
 	gf := &models.GenericFile{}
-	resp.Error = json.Unmarshal(resp.data, gf)
+	resp.Error = unmarshalPharosJson(resp.data, PharosGenericFile, gf)
 	if resp.Error == nil {
 		resp.files[0] = gf
 	}
@@ -343,6 +395,8 @@ func (client *PharosClient) GenericFileGet(identifier string, includeRelations b
 // * with_ingest_state=true - Include ingest state data in the response.
 // * storage_option - "Standard", "Glacier-OH", "Glacier-OR", "Glacier-VA",
 //                    "Glacier-Deep-OH", "Glacier-Deep-OR", "Glacier-Deep-VA"
+// * uri_like - Returns files whose URI contains this string, such as
+//              a storage key.
 func (client *PharosClient) GenericFileList(params url.Values) *PharosResponse {
 	// Set up the response object
 	resp := NewPharosResponse(PharosGenericFile)
@@ -373,6 +427,9 @@ func (client *PharosClient) GenericFileSave(obj *models.GenericFile) *PharosResp
 	// Set up the response object
 	resp := NewPharosResponse(PharosGenericFile)
 	resp.files = make([]*models.GenericFile, 1)
+	if resp.Error = obj.Validate(); resp.Error != nil {
+		return resp
+	}
 
 	// URL and method
 	relativeUrl := fmt.Sprintf("/api/%s/files/", client.apiVersion)
@@ -381,6 +438,9 @@ func (client *PharosClient) GenericFileSave(obj *models.GenericFile) *PharosResp
 		// PUT URL looks like /api/v2/files/college.edu%2Fobject_name%2Ffile.xml
 		relativeUrl = fmt.Sprintf("%s%s", relativeUrl, escapeFileIdentifier(obj.Identifier))
 		httpMethod = "PUT"
+	} else {
+		resp.recordExists = client.recordExistsAt(fmt.Sprintf("/api/%s/files/%s",
+			client.apiVersion, escapeFileIdentifier(obj.Identifier)))
 	}
 	absoluteUrl := client.BuildUrl(relativeUrl)
 
@@ -392,13 +452,14 @@ func (client *PharosClient) GenericFileSave(obj *models.GenericFile) *PharosResp
 
 	// Run the request
 	client.DoRequest(resp, httpMethod, absoluteUrl, bytes.NewBuffer(postData))
+	client.forgetObject(obj.IntellectualObjectIdentifier)
 	if resp.Error != nil {
 		return resp
 	}
 
 	// Parse the JSON from the response body
 	gf := &models.GenericFile{}
-	resp.Error = json.Unmarshal(resp.data, gf)
+	resp.Error = unmarshalPharosJson(resp.data, PharosGenericFile, gf)
 	if resp.Error == nil {
 		resp.files[0] = gf
 	}
@@ -430,6 +491,9 @@ func (client *PharosClient) GenericFileSaveBatch(objList []*models.GenericFile)
 				"is for creating new GenericFiles only.")
 			return resp
 		}
+		if resp.Error = gf.Validate(); resp.Error != nil {
+			return resp
+		}
 	}
 
 	// URL and method
@@ -454,6 +518,7 @@ func (client *PharosClient) GenericFileSaveBatch(objList []*models.GenericFile)
 
 	// Run the request
 	client.DoRequest(resp, httpMethod, absoluteUrl, bytes.NewBuffer(postData))
+	client.forgetObject(objList[0].IntellectualObjectIdentifier)
 	if resp.Error != nil {
 		return resp
 	}
@@ -482,7 +547,7 @@ func (client *PharosClient) GenericFileRequestRestore(identifier string) *Pharos
 
 	// Note that we're getting a WorkItem back.
 	workItem := &models.WorkItem{}
-	resp.Error = json.Unmarshal(resp.data, workItem)
+	resp.Error = unmarshalPharosJson(resp.data, PharosWorkItem, workItem)
 	if resp.Error == nil {
 		resp.workItems[0] = workItem
 	}
@@ -505,6 +570,7 @@ func (client *PharosClient) GenericFileFinishDelete(identifier string) *PharosRe
 
 	// Run the request
 	client.DoRequest(resp, "GET", absoluteUrl, nil)
+	client.forgetObject("")
 	if resp.Error != nil {
 		return resp
 	}
@@ -576,6 +642,9 @@ func (client *PharosClient) ChecksumSave(obj *models.Checksum, gfIdentifier stri
 	// Set up the response object
 	resp := NewPharosResponse(PharosChecksum)
 	resp.checksums = make([]*models.Checksum, 1)
+	if resp.Error = obj.Validate(); resp.Error != nil {
+		return resp
+	}
 
 	// URL and method
 	relativeUrl := fmt.Sprintf("/api/%s/checksums/%s", client.apiVersion,
@@ -671,6 +740,9 @@ func (client *PharosClient) PremisEventSave(obj *models.PremisEvent) *PharosResp
 	// Set up the response object
 	resp := NewPharosResponse(PharosPremisEvent)
 	resp.events = make([]*models.PremisEvent, 1)
+	if resp.Error = obj.Validate(); resp.Error != nil {
+		return resp
+	}
 
 	// URL and method
 	relativeUrl := fmt.Sprintf("/api/%s/events/", client.apiVersion)
@@ -679,6 +751,9 @@ func (client *PharosClient) PremisEventSave(obj *models.PremisEvent) *PharosResp
 		// PUT is not even implemented in Pharos, and never will be
 		relativeUrl = fmt.Sprintf("%s/%s", relativeUrl, url.QueryEscape(obj.Identifier))
 		httpMethod = "PUT"
+	} else {
+		resp.recordExists = client.recordExistsAt(fmt.Sprintf("/api/%s/events/%s/",
+			client.apiVersion, url.QueryEscape(obj.Identifier)))
 	}
 	absoluteUrl := client.BuildUrl(relativeUrl)
 
@@ -754,6 +829,9 @@ func (client *PharosClient) WorkItemSave(obj *models.WorkItem) *PharosResponse {
 	// Set up the response object
 	resp := NewPharosResponse(PharosWorkItem)
 	resp.workItems = make([]*models.WorkItem, 1)
+	if resp.Error = obj.Validate(); resp.Error != nil {
+		return resp
+	}
 
 	// URL and method
 	relativeUrl := fmt.Sprintf("/api/%s/items/", client.apiVersion)
@@ -779,13 +857,116 @@ func (client *PharosClient) WorkItemSave(obj *models.WorkItem) *PharosResponse {
 
 	// Parse the JSON from the response body
 	workItem := &models.WorkItem{}
-	resp.Error = json.Unmarshal(resp.data, workItem)
+	resp.Error = unmarshalPharosJson(resp.data, PharosWorkItem, workItem)
 	if resp.Error == nil {
 		resp.workItems[0] = workItem
 	}
 	return resp
 }
 
+// WorkItemStatusUpdateBatch updates the status of a batch of existing
+// WorkItems in a single PUT, so workers finishing or requeuing many
+// items at once don't have to send one request per item. It sends
+// only the fields in WorkItem.StatusForPharos, so all of the WorkItems
+// in objList must have non-zero Ids. The response object will be a list
+// containing a new copy of each WorkItem that was updated. On the Pharos
+// end, the batch update is run as a transaction, so either all updates
+// succeed, or none do.
+func (client *PharosClient) WorkItemStatusUpdateBatch(objList []*models.WorkItem) *PharosResponse {
+	// Set up the response object
+	resp := NewPharosResponse(PharosWorkItem)
+	resp.workItems = make([]*models.WorkItem, len(objList))
+
+	if len(objList) == 0 {
+		resp.Error = fmt.Errorf("WorkItemStatusUpdateBatch was asked to update an empty list.")
+		return resp
+	}
+	batch := make([]map[string]interface{}, len(objList))
+	for i, item := range objList {
+		if item.Id == 0 {
+			resp.Error = fmt.Errorf("One or more WorkItems in the list " +
+				"passed to WorkItemStatusUpdateBatch has an id of zero. This call " +
+				"is for updating existing WorkItems only.")
+			return resp
+		}
+		batch[i] = item.StatusForPharos()
+	}
+
+	// URL and method
+	relativeUrl := fmt.Sprintf("/api/%s/items/update_batch/", client.apiVersion)
+	absoluteUrl := client.BuildUrl(relativeUrl)
+
+	// Prepare the JSON data
+	putData, err := json.Marshal(batch)
+	if err != nil {
+		resp.Error = fmt.Errorf("Error marshalling WorkItem batch to JSON: %v", err)
+		return resp
+	}
+
+	// Run the request
+	client.DoRequest(resp, "PUT", absoluteUrl, bytes.NewBuffer(putData))
+	if resp.Error != nil {
+		return resp
+	}
+
+	resp.UnmarshalJsonList()
+	return resp
+}
+
+// WorkItemBatchSave saves a batch of WorkItems to Pharos in a single
+// PUT, so workers that create or update many items at once don't have
+// to send one request per item. WorkItems with an Id of zero are
+// created, and the rest are updated. Unlike WorkItemStatusUpdateBatch,
+// this sends all of the fields WorkItemSave sends. The response object
+// will be a list containing a new copy of each WorkItem, in the same
+// order as objList. The new copies of created items have correct ids
+// and timestamps. On the Pharos end, the batch is saved in a
+// transaction, so either all saves succeed, or none do. If the batch
+// includes new WorkItems, the request is never retried, since Pharos
+// may have created them before the request failed.
+func (client *PharosClient) WorkItemBatchSave(objList []*models.WorkItem) *PharosResponse {
+	// Set up the response object
+	resp := NewPharosResponse(PharosWorkItem)
+	resp.workItems = make([]*models.WorkItem, len(objList))
+
+	if len(objList) == 0 {
+		resp.Error = fmt.Errorf("WorkItemBatchSave was asked to save an empty list.")
+		return resp
+	}
+	batch := make([]map[string]interface{}, len(objList))
+	for i, item := range objList {
+		if resp.Error = item.Validate(); resp.Error != nil {
+			return resp
+		}
+		batch[i] = item.DataForPharos()
+		if item.Id > 0 {
+			batch[i]["id"] = item.Id
+		} else {
+			resp.createsRecords = true
+		}
+	}
+
+	// URL and method
+	relativeUrl := fmt.Sprintf("/api/%s/items/save_batch/", client.apiVersion)
+	absoluteUrl := client.BuildUrl(relativeUrl)
+
+	// Prepare the JSON data
+	putData, err := json.Marshal(batch)
+	if err != nil {
+		resp.Error = fmt.Errorf("Error marshalling WorkItem batch to JSON: %v", err)
+		return resp
+	}
+
+	// Run the request
+	client.DoRequest(resp, "PUT", absoluteUrl, bytes.NewBuffer(putData))
+	if resp.Error != nil {
+		return resp
+	}
+
+	resp.UnmarshalJsonList()
+	return resp
+}
+
 // WorkItemGet returns the WorkItem with the specified ID.
 func (client *PharosClient) WorkItemGet(id int) *PharosResponse {
 	// Set up the response object
@@ -804,7 +985,7 @@ func (client *PharosClient) WorkItemGet(id int) *PharosResponse {
 
 	// Parse the JSON from the response body
 	workItem := &models.WorkItem{}
-	resp.Error = json.Unmarshal(resp.data, workItem)
+	resp.Error = unmarshalPharosJson(resp.data, PharosWorkItem, workItem)
 	if resp.Error == nil {
 		resp.workItems[0] = workItem
 	}
@@ -898,13 +1079,62 @@ func (client *PharosClient) FinishRestorationSpotTest(workItemId int) *PharosRes
 
 	// Parse the JSON from the response body
 	workItem := &models.WorkItem{}
-	resp.Error = json.Unmarshal(resp.data, workItem)
+	resp.Error = unmarshalPharosJson(resp.data, PharosWorkItem, workItem)
 	if resp.Error == nil {
 		resp.workItems[0] = workItem
 	}
 	return resp
 }
 
+// IngestReceiptSend asks Pharos to email receipt to the depositor
+// named in receipt.Recipient. This call returns no data. If
+// response.Error is nil, Pharos accepted the receipt for delivery.
+func (client *PharosClient) IngestReceiptSend(receipt *models.IngestReceipt) *PharosResponse {
+	// Set up the response object
+	resp := NewPharosResponse(PharosWorkItem)
+	resp.workItems = make([]*models.WorkItem, 0)
+
+	// URL and method
+	relativeUrl := fmt.Sprintf("/api/%s/notifications/ingest_receipt/%d/",
+		client.apiVersion, receipt.WorkItemId)
+	absoluteUrl := client.BuildUrl(relativeUrl)
+
+	// Prepare the JSON data
+	postData, err := json.Marshal(receipt)
+	if err != nil {
+		resp.Error = fmt.Errorf("Error marshalling IngestReceipt to JSON: %v", err)
+		return resp
+	}
+
+	// Run the request
+	client.DoRequest(resp, "POST", absoluteUrl, bytes.NewBuffer(postData))
+	return resp
+}
+
+// QueueStarvationAlertSend asks Pharos to alert APTrust admins to the
+// starved queues in report.Starved. This call returns no data. If
+// response.Error is nil, Pharos accepted the alert for delivery.
+func (client *PharosClient) QueueStarvationAlertSend(report *models.StarvationReport) *PharosResponse {
+	// Set up the response object
+	resp := NewPharosResponse(PharosWorkItem)
+	resp.workItems = make([]*models.WorkItem, 0)
+
+	// URL and method
+	relativeUrl := fmt.Sprintf("/api/%s/notifications/queue_starvation/", client.apiVersion)
+	absoluteUrl := client.BuildUrl(relativeUrl)
+
+	// Prepare the JSON data
+	postData, err := json.Marshal(report)
+	if err != nil {
+		resp.Error = fmt.Errorf("Error marshalling StarvationReport to JSON: %v", err)
+		return resp
+	}
+
+	// Run the request
+	client.DoRequest(resp, "POST", absoluteUrl, bytes.NewBuffer(postData))
+	return resp
+}
+
 // -------------------------------------------------------------------------
 // Utility Methods
 // -------------------------------------------------------------------------
@@ -914,7 +1144,190 @@ func (client *PharosClient) FinishRestorationSpotTest(workItemId int) *PharosRes
 // is "http://localhost:3456", then client.BuildUrl("/path/to/action.json")
 // would return "http://localhost:3456/path/to/action.json".
 func (client *PharosClient) BuildUrl(relativeUrl string) string {
-	return client.hostUrl + relativeUrl
+	return client.HostUrl() + relativeUrl
+}
+
+// HostUrl returns the protocol and host of the Pharos server,
+// such as "https://repo.aptrust.org".
+func (client *PharosClient) HostUrl() string {
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+	return client.hostUrl
+}
+
+// SetHostUrl points the client at the Pharos server at hostUrl,
+// which has the same format as for NewPharosClient. It's safe to
+// call while other goroutines are using the client.
+func (client *PharosClient) SetHostUrl(hostUrl string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.hostUrl = hostUrl
+}
+
+// RetryPolicy returns the policy the client uses to retry requests
+// that fail with a connection error or a 5xx response.
+func (client *PharosClient) RetryPolicy() PharosRetryPolicy {
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+	return client.retryPolicy
+}
+
+// SetRetryPolicy sets the policy the client uses to retry requests
+// that fail with a connection error or a 5xx response. By default,
+// the client does not retry. It's safe to call while other goroutines
+// are using the client.
+func (client *PharosClient) SetRetryPolicy(policy PharosRetryPolicy) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.retryPolicy = policy
+}
+
+// SetRateLimit limits the client to requestsPerSecond requests per
+// second on average, allowing bursts of up to burst requests at once.
+// Callers that would go over the limit wait their turn. Retries count
+// as requests. A requestsPerSecond of zero removes the limit. By
+// default, the client has no limit. Note that each client has its own
+// limit, so the total load on Pharos is the sum of the limits of all
+// the clients, in all the workers, that are talking to it.
+func (client *PharosClient) SetRateLimit(requestsPerSecond float64, burst int) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if requestsPerSecond > 0 {
+		client.limiter = newPharosRateLimiter(requestsPerSecond, burst)
+	} else {
+		client.limiter = nil
+	}
+}
+
+// WithContext returns a copy of the client that sends its requests
+// with ctx. When ctx is cancelled, calls waiting for the rate limit,
+// waiting to retry, or waiting on Pharos return right away, with
+// ctx.Err() or the error from the HTTP client in the response. The
+// copy shares the original's HTTP client, cache, rate limit and
+// request observer, and picks up the original's host URL and retry
+// policy as they are right now. Use it for one call, or a few
+// related calls, like this:
+//
+//	resp := client.WithContext(ctx).WorkItemGet(id)
+func (client *PharosClient) WithContext(ctx context.Context) *PharosClient {
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+	return &PharosClient{
+		hostUrl:     client.hostUrl,
+		apiVersion:  client.apiVersion,
+		apiUser:     client.apiUser,
+		apiKey:      client.apiKey,
+		institution: client.institution,
+		httpClient:  client.httpClient,
+		transport:   client.transport,
+		retryPolicy: client.retryPolicy,
+		cache:       client.cache,
+		limiter:     client.limiter,
+		observer:    client.observer,
+		ctx:         ctx,
+	}
+}
+
+// SetRequestObserver tells the client to report each request it
+// sends to observer, so we can track Pharos latency and error rates.
+// Requests answered from the cache are not reported. Pass nil to
+// stop reporting.
+func (client *PharosClient) SetRequestObserver(observer PharosRequestObserver) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.observer = observer
+}
+
+// observe reports a request to the client's request observer, if
+// it has one.
+func (client *PharosClient) observe(request *http.Request, statusCode int, duration time.Duration) {
+	client.mutex.RLock()
+	observer := client.observer
+	client.mutex.RUnlock()
+	if observer != nil {
+		observer.ObservePharosRequest(request.Method, pharosEndpoint(request.URL), statusCode, duration)
+	}
+}
+
+// requestContext returns the context for the client's requests, and
+// its rate limiter, which may be nil.
+func (client *PharosClient) requestContext() (context.Context, *pharosRateLimiter) {
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+	ctx := client.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx, client.limiter
+}
+
+// EnableCache tells the client to cache the responses to
+// InstitutionGet and IntellectualObjectGet for ttl, so workers that
+// ask for the same records over and over don't have to keep asking
+// Pharos. Saving or deleting an object or its files through this
+// client removes the object from the cache, but changes made by other
+// clients won't show up until the cached response expires. A ttl of
+// zero turns the cache off. By default, the client has no cache.
+func (client *PharosClient) EnableCache(ttl time.Duration) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if ttl > 0 {
+		client.cache = newPharosCache(ttl)
+	} else {
+		client.cache = nil
+	}
+}
+
+// ClearCache removes all responses from the client's cache.
+func (client *PharosClient) ClearCache() {
+	if cache := client.getCache(); cache != nil {
+		cache.invalidate("")
+	}
+}
+
+func (client *PharosClient) getCache() *pharosCache {
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+	return client.cache
+}
+
+// doCachedGet fills in resp with the cached response to a GET request
+// for absoluteUrl, if the client has one. Otherwise, it sends the
+// request, and caches the response if it succeeded.
+func (client *PharosClient) doCachedGet(resp *PharosResponse, absoluteUrl string) {
+	cache := client.getCache()
+	if cache != nil && cache.get(absoluteUrl, resp) {
+		return
+	}
+	client.DoRequest(resp, "GET", absoluteUrl, nil)
+	if cache != nil {
+		cache.put(absoluteUrl, resp)
+	}
+}
+
+// forgetObject removes the object with the specified identifier from
+// the client's cache, including all of its files and events. If
+// identifier is empty, this removes all objects from the cache.
+func (client *PharosClient) forgetObject(identifier string) {
+	if cache := client.getCache(); cache != nil {
+		relativeUrl := fmt.Sprintf("/api/%s/objects/%s", client.apiVersion, escapeFileIdentifier(identifier))
+		cache.invalidate(client.BuildUrl(relativeUrl))
+	}
+}
+
+// splitUrl splits absoluteUrl into its protocol and host, and
+// everything after them. We don't simply strip client.hostUrl,
+// because the host may have changed since we built absoluteUrl.
+func splitUrl(absoluteUrl string) (hostUrl, relativeUrl string) {
+	start := strings.Index(absoluteUrl, "://")
+	if start < 0 {
+		return "", absoluteUrl
+	}
+	end := strings.Index(absoluteUrl[start+3:], "/")
+	if end < 0 {
+		return absoluteUrl, ""
+	}
+	return absoluteUrl[:start+3+end], absoluteUrl[start+3+end:]
 }
 
 // NewJsonRequest returns a new request with headers indicating
@@ -941,6 +1354,9 @@ func (client *PharosClient) NewJsonRequest(method, absoluteUrl string, requestDa
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("X-Pharos-API-User", client.apiUser)
 	req.Header.Add("X-Pharos-API-Key", client.apiKey)
+	if client.institution != "" {
+		req.Header.Add("X-Pharos-API-Institution", client.institution)
+	}
 	req.Header.Add("Connection", "Keep-Alive")
 
 	// Unfix the URL that golang net/url "fixes" for us.
@@ -952,7 +1368,7 @@ func (client *PharosClient) NewJsonRequest(method, absoluteUrl string, requestDa
 	if err != nil {
 		return nil, err
 	}
-	opaqueUrl := strings.Replace(absoluteUrl, client.hostUrl, "", 1)
+	_, opaqueUrl := splitUrl(absoluteUrl)
 
 	// This fixes an issue with GenericFile names that include spaces.
 	opaqueUrl = strings.Replace(opaqueUrl, " ", "%20", -1)
@@ -973,8 +1389,73 @@ func (client *PharosClient) NewJsonRequest(method, absoluteUrl string, requestDa
 //
 // For a description of the other params, see NewJsonRequest.
 //
-// If an error occurs, it will be recorded in resp.Error.
+// If an error occurs, it will be recorded in resp.Error. If the client
+// is scoped to an institution and the request refers to some other
+// institution, DoRequest records an error and does not send the request.
+//
+// DoRequest waits for the client's rate limit, if it has one, and
+// retries the request according to the client's RetryPolicy. It
+// retries a POST only if it can first confirm that the record the
+// POST creates does not exist.
 func (client *PharosClient) DoRequest(resp *PharosResponse, method, absoluteUrl string, requestData io.Reader) {
+	if client.institution != "" {
+		absoluteUrl, resp.Error = client.applyInstitutionScope(method, absoluteUrl)
+		if resp.Error != nil {
+			return
+		}
+	}
+
+	// Read the request body up front, so we can resend it
+	// if we have to retry.
+	var body []byte
+	if requestData != nil {
+		body, resp.Error = ioutil.ReadAll(requestData)
+		if resp.Error != nil {
+			return
+		}
+	}
+
+	policy := client.RetryPolicy()
+	ctx, limiter := client.requestContext()
+	for attempt := 1; ; attempt++ {
+		if limiter != nil {
+			if err := limiter.wait(ctx); err != nil {
+				resp.Error = err
+				return
+			}
+		}
+		client.doRequestOnce(ctx, resp, method, absoluteUrl, body)
+		if ctx.Err() != nil || !policy.shouldRetry(resp, attempt) {
+			return
+		}
+		timer := time.NewTimer(policy.Delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+		if !resp.isIdempotent(method) && !resp.safeToResend() {
+			return
+		}
+	}
+}
+
+// doRequestOnce makes a single attempt at the request described by
+// DoRequest's params, with the specified context, recording the
+// result in resp. Param body is
+// the request body, or nil if there is none.
+func (client *PharosClient) doRequestOnce(ctx context.Context, resp *PharosResponse, method, absoluteUrl string, body []byte) {
+	// Clear out the results of any prior attempt.
+	resp.Response = nil
+	resp.data = nil
+	resp.hasBeenRead = false
+
+	var requestData io.Reader
+	if body != nil {
+		requestData = bytes.NewReader(body)
+	}
+
 	// Build the request
 	request, err := client.NewJsonRequest(method, absoluteUrl, requestData)
 	resp.Request = request
@@ -984,8 +1465,12 @@ func (client *PharosClient) DoRequest(resp *PharosResponse, method, absoluteUrl
 	}
 
 	// Issue the HTTP request
+	request = request.WithContext(ctx)
+	resp.Request = request
+	startedAt := time.Now()
 	resp.Response, resp.Error = client.httpClient.Do(request)
 	if resp.Error != nil {
+		client.observe(request, 0, time.Since(startedAt))
 		return
 	}
 
@@ -996,6 +1481,7 @@ func (client *PharosClient) DoRequest(resp *PharosResponse, method, absoluteUrl
 	// If there's an error reading the response body, it will
 	// be recorded in resp.Error.
 	resp.readResponse()
+	client.observe(request, resp.Response.StatusCode, time.Since(startedAt))
 
 	if resp.Error == nil && resp.Response.StatusCode >= 400 {
 		body, _ := resp.RawResponseData()
@@ -1004,6 +1490,56 @@ func (client *PharosClient) DoRequest(resp *PharosResponse, method, absoluteUrl
 	}
 }
 
+// applyInstitutionScope returns an error if absoluteUrl refers to
+// an institution other than the one to which this client is scoped.
+// Identifiers of objects, files and events begin with the identifier
+// of the institution that owns them, so any path segment that contains
+// a slash once unescaped must begin with client.institution. For GET
+// requests, this returns absoluteUrl with the institution_identifier
+// param set, so Pharos returns only the institution's records.
+func (client *PharosClient) applyInstitutionScope(method, absoluteUrl string) (string, error) {
+	hostUrl, relativeUrl := splitUrl(absoluteUrl)
+	path, query := relativeUrl, ""
+	if index := strings.Index(relativeUrl, "?"); index > -1 {
+		path, query = relativeUrl[:index], relativeUrl[index+1:]
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		value, err := url.QueryUnescape(segment)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(value, "/") {
+			if !strings.HasPrefix(value, client.institution+"/") {
+				return "", client.scopeError(value)
+			}
+		} else if i > 0 && value != "" &&
+			(segments[i-1] == "institutions" || segments[i-1] == "objects") &&
+			value != client.institution {
+			return "", client.scopeError(value)
+		}
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", err
+	}
+	for _, key := range []string{"institution", "institution_identifier"} {
+		if value := params.Get(key); value != "" && value != client.institution {
+			return "", client.scopeError(value)
+		}
+	}
+	if method == "GET" {
+		params.Set("institution_identifier", client.institution)
+		return fmt.Sprintf("%s%s?%s", hostUrl, path, params.Encode()), nil
+	}
+	return absoluteUrl, nil
+}
+
+func (client *PharosClient) scopeError(identifier string) error {
+	return fmt.Errorf("Request for '%s' is outside of this client's institution scope (%s)",
+		identifier, client.institution)
+}
+
 func escapeFileIdentifier(identifier string) string {
 	encoded := url.QueryEscape(identifier)
 	return strings.Replace(encoded, "+", "%20", -1)
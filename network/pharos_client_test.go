@@ -3,6 +3,7 @@ package network_test
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/network"
 	"github.com/APTrust/exchange/util/testutil"
@@ -24,6 +25,25 @@ func TestNewPharosClient(t *testing.T) {
 	}
 }
 
+func TestPharosClientSetHostUrl(t *testing.T) {
+	oldServer := httptest.NewServer(http.HandlerFunc(institutionGetHandler))
+	defer oldServer.Close()
+	newServer := httptest.NewServer(http.HandlerFunc(institutionGetHandler))
+	defer newServer.Close()
+	client, err := network.NewPharosClient(oldServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	assert.Equal(t, oldServer.URL, client.HostUrl())
+
+	// Requests go to the new host once it's set.
+	client.SetHostUrl(newServer.URL)
+	assert.Equal(t, newServer.URL, client.HostUrl())
+	assert.Equal(t, newServer.URL+"/api/v2/", client.BuildUrl("/api/v2/"))
+	response := client.InstitutionGet("college.edu")
+	require.Nil(t, response.Error)
+	assert.Equal(t, strings.TrimPrefix(newServer.URL, "http://"), response.Request.URL.Host)
+	assert.Equal(t, "/api/v2/institutions/college.edu/", response.Request.URL.Opaque)
+}
+
 func TestInstitutionGet(t *testing.T) {
 	testServer := httptest.NewServer(http.HandlerFunc(institutionGetHandler))
 	defer testServer.Close()
@@ -47,6 +67,9 @@ func TestInstitutionGet(t *testing.T) {
 		t.Errorf("Institution should not be nil")
 	}
 	assert.NotEqual(t, "", response.Institution().Identifier)
+	assert.EqualValues(t, 5000000, response.Institution().QuotaBytes)
+	assert.EqualValues(t, 1200000, response.Institution().BytesUsed)
+	assert.False(t, response.Institution().BytesUsedAsOf.IsZero())
 }
 
 func TestInstitutionList(t *testing.T) {
@@ -340,6 +363,24 @@ func TestGenericFileGet(t *testing.T) {
 	assert.Equal(t, 3, len(obj.Checksums))
 }
 
+func TestGenericFileGetInvalidJson(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"id": 5, "identifier": "college.edu/object/file.xml", "size": "big"}`)
+	}))
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	// Instead of a zero-valued GenericFile, we should get
+	// an error describing the bad field.
+	response := client.GenericFileGet("college.edu/object/file.xml", false)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, "GenericFile: field 'size' should be number, but is string", response.Error.Error())
+	assert.Nil(t, response.GenericFile())
+}
+
 func TestGenericFileList(t *testing.T) {
 	testServer := httptest.NewServer(http.HandlerFunc(genericFileListHandler))
 	defer testServer.Close()
@@ -778,6 +819,87 @@ func TestFinishRestorationSpotTest(t *testing.T) {
 	assert.True(t, obj.Retry)
 }
 
+func TestIngestReceiptSend(t *testing.T) {
+	var posted *models.IngestReceipt
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted = &models.IngestReceipt{}
+		json.NewDecoder(r.Body).Decode(posted)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	receipt := &models.IngestReceipt{
+		WorkItemId:       999,
+		ObjectIdentifier: "test.edu/bag",
+		Recipient:        "user@test.edu",
+		FileCount:        3,
+	}
+	response := client.IngestReceiptSend(receipt)
+	assert.Equal(t, "POST", response.Request.Method)
+	assert.Equal(t, "/api/v2/notifications/ingest_receipt/999/", response.Request.URL.Opaque)
+	assert.Nil(t, response.Error)
+	require.NotNil(t, posted)
+	assert.Equal(t, "user@test.edu", posted.Recipient)
+	assert.Equal(t, 3, posted.FileCount)
+
+	errServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer errServer.Close()
+	client, err = network.NewPharosClient(errServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	assert.NotNil(t, client.IngestReceiptSend(receipt).Error)
+}
+
+func TestQueueStarvationAlertSend(t *testing.T) {
+	var posted *models.StarvationReport
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted = &models.StarvationReport{}
+		json.NewDecoder(r.Body).Decode(posted)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	report := models.NewStarvationReport("host1", time.Now().UTC())
+	report.Starved = append(report.Starved, &models.PendingQueue{
+		Action:           constants.ActionRestore,
+		Institution:      "test.edu",
+		PendingCount:     2,
+		OldestWorkItemId: 999,
+	})
+	response := client.QueueStarvationAlertSend(report)
+	assert.Equal(t, "POST", response.Request.Method)
+	assert.Equal(t, "/api/v2/notifications/queue_starvation/", response.Request.URL.Opaque)
+	assert.Nil(t, response.Error)
+	require.NotNil(t, posted)
+	assert.Equal(t, "host1", posted.Host)
+	require.Equal(t, 1, len(posted.Starved))
+	assert.Equal(t, 999, posted.Starved[0].OldestWorkItemId)
+}
+
+func TestWorkItemListInvalidJson(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"count": 2, "next": null, "previous": null, "results": [
+			{"id": 1, "name": "bag1.tar", "bucket": "aptrust.receiving.test.edu", "action": "Ingest"},
+			{"id": 2, "name": "bag2.tar", "action": "Ingest"}]}`)
+	}))
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	response := client.WorkItemList(nil)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, "WorkItem results[1]: required field 'bucket' (string) is missing", response.Error.Error())
+}
+
 func TestWorkItemList(t *testing.T) {
 	testServer := httptest.NewServer(http.HandlerFunc(workItemListHandler))
 	defer testServer.Close()
@@ -882,6 +1004,141 @@ func TestWorkItemSave(t *testing.T) {
 	assert.NotEqual(t, origModTime, obj.UpdatedAt)
 }
 
+func TestSaveInvalidRecords(t *testing.T) {
+	requests := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	// Invalid records fail locally, and never go to Pharos.
+	workItem := testutil.MakeWorkItem()
+	workItem.Action = "Dance"
+	response := client.WorkItemSave(workItem)
+	require.NotNil(t, response.Error)
+	assert.Contains(t, response.Error.Error(), "Action 'Dance' is not one of: ")
+	assert.Nil(t, response.Request)
+
+	obj := testutil.MakeIntellectualObject(2, 0, 0, 0)
+	obj.Title = ""
+	response = client.IntellectualObjectSave(obj)
+	require.NotNil(t, response.Error)
+	assert.Contains(t, response.Error.Error(), "Title is required")
+
+	for _, gf := range obj.GenericFiles {
+		gf.Id = 0
+	}
+	obj.GenericFiles[1].FileFormat = ""
+	response = client.GenericFileSaveBatch(obj.GenericFiles)
+	require.NotNil(t, response.Error)
+	assert.Contains(t, response.Error.Error(), "FileFormat is required")
+	response = client.GenericFileSave(obj.GenericFiles[1])
+	require.NotNil(t, response.Error)
+
+	checksum := testutil.MakeChecksum()
+	checksum.Algorithm = "crc32"
+	response = client.ChecksumSave(checksum, "test.edu/obj1/file.txt")
+	require.NotNil(t, response.Error)
+
+	event := testutil.MakePremisEvent()
+	event.Identifier = ""
+	response = client.PremisEventSave(event)
+	require.NotNil(t, response.Error)
+
+	assert.Equal(t, 0, requests)
+}
+
+func TestWorkItemStatusUpdateBatch(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(workItemStatusUpdateBatchHandler))
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	items := make([]*models.WorkItem, 3)
+	for i := range items {
+		items[i] = testutil.MakeWorkItem()
+		items[i].Id = 500 + i
+		items[i].Status = constants.StatusPending
+		items[i].Note = "Requeued"
+	}
+	response := client.WorkItemStatusUpdateBatch(items)
+
+	// Check the request URL and method
+	assert.Equal(t, "PUT", response.Request.Method)
+	assert.Equal(t, "/api/v2/items/update_batch/", response.Request.URL.Opaque)
+
+	require.Nil(t, response.Error)
+	saved := response.WorkItems()
+	assert.EqualValues(t, "WorkItem", response.ObjectType())
+	require.Equal(t, 3, len(saved))
+	for i, item := range saved {
+		assert.Equal(t, 500+i, item.Id)
+		assert.Equal(t, constants.StatusPending, item.Status)
+		assert.Equal(t, "Requeued", item.Note)
+		// We send only status fields.
+		assert.Empty(t, item.Name)
+	}
+
+	// Empty lists and unsaved items are errors.
+	response = client.WorkItemStatusUpdateBatch([]*models.WorkItem{})
+	assert.NotNil(t, response.Error)
+	assert.Nil(t, response.Request)
+	items[1].Id = 0
+	response = client.WorkItemStatusUpdateBatch(items)
+	assert.NotNil(t, response.Error)
+	assert.Nil(t, response.Request)
+}
+
+func TestWorkItemBatchSave(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(workItemBatchSaveHandler))
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	items := make([]*models.WorkItem, 3)
+	for i := range items {
+		items[i] = testutil.MakeWorkItem()
+		items[i].Id = 0
+		items[i].Name = fmt.Sprintf("bag_%d.tar", i)
+	}
+	items[2].Id = 502
+	response := client.WorkItemBatchSave(items)
+
+	// Check the request URL and method
+	assert.Equal(t, "PUT", response.Request.Method)
+	assert.Equal(t, "/api/v2/items/save_batch/", response.Request.URL.Opaque)
+
+	require.Nil(t, response.Error)
+	saved := response.WorkItems()
+	assert.EqualValues(t, "WorkItem", response.ObjectType())
+	require.Equal(t, 3, len(saved))
+	for i, item := range saved {
+		// We send all fields, not just status fields.
+		assert.Equal(t, items[i].Name, item.Name)
+		assert.Equal(t, items[i].Bucket, item.Bucket)
+		assert.Equal(t, items[i].ETag, item.ETag)
+	}
+	// New items get ids, and existing items keep theirs.
+	assert.Equal(t, 1000, saved[0].Id)
+	assert.Equal(t, 1001, saved[1].Id)
+	assert.Equal(t, 502, saved[2].Id)
+
+	// Empty lists and invalid items are errors.
+	response = client.WorkItemBatchSave([]*models.WorkItem{})
+	assert.NotNil(t, response.Error)
+	assert.Nil(t, response.Request)
+	items[1].Name = ""
+	response = client.WorkItemBatchSave(items)
+	assert.NotNil(t, response.Error)
+	assert.Nil(t, response.Request)
+}
+
 func TestWorkStateItemGet(t *testing.T) {
 	testServer := httptest.NewServer(http.HandlerFunc(workItemStateGetHandler))
 	defer testServer.Close()
@@ -1015,6 +1272,9 @@ func sampleParams() url.Values {
 
 func institutionGetHandler(w http.ResponseWriter, r *http.Request) {
 	obj := testutil.MakeInstitution()
+	obj.QuotaBytes = 5000000
+	obj.BytesUsed = 1200000
+	obj.BytesUsedAsOf = time.Now().UTC()
 	objJson, _ := json.Marshal(obj)
 	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprintln(w, string(objJson))
@@ -1333,6 +1593,51 @@ func workItemSaveHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, string(objJson))
 }
 
+// workItemStatusUpdateBatchHandler returns a list of WorkItems built
+// from the status fields in the request, as if they had been saved.
+func workItemStatusUpdateBatchHandler(w http.ResponseWriter, r *http.Request) {
+	batch := make([]*models.WorkItem, 0)
+	err := json.NewDecoder(r.Body).Decode(&batch)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error decoding JSON data: %v", err)
+		fmt.Fprintln(w, "")
+		return
+	}
+	for _, item := range batch {
+		item.UpdatedAt = time.Now().UTC()
+	}
+	data := listResponseData()
+	data["results"] = batch
+	listJson, _ := json.Marshal(data)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, string(listJson))
+}
+
+func workItemBatchSaveHandler(w http.ResponseWriter, r *http.Request) {
+	batch := make([]*models.WorkItem, 0)
+	err := json.NewDecoder(r.Body).Decode(&batch)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error decoding JSON data: %v", err)
+		fmt.Fprintln(w, "")
+		return
+	}
+	// Assign IDs to new items, as if they've been saved.
+	nextId := 1000
+	for _, item := range batch {
+		if item.Id == 0 {
+			item.Id = nextId
+			nextId++
+			item.CreatedAt = time.Now().UTC()
+		}
+		item.UpdatedAt = time.Now().UTC()
+	}
+	data := listResponseData()
+	data["results"] = batch
+	listJson, _ := json.Marshal(data)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, string(listJson))
+}
+
 // -------------------------------------------------------------------------
 // WorkItemState handlers
 // -------------------------------------------------------------------------
@@ -1365,3 +1670,76 @@ func workItemStateSaveHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprintln(w, string(objJson))
 }
+
+func TestNewInstitutionScopedPharosClient(t *testing.T) {
+	client, err := network.NewInstitutionScopedPharosClient("http://example.com", "v2", "user", "key", "college.edu")
+	require.Nil(t, err)
+	assert.Equal(t, "college.edu", client.Institution())
+
+	_, err = network.NewInstitutionScopedPharosClient("http://example.com", "v2", "user", "key", "")
+	assert.NotNil(t, err)
+
+	client, err = network.NewPharosClient("http://example.com", "v2", "user", "key")
+	require.Nil(t, err)
+	assert.Equal(t, "", client.Institution())
+}
+
+func TestInstitutionScopedPharosClient_InScope(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(intellectualObjectGetHandler))
+	defer testServer.Close()
+	client, err := network.NewInstitutionScopedPharosClient(testServer.URL, "v2", "user", "key", "college.edu")
+	require.Nil(t, err)
+
+	response := client.IntellectualObjectGet("college.edu/bag", false, false)
+	require.Nil(t, response.Error)
+	assert.Equal(t, "college.edu", response.Request.Header.Get("X-Pharos-API-Institution"))
+	assert.Equal(t, "/api/v2/objects/college.edu%2Fbag?institution_identifier=college.edu",
+		response.Request.URL.Opaque)
+
+	testServer = httptest.NewServer(http.HandlerFunc(workItemListHandler))
+	defer testServer.Close()
+	client, err = network.NewInstitutionScopedPharosClient(testServer.URL, "v2", "user", "key", "college.edu")
+	require.Nil(t, err)
+	params := url.Values{}
+	params.Set("name", "bag.tar")
+	response = client.WorkItemList(params)
+	require.Nil(t, response.Error)
+	assert.Equal(t, "/api/v2/items/?institution_identifier=college.edu&name=bag.tar",
+		response.Request.URL.Opaque)
+}
+
+func TestInstitutionScopedPharosClient_OutOfScope(t *testing.T) {
+	requestsReceived := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsReceived++
+	}))
+	defer testServer.Close()
+	client, err := network.NewInstitutionScopedPharosClient(testServer.URL, "v2", "user", "key", "college.edu")
+	require.Nil(t, err)
+
+	response := client.IntellectualObjectGet("other.edu/bag", false, false)
+	require.NotNil(t, response.Error)
+	assert.Contains(t, response.Error.Error(), "other.edu/bag")
+
+	response = client.GenericFileGet("other.edu/bag/data/file.txt", false)
+	assert.NotNil(t, response.Error)
+
+	response = client.InstitutionGet("other.edu")
+	assert.NotNil(t, response.Error)
+
+	params := url.Values{}
+	params.Set("institution", "other.edu")
+	response = client.IntellectualObjectList(params)
+	assert.NotNil(t, response.Error)
+
+	params = url.Values{}
+	params.Set("institution_identifier", "other.edu")
+	response = client.WorkItemList(params)
+	assert.NotNil(t, response.Error)
+
+	// Lookalike institution identifiers are out of scope too.
+	response = client.IntellectualObjectGet("college.edu.evil/bag", false, false)
+	assert.NotNil(t, response.Error)
+
+	assert.Equal(t, 0, requestsReceived)
+}
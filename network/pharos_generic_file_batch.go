@@ -0,0 +1,98 @@
+package network
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/models"
+)
+
+// DefaultGenericFileBatchSize is the number of GenericFiles
+// apt_record sends in each GenericFileSaveBatchResults request if
+// its config doesn't specify a batch size.
+const DefaultGenericFileBatchSize = 100
+
+// GenericFileSaveResult describes the outcome of saving one new
+// GenericFile with GenericFileSaveBatchResults.
+type GenericFileSaveResult struct {
+	// GenericFile is the file the caller asked us to save.
+	GenericFile *models.GenericFile
+
+	// Saved is the copy of the file Pharos returned, with its new
+	// id and timestamps, and those of its checksums and events.
+	// It's nil if the file was not saved.
+	Saved *models.GenericFile
+
+	// Error describes why the file was not saved. It's nil if the
+	// file was saved.
+	Error error
+
+	// Response is the response to the batch request that included
+	// this file. Files in the same batch share a response.
+	Response *PharosResponse
+}
+
+// GenericFileSaveBatchResults creates new GenericFile records in
+// Pharos in a single request, like GenericFileSaveBatch, but returns
+// one result for each file in objList, in the same order. Callers
+// with too many files for one request split them into batches of
+// WorkerConfig.PharosBatchSize, as apt_record does.
+func (client *PharosClient) GenericFileSaveBatchResults(objList []*models.GenericFile) []*GenericFileSaveResult {
+	results := newGenericFileSaveResults(objList)
+	client.saveGenericFileBatch(results)
+	return results
+}
+
+// newGenericFileSaveResults returns a result for each file in
+// objList, with an error for each file that doesn't belong to the
+// same IntellectualObject as the first.
+func newGenericFileSaveResults(objList []*models.GenericFile) []*GenericFileSaveResult {
+	results := make([]*GenericFileSaveResult, len(objList))
+	for i, gf := range objList {
+		results[i] = &GenericFileSaveResult{GenericFile: gf}
+		if gf.IntellectualObjectId != objList[0].IntellectualObjectId {
+			results[i].Error = fmt.Errorf("GenericFile %s belongs to object %d, "+
+				"but this batch is for object %d", gf.Identifier,
+				gf.IntellectualObjectId, objList[0].IntellectualObjectId)
+		}
+	}
+	return results
+}
+
+// saveGenericFileBatch saves the files in results that don't already
+// have an error in a single request, and fills in the results.
+func (client *PharosClient) saveGenericFileBatch(results []*GenericFileSaveResult) {
+	batch := make([]*models.GenericFile, 0, len(results))
+	for _, result := range results {
+		if result.Error == nil {
+			batch = append(batch, result.GenericFile)
+		}
+	}
+	if len(batch) == 0 {
+		return
+	}
+	resp := client.GenericFileSaveBatch(batch)
+
+	// Pharos saves the batch in a transaction, but match files by
+	// identifier anyway, so we record whatever it says it saved.
+	saved := make(map[string]*models.GenericFile, len(batch))
+	for _, gf := range resp.GenericFiles() {
+		if gf != nil {
+			saved[gf.Identifier] = gf
+		}
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			continue
+		}
+		result.Response = resp
+		result.Saved = saved[result.GenericFile.Identifier]
+		if result.Saved != nil {
+			continue
+		}
+		if resp.Error != nil {
+			result.Error = resp.Error
+		} else {
+			result.Error = fmt.Errorf("Pharos did not return GenericFile %s "+
+				"after saving batch", result.GenericFile.Identifier)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package network_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// batchServer records the number of files in each batch it gets,
+// and passes the batch to genericFileSaveBatchHandler. It returns
+// 500 for the batch numbers in failBatches.
+type batchServer struct {
+	mutex       sync.Mutex
+	batchSizes  []int
+	failBatches map[int]bool
+}
+
+func (server *batchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	batch := make([]*models.GenericFileForPharos, 0)
+	json.Unmarshal(body, &batch)
+	server.mutex.Lock()
+	server.batchSizes = append(server.batchSizes, len(batch))
+	fail := server.failBatches[len(server.batchSizes)-1]
+	server.mutex.Unlock()
+	if fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	genericFileSaveBatchHandler(w, r)
+}
+
+func TestGenericFileSaveBatchResultsFailure(t *testing.T) {
+	server := &batchServer{failBatches: map[int]bool{0: true}}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	client.SetRetryPolicy(network.PharosRetryPolicy{MaxAttempts: 1})
+
+	obj := testutil.MakeIntellectualObject(3, 2, 2, 0)
+	for _, gf := range obj.GenericFiles {
+		gf.Id = 0
+		gf.IntellectualObjectId = obj.Id
+	}
+	results := client.GenericFileSaveBatchResults(obj.GenericFiles)
+	assert.Equal(t, []int{3}, server.batchSizes)
+	require.Equal(t, 3, len(results))
+	for i, result := range results {
+		assert.Equal(t, obj.GenericFiles[i], result.GenericFile)
+		assert.NotNil(t, result.Error)
+		assert.Nil(t, result.Saved)
+		require.NotNil(t, result.Response)
+		assert.Equal(t, 500, result.Response.Response.StatusCode)
+	}
+	assert.True(t, results[0].Response == results[2].Response)
+}
+
+func TestGenericFileSaveBatchResultsValidation(t *testing.T) {
+	server := &batchServer{}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	// Files must all belong to the first file's object.
+	obj := testutil.MakeIntellectualObject(3, 0, 0, 0)
+	for _, gf := range obj.GenericFiles {
+		gf.Id = 0
+		gf.IntellectualObjectId = obj.Id
+	}
+	obj.GenericFiles[1].IntellectualObjectId = obj.Id + 1
+
+	results := client.GenericFileSaveBatchResults(obj.GenericFiles)
+	assert.Equal(t, []int{2}, server.batchSizes)
+	require.Equal(t, 3, len(results))
+	assert.Nil(t, results[0].Error)
+	assert.NotNil(t, results[1].Error)
+	assert.Nil(t, results[1].Response)
+	assert.Nil(t, results[2].Error)
+
+	assert.Empty(t, client.GenericFileSaveBatchResults(nil))
+}
+
+func TestGenericFileSaveBatchResults(t *testing.T) {
+	server := &batchServer{}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	// All of the files go in one request, however many there are.
+	obj := testutil.MakeIntellectualObject(150, 0, 0, 0)
+	for _, gf := range obj.GenericFiles {
+		gf.Id = 0
+		gf.IntellectualObjectId = obj.Id
+	}
+	results := client.GenericFileSaveBatchResults(obj.GenericFiles)
+	assert.Equal(t, []int{150}, server.batchSizes)
+	require.Equal(t, 150, len(results))
+	for i, result := range results {
+		assert.Equal(t, obj.GenericFiles[i], result.GenericFile)
+		assert.Nil(t, result.Error)
+		require.NotNil(t, result.Saved)
+		assert.Equal(t, result.GenericFile.Identifier, result.Saved.Identifier)
+	}
+}
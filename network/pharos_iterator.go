@@ -0,0 +1,189 @@
+package network
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/models"
+	"net/url"
+	"strconv"
+)
+
+// pharosPager fetches successive pages of results from a Pharos
+// list endpoint, following the next-page links in each response.
+type pharosPager struct {
+	params   url.Values
+	fetch    func(url.Values) *PharosResponse
+	response *PharosResponse
+	onPage   func(*PharosResponse)
+	err      error
+	started  bool
+}
+
+// newPharosPager returns a pager that calls fetch with a copy of params,
+// starting at page one. If perPage is greater than zero, it sets the
+// page size.
+func newPharosPager(params url.Values, perPage int, fetch func(url.Values) *PharosResponse) *pharosPager {
+	pageParams := url.Values{}
+	for key, values := range params {
+		pageParams[key] = append([]string{}, values...)
+	}
+	if pageParams.Get("page") == "" {
+		pageParams.Set("page", "1")
+	}
+	if perPage > 0 {
+		pageParams.Set("per_page", strconv.Itoa(perPage))
+	}
+	return &pharosPager{
+		params: pageParams,
+		fetch:  fetch,
+	}
+}
+
+// nextPage fetches the next page of results. It returns false if
+// there are no more pages, or if the request failed.
+func (pager *pharosPager) nextPage() bool {
+	if pager.err != nil {
+		return false
+	}
+	if pager.started {
+		if !pager.response.HasNextPage() {
+			return false
+		}
+		pager.params = pager.response.ParamsForNextPage()
+	}
+	pager.started = true
+	pager.response = pager.fetch(pager.params)
+	if pager.response.Error != nil {
+		pager.err = pager.response.Error
+		return false
+	}
+	if pager.response.Response != nil && pager.response.Response.StatusCode != 200 {
+		pager.err = fmt.Errorf("%s %s returned status code %d",
+			pager.response.Request.Method, pager.response.Request.URL,
+			pager.response.Response.StatusCode)
+		return false
+	}
+	if pager.onPage != nil {
+		pager.onPage(pager.response)
+	}
+	return true
+}
+
+// WorkItemIterator iterates over all of the WorkItems that match a
+// query, fetching pages from Pharos as it goes. Use it like this:
+//
+//	iterator := client.WorkItemIterator(params, 100)
+//	for iterator.Next() {
+//		workItem := iterator.WorkItem()
+//		...
+//	}
+//	if iterator.Err() != nil {
+//		...
+//	}
+type WorkItemIterator struct {
+	pager *pharosPager
+	items []*models.WorkItem
+	index int
+}
+
+// WorkItemIterator returns an iterator over the WorkItems that match
+// params, fetching perPage items at a time. If perPage is zero, the
+// page size is whatever params says, or the Pharos default.
+func (client *PharosClient) WorkItemIterator(params url.Values, perPage int) *WorkItemIterator {
+	return &WorkItemIterator{
+		pager: newPharosPager(params, perPage, client.WorkItemList),
+	}
+}
+
+// Next advances to the next WorkItem, fetching the next page from
+// Pharos if necessary. It returns false when there are no more items
+// or when a request fails. Check Err to see which.
+func (iterator *WorkItemIterator) Next() bool {
+	iterator.index++
+	for iterator.index >= len(iterator.items) {
+		if !iterator.pager.nextPage() {
+			return false
+		}
+		iterator.items = iterator.pager.response.WorkItems()
+		iterator.index = 0
+	}
+	return true
+}
+
+// WorkItem returns the current WorkItem.
+func (iterator *WorkItemIterator) WorkItem() *models.WorkItem {
+	return iterator.items[iterator.index]
+}
+
+// OnPage sets a function to call with each page's response, after it
+// loads successfully. This is useful for logging.
+func (iterator *WorkItemIterator) OnPage(onPage func(*PharosResponse)) *WorkItemIterator {
+	iterator.pager.onPage = onPage
+	return iterator
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (iterator *WorkItemIterator) Err() error {
+	return iterator.pager.err
+}
+
+// Response returns the response for the last page requested, or nil
+// if Next hasn't been called.
+func (iterator *WorkItemIterator) Response() *PharosResponse {
+	return iterator.pager.response
+}
+
+// GenericFileIterator iterates over all of the GenericFiles that match
+// a query, fetching pages from Pharos as it goes. It works like
+// WorkItemIterator.
+type GenericFileIterator struct {
+	pager *pharosPager
+	files []*models.GenericFile
+	index int
+}
+
+// GenericFileIterator returns an iterator over the GenericFiles that
+// match params, fetching perPage files at a time. If perPage is zero,
+// the page size is whatever params says, or the Pharos default.
+func (client *PharosClient) GenericFileIterator(params url.Values, perPage int) *GenericFileIterator {
+	return &GenericFileIterator{
+		pager: newPharosPager(params, perPage, client.GenericFileList),
+	}
+}
+
+// Next advances to the next GenericFile, fetching the next page from
+// Pharos if necessary. It returns false when there are no more files
+// or when a request fails. Check Err to see which.
+func (iterator *GenericFileIterator) Next() bool {
+	iterator.index++
+	for iterator.index >= len(iterator.files) {
+		if !iterator.pager.nextPage() {
+			return false
+		}
+		iterator.files = iterator.pager.response.GenericFiles()
+		iterator.index = 0
+	}
+	return true
+}
+
+// GenericFile returns the current GenericFile.
+func (iterator *GenericFileIterator) GenericFile() *models.GenericFile {
+	return iterator.files[iterator.index]
+}
+
+// OnPage sets a function to call with each page's response, after it
+// loads successfully. This is useful for logging.
+func (iterator *GenericFileIterator) OnPage(onPage func(*PharosResponse)) *GenericFileIterator {
+	iterator.pager.onPage = onPage
+	return iterator
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (iterator *GenericFileIterator) Err() error {
+	return iterator.pager.err
+}
+
+// Response returns the response for the last page requested, or nil
+// if Next hasn't been called.
+func (iterator *GenericFileIterator) Response() *PharosResponse {
+	return iterator.pager.response
+}
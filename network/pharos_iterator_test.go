@@ -0,0 +1,164 @@
+package network_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// pagedListServer serves three pages of results, built by makePage,
+// and records the query of each request. The second page is empty,
+// to make sure iterators skip empty pages.
+type pagedListServer struct {
+	mutex    sync.Mutex
+	queries  []url.Values
+	makePage func(page int) interface{}
+	failPage int
+}
+
+func (server *pagedListServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	server.queries = append(server.queries, r.URL.Query())
+	server.mutex.Unlock()
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page == server.failPage {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintln(w, `{"error": "Something went wrong"}`)
+		return
+	}
+	data := make(map[string]interface{})
+	data["count"] = 4
+	data["previous"] = nil
+	data["next"] = nil
+	if page < 3 {
+		nextParams := r.URL.Query()
+		nextParams.Set("page", strconv.Itoa(page+1))
+		data["next"] = fmt.Sprintf("http://example.com/items/?%s", nextParams.Encode())
+	}
+	data["results"] = server.makePage(page)
+	listJson, _ := json.Marshal(data)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, string(listJson))
+}
+
+func workItemPage(page int) interface{} {
+	if page == 2 {
+		return []*models.WorkItem{}
+	}
+	list := make([]*models.WorkItem, 2)
+	for i := range list {
+		list[i] = testutil.MakeWorkItem()
+		list[i].Name = fmt.Sprintf("bag_%d_%d.tar", page, i)
+	}
+	return list
+}
+
+func genericFilePage(page int) interface{} {
+	if page == 2 {
+		return []*models.GenericFile{}
+	}
+	list := make([]*models.GenericFile, 2)
+	for i := range list {
+		list[i] = testutil.MakeGenericFile(0, 0, "test.edu/bag")
+		list[i].Identifier = fmt.Sprintf("test.edu/bag/file_%d_%d.txt", page, i)
+	}
+	return list
+}
+
+func TestWorkItemIterator(t *testing.T) {
+	server := &pagedListServer{makePage: workItemPage}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	params := url.Values{}
+	params.Set("item_action", "Ingest")
+	iterator := client.WorkItemIterator(params, 2)
+	pagesLoaded := 0
+	iterator.OnPage(func(resp *network.PharosResponse) {
+		pagesLoaded++
+	})
+	names := make([]string, 0)
+	for iterator.Next() {
+		names = append(names, iterator.WorkItem().Name)
+	}
+	require.Nil(t, iterator.Err())
+	assert.Equal(t, []string{"bag_1_0.tar", "bag_1_1.tar", "bag_3_0.tar", "bag_3_1.tar"}, names)
+	assert.Equal(t, 3, pagesLoaded)
+	assert.False(t, iterator.Next())
+
+	// The iterator should set page and per_page, keep the
+	// caller's params, and leave the caller's params unchanged.
+	require.Equal(t, 3, len(server.queries))
+	for i, query := range server.queries {
+		assert.Equal(t, strconv.Itoa(i+1), query.Get("page"))
+		assert.Equal(t, "2", query.Get("per_page"))
+		assert.Equal(t, "Ingest", query.Get("item_action"))
+	}
+	assert.Equal(t, "", params.Get("page"))
+	assert.Equal(t, "", params.Get("per_page"))
+}
+
+func TestWorkItemIteratorError(t *testing.T) {
+	server := &pagedListServer{makePage: workItemPage, failPage: 3}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	iterator := client.WorkItemIterator(nil, 0)
+	count := 0
+	for iterator.Next() {
+		count++
+	}
+	assert.Equal(t, 2, count)
+	require.NotNil(t, iterator.Err())
+	assert.Contains(t, iterator.Err().Error(), "500")
+	require.NotNil(t, iterator.Response())
+	assert.Equal(t, http.StatusInternalServerError, iterator.Response().Response.StatusCode)
+	assert.False(t, iterator.Next())
+	assert.Equal(t, 3, len(server.queries))
+	assert.Equal(t, "", server.queries[0].Get("per_page"))
+}
+
+func TestGenericFileIterator(t *testing.T) {
+	server := &pagedListServer{makePage: genericFilePage}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	params := url.Values{}
+	params.Set("storage_option", "Standard")
+	iterator := client.GenericFileIterator(params, 50)
+	identifiers := make([]string, 0)
+	for iterator.Next() {
+		identifiers = append(identifiers, iterator.GenericFile().Identifier)
+	}
+	require.Nil(t, iterator.Err())
+	assert.Equal(t, []string{
+		"test.edu/bag/file_1_0.txt",
+		"test.edu/bag/file_1_1.txt",
+		"test.edu/bag/file_3_0.txt",
+		"test.edu/bag/file_3_1.txt",
+	}, identifiers)
+	require.Equal(t, 3, len(server.queries))
+	for _, query := range server.queries {
+		assert.Equal(t, "50", query.Get("per_page"))
+		assert.Equal(t, "Standard", query.Get("storage_option"))
+	}
+}
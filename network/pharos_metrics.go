@@ -0,0 +1,48 @@
+package network
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// PharosRequestObserver receives a record of each request a
+// PharosClient sends, so it can track Pharos latency and error rates.
+// See PharosClient.SetRequestObserver. Implementations must be safe
+// to call from many goroutines at once.
+type PharosRequestObserver interface {
+	// ObservePharosRequest is called after each request, including
+	// each retry. Param endpoint is the path of the request URL,
+	// with identifiers and ids replaced by ":id", such as
+	// "/api/v2/items/:id". Param statusCode is zero if the request
+	// got no response. Param duration is the time from sending the
+	// request to reading the whole response.
+	ObservePharosRequest(method, endpoint string, statusCode int, duration time.Duration)
+}
+
+// endpointName matches path segments that are part of an endpoint's
+// name, such as "items" or "finish_delete", rather than an id or
+// identifier. Identifiers always include a period or an escaped
+// slash, and ids are numbers.
+var endpointName = regexp.MustCompile(`^[a-z_]+$`)
+
+// pharosEndpoint returns the path of request URL u, with ids and
+// identifiers replaced by ":id", so that requests for different
+// records at the same endpoint can be counted together.
+func pharosEndpoint(u *url.URL) string {
+	path := u.EscapedPath()
+	if u.Opaque != "" {
+		// NewJsonRequest puts the path into Opaque, to preserve
+		// escaped slashes. Opaque may include the query.
+		path = strings.SplitN(u.Opaque, "?", 2)[0]
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		// The first two segments are "api" and the API version.
+		if i > 1 && !endpointName.MatchString(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,82 @@
+package network_test
+
+import (
+	"github.com/APTrust/exchange/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type observedRequest struct {
+	method     string
+	endpoint   string
+	statusCode int
+	duration   time.Duration
+}
+
+type testObserver struct {
+	mutex    sync.Mutex
+	requests []observedRequest
+}
+
+func (observer *testObserver) ObservePharosRequest(method, endpoint string, statusCode int, duration time.Duration) {
+	observer.mutex.Lock()
+	defer observer.mutex.Unlock()
+	observer.requests = append(observer.requests,
+		observedRequest{method, endpoint, statusCode, duration})
+}
+
+func TestPharosClientRequestObserver(t *testing.T) {
+	server := &failingServer{statuses: []int{503}}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	client.SetRetryPolicy(testRetryPolicy())
+	observer := &testObserver{}
+	client.SetRequestObserver(observer)
+
+	// One failure and one retry.
+	resp := client.WorkItemGet(1)
+	require.Nil(t, resp.Error)
+	require.Equal(t, 2, len(observer.requests))
+	assert.Equal(t, "GET", observer.requests[0].method)
+	assert.Equal(t, "/api/v2/items/:id", observer.requests[0].endpoint)
+	assert.Equal(t, 503, observer.requests[0].statusCode)
+	assert.Equal(t, 200, observer.requests[1].statusCode)
+	assert.True(t, observer.requests[1].duration > 0)
+
+	// Cached responses don't go to Pharos, so they aren't observed.
+	countingTestServer := httptest.NewServer(&countingServer{})
+	defer countingTestServer.Close()
+	client, err = network.NewPharosClient(countingTestServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	client.SetRequestObserver(observer)
+	client.EnableCache(time.Minute)
+	client.InstitutionGet("college.edu")
+	client.InstitutionGet("college.edu")
+	require.Equal(t, 3, len(observer.requests))
+	assert.Equal(t, "/api/v2/institutions/:id", observer.requests[2].endpoint)
+}
+
+func TestPharosClientRequestObserverConnectionError(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(workItemGetHandler))
+	hostUrl := testServer.URL
+	testServer.Close()
+
+	client, err := network.NewPharosClient(hostUrl, "v2", "user", "key")
+	require.Nil(t, err)
+	client.SetRetryPolicy(network.PharosRetryPolicy{MaxAttempts: 1})
+	observer := &testObserver{}
+	client.SetRequestObserver(observer)
+
+	resp := client.WorkItemGet(1)
+	require.NotNil(t, resp.Error)
+	require.Equal(t, 1, len(observer.requests))
+	assert.Equal(t, 0, observer.requests[0].statusCode)
+}
@@ -0,0 +1,82 @@
+package network
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pharosRateLimiter is a token bucket that limits how often a
+// PharosClient sends requests, so a burst of work doesn't overload
+// Pharos. The bucket holds up to burst tokens, and refills at rate
+// tokens per second. Each request takes one token, and waits for
+// one if the bucket is empty.
+type pharosRateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mutex  sync.Mutex
+}
+
+// newPharosRateLimiter returns a limiter that allows requestsPerSecond
+// requests per second on average, and up to burst requests at once.
+// The bucket starts out full.
+func newPharosRateLimiter(requestsPerSecond float64, burst int) *pharosRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &pharosRateLimiter{
+		rate:   requestsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until the caller may send a request, or until ctx is
+// cancelled. It returns ctx.Err() if ctx was cancelled first.
+func (limiter *pharosRateLimiter) wait(ctx context.Context) error {
+	delay := limiter.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		limiter.unreserve()
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token from the bucket, and returns how long the
+// caller has to wait before the token is really there. The bucket
+// can go negative, which puts later callers in line behind this one.
+func (limiter *pharosRateLimiter) reserve() time.Duration {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	now := time.Now()
+	limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.rate
+	if limiter.tokens > limiter.burst {
+		limiter.tokens = limiter.burst
+	}
+	limiter.last = now
+	limiter.tokens--
+	if limiter.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-limiter.tokens / limiter.rate * float64(time.Second))
+}
+
+// unreserve puts back a token the caller reserved but didn't use.
+func (limiter *pharosRateLimiter) unreserve() {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	limiter.tokens++
+	if limiter.tokens > limiter.burst {
+		limiter.tokens = limiter.burst
+	}
+}
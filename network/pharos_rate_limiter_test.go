@@ -0,0 +1,93 @@
+package network_test
+
+import (
+	"context"
+	"github.com/APTrust/exchange/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPharosClientRateLimit(t *testing.T) {
+	server := &countingServer{}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	// A burst of two requests goes right away. After that,
+	// requests go one every 50 milliseconds.
+	client.SetRateLimit(20, 2)
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		resp := client.InstitutionGet("college.edu")
+		require.Nil(t, resp.Error)
+	}
+	assert.True(t, time.Since(start) < 40*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		resp := client.InstitutionGet("college.edu")
+		require.Nil(t, resp.Error)
+	}
+	assert.True(t, time.Since(start) >= 140*time.Millisecond)
+	assert.Equal(t, 5, server.requestCount())
+
+	// Zero removes the limit.
+	client.SetRateLimit(0, 0)
+	start = time.Now()
+	for i := 0; i < 5; i++ {
+		client.InstitutionGet("college.edu")
+	}
+	assert.True(t, time.Since(start) < 40*time.Millisecond)
+}
+
+func TestPharosClientRateLimitCancel(t *testing.T) {
+	server := &countingServer{}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	client.SetRateLimit(0.5, 1)
+
+	resp := client.InstitutionGet("college.edu")
+	require.Nil(t, resp.Error)
+
+	// The next request would wait two seconds for the rate limit,
+	// but gives up when its context is cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	resp = client.WithContext(ctx).InstitutionGet("college.edu")
+	assert.Equal(t, context.DeadlineExceeded, resp.Error)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, 1, server.requestCount())
+}
+
+func TestPharosClientWithContextCancelsRetry(t *testing.T) {
+	server := &failingServer{statuses: []int{500, 500, 500}}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	client.SetRetryPolicy(network.PharosRetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   10 * time.Second,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	resp := client.WithContext(ctx).WorkItemGet(1)
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, 500, resp.Response.StatusCode)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, 1, server.requestCount())
+
+	// The copy has the original's settings.
+	assert.Equal(t, client.RetryPolicy(), client.WithContext(ctx).RetryPolicy())
+	assert.Equal(t, client.HostUrl(), client.WithContext(ctx).HostUrl())
+}
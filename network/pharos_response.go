@@ -84,6 +84,17 @@ type PharosResponse struct {
 	// The raw data contained in the body of the HTTP
 	// respone.
 	data []byte
+
+	// recordExists, if set, looks up the record a POST creates.
+	// DoRequest retries a failed POST only if this says the
+	// record does not exist. See PharosRetryPolicy.
+	recordExists func() (bool, error)
+
+	// createsRecords is true for a PUT that creates new records,
+	// such as a WorkItemBatchSave that includes new WorkItems.
+	// Unlike other PUTs, it isn't safe to resend, since Pharos may
+	// have created the records before the request failed.
+	createsRecords bool
 }
 
 type PharosObjectType string
@@ -331,6 +342,9 @@ func (resp *PharosResponse) decodeAsObjectList() error {
 		return err
 	}
 	resp.Error = json.Unmarshal(data, &temp)
+	if resp.Error == nil {
+		resp.Error = validatePharosJsonList(data, PharosIntellectualObject)
+	}
 	resp.Count = temp.Count
 	resp.Next = temp.Next
 	resp.Previous = temp.Previous
@@ -379,6 +393,9 @@ func (resp *PharosResponse) decodeAsGenericFileList() error {
 		return err
 	}
 	resp.Error = json.Unmarshal(data, &temp)
+	if resp.Error == nil {
+		resp.Error = validatePharosJsonList(data, PharosGenericFile)
+	}
 	resp.Count = temp.Count
 	resp.Next = temp.Next
 	resp.Previous = temp.Previous
@@ -451,6 +468,9 @@ func (resp *PharosResponse) decodeAsWorkItemList() error {
 		return err
 	}
 	resp.Error = json.Unmarshal(data, &temp)
+	if resp.Error == nil {
+		resp.Error = validatePharosJsonList(data, PharosWorkItem)
+	}
 	resp.Count = temp.Count
 	resp.Next = temp.Next
 	resp.Previous = temp.Previous
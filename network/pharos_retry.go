@@ -0,0 +1,146 @@
+package network
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// DefaultPharosRetryDelay is the delay before the first retry when
+// a PharosRetryPolicy doesn't set BaseDelay.
+const DefaultPharosRetryDelay = 1 * time.Second
+
+// DefaultPharosMaxRetryDelay is the longest PharosClient will wait
+// between attempts when a PharosRetryPolicy doesn't set MaxDelay.
+const DefaultPharosMaxRetryDelay = 30 * time.Second
+
+// PharosRetryPolicy tells PharosClient how to retry requests that
+// fail with a connection error or a 5xx response. Other failures,
+// such as 4xx responses, are never retried, since sending the same
+// request again would give the same result.
+//
+// Note that a request that failed with a 5xx response or a broken
+// connection may have succeeded on the server. So PharosClient
+// retries only GET, HEAD, PUT and DELETE requests, which have the
+// same effect no matter how many times Pharos gets them. Resending a
+// POST that Pharos already committed would create duplicate
+// WorkItems, events or checksums, so PharosClient retries a POST only
+// when it can look up the record the POST creates, and Pharos says
+// that record does not exist. IntellectualObjectSave, GenericFileSave
+// and PremisEventSave can do this, because their records have unique
+// identifiers. Other POSTs are never retried, and neither is a
+// WorkItemBatchSave that creates new WorkItems, even though it's a PUT.
+//
+// The zero value makes one attempt, with no retries.
+type PharosRetryPolicy struct {
+	// MaxAttempts is the most times to send each request, including
+	// the first. Values less than two mean don't retry.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. The delay
+	// doubles with each retry after that. Defaults to
+	// DefaultPharosRetryDelay.
+	BaseDelay time.Duration
+
+	// MaxDelay is the longest delay between attempts. Defaults to
+	// DefaultPharosMaxRetryDelay.
+	MaxDelay time.Duration
+
+	// Jitter is the fraction, between zero and one, by which to
+	// randomly shorten each delay, so that workers that failed
+	// together don't all retry together. 0.5 means each delay is
+	// somewhere between half and all of the full delay.
+	Jitter float64
+}
+
+// Delay returns how long to wait after the specified attempt fails,
+// before making the next one. Attempts are numbered from one.
+func (policy PharosRetryPolicy) Delay(attempt int) time.Duration {
+	baseDelay := policy.BaseDelay
+	if baseDelay <= 0 {
+		baseDelay = DefaultPharosRetryDelay
+	}
+	maxDelay := policy.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultPharosMaxRetryDelay
+	}
+	delay := float64(baseDelay) * math.Pow(2, float64(attempt-1))
+	if delay > float64(maxDelay) {
+		delay = float64(maxDelay)
+	}
+	jitter := math.Min(math.Max(policy.Jitter, 0), 1)
+	delay -= delay * jitter * rand.Float64()
+	return time.Duration(delay)
+}
+
+// shouldRetry returns true if resp failed in a way that may succeed
+// on another attempt: the connection failed, the response body
+// couldn't be read, or the server returned a 5xx status. For a
+// request that isn't idempotent, it returns true only if resp can
+// check whether the request created its record. DoRequest makes that
+// check with safeToResend before it retries.
+func (policy PharosRetryPolicy) shouldRetry(resp *PharosResponse, attempt int) bool {
+	if attempt >= policy.MaxAttempts || resp.Error == nil || resp.Request == nil {
+		return false
+	}
+	if resp.Response != nil && resp.Response.StatusCode < 500 {
+		return false
+	}
+	return resp.isIdempotent(resp.Request.Method) || resp.recordExists != nil
+}
+
+// isIdempotent returns true if sending resp's request, which uses the
+// specified HTTP method, more than once has the same effect as
+// sending it once.
+func (resp *PharosResponse) isIdempotent(method string) bool {
+	return isIdempotent(method) && !resp.createsRecords
+}
+
+// isIdempotent returns true if sending a request with the specified
+// HTTP method more than once has the same effect as sending it once.
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// safeToResend looks up the record that resp's failed POST would have
+// created, and returns true if Pharos says it does not exist. If the
+// record exists, or the lookup fails, this adds that to resp.Error
+// and returns false.
+func (resp *PharosResponse) safeToResend() bool {
+	if resp.recordExists == nil {
+		return false
+	}
+	exists, err := resp.recordExists()
+	if err != nil {
+		resp.Error = fmt.Errorf("%v (Not retrying, because we could not "+
+			"check whether Pharos created the record: %v)", resp.Error, err)
+		return false
+	}
+	if exists {
+		resp.Error = fmt.Errorf("%v (Not retrying, because Pharos "+
+			"created the record despite the error.)", resp.Error)
+		return false
+	}
+	return true
+}
+
+// recordExistsAt returns a function that tells whether the record at
+// relativeUrl exists in Pharos. A POST that creates a record can set
+// this as its response's recordExists, so DoRequest can retry it
+// without creating the record twice.
+func (client *PharosClient) recordExistsAt(relativeUrl string) func() (bool, error) {
+	return func() (bool, error) {
+		resp := NewPharosResponse("")
+		client.DoRequest(resp, "GET", client.BuildUrl(relativeUrl), nil)
+		if resp.Response != nil && resp.Response.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return resp.Error == nil, resp.Error
+	}
+}
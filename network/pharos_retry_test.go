@@ -0,0 +1,277 @@
+package network_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failingServer returns the status codes in statuses, one per
+// request, and then returns a WorkItem for a GET, or echoes the
+// request body back for other methods, as if it saved the record. It
+// records the body of each request. If lookupStatus is set, it
+// answers GET requests with that status instead, as if looking up a
+// record a POST created, and counts them in lookups.
+type failingServer struct {
+	mutex        sync.Mutex
+	statuses     []int
+	bodies       []string
+	lookupStatus int
+	lookups      int
+}
+
+func (server *failingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if r.Method == "GET" && server.lookupStatus != 0 {
+		server.lookups += 1
+		w.WriteHeader(server.lookupStatus)
+		fmt.Fprintln(w, `{}`)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	server.bodies = append(server.bodies, string(body))
+	if len(server.statuses) > 0 {
+		status := server.statuses[0]
+		server.statuses = server.statuses[1:]
+		w.WriteHeader(status)
+		fmt.Fprintln(w, `{"error": "Try again"}`)
+		return
+	}
+	if r.Method == "GET" {
+		workItemGetHandler(w, r)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	workItemSaveHandler(w, r)
+}
+
+func (server *failingServer) requestCount() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return len(server.bodies)
+}
+
+func testRetryPolicy() network.PharosRetryPolicy {
+	return network.PharosRetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		Jitter:      0.5,
+	}
+}
+
+func TestPharosRetryPolicyDelay(t *testing.T) {
+	policy := network.PharosRetryPolicy{
+		BaseDelay: 100 * time.Millisecond,
+		MaxDelay:  time.Second,
+	}
+	assert.Equal(t, 100*time.Millisecond, policy.Delay(1))
+	assert.Equal(t, 200*time.Millisecond, policy.Delay(2))
+	assert.Equal(t, 400*time.Millisecond, policy.Delay(3))
+	assert.Equal(t, time.Second, policy.Delay(5))
+
+	// Jitter shortens the delay by up to that fraction.
+	policy.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		delay := policy.Delay(2)
+		assert.True(t, delay >= 100*time.Millisecond && delay <= 200*time.Millisecond)
+	}
+
+	// Defaults
+	policy = network.PharosRetryPolicy{}
+	assert.Equal(t, network.DefaultPharosRetryDelay, policy.Delay(1))
+	assert.Equal(t, network.DefaultPharosMaxRetryDelay, policy.Delay(20))
+}
+
+func TestPharosClientRetriesServerErrors(t *testing.T) {
+	server := &failingServer{statuses: []int{503, 500}}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	client.SetRetryPolicy(testRetryPolicy())
+	assert.Equal(t, testRetryPolicy(), client.RetryPolicy())
+
+	// Updating a WorkItem is a PUT, which is safe to resend.
+	workItem := testutil.MakeWorkItem()
+	workItem.Id = 1000
+	resp := client.WorkItemSave(workItem)
+	require.Nil(t, resp.Error)
+	assert.Equal(t, "PUT", resp.Request.Method)
+	assert.Equal(t, 200, resp.Response.StatusCode)
+	require.NotNil(t, resp.WorkItem())
+	assert.Equal(t, 1000, resp.WorkItem().Id)
+
+	// Each attempt should send the same request body.
+	require.Equal(t, 3, server.requestCount())
+	assert.NotEmpty(t, server.bodies[0])
+	assert.Equal(t, server.bodies[0], server.bodies[1])
+	assert.Equal(t, server.bodies[0], server.bodies[2])
+}
+
+func TestPharosClientStopsAfterMaxAttempts(t *testing.T) {
+	server := &failingServer{statuses: []int{500, 502, 503, 504}}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	client.SetRetryPolicy(testRetryPolicy())
+
+	resp := client.WorkItemGet(1)
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, 503, resp.Response.StatusCode)
+	assert.Equal(t, 3, server.requestCount())
+}
+
+func TestPharosClientDoesNotRetryClientErrors(t *testing.T) {
+	server := &failingServer{statuses: []int{404}}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	client.SetRetryPolicy(testRetryPolicy())
+
+	resp := client.WorkItemGet(1)
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, 404, resp.Response.StatusCode)
+	assert.Equal(t, 1, server.requestCount())
+}
+
+func TestPharosClientDoesNotRetryByDefault(t *testing.T) {
+	server := &failingServer{statuses: []int{500}}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	resp := client.WorkItemGet(1)
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, 1, server.requestCount())
+}
+
+func TestPharosClientDoesNotRetryPost(t *testing.T) {
+	server := &failingServer{statuses: []int{500}}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	client.SetRetryPolicy(testRetryPolicy())
+
+	// Creating a WorkItem is a POST, and we have no way to tell
+	// whether Pharos created it before failing.
+	workItem := testutil.MakeWorkItem()
+	workItem.Id = 0
+	resp := client.WorkItemSave(workItem)
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, "POST", resp.Request.Method)
+	assert.Equal(t, 1, server.requestCount())
+}
+
+func TestPharosClientDoesNotRetryBatchCreate(t *testing.T) {
+	server := &failingServer{statuses: []int{500, 500}}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	client.SetRetryPolicy(testRetryPolicy())
+
+	// A batch save is a PUT, but Pharos may have created the
+	// new WorkItems in it before failing.
+	existing := testutil.MakeWorkItem()
+	existing.Id = 1000
+	newItem := testutil.MakeWorkItem()
+	newItem.Id = 0
+	resp := client.WorkItemBatchSave([]*models.WorkItem{existing, newItem})
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, "PUT", resp.Request.Method)
+	assert.Equal(t, 1, server.requestCount())
+
+	// A batch of existing WorkItems is safe to resend.
+	client.WorkItemBatchSave([]*models.WorkItem{existing})
+	assert.Equal(t, 3, server.requestCount())
+}
+
+func TestPharosClientRetriesPostIfRecordNotCreated(t *testing.T) {
+	server := &failingServer{statuses: []int{502}, lookupStatus: 404}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	client.SetRetryPolicy(testRetryPolicy())
+
+	event := testutil.MakePremisEvent()
+	event.Id = 0
+	resp := client.PremisEventSave(event)
+	require.Nil(t, resp.Error)
+	assert.Equal(t, "POST", resp.Request.Method)
+	require.NotNil(t, resp.PremisEvent())
+	assert.Equal(t, event.Identifier, resp.PremisEvent().Identifier)
+	assert.Equal(t, 2, server.requestCount())
+	assert.Equal(t, 1, server.lookups)
+}
+
+func TestPharosClientDoesNotRetryPostIfRecordCreated(t *testing.T) {
+	server := &failingServer{statuses: []int{502}, lookupStatus: 200}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	client.SetRetryPolicy(testRetryPolicy())
+
+	event := testutil.MakePremisEvent()
+	event.Id = 0
+	resp := client.PremisEventSave(event)
+	require.NotNil(t, resp.Error)
+	assert.Contains(t, resp.Error.Error(), "Pharos created the record")
+	assert.Equal(t, 1, server.requestCount())
+	assert.Equal(t, 1, server.lookups)
+
+	// If the lookup fails, we can't tell, so we don't retry.
+	server = &failingServer{statuses: []int{502}, lookupStatus: 500}
+	testServer2 := httptest.NewServer(server)
+	defer testServer2.Close()
+	client.SetHostUrl(testServer2.URL)
+	resp = client.PremisEventSave(event)
+	require.NotNil(t, resp.Error)
+	assert.Contains(t, resp.Error.Error(), "could not check whether Pharos created the record")
+	assert.Equal(t, 1, server.requestCount())
+}
+
+func TestPharosClientRetriesConnectionErrors(t *testing.T) {
+	// Nothing is listening at this address once the server closes.
+	testServer := httptest.NewServer(http.HandlerFunc(workItemGetHandler))
+	hostUrl := testServer.URL
+	testServer.Close()
+
+	client, err := network.NewPharosClient(hostUrl, "v2", "user", "key")
+	require.Nil(t, err)
+	policy := testRetryPolicy()
+	policy.BaseDelay = 20 * time.Millisecond
+	policy.Jitter = 0
+	client.SetRetryPolicy(policy)
+
+	start := time.Now()
+	resp := client.WorkItemGet(1)
+	require.NotNil(t, resp.Error)
+	assert.Nil(t, resp.Response)
+	// Two retries, with delays of 20ms and 40ms.
+	assert.True(t, time.Since(start) >= 60*time.Millisecond)
+}
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/models"
+)
+
+// pharosSchemas maps the object types we validate to the schemas
+// describing their JSON. Types not listed here are decoded without
+// validation.
+var pharosSchemas = map[PharosObjectType][]models.SchemaField{
+	PharosIntellectualObject: models.IntellectualObjectSchema,
+	PharosGenericFile:        models.GenericFileSchema,
+	PharosWorkItem:           models.WorkItemSchema,
+}
+
+// unmarshalPharosJson checks data against the schema for objType,
+// and then decodes it into obj. This gives us an error naming the
+// bad field, instead of a zero-valued struct, when Pharos sends
+// something we don't expect.
+func unmarshalPharosJson(data []byte, objType PharosObjectType, obj interface{}) error {
+	if schema, ok := pharosSchemas[objType]; ok {
+		if err := models.ValidateJson(string(objType), data, schema); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(data, obj)
+}
+
+// validatePharosJsonList checks each record in the results of a
+// Pharos list response against the schema for objType. It doesn't
+// report JSON syntax errors, which the list decoders report.
+func validatePharosJsonList(data []byte, objType PharosObjectType) error {
+	schema, ok := pharosSchemas[objType]
+	if !ok {
+		return nil
+	}
+	temp := struct {
+		Results []json.RawMessage `json:"results"`
+	}{}
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return nil
+	}
+	for i, record := range temp.Results {
+		model := fmt.Sprintf("%s results[%d]", objType, i)
+		if err := models.ValidateJson(model, record, schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"net/url"
+	"strings"
 )
 
 type S3Copy struct {
@@ -91,5 +92,58 @@ func (client *S3Copy) Copy() {
 	err = service.WaitUntilObjectExists(headObjectInput)
 	if err != nil {
 		client.ErrorMessage = err.Error()
+		return
+	}
+	client.verify(service, headObjectInput)
+}
+
+// verify compares the copy to the original, and sets ErrorMessage
+// if they differ. We've seen S3 silently truncate copies, so we don't
+// take the copy's word for it.
+func (client *S3Copy) verify(service *s3.S3, destHeadInput *s3.HeadObjectInput) {
+	source, err := service.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(client.SourceBucket),
+		Key:    aws.String(client.SourceKey),
+	})
+	if err != nil {
+		client.ErrorMessage = fmt.Sprintf("Cannot verify copy: %v", err)
+		return
+	}
+	dest, err := service.HeadObject(destHeadInput)
+	if err != nil {
+		client.ErrorMessage = fmt.Sprintf("Cannot verify copy: %v", err)
+		return
+	}
+	err = CompareS3Copy(source, dest)
+	if err != nil {
+		client.ErrorMessage = err.Error()
+	}
+}
+
+// CompareS3Copy returns an error if the HEAD response for a copied
+// object shows it differs from the HEAD response for the original.
+// Sizes must always match. S3 copies an object in one piece, so the
+// copy's ETag is the md5 digest of the copy. We compare that to the
+// original's ETag if the original was also uploaded in one piece,
+// and to the md5 in the original's metadata, if it has one.
+func CompareS3Copy(source, dest *s3.HeadObjectOutput) error {
+	sourceSize := aws.Int64Value(source.ContentLength)
+	destSize := aws.Int64Value(dest.ContentLength)
+	if sourceSize != destSize {
+		return fmt.Errorf("Copy has %d bytes, original has %d", destSize, sourceSize)
+	}
+	destDigest, destParts, err := ParseS3ETag(aws.StringValue(dest.ETag))
+	if err != nil || destParts != 0 {
+		// Can't verify the content.
+		return nil
+	}
+	sourceDigest, sourceParts, err := ParseS3ETag(aws.StringValue(source.ETag))
+	if err == nil && sourceParts == 0 && sourceDigest != destDigest {
+		return fmt.Errorf("Copy has ETag %s, original has %s", destDigest, sourceDigest)
+	}
+	md5 := strings.ToLower(aws.StringValue(source.Metadata["Md5"]))
+	if md5 != "" && md5 != destDigest {
+		return fmt.Errorf("Copy has ETag %s, original has md5 %s", destDigest, md5)
 	}
+	return nil
 }
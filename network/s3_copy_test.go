@@ -5,6 +5,8 @@ import (
 	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/network"
 	"github.com/APTrust/exchange/util/testutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"os"
@@ -54,3 +56,42 @@ func deleteCopy(t *testing.T, key string) {
 func getS3CopyTempName() string {
 	return fmt.Sprintf("DELETE_ME_%s", time.Now().UTC().Format(time.RFC3339Nano))
 }
+
+func TestCompareS3Copy(t *testing.T) {
+	md5 := "8d7b0e3a24fc899b1d92a73537401805"
+	source := &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(1024),
+		ETag:          aws.String(`"` + md5 + `"`),
+	}
+	dest := &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(1024),
+		ETag:          aws.String(`"` + md5 + `"`),
+	}
+	assert.Nil(t, network.CompareS3Copy(source, dest))
+
+	// Truncated copy
+	dest.ContentLength = aws.Int64(512)
+	err := network.CompareS3Copy(source, dest)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Copy has 512 bytes")
+	dest.ContentLength = aws.Int64(1024)
+
+	// Same size, different content
+	dest.ETag = aws.String(`"00000000000000000000000000000000"`)
+	err = network.CompareS3Copy(source, dest)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "original has "+md5)
+
+	// Multipart original: compare the copy to the md5 in the metadata.
+	source.ETag = aws.String(`"0123456789abcdef0123456789abcdef-3"`)
+	source.Metadata = map[string]*string{"Md5": aws.String(md5)}
+	err = network.CompareS3Copy(source, dest)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "original has md5 "+md5)
+	dest.ETag = aws.String(`"` + md5 + `"`)
+	assert.Nil(t, network.CompareS3Copy(source, dest))
+
+	// Multipart copy can only be checked by size.
+	dest.ETag = aws.String(`"0123456789abcdef0123456789abcdef-2"`)
+	assert.Nil(t, network.CompareS3Copy(source, dest))
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"golang.org/x/crypto/blake2b"
 	"hash"
 	"io"
 	"io/ioutil"
@@ -23,18 +24,48 @@ type S3Download struct {
 	CalculateSha256 bool
 	Md5Digest       string
 	Sha256Digest    string
+
+	// CalculateBlake2b tells Fetch to calculate a 512-bit blake2b
+	// digest of the download, which it puts in Blake2bDigest.
+	// Set this after calling NewS3Download.
+	CalculateBlake2b bool
+	Blake2bDigest    string
+
 	BytesCopied     int64
 	ErrorMessage    string
 
+	// Resume tells Fetch to pick up where an earlier, interrupted
+	// download left off, by requesting only the bytes after those
+	// already in LocalPath. This applies to the retries within Fetch,
+	// too, so a dropped connection doesn't cost us the bytes we've
+	// already copied. Checksums still cover the entire file.
+	//
+	// A resumable download records the object's ETag in ETagPath.
+	// Fetch keeps the bytes in LocalPath only if that ETag still
+	// matches the object in S3, and the object is at least as large
+	// as LocalPath. Otherwise, it starts from zero. So the earlier
+	// attempt must also have set Resume.
+	Resume bool
+
+	// ResumedFrom is the number of bytes that were already in
+	// LocalPath when the last download attempt started. BytesCopied
+	// includes these bytes.
+	ResumedFrom int64
+
 	// The response from S3 for the attempted download.
 	// Don't try to read Response.Body, because if this
 	// object is non-nil, the response will already have
 	// been read and closed.
 	Response *s3.GetObjectOutput
 
+	// SessionPool, if set, supplies a shared session and S3
+	// client instead of creating new ones for each download.
+	SessionPool *S3SessionPool
+
 	accessKeyId     string
 	secretAccessKey string
 	session         *session.Session
+	objectSize      int64
 }
 
 // Sets up a new S3 download. Params:
@@ -70,8 +101,13 @@ func NewS3Download(accessKeyId, secretAccessKey, region, bucket, key, localPath
 func (client *S3Download) GetSession() *session.Session {
 	if client.session == nil {
 		var err error
-		client.session, err = GetS3Session(client.AWSRegion,
-			client.accessKeyId, client.secretAccessKey)
+		if client.SessionPool != nil {
+			client.session, err = client.SessionPool.Session(client.AWSRegion,
+				client.accessKeyId, client.secretAccessKey)
+		} else {
+			client.session, err = GetS3Session(client.AWSRegion,
+				client.accessKeyId, client.secretAccessKey)
+		}
 		if err != nil {
 			client.ErrorMessage = err.Error()
 		}
@@ -79,13 +115,16 @@ func (client *S3Download) GetSession() *session.Session {
 	return client.session
 }
 
+// SetSessionEndpoint sets the S3 endpoint. This is for testing
+// against a local S3 server.
+func (client *S3Download) SetSessionEndpoint(url string) {
+	session := client.GetSession()
+	session.Config.Endpoint = &url
+}
+
 // Fetch the file from S3.
 func (client *S3Download) Fetch() {
-	_session := client.GetSession()
-	if _session == nil {
-		return
-	}
-	service := s3.New(_session)
+	service := client.getService()
 	if service == nil {
 		return
 	}
@@ -110,6 +149,25 @@ func (client *S3Download) Fetch() {
 	}
 }
 
+// getService returns a shared S3 client from the SessionPool, if
+// there is one, or a new client otherwise.
+func (client *S3Download) getService() *s3.S3 {
+	if client.SessionPool != nil {
+		service, err := client.SessionPool.Service(client.AWSRegion,
+			client.accessKeyId, client.secretAccessKey)
+		if err != nil {
+			client.ErrorMessage = err.Error()
+			return nil
+		}
+		return service
+	}
+	_session := client.GetSession()
+	if _session == nil {
+		return nil
+	}
+	return s3.New(_session)
+}
+
 // Tries to download the file from S3. This uses GetObject which
 // uses a single HTTP stream, rather than an s3Manager.Downloader,
 // which uses multiple streams. We generally have to calculate
@@ -121,6 +179,22 @@ func (client *S3Download) Fetch() {
 // multi-gigabyte files, we really don't want to have to read them
 // again to produce the checksums.
 func (client *S3Download) tryDownload(service *s3.S3, params *s3.GetObjectInput) error {
+	offset, etag, err := client.resumeOffset(service)
+	if err != nil {
+		return err
+	}
+	client.ResumedFrom = offset
+	if offset > 0 {
+		if offset == client.objectSize {
+			return client.finishResumedDownload()
+		}
+		// If-Match makes S3 refuse the range if the object changed
+		// since we looked at it. The next attempt starts over.
+		rangeParams := *params
+		rangeParams.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+		rangeParams.IfMatch = aws.String(etag)
+		params = &rangeParams
+	}
 	resp, err := service.GetObject(params)
 	if err != nil {
 		return err
@@ -128,6 +202,19 @@ func (client *S3Download) tryDownload(service *s3.S3, params *s3.GetObjectInput)
 	defer resp.Body.Close()
 	client.Response = resp
 
+	// Record which version of the object we're downloading, so a
+	// later attempt can tell whether it's safe to resume.
+	if client.Resume && offset == 0 && client.LocalPath != os.DevNull {
+		err = os.MkdirAll(filepath.Dir(client.LocalPath), 0755)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(client.ETagPath(), []byte(aws.StringValue(resp.ETag)), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Create the download directory and open a file for writing.
 	writers := make([]io.Writer, 0)
 	if client.LocalPath == os.DevNull {
@@ -137,7 +224,11 @@ func (client *S3Download) tryDownload(service *s3.S3, params *s3.GetObjectInput)
 		if err != nil {
 			return err
 		}
-		outputFile, err := os.Create(client.LocalPath)
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if offset > 0 {
+			flags = os.O_WRONLY | os.O_APPEND
+		}
+		outputFile, err := os.OpenFile(client.LocalPath, flags, 0666)
 		if err != nil {
 			return err
 		}
@@ -159,8 +250,22 @@ func (client *S3Download) tryDownload(service *s3.S3, params *s3.GetObjectInput)
 		sha256Hash = sha256.New()
 		writers = append(writers, sha256Hash)
 	}
+	var blake2bHash hash.Hash
+	if client.CalculateBlake2b {
+		blake2bHash, _ = blake2b.New512(nil)
+		writers = append(writers, blake2bHash)
+	}
 	multiWriter = io.MultiWriter(writers...)
 
+	// When resuming, the checksums have to include the bytes
+	// we downloaded earlier.
+	if offset > 0 && (client.CalculateMd5 || client.CalculateSha256 || client.CalculateBlake2b) {
+		err = client.hashLocalFile(md5Hash, sha256Hash, blake2bHash, offset)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Copy the file, with several tries. On larger files,
 	// we often get a "connection reset by peer" error.
 	// Better to retry a few times now than throw this
@@ -171,6 +276,7 @@ func (client *S3Download) tryDownload(service *s3.S3, params *s3.GetObjectInput)
 			break
 		}
 	}
+	client.BytesCopied += offset
 	if err != nil {
 		return err
 	}
@@ -182,7 +288,116 @@ func (client *S3Download) tryDownload(service *s3.S3, params *s3.GetObjectInput)
 	if client.CalculateSha256 {
 		client.Sha256Digest = fmt.Sprintf("%x", sha256Hash.Sum(nil))
 	}
+	if client.CalculateBlake2b {
+		client.Blake2bDigest = fmt.Sprintf("%x", blake2bHash.Sum(nil))
+	}
+	client.removeETagFile()
 
 	// No errors.
 	return nil
 }
+
+// ETagPath returns the path of the file in which a resumable download
+// records the ETag of the object it's downloading, so a later attempt
+// can tell whether the object has changed. Fetch deletes this file
+// once the download is complete.
+func (client *S3Download) ETagPath() string {
+	return client.LocalPath + ".etag"
+}
+
+// resumeOffset returns the number of bytes already in LocalPath that
+// we can keep, and the ETag of the object those bytes came from. It
+// returns zero, meaning start over, unless Resume is set and S3 still
+// has the same version of the object, at least as large as the local
+// file. It also sets objectSize when resuming.
+func (client *S3Download) resumeOffset(service *s3.S3) (int64, string, error) {
+	if !client.Resume || client.LocalPath == os.DevNull {
+		return 0, "", nil
+	}
+	fileInfo, err := os.Stat(client.LocalPath)
+	if err != nil || fileInfo.Size() == 0 {
+		return 0, "", nil
+	}
+	savedETag, err := ioutil.ReadFile(client.ETagPath())
+	if err != nil {
+		// We don't know which version of the object the local
+		// bytes came from.
+		return 0, "", nil
+	}
+	head, err := service.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(client.BucketName),
+		Key:    aws.String(client.KeyName),
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	etag := aws.StringValue(head.ETag)
+	client.objectSize = aws.Int64Value(head.ContentLength)
+	if etag == "" || etag != string(savedETag) || fileInfo.Size() > client.objectSize {
+		return 0, "", nil
+	}
+	return fileInfo.Size(), etag, nil
+}
+
+// removeETagFile deletes the ETag file of a resumable download.
+func (client *S3Download) removeETagFile() {
+	if client.Resume && client.LocalPath != os.DevNull {
+		os.Remove(client.ETagPath())
+	}
+}
+
+// finishResumedDownload sets BytesCopied and the checksums for a
+// resumed download when LocalPath already holds the whole file of
+// the same version as the object in S3.
+func (client *S3Download) finishResumedDownload() error {
+	var md5Hash hash.Hash
+	var sha256Hash hash.Hash
+	if client.CalculateMd5 {
+		md5Hash = md5.New()
+	}
+	if client.CalculateSha256 {
+		sha256Hash = sha256.New()
+	}
+	var blake2bHash hash.Hash
+	if client.CalculateBlake2b {
+		blake2bHash, _ = blake2b.New512(nil)
+	}
+	err := client.hashLocalFile(md5Hash, sha256Hash, blake2bHash, client.ResumedFrom)
+	if err != nil {
+		return err
+	}
+	client.BytesCopied = client.ResumedFrom
+	if client.CalculateMd5 {
+		client.Md5Digest = fmt.Sprintf("%x", md5Hash.Sum(nil))
+	}
+	if client.CalculateSha256 {
+		client.Sha256Digest = fmt.Sprintf("%x", sha256Hash.Sum(nil))
+	}
+	if client.CalculateBlake2b {
+		client.Blake2bDigest = fmt.Sprintf("%x", blake2bHash.Sum(nil))
+	}
+	client.removeETagFile()
+	return nil
+}
+
+// hashLocalFile passes the first byteCount bytes of LocalPath through
+// whichever of md5Hash, sha256Hash and blake2bHash are not nil.
+func (client *S3Download) hashLocalFile(md5Hash, sha256Hash, blake2bHash hash.Hash, byteCount int64) error {
+	writers := make([]io.Writer, 0)
+	if md5Hash != nil {
+		writers = append(writers, md5Hash)
+	}
+	if sha256Hash != nil {
+		writers = append(writers, sha256Hash)
+	}
+	if blake2bHash != nil {
+		writers = append(writers, blake2bHash)
+	}
+	localFile, err := os.Open(client.LocalPath)
+	if err != nil {
+		return err
+	}
+	defer localFile.Close()
+	_, err = io.CopyN(io.MultiWriter(writers...), localFile, byteCount)
+	return err
+}
@@ -1,13 +1,21 @@
 package network_test
 
 import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
 	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/network"
 	"github.com/APTrust/exchange/util/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -119,3 +127,195 @@ func TestFetchWithChecksums(t *testing.T) {
 	assert.Equal(t, testFileMd5, download.Md5Digest)
 	assert.Equal(t, testFileSha256, download.Sha256Digest)
 }
+
+const resumeTestContent = "The quick brown fox jumps over the lazy dog."
+
+// rangeServer serves resumeTestContent with the given ETag, honoring
+// the Range and If-Match headers the way S3 does, and records the
+// range of each GET.
+type rangeServer struct {
+	etag   string
+	ranges []string
+}
+
+func (server *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("ETag", server.etag)
+	if r.Method == "HEAD" {
+		w.Header().Set("Content-Length", strconv.Itoa(len(resumeTestContent)))
+		return
+	}
+	requestedRange := r.Header.Get("Range")
+	server.ranges = append(server.ranges, requestedRange)
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != server.etag {
+		server.writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+		return
+	}
+	start := 0
+	if requestedRange != "" {
+		start, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(requestedRange, "bytes="), "-"))
+	}
+	if start >= len(resumeTestContent) {
+		server.writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(resumeTestContent)-start))
+	if requestedRange != "" {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d",
+			start, len(resumeTestContent)-1, len(resumeTestContent)))
+		w.WriteHeader(http.StatusPartialContent)
+	}
+	fmt.Fprint(w, resumeTestContent[start:])
+}
+
+func (server *rangeServer) writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>`+
+		`<Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+func getResumeTestDownload(t *testing.T, serverURL, localPath string) *network.S3Download {
+	download := network.NewS3Download("key", "secret", constants.AWSVirginia,
+		constants.AWS_TEST_HACK_BUCKET_NAME, "fox.txt", localPath, true, true)
+	download.Resume = true
+	download.SetSessionEndpoint(strings.Replace(serverURL, constants.AWS_TEST_HACK_IP_PREFIX, "", 1))
+	require.Empty(t, download.ErrorMessage)
+	return download
+}
+
+// writeResumeTestFile leaves localPath as an interrupted download
+// would, with content and the ETag of the object it came from.
+func writeResumeTestFile(t *testing.T, download *network.S3Download, content, etag string) {
+	require.Nil(t, ioutil.WriteFile(download.LocalPath, []byte(content), 0644))
+	require.Nil(t, ioutil.WriteFile(download.ETagPath(), []byte(etag), 0644))
+}
+
+func assertResumeTestFileComplete(t *testing.T, download *network.S3Download) {
+	require.Empty(t, download.ErrorMessage)
+	assert.Equal(t, int64(len(resumeTestContent)), download.BytesCopied)
+	assert.Equal(t, fmt.Sprintf("%x", md5.Sum([]byte(resumeTestContent))), download.Md5Digest)
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte(resumeTestContent))), download.Sha256Digest)
+	data, err := ioutil.ReadFile(download.LocalPath)
+	require.Nil(t, err)
+	assert.Equal(t, resumeTestContent, string(data))
+	// The ETag file is only for incomplete downloads.
+	_, err = os.Stat(download.ETagPath())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFetchResume(t *testing.T) {
+	server := &rangeServer{etag: `"fba9dede5f27731c9771645a39863328"`}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	tmpDir, err := ioutil.TempDir("", "s3_download_resume_test")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+	localPath := filepath.Join(tmpDir, "fox.txt")
+
+	// Nothing on disk, so we download the whole file.
+	download := getResumeTestDownload(t, testServer.URL, localPath)
+	download.Fetch()
+	assertResumeTestFileComplete(t, download)
+	assert.Equal(t, []string{""}, server.ranges)
+	assert.Equal(t, int64(0), download.ResumedFrom)
+
+	// Leave a partial download on disk, and we should
+	// fetch only the rest of it.
+	server.ranges = nil
+	download = getResumeTestDownload(t, testServer.URL, localPath)
+	writeResumeTestFile(t, download, resumeTestContent[:10], server.etag)
+	download.Fetch()
+	assertResumeTestFileComplete(t, download)
+	assert.Equal(t, []string{"bytes=10-"}, server.ranges)
+	assert.Equal(t, int64(10), download.ResumedFrom)
+
+	// If we already have the whole file, we just compute
+	// the checksums.
+	server.ranges = nil
+	download = getResumeTestDownload(t, testServer.URL, localPath)
+	writeResumeTestFile(t, download, resumeTestContent, server.etag)
+	download.Fetch()
+	assertResumeTestFileComplete(t, download)
+	assert.Empty(t, server.ranges)
+	assert.Equal(t, int64(len(resumeTestContent)), download.ResumedFrom)
+
+	// Without an ETag file, we can't tell where the local
+	// bytes came from, so we start over.
+	server.ranges = nil
+	download = getResumeTestDownload(t, testServer.URL, localPath)
+	require.Nil(t, ioutil.WriteFile(localPath, []byte("The quick"), 0644))
+	download.Fetch()
+	assertResumeTestFileComplete(t, download)
+	assert.Equal(t, []string{""}, server.ranges)
+	assert.Equal(t, int64(0), download.ResumedFrom)
+
+	// Without Resume, we start over.
+	server.ranges = nil
+	download = getResumeTestDownload(t, testServer.URL, localPath)
+	download.Resume = false
+	writeResumeTestFile(t, download, resumeTestContent[:10], server.etag)
+	download.Fetch()
+	require.Empty(t, download.ErrorMessage)
+	assert.Equal(t, []string{""}, server.ranges)
+	assert.Equal(t, int64(0), download.ResumedFrom)
+	data, err := ioutil.ReadFile(localPath)
+	require.Nil(t, err)
+	assert.Equal(t, resumeTestContent, string(data))
+}
+
+func TestFetchResumeLocalFileTooLarge(t *testing.T) {
+	server := &rangeServer{etag: `"fba9dede5f27731c9771645a39863328"`}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	tmpDir, err := ioutil.TempDir("", "s3_download_resume_test")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	// The local file has more bytes than the object, so it
+	// can't be a partial copy of it, even with a matching ETag.
+	download := getResumeTestDownload(t, testServer.URL, filepath.Join(tmpDir, "fox.txt"))
+	writeResumeTestFile(t, download, resumeTestContent+" And then some.", server.etag)
+	download.Fetch()
+	assertResumeTestFileComplete(t, download)
+	assert.Equal(t, []string{""}, server.ranges)
+	assert.Equal(t, int64(0), download.ResumedFrom)
+}
+
+func TestFetchResumeObjectChanged(t *testing.T) {
+	server := &rangeServer{etag: `"fba9dede5f27731c9771645a39863328"`}
+	testServer := httptest.NewServer(server)
+	defer testServer.Close()
+
+	tmpDir, err := ioutil.TempDir("", "s3_download_resume_test")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+	localPath := filepath.Join(tmpDir, "fox.txt")
+
+	// The local bytes came from an older version of the object,
+	// so we start over rather than mix versions.
+	download := getResumeTestDownload(t, testServer.URL, localPath)
+	writeResumeTestFile(t, download, "The slow green", `"0123456789abcdef0123456789abcdef"`)
+	download.Fetch()
+	assertResumeTestFileComplete(t, download)
+	assert.Equal(t, []string{""}, server.ranges)
+	assert.Equal(t, int64(0), download.ResumedFrom)
+
+	// If the object changes between the HEAD and the ranged GET,
+	// S3 refuses the range, and the next attempt starts over.
+	server.ranges = nil
+	download = getResumeTestDownload(t, testServer.URL, localPath)
+	writeResumeTestFile(t, download, "The slow green", server.etag)
+	changer := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.ServeHTTP(w, r)
+		if r.Method == "HEAD" && len(server.ranges) == 0 {
+			server.etag = `"fedcba9876543210fedcba9876543210"`
+		}
+	})
+	testServer.Config.Handler = changer
+	download.Fetch()
+	assertResumeTestFileComplete(t, download)
+	assert.Equal(t, []string{"bytes=14-", ""}, server.ranges)
+	assert.Equal(t, int64(0), download.ResumedFrom)
+}
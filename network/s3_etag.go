@@ -0,0 +1,71 @@
+package network
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseS3ETag splits an S3 ETag into its hex digest and part count.
+// S3 gives an object uploaded in a single PutObject request an ETag
+// that is the md5 digest of the object, and returns a part count of
+// zero for those. An object uploaded in parts gets an ETag like
+// "<digest>-<parts>", where digest is the md5 of the concatenated
+// binary md5 digests of the parts. ETags of objects encrypted with
+// SSE-KMS or SSE-C are neither, and can't be verified.
+func ParseS3ETag(etag string) (digest string, parts int, err error) {
+	etag = strings.ToLower(strings.Trim(etag, `"`))
+	index := strings.LastIndex(etag, "-")
+	if index < 0 {
+		return etag, 0, nil
+	}
+	parts, err = strconv.Atoi(etag[index+1:])
+	if err != nil || parts < 1 {
+		return "", 0, fmt.Errorf("ETag '%s' has an invalid part count", etag)
+	}
+	return etag[0:index], parts, nil
+}
+
+// S3PartCount returns the number of parts the S3 uploader uses to
+// send size bytes in parts of partSize bytes. It returns zero if the
+// uploader sends the whole thing in a single PutObject request, which
+// it does when size is no larger than partSize.
+func S3PartCount(size, partSize int64) int {
+	if partSize <= 0 || size <= partSize {
+		return 0
+	}
+	parts := size / partSize
+	if size%partSize != 0 {
+		parts++
+	}
+	return int(parts)
+}
+
+// MultipartETag reads all of reader and returns the ETag S3 would
+// assign to it if it were uploaded in parts of partSize bytes,
+// without the surrounding quotes. Compare the result to an ETag whose
+// part count matches S3PartCount.
+func MultipartETag(reader io.Reader, partSize int64) (string, error) {
+	if partSize <= 0 {
+		return "", fmt.Errorf("Part size must be greater than zero")
+	}
+	digests := md5.New()
+	parts := 0
+	for {
+		partHash := md5.New()
+		n, err := io.CopyN(partHash, reader, partSize)
+		if n > 0 {
+			digests.Write(partHash.Sum(nil))
+			parts++
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(digests.Sum(nil)), parts), nil
+}
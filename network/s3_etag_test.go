@@ -0,0 +1,59 @@
+package network_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"github.com/APTrust/exchange/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseS3ETag(t *testing.T) {
+	digest, parts, err := network.ParseS3ETag(`"8D7B0E3A24FC899B1D92A73537401805"`)
+	require.Nil(t, err)
+	assert.Equal(t, "8d7b0e3a24fc899b1d92a73537401805", digest)
+	assert.Equal(t, 0, parts)
+
+	digest, parts, err = network.ParseS3ETag("8d7b0e3a24fc899b1d92a73537401805-12")
+	require.Nil(t, err)
+	assert.Equal(t, "8d7b0e3a24fc899b1d92a73537401805", digest)
+	assert.Equal(t, 12, parts)
+
+	_, _, err = network.ParseS3ETag("8d7b0e3a24fc899b1d92a73537401805-x")
+	assert.NotNil(t, err)
+	_, _, err = network.ParseS3ETag("8d7b0e3a24fc899b1d92a73537401805-0")
+	assert.NotNil(t, err)
+}
+
+func TestS3PartCount(t *testing.T) {
+	assert.Equal(t, 0, network.S3PartCount(100, 100))
+	assert.Equal(t, 0, network.S3PartCount(10, 100))
+	assert.Equal(t, 2, network.S3PartCount(101, 100))
+	assert.Equal(t, 2, network.S3PartCount(200, 100))
+	assert.Equal(t, 3, network.S3PartCount(201, 100))
+	assert.Equal(t, 0, network.S3PartCount(201, 0))
+}
+
+func TestMultipartETag(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 25)
+	part1 := md5.Sum(data[0:100])
+	part2 := md5.Sum(data[100:200])
+	part3 := md5.Sum(data[200:])
+	all := append(append(part1[:], part2[:]...), part3[:]...)
+	digest := md5.Sum(all)
+	expected := hex.EncodeToString(digest[:]) + "-3"
+
+	etag, err := network.MultipartETag(bytes.NewReader(data), 100)
+	require.Nil(t, err)
+	assert.Equal(t, expected, etag)
+
+	// A truncated copy gets a different ETag.
+	etag, err = network.MultipartETag(bytes.NewReader(data[0:240]), 100)
+	require.Nil(t, err)
+	assert.NotEqual(t, expected, etag)
+
+	_, err = network.MultipartETag(bytes.NewReader(data), 0)
+	assert.NotNil(t, err)
+}
@@ -11,10 +11,20 @@ import (
 )
 
 type S3Head struct {
-	AWSRegion       string
-	BucketName      string
-	ErrorMessage    string
-	Response        *s3.HeadObjectOutput
+	AWSRegion    string
+	BucketName   string
+	ErrorMessage string
+	Response     *s3.HeadObjectOutput
+
+	// StatusCode is the HTTP status code of the response to the
+	// last HEAD request, or zero if S3 did not respond. It's 404
+	// if the object does not exist.
+	StatusCode int
+
+	// SessionPool, if set, supplies a shared session instead
+	// of creating a new one for each head client.
+	SessionPool *S3SessionPool
+
 	input           *s3.HeadObjectInput
 	session         *session.Session
 	accessKeyId     string
@@ -52,8 +62,13 @@ func NewS3Head(accessKeyId, secretAccessKey, region, bucket string) *S3Head {
 func (client *S3Head) GetSession() *session.Session {
 	if client.session == nil {
 		var err error
-		client.session, err = GetS3Session(client.AWSRegion,
-			client.accessKeyId, client.secretAccessKey)
+		if client.SessionPool != nil {
+			client.session, err = client.SessionPool.Session(client.AWSRegion,
+				client.accessKeyId, client.secretAccessKey)
+		} else {
+			client.session, err = GetS3Session(client.AWSRegion,
+				client.accessKeyId, client.secretAccessKey)
+		}
 		if err != nil {
 			client.ErrorMessage = err.Error()
 		}
@@ -80,6 +95,7 @@ func (client *S3Head) SetSessionEndpoint(url string) {
 func (client *S3Head) Head(key string) {
 	client.Response = nil
 	client.ErrorMessage = ""
+	client.StatusCode = 0
 	_session := client.GetSession()
 	if _session == nil {
 		return
@@ -99,6 +115,9 @@ func (client *S3Head) Head(key string) {
 	client.input = params
 	request, response := service.HeadObjectRequest(params)
 	err := request.Send()
+	if request.HTTPResponse != nil {
+		client.StatusCode = request.HTTPResponse.StatusCode
+	}
 	if err != nil {
 		client.ErrorMessage = err.Error()
 		return
@@ -173,7 +192,7 @@ func (client *S3Head) StoredFile() *models.StoredFile {
 		ContentType:  util.PointerToString(resp.ContentType),
 		Institution:  client.GetHeaderMetadata("Institution"),
 		BagName:      client.GetHeaderMetadata("Bag"),
-		PathInBag:    client.GetHeaderMetadata("Bagpath"),
+		PathInBag:    util.DecodeManifestPath(client.GetHeaderMetadata("Bagpath")),
 		Md5:          client.GetHeaderMetadata("Md5"),
 		Sha256:       client.GetHeaderMetadata("Sha256"),
 		LastSeenAt:   now,
@@ -0,0 +1,108 @@
+package network
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"sync"
+)
+
+// S3SessionPool caches AWS sessions, S3 service clients and s3manager
+// uploaders, keyed by region and credentials. Creating a new session
+// for every file is slow, and under high ingest concurrency it leaves
+// a trail of idle HTTP connections behind. AWS sessions, S3 clients
+// and uploaders are all safe for concurrent use, so a single pool
+// can be shared by all of a worker's goroutines through the Context.
+type S3SessionPool struct {
+	sessions  map[string]*session.Session
+	services  map[string]*s3.S3
+	uploaders map[string]*s3manager.Uploader
+	mutex     *sync.Mutex
+}
+
+// NewS3SessionPool returns a new, empty S3SessionPool.
+func NewS3SessionPool() *S3SessionPool {
+	return &S3SessionPool{
+		sessions:  make(map[string]*session.Session),
+		services:  make(map[string]*s3.S3),
+		uploaders: make(map[string]*s3manager.Uploader),
+		mutex:     &sync.Mutex{},
+	}
+}
+
+// Session returns a cached session for the specified region and
+// credentials, creating one if necessary. As with GetS3Session,
+// empty credentials mean use the AWS env vars.
+func (pool *S3SessionPool) Session(region, accessKeyId, secretAccessKey string) (*session.Session, error) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	return pool.getSession(region, accessKeyId, secretAccessKey)
+}
+
+// Service returns a cached S3 service client for the specified
+// region and credentials.
+func (pool *S3SessionPool) Service(region, accessKeyId, secretAccessKey string) (*s3.S3, error) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	key := poolKey(region, accessKeyId, secretAccessKey)
+	if service, ok := pool.services[key]; ok {
+		return service, nil
+	}
+	_session, err := pool.getSession(region, accessKeyId, secretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	service := s3.New(_session)
+	pool.services[key] = service
+	return service, nil
+}
+
+// Uploader returns a cached s3manager.Uploader for the specified
+// region and credentials. Callers that need a non-default part size
+// or concurrency should pass options to Uploader.Upload rather than
+// changing the shared uploader's fields.
+func (pool *S3SessionPool) Uploader(region, accessKeyId, secretAccessKey string) (*s3manager.Uploader, error) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	key := poolKey(region, accessKeyId, secretAccessKey)
+	if uploader, ok := pool.uploaders[key]; ok {
+		return uploader, nil
+	}
+	_session, err := pool.getSession(region, accessKeyId, secretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	uploader := s3manager.NewUploader(_session)
+	pool.uploaders[key] = uploader
+	return uploader, nil
+}
+
+// Size returns the number of sessions in the pool.
+func (pool *S3SessionPool) Size() int {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	return len(pool.sessions)
+}
+
+// getSession does the work for Session. Caller must hold the mutex.
+func (pool *S3SessionPool) getSession(region, accessKeyId, secretAccessKey string) (*session.Session, error) {
+	key := poolKey(region, accessKeyId, secretAccessKey)
+	if _session, ok := pool.sessions[key]; ok {
+		return _session, nil
+	}
+	_session, err := GetS3Session(region, accessKeyId, secretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	pool.sessions[key] = _session
+	return _session, nil
+}
+
+// poolKey returns the map key for region and credentials. We hash
+// the secret so it doesn't sit around in plain text in the map keys.
+func poolKey(region, accessKeyId, secretAccessKey string) string {
+	return fmt.Sprintf("%s|%s|%x", region, accessKeyId,
+		sha256.Sum256([]byte(secretAccessKey)))
+}
@@ -0,0 +1,108 @@
+package network_test
+
+import (
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/network"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestS3SessionPoolSession(t *testing.T) {
+	pool := network.NewS3SessionPool()
+	require.NotNil(t, pool)
+	assert.Equal(t, 0, pool.Size())
+
+	session1, err := pool.Session(constants.AWSVirginia, "key1", "secret1")
+	require.Nil(t, err)
+	require.NotNil(t, session1)
+	session2, err := pool.Session(constants.AWSVirginia, "key1", "secret1")
+	require.Nil(t, err)
+	assert.True(t, session1 == session2)
+	assert.Equal(t, 1, pool.Size())
+
+	// Different region, different session.
+	session3, err := pool.Session(constants.AWSOregon, "key1", "secret1")
+	require.Nil(t, err)
+	assert.False(t, session1 == session3)
+	assert.Equal(t, 2, pool.Size())
+
+	// Different credentials, different session.
+	session4, err := pool.Session(constants.AWSVirginia, "key1", "secret2")
+	require.Nil(t, err)
+	assert.False(t, session1 == session4)
+	assert.Equal(t, 3, pool.Size())
+}
+
+func TestS3SessionPoolServiceAndUploader(t *testing.T) {
+	pool := network.NewS3SessionPool()
+	service1, err := pool.Service(constants.AWSVirginia, "key1", "secret1")
+	require.Nil(t, err)
+	service2, err := pool.Service(constants.AWSVirginia, "key1", "secret1")
+	require.Nil(t, err)
+	assert.True(t, service1 == service2)
+
+	uploader1, err := pool.Uploader(constants.AWSVirginia, "key1", "secret1")
+	require.Nil(t, err)
+	uploader2, err := pool.Uploader(constants.AWSVirginia, "key1", "secret1")
+	require.Nil(t, err)
+	assert.True(t, uploader1 == uploader2)
+
+	// Service and uploader should share one session.
+	assert.Equal(t, 1, pool.Size())
+}
+
+func TestS3SessionPoolConcurrentAccess(t *testing.T) {
+	pool := network.NewS3SessionPool()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := pool.Uploader(constants.AWSVirginia, "key1", "secret1")
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, pool.Size())
+}
+
+func TestS3UploadAndDownloadUsePool(t *testing.T) {
+	pool := network.NewS3SessionPool()
+	upload := network.NewS3Upload("key1", "secret1", constants.AWSVirginia,
+		"bucket", "key", "")
+	upload.SessionPool = pool
+	download := network.NewS3Download("key1", "secret1", constants.AWSVirginia,
+		"bucket", "key", "/dev/null", false, false)
+	download.SessionPool = pool
+	assert.True(t, upload.GetSession() == download.GetSession())
+	assert.Equal(t, 1, pool.Size())
+}
+
+// Compare these two with:
+//
+// go test ./network -run=XXX -bench=S3Session -benchmem
+//
+// The unpooled version builds a new session and uploader for each
+// file, which is what apt_store used to do.
+func BenchmarkS3UploaderUnpooled(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_session, err := network.GetS3Session(constants.AWSVirginia, "key1", "secret1")
+		if err != nil {
+			b.Fatal(err)
+		}
+		s3manager.NewUploader(_session)
+	}
+}
+
+func BenchmarkS3UploaderPooled(b *testing.B) {
+	pool := network.NewS3SessionPool()
+	for i := 0; i < b.N; i++ {
+		_, err := pool.Uploader(constants.AWSVirginia, "key1", "secret1")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
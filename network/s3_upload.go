@@ -27,10 +27,15 @@ import (
 // urlOfNewItem := upload.Response.Location
 //
 type S3Upload struct {
-	AWSRegion       string
-	ErrorMessage    string
-	UploadInput     *s3manager.UploadInput
-	Response        *s3manager.UploadOutput
+	AWSRegion    string
+	ErrorMessage string
+	UploadInput  *s3manager.UploadInput
+	Response     *s3manager.UploadOutput
+
+	// SessionPool, if set, supplies a shared session and uploader
+	// instead of creating new ones for each upload.
+	SessionPool *S3SessionPool
+
 	session         *session.Session
 	accessKeyId     string
 	secretAccessKey string
@@ -84,8 +89,13 @@ func NewS3Upload(accessKeyId, secretAccessKey, region, bucket, key, contentType
 func (client *S3Upload) GetSession() *session.Session {
 	if client.session == nil {
 		var err error
-		client.session, err = GetS3Session(client.AWSRegion,
-			client.accessKeyId, client.secretAccessKey)
+		if client.SessionPool != nil {
+			client.session, err = client.SessionPool.Session(client.AWSRegion,
+				client.accessKeyId, client.secretAccessKey)
+		} else {
+			client.session, err = GetS3Session(client.AWSRegion,
+				client.accessKeyId, client.secretAccessKey)
+		}
 		if err != nil {
 			client.ErrorMessage = err.Error()
 		}
@@ -117,11 +127,10 @@ func (client *S3Upload) AddMetadata(key, value string) {
 // crash due to lack of memory. (Esp. when we're dealing with 1TB files.)
 // See apt_storer for an example.
 func (client *S3Upload) Send(reader io.Reader) {
-	_session := client.GetSession()
-	if _session == nil {
+	uploader := client.getUploader()
+	if uploader == nil {
 		return
 	}
-	uploader := s3manager.NewUploader(_session)
 	client.UploadInput.Body = reader
 	var err error
 	client.Response, err = uploader.Upload(client.UploadInput)
@@ -147,11 +156,10 @@ func (client *S3Upload) SendWithSize(reader io.Reader, fileSize int64) {
 	if chunkSize < BIG_CHUNK_SIZE {
 		chunkSize = BIG_CHUNK_SIZE
 	}
-	_session := client.GetSession()
-	if _session == nil {
+	uploader := client.getUploader()
+	if uploader == nil {
 		return
 	}
-	uploader := s3manager.NewUploader(_session)
 
 	// The uploader reads these chunks into memory,
 	// so we can't have too many of them. We typically
@@ -166,12 +174,18 @@ func (client *S3Upload) SendWithSize(reader io.Reader, fileSize int64) {
 	// Even with these conservative settings (2 workers, 50MB
 	// chunks, and 2 concurrent connections), memory usage
 	// hovers around 1.2GB.
-	uploader.PartSize = chunkSize
-	uploader.Concurrency = 2
-
+	//
+	// Set these per upload, because the uploader may be
+	// shared with other goroutines through the SessionPool.
+	client.partSize = chunkSize
+	client.concurrency = 2
 	client.UploadInput.Body = reader
 	var err error
-	client.Response, err = uploader.Upload(client.UploadInput)
+	client.Response, err = uploader.Upload(client.UploadInput,
+		func(u *s3manager.Uploader) {
+			u.PartSize = chunkSize
+			u.Concurrency = 2
+		})
 	if err != nil {
 		client.ErrorMessage = err.Error()
 	}
@@ -184,3 +198,22 @@ func (client *S3Upload) PartSize() int64 {
 func (client *S3Upload) Concurrency() int {
 	return client.concurrency
 }
+
+// getUploader returns a shared uploader from the SessionPool, if
+// there is one, or a new uploader otherwise.
+func (client *S3Upload) getUploader() *s3manager.Uploader {
+	if client.SessionPool != nil {
+		uploader, err := client.SessionPool.Uploader(client.AWSRegion,
+			client.accessKeyId, client.secretAccessKey)
+		if err != nil {
+			client.ErrorMessage = err.Error()
+			return nil
+		}
+		return uploader
+	}
+	_session := client.GetSession()
+	if _session == nil {
+		return nil
+	}
+	return s3manager.NewUploader(_session)
+}
@@ -0,0 +1,168 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serviceNamePattern matches a logical service name in a config
+// setting, such as "{nsqd-http}" in "http://{nsqd-http}".
+var serviceNamePattern = regexp.MustCompile(`\{([A-Za-z0-9_.-]+)\}`)
+
+// ServiceResolver finds the current addresses of a logical service,
+// such as "nsqd-http" or "pharos", so config settings can name the
+// service instead of hard-coding its hosts.
+type ServiceResolver interface {
+	// Resolve returns the addresses of the service's instances,
+	// as host:port, most preferred first. It returns an error if
+	// it can't find any.
+	Resolve(service string) ([]string, error)
+}
+
+// DNSResolver resolves service names through DNS SRV records.
+type DNSResolver struct {
+	// Domain is the domain of the SRV records. The record for
+	// service "nsqd-http" is _nsqd-http._tcp.<Domain>. If Domain
+	// is empty, service names must be full SRV record names.
+	Domain string
+	// LookupSRV looks up SRV records. It's a variable so tests
+	// can fake it. It defaults to net.LookupSRV.
+	LookupSRV func(service, proto, name string) (string, []*net.SRV, error)
+}
+
+// NewDNSResolver returns a DNSResolver that looks up SRV records in
+// domain.
+func NewDNSResolver(domain string) *DNSResolver {
+	return &DNSResolver{
+		Domain:    domain,
+		LookupSRV: net.LookupSRV,
+	}
+}
+
+// Resolve returns the targets of the service's SRV records, in the
+// order of their priority and weight.
+func (resolver *DNSResolver) Resolve(service string) ([]string, error) {
+	var records []*net.SRV
+	var err error
+	if resolver.Domain == "" {
+		_, records, err = resolver.LookupSRV("", "", service)
+	} else {
+		_, records, err = resolver.LookupSRV(service, "tcp", resolver.Domain)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot look up SRV records for service '%s': %v", service, err)
+	}
+	addresses := make([]string, 0)
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Target, ".")
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("No SRV records for service '%s'", service)
+	}
+	return addresses, nil
+}
+
+// ConsulResolver resolves service names through the health API of
+// a Consul agent, which returns only instances that pass their
+// health checks.
+type ConsulResolver struct {
+	// Address is the HTTP address of the Consul agent, such
+	// as "http://127.0.0.1:8500".
+	Address string
+	Client  *http.Client
+}
+
+// NewConsulResolver returns a ConsulResolver that asks the
+// Consul agent at address.
+func NewConsulResolver(address string) *ConsulResolver {
+	return &ConsulResolver{
+		Address: address,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// consulServiceEntry is the part of a Consul health API entry
+// that we use.
+type consulServiceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+// Resolve returns the addresses of the service's healthy instances.
+func (resolver *ConsulResolver) Resolve(service string) ([]string, error) {
+	reqUrl := fmt.Sprintf("%s/v1/health/service/%s?passing",
+		strings.TrimSuffix(resolver.Address, "/"), url.PathEscape(service))
+	resp, err := resolver.Client.Get(reqUrl)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot get service '%s' from Consul: %v", service, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Consul returned status %d for service '%s'",
+			resp.StatusCode, service)
+	}
+	entries := make([]consulServiceEntry, 0)
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("Cannot parse Consul response for service '%s': %v", service, err)
+	}
+	addresses := make([]string, 0)
+	for _, entry := range entries {
+		// The service address is empty if it's the
+		// same as the node address.
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)))
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("Consul has no healthy instances of service '%s'", service)
+	}
+	return addresses, nil
+}
+
+// HasServiceName returns true if value names a service in braces,
+// as in "http://{nsqd-http}".
+func HasServiceName(value string) bool {
+	return serviceNamePattern.MatchString(value)
+}
+
+// ExpandServiceName returns a copy of value for each address of the
+// service that value names in braces, most preferred first. E.g. if
+// service "nsqd-http" is at 10.0.0.5:4151 and 10.0.0.6:4151, the
+// expansion of "http://{nsqd-http}" is "http://10.0.0.5:4151" and
+// "http://10.0.0.6:4151". It returns value unchanged if value names
+// no service, and an error if value names more than one service or
+// resolver cannot resolve the name.
+func ExpandServiceName(resolver ServiceResolver, value string) ([]string, error) {
+	matches := serviceNamePattern.FindAllStringSubmatchIndex(value, -1)
+	if len(matches) == 0 {
+		return []string{value}, nil
+	}
+	if len(matches) > 1 {
+		return nil, fmt.Errorf("Setting '%s' names more than one service", value)
+	}
+	match := matches[0]
+	addresses, err := resolver.Resolve(value[match[2]:match[3]])
+	if err != nil {
+		return nil, err
+	}
+	expanded := make([]string, len(addresses))
+	for i, address := range addresses {
+		expanded[i] = value[:match[0]] + address + value[match[1]:]
+	}
+	return expanded, nil
+}
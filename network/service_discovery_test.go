@@ -0,0 +1,90 @@
+package network_test
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testResolver resolves service names from a map.
+type testResolver map[string][]string
+
+func (resolver testResolver) Resolve(service string) ([]string, error) {
+	addresses, ok := resolver[service]
+	if !ok {
+		return nil, fmt.Errorf("No such service '%s'", service)
+	}
+	return addresses, nil
+}
+
+func TestDNSResolver(t *testing.T) {
+	lookups := make([]string, 0)
+	resolver := network.NewDNSResolver("example.com")
+	resolver.LookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		lookups = append(lookups, fmt.Sprintf("_%s._%s.%s", service, proto, name))
+		return "", []*net.SRV{
+			{Target: "nsq1.example.com.", Port: 4151},
+			{Target: "nsq2.example.com.", Port: 4151},
+		}, nil
+	}
+	addresses, err := resolver.Resolve("nsqd-http")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"nsq1.example.com:4151", "nsq2.example.com:4151"}, addresses)
+	assert.Equal(t, []string{"_nsqd-http._tcp.example.com"}, lookups)
+
+	resolver.LookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		return "", nil, fmt.Errorf("no such host")
+	}
+	_, err = resolver.Resolve("nsqd-http")
+	assert.NotNil(t, err)
+}
+
+func TestConsulResolver(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/health/service/pharos" {
+			fmt.Fprintln(w, `[]`)
+			return
+		}
+		_, passing := r.URL.Query()["passing"]
+		assert.True(t, passing)
+		fmt.Fprintln(w, `[
+          {"Node": {"Address": "10.0.0.5"}, "Service": {"Address": "", "Port": 9292}},
+          {"Node": {"Address": "10.0.0.6"}, "Service": {"Address": "10.0.1.6", "Port": 9293}}
+        ]`)
+	}))
+	defer testServer.Close()
+	resolver := network.NewConsulResolver(testServer.URL + "/")
+	addresses, err := resolver.Resolve("pharos")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.5:9292", "10.0.1.6:9293"}, addresses)
+
+	_, err = resolver.Resolve("nsqd-http")
+	require.NotNil(t, err)
+	assert.Equal(t, "Consul has no healthy instances of service 'nsqd-http'", err.Error())
+}
+
+func TestExpandServiceName(t *testing.T) {
+	resolver := testResolver{
+		"nsqd-http": []string{"10.0.0.5:4151", "10.0.0.6:4151"},
+	}
+	assert.True(t, network.HasServiceName("http://{nsqd-http}"))
+	assert.False(t, network.HasServiceName("http://localhost:4151"))
+
+	expanded, err := network.ExpandServiceName(resolver, "http://{nsqd-http}/")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"http://10.0.0.5:4151/", "http://10.0.0.6:4151/"}, expanded)
+
+	expanded, err = network.ExpandServiceName(resolver, "http://localhost:4151")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"http://localhost:4151"}, expanded)
+
+	_, err = network.ExpandServiceName(resolver, "http://{pharos}")
+	assert.NotNil(t, err)
+	_, err = network.ExpandServiceName(resolver, "{nsqd-http},{nsqd-http}")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,109 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/models"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// SiegfriedClient identifies file formats by sending file content to
+// a Siegfried server, which is started with "sf -serve <addr>".
+// Siegfried matches files against the PRONOM signature registry.
+type SiegfriedClient struct {
+	// URL is the base URL of the Siegfried server.
+	URL    string
+	Client *http.Client
+}
+
+// NewSiegfriedClient returns a client for the Siegfried server at
+// the specified base URL.
+func NewSiegfriedClient(url string) *SiegfriedClient {
+	return &SiegfriedClient{
+		URL:    url,
+		Client: &http.Client{Timeout: 30 * time.Minute},
+	}
+}
+
+// siegfriedResponse is the part of Siegfried's JSON output we use.
+type siegfriedResponse struct {
+	Files []struct {
+		Filename string `json:"filename"`
+		Errors   string `json:"errors"`
+		Matches  []struct {
+			Namespace string `json:"ns"`
+			models.FormatIdentification
+		} `json:"matches"`
+	} `json:"files"`
+}
+
+// Identify streams the content of reader to Siegfried and returns the
+// PRONOM match for it. Param filename is the file's name, which
+// Siegfried uses for extension matching. Identify reads reader to the
+// end, unless it returns an error.
+func (client *SiegfriedClient) Identify(filename string, reader io.Reader) (*models.FormatIdentification, error) {
+	pipeReader, pipeWriter := io.Pipe()
+	form := multipart.NewWriter(pipeWriter)
+	go func() {
+		part, err := form.CreateFormFile("file", path.Base(filename))
+		if err == nil {
+			_, err = io.Copy(part, reader)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+	identifyURL := strings.TrimSuffix(client.URL, "/") + "/identify?format=json"
+	req, err := http.NewRequest("POST", identifyURL, pipeReader)
+	if err != nil {
+		pipeReader.CloseWithError(err)
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		pipeReader.CloseWithError(err)
+		return nil, fmt.Errorf("Siegfried could not identify %s: %v", filename, err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read Siegfried response for %s: %v", filename, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Siegfried returned status %d for %s: %s",
+			resp.StatusCode, filename, string(data))
+	}
+	return parseSiegfriedResponse(filename, data)
+}
+
+// parseSiegfriedResponse returns the first PRONOM match in Siegfried's
+// JSON output. If Siegfried was configured with several identifiers,
+// matches from other namespaces are ignored.
+func parseSiegfriedResponse(filename string, data []byte) (*models.FormatIdentification, error) {
+	response := &siegfriedResponse{}
+	if err := json.Unmarshal(data, response); err != nil {
+		return nil, fmt.Errorf("Cannot parse Siegfried response for %s: %v", filename, err)
+	}
+	if len(response.Files) == 0 {
+		return nil, fmt.Errorf("Siegfried returned no results for %s", filename)
+	}
+	file := response.Files[0]
+	if file.Errors != "" {
+		return nil, fmt.Errorf("Siegfried could not identify %s: %s", filename, file.Errors)
+	}
+	for _, match := range file.Matches {
+		if match.Namespace == "" || match.Namespace == "pronom" {
+			identification := match.FormatIdentification
+			return &identification, nil
+		}
+	}
+	return &models.FormatIdentification{PronomPuid: "UNKNOWN"}, nil
+}
@@ -0,0 +1,75 @@
+package network_test
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// siegfriedTestServer fakes "sf -serve". It identifies files that
+// start with "%PDF" as PDF 1.4, and nothing else.
+func siegfriedTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/identify", r.URL.Path)
+		assert.Equal(t, "json", r.URL.Query().Get("format"))
+		file, header, err := r.FormFile("file")
+		require.Nil(t, err)
+		data, err := ioutil.ReadAll(file)
+		require.Nil(t, err)
+		if header.Filename == "broken.bin" {
+			fmt.Fprint(w, `{"files": [{"filename": "broken.bin", "errors": "empty source"}]}`)
+			return
+		}
+		match := `{"ns": "pronom", "id": "UNKNOWN", "warning": "no match"}`
+		if strings.HasPrefix(string(data), "%PDF") {
+			match = `{"ns": "pronom", "id": "fmt/18", "format": "Acrobat PDF 1.4 - Portable Document Format", ` +
+				`"version": "1.4", "mime": "application/pdf", "basis": "byte match at 0, 8", "warning": ""}`
+		}
+		fmt.Fprintf(w, `{"siegfried": "1.9.1", "files": [{"filename": "%s", "filesize": %d, `+
+			`"errors": "", "matches": [{"ns": "tika", "id": "text/plain"}, %s]}]}`,
+			header.Filename, len(data), match)
+	}))
+}
+
+func TestSiegfriedClientIdentify(t *testing.T) {
+	server := siegfriedTestServer(t)
+	defer server.Close()
+	client := network.NewSiegfriedClient(server.URL + "/")
+
+	id, err := client.Identify("data/docs/report.pdf", strings.NewReader("%PDF-1.4 ..."))
+	require.Nil(t, err)
+	require.NotNil(t, id)
+	assert.Equal(t, "fmt/18", id.PronomPuid)
+	assert.Equal(t, "1.4", id.FormatVersion)
+	assert.Equal(t, "application/pdf", id.MimeType)
+	assert.Equal(t, "byte match at 0, 8", id.Basis)
+	assert.Equal(t, "", id.Warning)
+
+	id, err = client.Identify("data/notes.txt", strings.NewReader("Just some notes"))
+	require.Nil(t, err)
+	assert.Equal(t, "UNKNOWN", id.PronomPuid)
+	assert.False(t, id.IsKnown())
+
+	_, err = client.Identify("data/broken.bin", strings.NewReader(""))
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "empty source")
+}
+
+func TestSiegfriedClientIdentifyServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, "signature file not loaded")
+	}))
+	defer server.Close()
+	client := network.NewSiegfriedClient(server.URL)
+	_, err := client.Identify("data/report.pdf", strings.NewReader("%PDF-1.4 ..."))
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "status 500")
+}
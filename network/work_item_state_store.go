@@ -0,0 +1,311 @@
+package network
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+// StateBlobStore stores the WorkItemState payloads that are too large
+// for Pharos, and the WorkItemState history.
+type StateBlobStore interface {
+	Put(bucket, key string, data []byte) error
+	Get(bucket, key string) ([]byte, error)
+	// List returns the keys in bucket that start with prefix.
+	List(bucket, prefix string) ([]string, error)
+}
+
+// S3StateBlobStore is a StateBlobStore that keeps payloads in S3.
+type S3StateBlobStore struct {
+	AWSRegion   string
+	SessionPool *S3SessionPool
+
+	accessKeyId     string
+	secretAccessKey string
+}
+
+// NewS3StateBlobStore returns a StateBlobStore that reads and writes
+// S3 objects in the specified region, using clients from sessionPool.
+func NewS3StateBlobStore(accessKeyId, secretAccessKey, region string, sessionPool *S3SessionPool) *S3StateBlobStore {
+	return &S3StateBlobStore{
+		AWSRegion:       region,
+		SessionPool:     sessionPool,
+		accessKeyId:     accessKeyId,
+		secretAccessKey: secretAccessKey,
+	}
+}
+
+// Put writes data to bucket/key.
+func (store *S3StateBlobStore) Put(bucket, key string, data []byte) error {
+	service, err := store.SessionPool.Service(store.AWSRegion, store.accessKeyId, store.secretAccessKey)
+	if err != nil {
+		return err
+	}
+	_, err = service.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
+
+// Get returns the contents of bucket/key.
+func (store *S3StateBlobStore) Get(bucket, key string) ([]byte, error) {
+	service, err := store.SessionPool.Service(store.AWSRegion, store.accessKeyId, store.secretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := service.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// List returns the keys in bucket that start with prefix.
+func (store *S3StateBlobStore) List(bucket, prefix string) ([]string, error) {
+	service, err := store.SessionPool.Service(store.AWSRegion, store.accessKeyId, store.secretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	err = service.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, *obj.Key)
+		}
+		return true
+	})
+	return keys, err
+}
+
+// WorkItemStateStore saves WorkItemStates to Pharos and gets them back,
+// on behalf of all workers. States larger than MaxSize go to Blobs,
+// and Pharos gets a WorkItemStatePointer in their place. The store
+// follows pointers on the way back, so callers always see the full
+// state, regardless of where it lives.
+type WorkItemStateStore struct {
+	Pharos *PharosClient
+	Blobs  StateBlobStore
+	// Bucket is the bucket in Blobs where new payloads go. Existing
+	// pointers carry their own bucket.
+	Bucket string
+	// MaxSize is the largest state, in bytes, that we store in
+	// Pharos. Zero means no limit.
+	MaxSize int
+	// CompressAbove is the size, in bytes, above which Save gzips
+	// states before checking MaxSize. Zero means never compress.
+	CompressAbove int
+	// KeepHistory tells Save to add a copy of each state it saves
+	// to the item's history in Bucket. See History.
+	KeepHistory bool
+	// Node and Pid identify this worker in the history. The
+	// constructor sets them to the hostname and process id.
+	Node string
+	Pid  int
+}
+
+// NewWorkItemStateStore returns a WorkItemStateStore that offloads
+// states larger than maxSize to bucket in blobs. If blobs is nil,
+// bucket is empty, or maxSize is zero, all states go to Pharos.
+func NewWorkItemStateStore(pharos *PharosClient, blobs StateBlobStore, bucket string, maxSize int) *WorkItemStateStore {
+	node, _ := os.Hostname()
+	return &WorkItemStateStore{
+		Pharos:  pharos,
+		Blobs:   blobs,
+		Bucket:  bucket,
+		MaxSize: maxSize,
+		Node:    node,
+		Pid:     os.Getpid(),
+	}
+}
+
+// Save saves state to Pharos, first compressing its payload if it's
+// larger than CompressAbove, and then moving the payload to Blobs if
+// it's still larger than MaxSize. The WorkItemState in the response
+// has the full, uncompressed payload, not the pointer. If KeepHistory
+// is on, Save adds state to the history before saving it, and does
+// not save it if it can't add it to the history.
+func (store *WorkItemStateStore) Save(state *models.WorkItemState) *PharosResponse {
+	if store.keepingHistory() {
+		if err := store.addToHistory(state); err != nil {
+			resp := NewPharosResponse(PharosWorkItemState)
+			resp.Error = err
+			return resp
+		}
+	}
+	encodedState := *state
+	if store.shouldCompress(state) {
+		if err := encodedState.Compress(); err != nil {
+			resp := NewPharosResponse(PharosWorkItemState)
+			resp.Error = fmt.Errorf("Cannot compress state of WorkItem %d: %v",
+				state.WorkItemId, err)
+			return resp
+		}
+	}
+	if !store.shouldOffload(&encodedState) {
+		resp := store.Pharos.WorkItemStateSave(&encodedState)
+		store.restorePayload(resp, state)
+		return resp
+	}
+	payload := []byte(encodedState.State)
+	digest := sha256.Sum256(payload)
+	pointer := &models.WorkItemStatePointer{
+		Bucket:          store.Bucket,
+		Key:             fmt.Sprintf("work_item_states/%d/%s.json", state.WorkItemId, hex.EncodeToString(digest[:])),
+		Sha256:          hex.EncodeToString(digest[:]),
+		Size:            len(payload),
+		ContentEncoding: encodedState.ContentEncoding,
+	}
+	if err := store.Blobs.Put(pointer.Bucket, pointer.Key, payload); err != nil {
+		resp := NewPharosResponse(PharosWorkItemState)
+		resp.Error = fmt.Errorf("Cannot save state of WorkItem %d to %s/%s: %v",
+			state.WorkItemId, pointer.Bucket, pointer.Key, err)
+		return resp
+	}
+	pointerState := *state
+	if err := pointerState.SetStatePointer(pointer); err != nil {
+		resp := NewPharosResponse(PharosWorkItemState)
+		resp.Error = err
+		return resp
+	}
+	resp := store.Pharos.WorkItemStateSave(&pointerState)
+	store.restorePayload(resp, state)
+	return resp
+}
+
+// restorePayload puts the payload of state, as the caller gave it to
+// Save, into the WorkItemState in resp, in place of the compressed
+// payload or pointer that went to Pharos.
+func (store *WorkItemStateStore) restorePayload(resp *PharosResponse, state *models.WorkItemState) {
+	if resp.Error == nil && resp.WorkItemState() != nil {
+		resp.WorkItemState().State = state.State
+		resp.WorkItemState().ContentEncoding = state.ContentEncoding
+	}
+}
+
+// Get returns the WorkItemState with the specified id from Pharos. If
+// Pharos has a pointer in place of the state, Get fetches the payload
+// from Blobs and checks its sha256 digest. Compressed payloads are
+// decompressed, so the state always holds plain JSON. Any error in
+// that goes into the response's Error.
+func (store *WorkItemStateStore) Get(workItemStateId int) *PharosResponse {
+	resp := store.Pharos.WorkItemStateGet(workItemStateId)
+	if resp.Error != nil || resp.WorkItemState() == nil {
+		return resp
+	}
+	state := resp.WorkItemState()
+	pointer := state.StatePointer()
+	if pointer == nil {
+		resp.Error = state.Decompress()
+		return resp
+	}
+	if store.Blobs == nil {
+		resp.Error = fmt.Errorf("WorkItemState %d is stored at %s/%s, but there is "+
+			"no blob store configured to read it", state.Id, pointer.Bucket, pointer.Key)
+		return resp
+	}
+	payload, err := store.Blobs.Get(pointer.Bucket, pointer.Key)
+	if err != nil {
+		resp.Error = fmt.Errorf("Cannot read WorkItemState %d from %s/%s: %v",
+			state.Id, pointer.Bucket, pointer.Key, err)
+		return resp
+	}
+	digest := sha256.Sum256(payload)
+	if hex.EncodeToString(digest[:]) != pointer.Sha256 {
+		resp.Error = fmt.Errorf("WorkItemState %d at %s/%s has sha256 %s, expected %s",
+			state.Id, pointer.Bucket, pointer.Key, hex.EncodeToString(digest[:]), pointer.Sha256)
+		return resp
+	}
+	state.State = string(payload)
+	state.ContentEncoding = pointer.ContentEncoding
+	resp.Error = state.Decompress()
+	return resp
+}
+
+// shouldCompress returns true if Save should compress state's payload.
+func (store *WorkItemStateStore) shouldCompress(state *models.WorkItemState) bool {
+	return store.CompressAbove > 0 && state.ContentEncoding == "" &&
+		len(state.State) > store.CompressAbove
+}
+
+// shouldOffload returns true if state's payload belongs in Blobs
+// instead of Pharos.
+func (store *WorkItemStateStore) shouldOffload(state *models.WorkItemState) bool {
+	return store.Blobs != nil && store.Bucket != "" && store.MaxSize > 0 &&
+		len(state.State) > store.MaxSize
+}
+
+// History returns every version of the WorkItem's state that workers
+// have saved with KeepHistory on, oldest first.
+func (store *WorkItemStateStore) History(workItemId int) ([]*models.WorkItemStateVersion, error) {
+	if store.Blobs == nil || store.Bucket == "" {
+		return nil, fmt.Errorf("Cannot get state history of WorkItem %d, because "+
+			"there is no bucket configured for it", workItemId)
+	}
+	keys, err := store.Blobs.List(store.Bucket, historyPrefix(workItemId))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot list state history of WorkItem %d: %v", workItemId, err)
+	}
+	// Keys start with the time of the save, so this puts
+	// them in the order they were saved.
+	sort.Strings(keys)
+	versions := make([]*models.WorkItemStateVersion, len(keys))
+	for i, key := range keys {
+		data, err := store.Blobs.Get(store.Bucket, key)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read %s/%s: %v", store.Bucket, key, err)
+		}
+		version := &models.WorkItemStateVersion{}
+		if err = json.Unmarshal(data, version); err != nil {
+			return nil, fmt.Errorf("Cannot parse %s/%s: %v", store.Bucket, key, err)
+		}
+		version.Version = i + 1
+		versions[i] = version
+	}
+	return versions, nil
+}
+
+// keepingHistory returns true if Save should add states to the history.
+func (store *WorkItemStateStore) keepingHistory() bool {
+	return store.KeepHistory && store.Blobs != nil && store.Bucket != ""
+}
+
+// addToHistory writes a new version of state to Blobs. Each version
+// gets its own key, so nothing in the history is ever overwritten.
+func (store *WorkItemStateStore) addToHistory(state *models.WorkItemState) error {
+	version := models.NewWorkItemStateVersion(state, store.Node, store.Pid, time.Now())
+	data, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%s-%s-%d.json", historyPrefix(state.WorkItemId),
+		version.SavedAt.Format("20060102T150405.000000000Z"), store.Node, store.Pid)
+	if err = store.Blobs.Put(store.Bucket, key, data); err != nil {
+		return fmt.Errorf("Cannot add state of WorkItem %d to history at %s/%s: %v",
+			state.WorkItemId, store.Bucket, key, err)
+	}
+	return nil
+}
+
+// historyPrefix returns the prefix of the keys for the WorkItem's
+// state history.
+func historyPrefix(workItemId int) string {
+	return fmt.Sprintf("work_item_states/%d/history/", workItemId)
+}
@@ -0,0 +1,230 @@
+package network_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// memoryBlobStore is a StateBlobStore that keeps payloads in memory.
+type memoryBlobStore struct {
+	blobs map[string][]byte
+}
+
+func (store *memoryBlobStore) Put(bucket, key string, data []byte) error {
+	store.blobs[bucket+"/"+key] = data
+	return nil
+}
+
+func (store *memoryBlobStore) Get(bucket, key string) ([]byte, error) {
+	data, ok := store.blobs[bucket+"/"+key]
+	if !ok {
+		return nil, fmt.Errorf("No such key: %s/%s", bucket, key)
+	}
+	return data, nil
+}
+
+func (store *memoryBlobStore) List(bucket, prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	for key := range store.blobs {
+		if strings.HasPrefix(key, bucket+"/"+prefix) {
+			keys = append(keys, strings.TrimPrefix(key, bucket+"/"))
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// statePharosHandler saves one WorkItemState in memory, so tests can
+// see what the store sent to Pharos and get it back.
+type statePharosHandler struct {
+	saved *models.WorkItemState
+}
+
+func (handler *statePharosHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		if handler.saved == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, _ := json.Marshal(handler.saved)
+		w.Write(data)
+		return
+	}
+	state := &models.WorkItemState{}
+	if err := json.NewDecoder(r.Body).Decode(state); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	handler.saved = state
+	handler.saved.Id = 1000
+	data, _ := json.Marshal(handler.saved)
+	w.Write(data)
+}
+
+func newTestStateStore(t *testing.T, maxSize int) (*network.WorkItemStateStore, *statePharosHandler, *memoryBlobStore, func()) {
+	handler := &statePharosHandler{}
+	testServer := httptest.NewServer(handler)
+	client, err := network.NewPharosClient(testServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+	blobs := &memoryBlobStore{blobs: make(map[string][]byte)}
+	store := network.NewWorkItemStateStore(client, blobs, "aptrust.states", maxSize)
+	return store, handler, blobs, testServer.Close
+}
+
+func TestWorkItemStateStore_SmallState(t *testing.T) {
+	store, handler, blobs, closeServer := newTestStateStore(t, 1000)
+	defer closeServer()
+
+	state := models.NewWorkItemState(999, "Restore", `{"small": true}`)
+	resp := store.Save(state)
+	require.Nil(t, resp.Error)
+	assert.Equal(t, `{"small": true}`, resp.WorkItemState().State)
+	assert.Equal(t, `{"small": true}`, handler.saved.State)
+	assert.Empty(t, blobs.blobs)
+
+	resp = store.Get(1000)
+	require.Nil(t, resp.Error)
+	assert.Equal(t, `{"small": true}`, resp.WorkItemState().State)
+}
+
+func TestWorkItemStateStore_LargeState(t *testing.T) {
+	store, handler, blobs, closeServer := newTestStateStore(t, 100)
+	defer closeServer()
+
+	payload := fmt.Sprintf(`{"big": "%s"}`, strings.Repeat("x", 500))
+	state := models.NewWorkItemState(999, "Restore", payload)
+	resp := store.Save(state)
+	require.Nil(t, resp.Error)
+
+	// Caller sees the whole state, Pharos sees only a pointer.
+	assert.Equal(t, payload, resp.WorkItemState().State)
+	assert.Equal(t, 1000, resp.WorkItemState().Id)
+	assert.Equal(t, payload, state.State)
+	pointer := handler.saved.StatePointer()
+	require.NotNil(t, pointer)
+	assert.Equal(t, "aptrust.states", pointer.Bucket)
+	assert.True(t, strings.HasPrefix(pointer.Key, "work_item_states/999/"))
+	assert.Equal(t, len(payload), pointer.Size)
+	assert.Equal(t, payload, string(blobs.blobs[pointer.Bucket+"/"+pointer.Key]))
+
+	resp = store.Get(1000)
+	require.Nil(t, resp.Error)
+	assert.Equal(t, payload, resp.WorkItemState().State)
+
+	// Get should notice if the payload has changed.
+	blobs.blobs[pointer.Bucket+"/"+pointer.Key] = []byte(`{"tampered": true}`)
+	resp = store.Get(1000)
+	require.NotNil(t, resp.Error)
+	assert.Contains(t, resp.Error.Error(), "sha256")
+}
+
+func TestWorkItemStateStore_CompressedState(t *testing.T) {
+	store, handler, blobs, closeServer := newTestStateStore(t, 1000)
+	defer closeServer()
+	store.CompressAbove = 100
+
+	// Compressed, this fits in Pharos.
+	payload := fmt.Sprintf(`{"big": "%s"}`, strings.Repeat("x", 5000))
+	state := models.NewWorkItemState(999, "Restore", payload)
+	resp := store.Save(state)
+	require.Nil(t, resp.Error)
+	assert.Equal(t, payload, resp.WorkItemState().State)
+	assert.Equal(t, "", resp.WorkItemState().ContentEncoding)
+	assert.Equal(t, payload, state.State)
+	assert.Equal(t, models.WorkItemStateEncodingGzip, handler.saved.ContentEncoding)
+	assert.True(t, len(handler.saved.State) < 1000)
+	assert.Empty(t, blobs.blobs)
+
+	resp = store.Get(1000)
+	require.Nil(t, resp.Error)
+	assert.Equal(t, payload, resp.WorkItemState().State)
+	assert.Equal(t, "", resp.WorkItemState().ContentEncoding)
+
+	// Compressed states that are still too big are offloaded,
+	// and the pointer says how they're encoded.
+	store.MaxSize = 10
+	resp = store.Save(state)
+	require.Nil(t, resp.Error)
+	pointer := handler.saved.StatePointer()
+	require.NotNil(t, pointer)
+	assert.Equal(t, models.WorkItemStateEncodingGzip, pointer.ContentEncoding)
+	resp = store.Get(1000)
+	require.Nil(t, resp.Error)
+	assert.Equal(t, payload, resp.WorkItemState().State)
+}
+
+func TestWorkItemStateStore_NoBlobs(t *testing.T) {
+	store, handler, _, closeServer := newTestStateStore(t, 100)
+	defer closeServer()
+	store.Blobs = nil
+
+	// Without a blob store, everything goes to Pharos.
+	payload := fmt.Sprintf(`{"big": "%s"}`, strings.Repeat("x", 500))
+	resp := store.Save(models.NewWorkItemState(999, "Restore", payload))
+	require.Nil(t, resp.Error)
+	assert.Equal(t, payload, handler.saved.State)
+
+	// But we can't follow a pointer.
+	require.Nil(t, handler.saved.SetStatePointer(&models.WorkItemStatePointer{
+		Bucket: "aptrust.states",
+		Key:    "work_item_states/999/abc.json",
+	}))
+	resp = store.Get(1000)
+	assert.NotNil(t, resp.Error)
+}
+
+func TestWorkItemStateStore_History(t *testing.T) {
+	store, _, blobs, closeServer := newTestStateStore(t, 100)
+	defer closeServer()
+
+	// No history unless we ask for it.
+	resp := store.Save(models.NewWorkItemState(999, "Ingest", `{"attempt": 0}`))
+	require.Nil(t, resp.Error)
+	history, err := store.History(999)
+	require.Nil(t, err)
+	assert.Empty(t, history)
+
+	store.KeepHistory = true
+	store.Node = "worker1"
+	store.Pid = 4321
+	payloads := []string{
+		`{"attempt": 1}`,
+		`{"attempt": 2}`,
+		fmt.Sprintf(`{"attempt": 3, "big": "%s"}`, strings.Repeat("x", 500)),
+	}
+	for _, payload := range payloads {
+		resp = store.Save(models.NewWorkItemState(999, "Ingest", payload))
+		require.Nil(t, resp.Error)
+	}
+	resp = store.Save(models.NewWorkItemState(1000, "Ingest", `{"other": true}`))
+	require.Nil(t, resp.Error)
+
+	history, err = store.History(999)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(history))
+	for i, version := range history {
+		assert.Equal(t, i+1, version.Version)
+		assert.Equal(t, 999, version.WorkItemId)
+		assert.Equal(t, "Ingest", version.Action)
+		// History holds full states, even offloaded ones.
+		assert.Equal(t, payloads[i], version.State)
+		assert.Equal(t, "worker1", version.Node)
+		assert.Equal(t, 4321, version.Pid)
+		assert.False(t, version.SavedAt.IsZero())
+	}
+
+	// No history without a bucket.
+	store.Blobs = nil
+	_, err = store.History(999)
+	assert.NotNil(t, err)
+	assert.NotEmpty(t, blobs.blobs)
+}
@@ -44,9 +44,7 @@ func main() {
 		fmt.Printf("Filename: %s\n", fileToCheck)
 		fmt.Println("----------------------------------------------")
 	}
-	client, err := network.NewPharosClient(opts.PharosURL,
-		common.PharosAPIVersion,
-		opts.APTrustAPIUser, opts.APTrustAPIKey)
+	client, err := newPharosClient(opts)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(common.EXIT_RUNTIME_ERR)
@@ -143,6 +141,20 @@ func printText(objects []OutputObject, fileToCheck, etag string) {
 	}
 }
 
+// newPharosClient returns a PharosClient scoped to the user's
+// institution, so we never see another institution's records.
+// If we don't know the user's institution, Pharos limits what
+// we can see based on the user's API credentials.
+func newPharosClient(opts *common.Options) (*network.PharosClient, error) {
+	if opts.Institution != "" {
+		return network.NewInstitutionScopedPharosClient(opts.PharosURL,
+			common.PharosAPIVersion, opts.APTrustAPIUser, opts.APTrustAPIKey,
+			opts.Institution)
+	}
+	return network.NewPharosClient(opts.PharosURL, common.PharosAPIVersion,
+		opts.APTrustAPIUser, opts.APTrustAPIKey)
+}
+
 func printJson(objects []OutputObject) {
 	jsonBytes, err := json.Marshal(objects)
 	if err != nil {
@@ -183,6 +195,7 @@ func printOpts(opts *common.Options) {
 	fmt.Println("  APTrust API User:", opts.APTrustAPIUser, "(from", opts.APTrustAPIUserFrom, ")")
 	fmt.Println("  APTrust API Key:", opts.APTrustAPIKey, "(from", opts.APTrustAPIKeyFrom, ")")
 	fmt.Println("  APTrust REST URL:", opts.PharosURL)
+	fmt.Println("  Institution:", opts.Institution)
 	fmt.Println("  Output Format:", opts.OutputFormat)
 	fmt.Println("  Debug:", opts.Debug)
 	fmt.Println("----------------------------------------------")
@@ -204,6 +217,7 @@ func parseCommandLine() *common.Options {
 	var pharosEnv string
 	var outputFormat string
 	var etag string
+	var institution string
 	var help bool
 	var version bool
 	var debug bool
@@ -211,6 +225,7 @@ func parseCommandLine() *common.Options {
 	flag.StringVar(&pharosEnv, "env", "production", "Which environment to query: production [default] or demo.")
 	flag.StringVar(&outputFormat, "format", "text", "Output format ('text' or 'json')")
 	flag.StringVar(&etag, "etag", "", "The etag of the bag you want to check on")
+	flag.StringVar(&institution, "institution", "", "Identifier of your institution")
 	flag.BoolVar(&help, "help", false, "Show help")
 	flag.BoolVar(&version, "version", false, "Show version")
 	flag.BoolVar(&debug, "debug", false, "Print debugging output to stdout")
@@ -239,6 +254,7 @@ func parseCommandLine() *common.Options {
 		PathToConfigFile: pathToConfigFile,
 		OutputFormat:     outputFormat,
 		ETag:             etag,
+		Institution:      institution,
 		PharosURL:        pharosUrl,
 		Debug:            debug,
 	}
@@ -265,6 +281,7 @@ APTrust config file.
 Usage: apt_check_ingest [--config=<path to config file>] \
 			[--env=<production|demo>] \
 			[--etag=<etag>] \
+			[--institution=<identifier>] \
 			[--format=<json|text>] \
 			[--debug] <filename.tar>
 
@@ -290,6 +307,11 @@ Options
   will have a different etag. Specifying the etag here allows you to check
   on a single version of a bag that was uploaded multiple times.
 
+--institution is the identifier of your institution, e.g. "virginia.edu".
+  The tool will query only your institution's records. If unspecified,
+  the tool derives your institution from the ReceivingBucket setting in
+  your config file.
+
 --format specifies whether the result of the query should be printed
   to STDOUT in json or plain text format. Default is json.
 
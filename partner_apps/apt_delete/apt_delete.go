@@ -55,12 +55,16 @@ func parseCommandLine() (*common.Options, []string) {
 	var region string
 	var bucket string
 	var key string
+	var keysFrom string
+	var force bool
 	var help bool
 	var version bool
 	flag.StringVar(&pathToConfigFile, "config", "", "Path to partner config file")
 	flag.StringVar(&region, "region", constants.AWSVirginia, "AWS region (default 'us-east-1')")
 	flag.StringVar(&bucket, "bucket", "", "The bucket to delete from")
 	flag.StringVar(&key, "key", "", "The key (name) of the object to delete")
+	flag.StringVar(&keysFrom, "keys-from", "", "File listing keys to delete, one per line ('-' for STDIN)")
+	flag.BoolVar(&force, "force", false, "Delete from buckets not in the config file")
 	flag.BoolVar(&help, "help", false, "Show help")
 	flag.BoolVar(&version, "version", false, "Show version")
 
@@ -80,6 +84,7 @@ func parseCommandLine() (*common.Options, []string) {
 		Region:           region,
 		Bucket:           bucket,
 		Key:              key,
+		Force:            force,
 	}
 
 	if os.Getenv("AWS_ACCESS_KEY_ID") != "" {
@@ -92,6 +97,14 @@ func parseCommandLine() (*common.Options, []string) {
 	}
 
 	files := flag.Args()
+	if keysFrom != "" {
+		keys, err := readKeysFrom(keysFrom)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Cannot read keys from", keysFrom+":", err)
+			os.Exit(common.EXIT_USER_ERR)
+		}
+		files = append(files, keys...)
+	}
 
 	if len(files) == 0 {
 		fmt.Fprintln(os.Stderr, "You must specify at least one file to delete.")
@@ -101,6 +114,20 @@ func parseCommandLine() (*common.Options, []string) {
 	return opts, files
 }
 
+// readKeysFrom reads the list of keys to delete from the file at
+// pathToFile, or from STDIN if pathToFile is "-".
+func readKeysFrom(pathToFile string) ([]string, error) {
+	if pathToFile == "-" {
+		return common.ReadKeys(os.Stdin)
+	}
+	file, err := os.Open(pathToFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return common.ReadKeys(file)
+}
+
 // Tell the user about the program.
 func printUsage() {
 	message := `
@@ -113,6 +140,8 @@ apt_delete [options] key
 apt_delete --config=<path to config file> \
 	   [--region=<AWS region>] \
 	   --bucket=<bucket to delete from> \
+	   [--force] \
+	   [--keys-from=<file or ->] \
 	   file1 ... fileN
 
 apt_delete --help
@@ -130,6 +159,8 @@ it will get your AWS credentials from the environment variables
 AWS credentials, it will exit with an error message.
 
 --bucket is the name of the S3 bucket containing the key you want to delete.
+  This must be the ReceivingBucket from your config file, unless you also
+  specify --force.
 
 --config is the optional path to your APTrust partner config file.
   If you omit this, the program uses the config at
@@ -139,8 +170,19 @@ AWS credentials, it will exit with an error message.
   For info about what should be in your config file, see
   https://wiki.aptrust.org/Partner_Tools
 
+--force lets you delete from a bucket that is not the ReceivingBucket in
+  your config file, such as your RestorationBucket. Be careful with this,
+  and never use it to delete from a preservation bucket.
+
 --help prints this help message and exits.
 
+--keys-from is the path to a file that lists the keys to delete, one per
+  line. Blank lines and lines beginning with # are ignored. Keys may not
+  begin or end with spaces or tabs, because those are easy to add by
+  mistake and would delete a different key. Use --keys-from=-
+  to read the list from STDIN. Keys from this file are deleted along with
+  any keys listed on the command line.
+
 --region is the S3 region to connect to. This defaults to us-east-1.
 
 --version prints version info and exits.
@@ -151,9 +193,13 @@ If keys contain whitespace, quote them.
 
 Example:
 
-   Delete three keys from the bucket called "my_bucket"
+   Delete three keys from your receiving bucket
+
+   apt_delete -bucket=aptrust.receiving.example.edu old_file.pdf old_image.jpg old_windbag.trump
+
+   Delete the keys listed in old_bags.txt from your receiving bucket
 
-   apt_delete -bucket=my_bucket old_file.pdf old_image.jpg old_windbag.trump
+   apt_delete -bucket=aptrust.receiving.example.edu -keys-from=old_bags.txt
 
 Exit codes:
 
@@ -17,6 +17,18 @@ func main() {
 		fmt.Fprintln(os.Stderr, opts.AllErrorsAsString())
 		os.Exit(common.EXIT_USER_ERR)
 	}
+	// Look up the expected checksums before downloading, so we
+	// don't make the user wait for a large download only to find
+	// we can't verify it.
+	expectedMd5, expectedSha256 := opts.Md5, opts.Sha256
+	if opts.FileIdentifier != "" {
+		var err error
+		expectedMd5, expectedSha256, err = getPharosDigests(opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(common.EXIT_RUNTIME_ERR)
+		}
+	}
 	client := network.NewS3Download(
 		opts.AccessKeyId,
 		opts.SecretAccessKey,
@@ -27,8 +39,10 @@ func main() {
 		true,
 		true,
 	)
+	client.Resume = opts.Resume
 	client.Fetch()
 	result := common.NewDownloadResult(opts, client)
+	result.VerifyChecksums(expectedMd5, expectedSha256)
 	output := result.ToText()
 	if opts.OutputFormat == "json" {
 		var err error
@@ -43,12 +57,45 @@ func main() {
 	exitCode := common.EXIT_OK
 	if strings.Contains(result.ErrorMessage, "NoSuchKey") {
 		exitCode = common.EXIT_ITEM_NOT_FOUND
+	} else if result.ChecksumMismatch {
+		exitCode = common.EXIT_CHECKSUM_MISMATCH
 	} else if result.ErrorMessage != "" {
 		exitCode = common.EXIT_RUNTIME_ERR
 	}
 	os.Exit(exitCode)
 }
 
+// getPharosDigests returns the latest md5 and sha256 digests Pharos
+// has for the GenericFile opts.FileIdentifier. Pharos shows us only
+// files that the user's API credentials allow us to see.
+func getPharosDigests(opts *common.Options) (md5, sha256 string, err error) {
+	client, err := network.NewPharosClient(opts.PharosURL, common.PharosAPIVersion,
+		opts.APTrustAPIUser, opts.APTrustAPIKey)
+	if err != nil {
+		return "", "", err
+	}
+	resp := client.GenericFileGet(opts.FileIdentifier, true)
+	if resp.Error != nil {
+		return "", "", fmt.Errorf("Cannot get checksums for %s from Pharos: %v",
+			opts.FileIdentifier, resp.Error)
+	}
+	gf := resp.GenericFile()
+	if gf == nil {
+		return "", "", fmt.Errorf("Pharos has no record of %s", opts.FileIdentifier)
+	}
+	if checksum := gf.GetChecksumByAlgorithm(constants.AlgMd5); checksum != nil {
+		md5 = checksum.Digest
+	}
+	if checksum := gf.GetChecksumByAlgorithm(constants.AlgSha256); checksum != nil {
+		sha256 = checksum.Digest
+	}
+	if md5 == "" && sha256 == "" {
+		return "", "", fmt.Errorf("Pharos has no md5 or sha256 checksum for %s",
+			opts.FileIdentifier)
+	}
+	return md5, sha256, nil
+}
+
 // Get user-specified options from the command line,
 // environment, and/or config file.
 func getUserOptions() *common.Options {
@@ -67,6 +114,11 @@ func parseCommandLine() *common.Options {
 	var key string
 	var dir string
 	var outputFormat string
+	var md5 string
+	var sha256 string
+	var pharosFile string
+	var pharosEnv string
+	var resume bool
 	var help bool
 	var version bool
 
@@ -76,6 +128,11 @@ func parseCommandLine() *common.Options {
 	flag.StringVar(&key, "key", "", "The key you want to fetch")
 	flag.StringVar(&dir, "dir", "", "Download file to this directory (default is current dir)")
 	flag.StringVar(&outputFormat, "format", "text", "Output format ('text' or 'json')")
+	flag.StringVar(&md5, "md5", "", "Expected md5 digest of the downloaded file")
+	flag.StringVar(&sha256, "sha256", "", "Expected sha256 digest of the downloaded file")
+	flag.StringVar(&pharosFile, "pharosFile", "", "Verify the download against this file's checksums in Pharos")
+	flag.StringVar(&pharosEnv, "env", "production", "Pharos environment for -pharosFile: production [default] or demo.")
+	flag.BoolVar(&resume, "resume", false, "Resume an interrupted download instead of starting over")
 	flag.BoolVar(&help, "help", false, "Show help")
 	flag.BoolVar(&version, "version", false, "Show version")
 
@@ -90,6 +147,16 @@ func parseCommandLine() *common.Options {
 		os.Exit(common.EXIT_NO_OP)
 	}
 
+	if pharosEnv != "production" && pharosEnv != "demo" {
+		fmt.Fprintln(os.Stderr, "Invalid value for -env:", pharosEnv)
+		printUsage()
+		os.Exit(common.EXIT_USER_ERR)
+	}
+	pharosUrl := "https://repo.aptrust.org"
+	if pharosEnv == "demo" {
+		pharosUrl = "https://demo.aptrust.org"
+	}
+
 	opts := &common.Options{
 		PathToConfigFile: pathToConfigFile,
 		Region:           region,
@@ -97,6 +164,11 @@ func parseCommandLine() *common.Options {
 		Key:              key,
 		Dir:              dir,
 		OutputFormat:     outputFormat,
+		Md5:              md5,
+		Sha256:           sha256,
+		FileIdentifier:   pharosFile,
+		PharosURL:        pharosUrl,
+		Resume:           resume,
 	}
 
 	if os.Getenv("AWS_ACCESS_KEY_ID") != "" {
@@ -122,7 +194,10 @@ apt_download --key=<name/key of object to download> \
              [--config=<path to config file>] \
              [--region=<aws region to connect to>] \
 			 [--dir=<download the object to this dir>] \
-			 [--format=<'text' or 'json'>]
+			 [--format=<'text' or 'json'>] \
+			 [--resume] \
+			 [--md5=<expected md5>] [--sha256=<expected sha256>] \
+			 [--pharosFile=<file identifier> [--env=<'production' or 'demo'>]]
 
 apt_download --help
 
@@ -158,6 +233,9 @@ If it can't find your AWS credentials, the download will fail.
   there's no config file, your S3 item will be downloaded into the
   current working directory from which you're running this app.
 
+--env is the Pharos environment to query for -pharosFile. Options are
+  'production' and 'demo', and the default is 'production'.
+
 --format is the format of the output printed to STDOUT when the download
   is complete. Options are 'text' and 'json', and the default is 'text'.
 
@@ -166,11 +244,33 @@ If it can't find your AWS credentials, the download will fail.
 --key is the name of the item you want to download from S3. This param
   is required.
 
+--md5 is the md5 digest you expect the downloaded file to have. If the
+  download's md5 doesn't match, apt_download exits with code 7.
+
+--pharosFile is the identifier of a file in APTrust, such as
+  "virginia.edu/my_bag/data/file.pdf". apt_download checks the download
+  against the latest md5 and sha256 checksums APTrust has for that file,
+  and exits with code 7 if they don't match. This requires the
+  AptrustApiUser and AptrustApiKey settings in your config file, and
+  can't be combined with --md5 or --sha256.
+
 --region is the S3 region to connect to. This defaults to us-east-1. You
   generally should not have to set this for APTrust downloads,
   but you may set it on the command line to download non-APTrust
   files from your own buckets.
 
+--resume continues an interrupted download, keeping the part of the file
+  that's already in --dir and fetching only the rest. Without this,
+  apt_download starts over and overwrites any existing file. Checksums
+  always cover the whole file. While a download with --resume is
+  incomplete, apt_download keeps the object's ETag in a file named
+  after the download, with .etag on the end. If the object has changed
+  since then, or the interrupted download didn't use --resume,
+  apt_download starts over.
+
+--sha256 is the sha256 digest you expect the downloaded file to have. If
+  the download's sha256 doesn't match, apt_download exits with code 7.
+
 --version prints version info and exits.
 
 Examples:
@@ -187,12 +287,18 @@ Examples:
 
    apt_download -key="my_bag.tar" -bucket="my.custom.bucket" -dir="/home/joy/downloads"
 
+3. Finish an interrupted download of "file.pdf", and make sure it matches
+   the checksums APTrust has on record.
+
+   apt_download -key="file.pdf" -resume -pharosFile="virginia.edu/my_bag/data/file.pdf"
+
 Exit codes:
 
 0 - Bag was successfully downloaded.
 1 - Operation could not be completed due to runtime, network, or server error
 3 - Operation could not be completed due to usage error (e.g. missing params)
 4 - Bag was not found in S3.
+7 - The download's checksums did not match the expected checksums.
 100 - Printed help or version message. No other operations attempted.
 `
 	fmt.Println(message)
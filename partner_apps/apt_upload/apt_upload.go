@@ -19,6 +19,9 @@ func main() {
 		fmt.Fprintln(os.Stderr, opts.AllErrorsAsString())
 		os.Exit(common.EXIT_USER_ERR)
 	}
+	if opts.ValidateBag {
+		validateBag(opts)
+	}
 	uploadClient := network.NewS3Upload(
 		opts.AccessKeyId,
 		opts.SecretAccessKey,
@@ -45,6 +48,31 @@ func main() {
 	os.Exit(exitCode)
 }
 
+// validateBag validates the bag we're about to upload, and exits
+// without uploading if the bag is invalid, unless the user said
+// to upload it anyway.
+func validateBag(opts *common.Options) {
+	summary, err := common.ValidateBag(opts.FileToUpload, opts.BagValidationConfigFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(common.EXIT_RUNTIME_ERR)
+	}
+	for _, warning := range summary.WarningMessages() {
+		fmt.Fprintln(os.Stderr, "Warning:", warning)
+	}
+	if !summary.HasErrors() {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Bag is not valid")
+	fmt.Fprintln(os.Stderr, summary.AllErrorsAsString())
+	if opts.UploadInvalidBag {
+		fmt.Fprintln(os.Stderr, "Uploading anyway because of --force")
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Bag was not uploaded. Use --force to upload it anyway.")
+	os.Exit(common.EXIT_BAG_INVALID)
+}
+
 func exitOnFileError(err error) {
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
@@ -97,6 +125,9 @@ func parseCommandLine() *common.Options {
 	var contentType string
 	var outputFormat string
 	var metadata string
+	var validate bool
+	var validationConfig string
+	var force bool
 	var help bool
 	var version bool
 
@@ -107,6 +138,9 @@ func parseCommandLine() *common.Options {
 	flag.StringVar(&contentType, "contentType", "", "The mime type being uploaded (optional)")
 	flag.StringVar(&outputFormat, "format", "text", "Output format ('text' or 'json')")
 	flag.StringVar(&metadata, "metadata", "", "Optional metadata to store in S3")
+	flag.BoolVar(&validate, "validate", false, "Validate the bag before uploading")
+	flag.StringVar(&validationConfig, "validationConfig", "", "Path to bag validation config file (required with --validate)")
+	flag.BoolVar(&force, "force", false, "Upload the bag even if it's not valid")
 	flag.BoolVar(&help, "help", false, "Show help")
 	flag.BoolVar(&version, "version", false, "Show version")
 
@@ -140,6 +174,10 @@ func parseCommandLine() *common.Options {
 		ContentType:      contentType,
 		FileToUpload:     filePath,
 		OutputFormat:     outputFormat,
+
+		ValidateBag:             validate,
+		BagValidationConfigFile: validationConfig,
+		UploadInvalidBag:        force,
 	}
 
 	if os.Getenv("AWS_ACCESS_KEY_ID") != "" {
@@ -180,6 +218,7 @@ apt_upload --bucket=<bucket to upload to> \
 		   [--region=<aws region to connect to>] \
 		   [--key=<name/key of object to upload>] \
 		   [--metadata=<json string>] \
+		   [--validate --validationConfig=<path to config> [--force]] \
 		   <file>
 
 apt_upload --help
@@ -241,6 +280,19 @@ AWS credentials, the upload will fail.
               "Institution":"virginia.edu","Md5":"12345",
               "Sha256":"54321"}'
 
+--validate tells apt_upload to validate the bag before uploading it. If the
+  bag is not valid, apt_upload prints the validation errors and exits without
+  uploading, so you don't have to wait for ingest to reject the bag hours
+  later. This requires --validationConfig.
+
+--validationConfig is the path to the bag validation config file that
+  describes what a valid bag looks like. This is the same file that
+  apt_validate uses. An example is at
+  https://github.com/APTrust/exchange/blob/master/config/aptrust_bag_validation_config.json
+
+--force tells apt_upload to upload the bag even if --validate finds it
+  is not valid.
+
 --version prints version info and exits.
 
 --help prints this help message and exits.
@@ -263,10 +315,17 @@ Examples:
 
    apt_upload --bucket="my.custom.bucket" --key="MySpecialFile.tar" /home/joy/my_bag.tar
 
+4. Validate item "/home/joy/my_bag.tar" and upload it to your receiving
+   bucket only if it's valid
+
+   apt_upload --validate --validationConfig="/home/joy/aptrust_bag_validation_config.json" \
+              /home/joy/my_bag.tar
+
 Exit codes:
 
 0 - Item was successfully uploaded.
 1 - Upload failed.
+2 - Bag is not valid and was not uploaded. (--validate only)
 3 - Operation could not be completed due to usage error (e.g. missing params)
 4 - File does not exist.
 100 - Printed help or version message. No other operations attempted.
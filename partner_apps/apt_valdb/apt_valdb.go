@@ -0,0 +1,176 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"github.com/APTrust/exchange/partner_apps/common"
+	"github.com/APTrust/exchange/util/storage"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	command, format := parseCommandLine()
+	var err error
+	if command == "export" {
+		err = export(flag.Arg(1), flag.Arg(2), format)
+	} else {
+		err = importBundle(flag.Arg(1), flag.Arg(2))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(common.EXIT_RUNTIME_ERR)
+	}
+	os.Exit(common.EXIT_OK)
+}
+
+// export writes the contents of the .valdb file at pathToDB to
+// pathToBundle in the specified format. The output is gzipped if
+// pathToBundle ends with .gz.
+func export(pathToDB, pathToBundle, format string) error {
+	if _, err := os.Stat(pathToDB); err != nil {
+		return fmt.Errorf("Can't find validation db: %v", err)
+	}
+	db, err := storage.NewBoltDB(pathToDB)
+	if err != nil {
+		return fmt.Errorf("Can't open validation db: %v", err)
+	}
+	defer db.Close()
+	file, err := os.Create(pathToBundle)
+	if err != nil {
+		return fmt.Errorf("Can't create output file: %v", err)
+	}
+	defer file.Close()
+	var writer io.Writer = file
+	if strings.HasSuffix(pathToBundle, ".gz") {
+		gzWriter := gzip.NewWriter(file)
+		defer gzWriter.Close()
+		writer = gzWriter
+	}
+	if format == "csv" {
+		err = storage.ExportCSV(db, writer)
+	} else {
+		err = storage.ExportBundle(db, db.ObjectIdentifier(), writer)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d files from %s to %s\n", db.FileCount(), pathToDB, pathToBundle)
+	return nil
+}
+
+// importBundle reads the bundle at pathToBundle, which must have been
+// written by export in JSON format, into a new .valdb file at pathToDB.
+func importBundle(pathToBundle, pathToDB string) error {
+	if _, err := os.Stat(pathToDB); err == nil {
+		return fmt.Errorf("%s already exists. Will not overwrite it.", pathToDB)
+	}
+	file, err := os.Open(pathToBundle)
+	if err != nil {
+		return fmt.Errorf("Can't open bundle: %v", err)
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if strings.HasSuffix(pathToBundle, ".gz") {
+		gzReader, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("Can't read gzipped bundle: %v", err)
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	}
+	db, err := storage.NewBoltDB(pathToDB)
+	if err != nil {
+		return fmt.Errorf("Can't create validation db: %v", err)
+	}
+	defer db.Close()
+	objIdentifier, fileCount, err := storage.ImportBundle(reader, db)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported object %s with %d files into %s\n", objIdentifier, fileCount, pathToDB)
+	return nil
+}
+
+func parseCommandLine() (command, format string) {
+	var help bool
+	var version bool
+	flag.StringVar(&format, "format", "json", "Export format: json or csv")
+	flag.BoolVar(&help, "help", false, "Show help")
+	flag.BoolVar(&version, "version", false, "Show version")
+
+	flag.Parse()
+
+	if version {
+		fmt.Println(common.GetVersion())
+		os.Exit(common.EXIT_NO_OP)
+	}
+	command = flag.Arg(0)
+	if help || (command != "export" && command != "import") || flag.Arg(1) == "" || flag.Arg(2) == "" {
+		printUsage()
+		os.Exit(common.EXIT_USER_ERR)
+	}
+	if format != "json" && format != "csv" {
+		fmt.Fprintln(os.Stderr, "--format must be json or csv")
+		os.Exit(common.EXIT_USER_ERR)
+	}
+	if command == "import" && format != "json" {
+		fmt.Fprintln(os.Stderr, "Only json bundles can be imported")
+		os.Exit(common.EXIT_USER_ERR)
+	}
+	return command, format
+}
+
+// Tell the user about the program.
+func printUsage() {
+	message := `
+apt_valdb exports the validation database (.valdb file) that apt_validate
+creates for large bags to a portable bundle, and imports bundles back
+into .valdb files. If APTrust support asks you for help troubleshooting
+a validation problem, you can send them a bundle instead of the bag.
+
+Usage:
+
+apt_valdb [--format=<json|csv>] export path_to_valdb path_to_bundle
+apt_valdb import path_to_bundle path_to_valdb
+
+apt_valdb --help
+apt_valdb --version
+
+Options
+
+--format option is not required. It applies to export only. The default,
+json, writes one JSON record per line, describing the bag and each of its
+files. Only json bundles can be imported. The csv format writes a
+spreadsheet with one row per file, showing its type, size, and the
+checksums in the manifests alongside the checksums the validator
+calculated.
+
+--help prints this help message and exits.
+
+--version prints version info and exits.
+
+Arguments
+
+The export command reads path_to_valdb and writes path_to_bundle.
+The import command reads path_to_bundle and writes a new .valdb file
+to path_to_valdb, which must not already exist.
+
+If path_to_bundle ends with .gz, apt_valdb gzips the bundle on export
+and unzips it on import.
+
+To get a .valdb file, run apt_validate with --keepdb. The .valdb file
+is in the same directory as the bag.
+
+Exit codes:
+
+0 - Export or import succeeded
+1 - Export or import could not be completed, typically because of a
+	problem reading or writing one of the files.
+3 - Operation could not be completed due to usage error (e.g. missing params)
+
+`
+	fmt.Println(message)
+}
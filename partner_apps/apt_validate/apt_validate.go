@@ -9,10 +9,11 @@ import (
 	"github.com/APTrust/exchange/validation"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 func main() {
-	pathToConfigFile, pathToOutFile, preserveAttrs := parseCommandLine()
+	pathToConfigFile, pathToOutFile, preserveAttrs, workers, progress, memDBMaxFiles, keepDB, structureOnly := parseCommandLine()
 	configAbsPath, err := filepath.Abs(pathToConfigFile)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
@@ -33,27 +34,64 @@ func main() {
 		fmt.Fprintln(os.Stderr, "Error creating validator: ", err.Error())
 		os.Exit(common.EXIT_RUNTIME_ERR)
 	}
-	summary, err := validator.Validate()
+	validator.ChecksumWorkers = workers
+	if pathToOutFile == "" && !keepDB {
+		// We need the .valdb file to write the output file.
+		validator.MemoryDBMaxFiles = memDBMaxFiles
+	}
+	if progress {
+		validator.Progress = progressPrinter(10 * time.Second)
+	}
+	validate := validator.Validate
+	if structureOnly {
+		validate = validator.ValidateStructureOnly
+	}
+	summary, err := validate()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "The validator encountered an error: ", err.Error())
 		os.Exit(common.EXIT_RUNTIME_ERR)
 	}
+	for _, warning := range summary.WarningMessages() {
+		fmt.Fprintln(os.Stderr, "Warning:", warning)
+	}
 	exitCode := common.EXIT_OK
 	if summary.HasErrors() {
-		cleanup(validator.DBName())
 		fmt.Println("Bag is not valid")
 		fmt.Println(summary.AllErrorsAsString())
 		exitCode = common.EXIT_BAG_INVALID
+	} else if structureOnly {
+		fmt.Println("Bag structure is valid. Payload checksums were not checked.")
 	} else {
 		fmt.Println("Bag is valid")
 	}
 	if pathToOutFile != "" {
 		printOutput(validator, pathToOutFile)
 	}
-	cleanup(validator.DBName())
+	if keepDB {
+		fmt.Println("Validation db is at", validator.DBName())
+	} else {
+		cleanup(validator.DBName())
+	}
 	os.Exit(exitCode)
 }
 
+// progressPrinter returns a Progress callback for the validator that
+// prints progress to stderr when the validator starts a new phase, and
+// otherwise no more often than once per interval.
+func progressPrinter(interval time.Duration) func(validation.ValidationProgress) {
+	lastPhase := ""
+	lastPrinted := time.Time{}
+	return func(progress validation.ValidationProgress) {
+		if progress.Phase == lastPhase && time.Since(lastPrinted) < interval {
+			return
+		}
+		lastPhase = progress.Phase
+		lastPrinted = time.Now()
+		fmt.Fprintf(os.Stderr, "%s: %d files processed, %d bytes hashed\n",
+			progress.Phase, progress.FilesProcessed, progress.BytesHashed)
+	}
+}
+
 func printOutput(validator *validation.Validator, pathToOutFile string) {
 	file, err := os.Create(pathToOutFile)
 	if err != nil {
@@ -77,12 +115,18 @@ func cleanup(filePath string) {
 	}
 }
 
-func parseCommandLine() (pathToConfigFile, pathToOutFile string, preserveAttrs bool) {
+func parseCommandLine() (pathToConfigFile, pathToOutFile string, preserveAttrs bool, workers int, progress bool, memDBMaxFiles int, keepDB bool, structureOnly bool) {
 	var help bool
 	var version bool
 	flag.StringVar(&pathToConfigFile, "config", "", "Path to bag validation config file")
 	flag.StringVar(&pathToOutFile, "outfile", "", "Path to file for dumping JSON output")
 	flag.BoolVar(&preserveAttrs, "attrs", false, "Preserve attributes")
+	flag.IntVar(&workers, "workers", 1, "Number of files to checksum at once (untarred bags only)")
+	flag.BoolVar(&progress, "progress", false, "Print progress to stderr")
+	flag.BoolVar(&keepDB, "keepdb", false, "Keep the .valdb file after validation")
+	flag.BoolVar(&structureOnly, "structure-only", false, "Check bag structure and tags without hashing payload files")
+	flag.IntVar(&memDBMaxFiles, "memdb-max-files", validation.DEFAULT_MEMORY_DB_MAX_FILES,
+		"Keep validation data in memory for bags with up to this many files")
 	flag.BoolVar(&help, "help", false, "Show help")
 	flag.BoolVar(&version, "version", false, "Show version")
 
@@ -96,7 +140,7 @@ func parseCommandLine() (pathToConfigFile, pathToOutFile string, preserveAttrs b
 		printUsage()
 		os.Exit(common.EXIT_USER_ERR)
 	}
-	return pathToConfigFile, pathToOutFile, preserveAttrs
+	return pathToConfigFile, pathToOutFile, preserveAttrs, workers, progress, memDBMaxFiles, keepDB, structureOnly
 }
 
 // Tell the user about the program.
@@ -108,7 +152,12 @@ Usage:
 
 apt_validate --config=<config_file> \
              [--attrs=<true|false>] \
+             [--keepdb] \
+             [--memdb-max-files=<number>] \
              [--outfile=<path_to_output_file>] \
+             [--progress] \
+             [--structure-only] \
+             [--workers=<number>] \
              path_to_bag
 
 apt_validate --help
@@ -126,21 +175,55 @@ the validator.
 --config is required and should be the path to a bag validation
 config file that describes the validation rules. An example can be found at
 https://github.com/APTrust/exchange/blob/master/config/aptrust_bag_validation_config.json
-but the config file must exist on the local drive.
+but the config file must exist on the local drive. This may also be
+a standard BagIt Profile (see https://bagit-profiles.github.io), which
+apt_validate converts to a bag validation config.
 
 --help prints this help message and exits.
 
+--keepdb option is not required. If specified, the validator keeps its
+data in a database file on disk, and does not delete that file when it's
+done. The file is next to the bag, and its name ends with .valdb. You
+can export it with apt_valdb and send it to APTrust support if you need
+help figuring out why a bag is invalid.
+
+--memdb-max-files option is not required. The validator keeps data about
+bags with up to this many files in memory, which is faster than keeping
+it in a database file on disk. For larger bags, it uses a database file.
+The default is 1000. Set this to zero to always use a database file.
+This option has no effect when you specify --attrs=true, --keepdb
+or --outfile.
+
 --outfile option is not required. If specified, the validator will dump
 JSON information about the bag and its contents to this file. That info may be
 useful, especially when combined with --attrs=true, in cases where you're trying
 to debug your bagging process.
 
+--progress option is not required. If specified, the validator prints
+the number of files it has processed and bytes it has hashed to stderr
+every ten seconds. This is useful when validating very large bags,
+which can take hours.
+
+--structure-only option is not required. If specified, the validator
+checks the bag's structure, required files and tags, tag values, manifests
+and Payload-Oxum, but does not read or hash payload files. This takes
+seconds instead of hours for very large bags, and is a good check to run
+before full validation. A bag that passes this check may still have bad
+checksums.
+
 --version prints version info and exits.
 
+--workers option is not required. It sets the number of files whose
+checksums the validator calculates at once. The default is 1. Higher
+values can speed up validation of very large bags on fast disks. This
+applies only to untarred bags. Tarred bags are always read one file
+at a time.
+
 Arguments
 
 The path_to_bag parameter is required. It should be the absolute path
-to the directory containing the untarred bag, or to a tarred bag file.
+to the directory containing the untarred bag, or to a tarred bag file
+(.tar, or .tar.gz, .tgz or .zip if the config file accepts those).
 
 Exit codes:
 
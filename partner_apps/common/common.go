@@ -53,6 +53,10 @@ const (
 	// some versions of the bag have been ingested while others
 	// have not.
 	EXIT_SOME_INGESTED = 6
+	// EXIT_CHECKSUM_MISMATCH occurs when apt_download finishes
+	// a download, but the file's checksums don't match the ones
+	// the user or Pharos said to expect.
+	EXIT_CHECKSUM_MISMATCH = 7
 	// EXIT_NO_OP means the user requested help message or
 	// version info. The program printed the info, and no other
 	// operations were performed.
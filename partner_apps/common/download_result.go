@@ -25,6 +25,9 @@ type DownloadResult struct {
 	S3ServerSideEncryption string             `json:"s3_server_side_encryption,omitempty"`
 	S3StorageClass         string             `json:"s3_storage_class,omitempty"`
 	S3VersionId            string             `json:"s3_version_id,omitempty"`
+	ResumedFrom            int64              `json:"resumed_from,omitempty"`
+	ChecksumsVerified      bool               `json:"checksums_verified,omitempty"`
+	ChecksumMismatch       bool               `json:"checksum_mismatch,omitempty"`
 	ErrorMessage           string             `json:"error_message,omitempty"`
 }
 
@@ -37,6 +40,7 @@ func NewDownloadResult(opts *Options, client *network.S3Download) *DownloadResul
 		Md5:             client.Md5Digest,
 		Sha256:          client.Sha256Digest,
 		BytesDownloaded: client.BytesCopied,
+		ResumedFrom:     client.ResumedFrom,
 		ErrorMessage:    client.ErrorMessage,
 	}
 	if client.Response != nil {
@@ -67,6 +71,33 @@ func NewDownloadResult(opts *Options, client *network.S3Download) *DownloadResul
 	return result
 }
 
+// VerifyChecksums compares the digests of the downloaded file with
+// expectedMd5 and expectedSha256, skipping either one that's empty.
+// It sets ChecksumsVerified if all of the expected digests match,
+// and sets ChecksumMismatch and ErrorMessage if any don't. This does
+// nothing if the download failed.
+func (result *DownloadResult) VerifyChecksums(expectedMd5, expectedSha256 string) {
+	if result.ErrorMessage != "" || (expectedMd5 == "" && expectedSha256 == "") {
+		return
+	}
+	mismatches := make([]string, 0)
+	if expectedMd5 != "" && !strings.EqualFold(expectedMd5, result.Md5) {
+		mismatches = append(mismatches, fmt.Sprintf("md5 is %s, expected %s",
+			result.Md5, expectedMd5))
+	}
+	if expectedSha256 != "" && !strings.EqualFold(expectedSha256, result.Sha256) {
+		mismatches = append(mismatches, fmt.Sprintf("sha256 is %s, expected %s",
+			result.Sha256, expectedSha256))
+	}
+	if len(mismatches) > 0 {
+		result.ChecksumMismatch = true
+		result.ErrorMessage = fmt.Sprintf("Checksum mismatch: %s",
+			strings.Join(mismatches, "; "))
+		return
+	}
+	result.ChecksumsVerified = true
+}
+
 // ToJson returns a JSON representation of the result.
 // This contains more information than the plain text
 // version returned by ToText().
@@ -88,6 +119,12 @@ func (result *DownloadResult) ToText() string {
 	} else {
 		msg = fmt.Sprintf("[OK] Downloaded '%s' to '%s'. md5: %s, sha256: %s",
 			result.Key, result.SavedTo, result.Md5, result.Sha256)
+		if result.ResumedFrom > 0 {
+			msg += fmt.Sprintf(". Resumed at byte %d", result.ResumedFrom)
+		}
+		if result.ChecksumsVerified {
+			msg += ". Checksums verified"
+		}
 	}
 	return msg
 }
@@ -13,6 +13,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -95,3 +96,61 @@ func TestDownloadResultToText(t *testing.T) {
 	require.NotNil(t, result)
 	assert.Equal(t, "[OK] Downloaded 'TestKey' to '~/tmp/'. md5: 12345, sha256: 54321", result.ToText())
 }
+
+func TestDownloadResultVerifyChecksums(t *testing.T) {
+	opts := getOpts()
+	client, err := getDownloadClient()
+	require.Nil(t, err)
+
+	// Nothing to verify
+	result := common.NewDownloadResult(opts, client)
+	result.VerifyChecksums("", "")
+	assert.False(t, result.ChecksumsVerified)
+	assert.False(t, result.ChecksumMismatch)
+	assert.Empty(t, result.ErrorMessage)
+
+	// Matching digests, in any case
+	client.Md5Digest = "abcdef"
+	result = common.NewDownloadResult(opts, client)
+	result.VerifyChecksums("ABCDEF", "54321")
+	assert.True(t, result.ChecksumsVerified)
+	assert.False(t, result.ChecksumMismatch)
+	assert.Empty(t, result.ErrorMessage)
+	assert.Equal(t, "[OK] Downloaded 'TestKey' to '~/tmp/'. md5: abcdef, sha256: 54321. Checksums verified", result.ToText())
+
+	// Only sha256
+	result = common.NewDownloadResult(opts, client)
+	result.VerifyChecksums("", "54321")
+	assert.True(t, result.ChecksumsVerified)
+
+	// Mismatch
+	result = common.NewDownloadResult(opts, client)
+	result.VerifyChecksums("abcdef", "99999")
+	assert.False(t, result.ChecksumsVerified)
+	assert.True(t, result.ChecksumMismatch)
+	assert.Equal(t, "Checksum mismatch: sha256 is 54321, expected 99999", result.ErrorMessage)
+	assert.True(t, strings.HasPrefix(result.ToText(), "[ERROR]"))
+
+	// Failed downloads aren't verified
+	client.ErrorMessage = "Connection reset by peer"
+	result = common.NewDownloadResult(opts, client)
+	result.VerifyChecksums("abcdef", "54321")
+	assert.False(t, result.ChecksumsVerified)
+	assert.False(t, result.ChecksumMismatch)
+	assert.Equal(t, "Connection reset by peer", result.ErrorMessage)
+}
+
+func TestDownloadResultResumed(t *testing.T) {
+	opts := getOpts()
+	client, err := getDownloadClient()
+	require.Nil(t, err)
+	client.ResumedFrom = 800
+	client.BytesCopied = 1635
+	result := common.NewDownloadResult(opts, client)
+	assert.Equal(t, int64(800), result.ResumedFrom)
+	assert.Equal(t, int64(1635), result.BytesDownloaded)
+	assert.Equal(t, "[OK] Downloaded 'TestKey' to '~/tmp/'. md5: 12345, sha256: 54321. Resumed at byte 800", result.ToText())
+	jsonString, err := result.ToJson()
+	require.Nil(t, err)
+	assert.Contains(t, jsonString, `"resumed_from":800`)
+}
@@ -2,6 +2,7 @@ package common
 
 import (
 	"fmt"
+	"github.com/APTrust/exchange/util"
 	"github.com/APTrust/exchange/util/fileutil"
 	"github.com/APTrust/exchange/util/partner"
 	"os"
@@ -50,6 +51,22 @@ type Options struct {
 	// Dir is the directory into which the S3 object should be downloaded.
 	// This option is for downloads only.
 	Dir string
+	// Resume tells apt_download to continue an interrupted download
+	// of Key, keeping the bytes already in Dir, instead of starting
+	// over.
+	Resume bool
+	// Md5 is the md5 digest apt_download should expect the downloaded
+	// file to have. If this is empty, we don't check the md5.
+	Md5 string
+	// Sha256 is the sha256 digest apt_download should expect the
+	// downloaded file to have. If this is empty, we don't check the
+	// sha256.
+	Sha256 string
+	// FileIdentifier is the identifier of a GenericFile in Pharos.
+	// If this is set, apt_download checks the downloaded file against
+	// the latest md5 and sha256 digests Pharos has for that file.
+	// E.g. "virginia.edu/bag_name/data/file.pdf"
+	FileIdentifier string
 	// ContentType is the content type of the object being uploaded
 	// to S3. This option applies to uploads only, and can be left
 	// empty.
@@ -66,13 +83,34 @@ type Options struct {
 	// FileToUpload is the path the file that should be uploaded to S3.
 	// This is required for apt_upload only, and is ignored elsewhere.
 	FileToUpload string
+	// ValidateBag tells apt_upload to validate FileToUpload as a bag
+	// before uploading it.
+	ValidateBag bool
+	// BagValidationConfigFile is the path to the bag validation config
+	// file that describes what a valid bag looks like. This is required
+	// if ValidateBag is true.
+	BagValidationConfigFile string
+	// UploadInvalidBag tells apt_upload to upload the bag even if
+	// validation fails.
+	UploadInvalidBag bool
 	// PharosURL is the URL of the Pharos production or demo system.
 	PharosURL string
+	// Institution is the identifier of the institution whose records
+	// a Pharos query may see. E.g. "virginia.edu". If not specified,
+	// this is derived from the ReceivingBucket in the config file.
+	Institution string
 	// OutputFormat specifies how the program should print its results
 	// to STDOUT. Options are "text" and "json".
 	OutputFormat string
 	// Debug indicates whether we should print debug output to Stdout.
 	Debug bool
+	// Force tells apt_delete to delete from Bucket even if Bucket is
+	// not one of the buckets in the partner config file.
+	Force bool
+	// ReceivingBucket is the receiving bucket from the partner config
+	// file. apt_delete deletes only from this bucket, unless Force is
+	// true.
+	ReceivingBucket string
 	// error contains a list of errors describing why these options are
 	// not valid for an operation like upload or download.
 	errors []string
@@ -142,6 +180,12 @@ func (opts *Options) VerifyRequiredDownloadOptions() {
 	if opts.SecretAccessKey == "" {
 		opts.addError("Cannot find AWS_SECRET_ACCESS_KEY in environment or config file")
 	}
+	if opts.FileIdentifier != "" {
+		if opts.Md5 != "" || opts.Sha256 != "" {
+			opts.addError("Params -md5 and -sha256 cannot be used with -pharosFile")
+		}
+		opts.VerifyRequiredAPICredentials()
+	}
 }
 
 // VerifyRequiredUploadOptions checks to see that all
@@ -159,6 +203,9 @@ func (opts *Options) VerifyRequiredUploadOptions() {
 	if opts.FileToUpload == "" {
 		opts.addError("You must specify a file to upload")
 	}
+	if opts.ValidateBag && opts.BagValidationConfigFile == "" {
+		opts.addError("Param -validationConfig must be specified when using -validate")
+	}
 }
 
 // VerifyRequiredListOptions checks to see that all
@@ -179,10 +226,17 @@ func (opts *Options) VerifyRequiredListOptions() {
 }
 
 // VerifyRequiredDeleteOptions checks to see that all
-// required S3 delete options are set.
+// required S3 delete options are set, and that Bucket is the
+// ReceivingBucket, unless Force is true. That keeps users from
+// accidentally deleting from restoration or preservation buckets,
+// or other buckets that aren't theirs to clean up.
 func (opts *Options) VerifyRequiredDeleteOptions() {
 	if opts.Bucket == "" {
 		opts.addError("Param -bucket must be specified on the command line or in the config file")
+	} else if !opts.Force && opts.Bucket != opts.ReceivingBucket {
+		opts.addError(fmt.Sprintf("Refusing to delete from bucket '%s', which is not the "+
+			"ReceivingBucket in your config file. "+
+			"Use -force to delete from it anyway.", opts.Bucket))
 	}
 	if opts.AccessKeyId == "" {
 		opts.addError("Cannot find AWS_ACCESS_KEY_ID in environment or config file")
@@ -254,6 +308,12 @@ func (opts *Options) MergeConfigFileOptions(action string) {
 	if action == "upload" && opts.Bucket == "" && partnerConfig.ReceivingBucket != "" {
 		opts.Bucket = partnerConfig.ReceivingBucket
 	}
+	if action == "delete" {
+		opts.ReceivingBucket = partnerConfig.ReceivingBucket
+	}
+	if action == "check_ingest" && opts.Institution == "" && partnerConfig.ReceivingBucket != "" {
+		opts.Institution = util.OwnerOf(partnerConfig.ReceivingBucket)
+	}
 	if opts.Dir == "" && partnerConfig.DownloadDir != "" {
 		opts.Dir = partnerConfig.DownloadDir
 	}
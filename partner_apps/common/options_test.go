@@ -148,6 +148,23 @@ func TestVerifyRequiredDownloadOptions(t *testing.T) {
 	opts.ClearErrors()
 	opts.VerifyRequiredDownloadOptions()
 	assert.Empty(t, opts.Errors())
+
+	// Verifying against Pharos requires API credentials, and
+	// excludes user-supplied digests.
+	opts.FileIdentifier = "test.edu/bag/data/file.txt"
+	opts.Md5 = "12345"
+	opts.APTrustAPIUser = ""
+	opts.APTrustAPIKey = ""
+	opts.ClearErrors()
+	opts.VerifyRequiredDownloadOptions()
+	assert.Equal(t, 3, len(opts.Errors()))
+
+	opts.Md5 = ""
+	opts.APTrustAPIUser = "user@test.edu"
+	opts.APTrustAPIKey = "secret"
+	opts.ClearErrors()
+	opts.VerifyRequiredDownloadOptions()
+	assert.Empty(t, opts.Errors())
 }
 
 func TestVerifyRequiredUploadOptions(t *testing.T) {
@@ -167,6 +184,17 @@ func TestVerifyRequiredUploadOptions(t *testing.T) {
 	opts.ClearErrors()
 	opts.VerifyRequiredUploadOptions()
 	assert.Empty(t, opts.Errors())
+
+	opts.ValidateBag = true
+	opts.ClearErrors()
+	opts.VerifyRequiredUploadOptions()
+	require.Equal(t, 1, len(opts.Errors()))
+	assert.Equal(t, "Param -validationConfig must be specified when using -validate", opts.Errors()[0])
+
+	opts.BagValidationConfigFile = "/home/joy/aptrust_bag_validation_config.json"
+	opts.ClearErrors()
+	opts.VerifyRequiredUploadOptions()
+	assert.Empty(t, opts.Errors())
 }
 
 func TestVerifyRequiredListOptions(t *testing.T) {
@@ -201,9 +229,27 @@ func TestVerifyRequiredDeleteOptions(t *testing.T) {
 	filePath, err := getConfigFilePath()
 	require.Nil(t, err)
 	opts.PathToConfigFile = filePath
-	opts.Bucket = "Monsieur Creosote"
+	opts.Bucket = "aptrust.receiving.testbucket.edu"
 	opts.ClearErrors()
 	opts.MergeConfigFileOptions("delete")
+	assert.Equal(t, "aptrust.receiving.testbucket.edu", opts.ReceivingBucket)
+	opts.VerifyRequiredDeleteOptions()
+	assert.Empty(t, opts.Errors())
+
+	// Buckets other than the receiving bucket require -force,
+	// even the restoration bucket.
+	for _, bucket := range []string{"aptrust.restore.testbucket.edu", "aptrust.preservation.storage"} {
+		opts.Bucket = bucket
+		opts.ClearErrors()
+		opts.VerifyRequiredDeleteOptions()
+		require.Equal(t, 1, len(opts.Errors()))
+		assert.Equal(t, "Refusing to delete from bucket '"+bucket+"', which is "+
+			"not the ReceivingBucket in your config file. "+
+			"Use -force to delete from it anyway.", opts.Errors()[0])
+	}
+
+	opts.Force = true
+	opts.ClearErrors()
 	opts.VerifyRequiredDeleteOptions()
 	assert.Empty(t, opts.Errors())
 }
@@ -253,6 +299,21 @@ func TestMergeConfigFileOptions(t *testing.T) {
 	assert.Equal(t, conf.DownloadDir, opts.Dir)
 	assert.Equal(t, conf.AwsAccessKeyId, opts.AccessKeyId)
 	assert.Equal(t, conf.AwsSecretAccessKey, opts.SecretAccessKey)
+	assert.Empty(t, opts.Institution)
+
+	// apt_check_ingest gets its institution from the receiving bucket.
+	opts = &common.Options{
+		PathToConfigFile: filePath,
+	}
+	opts.MergeConfigFileOptions("check_ingest")
+	assert.Equal(t, "testbucket.edu", opts.Institution)
+
+	opts = &common.Options{
+		PathToConfigFile: filePath,
+		Institution:      "example.edu",
+	}
+	opts.MergeConfigFileOptions("check_ingest")
+	assert.Equal(t, "example.edu", opts.Institution)
 }
 
 func TestLoadConfigFile(t *testing.T) {
@@ -0,0 +1,36 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadKeys reads S3 keys from reader, one per line. It skips blank
+// lines and lines that begin with #. This is for tools like
+// apt_delete, which can take a list of keys from a file or STDIN.
+//
+// Keys are used exactly as written, so ReadKeys returns an error if
+// a key begins or ends with whitespace, rather than guess whether the
+// whitespace is part of the key. It does strip the carriage return
+// from lines that end with CRLF.
+func ReadKeys(reader io.Reader) ([]string, error) {
+	keys := make([]string, 0)
+	scanner := bufio.NewScanner(reader)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum += 1
+		key := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(key) == "" || strings.HasPrefix(key, "#") {
+			continue
+		}
+		if key != strings.TrimSpace(key) {
+			return nil, fmt.Errorf("Key %q on line %d begins or ends with "+
+				"whitespace. Remove the whitespace if it's not part of the key.",
+				key, lineNum)
+		}
+		keys = append(keys, key)
+	}
+	return keys, scanner.Err()
+}
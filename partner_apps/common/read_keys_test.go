@@ -0,0 +1,30 @@
+package common_test
+
+import (
+	"github.com/APTrust/exchange/partner_apps/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestReadKeys(t *testing.T) {
+	input := "# Bags to delete\nbag1.tar\n\n   \nbag2.tar\r\nsub/dir/bag 3.tar\n"
+	keys, err := common.ReadKeys(strings.NewReader(input))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"bag1.tar", "bag2.tar", "sub/dir/bag 3.tar"}, keys)
+
+	keys, err = common.ReadKeys(strings.NewReader(""))
+	require.Nil(t, err)
+	assert.Empty(t, keys)
+
+	// Keys with leading or trailing whitespace are rejected,
+	// not trimmed, since the whitespace may be part of the key.
+	keys, err = common.ReadKeys(strings.NewReader("bag1.tar\n  bag2.tar  \n"))
+	require.NotNil(t, err)
+	assert.Nil(t, keys)
+	assert.Equal(t, `Key "  bag2.tar  " on line 2 begins or ends with whitespace. `+
+		"Remove the whitespace if it's not part of the key.", err.Error())
+	_, err = common.ReadKeys(strings.NewReader("bag1.tar\t\n"))
+	assert.NotNil(t, err)
+}
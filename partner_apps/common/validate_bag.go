@@ -0,0 +1,34 @@
+package common
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util/fileutil"
+	"github.com/APTrust/exchange/validation"
+	"os"
+)
+
+// ValidateBag validates the bag at pathToBag against the bag validation
+// config at pathToConfigFile, and returns the validator's WorkSummary.
+// Check summary.HasErrors() to see whether the bag is valid. This returns
+// an error only if it could not run the validator. It removes the
+// validation database when it's done.
+func ValidateBag(pathToBag, pathToConfigFile string) (*models.WorkSummary, error) {
+	conf, errors := validation.LoadBagValidationConfig(pathToConfigFile)
+	if errors != nil && len(errors) > 0 {
+		return nil, fmt.Errorf("Could not load bag validation config: %v", errors[0])
+	}
+	validator, err := validation.NewValidator(pathToBag, conf, false)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating validator: %v", err)
+	}
+	validator.MemoryDBMaxFiles = validation.DEFAULT_MEMORY_DB_MAX_FILES
+	summary, err := validator.Validate()
+	if fileutil.LooksSafeToDelete(validator.DBName(), 12, 3) {
+		os.Remove(validator.DBName())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("The validator encountered an error: %v", err)
+	}
+	return summary, nil
+}
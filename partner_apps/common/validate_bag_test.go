@@ -0,0 +1,39 @@
+package common_test
+
+import (
+	"github.com/APTrust/exchange/partner_apps/common"
+	"github.com/APTrust/exchange/util/fileutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func getBagPaths(t *testing.T, bagName string) (string, string) {
+	pathToBag, err := fileutil.RelativeToAbsPath(filepath.Join("testdata", "unit_test_bags", bagName))
+	require.Nil(t, err)
+	pathToConfig, err := fileutil.RelativeToAbsPath(filepath.Join("config", "aptrust_bag_validation_config.json"))
+	require.Nil(t, err)
+	return pathToBag, pathToConfig
+}
+
+func TestValidateBag(t *testing.T) {
+	pathToBag, pathToConfig := getBagPaths(t, "example.edu.tagsample_good.tar")
+	summary, err := common.ValidateBag(pathToBag, pathToConfig)
+	require.Nil(t, err)
+	require.NotNil(t, summary)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+	assert.False(t, fileutil.FileExists(pathToBag[0:len(pathToBag)-4]+".valdb"))
+
+	pathToBag, pathToConfig = getBagPaths(t, "example.edu.sample_bad_checksums.tar")
+	summary, err = common.ValidateBag(pathToBag, pathToConfig)
+	require.Nil(t, err)
+	require.NotNil(t, summary)
+	assert.True(t, summary.HasErrors())
+}
+
+func TestValidateBagBadConfig(t *testing.T) {
+	pathToBag, _ := getBagPaths(t, "example.edu.tagsample_good.tar")
+	_, err := common.ValidateBag(pathToBag, "/no/such/config.json")
+	assert.NotNil(t, err)
+}
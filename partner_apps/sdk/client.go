@@ -0,0 +1,307 @@
+// Package sdk lets APTrust depositors validate, upload, restore and
+// check the status of bags from their own Go programs, without
+// shelling out to the partner apps. Everything a Client needs comes
+// from its Config, so a program can create as many independent
+// clients as it likes.
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"github.com/APTrust/exchange/partner_apps/common"
+	"github.com/APTrust/exchange/util"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// Operations describes what a depositor can do with APTrust. Client
+// implements it. Code that embeds APTrust operations should depend on
+// this interface, so it can substitute a fake in its own tests.
+type Operations interface {
+	ValidateBag(pathToBag string) (*models.WorkSummary, error)
+	Upload(pathToFile string, metadata map[string]string) (*common.UploadResult, error)
+	RequestObjectRestore(objIdentifier string) (*models.WorkItem, error)
+	RequestFileRestore(gfIdentifier string) (*models.WorkItem, error)
+	IngestStatus(bagName, etag string) ([]*models.WorkItem, error)
+	WorkItemStatus(workItemId int) (*models.WorkItem, error)
+	RestoreReceipt(key string) (*models.RestoreReceipt, error)
+}
+
+// Config holds the settings for a Client. Settings that an operation
+// does not use may be left empty. See the doc on each operation.
+type Config struct {
+	// AwsAccessKeyId and AwsSecretAccessKey are the credentials
+	// for uploading to the receiving bucket.
+	AwsAccessKeyId     string
+	AwsSecretAccessKey string
+	// Region is the AWS region of the receiving bucket. It defaults
+	// to us-east-1.
+	Region string
+	// ReceivingBucket is the bucket to which Upload sends bags.
+	ReceivingBucket string
+	// RestorationBucket is the bucket to which APTrust restores bags.
+	RestorationBucket string
+	// PharosURL is the URL of the Pharos production or demo system.
+	PharosURL string
+	// APTrustAPIUser and APTrustAPIKey are the credentials for the
+	// Pharos REST API.
+	APTrustAPIUser string
+	APTrustAPIKey  string
+	// Institution is the identifier of the depositor's institution,
+	// e.g. "virginia.edu". If empty, it is derived from ReceivingBucket.
+	// The Client can see only this institution's Pharos records.
+	Institution string
+	// BagValidationConfigFile is the path to the bag validation
+	// config file that describes what a valid bag looks like.
+	BagValidationConfigFile string
+}
+
+// NewConfigFromPartnerConfig returns a Config with the settings from
+// an APTrust partner config file, such as the partner apps use.
+// Param pharosURL is the URL of the Pharos system to talk to. Call
+// partnerConfig.LoadAwsFromEnv() first if the AWS keys may come
+// from the environment.
+func NewConfigFromPartnerConfig(partnerConfig *common.PartnerConfig, pharosURL string) *Config {
+	return &Config{
+		AwsAccessKeyId:     partnerConfig.AwsAccessKeyId,
+		AwsSecretAccessKey: partnerConfig.AwsSecretAccessKey,
+		Region:             constants.AWSVirginia,
+		ReceivingBucket:    partnerConfig.ReceivingBucket,
+		RestorationBucket:  partnerConfig.RestorationBucket,
+		PharosURL:          pharosURL,
+		APTrustAPIUser:     partnerConfig.APTrustAPIUser,
+		APTrustAPIKey:      partnerConfig.APTrustAPIKey,
+	}
+}
+
+// Client performs APTrust operations for a single depositor.
+// It is safe for concurrent use.
+type Client struct {
+	config       Config
+	pharosClient *network.PharosClient
+}
+
+// NewClient returns a new Client with a copy of config. It returns an
+// error if PharosURL is set but the client cannot talk to Pharos.
+func NewClient(config *Config) (*Client, error) {
+	if config == nil {
+		return nil, fmt.Errorf("Param config cannot be nil")
+	}
+	client := &Client{config: *config}
+	if client.config.Region == "" {
+		client.config.Region = constants.AWSVirginia
+	}
+	if client.config.Institution == "" && client.config.ReceivingBucket != "" {
+		client.config.Institution = util.OwnerOf(client.config.ReceivingBucket)
+	}
+	if client.config.PharosURL != "" {
+		var err error
+		if client.config.Institution != "" {
+			client.pharosClient, err = network.NewInstitutionScopedPharosClient(
+				client.config.PharosURL, common.PharosAPIVersion,
+				client.config.APTrustAPIUser, client.config.APTrustAPIKey,
+				client.config.Institution)
+		} else {
+			client.pharosClient, err = network.NewPharosClient(
+				client.config.PharosURL, common.PharosAPIVersion,
+				client.config.APTrustAPIUser, client.config.APTrustAPIKey)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Cannot create Pharos client: %v", err)
+		}
+	}
+	return client, nil
+}
+
+// Config returns a copy of the client's settings.
+func (client *Client) Config() Config {
+	return client.config
+}
+
+// ValidateBag validates the bag at pathToBag against the config in
+// BagValidationConfigFile. Check summary.HasErrors() to see whether
+// the bag is valid. This returns an error only if it could not run
+// the validator.
+func (client *Client) ValidateBag(pathToBag string) (*models.WorkSummary, error) {
+	if client.config.BagValidationConfigFile == "" {
+		return nil, fmt.Errorf("Config setting BagValidationConfigFile is missing.")
+	}
+	return common.ValidateBag(pathToBag, client.config.BagValidationConfigFile)
+}
+
+// Upload copies the file at pathToFile into the receiving bucket,
+// under its base name. Param metadata is optional metadata to store
+// with the S3 object. This returns an error if the upload failed. The
+// result is non-nil whenever the upload was attempted, and includes
+// the ETag that IngestStatus uses.
+func (client *Client) Upload(pathToFile string, metadata map[string]string) (*common.UploadResult, error) {
+	if client.config.ReceivingBucket == "" {
+		return nil, fmt.Errorf("Config setting ReceivingBucket is missing.")
+	}
+	if client.config.AwsAccessKeyId == "" || client.config.AwsSecretAccessKey == "" {
+		return nil, fmt.Errorf("Config settings AwsAccessKeyId and AwsSecretAccessKey are required for upload.")
+	}
+	filestat, err := os.Stat(pathToFile)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(pathToFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	opts := &common.Options{
+		AccessKeyId:     client.config.AwsAccessKeyId,
+		SecretAccessKey: client.config.AwsSecretAccessKey,
+		Region:          client.config.Region,
+		Bucket:          client.config.ReceivingBucket,
+		Key:             path.Base(pathToFile),
+		FileToUpload:    pathToFile,
+	}
+	uploadClient := network.NewS3Upload(opts.AccessKeyId, opts.SecretAccessKey,
+		opts.Region, opts.Bucket, opts.Key, "")
+	for key, value := range metadata {
+		uploadClient.AddMetadata(strings.ToLower(key), value)
+	}
+	uploadClient.Send(file)
+	headClient := network.NewS3Head(opts.AccessKeyId, opts.SecretAccessKey,
+		opts.Region, opts.Bucket)
+	headClient.Head(opts.Key)
+	result := common.NewUploadResult(opts, uploadClient, headClient, filestat.Size())
+	if result.ErrorMessage != "" {
+		return result, fmt.Errorf("Upload of %s failed: %s", pathToFile, result.ErrorMessage)
+	}
+	return result, nil
+}
+
+// RequestObjectRestore asks APTrust to restore the intellectual object
+// with the specified identifier to the restoration bucket, and returns
+// the WorkItem that tracks the restoration.
+func (client *Client) RequestObjectRestore(objIdentifier string) (*models.WorkItem, error) {
+	pharosClient, err := client.pharos()
+	if err != nil {
+		return nil, err
+	}
+	resp := pharosClient.IntellectualObjectRequestRestore(objIdentifier)
+	if resp.Error != nil {
+		return nil, fmt.Errorf("Restore request for %s failed: %v", objIdentifier, resp.Error)
+	}
+	return resp.WorkItem(), nil
+}
+
+// RequestFileRestore asks APTrust to restore the generic file with
+// the specified identifier to the restoration bucket, and returns
+// the WorkItem that tracks the restoration.
+func (client *Client) RequestFileRestore(gfIdentifier string) (*models.WorkItem, error) {
+	pharosClient, err := client.pharos()
+	if err != nil {
+		return nil, err
+	}
+	resp := pharosClient.GenericFileRequestRestore(gfIdentifier)
+	if resp.Error != nil {
+		return nil, fmt.Errorf("Restore request for %s failed: %v", gfIdentifier, resp.Error)
+	}
+	return resp.WorkItem(), nil
+}
+
+// IngestStatus returns the ingest WorkItems for the bag with the
+// specified name (e.g. "my_bag.tar"), oldest first. Param etag is
+// optional. If it's set, this returns only the WorkItems for that
+// upload of the bag. Use Ingested to see whether a WorkItem
+// finished ingest.
+func (client *Client) IngestStatus(bagName, etag string) ([]*models.WorkItem, error) {
+	pharosClient, err := client.pharos()
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("name", bagName)
+	params.Set("item_action", constants.ActionIngest)
+	params.Set("sort", "date")
+	if etag != "" {
+		params.Set("etag", etag)
+	}
+	params.Set("page", "1")
+	params.Set("per_page", "100")
+	items := make([]*models.WorkItem, 0)
+	for {
+		resp := pharosClient.WorkItemList(params)
+		if resp.Error != nil {
+			return nil, fmt.Errorf("Cannot get ingest status of %s: %v", bagName, resp.Error)
+		}
+		items = append(items, resp.WorkItems()...)
+		if resp.HasNextPage() == false {
+			break
+		}
+		params = resp.ParamsForNextPage()
+	}
+	return items, nil
+}
+
+// WorkItemStatus returns the WorkItem with the specified id, such as
+// the one RequestObjectRestore returned.
+func (client *Client) WorkItemStatus(workItemId int) (*models.WorkItem, error) {
+	pharosClient, err := client.pharos()
+	if err != nil {
+		return nil, err
+	}
+	resp := pharosClient.WorkItemGet(workItemId)
+	if resp.Error != nil {
+		return nil, fmt.Errorf("Cannot get WorkItem %d: %v", workItemId, resp.Error)
+	}
+	return resp.WorkItem(), nil
+}
+
+// RestoreReceipt returns the receipt APTrust wrote when it restored
+// the bag with the specified key (e.g. "my_bag.tar") to the
+// restoration bucket. The receipt records the size and checksums
+// of the bag APTrust delivered.
+func (client *Client) RestoreReceipt(key string) (*models.RestoreReceipt, error) {
+	if client.config.RestorationBucket == "" {
+		return nil, fmt.Errorf("Config setting RestorationBucket is missing.")
+	}
+	tempFile, err := ioutil.TempFile("", "aptrust_receipt")
+	if err != nil {
+		return nil, err
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+	download := network.NewS3Download(client.config.AwsAccessKeyId,
+		client.config.AwsSecretAccessKey, client.config.Region,
+		client.config.RestorationBucket, key+models.RestoreReceiptSuffix,
+		tempFile.Name(), false, false)
+	download.Fetch()
+	if download.ErrorMessage != "" {
+		return nil, fmt.Errorf("Cannot get receipt for %s: %s", key, download.ErrorMessage)
+	}
+	data, err := ioutil.ReadFile(tempFile.Name())
+	if err != nil {
+		return nil, err
+	}
+	receipt := &models.RestoreReceipt{}
+	err = json.Unmarshal(data, receipt)
+	if err != nil {
+		return nil, fmt.Errorf("Receipt for %s is not valid JSON: %v", key, err)
+	}
+	return receipt, nil
+}
+
+// Ingested returns true if workItem describes a completed ingest.
+func Ingested(workItem *models.WorkItem) bool {
+	return (workItem.Action == constants.ActionIngest &&
+		workItem.Stage == constants.StageCleanup &&
+		workItem.Status == constants.StatusSuccess)
+}
+
+func (client *Client) pharos() (*network.PharosClient, error) {
+	if client.pharosClient == nil {
+		return nil, fmt.Errorf("Config setting PharosURL is missing.")
+	}
+	return client.pharosClient, nil
+}
@@ -0,0 +1,166 @@
+package sdk_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/partner_apps/common"
+	"github.com/APTrust/exchange/partner_apps/sdk"
+	"github.com/APTrust/exchange/util/fileutil"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// Make sure Client implements Operations.
+var _ sdk.Operations = (*sdk.Client)(nil)
+
+func workItemHandler(w http.ResponseWriter, r *http.Request) {
+	workItem := testutil.MakeWorkItem()
+	workItem.Id = 42
+	workItem.Action = constants.ActionRestore
+	workItem.ObjectIdentifier = "test.edu/bag"
+	data, _ := json.Marshal(workItem)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, string(data))
+}
+
+func workItemListHandler(w http.ResponseWriter, r *http.Request) {
+	list := make([]*models.WorkItem, 2)
+	for i := range list {
+		list[i] = testutil.MakeWorkItem()
+		list[i].Name = r.URL.Query().Get("name")
+		list[i].Action = r.URL.Query().Get("item_action")
+	}
+	data := map[string]interface{}{
+		"count":    2,
+		"next":     nil,
+		"previous": nil,
+		"results":  list,
+	}
+	listJson, _ := json.Marshal(data)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, string(listJson))
+}
+
+func newClient(t *testing.T, handler http.HandlerFunc) (*sdk.Client, *httptest.Server) {
+	testServer := httptest.NewServer(handler)
+	client, err := sdk.NewClient(&sdk.Config{
+		ReceivingBucket: "aptrust.receiving.test.edu",
+		PharosURL:       testServer.URL,
+		APTrustAPIUser:  "user@test.edu",
+		APTrustAPIKey:   "secret",
+	})
+	require.Nil(t, err)
+	return client, testServer
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := sdk.NewClient(nil)
+	assert.NotNil(t, err)
+
+	client, err := sdk.NewClient(&sdk.Config{ReceivingBucket: "aptrust.receiving.test.edu"})
+	require.Nil(t, err)
+	config := client.Config()
+	assert.Equal(t, "test.edu", config.Institution)
+	assert.Equal(t, constants.AWSVirginia, config.Region)
+
+	// Operations that need settings we don't have return errors.
+	_, err = client.ValidateBag("bag.tar")
+	assert.NotNil(t, err)
+	_, err = client.Upload("bag.tar", nil)
+	assert.NotNil(t, err)
+	_, err = client.IngestStatus("bag.tar", "")
+	assert.NotNil(t, err)
+	_, err = client.RequestObjectRestore("test.edu/bag")
+	assert.NotNil(t, err)
+	_, err = client.RestoreReceipt("bag.tar")
+	assert.NotNil(t, err)
+}
+
+func TestNewConfigFromPartnerConfig(t *testing.T) {
+	partnerConfig := &common.PartnerConfig{
+		AwsAccessKeyId:     "key",
+		AwsSecretAccessKey: "secret",
+		ReceivingBucket:    "aptrust.receiving.test.edu",
+		RestorationBucket:  "aptrust.restore.test.edu",
+		APTrustAPIUser:     "user@test.edu",
+		APTrustAPIKey:      "api-key",
+	}
+	config := sdk.NewConfigFromPartnerConfig(partnerConfig, "https://example.com")
+	assert.Equal(t, "key", config.AwsAccessKeyId)
+	assert.Equal(t, "secret", config.AwsSecretAccessKey)
+	assert.Equal(t, "aptrust.receiving.test.edu", config.ReceivingBucket)
+	assert.Equal(t, "aptrust.restore.test.edu", config.RestorationBucket)
+	assert.Equal(t, "https://example.com", config.PharosURL)
+	assert.Equal(t, "user@test.edu", config.APTrustAPIUser)
+	assert.Equal(t, "api-key", config.APTrustAPIKey)
+}
+
+func TestClientValidateBag(t *testing.T) {
+	pathToBag, err := fileutil.RelativeToAbsPath(filepath.Join("testdata", "unit_test_bags", "example.edu.tagsample_good.tar"))
+	require.Nil(t, err)
+	pathToConfig, err := fileutil.RelativeToAbsPath(filepath.Join("config", "aptrust_bag_validation_config.json"))
+	require.Nil(t, err)
+	client, err := sdk.NewClient(&sdk.Config{BagValidationConfigFile: pathToConfig})
+	require.Nil(t, err)
+	summary, err := client.ValidateBag(pathToBag)
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+}
+
+func TestClientRequestRestore(t *testing.T) {
+	client, testServer := newClient(t, workItemHandler)
+	defer testServer.Close()
+	workItem, err := client.RequestObjectRestore("test.edu/bag")
+	require.Nil(t, err)
+	require.NotNil(t, workItem)
+	assert.Equal(t, 42, workItem.Id)
+
+	workItem, err = client.RequestFileRestore("test.edu/bag/data/file.txt")
+	require.Nil(t, err)
+	require.NotNil(t, workItem)
+	assert.Equal(t, constants.ActionRestore, workItem.Action)
+
+	// Client is scoped to its own institution.
+	_, err = client.RequestObjectRestore("other.edu/bag")
+	assert.NotNil(t, err)
+}
+
+func TestClientIngestStatus(t *testing.T) {
+	client, testServer := newClient(t, workItemListHandler)
+	defer testServer.Close()
+	items, err := client.IngestStatus("bag.tar", "1234")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(items))
+	assert.Equal(t, "bag.tar", items[0].Name)
+	assert.Equal(t, constants.ActionIngest, items[0].Action)
+}
+
+func TestClientWorkItemStatus(t *testing.T) {
+	client, testServer := newClient(t, workItemHandler)
+	defer testServer.Close()
+	workItem, err := client.WorkItemStatus(42)
+	require.Nil(t, err)
+	require.NotNil(t, workItem)
+	assert.Equal(t, 42, workItem.Id)
+}
+
+func TestIngested(t *testing.T) {
+	workItem := &models.WorkItem{
+		Action: constants.ActionIngest,
+		Stage:  constants.StageCleanup,
+		Status: constants.StatusSuccess,
+	}
+	assert.True(t, sdk.Ingested(workItem))
+	workItem.Stage = constants.StageStore
+	assert.False(t, sdk.Ingested(workItem))
+	workItem.Stage = constants.StageCleanup
+	workItem.Action = constants.ActionRestore
+	assert.False(t, sdk.Ingested(workItem))
+}
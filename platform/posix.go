@@ -30,6 +30,18 @@ func FileOwnerAndGroup(finfo os.FileInfo) (uid int, gid int) {
 	return uid, gid
 }
 
+// IsSparseFile returns true if the file described by finfo takes up
+// less space on disk than its size, which means it has holes. Note that
+// file systems that compress data, such as ZFS, may make files look
+// sparse when they're not.
+func IsSparseFile(finfo os.FileInfo) bool {
+	systat, ok := finfo.Sys().(*syscall.Stat_t)
+	if !ok || systat == nil || !finfo.Mode().IsRegular() {
+		return false
+	}
+	return int64(systat.Blocks)*512 < finfo.Size()
+}
+
 // On Linux and OSX, this uses df in a safe way (without passing
 // through any user-supplied input) to find the mountpoint of a
 // given file.
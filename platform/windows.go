@@ -21,6 +21,12 @@ func FileOwnerAndGroup(finfo os.FileInfo) (uid int, gid int) {
 	return uid, gid // will be 0,0
 }
 
+// Windows does not give us the block count we need to detect
+// sparse files, so this always returns false.
+func IsSparseFile(finfo os.FileInfo) bool {
+	return false
+}
+
 // Don't even try. This function is for use with the VolumeService,
 // which we're not going to run on Windows.
 func GetMountPointFromPath(path string) (string, error) {
@@ -18,6 +18,7 @@ EMAIL = "help@aptrust.org"
         'apt_download',
         'apt_list',
         'apt_upload',
+        'apt_valdb',
         'apt_validate']
 
 def run()
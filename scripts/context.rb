@@ -30,8 +30,10 @@ class Context
 	@apps = {
 	  'apt_audit_list' => App.new('apt_audit_list', 'application'),
 	  'apt_bucket_reader' => App.new('apt_bucket_reader', 'application'),
+	  'apt_checksum_audit' => App.new('apt_checksum_audit', 'application'),
       'apt_dump_files' => App.new('apt_dump_files', 'application'),
       'apt_dump_valdb' => App.new('apt_dump_valdb', 'application'),
+	  'apt_export' => App.new('apt_export', 'application'),
 	  'apt_fetch' => App.new('apt_fetch', 'service'),
 	  'apt_file_delete' => App.new('apt_file_delete', 'service'),
 	  'apt_file_restore' => App.new('apt_file_restore', 'service'),
@@ -41,9 +43,12 @@ class Context
 	  'apt_queue' => App.new('apt_queue', 'application'),
 	  'apt_queue_fixity' => App.new('apt_queue_fixity', 'application'),
 	  'apt_record' => App.new('apt_record', 'service'),
+	  'apt_reconcile' => App.new('apt_reconcile', 'application'),
 	  'apt_restore' => App.new('apt_restore', 'service'),
 	  'apt_restore_from_glacier' => App.new('apt_restore_from_glacier', 'application'),
 	  'apt_spot_test_restore' => App.new('apt_spot_test_restore', 'application'),
+	  'apt_starvation_monitor' => App.new('apt_starvation_monitor', 'application'),
+	  'apt_storage_key_audit' => App.new('apt_storage_key_audit', 'application'),
 	  'apt_store' => App.new('apt_store', 'service'),
 	  'apt_volume_service' => App.new('apt_volume_service', 'service'),
 	  'nsq_service' => App.new('nsq_service', 'special'),
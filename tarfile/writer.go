@@ -10,7 +10,9 @@ import (
 
 type Writer struct {
 	PathToTarFile string
+	tarFile       *os.File
 	tarWriter     *tar.Writer
+	offset        int64
 }
 
 func NewWriter(pathToTarFile string) *Writer {
@@ -24,15 +26,63 @@ func (writer *Writer) Open() error {
 	if err != nil {
 		return fmt.Errorf("Error creating tar file: %v", err)
 	}
-	writer.tarWriter = tar.NewWriter(tarFile)
+	writer.tarFile = tarFile
+	writer.offset = 0
+	writer.tarWriter = tar.NewWriter(&offsetWriter{writer: tarFile, offset: &writer.offset})
 	return nil
 }
 
+// OpenAt opens an existing, partially written tar file so we can
+// keep adding files to it. Param offset should be a value returned by
+// Offset() after a successful call to AddToArchive. Anything in the
+// file past offset, including a partially written entry, is discarded.
+// This lets workers resume building large tar files after a restart.
+func (writer *Writer) OpenAt(offset int64) error {
+	finfo, err := os.Stat(writer.PathToTarFile)
+	if err != nil {
+		return fmt.Errorf("Cannot reopen tar file: %v", err)
+	}
+	if finfo.Size() < offset {
+		return fmt.Errorf("Cannot reopen tar file %s at offset %d: file is only %d bytes",
+			writer.PathToTarFile, offset, finfo.Size())
+	}
+	tarFile, err := os.OpenFile(writer.PathToTarFile, os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Error opening tar file: %v", err)
+	}
+	if err = tarFile.Truncate(offset); err == nil {
+		_, err = tarFile.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		tarFile.Close()
+		return fmt.Errorf("Error resetting tar file to offset %d: %v", offset, err)
+	}
+	writer.tarFile = tarFile
+	writer.offset = offset
+	writer.tarWriter = tar.NewWriter(&offsetWriter{writer: tarFile, offset: &writer.offset})
+	return nil
+}
+
+// Offset returns the number of bytes written to the tar file so far.
+// After each successful call to AddToArchive, this is the offset at
+// which the next entry will begin.
+func (writer *Writer) Offset() int64 {
+	return writer.offset
+}
+
 func (writer *Writer) Close() error {
+	var err error
 	if writer.tarWriter != nil {
-		return writer.tarWriter.Close()
+		err = writer.tarWriter.Close()
 	}
-	return nil
+	if writer.tarFile != nil {
+		fileErr := writer.tarFile.Close()
+		if err == nil {
+			err = fileErr
+		}
+		writer.tarFile = nil
+	}
+	return err
 }
 
 // Adds a file to a tar archive.
@@ -44,11 +94,16 @@ func (writer *Writer) AddToArchive(filePath, pathWithinArchive string) error {
 	if err != nil {
 		return fmt.Errorf("Cannot add '%s' to archive: %v", filePath, err)
 	}
+	// We leave Format unset, so the tar writer uses a plain USTAR
+	// header when it can, and a PAX header for names longer than
+	// USTAR allows, non-ASCII names, and files larger than 8GB.
+	// Unlike GNU headers, PAX headers are readable by any POSIX tar.
 	header := &tar.Header{
-		Name:    pathWithinArchive,
-		Size:    finfo.Size(),
-		Mode:    int64(finfo.Mode().Perm()),
-		ModTime: finfo.ModTime(),
+		Typeflag: tar.TypeReg,
+		Name:     pathWithinArchive,
+		Size:     finfo.Size(),
+		Mode:     int64(finfo.Mode().Perm()),
+		ModTime:  finfo.ModTime(),
 	}
 
 	// This call adds the owner and group info to the tar file header.
@@ -81,5 +136,19 @@ func (writer *Writer) AddToArchive(filePath, pathWithinArchive string) error {
 			filePath, err)
 	}
 
-	return nil
+	// Write the padding for this entry now, so Offset() points
+	// to the start of the next entry.
+	return writer.tarWriter.Flush()
+}
+
+// offsetWriter counts the bytes written to the underlying writer.
+type offsetWriter struct {
+	writer io.Writer
+	offset *int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	*w.offset += int64(n)
+	return n, err
 }
@@ -5,6 +5,7 @@ import (
 	//	"fmt"
 	"github.com/APTrust/exchange/tarfile"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"io/ioutil"
 	"os"
 	"path"
@@ -84,6 +85,58 @@ func TestAddToArchive(t *testing.T) {
 	assert.Equal(t, "data/subdir/file2.json", filesInArchive[1])
 }
 
+func TestOpenAt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tarwriter_test")
+	if err != nil {
+		assert.FailNow(t, "Cannot create temp dir", err.Error())
+	}
+	tempFilePath := filepath.Join(dir, "test_file.tar")
+	defer os.RemoveAll(dir)
+
+	// Write one file, note the offset, then write a second
+	// file without closing, as if the worker had died.
+	w := tarfile.NewWriter(tempFilePath)
+	err = w.Open()
+	assert.Nil(t, err)
+	err = w.AddToArchive(pathToTestFile("cleanup_result.json"), "file1.json")
+	assert.Nil(t, err)
+	offset := w.Offset()
+	assert.True(t, offset > 0)
+	assert.Equal(t, int64(0), offset%512)
+	err = w.AddToArchive(pathToTestFile("ingest_result.json"), "partial.json")
+	assert.Nil(t, err)
+
+	// Resume at the offset and add a different second file.
+	w = tarfile.NewWriter(tempFilePath)
+	err = w.OpenAt(offset)
+	assert.Nil(t, err)
+	assert.Equal(t, offset, w.Offset())
+	err = w.AddToArchive(pathToTestFile("ingest_result.json"), "data/file2.json")
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+
+	file, err := os.Open(tempFilePath)
+	if err != nil {
+		assert.FailNow(t, "Could not open tar file", err.Error())
+	}
+	defer file.Close()
+	filesInArchive := make([]string, 0)
+	reader := tar.NewReader(file)
+	for {
+		header, err := reader.Next()
+		if err != nil {
+			break
+		}
+		filesInArchive = append(filesInArchive, header.Name)
+	}
+	assert.Equal(t, []string{"file1.json", "data/file2.json"}, filesInArchive)
+
+	// Can't resume past the end of the file.
+	w = tarfile.NewWriter(tempFilePath)
+	err = w.OpenAt(offset * 100)
+	assert.NotNil(t, err)
+}
+
 func TestAddToArchiveWithClosedWriter(t *testing.T) {
 	dir, err := ioutil.TempDir("", "tarwriter_test")
 	if err != nil {
@@ -137,6 +190,38 @@ func TestAddToArchiveWithBadFilePath(t *testing.T) {
 	assert.True(t, strings.Contains(err.Error(), "no such file or directory"))
 }
 
+func TestAddToArchiveLongAndUTF8Names(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tarwriter_test")
+	if err != nil {
+		assert.FailNow(t, "Cannot create temp dir", err.Error())
+	}
+	tempFilePath := filepath.Join(dir, "test_file.tar")
+	defer os.RemoveAll(dir)
+	w := tarfile.NewWriter(tempFilePath)
+	defer w.Close()
+	require.Nil(t, w.Open())
+
+	// USTAR headers can't hold these names, so the writer
+	// should fall back to PAX headers.
+	longName := "bag/data/" + strings.Repeat("subdirectory/", 20) + "file.txt"
+	utf8Name := "bag/data/ünïcødé/文件.txt"
+	require.Nil(t, w.AddToArchive(pathToTestFile("cleanup_result.json"), longName))
+	require.Nil(t, w.AddToArchive(pathToTestFile("cleanup_result.json"), utf8Name))
+	require.Nil(t, w.Close())
+
+	file, err := os.Open(w.PathToTarFile)
+	require.Nil(t, err)
+	defer file.Close()
+	reader := tar.NewReader(file)
+	for _, expectedName := range []string{longName, utf8Name} {
+		header, err := reader.Next()
+		require.Nil(t, err)
+		assert.Equal(t, expectedName, header.Name)
+		assert.Equal(t, byte(tar.TypeReg), header.Typeflag)
+		assert.Equal(t, tar.FormatPAX, header.Format)
+	}
+}
+
 func pathToTestFile(name string) string {
 	_, filename, _, _ := runtime.Caller(0)
 	testDataPath, _ := filepath.Abs(path.Join(filepath.Dir(filename), "..", "testdata", "json_objects"))
@@ -0,0 +1,145 @@
+// Package fasthash provides the fast, non-cryptographic digest
+// algorithms some bagging tools write into manifests: crc32c and
+// xxh64. The validator uses them only as supplementary checks, and
+// to pre-screen very large bags before the slower cryptographic
+// hashing. They're not suitable as the sole fixity for a bag.
+package fasthash
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"math/bits"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// NewCRC32C returns a hash that calculates the CRC-32 checksum with
+// the Castagnoli polynomial, as in manifest-crc32c.txt files.
+func NewCRC32C() hash.Hash32 {
+	return crc32.New(castagnoli)
+}
+
+// The primes are variables rather than constants, so that sums
+// and negations wrap around as the algorithm expects.
+var (
+	prime1 uint64 = 11400714785074694791
+	prime2 uint64 = 14029467366897019727
+	prime3 uint64 = 1609587929392839161
+	prime4 uint64 = 9650029242287828579
+	prime5 uint64 = 2870177450012600261
+)
+
+// xxh64 is a streaming implementation of the 64-bit xxHash
+// algorithm, with seed zero. See
+// https://github.com/Cyan4973/xxHash/blob/dev/doc/xxhash_spec.md
+type xxh64 struct {
+	v1, v2, v3, v4 uint64
+	total          uint64
+	buf            [32]byte
+	bufLen         int
+}
+
+// NewXXH64 returns a hash that calculates the 64-bit xxHash of its
+// input, with seed zero, as in manifest-xxh64.txt files. Sum writes
+// the digest in big-endian order, so its hex encoding matches the
+// canonical form that xxhsum prints.
+func NewXXH64() hash.Hash64 {
+	h := &xxh64{}
+	h.Reset()
+	return h
+}
+
+func (h *xxh64) Reset() {
+	h.v1 = prime1 + prime2
+	h.v2 = prime2
+	h.v3 = 0
+	h.v4 = -prime1
+	h.total = 0
+	h.bufLen = 0
+}
+
+func (h *xxh64) Size() int { return 8 }
+
+func (h *xxh64) BlockSize() int { return 32 }
+
+func (h *xxh64) Write(data []byte) (int, error) {
+	n := len(data)
+	h.total += uint64(n)
+	if h.bufLen+len(data) < 32 {
+		h.bufLen += copy(h.buf[h.bufLen:], data)
+		return n, nil
+	}
+	if h.bufLen > 0 {
+		copied := copy(h.buf[h.bufLen:], data)
+		h.stripe(h.buf[:])
+		data = data[copied:]
+		h.bufLen = 0
+	}
+	for len(data) >= 32 {
+		h.stripe(data[:32])
+		data = data[32:]
+	}
+	h.bufLen = copy(h.buf[:], data)
+	return n, nil
+}
+
+func (h *xxh64) stripe(data []byte) {
+	h.v1 = round(h.v1, binary.LittleEndian.Uint64(data[0:8]))
+	h.v2 = round(h.v2, binary.LittleEndian.Uint64(data[8:16]))
+	h.v3 = round(h.v3, binary.LittleEndian.Uint64(data[16:24]))
+	h.v4 = round(h.v4, binary.LittleEndian.Uint64(data[24:32]))
+}
+
+func (h *xxh64) Sum64() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		acc = bits.RotateLeft64(h.v1, 1) + bits.RotateLeft64(h.v2, 7) +
+			bits.RotateLeft64(h.v3, 12) + bits.RotateLeft64(h.v4, 18)
+		acc = mergeRound(acc, h.v1)
+		acc = mergeRound(acc, h.v2)
+		acc = mergeRound(acc, h.v3)
+		acc = mergeRound(acc, h.v4)
+	} else {
+		acc = prime5
+	}
+	acc += h.total
+	data := h.buf[:h.bufLen]
+	for len(data) >= 8 {
+		acc ^= round(0, binary.LittleEndian.Uint64(data[:8]))
+		acc = bits.RotateLeft64(acc, 27)*prime1 + prime4
+		data = data[8:]
+	}
+	if len(data) >= 4 {
+		acc ^= uint64(binary.LittleEndian.Uint32(data[:4])) * prime1
+		acc = bits.RotateLeft64(acc, 23)*prime2 + prime3
+		data = data[4:]
+	}
+	for _, b := range data {
+		acc ^= uint64(b) * prime5
+		acc = bits.RotateLeft64(acc, 11) * prime1
+	}
+	acc ^= acc >> 33
+	acc *= prime2
+	acc ^= acc >> 29
+	acc *= prime3
+	acc ^= acc >> 32
+	return acc
+}
+
+func (h *xxh64) Sum(b []byte) []byte {
+	var digest [8]byte
+	binary.BigEndian.PutUint64(digest[:], h.Sum64())
+	return append(b, digest[:]...)
+}
+
+func round(acc, input uint64) uint64 {
+	acc += input * prime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * prime1
+}
+
+func mergeRound(acc, val uint64) uint64 {
+	acc ^= round(0, val)
+	return acc*prime1 + prime4
+}
@@ -0,0 +1,49 @@
+package fasthash_test
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/util/fasthash"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestNewCRC32C(t *testing.T) {
+	h := fasthash.NewCRC32C()
+	h.Write([]byte("123456789"))
+	assert.Equal(t, "e3069283", fmt.Sprintf("%x", h.Sum(nil)))
+}
+
+func TestNewXXH64(t *testing.T) {
+	testCases := map[string]string{
+		"":    "ef46db3751d8e999",
+		"a":   "d24ec4f1a98c6e5b",
+		"abc": "44bc2cf5ad770999",
+	}
+	for input, expected := range testCases {
+		h := fasthash.NewXXH64()
+		h.Write([]byte(input))
+		assert.Equal(t, expected, fmt.Sprintf("%x", h.Sum(nil)), input)
+	}
+}
+
+func TestXXH64Streaming(t *testing.T) {
+	// The digest doesn't depend on how the input is split
+	// into writes, including writes that span stripes.
+	input := []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20))
+	whole := fasthash.NewXXH64()
+	whole.Write(input)
+	for _, chunkSize := range []int{1, 7, 31, 32, 33, 100} {
+		h := fasthash.NewXXH64()
+		for i := 0; i < len(input); i += chunkSize {
+			end := i + chunkSize
+			if end > len(input) {
+				end = len(input)
+			}
+			h.Write(input[i:end])
+		}
+		assert.Equal(t, whole.Sum64(), h.Sum64(), "chunk size %d", chunkSize)
+	}
+	whole.Reset()
+	assert.Equal(t, "ef46db3751d8e999", fmt.Sprintf("%x", whole.Sum(nil)))
+}
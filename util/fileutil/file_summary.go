@@ -17,4 +17,14 @@ type FileSummary struct {
 	IsRegularFile bool
 	Uid           int
 	Gid           int
+	// SpecialType is one of the SpecialType constants if this is a
+	// symlink, hard link, device or sparse file. It's empty for
+	// regular files and directories.
+	SpecialType string
+	// LinkTarget is the target of a symlink or hard link.
+	LinkTarget string
+	// ArchivePath is the name of the entry in a tar or zip file,
+	// exactly as the archive records it, including the top-level
+	// directory. It's empty for files on the file system.
+	ArchivePath string
 }
@@ -6,13 +6,19 @@ import (
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 )
 
 type FileSystemIterator struct {
+	// SpecialFilePolicy describes what to do with symlinks, devices
+	// and sparse files. If nil, the iterator uses DefaultSpecialFilePolicy.
+	SpecialFilePolicy *SpecialFilePolicy
+
 	rootPath string
 	files    []string
 	index    int
+	warnings []string
 }
 
 func NewFileSystemIterator(pathToDir string) (*FileSystemIterator, error) {
@@ -41,17 +47,45 @@ func NewFileSystemIterator(pathToDir string) (*FileSystemIterator, error) {
 // Returns an open reader for the next file, along with a FileSummary.
 // Returns io.EOF when it reaches the last file.
 // The caller is responsible for closing the reader.
+// Symlinks, devices and sparse files are returned, skipped or
+// rejected with an error, according to the iterator's SpecialFilePolicy.
 func (iter *FileSystemIterator) Next() (io.ReadCloser, *FileSummary, error) {
-	iter.index += 1
-	if iter.index >= len(iter.files) {
-		return nil, nil, io.EOF
-	}
-	filePath := iter.files[iter.index]
-	var stat os.FileInfo
-	var err error
-	if stat, err = os.Stat(filePath); os.IsNotExist(err) {
-		return nil, nil, fmt.Errorf("File '%s' does not exist.", filePath)
+	for {
+		iter.index += 1
+		if iter.index >= len(iter.files) {
+			return nil, nil, io.EOF
+		}
+		filePath := iter.files[iter.index]
+		var stat os.FileInfo
+		var err error
+		if stat, err = os.Lstat(filePath); os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("File '%s' does not exist.", filePath)
+		}
+		fs := iter.fileSummary(filePath, stat)
+		if fs.SpecialType != "" {
+			skip, err := iter.applySpecialFilePolicy(fs)
+			if err != nil {
+				return nil, fs, err
+			}
+			if skip {
+				continue
+			}
+		}
+		if isDevice(fs.Mode) {
+			// Don't open devices. Opening a named pipe blocks.
+			return nil, fs, fmt.Errorf("Cannot read device '%s'", filePath)
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return nil, fs, fmt.Errorf("Cannot read file '%s': %v", filePath, err)
+		}
+		return file, fs, nil
 	}
+}
+
+// fileSummary returns a FileSummary describing the file at filePath,
+// whose Lstat info is stat.
+func (iter *FileSystemIterator) fileSummary(filePath string, stat os.FileInfo) *FileSummary {
 	fileMode := stat.Mode()
 	fs := &FileSummary{
 		RelPath:       strings.Replace(filePath, iter.rootPath+string(os.PathSeparator), "", 1),
@@ -62,14 +96,76 @@ func (iter *FileSystemIterator) Next() (io.ReadCloser, *FileSummary, error) {
 		IsDir:         stat.IsDir(),
 		IsRegularFile: fileMode.IsRegular(),
 	}
+	if fileMode&os.ModeSymlink != 0 {
+		fs.SpecialType = SpecialTypeSymlink
+		fs.LinkTarget, _ = os.Readlink(filePath)
+	} else if isDevice(fileMode) {
+		fs.SpecialType = SpecialTypeDevice
+	} else if platform.IsSparseFile(stat) {
+		fs.SpecialType = SpecialTypeSparse
+	}
 	uid, gid := platform.FileOwnerAndGroup(stat)
 	fs.Uid = uid
 	fs.Gid = gid
-	file, err := os.Open(filePath)
+	return fs
+}
+
+// applySpecialFilePolicy returns true if the iterator should skip the
+// special file described by fs, or an error if the policy rejects it.
+// If the policy says to follow a symlink, this updates fs to describe
+// the link target.
+func (iter *FileSystemIterator) applySpecialFilePolicy(fs *FileSummary) (skip bool, err error) {
+	policy := iter.SpecialFilePolicy
+	if policy == nil {
+		policy = DefaultSpecialFilePolicy()
+	}
+	switch policy.ActionFor(fs.SpecialType) {
+	case SpecialFileSkip:
+		iter.warnings = append(iter.warnings, specialFileWarning(fs))
+		return true, nil
+	case SpecialFileFollow:
+		if fs.SpecialType == SpecialTypeSymlink {
+			return false, iter.followSymlink(fs)
+		}
+		return false, nil
+	}
+	return false, specialFileError(fs)
+}
+
+// followSymlink updates fs to describe the target of the symlink it
+// describes. It returns an error if the target is outside the directory
+// we're iterating over, because following links out of the bag could
+// pull in arbitrary files from the local system.
+func (iter *FileSystemIterator) followSymlink(fs *FileSummary) error {
+	target, err := filepath.EvalSymlinks(fs.AbsPath)
+	if err != nil {
+		return fmt.Errorf("Cannot follow symlink '%s' -> '%s': %v", fs.RelPath, fs.LinkTarget, err)
+	}
+	root, err := filepath.EvalSymlinks(iter.rootPath)
 	if err != nil {
-		return nil, fs, fmt.Errorf("Cannot read file '%s': %v", filePath, err)
+		return err
 	}
-	return file, fs, nil
+	if !strings.HasPrefix(target, root+string(os.PathSeparator)) {
+		return fmt.Errorf("Cannot follow symlink '%s' -> '%s', because its target "+
+			"is outside the bag.", fs.RelPath, fs.LinkTarget)
+	}
+	stat, err := os.Stat(target)
+	if err != nil {
+		return fmt.Errorf("Cannot follow symlink '%s' -> '%s': %v", fs.RelPath, fs.LinkTarget, err)
+	}
+	fs.Mode = stat.Mode()
+	fs.Size = stat.Size()
+	fs.ModTime = stat.ModTime()
+	fs.IsDir = stat.IsDir()
+	fs.IsRegularFile = stat.Mode().IsRegular()
+	fs.Uid, fs.Gid = platform.FileOwnerAndGroup(stat)
+	return nil
+}
+
+// Warnings returns a list of special files that the
+// iterator skipped because of its SpecialFilePolicy.
+func (iter *FileSystemIterator) Warnings() []string {
+	return iter.warnings
 }
 
 // Returns the last component of the path that this iterator is traversing.
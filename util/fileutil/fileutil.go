@@ -3,10 +3,12 @@ package fileutil
 import (
 	"crypto/md5"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/json"
 	"fmt"
 	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/util"
+	"golang.org/x/crypto/blake2b"
 	"hash"
 	"io"
 	"io/ioutil"
@@ -130,9 +132,10 @@ func LooksSafeToDelete(dir string, minLength, minSeparators int) bool {
 	return len(dir) >= minLength && separatorCount >= minSeparators
 }
 
-// CalculateChecksum calculates the md5 or sha256 checksum of a file.
+// CalculateChecksum calculates the md5, sha256, sha512 or blake2b checksum of a file.
 // Param pathToFile is the path the file, and algorithm should be one
-// of constants.AlgMd5 or constante.AlgSha256. Returns the hex-encoded
+// of constants.AlgMd5, constants.AlgSha256, constants.AlgSha512 or
+// constants.AlgBlake2b. Returns the hex-encoded
 // digest or an error.
 func CalculateChecksum(pathToFile, algorithm string) (string, error) {
 	if !util.StringListContains(constants.ChecksumAlgorithms, algorithm) {
@@ -143,6 +146,10 @@ func CalculateChecksum(pathToFile, algorithm string) (string, error) {
 		_hash = md5.New()
 	} else if algorithm == constants.AlgSha256 {
 		_hash = sha256.New()
+	} else if algorithm == constants.AlgSha512 {
+		_hash = sha512.New()
+	} else if algorithm == constants.AlgBlake2b {
+		_hash, _ = blake2b.New512(nil)
 	} else {
 		// In case we someday add a new algorithm to constants.ChecksumAlgorithms
 		return "", fmt.Errorf("Need to write in support for new digest algorithm %s", algorithm)
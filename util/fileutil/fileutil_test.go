@@ -182,6 +182,14 @@ func TestGetChecksum(t *testing.T) {
 	require.Nil(t, err)
 	assert.Equal(t, "24f4ea194115efa3e8a9bd229cbfa7ac23ded35917af6bd2ec24ffcb1a067f55", sha256)
 
+	sha512, err := fileutil.CalculateChecksum(filePath, constants.AlgSha512)
+	require.Nil(t, err)
+	assert.Equal(t, "28c929a4f101199028f97640fb7c44fb7d111650e496db0bf2166e579d0984cda38d169d3b1da65b461e0cdb6408800574ec08aa504ac0c5d6f32b0994c21e9e", sha512)
+
+	blake2b, err := fileutil.CalculateChecksum(filePath, constants.AlgBlake2b)
+	require.Nil(t, err)
+	assert.Equal(t, "c4f0b007523195a48c67ff0f110f99957c3fdb60b187905fc826bff770413ce3dac8e391e23e21cd76218881b15b07a2cd8592b9d0a3f91bea8fc13fce711aad", blake2b)
+
 	_, err = fileutil.CalculateChecksum(filePath, "fake_algorithm")
 	require.NotNil(t, err)
 
@@ -4,9 +4,36 @@ import (
 	"io"
 )
 
-// ReadIterator is an interface that allows TarFileIterator and
-// FileSystemIterator to be used interchangeably.
+// ReadIterator is an interface that allows TarFileIterator,
+// ZipFileIterator and FileSystemIterator to be used interchangeably.
 type ReadIterator interface {
 	Next() (io.ReadCloser, *FileSummary, error)
 	GetTopLevelDirNames() []string
+	Warnings() []string
+}
+
+// ArchiveIterator is a ReadIterator over a serialized bag that can
+// also find individual files and close the underlying archive.
+// TarFileIterator and ZipFileIterator are ArchiveIterators.
+type ArchiveIterator interface {
+	ReadIterator
+	Find(originalPathWithBagName string) (io.ReadCloser, error)
+	Close()
+}
+
+// NewArchiveIterator returns a ZipFileIterator if pathToArchive ends
+// with .zip, or a TarFileIterator otherwise.
+func NewArchiveIterator(pathToArchive string) (ArchiveIterator, error) {
+	if IsZipFile(pathToArchive) {
+		iterator, err := NewZipFileIterator(pathToArchive)
+		if err != nil {
+			return nil, err
+		}
+		return iterator, nil
+	}
+	iterator, err := NewTarFileIterator(pathToArchive)
+	if err != nil {
+		return nil, err
+	}
+	return iterator, nil
 }
@@ -0,0 +1,133 @@
+package fileutil
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/util"
+	"os"
+)
+
+// Types of special files that iterators may encounter. These are
+// the values of FileSummary.SpecialType.
+const (
+	SpecialTypeSymlink  = "symlink"
+	SpecialTypeHardLink = "hard link"
+	SpecialTypeDevice   = "device"
+	SpecialTypeSparse   = "sparse file"
+)
+
+// What iterators can do when they encounter a special file.
+const (
+	// SpecialFileReject causes the iterator to return an error.
+	SpecialFileReject = "reject"
+	// SpecialFileSkip causes the iterator to skip the file, and to
+	// add a message to the iterator's warnings.
+	SpecialFileSkip = "skip"
+	// SpecialFileFollow causes the iterator to return the contents
+	// of the file, as if it were a regular file. For symlinks, that's
+	// the contents of the link target.
+	SpecialFileFollow = "follow"
+)
+
+var specialFileActions = []string{SpecialFileReject, SpecialFileSkip, SpecialFileFollow}
+
+// SpecialFilePolicy describes what the TarFileIterator and the
+// FileSystemIterator should do when they encounter symlinks,
+// hard links, device nodes and sparse files. Each field should
+// be SpecialFileReject, SpecialFileSkip or SpecialFileFollow.
+//
+// Not all special files can be followed. Devices (including named
+// pipes and sockets) can never be followed. The TarFileIterator can't
+// follow symlinks or hard links, because it can't go back to read
+// the contents of the link target, so it returns an error for those.
+// On the file system, hard links are regular files, so the
+// FileSystemIterator treats them as such. The FileSystemIterator
+// follows symlinks only if their targets are inside the directory
+// it's iterating over, and it does not descend into symlinked
+// directories.
+type SpecialFilePolicy struct {
+	Symlinks    string
+	HardLinks   string
+	Devices     string
+	SparseFiles string
+}
+
+// DefaultSpecialFilePolicy returns the policy that iterators use
+// when none is specified. It skips links and devices, which have
+// no content of their own, and reads sparse files, whose holes
+// read as zeros.
+func DefaultSpecialFilePolicy() *SpecialFilePolicy {
+	return &SpecialFilePolicy{
+		Symlinks:    SpecialFileSkip,
+		HardLinks:   SpecialFileSkip,
+		Devices:     SpecialFileSkip,
+		SparseFiles: SpecialFileFollow,
+	}
+}
+
+// Validate returns an error if any setting in the policy is not
+// valid. Empty settings are valid, and fall back to the default.
+func (policy *SpecialFilePolicy) Validate() error {
+	settings := map[string]string{
+		"Symlinks":    policy.Symlinks,
+		"HardLinks":   policy.HardLinks,
+		"Devices":     policy.Devices,
+		"SparseFiles": policy.SparseFiles,
+	}
+	for _, name := range []string{"Symlinks", "HardLinks", "Devices", "SparseFiles"} {
+		value := settings[name]
+		if value != "" && !util.StringListContains(specialFileActions, value) {
+			return fmt.Errorf("SpecialFilePolicy.%s '%s' is not valid. "+
+				"Use reject, skip or follow.", name, value)
+		}
+	}
+	if policy.Devices == SpecialFileFollow {
+		return fmt.Errorf("SpecialFilePolicy.Devices cannot be follow.")
+	}
+	return nil
+}
+
+// ActionFor returns the action to take for the specified special
+// file type. This is one of SpecialFileReject, SpecialFileSkip or
+// SpecialFileFollow.
+func (policy *SpecialFilePolicy) ActionFor(specialType string) string {
+	action := ""
+	defaultAction := ""
+	defaultPolicy := DefaultSpecialFilePolicy()
+	switch specialType {
+	case SpecialTypeSymlink:
+		action, defaultAction = policy.Symlinks, defaultPolicy.Symlinks
+	case SpecialTypeHardLink:
+		action, defaultAction = policy.HardLinks, defaultPolicy.HardLinks
+	case SpecialTypeDevice:
+		action, defaultAction = policy.Devices, defaultPolicy.Devices
+	case SpecialTypeSparse:
+		action, defaultAction = policy.SparseFiles, defaultPolicy.SparseFiles
+	}
+	if action == "" {
+		return defaultAction
+	}
+	return action
+}
+
+// isDevice returns true if mode describes a device node,
+// named pipe or socket.
+func isDevice(mode os.FileMode) bool {
+	return mode&(os.ModeDevice|os.ModeCharDevice|os.ModeNamedPipe|os.ModeSocket) != 0
+}
+
+// specialFileError returns the error for a special file
+// that the policy rejects.
+func specialFileError(fs *FileSummary) error {
+	if fs.LinkTarget != "" {
+		return fmt.Errorf("Bag contains %s '%s' -> '%s', which is not allowed.",
+			fs.SpecialType, fs.RelPath, fs.LinkTarget)
+	}
+	return fmt.Errorf("Bag contains %s '%s', which is not allowed.",
+		fs.SpecialType, fs.RelPath)
+}
+
+// specialFileWarning returns the warning for a special file
+// that the policy skips.
+func specialFileWarning(fs *FileSummary) string {
+	return fmt.Sprintf("Skipped %s '%s'", fs.SpecialType, fs.RelPath)
+}
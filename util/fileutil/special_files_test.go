@@ -0,0 +1,197 @@
+// +build !windows
+
+package fileutil_test
+
+import (
+	"archive/tar"
+	"github.com/APTrust/exchange/util/fileutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// makeSpecialFilesDir creates a bag directory containing a regular
+// file, a symlink to that file, a symlink out of the bag, and a named
+// pipe. Returns the path to the bag directory. Caller should delete
+// the parent of that directory.
+func makeSpecialFilesDir(t *testing.T) string {
+	tempDir, err := ioutil.TempDir("", "special_files")
+	require.Nil(t, err)
+	bagDir := filepath.Join(tempDir, "bag")
+	require.Nil(t, os.MkdirAll(filepath.Join(bagDir, "data"), 0755))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(bagDir, "data", "file.txt"), []byte("hello"), 0644))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(tempDir, "outside.txt"), []byte("secret"), 0644))
+	require.Nil(t, os.Symlink(filepath.Join(bagDir, "data", "file.txt"), filepath.Join(bagDir, "data", "link.txt")))
+	require.Nil(t, os.Symlink(filepath.Join(tempDir, "outside.txt"), filepath.Join(bagDir, "data", "outside.txt")))
+	require.Nil(t, syscall.Mkfifo(filepath.Join(bagDir, "data", "pipe"), 0644))
+	return bagDir
+}
+
+// readAll reads all files from iter, returning a map of file
+// contents, keyed by relative path, and the first error.
+func readAll(iter fileutil.ReadIterator) (map[string]string, error) {
+	files := make(map[string]string)
+	for {
+		reader, fileSummary, err := iter.Next()
+		if err == io.EOF {
+			return files, nil
+		} else if err != nil {
+			return files, err
+		}
+		data, _ := ioutil.ReadAll(reader)
+		reader.Close()
+		files[fileSummary.RelPath] = string(data)
+	}
+}
+
+func TestSpecialFilePolicyValidate(t *testing.T) {
+	assert.Nil(t, fileutil.DefaultSpecialFilePolicy().Validate())
+	assert.Nil(t, (&fileutil.SpecialFilePolicy{}).Validate())
+
+	policy := &fileutil.SpecialFilePolicy{Symlinks: "ignore"}
+	err := policy.Validate()
+	require.NotNil(t, err)
+	assert.Equal(t, "SpecialFilePolicy.Symlinks 'ignore' is not valid. Use reject, skip or follow.", err.Error())
+
+	policy = &fileutil.SpecialFilePolicy{Devices: fileutil.SpecialFileFollow}
+	assert.NotNil(t, policy.Validate())
+}
+
+func TestSpecialFilePolicyActionFor(t *testing.T) {
+	policy := &fileutil.SpecialFilePolicy{Symlinks: fileutil.SpecialFileFollow}
+	assert.Equal(t, fileutil.SpecialFileFollow, policy.ActionFor(fileutil.SpecialTypeSymlink))
+	// Empty settings fall back to the default.
+	assert.Equal(t, fileutil.SpecialFileSkip, policy.ActionFor(fileutil.SpecialTypeHardLink))
+	assert.Equal(t, fileutil.SpecialFileSkip, policy.ActionFor(fileutil.SpecialTypeDevice))
+	assert.Equal(t, fileutil.SpecialFileFollow, policy.ActionFor(fileutil.SpecialTypeSparse))
+}
+
+func TestFileSystemIterator_SpecialFiles(t *testing.T) {
+	bagDir := makeSpecialFilesDir(t)
+	defer os.RemoveAll(filepath.Dir(bagDir))
+
+	// Default policy skips links and devices, with warnings.
+	fsi, err := fileutil.NewFileSystemIterator(bagDir)
+	require.Nil(t, err)
+	files, err := readAll(fsi)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"data/file.txt": "hello"}, files)
+	assert.Equal(t, 3, len(fsi.Warnings()))
+	assert.Contains(t, fsi.Warnings(), "Skipped symlink 'data/link.txt'")
+	assert.Contains(t, fsi.Warnings(), "Skipped device 'data/pipe'")
+
+	// Reject returns an error on the first special file.
+	fsi, err = fileutil.NewFileSystemIterator(bagDir)
+	require.Nil(t, err)
+	fsi.SpecialFilePolicy = &fileutil.SpecialFilePolicy{
+		Symlinks: fileutil.SpecialFileReject,
+		Devices:  fileutil.SpecialFileReject,
+	}
+	_, err = readAll(fsi)
+	require.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "Bag contains symlink 'data/link.txt' -> "), err.Error())
+
+	// Follow reads symlinks inside the bag, but not outside.
+	fsi, err = fileutil.NewFileSystemIterator(bagDir)
+	require.Nil(t, err)
+	fsi.SpecialFilePolicy = &fileutil.SpecialFilePolicy{Symlinks: fileutil.SpecialFileFollow}
+	files, err = readAll(fsi)
+	assert.Equal(t, "hello", files["data/link.txt"])
+	require.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "its target is outside the bag"), err.Error())
+}
+
+func TestFileSystemIterator_SparseFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "sparse_file")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	file, err := os.Create(filepath.Join(tempDir, "sparse.bin"))
+	require.Nil(t, err)
+	require.Nil(t, file.Truncate(8*1024*1024))
+	file.Close()
+
+	fsi, err := fileutil.NewFileSystemIterator(tempDir)
+	require.Nil(t, err)
+	reader, fileSummary, err := fsi.Next()
+	require.Nil(t, err)
+	reader.Close()
+	if fileSummary.SpecialType != fileutil.SpecialTypeSparse {
+		t.Skip("File system does not support sparse files")
+	}
+	// Default policy reads sparse files.
+	assert.True(t, fileSummary.IsRegularFile)
+
+	fsi, err = fileutil.NewFileSystemIterator(tempDir)
+	require.Nil(t, err)
+	fsi.SpecialFilePolicy = &fileutil.SpecialFilePolicy{SparseFiles: fileutil.SpecialFileReject}
+	_, _, err = fsi.Next()
+	require.NotNil(t, err)
+	assert.Equal(t, "Bag contains sparse file 'sparse.bin', which is not allowed.", err.Error())
+}
+
+func TestTarFileIterator_SpecialFiles(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "special_files_tar")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	tarPath := filepath.Join(tempDir, "bag.tar")
+	tarFile, err := os.Create(tarPath)
+	require.Nil(t, err)
+	writer := tar.NewWriter(tarFile)
+	now := time.Now()
+	headers := []*tar.Header{
+		{Name: "bag/data/file.txt", Typeflag: tar.TypeReg, Size: 5, Mode: 0644, ModTime: now},
+		{Name: "bag/data/link.txt", Typeflag: tar.TypeSymlink, Linkname: "file.txt", Mode: 0777, ModTime: now},
+		{Name: "bag/data/hard.txt", Typeflag: tar.TypeLink, Linkname: "bag/data/file.txt", Mode: 0644, ModTime: now},
+		{Name: "bag/data/pipe", Typeflag: tar.TypeFifo, Mode: 0644, ModTime: now},
+	}
+	for _, header := range headers {
+		require.Nil(t, writer.WriteHeader(header))
+		if header.Size > 0 {
+			_, err = writer.Write([]byte("hello"))
+			require.Nil(t, err)
+		}
+	}
+	require.Nil(t, writer.Close())
+	require.Nil(t, tarFile.Close())
+
+	// Default policy skips links and devices, with warnings.
+	tfi, err := fileutil.NewTarFileIterator(tarPath)
+	require.Nil(t, err)
+	files, err := readAll(tfi)
+	tfi.Close()
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"data/file.txt": "hello"}, files)
+	assert.Equal(t, []string{
+		"Skipped symlink 'data/link.txt'",
+		"Skipped hard link 'data/hard.txt'",
+		"Skipped device 'data/pipe'",
+	}, tfi.Warnings())
+
+	// Reject returns an error on the first special file.
+	tfi, err = fileutil.NewTarFileIterator(tarPath)
+	require.Nil(t, err)
+	tfi.SpecialFilePolicy = &fileutil.SpecialFilePolicy{Symlinks: fileutil.SpecialFileReject}
+	_, err = readAll(tfi)
+	tfi.Close()
+	require.NotNil(t, err)
+	assert.Equal(t, "Bag contains symlink 'data/link.txt' -> 'file.txt', which is not allowed.", err.Error())
+
+	// The tar iterator can't follow links.
+	tfi, err = fileutil.NewTarFileIterator(tarPath)
+	require.Nil(t, err)
+	tfi.SpecialFilePolicy = &fileutil.SpecialFilePolicy{
+		Symlinks:  fileutil.SpecialFileSkip,
+		HardLinks: fileutil.SpecialFileFollow,
+	}
+	_, err = readAll(tfi)
+	tfi.Close()
+	require.NotNil(t, err)
+	assert.Equal(t, "Cannot follow hard link 'data/hard.txt' -> 'bag/data/file.txt' in a tarred bag.", err.Error())
+}
@@ -2,6 +2,7 @@ package fileutil
 
 import (
 	"archive/tar"
+	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
@@ -11,58 +12,220 @@ import (
 // TarFileIterator lets us read tarred bags (or any other tarred files)
 // without having to untar them.
 type TarFileIterator struct {
+	// SpecialFilePolicy describes what to do with symlinks, hard
+	// links, devices and sparse files. If nil, the iterator uses
+	// DefaultSpecialFilePolicy.
+	SpecialFilePolicy *SpecialFilePolicy
+
 	tarReader        *tar.Reader
-	file             *os.File
+	reader           io.Closer
 	topLevelDirNames []string
+	warnings         []string
 }
 
 // NewTarFileIterator returns a new TarFileIterator. Param pathToTarFile
-// should be an absolute path to the tar file.
+// should be an absolute path to the tar file. If the file name ends
+// with .tar.gz or .tgz, the iterator unzips the file as it reads.
 func NewTarFileIterator(pathToTarFile string) (*TarFileIterator, error) {
 	file, err := os.Open(pathToTarFile)
 	if err != nil {
 		return nil, err
 	}
+	if IsGzippedTar(pathToTarFile) {
+		return NewGzipTarFileIteratorFromReader(file)
+	}
 	return &TarFileIterator{
 		tarReader:        tar.NewReader(file),
-		file:             file,
+		reader:           file,
+		topLevelDirNames: make([]string, 0),
+	}, nil
+}
+
+// NewTarFileIteratorFromReader returns a new TarFileIterator that reads
+// a tar file from reader, which may be a network stream, such as the
+// body of an S3 GET response. Like any tar reader, the iterator reads
+// forward only, so the caller must open a new stream to read the tar
+// file a second time. Closing the iterator closes reader.
+func NewTarFileIteratorFromReader(reader io.ReadCloser) *TarFileIterator {
+	return &TarFileIterator{
+		tarReader:        tar.NewReader(reader),
+		reader:           reader,
+		topLevelDirNames: make([]string, 0),
+	}
+}
+
+// NewGzipTarFileIteratorFromReader returns a new TarFileIterator that
+// reads a gzipped tar file from reader. Closing the iterator closes
+// reader. Returns an error, and closes reader, if reader does not
+// start with a gzip header.
+func NewGzipTarFileIteratorFromReader(reader io.ReadCloser) (*TarFileIterator, error) {
+	gzReader, err := gzip.NewReader(reader)
+	if err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("Cannot read gzipped tar file: %v", err)
+	}
+	return &TarFileIterator{
+		tarReader:        tar.NewReader(gzReader),
+		reader:           &gzipReadCloser{gzReader: gzReader, reader: reader},
 		topLevelDirNames: make([]string, 0),
 	}, nil
 }
 
+// IsGzippedTar returns true if fileName ends with .tar.gz or .tgz.
+func IsGzippedTar(fileName string) bool {
+	return strings.HasSuffix(fileName, ".tar.gz") || strings.HasSuffix(fileName, ".tgz")
+}
+
+// gzipReadCloser closes both a gzip reader and the
+// underlying file or stream.
+type gzipReadCloser struct {
+	gzReader *gzip.Reader
+	reader   io.Closer
+}
+
+func (closer *gzipReadCloser) Close() error {
+	closer.gzReader.Close()
+	return closer.reader.Close()
+}
+
 // Next returns an open reader for the next file, along with a FileSummary.
-// Returns io.EOF when it reaches the last file.
+// Returns io.EOF when it reaches the last file. Symlinks, hard links,
+// devices and sparse files are returned, skipped or rejected with an
+// error, according to the iterator's SpecialFilePolicy.
+//
+// The tar reader handles PAX and GNU headers, so names longer than
+// 100 bytes, UTF-8 names, and files larger than 8GB come through
+// intact. PAX global headers, which describe the archive rather than
+// a file, are skipped.
 func (iter *TarFileIterator) Next() (io.ReadCloser, *FileSummary, error) {
-	header, err := iter.tarReader.Next()
-	if err != nil {
-		// Error may be io.EOF, which just means we
-		// reached the end of the headers.
-		return nil, nil, err
+	for {
+		header, err := iter.nextHeader()
+		if err != nil {
+			// Error may be io.EOF, which just means we
+			// reached the end of the headers.
+			return nil, nil, err
+		}
+		iter.setTopLevelDirName(header.Name)
+		finfo := header.FileInfo()
+		// Path to file, minus the top-level directory name,
+		// which is the name of the bag.
+		relPathInArchive := (strings.Join(strings.Split(header.Name, "/")[1:], "/"))
+		fs := &FileSummary{
+			RelPath:       relPathInArchive,
+			AbsPath:       "",
+			ArchivePath:   header.Name,
+			Mode:          finfo.Mode(),
+			Size:          header.Size,
+			ModTime:       header.ModTime,
+			IsDir:         header.Typeflag == tar.TypeDir,
+			IsRegularFile: header.Typeflag == tar.TypeReg || header.Typeflag == tar.TypeRegA,
+			Uid:           header.Uid,
+			Gid:           header.Gid,
+			SpecialType:   tarSpecialType(header),
+		}
+		if fs.SpecialType == SpecialTypeSymlink || fs.SpecialType == SpecialTypeHardLink {
+			fs.LinkTarget = header.Linkname
+		}
+		if fs.SpecialType != "" {
+			skip, err := iter.applySpecialFilePolicy(fs)
+			if err != nil {
+				return nil, fs, err
+			}
+			if skip {
+				continue
+			}
+		}
+
+		// Wrap our tar reader in a TarReadCloser. When the caller
+		// calls Read() on this object, it will read to the end
+		// of whatever file the current header describes.
+		tarReadCloser := TarReadCloser{
+			tarReader: iter.tarReader,
+		}
+		return tarReadCloser, fs, nil
 	}
-	iter.setTopLevelDirName(header.Name)
-	finfo := header.FileInfo()
-	// Path to file, minus the top-level directory name,
-	// which is the name of the bag.
-	relPathInArchive := (strings.Join(strings.Split(header.Name, "/")[1:], "/"))
-	fs := &FileSummary{
-		RelPath:       relPathInArchive,
-		AbsPath:       "",
-		Mode:          finfo.Mode(),
-		Size:          header.Size,
-		ModTime:       header.ModTime,
-		IsDir:         header.Typeflag == tar.TypeDir,
-		IsRegularFile: header.Typeflag == tar.TypeReg || header.Typeflag == tar.TypeRegA,
-		Uid:           header.Uid,
-		Gid:           header.Gid,
+}
+
+// nextHeader returns the next header that describes a file or
+// directory, skipping PAX global headers. Tar files built with
+// "tar -cf bag.tar ./bag" have names starting with "./", which
+// we trim, so the first component of each name is the bag name.
+func (iter *TarFileIterator) nextHeader() (*tar.Header, error) {
+	for {
+		header, err := iter.tarReader.Next()
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		for strings.HasPrefix(header.Name, "./") {
+			header.Name = header.Name[2:]
+		}
+		if header.Name == "" || header.Name == "." {
+			// The "./" entry itself.
+			continue
+		}
+		return header, nil
 	}
+}
 
-	// Wrap our tar reader in a TarReadCloser. When the caller
-	// calls Read() on this object, it will read to the end
-	// of whatever file the current header describes.
-	tarReadCloser := TarReadCloser{
-		tarReader: iter.tarReader,
+// tarSpecialType returns the type of special file described by
+// header, or an empty string if it describes a regular file or
+// directory.
+func tarSpecialType(header *tar.Header) string {
+	switch header.Typeflag {
+	case tar.TypeSymlink:
+		return SpecialTypeSymlink
+	case tar.TypeLink:
+		return SpecialTypeHardLink
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		return SpecialTypeDevice
+	case tar.TypeGNUSparse:
+		return SpecialTypeSparse
+	}
+	// The tar reader reports PAX-format sparse files as regular
+	// files, but leaves the GNU sparse records in place.
+	if _, isSparse := header.PAXRecords["GNU.sparse.major"]; isSparse {
+		return SpecialTypeSparse
 	}
-	return tarReadCloser, fs, nil
+	if _, isSparse := header.PAXRecords["GNU.sparse.map"]; isSparse {
+		return SpecialTypeSparse
+	}
+	return ""
+}
+
+// applySpecialFilePolicy returns true if the iterator should skip the
+// special file described by fs, or an error if the policy rejects it.
+// If the policy says to follow the file, this updates fs to describe
+// a regular file.
+func (iter *TarFileIterator) applySpecialFilePolicy(fs *FileSummary) (skip bool, err error) {
+	policy := iter.SpecialFilePolicy
+	if policy == nil {
+		policy = DefaultSpecialFilePolicy()
+	}
+	switch policy.ActionFor(fs.SpecialType) {
+	case SpecialFileSkip:
+		iter.warnings = append(iter.warnings, specialFileWarning(fs))
+		return true, nil
+	case SpecialFileFollow:
+		// The tar reader expands the holes in sparse files, so we can
+		// read them like regular files. We can't go back and read the
+		// target of a link.
+		if fs.SpecialType == SpecialTypeSparse {
+			fs.IsRegularFile = true
+			return false, nil
+		}
+		return false, fmt.Errorf("Cannot follow %s '%s' -> '%s' in a tarred bag.",
+			fs.SpecialType, fs.RelPath, fs.LinkTarget)
+	}
+	return false, specialFileError(fs)
+}
+
+// Warnings returns a list of special files that the
+// iterator skipped because of its SpecialFilePolicy.
+func (iter *TarFileIterator) Warnings() []string {
+	return iter.warnings
 }
 
 // Find returns an open reader for the file with the specified name,
@@ -73,7 +236,7 @@ func (iter *TarFileIterator) Next() (io.ReadCloser, *FileSummary, error) {
 // to get the originalPath param.
 func (iter *TarFileIterator) Find(originalPathWithBagName string) (io.ReadCloser, error) {
 	for {
-		header, err := iter.tarReader.Next()
+		header, err := iter.nextHeader()
 		if err != nil {
 			// Error may be io.EOF, which just means we
 			// reached the end of the headers.
@@ -117,10 +280,10 @@ func (iter *TarFileIterator) GetTopLevelDirNames() []string {
 	return iter.topLevelDirNames
 }
 
-// Close closes the underlying tar file.
+// Close closes the underlying tar file or stream.
 func (iter *TarFileIterator) Close() {
-	if iter.file != nil {
-		iter.file.Close()
+	if iter.reader != nil {
+		iter.reader.Close()
 	}
 }
 
@@ -1,16 +1,20 @@
 package fileutil_test
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"github.com/APTrust/exchange/util/fileutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewTarFileIterator(t *testing.T) {
@@ -22,6 +26,109 @@ func TestNewTarFileIterator(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestNewTarFileIteratorFromReader(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	tarFilePath, _ := filepath.Abs(path.Join(filepath.Dir(filename),
+		"..", "..", "testdata", "unit_test_bags", "example.edu.tagsample_good.tar"))
+	file, err := os.Open(tarFilePath)
+	require.Nil(t, err)
+	defer file.Close()
+
+	// Use a pipe, so the iterator gets a stream it can't seek,
+	// like the body of an S3 response.
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		_, err := io.Copy(pipeWriter, file)
+		pipeWriter.CloseWithError(err)
+	}()
+	tfi := fileutil.NewTarFileIteratorFromReader(pipeReader)
+	require.NotNil(t, tfi)
+	defer tfi.Close()
+
+	fileCount := 0
+	for {
+		_, fileSummary, err := tfi.Next()
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err)
+		if fileSummary.IsRegularFile {
+			fileCount++
+		}
+	}
+	assert.True(t, fileCount > 0)
+	assert.Equal(t, []string{"example.edu.tagsample_good"}, tfi.GetTopLevelDirNames())
+}
+
+// gzipTestBag writes a gzipped copy of a tarred test bag to
+// dir/gzName and returns the path to the copy.
+func gzipTestBag(t *testing.T, tarName, dir, gzName string) string {
+	_, filename, _, _ := runtime.Caller(0)
+	tarFilePath, _ := filepath.Abs(path.Join(filepath.Dir(filename),
+		"..", "..", "testdata", "unit_test_bags", tarName))
+	src, err := os.Open(tarFilePath)
+	require.Nil(t, err)
+	defer src.Close()
+	gzPath := filepath.Join(dir, gzName)
+	dest, err := os.Create(gzPath)
+	require.Nil(t, err)
+	defer dest.Close()
+	gzWriter := gzip.NewWriter(dest)
+	_, err = io.Copy(gzWriter, src)
+	require.Nil(t, err)
+	require.Nil(t, gzWriter.Close())
+	return gzPath
+}
+
+func TestNewTarFileIterator_Gzipped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfi_gzip")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, gzName := range []string{"example.edu.tagsample_good.tar.gz", "example.edu.tagsample_good.tgz"} {
+		gzPath := gzipTestBag(t, "example.edu.tagsample_good.tar", dir, gzName)
+		tfi, err := fileutil.NewTarFileIterator(gzPath)
+		require.Nil(t, err, gzName)
+		require.NotNil(t, tfi, gzName)
+		fileCount := 0
+		for {
+			reader, fileSummary, err := tfi.Next()
+			if err == io.EOF {
+				break
+			}
+			require.Nil(t, err, gzName)
+			if fileSummary.IsRegularFile {
+				_, err = io.Copy(ioutil.Discard, reader)
+				require.Nil(t, err, gzName)
+				fileCount++
+			}
+		}
+		tfi.Close()
+		assert.True(t, fileCount > 0, gzName)
+		assert.Equal(t, []string{"example.edu.tagsample_good"}, tfi.GetTopLevelDirNames())
+	}
+
+	// Plain tar file with a .tgz name isn't gzipped.
+	_, filename, _, _ := runtime.Caller(0)
+	tarFilePath, _ := filepath.Abs(path.Join(filepath.Dir(filename),
+		"..", "..", "testdata", "unit_test_bags", "example.edu.tagsample_good.tar"))
+	data, err := ioutil.ReadFile(tarFilePath)
+	require.Nil(t, err)
+	badPath := filepath.Join(dir, "not_gzipped.tgz")
+	require.Nil(t, ioutil.WriteFile(badPath, data, 0644))
+	tfi, err := fileutil.NewTarFileIterator(badPath)
+	assert.Nil(t, tfi)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Cannot read gzipped tar file")
+}
+
+func TestIsGzippedTar(t *testing.T) {
+	assert.True(t, fileutil.IsGzippedTar("bag.tar.gz"))
+	assert.True(t, fileutil.IsGzippedTar("bag.tgz"))
+	assert.False(t, fileutil.IsGzippedTar("bag.tar"))
+	assert.False(t, fileutil.IsGzippedTar("bag.gz"))
+}
+
 func TestTFINext(t *testing.T) {
 	_, filename, _, _ := runtime.Caller(0)
 	tarFilePath, _ := filepath.Abs(path.Join(filepath.Dir(filename),
@@ -140,3 +247,90 @@ func TestTFIFind(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Nil(t, readCloser)
 }
+
+func TestTFINext_LongHeaders(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "long_headers_tar")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	tarPath := filepath.Join(tempDir, "bag.tar")
+	tarFile, err := os.Create(tarPath)
+	require.Nil(t, err)
+
+	// Names like this come from "tar -cf bag.tar ./bag" and from
+	// git archive, which adds a PAX global header.
+	deepPath := "bag/data/" + strings.Repeat("subdirectory/", 20) + "file.txt"
+	gnuPath := "bag/data/" + strings.Repeat("gnu_long_name_", 10) + ".txt"
+	utf8Path := "bag/data/ünïcødé/文件.txt"
+	now := time.Now()
+	writer := tar.NewWriter(tarFile)
+	headers := []*tar.Header{
+		{Name: "pax_global_header", Typeflag: tar.TypeXGlobalHeader,
+			PAXRecords: map[string]string{"comment": "abc123"}},
+		{Name: "./", Typeflag: tar.TypeDir, Mode: 0755, ModTime: now},
+		{Name: "./" + deepPath, Typeflag: tar.TypeReg, Size: 5, Mode: 0644, ModTime: now, Format: tar.FormatPAX},
+		{Name: gnuPath, Typeflag: tar.TypeReg, Size: 5, Mode: 0644, ModTime: now, Format: tar.FormatGNU},
+		{Name: utf8Path, Typeflag: tar.TypeReg, Size: 5, Mode: 0644, ModTime: now},
+	}
+	for _, header := range headers {
+		require.Nil(t, writer.WriteHeader(header))
+		if header.Size > 0 {
+			_, err = writer.Write([]byte("hello"))
+			require.Nil(t, err)
+		}
+	}
+	// Files over 8GB need a PAX or GNU size. We write only the
+	// header, since the iterator doesn't read the content unless
+	// we ask it to.
+	bigHeader := &tar.Header{Name: "bag/data/big.bin", Typeflag: tar.TypeReg,
+		Size: 9 << 30, Mode: 0644, ModTime: now}
+	require.Nil(t, writer.WriteHeader(bigHeader))
+	require.Nil(t, tarFile.Close())
+
+	tfi, err := fileutil.NewTarFileIterator(tarPath)
+	require.Nil(t, err)
+	defer tfi.Close()
+	expected := []string{deepPath, gnuPath, utf8Path}
+	for _, expectedPath := range expected {
+		reader, fs, err := tfi.Next()
+		require.Nil(t, err)
+		assert.Equal(t, expectedPath, fs.ArchivePath)
+		assert.Equal(t, strings.TrimPrefix(expectedPath, "bag/"), fs.RelPath)
+		assert.True(t, fs.IsRegularFile)
+		data, err := ioutil.ReadAll(reader)
+		require.Nil(t, err)
+		assert.Equal(t, "hello", string(data))
+	}
+	_, fs, err := tfi.Next()
+	require.Nil(t, err)
+	assert.Equal(t, "data/big.bin", fs.RelPath)
+	assert.EqualValues(t, 9<<30, fs.Size)
+
+	// The global header and "./" are not part of the bag.
+	assert.Equal(t, []string{"bag"}, tfi.GetTopLevelDirNames())
+}
+
+func TestTFIFind_DotSlashNames(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "dot_slash_tar")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	tarPath := filepath.Join(tempDir, "bag.tar")
+	tarFile, err := os.Create(tarPath)
+	require.Nil(t, err)
+	writer := tar.NewWriter(tarFile)
+	header := &tar.Header{Name: "./bag/bagit.txt", Typeflag: tar.TypeReg,
+		Size: 5, Mode: 0644, ModTime: time.Now()}
+	require.Nil(t, writer.WriteHeader(header))
+	_, err = writer.Write([]byte("hello"))
+	require.Nil(t, err)
+	require.Nil(t, writer.Close())
+	require.Nil(t, tarFile.Close())
+
+	tfi, err := fileutil.NewTarFileIterator(tarPath)
+	require.Nil(t, err)
+	defer tfi.Close()
+	reader, err := tfi.Find("bag/bagit.txt")
+	require.Nil(t, err)
+	data, err := ioutil.ReadAll(reader)
+	require.Nil(t, err)
+	assert.Equal(t, "hello", string(data))
+}
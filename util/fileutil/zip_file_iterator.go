@@ -0,0 +1,190 @@
+package fileutil
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// ZipFileIterator lets us read zipped bags without having to unzip
+// them. Unlike tar files, zip files have a central directory, so the
+// iterator can open any file at any time. Zip files don't record
+// file owners, so Uid and Gid are always zero.
+type ZipFileIterator struct {
+	// SpecialFilePolicy describes what to do with symlinks and
+	// devices. If nil, the iterator uses DefaultSpecialFilePolicy.
+	SpecialFilePolicy *SpecialFilePolicy
+
+	zipReader        *zip.ReadCloser
+	index            int
+	topLevelDirNames []string
+	warnings         []string
+}
+
+// NewZipFileIterator returns a new ZipFileIterator. Param pathToZipFile
+// should be an absolute path to the zip file.
+func NewZipFileIterator(pathToZipFile string) (*ZipFileIterator, error) {
+	zipReader, err := zip.OpenReader(pathToZipFile)
+	if err != nil {
+		return nil, err
+	}
+	return &ZipFileIterator{
+		zipReader:        zipReader,
+		index:            -1,
+		topLevelDirNames: make([]string, 0),
+	}, nil
+}
+
+// IsZipFile returns true if fileName ends with .zip.
+func IsZipFile(fileName string) bool {
+	return strings.HasSuffix(fileName, ".zip")
+}
+
+// Next returns an open reader for the next file, along with a FileSummary.
+// Returns io.EOF when it reaches the last file. For directory entries,
+// the reader is nil. The caller is responsible for closing the reader.
+// Symlinks and devices are returned, skipped or rejected with an error,
+// according to the iterator's SpecialFilePolicy.
+func (iter *ZipFileIterator) Next() (io.ReadCloser, *FileSummary, error) {
+	for {
+		iter.index += 1
+		if iter.index >= len(iter.zipReader.File) {
+			return nil, nil, io.EOF
+		}
+		zipFile := iter.zipReader.File[iter.index]
+		iter.setTopLevelDirName(zipFile.Name)
+		fs := zipFileSummary(zipFile)
+		if fs.SpecialType != "" {
+			skip, err := iter.applySpecialFilePolicy(zipFile, fs)
+			if err != nil {
+				return nil, fs, err
+			}
+			if skip {
+				continue
+			}
+		}
+		if fs.IsDir {
+			return nil, fs, nil
+		}
+		reader, err := zipFile.Open()
+		if err != nil {
+			return nil, fs, fmt.Errorf("Cannot read file '%s' in zip file: %v", zipFile.Name, err)
+		}
+		return reader, fs, nil
+	}
+}
+
+// zipFileSummary returns a FileSummary describing zipFile. The path
+// of a directory entry ends with a slash, which we strip.
+func zipFileSummary(zipFile *zip.File) *FileSummary {
+	mode := zipFile.Mode()
+	isDir := mode.IsDir() || strings.HasSuffix(zipFile.Name, "/")
+	name := strings.TrimSuffix(zipFile.Name, "/")
+	fs := &FileSummary{
+		// Path to file, minus the top-level directory name,
+		// which is the name of the bag.
+		RelPath:       strings.Join(strings.Split(name, "/")[1:], "/"),
+		AbsPath:       "",
+		ArchivePath:   zipFile.Name,
+		Mode:          mode,
+		Size:          int64(zipFile.UncompressedSize64),
+		ModTime:       zipFile.Modified,
+		IsDir:         isDir,
+		IsRegularFile: !isDir && mode.IsRegular(),
+	}
+	if mode&os.ModeSymlink != 0 {
+		fs.SpecialType = SpecialTypeSymlink
+	} else if isDevice(mode) {
+		fs.SpecialType = SpecialTypeDevice
+	}
+	return fs
+}
+
+// applySpecialFilePolicy returns true if the iterator should skip the
+// special file described by fs, or an error if the policy rejects it.
+// Zip files store a symlink's target as the content of the entry.
+func (iter *ZipFileIterator) applySpecialFilePolicy(zipFile *zip.File, fs *FileSummary) (skip bool, err error) {
+	if fs.SpecialType == SpecialTypeSymlink {
+		fs.LinkTarget = zipLinkTarget(zipFile)
+	}
+	policy := iter.SpecialFilePolicy
+	if policy == nil {
+		policy = DefaultSpecialFilePolicy()
+	}
+	switch policy.ActionFor(fs.SpecialType) {
+	case SpecialFileSkip:
+		iter.warnings = append(iter.warnings, specialFileWarning(fs))
+		return true, nil
+	case SpecialFileFollow:
+		return false, fmt.Errorf("Cannot follow %s '%s' -> '%s' in a zipped bag.",
+			fs.SpecialType, fs.RelPath, fs.LinkTarget)
+	}
+	return false, specialFileError(fs)
+}
+
+// zipLinkTarget returns the target of the symlink stored in zipFile,
+// or an empty string if it can't be read.
+func zipLinkTarget(zipFile *zip.File) string {
+	reader, err := zipFile.Open()
+	if err != nil {
+		return ""
+	}
+	defer reader.Close()
+	target, err := ioutil.ReadAll(io.LimitReader(reader, 4096))
+	if err != nil {
+		return ""
+	}
+	return string(target)
+}
+
+// Warnings returns a list of special files that the
+// iterator skipped because of its SpecialFilePolicy.
+func (iter *ZipFileIterator) Warnings() []string {
+	return iter.warnings
+}
+
+// Find returns an open reader for the file with the specified name,
+// or an error if that file cannot be found. Caller is responsible
+// for closing the reader. Use genericFile.OriginalPathWithBagName()
+// to get the originalPath param.
+func (iter *ZipFileIterator) Find(originalPathWithBagName string) (io.ReadCloser, error) {
+	for _, zipFile := range iter.zipReader.File {
+		if zipFile.Name == originalPathWithBagName {
+			return zipFile.Open()
+		}
+	}
+	return nil, fmt.Errorf("File '%s' not found in archive", originalPathWithBagName)
+}
+
+// Keep track of any top-level directory names we encounter.
+// See the notes on TarFileIterator.setTopLevelDirName.
+func (iter *ZipFileIterator) setTopLevelDirName(entryName string) {
+	topLevelDir := strings.Split(entryName, "/")[0]
+	for i := range iter.topLevelDirNames {
+		if iter.topLevelDirNames[i] == topLevelDir {
+			return
+		}
+	}
+	iter.topLevelDirNames = append(iter.topLevelDirNames, topLevelDir)
+}
+
+// GetTopLevelDirNames returns the names of the top level directories to
+// which the zip file expands. For APTrust purposes, the zip file should
+// expand to one directory whose name matches that of the zip file, minus
+// the .zip extension.
+//
+// Note that you should read the entire zip file before calling
+// this; otherwise, you may not get all the top-level dir names.
+func (iter *ZipFileIterator) GetTopLevelDirNames() []string {
+	return iter.topLevelDirNames
+}
+
+// Close closes the underlying zip file.
+func (iter *ZipFileIterator) Close() {
+	if iter.zipReader != nil {
+		iter.zipReader.Close()
+	}
+}
@@ -0,0 +1,199 @@
+package fileutil_test
+
+import (
+	"archive/zip"
+	"github.com/APTrust/exchange/util/fileutil"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// zipTestBag writes a zipped copy of a tarred test bag
+// to dir and returns the path to the copy.
+func zipTestBag(t *testing.T, tarName, dir string) string {
+	_, filename, _, _ := runtime.Caller(0)
+	tarFilePath, _ := filepath.Abs(path.Join(filepath.Dir(filename),
+		"..", "..", "testdata", "unit_test_bags", tarName))
+	zipPath := filepath.Join(dir, tarName[0:len(tarName)-4]+".zip")
+	require.Nil(t, testutil.TarToZip(tarFilePath, zipPath))
+	return zipPath
+}
+
+// writeZip writes a small zip file with a directory entry, a regular
+// file and a symlink to dir, and returns the path to the zip file.
+func writeZip(t *testing.T, dir string) string {
+	zipPath := filepath.Join(dir, "bag.zip")
+	file, err := os.Create(zipPath)
+	require.Nil(t, err)
+	defer file.Close()
+	zipWriter := zip.NewWriter(file)
+
+	dirHeader := &zip.FileHeader{Name: "bag/data/"}
+	dirHeader.SetMode(os.ModeDir | 0755)
+	_, err = zipWriter.CreateHeader(dirHeader)
+	require.Nil(t, err)
+
+	fileHeader := &zip.FileHeader{
+		Name:     "bag/data/file.txt",
+		Method:   zip.Deflate,
+		Modified: time.Date(2017, 4, 5, 6, 7, 8, 0, time.UTC),
+	}
+	fileHeader.SetMode(0644)
+	writer, err := zipWriter.CreateHeader(fileHeader)
+	require.Nil(t, err)
+	_, err = writer.Write([]byte("Hello, zip!"))
+	require.Nil(t, err)
+
+	linkHeader := &zip.FileHeader{Name: "bag/data/link.txt"}
+	linkHeader.SetMode(os.ModeSymlink | 0777)
+	writer, err = zipWriter.CreateHeader(linkHeader)
+	require.Nil(t, err)
+	_, err = writer.Write([]byte("file.txt"))
+	require.Nil(t, err)
+
+	require.Nil(t, zipWriter.Close())
+	return zipPath
+}
+
+func TestNewZipFileIterator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zfi")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	zfi, err := fileutil.NewZipFileIterator(zipTestBag(t, "example.edu.tagsample_good.tar", dir))
+	assert.NotNil(t, zfi)
+	assert.Nil(t, err)
+	zfi.Close()
+
+	zfi, err = fileutil.NewZipFileIterator(filepath.Join(dir, "does_not_exist.zip"))
+	assert.Nil(t, zfi)
+	assert.NotNil(t, err)
+}
+
+func TestZFINext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zfi")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	zfi, err := fileutil.NewZipFileIterator(writeZip(t, dir))
+	require.Nil(t, err)
+	defer zfi.Close()
+
+	// Directory entry
+	reader, fs, err := zfi.Next()
+	require.Nil(t, err)
+	assert.Nil(t, reader)
+	assert.Equal(t, "data", fs.RelPath)
+	assert.True(t, fs.IsDir)
+	assert.False(t, fs.IsRegularFile)
+
+	// Regular file
+	reader, fs, err = zfi.Next()
+	require.Nil(t, err)
+	require.NotNil(t, reader)
+	data, err := ioutil.ReadAll(reader)
+	reader.Close()
+	require.Nil(t, err)
+	assert.Equal(t, "Hello, zip!", string(data))
+	assert.Equal(t, "data/file.txt", fs.RelPath)
+	assert.Empty(t, fs.AbsPath)
+	assert.EqualValues(t, 11, fs.Size)
+	assert.True(t, fs.ModTime.Equal(time.Date(2017, 4, 5, 6, 7, 8, 0, time.UTC)))
+	assert.False(t, fs.IsDir)
+	assert.True(t, fs.IsRegularFile)
+
+	// The default policy skips symlinks, with a warning.
+	_, _, err = zfi.Next()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, []string{"bag"}, zfi.GetTopLevelDirNames())
+	require.Equal(t, 1, len(zfi.Warnings()))
+	assert.Contains(t, zfi.Warnings()[0], "data/link.txt")
+}
+
+func TestZFINext_RejectSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zfi")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	zfi, err := fileutil.NewZipFileIterator(writeZip(t, dir))
+	require.Nil(t, err)
+	defer zfi.Close()
+	zfi.SpecialFilePolicy = &fileutil.SpecialFilePolicy{Symlinks: fileutil.SpecialFileReject}
+
+	for i := 0; i < 2; i++ {
+		_, _, err := zfi.Next()
+		require.Nil(t, err)
+	}
+	reader, fs, err := zfi.Next()
+	require.NotNil(t, err)
+	assert.Nil(t, reader)
+	require.NotNil(t, fs)
+	assert.Equal(t, fileutil.SpecialTypeSymlink, fs.SpecialType)
+	assert.Equal(t, "file.txt", fs.LinkTarget)
+
+	// We can't follow links in a zip file.
+	zfi, err = fileutil.NewZipFileIterator(filepath.Join(dir, "bag.zip"))
+	require.Nil(t, err)
+	defer zfi.Close()
+	zfi.SpecialFilePolicy = &fileutil.SpecialFilePolicy{Symlinks: fileutil.SpecialFileFollow}
+	for i := 0; i < 2; i++ {
+		_, _, err := zfi.Next()
+		require.Nil(t, err)
+	}
+	_, _, err = zfi.Next()
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Cannot follow symlink")
+}
+
+func TestZFIFind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zfi")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	zfi, err := fileutil.NewZipFileIterator(zipTestBag(t, "example.edu.tagsample_good.tar", dir))
+	require.Nil(t, err)
+	defer zfi.Close()
+
+	// Unlike a tar iterator, a zip iterator can find files in any order.
+	for _, name := range []string{"example.edu.tagsample_good/data/datastream-DC",
+		"example.edu.tagsample_good/bagit.txt"} {
+		reader, err := zfi.Find(name)
+		require.Nil(t, err, name)
+		require.NotNil(t, reader, name)
+		data, err := ioutil.ReadAll(reader)
+		reader.Close()
+		require.Nil(t, err)
+		assert.True(t, len(data) > 0, name)
+	}
+	reader, err := zfi.Find("example.edu.tagsample_good/no_such_file.txt")
+	assert.Nil(t, reader)
+	assert.NotNil(t, err)
+}
+
+func TestNewArchiveIterator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zfi")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	iterator, err := fileutil.NewArchiveIterator(zipTestBag(t, "example.edu.tagsample_good.tar", dir))
+	require.Nil(t, err)
+	_, isZip := iterator.(*fileutil.ZipFileIterator)
+	assert.True(t, isZip)
+	iterator.Close()
+
+	_, filename, _, _ := runtime.Caller(0)
+	tarFilePath, _ := filepath.Abs(path.Join(filepath.Dir(filename),
+		"..", "..", "testdata", "unit_test_bags", "example.edu.tagsample_good.tar"))
+	iterator, err = fileutil.NewArchiveIterator(tarFilePath)
+	require.Nil(t, err)
+	_, isTar := iterator.(*fileutil.TarFileIterator)
+	assert.True(t, isTar)
+	iterator.Close()
+
+	iterator, err = fileutil.NewArchiveIterator(filepath.Join(dir, "does_not_exist.zip"))
+	assert.Nil(t, iterator)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"github.com/op/go-logging"
+	"sync"
+	"time"
+)
+
+// RecentErrors holds the most recent errors logged through the
+// logger returned by InitLogger, so workers can report them
+// through the admin API.
+var RecentErrors = NewErrorBuffer(50)
+
+// LogEntry is a single message captured by an ErrorBuffer.
+type LogEntry struct {
+	Time    time.Time
+	Level   string
+	Message string
+}
+
+// ErrorBuffer is a go-logging backend that keeps the last few
+// ERROR and CRITICAL messages in memory. This structure uses a
+// mutex, so it's safe to share across goroutines.
+type ErrorBuffer struct {
+	capacity int
+	entries  []LogEntry
+	mutex    *sync.Mutex
+}
+
+// NewErrorBuffer creates an ErrorBuffer that holds up to
+// capacity entries.
+func NewErrorBuffer(capacity int) *ErrorBuffer {
+	return &ErrorBuffer{
+		capacity: capacity,
+		entries:  make([]LogEntry, 0, capacity),
+		mutex:    &sync.Mutex{},
+	}
+}
+
+// Log implements logging.Backend. It discards messages less
+// severe than ERROR. When the buffer is full, the oldest entry
+// is dropped.
+func (buffer *ErrorBuffer) Log(level logging.Level, calldepth int, record *logging.Record) error {
+	if level > logging.ERROR {
+		return nil
+	}
+	entry := LogEntry{
+		Time:    record.Time.UTC(),
+		Level:   level.String(),
+		Message: record.Message(),
+	}
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	if len(buffer.entries) == buffer.capacity {
+		copy(buffer.entries, buffer.entries[1:])
+		buffer.entries = buffer.entries[:len(buffer.entries)-1]
+	}
+	buffer.entries = append(buffer.entries, entry)
+	return nil
+}
+
+// Entries returns a copy of the buffered entries, oldest first.
+func (buffer *ErrorBuffer) Entries() []LogEntry {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	entries := make([]LogEntry, len(buffer.entries))
+	copy(entries, buffer.entries)
+	return entries
+}
@@ -0,0 +1,37 @@
+package logger_test
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/util/logger"
+	"github.com/op/go-logging"
+	"testing"
+)
+
+func TestErrorBuffer(t *testing.T) {
+	buffer := logger.NewErrorBuffer(3)
+	log := logging.MustGetLogger("error_buffer_test")
+	log.SetBackend(logging.AddModuleLevel(buffer))
+
+	log.Info("Info messages are not kept")
+	log.Warning("Neither are warnings")
+	if len(buffer.Entries()) != 0 {
+		t.Errorf("Expected no entries, got %d", len(buffer.Entries()))
+	}
+
+	for i := 1; i <= 4; i++ {
+		log.Error("Error %d", i)
+	}
+	log.Critical("Critical")
+	entries := buffer.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	for i, expected := range []string{"Error 3", "Error 4", "Critical"} {
+		if entries[i].Message != expected {
+			t.Errorf("Entry %d: expected '%s', got '%s'", i, expected, entries[i].Message)
+		}
+	}
+	if entries[2].Level != fmt.Sprint(logging.CRITICAL) {
+		t.Errorf("Expected level CRITICAL, got %s", entries[2].Level)
+	}
+}
@@ -14,6 +14,7 @@ import (
 /*
 InitLogger creates and returns a logger suitable for logging
 human-readable message. Also returns the path to the log file.
+Errors logged through this logger are also kept in RecentErrors.
 */
 func InitLogger(config *models.Config) (*logging.Logger, string) {
 	processName := path.Base(os.Args[0])
@@ -44,10 +45,10 @@ func InitLogger(config *models.Config) (*logging.Logger, string) {
 		// Log to BOTH file and stderr
 		stderrBackend := logging.NewLogBackend(os.Stderr, "", stdlog.Lshortfile|stdlog.LstdFlags|stdlog.LUTC)
 		stderrBackend.Color = true
-		logging.SetBackend(logBackend, stderrBackend)
+		logging.SetBackend(logBackend, stderrBackend, RecentErrors)
 	} else {
-		// Log to file only
-		logging.SetBackend(logBackend)
+		// Log to file only, and keep recent errors for the admin API
+		logging.SetBackend(logBackend, RecentErrors)
 	}
 
 	return log, filename
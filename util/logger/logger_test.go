@@ -62,6 +62,10 @@ func TestInitLogger(t *testing.T) {
 	if false == strings.HasSuffix(string(data), "Test Message\n") {
 		t.Error("Expected message was not in the message log.")
 	}
+	entries := logger.RecentErrors.Entries()
+	if len(entries) == 0 || entries[len(entries)-1].Message != "Test Message" {
+		t.Error("Expected message was not in RecentErrors.")
+	}
 }
 
 func TestInitJsonLogger(t *testing.T) {
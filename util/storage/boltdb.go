@@ -9,11 +9,17 @@ import (
 	"github.com/boltdb/bolt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 )
 
 const FILE_BUCKET = "files"
 const OBJ_BUCKET = "objects"
+const GLACIER_REQUEST_BUCKET = "glacier_requests"
+
+// glacierRequestCountsKey is the key under which SaveGlacierRequestCounts
+// stores a GlacierRequestTracker's counts.
+const glacierRequestCountsKey = "counts"
 
 // BoltDB represents a bolt database, which is a single-file key-value
 // store. Our validator uses this to track information about the files
@@ -25,9 +31,24 @@ const OBJ_BUCKET = "objects"
 // per file. APTrust ingest services will track more than that: about
 // 8-9 kilobytes of data per file. Multiply that by 100k or even
 // 1 million files in a bag, and that's too much to keep in memory.
+//
+// Each bolt transaction syncs the DB file to disk, which makes saving
+// values one at a time very slow for bags with many files. Set
+// BatchSize to have Save buffer values in memory and write them in
+// batches. Reads see buffered values, so the buffering is invisible
+// to callers, except that values may not be on disk until the next
+// Flush or Close.
 type BoltDB struct {
-	db       *bolt.DB
-	filePath string
+	// BatchSize is the number of values Save and SaveBatch buffer
+	// before writing them all in a single transaction. Zero, the
+	// default, means write every value immediately.
+	BatchSize int
+
+	db             *bolt.DB
+	filePath       string
+	pendingObjects map[string][]byte
+	pendingFiles   map[string][]byte
+	mutex          sync.Mutex
 }
 
 // NewBoltDB opens a bolt database, creating the DB file if it doesn't
@@ -37,8 +58,10 @@ func NewBoltDB(filePath string) (boltDB *BoltDB, err error) {
 	db, err := bolt.Open(filePath, 0644, &bolt.Options{Timeout: 2 * time.Second})
 	if err == nil {
 		boltDB = &BoltDB{
-			db:       db,
-			filePath: filePath,
+			db:             db,
+			filePath:       filePath,
+			pendingObjects: make(map[string][]byte),
+			pendingFiles:   make(map[string][]byte),
 		}
 		err = boltDB.initBuckets()
 	}
@@ -68,14 +91,17 @@ func (boltDB *BoltDB) FilePath() string {
 	return boltDB.filePath
 }
 
-// Close closes the bolt database.
+// Close writes any buffered values and closes the bolt database.
+// Call Flush first if you need to know whether the write succeeded.
 func (boltDB *BoltDB) Close() {
+	boltDB.Flush()
 	boltDB.db.Close()
 }
 
 // ObjectIdentifier returns the IntellectualObject.Identifier
 // for the object stored in this DB file.
 func (boltDB *BoltDB) ObjectIdentifier() string {
+	boltDB.Flush()
 	key := make([]byte, 0)
 	boltDB.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(OBJ_BUCKET))
@@ -86,27 +112,91 @@ func (boltDB *BoltDB) ObjectIdentifier() string {
 	return string(key)
 }
 
-// Save saves a value to the bolt database.
+// Save saves a value to the bolt database. If BatchSize is greater
+// than zero, the value may sit in the write buffer until the buffer
+// is full.
 func (boltDB *BoltDB) Save(key string, value interface{}) error {
-	_, isIntelObj := value.(*models.IntellectualObject)
-	bucketName := FILE_BUCKET
-	if isIntelObj {
-		bucketName = OBJ_BUCKET
+	return boltDB.SaveBatch(map[string]interface{}{key: value})
+}
+
+// SaveBatch saves a number of values to the bolt database in a
+// single transaction, which is much faster than saving them one
+// at a time. Param values is a map of keys to values. If any
+// value cannot be encoded, none of them will be saved. If BatchSize
+// is greater than zero, the values may sit in the write buffer
+// until the buffer is full.
+func (boltDB *BoltDB) SaveBatch(values map[string]interface{}) error {
+	encoded := make(map[string][]byte, len(values))
+	for key, value := range values {
+		buf := bytes.NewBuffer(make([]byte, 0))
+		encoder := gob.NewEncoder(buf)
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+		encoded[key] = buf.Bytes()
 	}
-	var byteSlice []byte
-	buf := bytes.NewBuffer(byteSlice)
-	encoder := gob.NewEncoder(buf)
-	err := encoder.Encode(value)
-	if err == nil {
-		err = boltDB.db.Update(func(tx *bolt.Tx) error {
+	boltDB.mutex.Lock()
+	defer boltDB.mutex.Unlock()
+	for key, data := range encoded {
+		if _, isIntelObj := values[key].(*models.IntellectualObject); isIntelObj {
+			boltDB.pendingObjects[key] = data
+		} else {
+			boltDB.pendingFiles[key] = data
+		}
+	}
+	if len(boltDB.pendingObjects)+len(boltDB.pendingFiles) >= boltDB.BatchSize {
+		return boltDB.flush()
+	}
+	return nil
+}
+
+// Flush writes all buffered values to the bolt database in a
+// single transaction.
+func (boltDB *BoltDB) Flush() error {
+	boltDB.mutex.Lock()
+	defer boltDB.mutex.Unlock()
+	return boltDB.flush()
+}
+
+// flush writes buffered values. Caller must hold the mutex. If the
+// write fails, the values stay in the buffer, so the next flush
+// can try again.
+func (boltDB *BoltDB) flush() error {
+	if len(boltDB.pendingObjects) == 0 && len(boltDB.pendingFiles) == 0 {
+		return nil
+	}
+	err := boltDB.db.Update(func(tx *bolt.Tx) error {
+		for bucketName, pending := range map[string]map[string][]byte{
+			OBJ_BUCKET:  boltDB.pendingObjects,
+			FILE_BUCKET: boltDB.pendingFiles,
+		} {
 			bucket := tx.Bucket([]byte(bucketName))
-			err := bucket.Put([]byte(key), buf.Bytes())
-			return err
-		})
+			for key, data := range pending {
+				if err := bucket.Put([]byte(key), data); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		boltDB.pendingObjects = make(map[string][]byte)
+		boltDB.pendingFiles = make(map[string][]byte)
 	}
 	return err
 }
 
+// pendingValue returns the buffered value for key in the specified
+// bucket, or nil if there is none.
+func (boltDB *BoltDB) pendingValue(bucketName, key string) []byte {
+	boltDB.mutex.Lock()
+	defer boltDB.mutex.Unlock()
+	if bucketName == OBJ_BUCKET {
+		return boltDB.pendingObjects[key]
+	}
+	return boltDB.pendingFiles[key]
+}
+
 // GetIntellectualObject returns the IntellectualObject that matches
 // the specified key. This object will NOT include GenericFiles.
 // There may be tens of thousands of those, so you have to fetch
@@ -115,6 +205,10 @@ func (boltDB *BoltDB) Save(key string, value interface{}) error {
 func (boltDB *BoltDB) GetIntellectualObject(key string) (*models.IntellectualObject, error) {
 	var err error
 	obj := &models.IntellectualObject{}
+	if value := boltDB.pendingValue(OBJ_BUCKET, key); value != nil {
+		err = gob.NewDecoder(bytes.NewBuffer(value)).Decode(obj)
+		return obj, err
+	}
 	err = boltDB.db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(OBJ_BUCKET))
 		value := bucket.Get([]byte(key))
@@ -137,6 +231,10 @@ func (boltDB *BoltDB) GetIntellectualObject(key string) (*models.IntellectualObj
 func (boltDB *BoltDB) GetGenericFile(key string) (*models.GenericFile, error) {
 	var err error
 	gf := &models.GenericFile{}
+	if value := boltDB.pendingValue(FILE_BUCKET, key); value != nil {
+		err = gob.NewDecoder(bytes.NewBuffer(value)).Decode(gf)
+		return gf, err
+	}
 	err = boltDB.db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(FILE_BUCKET))
 		value := bucket.Get([]byte(key))
@@ -152,10 +250,50 @@ func (boltDB *BoltDB) GetGenericFile(key string) (*models.GenericFile, error) {
 	return gf, err
 }
 
+// GlacierRequestCounts returns the Glacier retrieval request counts
+// saved by SaveGlacierRequestCounts, or an empty map if none have been
+// saved. This lets a models.GlacierRequestTracker keep its counts in
+// the DB, so they survive a worker restart.
+func (boltDB *BoltDB) GlacierRequestCounts() (map[string]map[int]int, error) {
+	counts := make(map[string]map[int]int)
+	err := boltDB.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(GLACIER_REQUEST_BUCKET))
+		if bucket == nil {
+			return nil
+		}
+		value := bucket.Get([]byte(glacierRequestCountsKey))
+		if len(value) == 0 {
+			return nil
+		}
+		return gob.NewDecoder(bytes.NewBuffer(value)).Decode(&counts)
+	})
+	return counts, err
+}
+
+// SaveGlacierRequestCounts saves the Glacier retrieval request counts
+// of a models.GlacierRequestTracker, replacing any saved earlier. This
+// writes immediately, regardless of BatchSize.
+func (boltDB *BoltDB) SaveGlacierRequestCounts(counts map[string]map[int]int) error {
+	buf := bytes.NewBuffer(make([]byte, 0))
+	if err := gob.NewEncoder(buf).Encode(counts); err != nil {
+		return err
+	}
+	return boltDB.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(GLACIER_REQUEST_BUCKET))
+		if err != nil {
+			return fmt.Errorf("Error creating Glacier request bucket: %s", err)
+		}
+		return bucket.Put([]byte(glacierRequestCountsKey), buf.Bytes())
+	})
+}
+
 // ForEach calls the specified function for each key in the database's
 // file bucket.
 func (boltDB *BoltDB) ForEach(fn func(k, v []byte) error) error {
 	var err error
+	if err = boltDB.Flush(); err != nil {
+		return err
+	}
 	return boltDB.db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(FILE_BUCKET))
 		err = bucket.ForEach(fn)
@@ -168,6 +306,7 @@ func (boltDB *BoltDB) ForEach(fn func(k, v []byte) error) error {
 
 // FileIdentifiers returns a list of all keys in the database.
 func (boltDB *BoltDB) FileIdentifiers() []string {
+	boltDB.Flush()
 	keys := make([]string, 0)
 	boltDB.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(FILE_BUCKET))
@@ -182,6 +321,7 @@ func (boltDB *BoltDB) FileIdentifiers() []string {
 
 // FileCount returns the number of GenericFiles stored in the database.
 func (boltDB *BoltDB) FileCount() int {
+	boltDB.Flush()
 	count := 0
 	boltDB.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(FILE_BUCKET))
@@ -198,6 +338,7 @@ func (boltDB *BoltDB) FileCount() int {
 // identifiers from offset (zero-based) up to limit,
 // or end of list.
 func (boltDB *BoltDB) FileIdentifierBatch(offset, limit int) []string {
+	boltDB.Flush()
 	if offset < 0 {
 		offset = 0
 	}
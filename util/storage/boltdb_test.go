@@ -115,6 +115,123 @@ func TestBoltDB_FileIdentifierBatch(t *testing.T) {
 	assert.Equal(t, 0, len(batch))
 }
 
+func TestBoltDB_SaveBatch(t *testing.T) {
+	tempFile, err := ioutil.TempFile("", "boltdb_test")
+	require.Nil(t, err)
+	defer tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	bolt, err := storage.NewBoltDB(tempFile.Name())
+	require.Nil(t, err)
+	defer bolt.Close()
+
+	values := make(map[string]interface{})
+	values["Test Object"] = testutil.MakeIntellectualObject(1, 1, 1, 10)
+	for i := 0; i < 20; i++ {
+		gfId := fmt.Sprintf("uc.edu/bag/data/file_%02d.json", i)
+		gf := testutil.MakeGenericFile(2, 2, "uc.edu/bag")
+		gf.Identifier = gfId
+		values[gfId] = gf
+	}
+	require.Nil(t, bolt.SaveBatch(values))
+
+	assert.Equal(t, "Test Object", bolt.ObjectIdentifier())
+	assert.Equal(t, 20, bolt.FileCount())
+	gf, err := bolt.GetGenericFile("uc.edu/bag/data/file_07.json")
+	require.Nil(t, err)
+	require.NotNil(t, gf)
+	assert.Equal(t, "uc.edu/bag/data/file_07.json", gf.Identifier)
+
+	// Empty batch is a no-op.
+	assert.Nil(t, bolt.SaveBatch(make(map[string]interface{})))
+}
+
+func TestBoltDB_BatchSize(t *testing.T) {
+	tempFile, err := ioutil.TempFile("", "boltdb_test")
+	require.Nil(t, err)
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	bolt, err := storage.NewBoltDB(tempFile.Name())
+	require.Nil(t, err)
+	bolt.BatchSize = 5
+
+	obj := testutil.MakeIntellectualObject(1, 1, 1, 10)
+	require.Nil(t, bolt.Save(obj.Identifier, obj))
+	identifiers := make([]string, 7)
+	for i := range identifiers {
+		gf := testutil.MakeGenericFile(2, 2, fmt.Sprintf("%s/file_%d.txt", obj.Identifier, i))
+		require.Nil(t, bolt.Save(gf.Identifier, gf))
+		identifiers[i] = gf.Identifier
+	}
+
+	// Buffered values are visible before they're written...
+	restoredObj, err := bolt.GetIntellectualObject(obj.Identifier)
+	require.Nil(t, err)
+	require.NotNil(t, restoredObj)
+	assert.Equal(t, obj.Identifier, restoredObj.Identifier)
+	restoredFile, err := bolt.GetGenericFile(identifiers[6])
+	require.Nil(t, err)
+	require.NotNil(t, restoredFile)
+	assert.Equal(t, identifiers[6], restoredFile.Identifier)
+
+	// ...and to calls that scan the DB.
+	assert.Equal(t, 7, bolt.FileCount())
+	assert.Equal(t, obj.Identifier, bolt.ObjectIdentifier())
+
+	// The last value saved wins.
+	restoredFile.Size = 999
+	require.Nil(t, bolt.Save(restoredFile.Identifier, restoredFile))
+	restoredFile, err = bolt.GetGenericFile(identifiers[6])
+	require.Nil(t, err)
+	assert.EqualValues(t, 999, restoredFile.Size)
+
+	// Close writes what's left in the buffer.
+	bolt.Close()
+	bolt, err = storage.NewBoltDB(tempFile.Name())
+	require.Nil(t, err)
+	defer bolt.Close()
+	assert.Equal(t, identifiers, bolt.FileIdentifiers())
+	restoredFile, err = bolt.GetGenericFile(identifiers[6])
+	require.Nil(t, err)
+	require.NotNil(t, restoredFile)
+	assert.EqualValues(t, 999, restoredFile.Size)
+}
+
+func TestBoltDB_GlacierRequestCounts(t *testing.T) {
+	tempFile, err := ioutil.TempFile("", "boltdb_test")
+	require.Nil(t, err)
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	db, err := storage.NewBoltDB(tempFile.Name())
+	require.Nil(t, err)
+	counts, err := db.GlacierRequestCounts()
+	require.Nil(t, err)
+	assert.Empty(t, counts)
+
+	tracker, err := models.NewPersistentGlacierRequestTracker(3, db)
+	require.Nil(t, err)
+	tracker.SetInFlight("test.edu", 100, 2)
+	assert.True(t, tracker.Reserve("test.edu", 200))
+	assert.True(t, tracker.Reserve("example.edu", 300))
+	tracker.Remove("example.edu", 300)
+	assert.Nil(t, tracker.SaveError())
+	db.Close()
+
+	// A tracker opened on the same file after a restart picks up
+	// where the last one left off.
+	db, err = storage.NewBoltDB(tempFile.Name())
+	require.Nil(t, err)
+	defer db.Close()
+	tracker, err = models.NewPersistentGlacierRequestTracker(3, db)
+	require.Nil(t, err)
+	assert.Equal(t, 3, tracker.InFlight("test.edu"))
+	assert.Equal(t, 0, tracker.InFlight("example.edu"))
+	assert.False(t, tracker.Reserve("test.edu", 400))
+	assert.Equal(t, map[int]string{100: "test.edu", 200: "test.edu"}, tracker.WorkItems())
+}
+
 func TestBoltDB_DumpJson(t *testing.T) {
 	tempFile, err := ioutil.TempFile("", "boltdb_test")
 	require.Nil(t, err)
@@ -165,3 +282,35 @@ func TestBoltDB_DumpJson(t *testing.T) {
 		assert.Equal(t, 2, len(gf.Checksums))
 	}
 }
+
+func benchmarkBoltDBSave(b *testing.B, batchSize int) {
+	tempFile, err := ioutil.TempFile("", "boltdb_bench")
+	require.Nil(b, err)
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	bolt, err := storage.NewBoltDB(tempFile.Name())
+	require.Nil(b, err)
+	defer bolt.Close()
+	bolt.BatchSize = batchSize
+
+	gf := testutil.MakeGenericFile(2, 2, "test.edu/bag/data/file.txt")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		gf.Identifier = fmt.Sprintf("test.edu/bag/data/file_%09d.txt", i)
+		if err := bolt.Save(gf.Identifier, gf); err != nil {
+			b.Fatal(err)
+		}
+	}
+	require.Nil(b, bolt.Flush())
+}
+
+// Compare these to see what batching buys for bags with many
+// small files.
+func BenchmarkBoltDBSaveUnbuffered(b *testing.B) {
+	benchmarkBoltDBSave(b, 0)
+}
+
+func BenchmarkBoltDBSaveBatchSize1000(b *testing.B) {
+	benchmarkBoltDBSave(b, 1000)
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/models"
+	"io"
+	"strconv"
+)
+
+// BUNDLE_VERSION is the version of the validation bundle format
+// written by ExportBundle. ImportBundle rejects bundles with a
+// newer version.
+const BUNDLE_VERSION = 1
+
+// bundleBatchSize is the number of GenericFiles ImportBundle
+// saves in each transaction.
+const bundleBatchSize = 500
+
+// bundleRecord is a single record in a validation bundle. The first
+// record in a bundle contains the BundleVersion and the
+// IntellectualObject. Each record after that contains one GenericFile.
+type bundleRecord struct {
+	BundleVersion      int                        `json:"bundle_version,omitempty"`
+	IntellectualObject *models.IntellectualObject `json:"intellectual_object,omitempty"`
+	GenericFile        *models.GenericFile        `json:"generic_file,omitempty"`
+}
+
+// ExportBundle writes the contents of a validation DB to writer as a
+// validation bundle: a stream of JSON records, one per line, that can
+// be read back in by ImportBundle. Unlike the .valdb file itself, the
+// bundle does not depend on the BoltDB file format or the version of
+// the software that wrote it, so depositors can send us a bundle to
+// help troubleshoot validation problems without sending us the bag.
+// Param objIdentifier is the identifier of the IntellectualObject in db.
+func ExportBundle(db ValidationDB, objIdentifier string, writer io.Writer) error {
+	obj, err := db.GetIntellectualObject(objIdentifier)
+	if err != nil {
+		return fmt.Errorf("Can't get object from db: %v", err)
+	}
+	if obj == nil {
+		return fmt.Errorf("Object %s is not in the db", objIdentifier)
+	}
+	encoder := json.NewEncoder(writer)
+	err = encoder.Encode(&bundleRecord{BundleVersion: BUNDLE_VERSION, IntellectualObject: obj})
+	if err != nil {
+		return fmt.Errorf("Error writing object: %v", err)
+	}
+	for _, gfIdentifier := range db.FileIdentifiers() {
+		gf, err := db.GetGenericFile(gfIdentifier)
+		if err != nil {
+			return fmt.Errorf("Can't get GenericFile %s from db: %v", gfIdentifier, err)
+		}
+		err = encoder.Encode(&bundleRecord{GenericFile: gf})
+		if err != nil {
+			return fmt.Errorf("Error writing GenericFile %s: %v", gfIdentifier, err)
+		}
+	}
+	return nil
+}
+
+// ImportBundle reads a validation bundle written by ExportBundle
+// and saves its IntellectualObject and GenericFiles to db. It returns
+// the identifier of the IntellectualObject and the number of
+// GenericFiles imported.
+func ImportBundle(reader io.Reader, db ValidationDB) (objIdentifier string, fileCount int, err error) {
+	decoder := json.NewDecoder(reader)
+	header := &bundleRecord{}
+	if err = decoder.Decode(header); err != nil {
+		return "", 0, fmt.Errorf("Can't read bundle header: %v", err)
+	}
+	if header.BundleVersion < 1 || header.IntellectualObject == nil {
+		return "", 0, fmt.Errorf("Bundle header is missing version or object. " +
+			"This does not look like a validation bundle.")
+	}
+	if header.BundleVersion > BUNDLE_VERSION {
+		return "", 0, fmt.Errorf("Bundle version %d is newer than the newest version "+
+			"this program can read (%d)", header.BundleVersion, BUNDLE_VERSION)
+	}
+	obj := header.IntellectualObject
+	if err = db.Save(obj.Identifier, obj); err != nil {
+		return "", 0, fmt.Errorf("Can't save object %s: %v", obj.Identifier, err)
+	}
+	batch := make(map[string]interface{})
+	for {
+		record := &bundleRecord{}
+		err = decoder.Decode(record)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return obj.Identifier, fileCount, fmt.Errorf("Can't read record %d: %v", fileCount+2, err)
+		}
+		if record.GenericFile == nil {
+			return obj.Identifier, fileCount, fmt.Errorf("Record %d has no GenericFile", fileCount+2)
+		}
+		batch[record.GenericFile.Identifier] = record.GenericFile
+		fileCount++
+		if len(batch) == bundleBatchSize {
+			if err = db.SaveBatch(batch); err != nil {
+				return obj.Identifier, fileCount, err
+			}
+			batch = make(map[string]interface{})
+		}
+	}
+	if len(batch) > 0 {
+		if err = db.SaveBatch(batch); err != nil {
+			return obj.Identifier, fileCount, err
+		}
+	}
+	return obj.Identifier, fileCount, nil
+}
+
+// ExportCSV writes a summary of each GenericFile in db to writer in
+// CSV format, with a header row. This includes each file's type and
+// size, and the digests the validator calculated alongside the digests
+// in the manifests. It's easier to read than a bundle, but it can't be
+// imported.
+func ExportCSV(db ValidationDB, writer io.Writer) error {
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Write([]string{
+		"identifier", "file_type", "size",
+		"md5", "manifest_md5",
+		"sha256", "manifest_sha256",
+		"sha512", "manifest_sha512",
+		"blake2b", "manifest_blake2b",
+	})
+	for _, gfIdentifier := range db.FileIdentifiers() {
+		gf, err := db.GetGenericFile(gfIdentifier)
+		if err != nil {
+			return fmt.Errorf("Can't get GenericFile %s from db: %v", gfIdentifier, err)
+		}
+		csvWriter.Write([]string{
+			gf.Identifier, gf.IngestFileType, strconv.FormatInt(gf.Size, 10),
+			gf.IngestMd5, gf.IngestManifestMd5,
+			gf.IngestSha256, gf.IngestManifestSha256,
+			gf.IngestSha512, gf.IngestManifestSha512,
+			gf.IngestBlake2b, gf.IngestManifestBlake2b,
+		})
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,100 @@
+package storage_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/APTrust/exchange/util/storage"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func makeBundleTestDB(t *testing.T, fileCount int) (*storage.MemoryDB, string) {
+	db := storage.NewMemoryDB()
+	obj := testutil.MakeIntellectualObject(0, 1, 1, 5)
+	require.Nil(t, db.Save(obj.Identifier, obj))
+	values := make(map[string]interface{})
+	for i := 0; i < fileCount; i++ {
+		gf := testutil.MakeGenericFile(0, 0, obj.Identifier)
+		gf.Identifier = fmt.Sprintf("%s/data/file_%04d.txt", obj.Identifier, i)
+		gf.IngestMd5 = fmt.Sprintf("md5-%d", i)
+		gf.IngestManifestMd5 = fmt.Sprintf("md5-%d", i)
+		values[gf.Identifier] = gf
+	}
+	require.Nil(t, db.SaveBatch(values))
+	return db, obj.Identifier
+}
+
+func TestExportImportBundle(t *testing.T) {
+	// More files than one import batch.
+	src, objIdentifier := makeBundleTestDB(t, 1234)
+	defer src.Close()
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, storage.ExportBundle(src, objIdentifier, buf))
+	assert.Equal(t, 1235, strings.Count(buf.String(), "\n"))
+
+	dest := storage.NewMemoryDB()
+	defer dest.Close()
+	importedId, fileCount, err := storage.ImportBundle(buf, dest)
+	require.Nil(t, err)
+	assert.Equal(t, objIdentifier, importedId)
+	assert.Equal(t, 1234, fileCount)
+	assert.Equal(t, src.FileIdentifiers(), dest.FileIdentifiers())
+
+	srcObj, _ := src.GetIntellectualObject(objIdentifier)
+	destObj, err := dest.GetIntellectualObject(objIdentifier)
+	require.Nil(t, err)
+	require.NotNil(t, destObj)
+	assert.Equal(t, srcObj.Title, destObj.Title)
+	assert.Equal(t, len(srcObj.IngestTags), len(destObj.IngestTags))
+
+	gf, err := dest.GetGenericFile(objIdentifier + "/data/file_0007.txt")
+	require.Nil(t, err)
+	require.NotNil(t, gf)
+	assert.Equal(t, "md5-7", gf.IngestMd5)
+}
+
+func TestExportBundle_MissingObject(t *testing.T) {
+	db := storage.NewMemoryDB()
+	defer db.Close()
+	err := storage.ExportBundle(db, "test.edu/no-such-bag", &bytes.Buffer{})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not in the db")
+}
+
+func TestImportBundle_BadInput(t *testing.T) {
+	db := storage.NewMemoryDB()
+	defer db.Close()
+
+	_, _, err := storage.ImportBundle(strings.NewReader("not json"), db)
+	assert.NotNil(t, err)
+
+	_, _, err = storage.ImportBundle(strings.NewReader(`{"generic_file":{}}`), db)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "does not look like a validation bundle")
+
+	newer := fmt.Sprintf(`{"bundle_version":%d,"intellectual_object":{}}`, storage.BUNDLE_VERSION+1)
+	_, _, err = storage.ImportBundle(strings.NewReader(newer), db)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "is newer than")
+}
+
+func TestExportCSV(t *testing.T) {
+	db, _ := makeBundleTestDB(t, 3)
+	defer db.Close()
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, storage.ExportCSV(db, buf))
+	records, err := csv.NewReader(buf).ReadAll()
+	require.Nil(t, err)
+	require.Equal(t, 4, len(records))
+	assert.Equal(t, "identifier", records[0][0])
+	assert.Equal(t, 11, len(records[0]))
+	assert.True(t, strings.HasSuffix(records[1][0], "/data/file_0000.txt"))
+	assert.Equal(t, "md5-0", records[1][3])
+	assert.Equal(t, "md5-0", records[1][4])
+}
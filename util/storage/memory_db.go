@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"github.com/APTrust/exchange/models"
+	"sort"
+	"sync"
+)
+
+// MemoryDB is a ValidationDB that keeps its data in memory. It's much
+// faster than BoltDB for small bags, and it leaves nothing on disk. As
+// the BoltDB docs explain, bags with many files will use too much memory.
+// Like BoltDB, MemoryDB stores encoded copies of the values you save, so
+// changes to a value don't show up in the DB until you save it again.
+type MemoryDB struct {
+	objects map[string][]byte
+	files   map[string][]byte
+	mutex   sync.RWMutex
+}
+
+// NewMemoryDB returns a new, empty MemoryDB.
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		objects: make(map[string][]byte),
+		files:   make(map[string][]byte),
+	}
+}
+
+// Save saves a value to the DB.
+func (memoryDB *MemoryDB) Save(key string, value interface{}) error {
+	return memoryDB.SaveBatch(map[string]interface{}{key: value})
+}
+
+// SaveBatch saves a number of values to the DB. Param values is a map
+// of keys to values. If any value cannot be saved, none of them will be.
+func (memoryDB *MemoryDB) SaveBatch(values map[string]interface{}) error {
+	encoded := make(map[string][]byte, len(values))
+	for key, value := range values {
+		buf := bytes.NewBuffer(make([]byte, 0))
+		if err := gob.NewEncoder(buf).Encode(value); err != nil {
+			return err
+		}
+		encoded[key] = buf.Bytes()
+	}
+	memoryDB.mutex.Lock()
+	defer memoryDB.mutex.Unlock()
+	for key, data := range encoded {
+		if _, isIntelObj := values[key].(*models.IntellectualObject); isIntelObj {
+			memoryDB.objects[key] = data
+		} else {
+			memoryDB.files[key] = data
+		}
+	}
+	return nil
+}
+
+// GetIntellectualObject returns the IntellectualObject that matches
+// the specified key, or nil and no error if the key is not found.
+func (memoryDB *MemoryDB) GetIntellectualObject(key string) (*models.IntellectualObject, error) {
+	memoryDB.mutex.RLock()
+	data, ok := memoryDB.objects[key]
+	memoryDB.mutex.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	obj := &models.IntellectualObject{}
+	err := gob.NewDecoder(bytes.NewBuffer(data)).Decode(obj)
+	return obj, err
+}
+
+// GetGenericFile returns the GenericFile with the specified identifier,
+// or nil and no error if the key is not found.
+func (memoryDB *MemoryDB) GetGenericFile(key string) (*models.GenericFile, error) {
+	memoryDB.mutex.RLock()
+	data, ok := memoryDB.files[key]
+	memoryDB.mutex.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	gf := &models.GenericFile{}
+	err := gob.NewDecoder(bytes.NewBuffer(data)).Decode(gf)
+	return gf, err
+}
+
+// FileIdentifiers returns a sorted list of all GenericFile
+// identifiers in the DB.
+func (memoryDB *MemoryDB) FileIdentifiers() []string {
+	memoryDB.mutex.RLock()
+	defer memoryDB.mutex.RUnlock()
+	keys := make([]string, 0, len(memoryDB.files))
+	for key := range memoryDB.files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FileCount returns the number of GenericFiles in the DB.
+func (memoryDB *MemoryDB) FileCount() int {
+	memoryDB.mutex.RLock()
+	defer memoryDB.mutex.RUnlock()
+	return len(memoryDB.files)
+}
+
+// CopyTo copies everything in this DB to db. The validator uses
+// this to move its data to disk when a bag turns out to have
+// too many files to keep in memory.
+func (memoryDB *MemoryDB) CopyTo(db ValidationDB) error {
+	values := make(map[string]interface{})
+	memoryDB.mutex.RLock()
+	objKeys := make([]string, 0, len(memoryDB.objects))
+	for key := range memoryDB.objects {
+		objKeys = append(objKeys, key)
+	}
+	memoryDB.mutex.RUnlock()
+	for _, key := range objKeys {
+		obj, err := memoryDB.GetIntellectualObject(key)
+		if err != nil {
+			return err
+		}
+		values[key] = obj
+	}
+	for _, key := range memoryDB.FileIdentifiers() {
+		gf, err := memoryDB.GetGenericFile(key)
+		if err != nil {
+			return err
+		}
+		values[key] = gf
+	}
+	return db.SaveBatch(values)
+}
+
+// Flush is a no-op, since MemoryDB doesn't buffer writes.
+func (memoryDB *MemoryDB) Flush() error {
+	return nil
+}
+
+// Close releases the DB's data.
+func (memoryDB *MemoryDB) Close() {
+	memoryDB.mutex.Lock()
+	defer memoryDB.mutex.Unlock()
+	memoryDB.objects = make(map[string][]byte)
+	memoryDB.files = make(map[string][]byte)
+}
@@ -0,0 +1,85 @@
+package storage_test
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/util/storage"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+)
+
+// Both implementations must satisfy the interface the validator uses.
+var _ storage.ValidationDB = (*storage.BoltDB)(nil)
+var _ storage.ValidationDB = (*storage.MemoryDB)(nil)
+
+func TestMemoryDB(t *testing.T) {
+	db := storage.NewMemoryDB()
+	defer db.Close()
+
+	// Save and retrieve an object
+	obj := testutil.MakeIntellectualObject(1, 1, 1, 10)
+	require.Nil(t, db.Save("Test Object", obj))
+	restoredObj, err := db.GetIntellectualObject("Test Object")
+	require.Nil(t, err)
+	require.NotNil(t, restoredObj)
+	assert.Equal(t, obj.Identifier, restoredObj.Identifier)
+
+	nilObj, err := db.GetIntellectualObject("Nil Object")
+	require.Nil(t, err)
+	require.Nil(t, nilObj)
+
+	// Changes don't show up until we save again.
+	restoredObj.Title = "Changed"
+	restoredObj, err = db.GetIntellectualObject("Test Object")
+	require.Nil(t, err)
+	assert.Equal(t, obj.Title, restoredObj.Title)
+
+	// Save and retrieve generic files, in a batch.
+	values := make(map[string]interface{})
+	for i := 9; i >= 0; i-- {
+		gf := testutil.MakeGenericFile(2, 2, "uc.edu/bag")
+		gf.Identifier = fmt.Sprintf("uc.edu/bag/data/file_%02d.json", i)
+		values[gf.Identifier] = gf
+	}
+	require.Nil(t, db.SaveBatch(values))
+	gf, err := db.GetGenericFile("uc.edu/bag/data/file_07.json")
+	require.Nil(t, err)
+	require.NotNil(t, gf)
+	assert.Equal(t, "uc.edu/bag/data/file_07.json", gf.Identifier)
+
+	nilFile, err := db.GetGenericFile("Nil File")
+	require.Nil(t, err)
+	require.Nil(t, nilFile)
+
+	// Like BoltDB, file identifiers are sorted and don't include objects.
+	gfIds := db.FileIdentifiers()
+	require.Equal(t, 10, len(gfIds))
+	assert.True(t, sort.StringsAreSorted(gfIds))
+	assert.Equal(t, 10, db.FileCount())
+}
+
+func TestMemoryDB_CopyTo(t *testing.T) {
+	tempFile, err := ioutil.TempFile("", "memorydb_test")
+	require.Nil(t, err)
+	defer tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	db := storage.NewMemoryDB()
+	require.Nil(t, db.Save("Test Object", testutil.MakeIntellectualObject(1, 1, 1, 10)))
+	for i := 0; i < 5; i++ {
+		gf := testutil.MakeGenericFile(2, 2, "uc.edu/bag")
+		gf.Identifier = fmt.Sprintf("uc.edu/bag/data/file_%02d.json", i)
+		require.Nil(t, db.Save(gf.Identifier, gf))
+	}
+
+	bolt, err := storage.NewBoltDB(tempFile.Name())
+	require.Nil(t, err)
+	defer bolt.Close()
+	require.Nil(t, db.CopyTo(bolt))
+	assert.Equal(t, "Test Object", bolt.ObjectIdentifier())
+	assert.Equal(t, db.FileIdentifiers(), bolt.FileIdentifiers())
+}
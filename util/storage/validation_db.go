@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"github.com/APTrust/exchange/models"
+)
+
+// ValidationDB is the interface the validator uses to track information
+// about an IntellectualObject and the files in its bag. BoltDB keeps this
+// information in a file on disk, which can handle bags with millions of
+// files. MemoryDB keeps it in memory, which is faster for small bags.
+type ValidationDB interface {
+	// Save saves an IntellectualObject or GenericFile.
+	Save(key string, value interface{}) error
+	// SaveBatch saves a number of values at once.
+	SaveBatch(values map[string]interface{}) error
+	// GetIntellectualObject returns the IntellectualObject with the
+	// specified identifier, or nil if it's not in the DB.
+	GetIntellectualObject(key string) (*models.IntellectualObject, error)
+	// GetGenericFile returns the GenericFile with the specified
+	// identifier, or nil if it's not in the DB.
+	GetGenericFile(key string) (*models.GenericFile, error)
+	// FileIdentifiers returns the identifiers of all GenericFiles
+	// in the DB, in sorted order.
+	FileIdentifiers() []string
+	// FileCount returns the number of GenericFiles in the DB.
+	FileCount() int
+	// Flush writes any values the DB has buffered, and returns
+	// the error, if any, from writing them.
+	Flush() error
+	// Close closes the DB.
+	Close()
+}
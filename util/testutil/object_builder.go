@@ -0,0 +1,170 @@
+package testutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/icrowley/fake"
+)
+
+// checksumDigestLengths is the number of characters in the digests
+// ObjectBuilder makes for each algorithm.
+var checksumDigestLengths = map[string]int{
+	constants.AlgMd5:     32,
+	constants.AlgSha256:  64,
+	constants.AlgSha512:  128,
+	constants.AlgBlake2b: 128,
+}
+
+// ObjectBuilder builds IntellectualObjects for tests, so tests can
+// describe exactly the object they need instead of changing a random
+// object after the fact. For example:
+//
+//	obj := testutil.NewObjectBuilder().
+//		WithStorageOption(constants.StorageGlacierOH).
+//		WithFileCount(12).
+//		WithChecksums(constants.AlgMd5, constants.AlgSha256).
+//		Build()
+//
+// Anything the builder isn't told is random, as with
+// MakeIntellectualObject.
+type ObjectBuilder struct {
+	identifier      string
+	storageOption   string
+	fileCount       int
+	fileNamePattern string
+	fileSize        int64
+	eventCount      int
+	tagCount        int
+	algorithms      []string
+}
+
+// NewObjectBuilder returns a builder for an object with a random
+// identifier, standard storage and no files.
+func NewObjectBuilder() *ObjectBuilder {
+	return &ObjectBuilder{
+		identifier:    RandomObjectIdentifier(),
+		storageOption: constants.StorageStandard,
+		algorithms:    make([]string, 0),
+	}
+}
+
+// WithIdentifier sets the object identifier, which must look like
+// "institution.edu/bag_name".
+func (builder *ObjectBuilder) WithIdentifier(identifier string) *ObjectBuilder {
+	builder.identifier = identifier
+	return builder
+}
+
+// WithStorageOption sets the storage option of the object and
+// all of its files.
+func (builder *ObjectBuilder) WithStorageOption(storageOption string) *ObjectBuilder {
+	builder.storageOption = storageOption
+	return builder
+}
+
+// WithFileCount sets the number of GenericFiles in the object.
+func (builder *ObjectBuilder) WithFileCount(count int) *ObjectBuilder {
+	builder.fileCount = count
+	return builder
+}
+
+// WithFileNames names the files by formatting pattern with each
+// file's index, starting at zero. For example, "data/file_%d.txt"
+// gives the object's first file the identifier
+// "institution.edu/bag_name/data/file_0.txt". Without this,
+// file names are random.
+func (builder *ObjectBuilder) WithFileNames(pattern string) *ObjectBuilder {
+	builder.fileNamePattern = pattern
+	return builder
+}
+
+// WithFileSize sets the size of each file. The object's FileSize
+// is the total. Without this, file sizes are random.
+func (builder *ObjectBuilder) WithFileSize(size int64) *ObjectBuilder {
+	builder.fileSize = size
+	return builder
+}
+
+// WithEventCount sets the number of PremisEvents on the object and
+// on each of its files.
+func (builder *ObjectBuilder) WithEventCount(count int) *ObjectBuilder {
+	builder.eventCount = count
+	return builder
+}
+
+// WithTagCount sets the number of tags on the object.
+func (builder *ObjectBuilder) WithTagCount(count int) *ObjectBuilder {
+	builder.tagCount = count
+	return builder
+}
+
+// WithChecksums gives each file one Checksum for each of the
+// specified algorithms. Each Checksum has a digest of the right
+// length for its algorithm, which matches the file's ingest digest
+// for that algorithm. Supported algorithms are those in
+// constants.ChecksumAlgorithms.
+func (builder *ObjectBuilder) WithChecksums(algorithms ...string) *ObjectBuilder {
+	builder.algorithms = append(builder.algorithms, algorithms...)
+	return builder
+}
+
+// Build returns a new IntellectualObject, as described by the
+// builder. It panics if the builder's identifier or algorithms
+// are invalid, since that's a bug in the test.
+func (builder *ObjectBuilder) Build() *models.IntellectualObject {
+	if len(strings.Split(builder.identifier, "/")) != 2 {
+		panic(fmt.Sprintf("ObjectBuilder: invalid object identifier '%s'", builder.identifier))
+	}
+	obj := makeIntellectualObject(builder.identifier, 0, builder.eventCount, 0, builder.tagCount)
+	obj.StorageOption = builder.storageOption
+	obj.FileCount = builder.fileCount
+	obj.FileSize = 0
+	obj.GenericFiles = make([]*models.GenericFile, builder.fileCount)
+	for i := 0; i < builder.fileCount; i++ {
+		gf := builder.buildGenericFile(obj, i)
+		obj.FileSize += gf.Size
+		obj.GenericFiles[i] = gf
+	}
+	return obj
+}
+
+// buildGenericFile builds the file at the specified index of obj.
+func (builder *ObjectBuilder) buildGenericFile(obj *models.IntellectualObject, index int) *models.GenericFile {
+	gf := MakeGenericFile(builder.eventCount, 0, obj.Identifier)
+	if builder.fileNamePattern != "" {
+		gf.Identifier = fmt.Sprintf("%s/%s", obj.Identifier,
+			fmt.Sprintf(builder.fileNamePattern, index))
+		gf.IngestLocalPath = fmt.Sprintf("/mnt/aptrust/data/%s", gf.Identifier)
+	}
+	gf.IntellectualObjectId = obj.Id
+	gf.StorageOption = builder.storageOption
+	if builder.fileSize > 0 {
+		gf.Size = builder.fileSize
+	}
+	for _, alg := range builder.algorithms {
+		length, ok := checksumDigestLengths[alg]
+		if !ok {
+			panic(fmt.Sprintf("ObjectBuilder: unsupported checksum algorithm '%s'", alg))
+		}
+		digest := fake.CharactersN(length)
+		switch alg {
+		case constants.AlgMd5:
+			digest = gf.IngestMd5
+		case constants.AlgSha256:
+			digest = gf.IngestSha256
+		case constants.AlgSha512:
+			gf.IngestSha512 = digest
+		case constants.AlgBlake2b:
+			gf.IngestBlake2b = digest
+		}
+		checksum := MakeChecksum()
+		checksum.GenericFileId = gf.Id
+		checksum.Algorithm = alg
+		checksum.Digest = digest
+		gf.Checksums = append(gf.Checksums, checksum)
+	}
+	return gf
+}
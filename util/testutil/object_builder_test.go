@@ -0,0 +1,67 @@
+package testutil_test
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestObjectBuilder(t *testing.T) {
+	obj := testutil.NewObjectBuilder().
+		WithIdentifier("test.edu/glacier_bag").
+		WithStorageOption(constants.StorageGlacierOH).
+		WithFileCount(3).
+		WithFileNames("data/file_%d.txt").
+		WithFileSize(100).
+		WithEventCount(2).
+		WithTagCount(1).
+		WithChecksums(constants.AlgMd5, constants.AlgSha256, constants.AlgBlake2b).
+		Build()
+	require.NotNil(t, obj)
+	assert.Equal(t, "test.edu/glacier_bag", obj.Identifier)
+	assert.Equal(t, "test.edu", obj.Institution)
+	assert.Equal(t, "glacier_bag", obj.BagName)
+	assert.Equal(t, constants.StorageGlacierOH, obj.StorageOption)
+	assert.Equal(t, 3, obj.FileCount)
+	assert.EqualValues(t, 300, obj.FileSize)
+	assert.Equal(t, 2, len(obj.PremisEvents))
+	assert.Equal(t, 1, len(obj.IngestTags))
+
+	require.Equal(t, 3, len(obj.GenericFiles))
+	for i, gf := range obj.GenericFiles {
+		assert.Equal(t, fmt.Sprintf("test.edu/glacier_bag/data/file_%d.txt", i), gf.Identifier)
+		assert.Equal(t, obj.Identifier, gf.IntellectualObjectIdentifier)
+		assert.Equal(t, obj.Id, gf.IntellectualObjectId)
+		assert.Equal(t, constants.StorageGlacierOH, gf.StorageOption)
+		assert.EqualValues(t, 100, gf.Size)
+		assert.Equal(t, 2, len(gf.PremisEvents))
+		require.Equal(t, 3, len(gf.Checksums))
+		for _, checksum := range gf.Checksums {
+			assert.Nil(t, checksum.Validate())
+			assert.Equal(t, gf.Id, checksum.GenericFileId)
+		}
+		assert.Equal(t, gf.IngestMd5, gf.GetChecksumByAlgorithm(constants.AlgMd5).Digest)
+		assert.Equal(t, gf.IngestSha256, gf.GetChecksumByAlgorithm(constants.AlgSha256).Digest)
+		assert.Equal(t, gf.IngestBlake2b, gf.GetChecksumByAlgorithm(constants.AlgBlake2b).Digest)
+		assert.Equal(t, 128, len(gf.IngestBlake2b))
+	}
+}
+
+func TestObjectBuilderDefaults(t *testing.T) {
+	obj := testutil.NewObjectBuilder().Build()
+	require.NotNil(t, obj)
+	assert.NotEmpty(t, obj.Identifier)
+	assert.Equal(t, constants.StorageStandard, obj.StorageOption)
+	assert.Empty(t, obj.GenericFiles)
+	assert.Equal(t, 0, obj.FileCount)
+
+	assert.Panics(t, func() {
+		testutil.NewObjectBuilder().WithIdentifier("no_institution").Build()
+	})
+	assert.Panics(t, func() {
+		testutil.NewObjectBuilder().WithFileCount(1).WithChecksums("sha1").Build()
+	})
+}
@@ -1,8 +1,11 @@
 package testutil
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"os"
@@ -157,7 +160,12 @@ func MakeInstitution() *models.Institution {
 }
 
 func MakeIntellectualObject(fileCount, eventCount, checksumCount, tagCount int) *models.IntellectualObject {
-	objIdentifier := RandomObjectIdentifier()
+	return makeIntellectualObject(RandomObjectIdentifier(), fileCount, eventCount, checksumCount, tagCount)
+}
+
+// makeIntellectualObject makes an object with the specified identifier
+// for MakeIntellectualObject and ObjectBuilder.
+func makeIntellectualObject(objIdentifier string, fileCount, eventCount, checksumCount, tagCount int) *models.IntellectualObject {
 	objIdParts := strings.Split(objIdentifier, "/")
 	inst := objIdParts[0]
 	objName := objIdParts[1]
@@ -269,7 +277,7 @@ func MakeWorkSummary() *models.WorkSummary {
 	return &models.WorkSummary{
 		Attempted:     true,
 		AttemptNumber: 1,
-		Errors:        make([]string, 0),
+		Errors:        make([]*models.WorkError, 0),
 		StartedAt:     RandomDateTime(),
 		FinishedAt:    time.Now().UTC(),
 		Retry:         true,
@@ -405,3 +413,51 @@ func GetContext(configFile string) (*context.Context, error) {
 	config.ExpandFilePaths()
 	return context.NewContext(config), nil
 }
+
+// TarToZip writes a zip file at pathToZipFile with the same entries,
+// including directory entries and modification times, as the tar file
+// at pathToTarFile. Tests use this to make zipped copies of the tarred
+// bags in testdata.
+func TarToZip(pathToTarFile, pathToZipFile string) error {
+	tarFile, err := os.Open(pathToTarFile)
+	if err != nil {
+		return err
+	}
+	defer tarFile.Close()
+	zipFile, err := os.Create(pathToZipFile)
+	if err != nil {
+		return err
+	}
+	defer zipFile.Close()
+	zipWriter := zip.NewWriter(zipFile)
+	tarReader := tar.NewReader(tarFile)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		zipHeader, err := zip.FileInfoHeader(header.FileInfo())
+		if err != nil {
+			return err
+		}
+		zipHeader.Name = header.Name
+		zipHeader.Modified = header.ModTime
+		if header.Typeflag == tar.TypeDir {
+			zipHeader.Name = strings.TrimSuffix(header.Name, "/") + "/"
+		} else {
+			zipHeader.Method = zip.Deflate
+		}
+		writer, err := zipWriter.CreateHeader(zipHeader)
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeDir {
+			if _, err = io.Copy(writer, tarReader); err != nil {
+				return err
+			}
+		}
+	}
+	return zipWriter.Close()
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/APTrust/exchange/constants"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -23,6 +24,16 @@ var OwnerOfRestoreBucket = make(map[string]string)
 // as value.
 var RestoreBucketFor = make(map[string]string)
 
+// RestoreBucketPrefixes lists the prefixes of restoration buckets
+// other than constants.RestoreBucketPrefix, such as the ones in
+// Config.RestorationBucketPrefixes. OwnerOf strips these, too,
+// when it falls back to naming patterns.
+var RestoreBucketPrefixes = make([]string, 0)
+
+// This map uses institution id as key, institution identifier as
+// value. For example: 3 = "test.edu"
+var InstitutionIdentifierFor = make(map[int]string)
+
 var reManifest *regexp.Regexp = regexp.MustCompile("^manifest-[A-Za-z0-9]+\\.txt$")
 var reTagManifest *regexp.Regexp = regexp.MustCompile("^tagmanifest-[A-Za-z0-9]+\\.txt$")
 var reLegal *regexp.Regexp = regexp.MustCompile("^[A-Za-z0-9\\-_\\.]+$")
@@ -40,6 +51,14 @@ func OwnerOf(bucketName string) (institution string) {
 		return inst
 	}
 	// Otherwise, fall back to the old method that uses naming patterns.
+	for _, prefix := range RestoreBucketPrefixes {
+		if strings.HasPrefix(bucketName, prefix+"test.") {
+			return strings.Replace(bucketName, prefix+"test.", "", 1)
+		}
+		if strings.HasPrefix(bucketName, prefix) {
+			return strings.Replace(bucketName, prefix, "", 1)
+		}
+	}
 	if bucketName == constants.ReceiveTestBucketPrefix+"edu" {
 		// Actual test.edu receiving bucket for production.
 		// Didn't anticipate this case back in 2014. Oops.
@@ -48,6 +67,12 @@ func OwnerOf(bucketName string) (institution string) {
 		institution = strings.Replace(bucketName, constants.ReceiveTestBucketPrefix, "", 1)
 	} else if strings.HasPrefix(bucketName, constants.ReceiveBucketPrefix) {
 		institution = strings.Replace(bucketName, constants.ReceiveBucketPrefix, "", 1)
+	} else if bucketName == constants.RestoreTestBucketPrefix+"edu" {
+		// Actual test.edu restoration bucket for production.
+		institution = "test.edu"
+	} else if strings.HasPrefix(bucketName, constants.RestoreTestBucketPrefix) {
+		// Buckets from RestorationBucketFor when restoreToTestBuckets is true.
+		institution = strings.Replace(bucketName, constants.RestoreTestBucketPrefix, "", 1)
 	} else if strings.HasPrefix(bucketName, constants.RestoreBucketPrefix) {
 		institution = strings.Replace(bucketName, constants.RestoreBucketPrefix, "", 1)
 	} else if bucketName == "aptrust.test.receiving" || bucketName == "aptrust.integration.test" {
@@ -87,14 +112,31 @@ func BagNameFromTarFileName(pathToTarFile string) string {
 	return CleanBagName(fileName)
 }
 
+// TarSuffix matches the extensions of tarred bags: .tar, or .tar.gz
+// or .tgz for tarred bags that the depositor gzipped.
+var TarSuffix = regexp.MustCompile("\\.(tar|tar\\.gz|tgz)$")
+
+// HasTarSuffix returns true if fileName ends with .tar, .tar.gz or .tgz.
+func HasTarSuffix(fileName string) bool {
+	return TarSuffix.MatchString(fileName)
+}
+
+// SerializedBagSuffix matches the extensions of serialized bags:
+// the TarSuffix extensions, and .zip for zipped bags.
+var SerializedBagSuffix = regexp.MustCompile("\\.(tar|tar\\.gz|tgz|zip)$")
+
+// IsSerializedBag returns true if fileName ends with .tar, .tar.gz,
+// .tgz or .zip.
+func IsSerializedBag(fileName string) bool {
+	return SerializedBagSuffix.MatchString(fileName)
+}
+
 // CleanBagName returns the clean bag name. That's the tar file name minus
-// the tar extension and any ".bagN.ofN" suffix.
+// the serialization extension (.tar, .tar.gz, .tgz or .zip) and any
+// ".bagN.ofN" suffix.
 func CleanBagName(bagName string) string {
 	// Strip the .tar suffix
-	nameWithoutTar := bagName
-	if strings.HasSuffix(bagName, ".tar") {
-		nameWithoutTar = bagName[0 : len(bagName)-4]
-	}
+	nameWithoutTar := SerializedBagSuffix.ReplaceAllString(bagName, "")
 	// Now get rid of the .b001.of200 suffix if this is a multi-part bag.
 	cleanName := constants.MultipartSuffix.ReplaceAll([]byte(nameWithoutTar), []byte(""))
 	return string(cleanName)
@@ -296,6 +338,55 @@ func LooksLikeEscapedControl(str string) bool {
 	return reControl.MatchString(str)
 }
 
+// EncodeManifestPath percent-encodes a file path for inclusion in a
+// BagIt manifest. Per section 2.1.3 of the BagIt spec (RFC 8493),
+// carriage return, line feed and the percent sign itself must be
+// written as %0D, %0A and %25 respectively.
+func EncodeManifestPath(filePath string) string {
+	return manifestPathEncoder.Replace(filePath)
+}
+
+// DecodeManifestPath reverses EncodeManifestPath, converting %0D, %0A
+// and %25 in a path read from a BagIt manifest back to carriage
+// return, line feed and percent. Hex digits are case-insensitive.
+// Other percent sequences are left as they are.
+func DecodeManifestPath(filePath string) string {
+	return manifestPathDecoder.Replace(filePath)
+}
+
+var manifestPathEncoder = strings.NewReplacer(
+	"%", "%25",
+	"\n", "%0A",
+	"\r", "%0D",
+)
+
+var manifestPathDecoder = strings.NewReplacer(
+	"%25", "%",
+	"%0A", "\n",
+	"%0a", "\n",
+	"%0D", "\r",
+	"%0d", "\r",
+)
+
+// ParsePayloadOxum parses the value of a BagIt Payload-Oxum tag,
+// which has the form <octetstream sum>.<stream count>, and returns
+// the byte count and the file count.
+func ParsePayloadOxum(oxum string) (byteCount int64, fileCount int64, err error) {
+	parts := strings.Split(strings.TrimSpace(oxum), ".")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("'%s' should have the form <bytes>.<files>", oxum)
+	}
+	byteCount, err = strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || byteCount < 0 {
+		return 0, 0, fmt.Errorf("'%s' has an invalid byte count", oxum)
+	}
+	fileCount, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || fileCount < 0 {
+		return 0, 0, fmt.Errorf("'%s' has an invalid file count", oxum)
+	}
+	return byteCount, fileCount, nil
+}
+
 // IsGlacierDeepArchive returns true if bucketName matches
 // any of our Glacier Deep Archive storage buckets.
 func IsGlacierDeepArchive(storageOption string) bool {
@@ -19,6 +19,17 @@ func TestOwnerOf(t *testing.T) {
 		t.Error("OwnerOf misidentified restoration bucket owner")
 	}
 	assert.Equal(t, "test.edu", util.OwnerOf("aptrust.receiving.test.edu"))
+	assert.Equal(t, "unc.edu", util.OwnerOf("aptrust.restore.test.unc.edu"))
+	assert.Equal(t, "test.edu", util.OwnerOf("aptrust.restore.test.edu"))
+	assert.Equal(t, "test.edu", util.OwnerOf("aptrust.restore.test.test.edu"))
+}
+
+func TestOwnerOfWithRestoreBucketPrefixes(t *testing.T) {
+	util.RestoreBucketPrefixes = []string{"aptrust.restore-glacier."}
+	defer func() { util.RestoreBucketPrefixes = make([]string, 0) }()
+	assert.Equal(t, "unc.edu", util.OwnerOf("aptrust.restore-glacier.unc.edu"))
+	assert.Equal(t, "unc.edu", util.OwnerOf("aptrust.restore-glacier.test.unc.edu"))
+	assert.Equal(t, "unc.edu", util.OwnerOf("aptrust.restore.unc.edu"))
 }
 
 func TestRestorationBucketFor(t *testing.T) {
@@ -49,6 +60,30 @@ func TestCleanBagName(t *testing.T) {
 		t.Errorf("CleanBagName should have returned '%s', but returned '%s'",
 			expected, actual)
 	}
+	for _, name := range []string{"some.file.tar.gz", "some.file.tgz", "some.file.b1.of2.tgz", "some.file.zip"} {
+		actual = util.CleanBagName(name)
+		if actual != expected {
+			t.Errorf("CleanBagName(%s) should have returned '%s', but returned '%s'",
+				name, expected, actual)
+		}
+	}
+}
+
+func TestHasTarSuffix(t *testing.T) {
+	assert.True(t, util.HasTarSuffix("bag.tar"))
+	assert.True(t, util.HasTarSuffix("bag.tar.gz"))
+	assert.True(t, util.HasTarSuffix("bag.tgz"))
+	assert.False(t, util.HasTarSuffix("bag.gz"))
+	assert.False(t, util.HasTarSuffix("bag.zip"))
+	assert.False(t, util.HasTarSuffix("bag.tar/data"))
+}
+
+func TestIsSerializedBag(t *testing.T) {
+	assert.True(t, util.IsSerializedBag("bag.tar"))
+	assert.True(t, util.IsSerializedBag("bag.tgz"))
+	assert.True(t, util.IsSerializedBag("bag.zip"))
+	assert.False(t, util.IsSerializedBag("bag.7z"))
+	assert.False(t, util.IsSerializedBag("bag"))
 }
 
 func TestMin(t *testing.T) {
@@ -299,6 +334,44 @@ func TestContainsControlCharacter(t *testing.T) {
 	assert.False(t, util.ContainsControlCharacter("./this/is/a/valid/file/name.txt"))
 }
 
+func TestEncodeManifestPath(t *testing.T) {
+	assert.Equal(t, "data/file.txt", util.EncodeManifestPath("data/file.txt"))
+	assert.Equal(t, "data/100%25.txt", util.EncodeManifestPath("data/100%.txt"))
+	assert.Equal(t, "data/line%0Aone%0D.txt", util.EncodeManifestPath("data/line\none\r.txt"))
+	assert.Equal(t, "data/%2525.txt", util.EncodeManifestPath("data/%25.txt"))
+}
+
+func TestDecodeManifestPath(t *testing.T) {
+	assert.Equal(t, "data/file.txt", util.DecodeManifestPath("data/file.txt"))
+	assert.Equal(t, "data/100%.txt", util.DecodeManifestPath("data/100%25.txt"))
+	assert.Equal(t, "data/line\none\r.txt", util.DecodeManifestPath("data/line%0Aone%0D.txt"))
+	assert.Equal(t, "data/line\none\r.txt", util.DecodeManifestPath("data/line%0aone%0d.txt"))
+	assert.Equal(t, "data/%25.txt", util.DecodeManifestPath("data/%2525.txt"))
+	// Sequences the spec doesn't define are left alone.
+	assert.Equal(t, "data/%20.txt", util.DecodeManifestPath("data/%20.txt"))
+
+	// Round trip
+	original := "data/50% of\nall\rfiles%0A.txt"
+	assert.Equal(t, original, util.DecodeManifestPath(util.EncodeManifestPath(original)))
+}
+
+func TestParsePayloadOxum(t *testing.T) {
+	byteCount, fileCount, err := util.ParsePayloadOxum("279164.4")
+	require.Nil(t, err)
+	assert.EqualValues(t, 279164, byteCount)
+	assert.EqualValues(t, 4, fileCount)
+
+	byteCount, fileCount, err = util.ParsePayloadOxum(" 0.0 ")
+	require.Nil(t, err)
+	assert.EqualValues(t, 0, byteCount)
+	assert.EqualValues(t, 0, fileCount)
+
+	for _, bad := range []string{"", "1234", "1.2.3", "abc.4", "123.x", "-1.4"} {
+		_, _, err = util.ParsePayloadOxum(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
+
 func TestLooksLikeEscapedControl(t *testing.T) {
 	assert.True(t, util.LooksLikeEscapedControl("\\u0000 -- NULL"))
 	assert.True(t, util.LooksLikeEscapedControl("\\u0001 -- START OF HEADING"))
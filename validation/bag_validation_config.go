@@ -6,6 +6,7 @@ import (
 	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/util"
 	"github.com/APTrust/exchange/util/fileutil"
+	"golang.org/x/text/unicode/norm"
 	"io/ioutil"
 	"path/filepath"
 	"regexp"
@@ -20,6 +21,14 @@ const (
 
 var presenceValues = []string{REQUIRED, OPTIONAL, FORBIDDEN}
 
+// Unicode normalization policies for matching manifest entries
+// to files in the bag. See BagValidationConfig.UnicodeNormalization.
+const (
+	NormalizeNFC  = "NFC"
+	NormalizeNFD  = "NFD"
+	NormalizeNone = "none"
+)
+
 // FileSpec defines whether files at a specified path within
 // the bag are required, optional, or forbidden.
 type FileSpec struct {
@@ -48,6 +57,40 @@ type TagSpec struct {
 	EmptyOK bool
 	// Describes which values are allowed (case-insensitive).
 	AllowedValues []string
+	// ValuePattern is a regex that the tag's value must match,
+	// such as `^\d{4}-\d{2}-\d{2}$` for a date. If the spec also
+	// has AllowedValues, the value must satisfy both.
+	ValuePattern string
+	// Regex compiled internally from ValuePattern.
+	ValueRegex *regexp.Regexp
+	// Deprecated means the validator still accepts the tag, but
+	// warns that it may not in the future.
+	Deprecated bool
+}
+
+// ValueError returns a description of what's wrong with value, or an
+// empty string if value is acceptable for a tag described by this spec.
+// Call BagValidationConfig.CompileTagValueRegexes before calling this,
+// so that ValuePattern is compiled.
+func (tagspec *TagSpec) ValueError(tagName, value string) string {
+	value = strings.TrimSpace(value)
+	if len(tagspec.AllowedValues) > 0 {
+		valueOk := false
+		for _, allowed := range tagspec.AllowedValues {
+			if strings.ToLower(strings.TrimSpace(allowed)) == strings.ToLower(value) {
+				valueOk = true
+				break
+			}
+		}
+		if !valueOk {
+			return fmt.Sprintf("Tag '%s' has illegal value '%s'.", tagName, value)
+		}
+	}
+	if tagspec.ValueRegex != nil && !tagspec.ValueRegex.MatchString(value) {
+		return fmt.Sprintf("Tag '%s' has value '%s', which does not match pattern '%s'.",
+			tagName, value, tagspec.ValuePattern)
+	}
+	return ""
 }
 
 // Valid tells you whether this TagSpec is valid.
@@ -91,7 +134,13 @@ type BagValidationConfig struct {
 	// name. E.g. Must my_bag.tar untar to a directory called my_tar?
 	TopLevelDirMustMatchBagName bool
 	// Which fixity algorithms should we calculate on tag and
-	// payload files?
+	// payload files? Include sha1 to verify the sha1 manifests
+	// some legacy bags have, and blake2b to verify blake2b
+	// manifests. Otherwise, the validator ignores them.
+	// Include crc32c or xxh64 to verify those manifests as well.
+	// They're fast but not cryptographic, so they supplement md5,
+	// sha256, sha512 or blake2b, which must also be listed. See
+	// Validator.PreScreen.
 	FixityAlgorithms []string
 	// Regex to describe valid file and directory names.
 	// This can also be set to APTRUST to use the standard APTrust
@@ -100,6 +149,39 @@ type BagValidationConfig struct {
 	FileNamePattern string
 	// Regex compiled internally from FileNamePattern.
 	FileNameRegex *regexp.Regexp
+	// Serialization describes whether the bag must be tarred.
+	// This can be REQUIRED, OPTIONAL, or FORBIDDEN. If empty,
+	// the bag may be tarred or untarred.
+	Serialization string
+	// AcceptSerialization lists the MIME types of acceptable
+	// serializations, such as "application/x-tar". If empty,
+	// plain tarred bags are acceptable, but gzipped tarred bags
+	// are acceptable only if this includes one of GzipTarMimeTypes,
+	// such as "application/gzip", and zipped bags only if this
+	// includes one of ZipMimeTypes, such as "application/zip".
+	AcceptSerialization []string
+	// SpecialFilePolicy describes what to do with symlinks, hard links,
+	// devices and sparse files in the bag. If nil, the validator uses
+	// fileutil.DefaultSpecialFilePolicy.
+	SpecialFilePolicy *fileutil.SpecialFilePolicy
+	// UnicodeNormalization describes how the validator matches file
+	// paths in manifests to the files in the bag when they don't match
+	// exactly. Bags made on macOS often have NFD file names and NFC
+	// manifests. NFC (the default if empty) and NFD normalize both
+	// sides to that form before comparing them. "none" requires an
+	// exact match.
+	UnicodeNormalization string
+	// MaxBagSize is the largest total size, in bytes, of all the
+	// files in a valid bag. Zero means no limit.
+	MaxBagSize int64
+	// MaxFileCount is the largest number of files, including tag
+	// files and manifests, in a valid bag. Zero means no limit.
+	MaxFileCount int64
+	// InstitutionChecks lists extra checks for bags from specific
+	// institutions, which run after the standard checks. The key is
+	// the institution identifier, such as "virginia.edu", and each
+	// CheckSpec names a Check registered with RegisterCheck.
+	InstitutionChecks map[string][]CheckSpec
 }
 
 func NewBagValidationConfig() *BagValidationConfig {
@@ -122,9 +204,121 @@ func (config *BagValidationConfig) ValidateConfig() []error {
 				tagSpec.FilePath))
 		}
 	}
+	if config.Serialization != "" && !ValidPresenceValue(config.Serialization) {
+		errors = append(errors, fmt.Errorf(
+			"Serialization '%s' is not a valid presence value.",
+			config.Serialization))
+	}
+	if config.SpecialFilePolicy != nil {
+		if err := config.SpecialFilePolicy.Validate(); err != nil {
+			errors = append(errors, err)
+		}
+	}
+	if config.MaxBagSize < 0 {
+		errors = append(errors, fmt.Errorf("MaxBagSize cannot be negative."))
+	}
+	if config.MaxFileCount < 0 {
+		errors = append(errors, fmt.Errorf("MaxFileCount cannot be negative."))
+	}
+	if (util.StringListContains(config.FixityAlgorithms, constants.AlgCrc32c) ||
+		util.StringListContains(config.FixityAlgorithms, constants.AlgXxh64)) &&
+		!config.hasCryptographicFixity() {
+		errors = append(errors, fmt.Errorf(
+			"FixityAlgorithms includes crc32c or xxh64, which cannot be the only fixity check. "+
+				"Add md5, sha256, sha512 or blake2b."))
+	}
+	switch config.UnicodeNormalization {
+	case "", NormalizeNFC, NormalizeNFD, NormalizeNone:
+	default:
+		errors = append(errors, fmt.Errorf(
+			"UnicodeNormalization '%s' is not valid. Use %s, %s or %s.",
+			config.UnicodeNormalization, NormalizeNFC, NormalizeNFD, NormalizeNone))
+	}
+	for institution := range config.InstitutionChecks {
+		if _, err := config.ChecksFor(institution); err != nil {
+			errors = append(errors, err)
+		}
+	}
 	return errors
 }
 
+// ChecksFor returns the InstitutionChecks for the specified
+// institution, or an empty list if it has none.
+func (config *BagValidationConfig) ChecksFor(institution string) ([]Check, error) {
+	checks := make([]Check, 0)
+	for _, spec := range config.InstitutionChecks[institution] {
+		check, err := NewCheckFromSpec(spec)
+		if err != nil {
+			return nil, fmt.Errorf("InstitutionChecks for %s: %v", institution, err)
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// hasCryptographicFixity returns true if FixityAlgorithms includes
+// md5, sha256, sha512 or blake2b.
+func (config *BagValidationConfig) hasCryptographicFixity() bool {
+	for _, alg := range constants.ChecksumAlgorithms {
+		if util.StringListContains(config.FixityAlgorithms, alg) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeFileName returns name in the Unicode normalization form
+// that UnicodeNormalization calls for. It returns name unchanged if
+// UnicodeNormalization is "none".
+func (config *BagValidationConfig) NormalizeFileName(name string) string {
+	switch config.UnicodeNormalization {
+	case NormalizeNone:
+		return name
+	case NormalizeNFD:
+		return norm.NFD.String(name)
+	default:
+		return norm.NFC.String(name)
+	}
+}
+
+// AcceptsTar returns true if AcceptSerialization is empty or
+// includes one of the tar MIME types.
+func (config *BagValidationConfig) AcceptsTar() bool {
+	if len(config.AcceptSerialization) == 0 {
+		return true
+	}
+	for _, mimeType := range TarMimeTypes {
+		if util.StringListContains(config.AcceptSerialization, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsGzippedTar returns true if AcceptSerialization includes
+// one of the gzipped tar MIME types. Unlike plain tarred bags,
+// gzipped tarred bags must be explicitly accepted.
+func (config *BagValidationConfig) AcceptsGzippedTar() bool {
+	for _, mimeType := range GzipTarMimeTypes {
+		if util.StringListContains(config.AcceptSerialization, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsZip returns true if AcceptSerialization includes one of
+// the zip MIME types. Like gzipped tarred bags, zipped bags must be
+// explicitly accepted.
+func (config *BagValidationConfig) AcceptsZip() bool {
+	for _, mimeType := range ZipMimeTypes {
+		if util.StringListContains(config.AcceptSerialization, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
 // Call this before testing file names in the bag. This compiles the
 // filename validation regex, if the config includes a validation pattern.
 // Note the two built-in patterns: constants.APTrustFileNamePattern and
@@ -148,6 +342,29 @@ func (config *BagValidationConfig) CompileFileNameRegex() error {
 	return err
 }
 
+// CompileTagValueRegexes compiles the ValuePattern of each TagSpec.
+// If you load your validation config from a file,
+// LoadBagValidationConfig calls this for you.
+func (config *BagValidationConfig) CompileTagValueRegexes() error {
+	for tagName, tagSpec := range config.TagSpecs {
+		if tagSpec.ValuePattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(tagSpec.ValuePattern)
+		if err != nil {
+			return fmt.Errorf("Cannot compile regex for ValuePattern '%s' of tag '%s': %v",
+				tagSpec.ValuePattern, tagName, err)
+		}
+		tagSpec.ValueRegex = re
+		config.TagSpecs[tagName] = tagSpec
+	}
+	return nil
+}
+
+// LoadBagValidationConfig loads a BagValidationConfig from the JSON
+// file at pathToConfigFile. The file may be either a BagValidationConfig
+// or a standard BagIt Profile, which will be converted to a
+// BagValidationConfig. See https://bagit-profiles.github.io.
 func LoadBagValidationConfig(pathToConfigFile string) (*BagValidationConfig, []error) {
 	errors := make([]error, 0)
 	var file []byte
@@ -166,6 +383,9 @@ func LoadBagValidationConfig(pathToConfigFile string) (*BagValidationConfig, []e
 		errors = append(errors, detailedError)
 		return nil, errors
 	}
+	if IsBagItProfile(file) {
+		return loadBagItProfile(pathToConfigFile, file)
+	}
 	bagValidationConfig := NewBagValidationConfig()
 	err = json.Unmarshal(file, bagValidationConfig)
 	if err != nil {
@@ -180,5 +400,37 @@ func LoadBagValidationConfig(pathToConfigFile string) (*BagValidationConfig, []e
 	if regexErr != nil {
 		configErrors = append(configErrors, regexErr)
 	}
+	regexErr = bagValidationConfig.CompileTagValueRegexes()
+	if regexErr != nil {
+		configErrors = append(configErrors, regexErr)
+	}
+	return bagValidationConfig, configErrors
+}
+
+// loadBagItProfile converts the BagIt Profile in jsonData, which came
+// from the file at pathToProfile, into a BagValidationConfig.
+func loadBagItProfile(pathToProfile string, jsonData []byte) (*BagValidationConfig, []error) {
+	errors := make([]error, 0)
+	profile, err := NewBagItProfile(jsonData)
+	if err != nil {
+		errors = append(errors, fmt.Errorf(
+			"Error parsing BagIt Profile '%s': %v", pathToProfile, err))
+		return nil, errors
+	}
+	bagValidationConfig, err := profile.ToBagValidationConfig()
+	if err != nil {
+		errors = append(errors, fmt.Errorf(
+			"Error converting BagIt Profile '%s': %v", pathToProfile, err))
+		return nil, errors
+	}
+	configErrors := bagValidationConfig.ValidateConfig()
+	regexErr := bagValidationConfig.CompileFileNameRegex()
+	if regexErr != nil {
+		configErrors = append(configErrors, regexErr)
+	}
+	regexErr = bagValidationConfig.CompileTagValueRegexes()
+	if regexErr != nil {
+		configErrors = append(configErrors, regexErr)
+	}
 	return bagValidationConfig, configErrors
 }
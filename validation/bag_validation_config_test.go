@@ -2,6 +2,7 @@ package validation_test
 
 import (
 	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/util/fileutil"
 	"github.com/APTrust/exchange/validation"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -117,6 +118,35 @@ func TestValidateConfig(t *testing.T) {
 	conf.TagSpecs["bad_presence"] = badPresenceSpec
 	errors = conf.ValidateConfig()
 	assert.Equal(t, 2, len(errors))
+
+	conf.SpecialFilePolicy = &fileutil.SpecialFilePolicy{Devices: "follow"}
+	errors = conf.ValidateConfig()
+	assert.Equal(t, 3, len(errors))
+}
+
+func TestValidateConfigUnicodeNormalization(t *testing.T) {
+	config := validation.NewBagValidationConfig()
+	for _, policy := range []string{"", validation.NormalizeNFC, validation.NormalizeNFD, validation.NormalizeNone} {
+		config.UnicodeNormalization = policy
+		assert.Empty(t, config.ValidateConfig())
+	}
+	config.UnicodeNormalization = "NFKC"
+	errors := config.ValidateConfig()
+	require.Equal(t, 1, len(errors))
+	assert.Equal(t, "UnicodeNormalization 'NFKC' is not valid. Use NFC, NFD or none.", errors[0].Error())
+}
+
+func TestNormalizeFileName(t *testing.T) {
+	nfd := "data/cafe\u0301.txt"
+	nfc := "data/caf\u00e9.txt"
+	config := validation.NewBagValidationConfig()
+	assert.Equal(t, nfc, config.NormalizeFileName(nfd))
+	assert.Equal(t, nfc, config.NormalizeFileName(nfc))
+	config.UnicodeNormalization = validation.NormalizeNFD
+	assert.Equal(t, nfd, config.NormalizeFileName(nfc))
+	config.UnicodeNormalization = validation.NormalizeNone
+	assert.Equal(t, nfd, config.NormalizeFileName(nfd))
+	assert.Equal(t, nfc, config.NormalizeFileName(nfc))
 }
 
 func TestCompileFileNameRegex(t *testing.T) {
@@ -154,3 +184,95 @@ func TestCompileFileNameRegex(t *testing.T) {
 	assert.Equal(t, constants.PosixFileNamePattern, conf.FileNameRegex)
 
 }
+
+func TestCompileTagValueRegexes(t *testing.T) {
+	conf := validation.NewBagValidationConfig()
+	conf.TagSpecs["Bagging-Date"] = validation.TagSpec{
+		FilePath:     "bag-info.txt",
+		Presence:     validation.OPTIONAL,
+		ValuePattern: `^\d{4}-\d{2}-\d{2}$`,
+	}
+	conf.TagSpecs["Title"] = validation.TagSpec{
+		FilePath: "aptrust-info.txt",
+		Presence: validation.REQUIRED,
+	}
+	require.Nil(t, conf.CompileTagValueRegexes())
+	assert.NotNil(t, conf.TagSpecs["Bagging-Date"].ValueRegex)
+	assert.Nil(t, conf.TagSpecs["Title"].ValueRegex)
+
+	conf.TagSpecs["Title"] = validation.TagSpec{
+		FilePath:     "aptrust-info.txt",
+		Presence:     validation.REQUIRED,
+		ValuePattern: "ThisPatternIsInvalid[-",
+	}
+	err := conf.CompileTagValueRegexes()
+	require.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "Cannot compile regex"))
+	assert.Contains(t, err.Error(), "tag 'Title'")
+}
+
+func TestTagSpecValueError(t *testing.T) {
+	conf := validation.NewBagValidationConfig()
+	conf.TagSpecs["Access"] = validation.TagSpec{
+		FilePath:      "aptrust-info.txt",
+		Presence:      validation.REQUIRED,
+		AllowedValues: []string{"Consortia", "Institution", "Restricted"},
+	}
+	conf.TagSpecs["Internal-Sender-Identifier"] = validation.TagSpec{
+		FilePath:      "bag-info.txt",
+		Presence:      validation.OPTIONAL,
+		AllowedValues: []string{"ABC-123", "ABC-456", "XYZ-123"},
+		ValuePattern:  `^ABC-`,
+	}
+	require.Nil(t, conf.CompileTagValueRegexes())
+
+	access := conf.TagSpecs["Access"]
+	assert.Empty(t, access.ValueError("Access", "institution"))
+	assert.Empty(t, access.ValueError("Access", " Restricted "))
+	assert.Equal(t, "Tag 'Access' has illegal value 'Public'.",
+		access.ValueError("Access", "Public"))
+
+	// Value must be allowed and match the pattern.
+	sender := conf.TagSpecs["Internal-Sender-Identifier"]
+	assert.Empty(t, sender.ValueError("Internal-Sender-Identifier", "ABC-456"))
+	assert.Equal(t, "Tag 'Internal-Sender-Identifier' has illegal value 'ABC-789'.",
+		sender.ValueError("Internal-Sender-Identifier", "ABC-789"))
+	assert.Equal(t, "Tag 'Internal-Sender-Identifier' has value 'XYZ-123', which does not match pattern '^ABC-'.",
+		sender.ValueError("Internal-Sender-Identifier", "XYZ-123"))
+}
+
+func TestAPTrustTagSpecsMatchConstants(t *testing.T) {
+	conf, errors := validation.LoadBagValidationConfig(path.Join("config", "aptrust_bag_validation_config.json"))
+	require.Empty(t, errors)
+	access := conf.TagSpecs["Access"]
+	for _, value := range constants.AccessRights {
+		assert.Empty(t, access.ValueError("Access", value), value)
+	}
+	assert.Equal(t, len(constants.AccessRights), len(access.AllowedValues))
+	storageOption := conf.TagSpecs["Storage-Option"]
+	assert.ElementsMatch(t, constants.StorageOptions, storageOption.AllowedValues)
+}
+
+func TestValidateConfigLimits(t *testing.T) {
+	config := validation.NewBagValidationConfig()
+	config.MaxBagSize = 5000
+	config.MaxFileCount = 10
+	assert.Empty(t, config.ValidateConfig())
+	config.MaxBagSize = -1
+	config.MaxFileCount = -1
+	errors := config.ValidateConfig()
+	require.Equal(t, 2, len(errors))
+	assert.Equal(t, "MaxBagSize cannot be negative.", errors[0].Error())
+	assert.Equal(t, "MaxFileCount cannot be negative.", errors[1].Error())
+}
+
+func TestValidateConfigFastFixity(t *testing.T) {
+	config := validation.NewBagValidationConfig()
+	config.FixityAlgorithms = []string{constants.AlgSha256, constants.AlgCrc32c, constants.AlgXxh64}
+	assert.Empty(t, config.ValidateConfig())
+	config.FixityAlgorithms = []string{constants.AlgCrc32c, constants.AlgXxh64}
+	errors := config.ValidateConfig()
+	require.Equal(t, 1, len(errors))
+	assert.True(t, strings.HasPrefix(errors[0].Error(),
+		"FixityAlgorithms includes crc32c or xxh64, which cannot be the only fixity check."))
+}
@@ -0,0 +1,183 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/util"
+	"strings"
+)
+
+// TarMimeTypes are the MIME types a BagIt Profile may list in
+// Accept-Serialization to indicate that tarred bags are acceptable.
+var TarMimeTypes = []string{"application/tar", "application/x-tar"}
+
+// GzipTarMimeTypes are the MIME types a BagIt Profile may list in
+// Accept-Serialization to indicate that gzipped tarred bags
+// (.tar.gz or .tgz files) are acceptable.
+var GzipTarMimeTypes = []string{"application/gzip", "application/x-gzip", "application/tar+gzip"}
+
+// ZipMimeTypes are the MIME types a BagIt Profile may list in
+// Accept-Serialization to indicate that zipped bags are acceptable.
+var ZipMimeTypes = []string{"application/zip", "application/x-zip-compressed"}
+
+// BagItProfile describes a bag according to the BagIt Profiles
+// specification at https://bagit-profiles.github.io/bagit-profiles-specification/.
+// Call ToBagValidationConfig to convert a profile into a
+// BagValidationConfig that the validator can use.
+type BagItProfile struct {
+	// BagItProfileInfo describes the profile itself. It includes
+	// the BagIt-Profile-Identifier, Source-Organization, etc.
+	BagItProfileInfo map[string]string `json:"BagIt-Profile-Info"`
+	// BagInfo describes tags in bag-info.txt. The key is the tag name.
+	BagInfo map[string]BagItProfileTag `json:"Bag-Info"`
+	// ManifestsRequired lists the algorithms of the payload manifests
+	// every bag must have. E.g. "md5", "sha256".
+	ManifestsRequired []string `json:"Manifests-Required"`
+	// ManifestsAllowed lists the algorithms of all payload manifests
+	// a bag may have. If this is empty, any manifest is allowed.
+	ManifestsAllowed []string `json:"Manifests-Allowed"`
+	// TagManifestsRequired lists the algorithms of the tag manifests
+	// every bag must have.
+	TagManifestsRequired []string `json:"Tag-Manifests-Required"`
+	// TagManifestsAllowed lists the algorithms of all tag manifests
+	// a bag may have. If this is empty, any tag manifest is allowed.
+	TagManifestsAllowed []string `json:"Tag-Manifests-Allowed"`
+	// TagFilesRequired lists the paths of tag files every bag must have.
+	TagFilesRequired []string `json:"Tag-Files-Required"`
+	// AllowFetchTxt describes whether bags may contain fetch.txt.
+	// Per the spec, this defaults to true.
+	AllowFetchTxt *bool `json:"Allow-Fetch.txt"`
+	// Serialization can be "required", "optional" or "forbidden".
+	Serialization string `json:"Serialization"`
+	// AcceptSerialization lists the MIME types of acceptable
+	// serializations. E.g. "application/x-tar".
+	AcceptSerialization []string `json:"Accept-Serialization"`
+	// AcceptBagItVersion lists the acceptable values of the
+	// BagIt-Version tag in bagit.txt.
+	AcceptBagItVersion []string `json:"Accept-BagIt-Version"`
+}
+
+// BagItProfileTag describes a tag in a BagIt Profile's Bag-Info section.
+type BagItProfileTag struct {
+	// Required indicates whether the tag must be present
+	// and non-empty.
+	Required bool `json:"required"`
+	// Values lists the tag's allowed values. If this is empty,
+	// any value is allowed.
+	Values []string `json:"values"`
+}
+
+// NewBagItProfile parses a BagIt Profile from JSON data.
+func NewBagItProfile(jsonData []byte) (*BagItProfile, error) {
+	profile := &BagItProfile{}
+	err := json.Unmarshal(jsonData, profile)
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// IsBagItProfile returns true if jsonData looks like a BagIt Profile
+// rather than a BagValidationConfig. All BagIt Profiles must include
+// the BagIt-Profile-Info section.
+func IsBagItProfile(jsonData []byte) bool {
+	data := make(map[string]interface{})
+	if json.Unmarshal(jsonData, &data) != nil {
+		return false
+	}
+	_, ok := data["BagIt-Profile-Info"]
+	return ok
+}
+
+// ToBagValidationConfig converts this profile into a BagValidationConfig.
+// This returns an error if the profile requires a manifest or tag
+// manifest whose algorithm the validator does not support. The validator
+// calculates fixity for every supported algorithm the profile allows.
+//
+// Because the BagIt spec allows arbitrary tag files and tag directories,
+// the resulting config allows miscellaneous top-level files and directories,
+// and does not restrict file names.
+func (profile *BagItProfile) ToBagValidationConfig() (*BagValidationConfig, error) {
+	config := NewBagValidationConfig()
+	config.AllowFetchTxt = profile.AllowFetchTxt == nil || *profile.AllowFetchTxt
+	config.AllowMiscTopLevelFiles = true
+	config.AllowMiscDirectories = true
+	config.Serialization = strings.ToLower(profile.Serialization)
+	config.AcceptSerialization = profile.AcceptSerialization
+
+	err := profile.addManifestSpecs(config, "manifest", profile.ManifestsRequired, profile.ManifestsAllowed)
+	if err != nil {
+		return nil, err
+	}
+	err = profile.addManifestSpecs(config, "tagmanifest", profile.TagManifestsRequired, profile.TagManifestsAllowed)
+	if err != nil {
+		return nil, err
+	}
+	allowed := make([]string, 0)
+	allowed = append(allowed, profile.ManifestsRequired...)
+	allowed = append(allowed, profile.ManifestsAllowed...)
+	allowed = append(allowed, profile.TagManifestsRequired...)
+	allowed = append(allowed, profile.TagManifestsAllowed...)
+	for _, alg := range constants.ChecksumAlgorithms {
+		if len(allowed) == 0 || util.StringListContains(allowed, alg) {
+			config.FixityAlgorithms = append(config.FixityAlgorithms, alg)
+		}
+	}
+
+	for _, filePath := range profile.TagFilesRequired {
+		config.FileSpecs[filePath] = FileSpec{Presence: REQUIRED}
+	}
+	config.FileSpecs["bagit.txt"] = FileSpec{Presence: REQUIRED, ParseAsTagFile: true}
+	bagInfoPresence := OPTIONAL
+	for tagName, profileTag := range profile.BagInfo {
+		tagSpec := TagSpec{
+			FilePath:      "bag-info.txt",
+			Presence:      OPTIONAL,
+			EmptyOK:       !profileTag.Required,
+			AllowedValues: profileTag.Values,
+		}
+		if profileTag.Required {
+			tagSpec.Presence = REQUIRED
+			bagInfoPresence = REQUIRED
+		}
+		config.TagSpecs[tagName] = tagSpec
+	}
+	config.FileSpecs["bag-info.txt"] = FileSpec{Presence: bagInfoPresence, ParseAsTagFile: true}
+	if len(profile.AcceptBagItVersion) > 0 {
+		config.TagSpecs["BagIt-Version"] = TagSpec{
+			FilePath:      "bagit.txt",
+			Presence:      REQUIRED,
+			AllowedValues: profile.AcceptBagItVersion,
+		}
+	}
+	return config, nil
+}
+
+// addManifestSpecs adds FileSpecs for the manifests or tag manifests
+// (according to prefix) that the profile requires or allows. If the
+// profile lists allowed algorithms, manifests for supported algorithms
+// not on the list are forbidden.
+func (profile *BagItProfile) addManifestSpecs(config *BagValidationConfig, prefix string, required, allowed []string) error {
+	for _, alg := range required {
+		if !util.StringListContains(constants.ChecksumAlgorithms, alg) {
+			return fmt.Errorf("Profile requires %s-%s.txt, but the validator "+
+				"does not support the %s algorithm.", prefix, alg, alg)
+		}
+		config.FileSpecs[fmt.Sprintf("%s-%s.txt", prefix, alg)] = FileSpec{Presence: REQUIRED}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, alg := range constants.ChecksumAlgorithms {
+		fileName := fmt.Sprintf("%s-%s.txt", prefix, alg)
+		if util.StringListContains(required, alg) {
+			continue
+		} else if util.StringListContains(allowed, alg) {
+			config.FileSpecs[fileName] = FileSpec{Presence: OPTIONAL}
+		} else {
+			config.FileSpecs[fileName] = FileSpec{Presence: FORBIDDEN}
+		}
+	}
+	return nil
+}
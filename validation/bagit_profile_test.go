@@ -0,0 +1,171 @@
+package validation_test
+
+import (
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/util/fileutil"
+	"github.com/APTrust/exchange/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path"
+	"testing"
+)
+
+func getBagItProfile(t *testing.T) *validation.BagItProfile {
+	data, err := fileutil.LoadRelativeFile(path.Join("testdata", "json_objects", "bagit_profile.json"))
+	require.Nil(t, err)
+	profile, err := validation.NewBagItProfile(data)
+	require.Nil(t, err)
+	return profile
+}
+
+func TestIsBagItProfile(t *testing.T) {
+	data, err := fileutil.LoadRelativeFile(path.Join("testdata", "json_objects", "bagit_profile.json"))
+	require.Nil(t, err)
+	assert.True(t, validation.IsBagItProfile(data))
+
+	data, err = fileutil.LoadRelativeFile(path.Join("testdata", "json_objects", "bag_validation_config.json"))
+	require.Nil(t, err)
+	assert.False(t, validation.IsBagItProfile(data))
+
+	assert.False(t, validation.IsBagItProfile([]byte("this is not json")))
+}
+
+func TestNewBagItProfile(t *testing.T) {
+	profile := getBagItProfile(t)
+	assert.Equal(t, "example.edu", profile.BagItProfileInfo["Source-Organization"])
+	assert.Equal(t, 3, len(profile.BagInfo))
+	assert.True(t, profile.BagInfo["Bagging-Date"].Required)
+	assert.Equal(t, []string{"md5"}, profile.ManifestsRequired)
+	require.NotNil(t, profile.AllowFetchTxt)
+	assert.False(t, *profile.AllowFetchTxt)
+	assert.Equal(t, []string{"application/x-tar"}, profile.AcceptSerialization)
+
+	_, err := validation.NewBagItProfile([]byte("{ bad json"))
+	assert.NotNil(t, err)
+}
+
+func TestBagItProfileToBagValidationConfig(t *testing.T) {
+	conf, err := getBagItProfile(t).ToBagValidationConfig()
+	require.Nil(t, err)
+	require.NotNil(t, conf)
+
+	assert.False(t, conf.AllowFetchTxt)
+	assert.True(t, conf.AllowMiscTopLevelFiles)
+	assert.True(t, conf.AllowMiscDirectories)
+	assert.False(t, conf.TopLevelDirMustMatchBagName)
+	assert.Equal(t, validation.OPTIONAL, conf.Serialization)
+	assert.True(t, conf.AcceptsTar())
+	assert.Equal(t, []string{constants.AlgMd5, constants.AlgSha256}, conf.FixityAlgorithms)
+
+	assert.Equal(t, validation.REQUIRED, conf.FileSpecs["manifest-md5.txt"].Presence)
+	assert.Equal(t, validation.OPTIONAL, conf.FileSpecs["manifest-sha256.txt"].Presence)
+	assert.Equal(t, validation.FORBIDDEN, conf.FileSpecs["manifest-sha512.txt"].Presence)
+	assert.Equal(t, validation.OPTIONAL, conf.FileSpecs["tagmanifest-md5.txt"].Presence)
+	assert.Equal(t, validation.FORBIDDEN, conf.FileSpecs["tagmanifest-sha512.txt"].Presence)
+	assert.Equal(t, validation.REQUIRED, conf.FileSpecs["bagit.txt"].Presence)
+	assert.True(t, conf.FileSpecs["bagit.txt"].ParseAsTagFile)
+	assert.Equal(t, validation.REQUIRED, conf.FileSpecs["bag-info.txt"].Presence)
+	assert.True(t, conf.FileSpecs["bag-info.txt"].ParseAsTagFile)
+
+	require.Equal(t, 4, len(conf.TagSpecs))
+	sourceOrg := conf.TagSpecs["Source-Organization"]
+	assert.Equal(t, "bag-info.txt", sourceOrg.FilePath)
+	assert.Equal(t, validation.REQUIRED, sourceOrg.Presence)
+	assert.False(t, sourceOrg.EmptyOK)
+	assert.Equal(t, []string{"virginia.edu", "example.edu"}, sourceOrg.AllowedValues)
+	description := conf.TagSpecs["Internal-Sender-Description"]
+	assert.Equal(t, validation.OPTIONAL, description.Presence)
+	assert.True(t, description.EmptyOK)
+	version := conf.TagSpecs["BagIt-Version"]
+	assert.Equal(t, "bagit.txt", version.FilePath)
+	assert.Equal(t, []string{"0.97", "1.0"}, version.AllowedValues)
+
+	assert.Empty(t, conf.ValidateConfig())
+}
+
+func TestBagItProfileToBagValidationConfig_Defaults(t *testing.T) {
+	profile := &validation.BagItProfile{}
+	conf, err := profile.ToBagValidationConfig()
+	require.Nil(t, err)
+	assert.True(t, conf.AllowFetchTxt)
+	assert.Equal(t, constants.ChecksumAlgorithms, conf.FixityAlgorithms)
+	assert.Equal(t, validation.OPTIONAL, conf.FileSpecs["bag-info.txt"].Presence)
+	_, ok := conf.FileSpecs["manifest-md5.txt"]
+	assert.False(t, ok)
+	assert.Empty(t, conf.TagSpecs)
+}
+
+func TestBagItProfileToBagValidationConfig_UnsupportedAlgorithm(t *testing.T) {
+	profile := &validation.BagItProfile{
+		ManifestsRequired: []string{"sha1"},
+	}
+	_, err := profile.ToBagValidationConfig()
+	require.NotNil(t, err)
+	assert.Equal(t, "Profile requires manifest-sha1.txt, but the validator does not support the sha1 algorithm.", err.Error())
+}
+
+func TestLoadBagValidationConfig_FromBagItProfile(t *testing.T) {
+	configFilePath := path.Join("testdata", "json_objects", "bagit_profile.json")
+	conf, errors := validation.LoadBagValidationConfig(configFilePath)
+	require.Empty(t, errors)
+	require.NotNil(t, conf)
+	assert.Equal(t, validation.REQUIRED, conf.FileSpecs["manifest-md5.txt"].Presence)
+	assert.Equal(t, 4, len(conf.TagSpecs))
+}
+
+func TestValidator_WithBagItProfile(t *testing.T) {
+	configFilePath := path.Join("testdata", "json_objects", "bagit_profile.json")
+	conf, errors := validation.LoadBagValidationConfig(configFilePath)
+	require.Empty(t, errors)
+
+	validator, err := validation.NewValidator(getBagPath(t, "example.edu.sample_good.tar"), conf, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+
+	// Bag-Info tag values must be among those the profile allows.
+	conf.TagSpecs["Source-Organization"] = validation.TagSpec{
+		FilePath:      "bag-info.txt",
+		Presence:      validation.REQUIRED,
+		AllowedValues: []string{"example.edu"},
+	}
+	validator, err = validation.NewValidator(getBagPath(t, "example.edu.sample_good.tar"), conf, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	assert.True(t, summary.HasErrors())
+}
+
+func TestValidator_Serialization(t *testing.T) {
+	conf := getConfig(t)
+	conf.Serialization = validation.FORBIDDEN
+	validator, err := validation.NewValidator(getBagPath(t, "example.edu.sample_good.tar"), conf, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(summary.Errors))
+	assert.Contains(t, summary.Errors[0].Message, "Bag must not be serialized")
+
+	conf = getConfig(t)
+	conf.AcceptSerialization = []string{"application/zip"}
+	validator, err = validation.NewValidator(getBagPath(t, "example.edu.sample_good.tar"), conf, false)
+	require.Nil(t, err)
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(summary.Errors))
+	assert.Equal(t, "Tarred bags are not accepted. Accepted serializations: application/zip.", summary.Errors[0].Message)
+
+	conf = getConfig(t)
+	conf.Serialization = validation.REQUIRED
+	conf.AcceptSerialization = []string{"application/tar"}
+	validator, err = validation.NewValidator(getBagPath(t, "example.edu.tagsample_good.tar"), conf, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+}
@@ -0,0 +1,198 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util/fileutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BatchValidator validates a number of bags against the same
+// BagValidationConfig, several at a time, and reports on all of
+// them at once. It's for depositors who want to check dozens of
+// bags before a bulk deposit.
+type BatchValidator struct {
+	PathsToBags         []string
+	BagValidationConfig *BagValidationConfig
+
+	// Concurrency is the number of bags to validate at once.
+	Concurrency int
+
+	// StructureOnly tells the BatchValidator to call
+	// ValidateStructureOnly instead of Validate on each bag.
+	StructureOnly bool
+
+	// MemoryDBMaxFiles is passed through to each Validator.
+	// NewBatchValidator sets it to DEFAULT_MEMORY_DB_MAX_FILES.
+	MemoryDBMaxFiles int
+
+	// Progress, if set, is called each time the BatchValidator
+	// finishes validating a bag. Calls are not concurrent.
+	Progress func(*BagResult)
+}
+
+// BagResult is the result of validating one bag in a batch.
+type BagResult struct {
+	PathToBag string
+	// Summary is the validator's WorkSummary. It's nil if the
+	// validator could not run. See Error.
+	Summary *models.WorkSummary
+	// Warnings are the validator's warnings about the bag.
+	Warnings []string
+	// Error describes why the validator could not run, if it
+	// could not.
+	Error error
+}
+
+// IsValid returns true if the validator ran and found no errors.
+func (result *BagResult) IsValid() bool {
+	return result.Error == nil && result.Summary != nil && !result.Summary.HasErrors()
+}
+
+// BatchReport is the aggregated result of validating a batch of bags.
+type BatchReport struct {
+	// Results has one entry for each bag, in the same order as
+	// BatchValidator.PathsToBags.
+	Results []*BagResult
+	Valid   int
+	Invalid int
+}
+
+// AllValid returns true if every bag in the batch is valid.
+func (report *BatchReport) AllValid() bool {
+	return report.Invalid == 0
+}
+
+// InvalidBags returns the results for the bags that are not valid,
+// or that the validator could not check.
+func (report *BatchReport) InvalidBags() []*BagResult {
+	invalid := make([]*BagResult, 0)
+	for _, result := range report.Results {
+		if !result.IsValid() {
+			invalid = append(invalid, result)
+		}
+	}
+	return invalid
+}
+
+// ToText returns a human-readable report that lists each bag as
+// valid or invalid, with the errors for each invalid bag, followed
+// by the totals.
+func (report *BatchReport) ToText() string {
+	lines := make([]string, 0)
+	for _, result := range report.Results {
+		if result.IsValid() {
+			lines = append(lines, fmt.Sprintf("VALID    %s", result.PathToBag))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("INVALID  %s", result.PathToBag))
+		if result.Error != nil {
+			lines = append(lines, fmt.Sprintf("    %s", result.Error.Error()))
+		} else {
+			for _, message := range result.Summary.Errors {
+				lines = append(lines, fmt.Sprintf("    %s", message))
+			}
+		}
+	}
+	lines = append(lines, fmt.Sprintf("%d bags: %d valid, %d invalid",
+		len(report.Results), report.Valid, report.Invalid))
+	return strings.Join(lines, "\n")
+}
+
+// NewBatchValidator returns a BatchValidator that will validate
+// the bags in pathsToBags, concurrency bags at a time. Param
+// concurrency defaults to 1 if it's less than one.
+func NewBatchValidator(pathsToBags []string, bagValidationConfig *BagValidationConfig, concurrency int) (*BatchValidator, error) {
+	if len(pathsToBags) == 0 {
+		return nil, fmt.Errorf("Param pathsToBags cannot be empty")
+	}
+	err := validateConfig(bagValidationConfig)
+	if err != nil {
+		return nil, err
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &BatchValidator{
+		PathsToBags:         pathsToBags,
+		BagValidationConfig: bagValidationConfig,
+		Concurrency:         concurrency,
+		MemoryDBMaxFiles:    DEFAULT_MEMORY_DB_MAX_FILES,
+	}, nil
+}
+
+// NewBatchValidatorForGlob returns a BatchValidator for the bags whose
+// paths match pattern, such as "/mnt/deposits/*.tar". See filepath.Match
+// for the pattern syntax. It returns an error if nothing matches.
+func NewBatchValidatorForGlob(pattern string, bagValidationConfig *BagValidationConfig, concurrency int) (*BatchValidator, error) {
+	pathsToBags, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Bad pattern '%s': %v", pattern, err)
+	}
+	if len(pathsToBags) == 0 {
+		return nil, fmt.Errorf("No bags match '%s'", pattern)
+	}
+	sort.Strings(pathsToBags)
+	return NewBatchValidator(pathsToBags, bagValidationConfig, concurrency)
+}
+
+// Validate validates all of the bags and returns the report. A bag
+// that can't be validated, because it doesn't exist or can't be read,
+// counts as invalid. The validators' .valdb files are removed.
+func (batch *BatchValidator) Validate() *BatchReport {
+	report := &BatchReport{
+		Results: make([]*BagResult, len(batch.PathsToBags)),
+	}
+	mutex := &sync.Mutex{}
+	wg := sync.WaitGroup{}
+	semaphore := make(chan struct{}, batch.Concurrency)
+	for i, pathToBag := range batch.PathsToBags {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, pathToBag string) {
+			defer wg.Done()
+			result := batch.validateBag(pathToBag)
+			mutex.Lock()
+			report.Results[i] = result
+			if result.IsValid() {
+				report.Valid += 1
+			} else {
+				report.Invalid += 1
+			}
+			if batch.Progress != nil {
+				batch.Progress(result)
+			}
+			mutex.Unlock()
+			<-semaphore
+		}(i, pathToBag)
+	}
+	wg.Wait()
+	return report
+}
+
+// validateBag validates a single bag. NewBatchValidator has already
+// checked and compiled the config, so we don't call NewValidator,
+// which would compile it again while other goroutines are using it.
+func (batch *BatchValidator) validateBag(pathToBag string) *BagResult {
+	result := &BagResult{PathToBag: pathToBag}
+	if !fileutil.FileExists(pathToBag) {
+		result.Error = fmt.Errorf("Bag does not exist at %s", pathToBag)
+		return result
+	}
+	validator := newValidator(pathToBag, batch.BagValidationConfig, false)
+	validator.MemoryDBMaxFiles = batch.MemoryDBMaxFiles
+	validate := validator.Validate
+	if batch.StructureOnly {
+		validate = validator.ValidateStructureOnly
+	}
+	result.Summary, result.Error = validate()
+	result.Warnings = validator.Warnings()
+	if fileutil.LooksSafeToDelete(validator.DBName(), 12, 3) {
+		os.Remove(validator.DBName())
+	}
+	return result
+}
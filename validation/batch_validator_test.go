@@ -0,0 +1,104 @@
+package validation_test
+
+import (
+	"github.com/APTrust/exchange/util/fileutil"
+	"github.com/APTrust/exchange/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func batchConfig(t *testing.T) *validation.BagValidationConfig {
+	bagValidationConfig, err := getValidationConfig()
+	require.Nil(t, err)
+	optionalFileSpec := validation.FileSpec{Presence: "OPTIONAL"}
+	bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = optionalFileSpec
+	return bagValidationConfig
+}
+
+func TestNewBatchValidator(t *testing.T) {
+	_, err := validation.NewBatchValidator(nil, batchConfig(t), 2)
+	assert.NotNil(t, err)
+	_, err = validation.NewBatchValidator([]string{"bag.tar"}, nil, 2)
+	assert.NotNil(t, err)
+	batch, err := validation.NewBatchValidator([]string{"bag.tar"}, batchConfig(t), 0)
+	require.Nil(t, err)
+	assert.Equal(t, 1, batch.Concurrency)
+	assert.Equal(t, validation.DEFAULT_MEMORY_DB_MAX_FILES, batch.MemoryDBMaxFiles)
+}
+
+func TestNewBatchValidatorForGlob(t *testing.T) {
+	pattern := filepath.Join(filepath.Dir(getBagPath(t, "x")), "example.edu.sample_glacier_*.tar")
+	batch, err := validation.NewBatchValidatorForGlob(pattern, batchConfig(t), 2)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(batch.PathsToBags))
+	assert.True(t, strings.HasSuffix(batch.PathsToBags[0], "example.edu.sample_glacier_oh.tar"))
+
+	_, err = validation.NewBatchValidatorForGlob("/no/such/dir/*.tar", batchConfig(t), 2)
+	assert.NotNil(t, err)
+}
+
+func TestBatchValidator_Validate(t *testing.T) {
+	pathsToBags := []string{
+		getBagPath(t, "example.edu.sample_good.tar"),
+		getBagPath(t, "example.edu.sample_bad_checksums.tar"),
+		getBagPath(t, "example.edu.sample_glacier_oh.tar"),
+		getBagPath(t, "example.edu.does_not_exist.tar"),
+	}
+	batch, err := validation.NewBatchValidator(pathsToBags, batchConfig(t), 3)
+	require.Nil(t, err)
+	progressCalls := 0
+	batch.Progress = func(result *validation.BagResult) { progressCalls++ }
+	report := batch.Validate()
+
+	require.Equal(t, 4, len(report.Results))
+	assert.Equal(t, 4, progressCalls)
+	assert.Equal(t, 2, report.Valid)
+	assert.Equal(t, 2, report.Invalid)
+	assert.False(t, report.AllValid())
+	for i, result := range report.Results {
+		assert.Equal(t, pathsToBags[i], result.PathToBag)
+	}
+	assert.True(t, report.Results[0].IsValid())
+	assert.False(t, report.Results[1].IsValid())
+	assert.Nil(t, report.Results[1].Error)
+	assert.Equal(t, 5, len(report.Results[1].Summary.Errors))
+	assert.True(t, report.Results[2].IsValid())
+	assert.False(t, report.Results[3].IsValid())
+	assert.NotNil(t, report.Results[3].Error)
+
+	invalid := report.InvalidBags()
+	require.Equal(t, 2, len(invalid))
+	assert.Equal(t, pathsToBags[1], invalid[0].PathToBag)
+	assert.Equal(t, pathsToBags[3], invalid[1].PathToBag)
+
+	text := report.ToText()
+	assert.Contains(t, text, "VALID    "+pathsToBags[0])
+	assert.Contains(t, text, "INVALID  "+pathsToBags[1])
+	assert.Contains(t, text, "Required tag 'Access' is missing.")
+	assert.Contains(t, text, "Bag does not exist at "+pathsToBags[3])
+	assert.Contains(t, text, "4 bags: 2 valid, 2 invalid")
+
+	// No validation DBs left behind.
+	for _, pathToBag := range pathsToBags {
+		assert.False(t, fileutil.FileExists(pathToBag[0:len(pathToBag)-4]+".valdb"))
+	}
+}
+
+func TestBatchValidator_StructureOnly(t *testing.T) {
+	pathsToBags := []string{
+		getBagPath(t, "example.edu.sample_good.tar"),
+		getBagPath(t, "example.edu.sample_bad_checksums.tar"),
+	}
+	batch, err := validation.NewBatchValidator(pathsToBags, batchConfig(t), 2)
+	require.Nil(t, err)
+	batch.StructureOnly = true
+	report := batch.Validate()
+	require.Equal(t, 2, len(report.Results))
+	assert.True(t, report.Results[0].IsValid())
+	// Only the missing tag, no bad digests.
+	require.NotNil(t, report.Results[1].Summary)
+	assert.Equal(t, 1, len(report.Results[1].Summary.Errors))
+}
@@ -0,0 +1,132 @@
+package validation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/APTrust/exchange/models"
+)
+
+// CheckSpec names a registered Check and the params to build it with.
+// BagValidationConfig.InstitutionChecks uses these to describe the
+// extra checks each institution's bags must pass.
+type CheckSpec struct {
+	// Name is the name under which the Check was registered.
+	Name string
+	// Params are passed to the Check's CheckFactory. Their meaning
+	// depends on the Check.
+	Params map[string]string
+}
+
+// CheckFactory builds a Check from the params in a CheckSpec. It
+// returns an error if the params are missing or invalid.
+type CheckFactory func(params map[string]string) (Check, error)
+
+var checkRegistry = make(map[string]CheckFactory)
+var checkRegistryMutex = &sync.RWMutex{}
+
+// RegisterCheck makes a Check available to BagValidationConfig's
+// InstitutionChecks under the specified name. Packages that provide
+// institution-specific checks should call this from an init function,
+// so the checks are registered before the config is loaded. Like
+// database/sql's Register, this panics if name is already registered,
+// or if factory is nil.
+func RegisterCheck(name string, factory CheckFactory) {
+	checkRegistryMutex.Lock()
+	defer checkRegistryMutex.Unlock()
+	if factory == nil {
+		panic("validation: RegisterCheck factory is nil")
+	}
+	if _, exists := checkRegistry[name]; exists {
+		panic("validation: RegisterCheck called twice for check " + name)
+	}
+	checkRegistry[name] = factory
+}
+
+// RegisteredChecks returns the names of all registered Checks,
+// in sorted order.
+func RegisteredChecks() []string {
+	checkRegistryMutex.RLock()
+	defer checkRegistryMutex.RUnlock()
+	names := make([]string, 0, len(checkRegistry))
+	for name := range checkRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewCheckFromSpec returns the registered Check named in spec, built
+// with spec's params.
+func NewCheckFromSpec(spec CheckSpec) (Check, error) {
+	checkRegistryMutex.RLock()
+	factory := checkRegistry[spec.Name]
+	checkRegistryMutex.RUnlock()
+	if factory == nil {
+		return nil, fmt.Errorf("No check is registered under the name '%s'.", spec.Name)
+	}
+	check, err := factory(spec.Params)
+	if err != nil {
+		return nil, fmt.Errorf("Check '%s': %v", spec.Name, err)
+	}
+	return check, nil
+}
+
+// NewRequiredTagsCheck returns a Check that adds an error for each of
+// tagNames that is missing from the bag or has no value. If sourceFile
+// is not empty, the tag must appear in that file, such as
+// "bag-info.txt". The validator collects only the tags in files that
+// the BagValidationConfig says to parse.
+func NewRequiredTagsCheck(sourceFile string, tagNames ...string) Check {
+	name := fmt.Sprintf("required tags (%s)", strings.Join(tagNames, ", "))
+	return NewCheck(name, func(validator *Validator, obj *models.IntellectualObject, summary *models.WorkSummary) {
+		for _, tagName := range tagNames {
+			hasValue := false
+			for _, tag := range obj.FindTag(tagName) {
+				if (sourceFile == "" || tag.SourceFile == sourceFile) && strings.TrimSpace(tag.Value) != "" {
+					hasValue = true
+					break
+				}
+			}
+			if !hasValue && sourceFile != "" {
+				summary.AddError("Required tag '%s' is missing from %s.", tagName, sourceFile)
+			} else if !hasValue {
+				summary.AddError("Required tag '%s' is missing.", tagName)
+			}
+		}
+	})
+}
+
+// splitParam returns the comma-separated values of params[key],
+// with whitespace trimmed and empty values removed.
+func splitParam(params map[string]string, key string) []string {
+	values := make([]string, 0)
+	for _, value := range strings.Split(params[key], ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func init() {
+	// Param "extensions" is a comma-separated list, such as ".exe,.dll".
+	RegisterCheck("forbidden-extensions", func(params map[string]string) (Check, error) {
+		extensions := splitParam(params, "extensions")
+		if len(extensions) == 0 {
+			return nil, fmt.Errorf("Param 'extensions' is required.")
+		}
+		return NewForbiddenExtensionsCheck(extensions...), nil
+	})
+	// Param "tags" is a comma-separated list of tag names. Optional
+	// param "file" is the tag file that must contain them.
+	RegisterCheck("required-tags", func(params map[string]string) (Check, error) {
+		tagNames := splitParam(params, "tags")
+		if len(tagNames) == 0 {
+			return nil, fmt.Errorf("Param 'tags' is required.")
+		}
+		return NewRequiredTagsCheck(params["file"], tagNames...), nil
+	})
+}
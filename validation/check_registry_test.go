@@ -0,0 +1,94 @@
+package validation_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util"
+	"github.com/APTrust/exchange/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterCheck(t *testing.T) {
+	names := validation.RegisteredChecks()
+	assert.Contains(t, names, "forbidden-extensions")
+	assert.Contains(t, names, "required-tags")
+
+	// The registry is global, so don't register twice under -count.
+	if !util.StringListContains(names, "test-always-fails") {
+		validation.RegisterCheck("test-always-fails", func(params map[string]string) (validation.Check, error) {
+			return validation.NewCheck("always fails",
+				func(v *validation.Validator, obj *models.IntellectualObject, summary *models.WorkSummary) {
+					summary.AddError("Failed: %s", params["reason"])
+				}), nil
+		})
+	}
+	assert.Contains(t, validation.RegisteredChecks(), "test-always-fails")
+	assert.Panics(t, func() {
+		validation.RegisterCheck("test-always-fails", func(params map[string]string) (validation.Check, error) {
+			return nil, fmt.Errorf("duplicate")
+		})
+	})
+	assert.Panics(t, func() { validation.RegisterCheck("test-nil-factory", nil) })
+
+	check, err := validation.NewCheckFromSpec(validation.CheckSpec{Name: "test-always-fails"})
+	require.Nil(t, err)
+	assert.Equal(t, "always fails", check.Name())
+
+	_, err = validation.NewCheckFromSpec(validation.CheckSpec{Name: "no-such-check"})
+	assert.NotNil(t, err)
+	_, err = validation.NewCheckFromSpec(validation.CheckSpec{Name: "required-tags"})
+	assert.NotNil(t, err)
+	_, err = validation.NewCheckFromSpec(validation.CheckSpec{Name: "forbidden-extensions"})
+	assert.NotNil(t, err)
+}
+
+func TestInstitutionChecksConfig(t *testing.T) {
+	config := getConfig(t)
+	config.InstitutionChecks = map[string][]validation.CheckSpec{
+		"virginia.edu": {
+			{Name: "required-tags", Params: map[string]string{"tags": "Bag-Group-Identifier"}},
+			{Name: "forbidden-extensions", Params: map[string]string{"extensions": ".exe, .dll"}},
+		},
+	}
+	assert.Empty(t, config.ValidateConfig())
+	checks, err := config.ChecksFor("virginia.edu")
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(checks))
+	checks, err = config.ChecksFor("example.edu")
+	require.Nil(t, err)
+	assert.Empty(t, checks)
+
+	config.InstitutionChecks["example.edu"] = []validation.CheckSpec{{Name: "no-such-check"}}
+	assert.Equal(t, 1, len(config.ValidateConfig()))
+	_, err = config.ChecksFor("example.edu")
+	assert.NotNil(t, err)
+}
+
+func TestValidator_AddInstitutionChecks(t *testing.T) {
+	validator := getValidator(t, "example.edu.tagsample_good.tar", false)
+	defer deleteFile(validator.DBName())
+	validator.BagValidationConfig.InstitutionChecks = map[string][]validation.CheckSpec{
+		"example.edu": {
+			{Name: "required-tags", Params: map[string]string{
+				"tags": "Bag-Group-Identifier, Collection-Identifier",
+				"file": "bag-info.txt",
+			}},
+		},
+	}
+	defer func() { validator.BagValidationConfig.InstitutionChecks = nil }()
+
+	require.Nil(t, validator.AddInstitutionChecks("virginia.edu"))
+	assert.Equal(t, 4, len(validator.Checks))
+	require.Nil(t, validator.AddInstitutionChecks("example.edu"))
+	assert.Equal(t, 5, len(validator.Checks))
+
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Required tag 'Collection-Identifier' is missing from bag-info.txt."},
+		summary.ErrorMessages())
+	assert.False(t, util.StringListContains(summary.ErrorMessages(),
+		"Required tag 'Bag-Group-Identifier' is missing from bag-info.txt."))
+}
@@ -0,0 +1,189 @@
+package validation
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util"
+)
+
+// Check is a single step in the validator's post-read verification
+// pipeline. The validator runs its Checks in order, after it has read
+// the whole bag, parsed the manifests and tag files, and verified the
+// Payload-Oxum. Checks report problems by adding errors to summary.
+// A Check that needs to examine individual files can call the
+// validator's FileIdentifiers and GetGenericFile methods.
+type Check interface {
+	// Name is a short description of the check, for logging.
+	Name() string
+	// Run examines the bag described by validator and obj,
+	// and adds any errors to summary.
+	Run(validator *Validator, obj *models.IntellectualObject, summary *models.WorkSummary)
+}
+
+// CheckFunc is the signature of Check.Run.
+type CheckFunc func(validator *Validator, obj *models.IntellectualObject, summary *models.WorkSummary)
+
+// namedCheck is a Check built from a name and a CheckFunc.
+type namedCheck struct {
+	name string
+	run  CheckFunc
+}
+
+// NewCheck returns a Check with the specified name that calls run.
+func NewCheck(name string, run CheckFunc) Check {
+	return &namedCheck{name: name, run: run}
+}
+
+func (check *namedCheck) Name() string {
+	return check.name
+}
+
+func (check *namedCheck) Run(validator *Validator, obj *models.IntellectualObject, summary *models.WorkSummary) {
+	check.run(validator, obj, summary)
+}
+
+// DefaultChecks returns the checks every validator runs, in order:
+// payload manifest present, top-level folder name, required and
+// forbidden files, and required and allowed tags.
+func DefaultChecks() []Check {
+	return []Check{
+		NewCheck("manifest present", verifyManifestPresent),
+		NewCheck("top-level folder", verifyTopLevelFolder),
+		NewCheck("file specs", verifyFileSpecs),
+		NewCheck("tag specs", verifyTagSpecs),
+	}
+}
+
+// NewForbiddenExtensionsCheck returns a Check that adds an error
+// for each payload file whose name ends with one of the specified
+// extensions, such as ".exe". The comparison is case-insensitive.
+// Institutions that don't want certain file types in their
+// bags can register this with Validator.AddCheck.
+func NewForbiddenExtensionsCheck(extensions ...string) Check {
+	name := fmt.Sprintf("forbidden extensions (%s)", strings.Join(extensions, ", "))
+	return NewCheck(name, func(validator *Validator, obj *models.IntellectualObject, summary *models.WorkSummary) {
+		for _, gfIdentifier := range validator.FileIdentifiers() {
+			gf, err := validator.GetGenericFile(gfIdentifier)
+			if err != nil || gf == nil {
+				summary.AddError("Cannot get GenericFile %s from db: %v", gfIdentifier, err)
+				continue
+			}
+			if gf.IngestFileType != constants.PAYLOAD_FILE {
+				continue
+			}
+			ext := strings.ToLower(path.Ext(gf.OriginalPath()))
+			for _, forbidden := range extensions {
+				if ext != "" && ext == strings.ToLower(forbidden) {
+					summary.AddError("File '%s' has forbidden extension '%s'.",
+						gf.OriginalPath(), forbidden)
+				}
+			}
+		}
+	})
+}
+
+// NewStorageOptionCheck returns a Check that adds an error if the
+// bag's Storage-Option is not one of allowed. apt_fetch adds this for
+// institutions whose InstitutionOverrides limit their storage options.
+func NewStorageOptionCheck(allowed ...string) Check {
+	name := fmt.Sprintf("storage option (%s)", strings.Join(allowed, ", "))
+	return NewCheck(name, func(validator *Validator, obj *models.IntellectualObject, summary *models.WorkSummary) {
+		if !util.StringListContains(allowed, obj.StorageOption) {
+			summary.AddError("Storage-Option '%s' is not allowed for this institution. "+
+				"Use one of: %s.", obj.StorageOption, strings.Join(allowed, ", "))
+		}
+	})
+}
+
+// verifyManifestPresent checks to see if at least one payload manifest
+// is present in the bag. If not, it adds an error message to the
+// WorkSummary.
+func verifyManifestPresent(validator *Validator, obj *models.IntellectualObject, summary *models.WorkSummary) {
+	if len(validator.manifests) == 0 {
+		summary.AddError("Bag contains no payload manifest.")
+	}
+}
+
+// verifyTopLevelFolder ensures the top-level folder inside a tar file
+// has the same name as the bag. There should be exactly one top-level
+// folder whose name is the same as the bag. Anything else is an error.
+func verifyTopLevelFolder(validator *Validator, obj *models.IntellectualObject, summary *models.WorkSummary) {
+	if obj.IngestTarFilePath == "" {
+		return
+	}
+	baseName := path.Base(obj.IngestTarFilePath)
+	// Not sure why, but the Go path library uses hard-coded forward slashes.
+	// https://golang.org/src/path/path.go?s=4737:4766#L171
+	// That causes a problem on Windows.
+	if runtime.GOOS == "windows" {
+		parts := strings.Split(obj.IngestTarFilePath, "\\")
+		baseName = parts[len(parts)-1]
+	}
+	expectedDirName := TAR_SUFFIX.ReplaceAllString(baseName, "")
+	dirNames := obj.IngestTopLevelDirNames
+	if dirNames != nil {
+		for _, dirName := range dirNames {
+			if dirName != expectedDirName {
+				summary.AddError(
+					"Tarred bag should untar to directory '%s', not '%s'",
+					expectedDirName, dirName)
+			}
+		}
+	}
+}
+
+// verifyFileSpecs ensures required files are present and forbidden files
+// are not. This adds an error to the WorkSummary for any violations.
+func verifyFileSpecs(validator *Validator, obj *models.IntellectualObject, summary *models.WorkSummary) {
+	for gfPath, fileSpec := range validator.BagValidationConfig.FileSpecs {
+		if fileSpec.Presence == REQUIRED && !util.StringListContains(validator.requiredFiles, gfPath) {
+			summary.AddError("Required file '%s' is missing.", gfPath)
+		} else if fileSpec.Presence == FORBIDDEN && util.StringListContains(validator.forbiddenFiles, gfPath) {
+			summary.AddError("Bag contains forbidden file '%s'.", gfPath)
+		}
+	}
+}
+
+// verifyTagSpecs ensures required tags are present and forbidden tags
+// are not. The validator checks tag values as it parses the tags.
+func verifyTagSpecs(validator *Validator, obj *models.IntellectualObject, summary *models.WorkSummary) {
+	for tagName, tagSpec := range validator.BagValidationConfig.TagSpecs {
+		tags := obj.FindTag(tagName)
+		if tagSpec.Presence == FORBIDDEN {
+			if len(tags) > 0 {
+				summary.AddError("Forbidden tag '%s' found in file '%s'.",
+					tagName, tags[0].SourceFile)
+			}
+			continue
+		}
+		if tagSpec.Presence == REQUIRED {
+			checkRequiredTag(tagName, tags, tagSpec, summary)
+		}
+	}
+}
+
+// checkRequiredTag ensures that a required tag is present.
+// It adds and error to the WorkSummary if not.
+func checkRequiredTag(tagName string, tags []*models.Tag, tagSpec TagSpec, summary *models.WorkSummary) {
+	if tags == nil {
+		summary.AddError("Required tag '%s' is missing.", tagName)
+		return
+	}
+	if !tagSpec.EmptyOK {
+		tagHasValue := false
+		for _, tag := range tags {
+			if tag.Value != "" {
+				tagHasValue = true
+				break
+			}
+		}
+		if !tagHasValue {
+			summary.AddError("Value for tag '%s' is missing.", tagName)
+		}
+	}
+}
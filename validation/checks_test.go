@@ -0,0 +1,91 @@
+package validation_test
+
+import (
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/testhelper"
+	"github.com/APTrust/exchange/util"
+	"github.com/APTrust/exchange/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultChecks(t *testing.T) {
+	names := make([]string, 0)
+	for _, check := range validation.DefaultChecks() {
+		names = append(names, check.Name())
+	}
+	assert.Equal(t, []string{"manifest present", "top-level folder",
+		"file specs", "tag specs"}, names)
+
+	validator := getValidator(t, "example.edu.tagsample_good.tar", false)
+	assert.Equal(t, 4, len(validator.Checks))
+}
+
+func TestValidator_AddCheck(t *testing.T) {
+	validator := getValidator(t, "example.edu.tagsample_good.tar", false)
+	defer deleteFile(validator.DBName())
+	filesSeen := 0
+	validator.AddCheck(validation.NewCheck("institution check",
+		func(v *validation.Validator, obj *models.IntellectualObject, summary *models.WorkSummary) {
+			assert.Equal(t, "example.edu.tagsample_good", obj.Identifier)
+			filesSeen = len(v.FileIdentifiers())
+			summary.AddError("Institution does not like this bag.")
+		}))
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.Equal(t, 16, filesSeen)
+	assert.Equal(t, []string{"Institution does not like this bag."}, summary.ErrorMessages())
+}
+
+func TestValidator_RemoveDefaultChecks(t *testing.T) {
+	// Without the tag specs check, the missing Title is not an error.
+	// The validator checks tag values as it parses them, so the bad
+	// Access value still is.
+	validator := getValidator(t, "example.edu.tagsample_bad.tar", false)
+	defer deleteFile(validator.DBName())
+	validator.Checks = validator.Checks[:3]
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.True(t, summary.HasErrors())
+	assert.False(t, util.StringListContains(summary.ErrorMessages(), err_3))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_4))
+}
+
+func TestForbiddenExtensionsCheck(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.tagsample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	err = ioutil.WriteFile(filepath.Join(bagPath, "data", "setup.EXE"), []byte("MZ"), 0644)
+	require.Nil(t, err)
+	validator := getValidator(t, bagPath, false)
+	defer deleteFile(validator.DBName())
+	validator.AddCheck(validation.NewForbiddenExtensionsCheck(".exe", ".dll"))
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.True(t, util.StringListContains(summary.ErrorMessages(),
+		"File 'data/setup.EXE' has forbidden extension '.exe'."))
+}
+
+func TestStorageOptionCheck(t *testing.T) {
+	validator := getValidator(t, "example.edu.tagsample_good.tar", false)
+	defer deleteFile(validator.DBName())
+	validator.AddCheck(validation.NewStorageOptionCheck(constants.StorageStandard))
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors())
+
+	validator = getValidator(t, "example.edu.tagsample_good.tar", false)
+	defer deleteFile(validator.DBName())
+	validator.AddCheck(validation.NewStorageOptionCheck(constants.StorageGlacierDeepOR))
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Storage-Option 'Standard' is not allowed for this institution. " +
+		"Use one of: Glacier-Deep-OR."}, summary.ErrorMessages())
+}
@@ -0,0 +1,122 @@
+package validation
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/APTrust/exchange/util"
+	"github.com/APTrust/exchange/util/fileutil"
+)
+
+// These are the codes for an UnsafePathError.
+const (
+	// PATH_TRAVERSAL means a path has a ".." component.
+	PATH_TRAVERSAL = "PATH_TRAVERSAL"
+	// PATH_ABSOLUTE means a path starts with a slash or a
+	// Windows drive letter.
+	PATH_ABSOLUTE = "PATH_ABSOLUTE"
+	// PATH_CONTROL_CHARACTER means a path contains a control
+	// character, such as a tab or a null byte. Line feeds and
+	// carriage returns are allowed, because BagIt manifests can
+	// percent-encode them.
+	PATH_CONTROL_CHARACTER = "PATH_CONTROL_CHARACTER"
+	// PATH_BACKSLASH means a path contains a backslash, which
+	// Windows treats as a path separator.
+	PATH_BACKSLASH = "PATH_BACKSLASH"
+)
+
+var windowsDrive = regexp.MustCompile(`^[A-Za-z]:`)
+
+// UnsafePathError describes a file in a bag whose path could place
+// the file outside the bag's directory when the bag is unpacked, or
+// that is otherwise illegal. Code is one of the PATH_* constants.
+type UnsafePathError struct {
+	Path string
+	Code string
+}
+
+func (err *UnsafePathError) Error() string {
+	description := ""
+	switch err.Code {
+	case PATH_TRAVERSAL:
+		description = "refers to a parent directory"
+	case PATH_ABSOLUTE:
+		description = "is an absolute path"
+	case PATH_CONTROL_CHARACTER:
+		description = "contains a control character"
+	case PATH_BACKSLASH:
+		description = "contains a backslash"
+	}
+	// Quote paths with control characters, so they print legibly.
+	quotedPath := "'" + err.Path + "'"
+	if util.ContainsControlCharacter(err.Path) {
+		quotedPath = strconv.Quote(err.Path)
+	}
+	return fmt.Sprintf("%s: File path %s %s. Bags may not contain files like this.",
+		err.Code, quotedPath, description)
+}
+
+// CheckFilePath returns an UnsafePathError if filePath contains
+// "..", starts with a slash or drive letter, or contains a control
+// character or a backslash. Otherwise, it returns nil. Escaped
+// control characters, like the ones Mac OS puts in file names,
+// count as control characters, not backslashes. Line feeds and
+// carriage returns don't count, because the BagIt spec lets
+// manifests list files whose names contain them.
+func CheckFilePath(filePath string) error {
+	code := ""
+	if hasIllegalControlCharacter(filePath) {
+		code = PATH_CONTROL_CHARACTER
+	} else if strings.Contains(filePath, "\\") {
+		code = PATH_BACKSLASH
+	} else if strings.HasPrefix(filePath, "/") || windowsDrive.MatchString(filePath) {
+		code = PATH_ABSOLUTE
+	} else if util.StringListContains(strings.Split(filePath, "/"), "..") {
+		code = PATH_TRAVERSAL
+	}
+	if code != "" {
+		return &UnsafePathError{Path: filePath, Code: code}
+	}
+	return nil
+}
+
+// hasIllegalControlCharacter returns true if filePath contains a
+// control character, or something that looks like an escaped one,
+// other than a line feed or carriage return.
+func hasIllegalControlCharacter(filePath string) bool {
+	withoutNewlines := strings.NewReplacer("\n", "", "\r", "").Replace(filePath)
+	return util.ContainsControlCharacter(withoutNewlines) ||
+		util.LooksLikeEscapedControl(filePath)
+}
+
+// checkFileSummaryPath checks the path of the file described by
+// fileSummary. For files in a tar or zip file, it checks the full
+// name of the archive entry, because the relative path leaves out
+// the top-level directory, which may itself be unsafe. Relative paths
+// of files on the file system use the OS path separator, which is a
+// backslash on Windows.
+func checkFileSummaryPath(fileSummary *fileutil.FileSummary) error {
+	if fileSummary.ArchivePath != "" {
+		return CheckFilePath(fileSummary.ArchivePath)
+	}
+	return CheckFilePath(filepath.ToSlash(fileSummary.RelPath))
+}
+
+// junkFileNames are files that operating systems and file browsers
+// create on their own, and that depositors rarely mean to preserve.
+var junkFileNames = []string{".DS_Store", "Thumbs.db", "desktop.ini", ".Trashes"}
+
+// isJunkFile returns true if relPath looks like a file that the
+// operating system created, such as .DS_Store, Thumbs.db, or a Mac
+// "._" resource fork, or a file in a __MACOSX directory.
+func isJunkFile(relPath string) bool {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	if util.StringListContains(parts, "__MACOSX") {
+		return true
+	}
+	name := parts[len(parts)-1]
+	return strings.HasPrefix(name, "._") || util.StringListContains(junkFileNames, name)
+}
@@ -0,0 +1,98 @@
+package validation_test
+
+import (
+	"archive/tar"
+	"github.com/APTrust/exchange/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckFilePath(t *testing.T) {
+	for _, safePath := range []string{
+		"bag/data/file.txt",
+		"bag/data/..file.txt",
+		"bag/data/file..txt",
+		"bag/data/",
+		"bag/data/file\n.txt",
+		"bag/data/file\r.txt",
+	} {
+		assert.Nil(t, validation.CheckFilePath(safePath), safePath)
+	}
+	unsafePaths := map[string]string{
+		"../etc/cron.d/evil":   validation.PATH_TRAVERSAL,
+		"bag/../../evil":       validation.PATH_TRAVERSAL,
+		"bag/data/..":          validation.PATH_TRAVERSAL,
+		"/etc/passwd":          validation.PATH_ABSOLUTE,
+		"C:/Windows/evil.dll":  validation.PATH_ABSOLUTE,
+		"bag/data/file\t.txt":  validation.PATH_CONTROL_CHARACTER,
+		"bag/data/file\x00txt": validation.PATH_CONTROL_CHARACTER,
+		"bag\\..\\evil":        validation.PATH_BACKSLASH,
+		"bag/data/a\\b.txt":    validation.PATH_BACKSLASH,
+	}
+	for unsafePath, code := range unsafePaths {
+		err := validation.CheckFilePath(unsafePath)
+		require.NotNil(t, err, unsafePath)
+		unsafePathError, ok := err.(*validation.UnsafePathError)
+		require.True(t, ok, unsafePath)
+		assert.Equal(t, code, unsafePathError.Code, unsafePath)
+		assert.Equal(t, unsafePath, unsafePathError.Path)
+		assert.True(t, strings.HasPrefix(err.Error(), code+": "), err.Error())
+	}
+}
+
+// writeTarWithEntry writes a tarred bag to dir that contains a
+// bagit.txt file and a file with the specified entry name.
+func writeTarWithEntry(t *testing.T, dir, entryName string) string {
+	tarPath := filepath.Join(dir, "example.edu.unsafe.tar")
+	file, err := os.Create(tarPath)
+	require.Nil(t, err)
+	defer file.Close()
+	tarWriter := tar.NewWriter(file)
+	for _, name := range []string{"example.edu.unsafe/bagit.txt", entryName} {
+		content := []byte("BagIt-Version: 0.97\n")
+		require.Nil(t, tarWriter.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err = tarWriter.Write(content)
+		require.Nil(t, err)
+	}
+	require.Nil(t, tarWriter.Close())
+	return tarPath
+}
+
+func TestValidator_UnsafePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unsafe_paths")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	unsafeEntries := map[string]string{
+		"example.edu.unsafe/../../evil.txt":  validation.PATH_TRAVERSAL,
+		"../evil.txt":                        validation.PATH_TRAVERSAL,
+		"/etc/evil.txt":                      validation.PATH_ABSOLUTE,
+		"example.edu.unsafe/data/evil\t.txt": validation.PATH_CONTROL_CHARACTER,
+		"example.edu.unsafe\\..\\evil.txt":   validation.PATH_BACKSLASH,
+	}
+	for entryName, code := range unsafeEntries {
+		tarPath := writeTarWithEntry(t, dir, entryName)
+		validator, err := validation.NewValidator(tarPath, getConfig(t), false)
+		require.Nil(t, err)
+		summary, err := validator.Validate()
+		require.Nil(t, err)
+		assert.True(t, summary.ErrorIsFatal, entryName)
+		found := false
+		for _, message := range summary.ErrorMessages() {
+			if strings.Contains(message, code+": ") {
+				found = true
+			}
+		}
+		assert.True(t, found, "%s: %s", entryName, summary.AllErrorsAsString())
+		os.Remove(validator.DBName())
+	}
+}
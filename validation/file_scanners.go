@@ -0,0 +1,134 @@
+package validation
+
+import (
+	"errors"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util/fileutil"
+	"io"
+	"io/ioutil"
+)
+
+// FileScanner examines the contents of each file in the bag as the
+// validator reads it, so deployments can plug in virus scanning or
+// format identification without forking the validator. Scanners see
+// the same bytes the validator hashes, in the same pass, so they
+// don't add a second read of the bag. The validator adds any error
+// a scanner returns to its WorkSummary, naming the scanner and the
+// file. A scanner error does not stop validation.
+//
+// When ChecksumWorkers is greater than one, the validator scans
+// several files at once, so Scan must be safe for concurrent use.
+// ValidateStructureOnly does not read payload files, so scanners
+// see only tag files and manifests in that mode.
+type FileScanner interface {
+	// Name is a short description of the scanner, for error messages.
+	Name() string
+	// Scan examines the file described by fileSummary, whose
+	// contents come from reader. Scan does not have to read
+	// the whole file.
+	Scan(reader io.Reader, fileSummary *fileutil.FileSummary) error
+}
+
+// GenericFileScanner is a FileScanner that records what it learns
+// about each file on the file's GenericFile, such as its format.
+// The validator calls ScanGenericFile instead of Scan for scanners
+// that implement this interface. The validator does not touch gf
+// until ScanGenericFile returns.
+type GenericFileScanner interface {
+	FileScanner
+	ScanGenericFile(reader io.Reader, fileSummary *fileutil.FileSummary, gf *models.GenericFile) error
+}
+
+// ScanWarning is an error that a scanner returns when it could not
+// do its job, but the file itself may be fine. The validator records
+// it as a warning rather than an error.
+type ScanWarning struct {
+	message string
+}
+
+// NewScanWarning returns a ScanWarning with the specified message.
+func NewScanWarning(message string) *ScanWarning {
+	return &ScanWarning{message: message}
+}
+
+func (warning *ScanWarning) Error() string {
+	return warning.message
+}
+
+// FileScanFunc is the signature of FileScanner.Scan.
+type FileScanFunc func(reader io.Reader, fileSummary *fileutil.FileSummary) error
+
+// namedScanner is a FileScanner built from a name and a FileScanFunc.
+type namedScanner struct {
+	name string
+	scan FileScanFunc
+}
+
+// NewFileScanner returns a FileScanner with the specified name
+// that calls scan.
+func NewFileScanner(name string, scan FileScanFunc) FileScanner {
+	return &namedScanner{name: name, scan: scan}
+}
+
+func (scanner *namedScanner) Name() string {
+	return scanner.name
+}
+
+func (scanner *namedScanner) Scan(reader io.Reader, fileSummary *fileutil.FileSummary) error {
+	return scanner.scan(reader, fileSummary)
+}
+
+// fileScan is a FileScanner running on one file. The validator
+// writes the file's contents to writer, and the scanner reads
+// them from the other end of the pipe.
+type fileScan struct {
+	scanner FileScanner
+	writer  *io.PipeWriter
+	done    chan error
+}
+
+// startScans starts each of the validator's scanners on the file
+// described by fileSummary, whose record is gf. Call finishScans after
+// writing the file's contents to the scans' writers.
+func (validator *Validator) startScans(fileSummary *fileutil.FileSummary, gf *models.GenericFile) []*fileScan {
+	scans := make([]*fileScan, len(validator.Scanners))
+	for i, scanner := range validator.Scanners {
+		reader, writer := io.Pipe()
+		scan := &fileScan{
+			scanner: scanner,
+			writer:  writer,
+			done:    make(chan error, 1),
+		}
+		go func() {
+			var err error
+			if gfScanner, ok := scan.scanner.(GenericFileScanner); ok {
+				err = gfScanner.ScanGenericFile(reader, fileSummary, gf)
+			} else {
+				err = scan.scanner.Scan(reader, fileSummary)
+			}
+			// Drain whatever the scanner didn't read, so
+			// the validator's writes don't block.
+			io.Copy(ioutil.Discard, reader)
+			scan.done <- err
+		}()
+		scans[i] = scan
+	}
+	return scans
+}
+
+// finishScans waits for scans to finish, and records any errors
+// and warnings against the file at pathInBag.
+func (validator *Validator) finishScans(scans []*fileScan, pathInBag string) {
+	for _, scan := range scans {
+		scan.writer.Close()
+		err := <-scan.done
+		var warning *ScanWarning
+		if errors.As(err, &warning) {
+			validator.summary.AddWarning("File scanner '%s' could not check file '%s': %v",
+				scan.scanner.Name(), pathInBag, warning)
+		} else if err != nil {
+			validator.summary.AddError("File scanner '%s' reported a problem with file '%s': %v",
+				scan.scanner.Name(), pathInBag, err)
+		}
+	}
+}
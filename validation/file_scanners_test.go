@@ -0,0 +1,93 @@
+package validation_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/testhelper"
+	"github.com/APTrust/exchange/util/fileutil"
+	"github.com/APTrust/exchange/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+// sizeScanner reads each file and records its size, so we can
+// tell the scanner saw the same bytes the validator hashed.
+type sizeScanner struct {
+	sizes map[string]int64
+	mutex sync.Mutex
+}
+
+func (scanner *sizeScanner) Name() string { return "size" }
+
+func (scanner *sizeScanner) Scan(reader io.Reader, fileSummary *fileutil.FileSummary) error {
+	size, err := io.Copy(ioutil.Discard, reader)
+	scanner.mutex.Lock()
+	scanner.sizes[fileSummary.RelPath] = size
+	scanner.mutex.Unlock()
+	return err
+}
+
+// virusScanner rejects files whose contents start with "Title".
+func virusScanner(reader io.Reader, fileSummary *fileutil.FileSummary) error {
+	start := make([]byte, 5)
+	io.ReadFull(reader, start)
+	if bytes.Equal(start, []byte("Title")) {
+		return fmt.Errorf("looks infected")
+	}
+	return nil
+}
+
+func TestValidator_FileScanners(t *testing.T) {
+	validator := validatorWithOptionalSpec(t, "example.edu.tagsample_good.tar")
+	defer deleteFile(validator.DBName())
+	sizes := &sizeScanner{sizes: make(map[string]int64)}
+	validator.AddFileScanner(sizes)
+	validator.AddFileScanner(validation.NewFileScanner("virus", virusScanner))
+	require.Equal(t, 2, len(validator.Scanners))
+	// The scanners are done by the time the checks run.
+	fileSizes := make(map[string]int64)
+	validator.AddCheck(validation.NewCheck("sizes", func(v *validation.Validator, obj *models.IntellectualObject, summary *models.WorkSummary) {
+		for _, identifier := range v.FileIdentifiers() {
+			gf, _ := v.GetGenericFile(identifier)
+			fileSizes[gf.OriginalPath()] = gf.Size
+		}
+	}))
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+
+	// Only the virus scanner complained, about a single file,
+	// and the bag was otherwise checked as usual.
+	require.Equal(t, 1, len(summary.Errors), summary.AllErrorsAsString())
+	assert.Equal(t, "File scanner 'virus' reported a problem with file 'aptrust-info.txt': looks infected",
+		summary.Errors[0].Message)
+
+	// The size scanner saw every file, whole.
+	assert.Equal(t, 16, len(fileSizes))
+	assert.Equal(t, fileSizes, sizes.sizes)
+}
+
+func TestValidator_FileScannersParallel(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.tagsample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	bagValidationConfig, err := getValidationConfig()
+	require.Nil(t, err)
+	validator, err := validation.NewValidator(bagPath, bagValidationConfig, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	validator.ChecksumWorkers = 4
+	validator.AddFileScanner(validation.NewFileScanner("virus", virusScanner))
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(summary.Errors), summary.AllErrorsAsString())
+	assert.Equal(t, "File scanner 'virus' reported a problem with file 'aptrust-info.txt': looks infected",
+		summary.Errors[0].Message)
+}
@@ -0,0 +1,54 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util/fileutil"
+	"io"
+)
+
+// FormatIdentifier identifies the format of a file from its name and
+// contents. network.SiegfriedClient is a FormatIdentifier.
+type FormatIdentifier interface {
+	Identify(filename string, reader io.Reader) (*models.FormatIdentification, error)
+}
+
+// FormatScanner is a GenericFileScanner that identifies the PRONOM
+// format of each payload file, and records it in the file's
+// PronomPuid and FormatConfidence. It skips tag files and manifests,
+// whose formats we already know. If the identifier fails, the
+// validator records a warning, since the bag is still valid.
+type FormatScanner struct {
+	identifier FormatIdentifier
+}
+
+// NewFormatScanner returns a FormatScanner that uses identifier.
+func NewFormatScanner(identifier FormatIdentifier) *FormatScanner {
+	return &FormatScanner{identifier: identifier}
+}
+
+// Name returns the name of this scanner.
+func (scanner *FormatScanner) Name() string {
+	return "format"
+}
+
+// Scan is here to satisfy the FileScanner interface. The validator
+// calls ScanGenericFile instead.
+func (scanner *FormatScanner) Scan(reader io.Reader, fileSummary *fileutil.FileSummary) error {
+	return scanner.ScanGenericFile(reader, fileSummary, nil)
+}
+
+// ScanGenericFile identifies the format of the file whose contents
+// come from reader, and records it on gf.
+func (scanner *FormatScanner) ScanGenericFile(reader io.Reader, fileSummary *fileutil.FileSummary, gf *models.GenericFile) error {
+	if gf == nil || gf.IngestFileType != constants.PAYLOAD_FILE {
+		return nil
+	}
+	identification, err := scanner.identifier.Identify(fileSummary.RelPath, reader)
+	if err != nil {
+		return NewScanWarning(fmt.Sprintf("Format identification failed: %v", err))
+	}
+	identification.ApplyTo(gf)
+	return nil
+}
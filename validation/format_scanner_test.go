@@ -0,0 +1,78 @@
+package validation_test
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeIdentifier identifies XML files, and can't cope with MARC.
+type fakeIdentifier struct {
+	identified []string
+	mutex      sync.Mutex
+}
+
+func (identifier *fakeIdentifier) Identify(filename string, reader io.Reader) (*models.FormatIdentification, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	identifier.mutex.Lock()
+	identifier.identified = append(identifier.identified, filename)
+	identifier.mutex.Unlock()
+	if strings.HasSuffix(filename, "MARC") {
+		return nil, fmt.Errorf("server unavailable")
+	}
+	if strings.HasPrefix(string(data), "<?xml") {
+		return &models.FormatIdentification{PronomPuid: "fmt/101", MimeType: "application/xml"}, nil
+	}
+	return &models.FormatIdentification{PronomPuid: "UNKNOWN"}, nil
+}
+
+func TestFormatScanner(t *testing.T) {
+	validator := validatorWithOptionalSpec(t, "example.edu.tagsample_good.tar")
+	defer deleteFile(validator.DBName())
+	identifier := &fakeIdentifier{identified: make([]string, 0)}
+	validator.AddFileScanner(validation.NewFormatScanner(identifier))
+	formats := make(map[string]*models.GenericFile)
+	validator.AddCheck(validation.NewCheck("formats", func(v *validation.Validator, obj *models.IntellectualObject, summary *models.WorkSummary) {
+		for _, identifier := range v.FileIdentifiers() {
+			gf, _ := v.GetGenericFile(identifier)
+			formats[gf.OriginalPath()] = gf
+		}
+	}))
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+
+	// Identification failures are warnings, not errors.
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+	require.Equal(t, 1, len(summary.Warnings))
+	assert.Equal(t, "File scanner 'format' could not check file 'data/datastream-MARC': "+
+		"Format identification failed: server unavailable", summary.Warnings[0])
+
+	// Only payload files are identified.
+	assert.Equal(t, 4, len(identifier.identified))
+
+	gf := formats["data/datastream-descMetadata"]
+	require.NotNil(t, gf)
+	assert.Equal(t, "fmt/101", gf.PronomPuid)
+	assert.Equal(t, constants.FormatConfidenceHigh, gf.FormatConfidence)
+	assert.Equal(t, "application/xml", gf.FileFormat)
+
+	gf = formats["data/datastream-MARC"]
+	require.NotNil(t, gf)
+	assert.Equal(t, "", gf.PronomPuid)
+	assert.Equal(t, "", gf.FormatConfidence)
+
+	gf = formats["bag-info.txt"]
+	require.NotNil(t, gf)
+	assert.Equal(t, "", gf.PronomPuid)
+}
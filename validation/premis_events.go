@@ -0,0 +1,88 @@
+package validation
+
+import (
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"time"
+)
+
+// recordingPremisEvents returns true if the validator should build
+// PREMIS events for each GenericFile. It does that only when it's
+// preserving the attributes that apt_record sends to Pharos, and only
+// when it has hashed every file.
+func (validator *Validator) recordingPremisEvents() bool {
+	return (validator.PreserveExtendedAttributes &&
+		!validator.structureOnly && !validator.preScreen)
+}
+
+// buildPremisEvents adds PREMIS events to gf describing what the
+// validator did to it: one digest calculation event for each checksum
+// it calculated, one fixity check event for each manifest digest it
+// checked, and a validation event with the outcome. Param problems
+// describes what was wrong with the file, if anything. The events
+// go into the .valdb with gf, so apt_record can send them to Pharos
+// without recomputing anything. GenericFile.BuildIngestEvents does
+// not build digest calculation or fixity check events for a file
+// that already has them.
+func (validator *Validator) buildPremisEvents(gf *models.GenericFile, problems []string) {
+	digests := []struct {
+		alg            string
+		manifestDigest string
+		fileDigest     string
+		generatedAt    time.Time
+		verifiedAt     time.Time
+	}{
+		{constants.AlgMd5, gf.IngestManifestMd5, gf.IngestMd5,
+			gf.IngestMd5GeneratedAt, gf.IngestMd5VerifiedAt},
+		{constants.AlgSha256, gf.IngestManifestSha256, gf.IngestSha256,
+			gf.IngestSha256GeneratedAt, gf.IngestSha256VerifiedAt},
+		{constants.AlgSha512, gf.IngestManifestSha512, gf.IngestSha512,
+			gf.IngestSha512GeneratedAt, gf.IngestSha512VerifiedAt},
+		{constants.AlgBlake2b, gf.IngestManifestBlake2b, gf.IngestBlake2b,
+			gf.IngestBlake2bGeneratedAt, gf.IngestBlake2bVerifiedAt},
+	}
+	events := make([]*models.PremisEvent, 0)
+	for _, digest := range digests {
+		if digest.fileDigest == "" {
+			continue
+		}
+		event, err := models.NewEventGenericFileDigestCalculation(
+			digest.generatedAt, digest.alg, digest.fileDigest)
+		if err != nil {
+			validator.summary.AddError("Cannot build %s digest calculation event for '%s': %v",
+				digest.alg, gf.OriginalPath(), err)
+			continue
+		}
+		events = append(events, event)
+		if digest.manifestDigest == "" {
+			continue
+		}
+		matched := (digest.manifestDigest == digest.fileDigest)
+		checkedAt := digest.verifiedAt
+		if !matched {
+			checkedAt = time.Now().UTC()
+		} else if checkedAt.After(gf.LastFixityCheck) {
+			gf.LastFixityCheck = checkedAt
+		}
+		event, err = models.NewEventGenericFileFixityCheck(
+			checkedAt, digest.alg, digest.fileDigest, matched)
+		if err != nil {
+			validator.summary.AddError("Cannot build %s fixity check event for '%s': %v",
+				digest.alg, gf.OriginalPath(), err)
+			continue
+		}
+		events = append(events, event)
+	}
+	event, err := models.NewEventGenericFileValidation(time.Now().UTC(), problems)
+	if err != nil {
+		validator.summary.AddError("Cannot build validation event for '%s': %v",
+			gf.OriginalPath(), err)
+	} else {
+		events = append(events, event)
+	}
+	for _, event := range events {
+		event.IntellectualObjectIdentifier = gf.IntellectualObjectIdentifier
+		event.GenericFileIdentifier = gf.Identifier
+		gf.PremisEvents = append(gf.PremisEvents, event)
+	}
+}
@@ -0,0 +1,35 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/APTrust/exchange/constants"
+)
+
+// CheckRightsTag returns an error if label is Embargo-Until and value
+// is not a date in the format YYYY-MM-DD, or if label is
+// Rights-Statement and value is not an absolute http or https URI.
+// These tags come from the aptrust-info.txt file. Empty values are
+// fine, and so are values of other tags.
+func CheckRightsTag(label, value string) error {
+	if value == "" {
+		return nil
+	}
+	switch strings.ToLower(label) {
+	case "embargo-until":
+		if _, err := time.Parse(constants.EmbargoDateFormat, value); err != nil {
+			return fmt.Errorf("Tag '%s' has value '%s', which is not a valid "+
+				"date in the format YYYY-MM-DD.", label, value)
+		}
+	case "rights-statement":
+		uri, err := url.Parse(value)
+		if err != nil || (uri.Scheme != "http" && uri.Scheme != "https") || uri.Host == "" {
+			return fmt.Errorf("Tag '%s' has value '%s', which is not an "+
+				"http or https URI.", label, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package validation_test
+
+import (
+	"github.com/APTrust/exchange/validation"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCheckRightsTag(t *testing.T) {
+	assert.Nil(t, validation.CheckRightsTag("Embargo-Until", "2030-01-31"))
+	assert.Nil(t, validation.CheckRightsTag("embargo-until", ""))
+	assert.Nil(t, validation.CheckRightsTag("Rights-Statement", "http://rightsstatements.org/vocab/InC/1.0/"))
+	assert.Nil(t, validation.CheckRightsTag("Rights-Statement", "https://creativecommons.org/licenses/by/4.0/"))
+	assert.Nil(t, validation.CheckRightsTag("Title", "Not a date or a URI"))
+
+	for _, value := range []string{"2030-02-30", "01/31/2030", "2030-01-31T00:00:00Z", "someday"} {
+		err := validation.CheckRightsTag("Embargo-Until", value)
+		if assert.NotNil(t, err, value) {
+			assert.Equal(t, "Tag 'Embargo-Until' has value '"+value+
+				"', which is not a valid date in the format YYYY-MM-DD.", err.Error())
+		}
+	}
+	for _, value := range []string{"rightsstatements.org/vocab/InC/1.0/", "ftp://example.com/rights", "https://", "In Copyright"} {
+		err := validation.CheckRightsTag("Rights-Statement", value)
+		if assert.NotNil(t, err, value) {
+			assert.Equal(t, "Tag 'Rights-Statement' has value '"+value+
+				"', which is not an http or https URI.", err.Error())
+		}
+	}
+}
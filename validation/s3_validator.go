@@ -0,0 +1,49 @@
+package validation
+
+import (
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/APTrust/exchange/network"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// NewS3Validator creates a Validator that streams the tarred bag at
+// bucket/key in the specified AWS region, without writing the tar file
+// to disk. It gets AWS credentials from the environment. The validator
+// keeps its .valdb validation database in workingDir. See
+// NewStreamingValidator for more info.
+func NewS3Validator(region, bucket, key, workingDir string, bagValidationConfig *BagValidationConfig, preserveExtendedAttributes bool) (*Validator, error) {
+	_session, err := network.GetS3Session(region, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return NewS3ValidatorWithClient(s3.New(_session), bucket, key, workingDir,
+		bagValidationConfig, preserveExtendedAttributes)
+}
+
+// NewS3ValidatorWithClient is the same as NewS3Validator, except that
+// it reads from S3 with the specified client.
+func NewS3ValidatorWithClient(client s3iface.S3API, bucket, key, workingDir string, bagValidationConfig *BagValidationConfig, preserveExtendedAttributes bool) (*Validator, error) {
+	openStream := func() (io.ReadCloser, error) {
+		output, err := client.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read s3://%s/%s: %v", bucket, key, err)
+		}
+		return output.Body, nil
+	}
+	validator, err := NewStreamingValidator(path.Base(key), workingDir, openStream,
+		bagValidationConfig, preserveExtendedAttributes)
+	if err != nil {
+		return nil, err
+	}
+	validator.s3Bucket = bucket
+	validator.s3Key = key
+	return validator, nil
+}
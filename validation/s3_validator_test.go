@@ -0,0 +1,122 @@
+package validation_test
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/util"
+	"github.com/APTrust/exchange/util/storage"
+	"github.com/APTrust/exchange/validation"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mockS3 serves tarred test bags in response to GetObject.
+type mockS3 struct {
+	s3iface.S3API
+	t        *testing.T
+	requests int
+}
+
+func (mock *mockS3) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	mock.requests++
+	if *input.Bucket != "aptrust.receiving.example.edu" {
+		return nil, fmt.Errorf("NoSuchBucket")
+	}
+	file, err := os.Open(getBagPath(mock.t, *input.Key))
+	if err != nil {
+		return nil, err
+	}
+	return &s3.GetObjectOutput{Body: file}, nil
+}
+
+func TestNewStreamingValidator(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "streaming_validator")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	openStream := func() (io.ReadCloser, error) {
+		return os.Open(getBagPath(t, "example.edu.tagsample_good.tar"))
+	}
+	validator, err := validation.NewStreamingValidator("example.edu.tagsample_good.tar",
+		tempDir, openStream, getConfig(t), true)
+	require.Nil(t, err)
+	assert.Equal(t, "example.edu.tagsample_good", validator.ObjIdentifier)
+	assert.Equal(t, filepath.Join(tempDir, "example.edu.tagsample_good.valdb"), validator.DBName())
+
+	_, err = validation.NewStreamingValidator("example.edu.tagsample_good",
+		tempDir, openStream, getConfig(t), true)
+	require.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "only tarred bags can be streamed"))
+
+	_, err = validation.NewStreamingValidator("example.edu.tagsample_good.tar",
+		filepath.Join(tempDir, "does-not-exist"), openStream, getConfig(t), true)
+	assert.NotNil(t, err)
+
+	_, err = validation.NewStreamingValidator("example.edu.tagsample_good.tar",
+		tempDir, nil, getConfig(t), true)
+	assert.NotNil(t, err)
+
+	_, err = validation.NewStreamingValidator("example.edu.tagsample_good.tar",
+		tempDir, openStream, nil, true)
+	assert.NotNil(t, err)
+}
+
+func TestS3Validator_BagValid(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "s3_validator")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	client := &mockS3{t: t}
+	validator, err := validation.NewS3ValidatorWithClient(client, "aptrust.receiving.example.edu",
+		"example.edu.tagsample_good.tar", tempDir, getConfig(t), true)
+	require.Nil(t, err)
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+
+	// The validator reads the bag only once.
+	assert.Equal(t, 1, client.requests)
+
+	db, err := storage.NewBoltDB(validator.DBName())
+	require.Nil(t, err)
+	defer db.Close()
+	obj, err := db.GetIntellectualObject(validator.ObjIdentifier)
+	require.Nil(t, err)
+	require.NotNil(t, obj)
+	assert.Equal(t, "aptrust.receiving.example.edu", obj.IngestS3Bucket)
+	assert.Equal(t, "example.edu.tagsample_good.tar", obj.IngestS3Key)
+	assert.Empty(t, obj.IngestTarFilePath)
+	assert.True(t, len(db.FileIdentifiers()) > 0)
+}
+
+func TestS3Validator_BagInvalid(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "s3_validator")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	validator, err := validation.NewS3ValidatorWithClient(&mockS3{t: t}, "aptrust.receiving.example.edu",
+		"example.edu.tagsample_bad.tar", tempDir, getConfig(t), false)
+	require.Nil(t, err)
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.True(t, summary.HasErrors())
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_5))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_6))
+}
+
+func TestS3Validator_CannotRead(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "s3_validator")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	validator, err := validation.NewS3ValidatorWithClient(&mockS3{t: t}, "no.such.bucket",
+		"example.edu.tagsample_good.tar", tempDir, getConfig(t), false)
+	require.Nil(t, err)
+	summary, _ := validator.Validate()
+	require.NotNil(t, summary)
+	assert.True(t, summary.HasErrors())
+	assert.True(t, strings.Contains(summary.AllErrorsAsString(), "Cannot read s3://no.such.bucket"))
+}
@@ -2,30 +2,54 @@ package validation
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/md5"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"fmt"
 	"hash"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
-	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/util"
+	"github.com/APTrust/exchange/util/fasthash"
 	"github.com/APTrust/exchange/util/fileutil"
 	"github.com/APTrust/exchange/util/storage"
 	"github.com/google/uuid"
 	"github.com/op/go-logging"
+	"golang.org/x/crypto/blake2b"
 )
 
 const VALIDATION_DB_SUFFIX = ".valdb"
 
-var TAR_SUFFIX = regexp.MustCompile("\\.tar$")
+// DEFAULT_MEMORY_DB_MAX_FILES is a reasonable value for
+// Validator.MemoryDBMaxFiles. Keeping data for this many
+// files in memory takes a few megabytes at most.
+const DEFAULT_MEMORY_DB_MAX_FILES = 1000
+
+// digestLengths is the number of hex characters in a digest
+// of each algorithm the validator can check.
+var digestLengths = map[string]int{
+	constants.AlgMd5:     32,
+	constants.AlgSha1:    40,
+	constants.AlgSha256:  64,
+	constants.AlgSha512:  128,
+	constants.AlgBlake2b: 128,
+	constants.AlgCrc32c:  8,
+	constants.AlgXxh64:   16,
+}
+
+var TAR_SUFFIX = util.SerializedBagSuffix
 
 // Validator validates a BagIt bag using a BagValidationConfig
 // object, which describes the bag's requirements.
@@ -43,12 +67,103 @@ type Validator struct {
 	forbiddenFiles             []string
 	calculateMd5               bool
 	calculateSha256            bool
+	calculateSha512            bool
+	calculateBlake2b           bool
+	calculateSha1              bool
+	calculateCrc32c            bool
+	calculateXxh64             bool
+	payloadByteCount           int64
+	payloadFileCount           int64
+
+	// filesToParse holds the contents of the manifests, tag manifests
+	// and tag files we parse, in the order we read them from the bag.
+	// We keep these in memory as we add files, so we don't have to
+	// read the whole bag a second time to parse them.
+	filesToParse []*fileToParse
+
+	// ChecksumWorkers is the number of goroutines that calculate
+	// checksums when validating an untarred bag. The default, zero,
+	// calculates checksums one file at a time. Tarred bags are always
+	// read one file at a time.
+	ChecksumWorkers int
+
+	// openStream, if set, opens a stream to read the tarred bag from
+	// a remote source, such as S3, instead of from PathToBag. See
+	// NewStreamingValidator. s3Bucket and s3Key describe where the
+	// bag came from, if it came from S3.
+	openStream func() (io.ReadCloser, error)
+	s3Bucket   string
+	s3Key      string
+
+	// Progress, if set, is called after the validator processes each
+	// file while adding files to the validation DB and while parsing
+	// manifests and tag files. Validating a very large bag can take
+	// hours, and this lets callers show that work is still being done.
+	// Progress may be called very frequently, so callers that log or
+	// print progress should throttle their output.
+	Progress func(ValidationProgress)
+	progress ValidationProgress
+
+	// normalizedIdentifiers maps the normalized form of each
+	// GenericFile identifier to the actual identifier. We build it
+	// only if a manifest entry doesn't match a file exactly. See
+	// BagValidationConfig.UnicodeNormalization.
+	normalizedIdentifiers map[string]string
+
+	// structureOnly is true while ValidateStructureOnly is running.
+	// See ValidateStructureOnly.
+	structureOnly bool
+
+	// preScreen is true while PreScreen is running. See PreScreen.
+	preScreen bool
+
+	// fileCount and byteCount tally the files in the bag as we read
+	// it. limitExceeded is true if the bag has more files or bytes
+	// than the config allows. See checkLimits.
+	fileCount     int64
+	byteCount     int64
+	limitExceeded bool
+
+	// unsafePathFound is true if the bag contains a file whose
+	// path could escape the bag directory. See CheckFilePath.
+	unsafePathFound bool
+
+	// Checks is the ordered list of checks the validator runs after
+	// it has read the bag and verified the Payload-Oxum. NewValidator
+	// fills this with DefaultChecks. Use AddCheck to register APTrust
+	// or institution-specific checks.
+	Checks []Check
+
+	// Scanners examine the contents of each file as the validator
+	// reads it. The validator has none by default. Use AddFileScanner
+	// to register virus scanners, format identifiers and the like.
+	Scanners []FileScanner
 
 	// Note that we can have only one open reference to the BoltDB
 	// at a time. If some other piece of code has this DB open,
 	// the validator will not be able to open it. If the validator
 	// has it open, others will not be able to open it.
-	db *storage.BoltDB
+	db storage.ValidationDB
+
+	// UseMemoryDB tells the validator to keep validation data in memory
+	// instead of in a .valdb file. MemoryDBMaxFiles, if greater than
+	// zero, tells the validator to keep validation data in memory until
+	// the bag turns out to have more than that many files, and then to
+	// move it into a .valdb file. Keeping data in memory is much faster
+	// for small bags, and leaves no .valdb file behind. The validator
+	// ignores both of these settings when PreserveExtendedAttributes is
+	// true, because callers that want to preserve ingest attributes
+	// read them from the .valdb file after validation.
+	UseMemoryDB      bool
+	MemoryDBMaxFiles int
+
+	// DBBatchSize is the number of GenericFile records the validator
+	// buffers before writing them to the .valdb file in a single
+	// transaction. Every transaction syncs the file to disk, so writing
+	// records one at a time makes bags with a million small files take
+	// hours. NewValidator sets this to DefaultDBBatchSize. Zero means
+	// write each record immediately.
+	DBBatchSize int
 
 	// This is a late addition, hacked in to help diagnose
 	// some issues in validating very large bags. When we rewrite
@@ -57,9 +172,38 @@ type Validator struct {
 	Logger *logging.Logger
 }
 
+// fileToParse is a manifest or tag file that we've read into memory.
+type fileToParse struct {
+	fileSummary *fileutil.FileSummary
+	content     []byte
+}
+
+// DefaultDBBatchSize is the default for Validator.DBBatchSize.
+const DefaultDBBatchSize = 1000
+
+// Validation phases reported in ValidationProgress.
+const (
+	PhaseAddingFiles  = "adding files"
+	PhaseParsingFiles = "parsing files"
+)
+
+// ValidationProgress describes how far the validator has gotten
+// in reading a bag.
+type ValidationProgress struct {
+	// Phase is the current phase of validation. See the Phase constants.
+	Phase string
+	// FilesProcessed is the number of files processed in the
+	// current phase.
+	FilesProcessed int64
+	// BytesHashed is the number of bytes that have gone through the
+	// checksum calculators. This includes tag files and manifests, as
+	// well as payload files.
+	BytesHashed int64
+}
+
 // NewValidator creates a new Validator. Param pathToBag
-// should be an absolute path to either the tarred bag (.tar file)
-// or to the untarred bag (a directory). Param bagValidationConfig
+// should be an absolute path to either the serialized bag (a .tar,
+// .tar.gz, .tgz or .zip file) or to the untarred bag (a directory). Param bagValidationConfig
 // defines what we need to validate, in addition to the checksums in the
 // manifests. If param preserveExtendedAttributes is true, the validator
 // will preserve special data attributes used by the APTrust ingest
@@ -72,15 +216,57 @@ func NewValidator(pathToBag string, bagValidationConfig *BagValidationConfig, pr
 	if err != nil {
 		return nil, err
 	}
+	return newValidator(pathToBag, bagValidationConfig, preserveExtendedAttributes), nil
+}
+
+// NewStreamingValidator creates a Validator that reads a tarred bag
+// from a stream instead of from the local disk, so we can validate bags
+// that are too large to fit on our local volumes. Param bagName is the
+// name of the tar file, such as "virginia.edu.bag.tar" or
+// "virginia.edu.bag.tar.gz". The validator
+// keeps its .valdb validation database in workingDir, which must exist.
+// The validator calls openStream once to get the stream, and closes the
+// stream when it's done with it. Params bagValidationConfig and preserveExtendedAttributes
+// are the same as for NewValidator.
+func NewStreamingValidator(bagName, workingDir string, openStream func() (io.ReadCloser, error), bagValidationConfig *BagValidationConfig, preserveExtendedAttributes bool) (*Validator, error) {
+	if !util.HasTarSuffix(bagName) {
+		return nil, fmt.Errorf("Cannot stream %s: only tarred bags can be streamed", bagName)
+	}
+	if !fileutil.FileExists(workingDir) {
+		return nil, fmt.Errorf("Working directory %s does not exist", workingDir)
+	}
+	if openStream == nil {
+		return nil, fmt.Errorf("Param openStream cannot be nil")
+	}
+	err := validateConfig(bagValidationConfig)
+	if err != nil {
+		return nil, err
+	}
+	// PathToBag is where the bag would be if it were on the local
+	// disk. We use it to name the validation database.
+	pathToBag := filepath.Join(workingDir, bagName)
+	validator := newValidator(pathToBag, bagValidationConfig, preserveExtendedAttributes)
+	validator.openStream = openStream
+	return validator, nil
+}
+
+// newValidator does the work of constructing a Validator, after
+// the constructors have checked their params.
+func newValidator(pathToBag string, bagValidationConfig *BagValidationConfig, preserveExtendedAttributes bool) *Validator {
 	calculateMd5 := util.StringListContains(bagValidationConfig.FixityAlgorithms, constants.AlgMd5)
 	calculateSha256 := util.StringListContains(bagValidationConfig.FixityAlgorithms, constants.AlgSha256)
+	calculateSha512 := util.StringListContains(bagValidationConfig.FixityAlgorithms, constants.AlgSha512)
+	calculateBlake2b := util.StringListContains(bagValidationConfig.FixityAlgorithms, constants.AlgBlake2b)
+	calculateSha1 := util.StringListContains(bagValidationConfig.FixityAlgorithms, constants.AlgSha1)
+	calculateCrc32c := util.StringListContains(bagValidationConfig.FixityAlgorithms, constants.AlgCrc32c)
+	calculateXxh64 := util.StringListContains(bagValidationConfig.FixityAlgorithms, constants.AlgXxh64)
 	tagFilesToParse := make([]string, 0)
 	for pathToFile, filespec := range bagValidationConfig.FileSpecs {
 		if filespec.ParseAsTagFile {
 			tagFilesToParse = append(tagFilesToParse, pathToFile)
 		}
 	}
-	validator := &Validator{
+	return &Validator{
 		PathToBag:                  pathToBag,
 		BagValidationConfig:        bagValidationConfig,
 		PreserveExtendedAttributes: preserveExtendedAttributes,
@@ -93,8 +279,14 @@ func NewValidator(pathToBag string, bagValidationConfig *BagValidationConfig, pr
 		forbiddenFiles:             make([]string, 0),
 		calculateMd5:               calculateMd5,
 		calculateSha256:            calculateSha256,
+		calculateSha512:            calculateSha512,
+		calculateBlake2b:           calculateBlake2b,
+		calculateSha1:              calculateSha1,
+		calculateCrc32c:            calculateCrc32c,
+		calculateXxh64:             calculateXxh64,
+		Checks:                     DefaultChecks(),
+		DBBatchSize:                DefaultDBBatchSize,
 	}
-	return validator, nil
 }
 
 // validateParams returns an error if there's a problem with the parameters
@@ -103,6 +295,12 @@ func validateParams(pathToBag string, bagValidationConfig *BagValidationConfig)
 	if !fileutil.FileExists(pathToBag) {
 		return fmt.Errorf("Bag does not exist at %s", pathToBag)
 	}
+	return validateConfig(bagValidationConfig)
+}
+
+// validateConfig returns an error if bagValidationConfig is missing
+// or invalid.
+func validateConfig(bagValidationConfig *BagValidationConfig) error {
 	if bagValidationConfig == nil {
 		return fmt.Errorf("Param bagValidationConfig cannot be nil")
 	}
@@ -115,6 +313,9 @@ func validateParams(pathToBag string, bagValidationConfig *BagValidationConfig)
 		return fmt.Errorf(errString)
 	}
 	err := bagValidationConfig.CompileFileNameRegex()
+	if err == nil {
+		err = bagValidationConfig.CompileTagValueRegexes()
+	}
 	if err != nil {
 		return fmt.Errorf("Error in BagValidationConfig: %v", err)
 	}
@@ -124,10 +325,7 @@ func validateParams(pathToBag string, bagValidationConfig *BagValidationConfig)
 // DBName returns the name of the BoltDB file where the validator keeps
 // track of validation data.
 func (validator *Validator) DBName() string {
-	bagPath := validator.PathToBag
-	if strings.HasSuffix(bagPath, ".tar") {
-		bagPath = bagPath[0 : len(bagPath)-4]
-	}
+	bagPath := TAR_SUFFIX.ReplaceAllString(validator.PathToBag, "")
 	if strings.HasSuffix(bagPath, string(os.PathSeparator)) {
 		bagPath = bagPath[0 : len(bagPath)-1]
 	}
@@ -138,38 +336,279 @@ func (validator *Validator) DBName() string {
 // iterator, depending on whether we're reading a tarred bag or
 // an untarred one.
 func (validator *Validator) getIterator() (fileutil.ReadIterator, error) {
-	if strings.HasSuffix(validator.PathToBag, ".tar") {
-		return fileutil.NewTarFileIterator(validator.PathToBag)
+	policy := validator.BagValidationConfig.SpecialFilePolicy
+	if validator.openStream != nil {
+		stream, err := validator.openStream()
+		if err != nil {
+			return nil, err
+		}
+		var iterator *fileutil.TarFileIterator
+		if fileutil.IsGzippedTar(validator.PathToBag) {
+			iterator, err = fileutil.NewGzipTarFileIteratorFromReader(stream)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			iterator = fileutil.NewTarFileIteratorFromReader(stream)
+		}
+		iterator.SpecialFilePolicy = policy
+		return iterator, nil
 	}
-	return fileutil.NewFileSystemIterator(validator.PathToBag)
+	if fileutil.IsZipFile(validator.PathToBag) {
+		iterator, err := fileutil.NewZipFileIterator(validator.PathToBag)
+		if iterator != nil {
+			iterator.SpecialFilePolicy = policy
+		}
+		return iterator, err
+	}
+	if util.HasTarSuffix(validator.PathToBag) {
+		iterator, err := fileutil.NewTarFileIterator(validator.PathToBag)
+		if iterator != nil {
+			iterator.SpecialFilePolicy = policy
+		}
+		return iterator, err
+	}
+	iterator, err := fileutil.NewFileSystemIterator(validator.PathToBag)
+	if iterator != nil {
+		iterator.SpecialFilePolicy = policy
+	}
+	return iterator, err
+}
+
+// Warnings returns warnings about the bag that don't make it
+// invalid, such as special files that the validator skipped. These
+// are the same as the warnings in the WorkSummary that Validate
+// returns.
+func (validator *Validator) Warnings() []string {
+	return validator.summary.WarningMessages()
 }
 
 // Validate reads and validates the bag, and returns a ValidationResult with
 // the IntellectualObject and any errors encountered during validation.
 func (validator *Validator) Validate() (*models.WorkSummary, error) {
-	db, err := storage.NewBoltDB(validator.DBName())
-	if err != nil {
-		return nil, err
+	if validator.usingMemoryDB() {
+		validator.db = storage.NewMemoryDB()
+	} else {
+		db, err := validator.openBoltDB()
+		if err != nil {
+			return nil, err
+		}
+		validator.db = db
 	}
-	defer db.Close()
-	validator.db = db
+	// Close whichever DB we wind up with, since moveToDisk may
+	// replace the memory DB with a BoltDB.
+	defer func() { validator.db.Close() }()
 	validator.summary.Start()
 	validator.summary.Attempted = true
 	validator.summary.AttemptNumber += 1
+	if !validator.verifySerialization() {
+		validator.summary.Finish()
+		return validator.summary, nil
+	}
+	if !validator.checkPayloadOxumBeforeReading() {
+		// A bag whose payload doesn't match its Payload-Oxum is
+		// incomplete, so don't spend time hashing it.
+		validator.summary.Finish()
+		return validator.summary, nil
+	}
 	validator.readBag()
-	validator.verifyManifestPresent()
-	validator.verifyTopLevelFolder()
-	validator.verifyFileSpecs()
-	validator.verifyTagSpecs()
+	if validator.unsafePathFound || validator.limitExceeded {
+		// We stopped reading at the unsafe file, or at the file
+		// that made the bag too big, so any further checks would
+		// be meaningless.
+		validator.summary.Finish()
+		return validator.summary, nil
+	}
+	validator.checkManifestAlgorithms()
+	if !validator.verifyPayloadOxum() {
+		// Don't bother checking digests if the payload is
+		// not the size the bagger said it should be.
+		validator.summary.Finish()
+		return validator.summary, nil
+	}
+	validator.runChecks()
 	validator.verifyGenericFiles()
 	validator.summary.Finish()
 	return validator.summary, nil
 }
 
+// ValidateStructureOnly is a quick pre-flight check for bags that
+// would take hours to validate. It does everything Validate does,
+// except that it does not read or hash payload files. It still parses
+// the manifests and tag files, checks the tag values and required
+// files, and checks the Payload-Oxum against the sizes of the payload
+// files. It does not check payload checksums, so a bag that passes
+// may still fail Validate.
+func (validator *Validator) ValidateStructureOnly() (*models.WorkSummary, error) {
+	validator.structureOnly = true
+	defer func() { validator.structureOnly = false }()
+	return validator.Validate()
+}
+
+// PreScreen is a quick check of very large bags before the full
+// validation. It does everything Validate does, except that it checks
+// payload files against only the crc32c and xxh64 manifests, which
+// are much faster to compute than md5 or sha256. It still checks tag
+// files against all of their manifests. A bag that fails PreScreen
+// will fail Validate, but a bag that passes may still fail Validate.
+// PreScreen checks the crc32c and xxh64 manifests only if they're in
+// FixityAlgorithms. If the bag has neither, PreScreen checks no
+// payload checksums, like ValidateStructureOnly, though it still
+// reads the payload files.
+func (validator *Validator) PreScreen() (*models.WorkSummary, error) {
+	validator.preScreen = true
+	defer func() { validator.preScreen = false }()
+	return validator.Validate()
+}
+
+// AddFileScanner adds scanner to the end of the validator's list
+// of Scanners.
+func (validator *Validator) AddFileScanner(scanner FileScanner) {
+	validator.Scanners = append(validator.Scanners, scanner)
+}
+
+// AddCheck adds check to the end of the validator's list of Checks.
+func (validator *Validator) AddCheck(check Check) {
+	validator.Checks = append(validator.Checks, check)
+}
+
+// AddInstitutionChecks adds the BagValidationConfig's InstitutionChecks
+// for the specified institution to the end of the validator's list of
+// Checks. Call this once, before Validate.
+func (validator *Validator) AddInstitutionChecks(institution string) error {
+	checks, err := validator.BagValidationConfig.ChecksFor(institution)
+	if err != nil {
+		return err
+	}
+	for _, check := range checks {
+		validator.AddCheck(check)
+	}
+	return nil
+}
+
+// runChecks runs each of the validator's Checks, in order.
+func (validator *Validator) runChecks() {
+	obj, err := validator.getIntellectualObject()
+	if err != nil {
+		validator.summary.AddError("Cannot get object metadata from db: %v", err)
+		return
+	}
+	for _, check := range validator.Checks {
+		validator.log(fmt.Sprintf("Running check '%s' for %s", check.Name(), validator.PathToBag))
+		check.Run(validator, obj, validator.summary)
+	}
+}
+
+// FileIdentifiers returns the identifiers of all of the GenericFiles
+// in the bag, in sorted order. This is for use by Checks, and works
+// only while Validate is running.
+func (validator *Validator) FileIdentifiers() []string {
+	return validator.db.FileIdentifiers()
+}
+
+// GetGenericFile returns the GenericFile with the specified identifier,
+// or nil if there's no such file in the bag. This is for use by Checks,
+// and works only while Validate is running.
+func (validator *Validator) GetGenericFile(identifier string) (*models.GenericFile, error) {
+	return validator.db.GetGenericFile(identifier)
+}
+
+// usingMemoryDB returns true if the validator should start out
+// keeping validation data in memory.
+func (validator *Validator) usingMemoryDB() bool {
+	return !validator.PreserveExtendedAttributes &&
+		(validator.UseMemoryDB || validator.MemoryDBMaxFiles > 0)
+}
+
+// moveToDiskIfTooBig moves validation data from memory into a BoltDB
+// file if the validator is keeping data in memory, and the bag has
+// more than MemoryDBMaxFiles files.
+func (validator *Validator) moveToDiskIfTooBig() error {
+	memoryDB, isMemoryDB := validator.db.(*storage.MemoryDB)
+	if !isMemoryDB || validator.UseMemoryDB || memoryDB.FileCount() <= validator.MemoryDBMaxFiles {
+		return nil
+	}
+	validator.log(fmt.Sprintf("Bag %s has more than %d files. Moving validation data to %s",
+		validator.PathToBag, validator.MemoryDBMaxFiles, validator.DBName()))
+	boltDB, err := validator.openBoltDB()
+	if err != nil {
+		return err
+	}
+	err = memoryDB.CopyTo(boltDB)
+	if err == nil {
+		err = boltDB.Flush()
+	}
+	if err != nil {
+		boltDB.Close()
+		return err
+	}
+	memoryDB.Close()
+	validator.db = boltDB
+	return nil
+}
+
+// openBoltDB opens the .valdb file, buffering writes according
+// to DBBatchSize.
+func (validator *Validator) openBoltDB() (*storage.BoltDB, error) {
+	boltDB, err := storage.NewBoltDB(validator.DBName())
+	if err == nil {
+		boltDB.BatchSize = validator.DBBatchSize
+	}
+	return boltDB, err
+}
+
+// flushDB writes any records the validation DB has buffered,
+// and records an error if it can't.
+func (validator *Validator) flushDB() {
+	if err := validator.db.Flush(); err != nil {
+		validator.summary.AddError("Could not write to validation db: %v", err)
+		validator.summary.ErrorIsFatal = true
+	}
+}
+
+// verifySerialization checks whether the bag is tarred or untarred,
+// according to the Serialization and AcceptSerialization settings
+// of the BagValidationConfig. It returns false and adds an error to
+// the WorkSummary if the bag's serialization is not acceptable.
+func (validator *Validator) verifySerialization() bool {
+	config := validator.BagValidationConfig
+	if util.IsSerializedBag(validator.PathToBag) {
+		if config.Serialization == FORBIDDEN {
+			validator.summary.AddError("Bag must not be serialized, but %s is a serialized file.",
+				validator.PathToBag)
+			return false
+		}
+		if fileutil.IsZipFile(validator.PathToBag) {
+			if !config.AcceptsZip() {
+				validator.summary.AddError("Zipped bags are not accepted. Accepted serializations: %s.",
+					strings.Join(config.AcceptSerialization, ", "))
+				return false
+			}
+		} else if fileutil.IsGzippedTar(validator.PathToBag) {
+			if !config.AcceptsGzippedTar() {
+				validator.summary.AddError("Gzipped tarred bags are not accepted. Accepted serializations: %s.",
+					strings.Join(config.AcceptSerialization, ", "))
+				return false
+			}
+		} else if len(config.AcceptSerialization) > 0 && !config.AcceptsTar() {
+			validator.summary.AddError("Tarred bags are not accepted. Accepted serializations: %s.",
+				strings.Join(config.AcceptSerialization, ", "))
+			return false
+		}
+	} else if config.Serialization == REQUIRED {
+		validator.summary.AddError("Bag must be serialized, but %s is a directory.",
+			validator.PathToBag)
+		return false
+	}
+	return true
+}
+
 // readBag reads through the contents of the bag and creates a list of
 // GenericFiles. This function creates a lightweight record of the
 // IntellectualObject in the db, and a for each file in the bag
-// (payload files, manifests, and everything else).
+// (payload files, manifests, and everything else). It reads the bag
+// only once, keeping copies of the manifests and tag files to parse
+// after it has a record of every file.
 func (validator *Validator) readBag() {
 	validator.log(fmt.Sprintf("Reading bag %s", validator.PathToBag))
 	// Call this for the side-effect of initializing the IntellectualObject
@@ -184,9 +623,14 @@ func (validator *Validator) readBag() {
 
 	// Add all files in the bag to the GenericFiles list
 	validator.addFiles()
+	if validator.unsafePathFound || validator.limitExceeded {
+		return
+	}
 
 	// Parse the files that can be parsed (manifests & plaintext tag files)
 	validator.parseFiles()
+	validator.filesToParse = nil
+	validator.normalizedIdentifiers = nil
 
 	// We can't set the storage type until after we've parsed the tag files.
 	validator.setStorageOption()
@@ -195,6 +639,7 @@ func (validator *Validator) readBag() {
 	if err != nil {
 		validator.summary.AddError("Could not save intelObj metadata: %v", err)
 	}
+	validator.flushDB()
 	validator.log(fmt.Sprintf("Finished reading %s", validator.PathToBag))
 }
 
@@ -220,7 +665,11 @@ func (validator *Validator) getIntellectualObject() (*models.IntellectualObject,
 func (validator *Validator) initIntellectualObject() (*models.IntellectualObject, error) {
 	obj := models.NewIntellectualObject()
 	obj.Identifier = validator.ObjIdentifier
-	if strings.HasSuffix(validator.PathToBag, ".tar") {
+	if validator.openStream != nil {
+		// The bag is not on the local disk.
+		obj.IngestS3Bucket = validator.s3Bucket
+		obj.IngestS3Key = validator.s3Key
+	} else if util.IsSerializedBag(validator.PathToBag) {
 		obj.IngestTarFilePath = validator.PathToBag
 	} else {
 		obj.IngestUntarredPath = validator.PathToBag
@@ -230,38 +679,280 @@ func (validator *Validator) initIntellectualObject() (*models.IntellectualObject
 }
 
 // addFiles adds a record for each file to our validation database.
+// If the bag is untarred and ChecksumWorkers is greater than one,
+// this calculates checksums on several files at once. Tarred bags
+// are always read sequentially, because the tar iterator can
+// read only one file at a time.
 func (validator *Validator) addFiles() {
 	validator.log(fmt.Sprintf("Creating file records for %s", validator.PathToBag))
+	validator.startPhase(PhaseAddingFiles)
+	validator.fileCount = 0
+	validator.byteCount = 0
 	iterator, err := validator.getIterator()
 	if err != nil {
 		validator.summary.AddError("Error getting file iterator: %v", err)
 		return
 	}
-	for {
-		err := validator.addFile(iterator)
-		if err != nil && (err == io.EOF || err.Error() == "EOF") {
-			break // readIterator hit the end of the list
-		} else if err != nil {
-			validator.summary.AddError("Error reading bag: %s", err.Error())
-			validator.summary.ErrorIsFatal = true
-			break // PT #146289839: Stop on error, or memory usage explodes.
+	if _, isFileSystemIterator := iterator.(*fileutil.FileSystemIterator); isFileSystemIterator && validator.ChecksumWorkers > 1 {
+		validator.addFilesInParallel(iterator)
+	} else {
+		for {
+			err := validator.addFile(iterator)
+			if err != nil && (err == io.EOF || err.Error() == "EOF") {
+				break // readIterator hit the end of the list
+			} else if _, isUnsafePath := err.(*UnsafePathError); isUnsafePath {
+				validator.rejectUnsafePath(err)
+				break
+			} else if _, isTooBig := err.(*BagLimitError); isTooBig {
+				validator.rejectOversizedBag(err)
+				break
+			} else if err != nil {
+				validator.summary.AddError("Error reading bag: %s", err.Error())
+				validator.summary.ErrorIsFatal = true
+				break // PT #146289839: Stop on error, or memory usage explodes.
+			}
 		}
 	}
+	for _, warning := range iterator.Warnings() {
+		validator.log(warning)
+		validator.summary.AddWarning("%s", warning)
+	}
 	validator.intelObj.IngestTopLevelDirNames = iterator.GetTopLevelDirNames()
 	validator.intelObj.IngestManifests = validator.manifests
 	validator.intelObj.IngestTagManifests = validator.tagManifests
 }
 
+// warnIfJunkFile adds a warning if the file looks like one the
+// operating system created, rather than part of the bag.
+func (validator *Validator) warnIfJunkFile(fileSummary *fileutil.FileSummary) {
+	if isJunkFile(fileSummary.RelPath) {
+		validator.summary.AddWarning("Bag contains '%s', which looks like a file "+
+			"created by the operating system. Consider removing it before bagging.",
+			fileSummary.RelPath)
+	}
+}
+
 // addFile adds a record for a single file to our validation database.
 func (validator *Validator) addFile(readIterator fileutil.ReadIterator) error {
 	reader, fileSummary, err := readIterator.Next()
 	if err != nil {
 		return err
 	}
+	if reader != nil {
+		defer reader.Close()
+	}
+	// Check every entry, including directories, before anything
+	// else. A file whose path escapes the bag directory must not
+	// make it into the db, because ingest would untar it there.
+	if err = checkFileSummaryPath(fileSummary); err != nil {
+		return err
+	}
 	if !fileSummary.IsRegularFile {
 		return nil
 	}
+	validator.warnIfJunkFile(fileSummary)
+	if err = validator.checkLimits(fileSummary); err != nil {
+		return err
+	}
+	gf := validator.newGenericFile(fileSummary)
+	var fileReader io.Reader = reader
+	content, err := validator.keepForParsing(reader, fileSummary)
+	if err != nil {
+		return err
+	}
+	if content != nil {
+		fileReader = bytes.NewReader(content)
+	}
+
+	// We calculate checksums in all contexts, because that's part of
+	// basic bag validation. Even if checksum calculation fails (which
+	// has not yet happened), we still want to keep a record of the
+	// GenericFile in the validation DB for later reporting purposes.
+	bytesRead, checksumError := validator.calculateChecksums(fileReader, gf, fileSummary)
+	validator.countPayload(gf, fileSummary, bytesRead)
+	validator.fileProcessed(bytesRead)
+	saveError := validator.db.Save(gf.Identifier, gf)
+	if checksumError != nil {
+		return checksumError
+	}
+	if saveError != nil {
+		return saveError
+	}
+	return validator.moveToDiskIfTooBig()
+}
+
+// rejectUnsafePath records an UnsafePathError as a fatal error.
+// Validate stops after reading the bag if it finds one.
+func (validator *Validator) rejectUnsafePath(err error) {
+	validator.summary.AddError(err.Error())
+	validator.summary.ErrorIsFatal = true
+	validator.unsafePathFound = true
+}
+
+// BagLimitError means a bag has more files or more bytes than
+// the BagValidationConfig's MaxFileCount or MaxBagSize.
+type BagLimitError struct {
+	message string
+}
 
+func (err *BagLimitError) Error() string {
+	return err.message
+}
+
+// checkLimits adds the file described by fileSummary to the bag's
+// file count and size, and returns a BagLimitError if either is over
+// its limit. We check as we read, so we can reject a bag with a
+// million files or terabytes of data before the ingest workers
+// spend hours on it.
+func (validator *Validator) checkLimits(fileSummary *fileutil.FileSummary) error {
+	config := validator.BagValidationConfig
+	validator.fileCount += 1
+	validator.byteCount += fileSummary.Size
+	if config.MaxFileCount > 0 && validator.fileCount > config.MaxFileCount {
+		return &BagLimitError{fmt.Sprintf(
+			"Bag contains more than %d files, which is the most a bag may contain.",
+			config.MaxFileCount)}
+	}
+	if config.MaxBagSize > 0 && validator.byteCount > config.MaxBagSize {
+		return &BagLimitError{fmt.Sprintf(
+			"Bag is larger than %d bytes, which is the largest a bag may be.",
+			config.MaxBagSize)}
+	}
+	return nil
+}
+
+// rejectOversizedBag records a BagLimitError as a fatal error.
+// Validate stops after reading the bag if it finds one.
+func (validator *Validator) rejectOversizedBag(err error) {
+	validator.summary.AddError(err.Error())
+	validator.summary.ErrorIsFatal = true
+	validator.limitExceeded = true
+}
+
+// checksumJob is a file waiting for a checksum worker.
+type checksumJob struct {
+	reader      io.ReadCloser
+	gf          *models.GenericFile
+	fileSummary *fileutil.FileSummary
+	bytesRead   int64
+	err         error
+}
+
+// addFilesInParallel adds a record for each file to our validation
+// database, using validator.ChecksumWorkers goroutines to calculate
+// checksums. This goroutine reads from the iterator, because setting
+// up GenericFile records touches the validator's lists of manifests
+// and required files. A single collector goroutine tallies the
+// payload and writes records to the DB in batches, since BoltDB
+// allows only one writer at a time.
+func (validator *Validator) addFilesInParallel(readIterator fileutil.ReadIterator) {
+	jobs := make(chan *checksumJob, validator.ChecksumWorkers*2)
+	results := make(chan *checksumJob, validator.ChecksumWorkers*2)
+	workers := sync.WaitGroup{}
+	for i := 0; i < validator.ChecksumWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				job.bytesRead, job.err = validator.calculateChecksums(job.reader, job.gf, job.fileSummary)
+				job.reader.Close()
+				results <- job
+			}
+		}()
+	}
+	done := make(chan []error)
+	go validator.collectChecksumResults(results, done)
+
+	for {
+		reader, fileSummary, err := readIterator.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			validator.summary.AddError("Error reading bag: %s", err.Error())
+			validator.summary.ErrorIsFatal = true
+			break
+		}
+		if err = checkFileSummaryPath(fileSummary); err != nil {
+			if reader != nil {
+				reader.Close()
+			}
+			validator.rejectUnsafePath(err)
+			break
+		}
+		if !fileSummary.IsRegularFile {
+			reader.Close()
+			continue
+		}
+		validator.warnIfJunkFile(fileSummary)
+		if err = validator.checkLimits(fileSummary); err != nil {
+			reader.Close()
+			validator.rejectOversizedBag(err)
+			break
+		}
+		gf := validator.newGenericFile(fileSummary)
+		content, err := validator.keepForParsing(reader, fileSummary)
+		if err != nil {
+			reader.Close()
+			validator.summary.AddError("Error reading bag: %s", err.Error())
+			validator.summary.ErrorIsFatal = true
+			break
+		}
+		if content != nil {
+			reader.Close()
+			reader = ioutil.NopCloser(bytes.NewReader(content))
+		}
+		jobs <- &checksumJob{
+			reader:      reader,
+			gf:          gf,
+			fileSummary: fileSummary,
+		}
+	}
+	close(jobs)
+	workers.Wait()
+	close(results)
+	for _, err := range <-done {
+		validator.summary.AddError("Error reading bag: %s", err.Error())
+		validator.summary.ErrorIsFatal = true
+	}
+}
+
+// collectChecksumResults tallies the payload size and saves GenericFile
+// records for completed checksum jobs, writing to the DB in batches of
+// up to 100 files. It sends a list of errors to done when the results
+// channel closes.
+func (validator *Validator) collectChecksumResults(results <-chan *checksumJob, done chan<- []error) {
+	errors := make([]error, 0)
+	batch := make(map[string]interface{})
+	saveBatch := func() {
+		if err := validator.db.SaveBatch(batch); err != nil {
+			errors = append(errors, err)
+		}
+		if err := validator.moveToDiskIfTooBig(); err != nil {
+			errors = append(errors, err)
+		}
+		batch = make(map[string]interface{})
+	}
+	for job := range results {
+		if job.err != nil {
+			errors = append(errors, job.err)
+		}
+		validator.countPayload(job.gf, job.fileSummary, job.bytesRead)
+		validator.fileProcessed(job.bytesRead)
+		batch[job.gf.Identifier] = job.gf
+		if len(batch) >= 100 {
+			saveBatch()
+		}
+	}
+	if len(batch) > 0 {
+		saveBatch()
+	}
+	done <- errors
+}
+
+// newGenericFile returns a new GenericFile record for the file
+// described in fileSummary, and notes whether it's a manifest or
+// a required or forbidden file.
+func (validator *Validator) newGenericFile(fileSummary *fileutil.FileSummary) *models.GenericFile {
 	gf := models.NewGenericFile()
 	gf.Identifier = fmt.Sprintf("%s/%s", validator.ObjIdentifier, fileSummary.RelPath)
 
@@ -297,37 +988,90 @@ func (validator *Validator) addFile(readIterator fileutil.ReadIterator) error {
 			validator.forbiddenFiles = append(validator.forbiddenFiles, gf.OriginalPath())
 		}
 	}
+	return gf
+}
 
-	// We calculate checksums in all contexts, because that's part of
-	// basic bag validation. Even if checksum calculation fails (which
-	// has not yet happened), we still want to keep a record of the
-	// GenericFile in the validation DB for later reporting purposes.
-	checksumError := validator.calculateChecksums(reader, gf)
-	saveError := validator.db.Save(gf.Identifier, gf)
-	if checksumError != nil {
-		return checksumError
+// countPayload adds a payload file to the payload byte and file counts.
+// It ignores files that are not payload files.
+func (validator *Validator) countPayload(gf *models.GenericFile, fileSummary *fileutil.FileSummary, bytesRead int64) {
+	if gf.IngestFileType == constants.PAYLOAD_FILE {
+		if !validator.calculatingChecksums() || validator.structureOnly {
+			bytesRead = fileSummary.Size
+		}
+		validator.payloadByteCount += bytesRead
+		validator.payloadFileCount += 1
 	}
-	return saveError
+}
+
+// calculatingChecksums returns true if the config tells us to calculate
+// at least one type of checksum.
+func (validator *Validator) calculatingChecksums() bool {
+	return (validator.calculateMd5 || validator.calculateSha256 ||
+		validator.calculateSha512 || validator.calculateBlake2b ||
+		validator.calculateSha1 ||
+		validator.calculateCrc32c || validator.calculateXxh64)
 }
 
 // calculateChecksums calculates the checksums on the given GenericFile.
 // Depending on the config options, we may calculate multiple checksums
 // in a single pass. (One of the perks of golang's MultiWriter.)
-func (validator *Validator) calculateChecksums(reader io.Reader, gf *models.GenericFile) error {
+// The validator's Scanners read the file in the same pass.
+// Returns the number of bytes read, which will be less than the file's
+// stated size if the bag is truncated.
+func (validator *Validator) calculateChecksums(reader io.Reader, gf *models.GenericFile, fileSummary *fileutil.FileSummary) (int64, error) {
+	var bytesRead int64
+	if validator.structureOnly && gf.IngestFileType == constants.PAYLOAD_FILE {
+		return bytesRead, nil
+	}
+	// When pre-screening, we compute only the fast digests of
+	// payload files.
+	fastOnly := validator.preScreen && gf.IngestFileType == constants.PAYLOAD_FILE
 	hashes := make([]io.Writer, 0)
 	var md5Hash hash.Hash
 	var sha256Hash hash.Hash
-	if validator.calculateMd5 {
+	var sha512Hash hash.Hash
+	var blake2bHash hash.Hash
+	var sha1Hash hash.Hash
+	var crc32cHash hash.Hash
+	var xxh64Hash hash.Hash
+	if validator.calculateMd5 && !fastOnly {
 		md5Hash = md5.New()
 		hashes = append(hashes, md5Hash)
 	}
-	if validator.calculateSha256 {
+	if validator.calculateSha256 && !fastOnly {
 		sha256Hash = sha256.New()
 		hashes = append(hashes, sha256Hash)
 	}
-	if len(hashes) > 0 {
-		multiWriter := io.MultiWriter(hashes...)
-		io.Copy(multiWriter, reader)
+	if validator.calculateSha512 && !fastOnly {
+		sha512Hash = sha512.New()
+		hashes = append(hashes, sha512Hash)
+	}
+	if validator.calculateBlake2b && !fastOnly {
+		// New512 returns an error only for keys over 64 bytes.
+		blake2bHash, _ = blake2b.New512(nil)
+		hashes = append(hashes, blake2bHash)
+	}
+	if validator.calculateSha1 && !fastOnly {
+		sha1Hash = sha1.New()
+		hashes = append(hashes, sha1Hash)
+	}
+	if validator.calculateCrc32c {
+		crc32cHash = fasthash.NewCRC32C()
+		hashes = append(hashes, crc32cHash)
+	}
+	if validator.calculateXxh64 {
+		xxh64Hash = fasthash.NewXXH64()
+		hashes = append(hashes, xxh64Hash)
+	}
+	scans := validator.startScans(fileSummary, gf)
+	writers := hashes
+	for _, scan := range scans {
+		writers = append(writers, scan.writer)
+	}
+	if len(writers) > 0 {
+		multiWriter := io.MultiWriter(writers...)
+		bytesRead, _ = io.Copy(multiWriter, reader)
+		validator.finishScans(scans, gf.OriginalPath())
 		utcNow := time.Now().UTC()
 		if md5Hash != nil {
 			gf.IngestMd5 = fmt.Sprintf("%x", md5Hash.Sum(nil))
@@ -341,8 +1085,32 @@ func (validator *Validator) calculateChecksums(reader io.Reader, gf *models.Gene
 				gf.IngestSha256GeneratedAt = utcNow
 			}
 		}
+		if sha512Hash != nil {
+			gf.IngestSha512 = fmt.Sprintf("%x", sha512Hash.Sum(nil))
+			if validator.PreserveExtendedAttributes {
+				gf.IngestSha512GeneratedAt = utcNow
+			}
+		}
+		if blake2bHash != nil {
+			gf.IngestBlake2b = fmt.Sprintf("%x", blake2bHash.Sum(nil))
+			if validator.PreserveExtendedAttributes {
+				gf.IngestBlake2bGeneratedAt = utcNow
+			}
+		}
+		if sha1Hash != nil {
+			gf.IngestSha1 = fmt.Sprintf("%x", sha1Hash.Sum(nil))
+			if validator.PreserveExtendedAttributes {
+				gf.IngestSha1GeneratedAt = utcNow
+			}
+		}
+		if crc32cHash != nil {
+			gf.IngestCrc32c = fmt.Sprintf("%x", crc32cHash.Sum(nil))
+		}
+		if xxh64Hash != nil {
+			gf.IngestXxh64 = fmt.Sprintf("%x", xxh64Hash.Sum(nil))
+		}
 	}
-	return nil
+	return bytesRead, nil
 }
 
 // setFileType figures whether a file is a manifest, tag manifest,
@@ -365,86 +1133,69 @@ func (validator *Validator) setFileType(gf *models.GenericFile, fileSummary *fil
 	}
 }
 
-// parseFiles parses files that the bagging config says to parse,
-// like manifests and certain tag files.
-func (validator *Validator) parseFiles() {
-	validator.log(fmt.Sprintf("Parsing tag files and manifests in %s", validator.PathToBag))
-	// We have to get a new iterator here, because if we're
-	// dealing with a TarFileIterator (which is likely), it's
-	// forward-only. We can't rewind it.
-	readIterator, err := validator.getIterator()
+// keepForParsing reads the file described by fileSummary into memory
+// and returns its contents, if it's a manifest, tag manifest or tag
+// file that parseFiles will parse. Otherwise, it returns nil and
+// leaves reader alone. Call this after newGenericFile, which notes
+// which files are manifests. These files are small, and keeping them
+// saves us a second pass through the bag.
+func (validator *Validator) keepForParsing(reader io.Reader, fileSummary *fileutil.FileSummary) ([]byte, error) {
+	if !validator.shouldParse(fileSummary) {
+		return nil, nil
+	}
+	content, err := ioutil.ReadAll(reader)
 	if err != nil {
-		validator.summary.AddError("Error getting file iterator: %v", err)
-		return
+		return nil, err
 	}
-	for {
-		// Don't use "defer reader.Close()" because the readers
-		// won't be closed until we exit the enclosing funcion,
-		// and the for loop may have opened 100k+ files by then.
-		reader, fileSummary, err := readIterator.Next()
+	validator.filesToParse = append(validator.filesToParse, &fileToParse{
+		fileSummary: fileSummary,
+		content:     content,
+	})
+	return content, nil
+}
 
-		// EOF means there are no more files to iterate through.
-		if err == io.EOF {
-			if reader != nil {
-				reader.Close()
-			}
-			return
-		}
+// shouldParse returns true if the file described by fileSummary is
+// a manifest, tag manifest, or tag file that the bagging config says
+// to parse.
+func (validator *Validator) shouldParse(fileSummary *fileutil.FileSummary) bool {
+	return util.StringListContains(validator.tagFilesToParse, fileSummary.RelPath) ||
+		util.StringListContains(validator.manifests, fileSummary.RelPath) ||
+		util.StringListContains(validator.tagManifests, fileSummary.RelPath)
+}
 
-		// Non EOF error means something went wrong.
-		// Try to avoid out of memory exception, which
-		// is either coming from too many errors or
-		// one gigantic error.
-		if err != nil {
-			if reader != nil {
-				reader.Close()
-			}
-			msg := ""
-			if len(err.Error()) > 2000 {
-				msg = err.Error()[0:2000]
-			} else {
-				msg = err.Error()
-			}
-			validator.summary.AddError(msg)
-			if len(validator.summary.Errors) > 100 {
-				if reader != nil {
-					reader.Close()
-				}
-				return
-			}
-			continue
-		}
+// parseFiles parses the manifests and tag files that addFiles kept
+// in memory. We can't parse them while adding files, because the
+// manifests may list files that come after them in the bag.
+func (validator *Validator) parseFiles() {
+	validator.log(fmt.Sprintf("Parsing tag files and manifests in %s", validator.PathToBag))
+	validator.startPhase(PhaseParsingFiles)
+	for _, file := range validator.filesToParse {
+		validator.parseFile(bytes.NewReader(file.content), file.fileSummary)
+		validator.fileProcessed(0)
+	}
+}
 
-		// If the iterator returns a directory, skip it.
-		if fileSummary.IsDir {
-			if reader != nil {
-				reader.Close()
-			}
-			continue
-		}
+// startPhase resets the count of files processed at the start
+// of a new validation phase, and reports progress.
+func (validator *Validator) startPhase(phase string) {
+	validator.progress.Phase = phase
+	validator.progress.FilesProcessed = 0
+	validator.reportProgress()
+}
 
-		// At this point, we should have an open reader
-		// pointing to a legitimate file.
-		gfIdentifier := fmt.Sprintf("%s/%s", validator.ObjIdentifier, fileSummary.RelPath)
-		gf, err := validator.db.GetGenericFile(gfIdentifier)
-		if err != nil {
-			validator.summary.AddError("Error finding '%s' in validation db: %v", gfIdentifier, err)
-			if reader != nil {
-				reader.Close()
-			}
-			continue
-		}
-		if gf == nil {
-			validator.summary.AddError("Cannot find '%s' in validation db", gfIdentifier)
-			if reader != nil {
-				reader.Close()
-			}
-			continue
-		}
-		validator.parseFile(reader, gf, fileSummary)
-		if reader != nil {
-			reader.Close()
-		}
+// fileProcessed counts a file processed in the current phase,
+// and reports progress.
+func (validator *Validator) fileProcessed(bytesHashed int64) {
+	validator.progress.FilesProcessed++
+	validator.progress.BytesHashed += bytesHashed
+	validator.reportProgress()
+}
+
+// reportProgress passes the current progress to the Progress
+// callback, if there is one.
+func (validator *Validator) reportProgress() {
+	if validator.Progress != nil {
+		validator.Progress(validator.progress)
 	}
 }
 
@@ -486,7 +1237,7 @@ func (validator *Validator) setStorageOption() {
 // tag manifest, or parsable plain-text tag file. If the file
 // doesn't match either of these cases, we skip it, and this is
 // a no-op.
-func (validator *Validator) parseFile(reader io.ReadCloser, gf *models.GenericFile, fileSummary *fileutil.FileSummary) {
+func (validator *Validator) parseFile(reader io.Reader, fileSummary *fileutil.FileSummary) {
 	parseAsTagFile := util.StringListContains(validator.tagFilesToParse, fileSummary.RelPath)
 	parseAsManifest := util.StringListContains(validator.manifests, fileSummary.RelPath) ||
 		util.StringListContains(validator.tagManifests, fileSummary.RelPath)
@@ -500,9 +1251,9 @@ func (validator *Validator) parseFile(reader io.ReadCloser, gf *models.GenericFi
 }
 
 // parseTags parses the tags in a bagit-format tag file. That's a plain-text
-// file with names and values separated by a colon.
-//
-// TODO: Move this into a separate file and make it more generic.
+// file with names and values separated by a colon. Tags are added to
+// obj.IngestTags in the order they appear in the file, with their raw
+// text, so the restorer can rebuild the file. See models.TagList.
 func (validator *Validator) parseTags(reader io.Reader, relFilePath string) {
 	obj, err := validator.getIntellectualObject()
 	if err != nil {
@@ -513,41 +1264,15 @@ func (validator *Validator) parseTags(reader io.Reader, relFilePath string) {
 		validator.summary.AddError("IntelObj '%s' is missing from validation db", validator.ObjIdentifier)
 		return
 	}
-	re := regexp.MustCompile(`^(\S*\:)?(\s*.*)?$`)
-	scanner := bufio.NewScanner(reader)
-	var tag *models.Tag
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-		if re.MatchString(line) {
-			data := re.FindStringSubmatch(line)
-			data[1] = strings.TrimSpace(data[1])
-			data[1] = strings.Replace(data[1], ":", "", 1)
-			if data[1] != "" {
-				if tag != nil && tag.Label != "" {
-					obj.IngestTags = append(obj.IngestTags, tag)
-				}
-				tag = models.NewTag(relFilePath, data[1], strings.TrimSpace(data[2]))
-				validator.SetIntelObjTagValue(obj, tag)
-				continue
-			}
-			value := strings.TrimSpace(data[2])
-			if tag != nil {
-				tag.Value = strings.Join([]string{tag.Value, value}, " ")
-				validator.SetIntelObjTagValue(obj, tag)
-			}
-		} else {
-			validator.summary.AddError("Unable to parse tag data from line: '%s'", line)
-		}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		validator.summary.AddError("Error reading tag file '%s': %v",
+			relFilePath, err.Error())
 	}
-	if tag != nil && tag.Label != "" {
+	for _, tag := range models.ParseTagList(relFilePath, data).Tags {
 		obj.IngestTags = append(obj.IngestTags, tag)
-	}
-	if scanner.Err() != nil {
-		validator.summary.AddError("Error reading tag file '%s': %v",
-			relFilePath, scanner.Err().Error())
+		validator.SetIntelObjTagValue(obj, tag)
+		validator.checkTagValue(tag)
 	}
 	err = validator.db.Save(validator.ObjIdentifier, obj)
 	if err != nil {
@@ -555,6 +1280,33 @@ func (validator *Validator) parseTags(reader io.Reader, relFilePath string) {
 	}
 }
 
+// checkTagValue adds an error to the summary if the value of tag
+// breaks the rules of the TagSpec for tags with its label in its file.
+// We check each tag once we've read all of its value, which may span
+// several lines.
+func (validator *Validator) checkTagValue(tag *models.Tag) {
+	if tag.SourceFile == "aptrust-info.txt" {
+		if err := CheckRightsTag(tag.Label, tag.Value); err != nil {
+			validator.summary.AddError(err.Error())
+		}
+	}
+	tagSpec, ok := validator.BagValidationConfig.TagSpecs[tag.Label]
+	if !ok || tagSpec.FilePath != tag.SourceFile || tagSpec.Presence == FORBIDDEN {
+		return
+	}
+	if tagSpec.Deprecated {
+		validator.summary.AddWarning("Tag '%s' in %s is deprecated, and may not "+
+			"be accepted in the future.", tag.Label, tag.SourceFile)
+	}
+	if tag.Value == "" && (tagSpec.EmptyOK || tagSpec.Presence == REQUIRED) {
+		// checkRequiredTag reports missing required values.
+		return
+	}
+	if message := tagSpec.ValueError(tag.Label, tag.Value); message != "" {
+		validator.summary.AddError(message)
+	}
+}
+
 // Copy certain values from the aptrust-info.txt file into
 // properties of the IntellectualObject. Although the
 // institution name generally appears in the tag "Source-Organization",
@@ -575,6 +1327,11 @@ func (validator *Validator) SetIntelObjTagValue(obj *models.IntellectualObject,
 			obj.Access = tag.Value
 		case "description":
 			obj.Description = tag.Value
+		case "embargo-until":
+			// CheckRightsTag reports invalid dates.
+			obj.EmbargoUntil, _ = time.Parse(constants.EmbargoDateFormat, tag.Value)
+		case "rights-statement":
+			obj.RightsStatement = tag.Value
 		}
 	} else if tag.SourceFile == "bag-info.txt" {
 		label := strings.ToLower(tag.Label)
@@ -602,19 +1359,33 @@ func (validator *Validator) parseManifest(reader io.Reader, fileSummary *fileuti
 	alg := ""
 	if strings.Contains(fileSummary.RelPath, constants.AlgSha256) {
 		alg = constants.AlgSha256
+	} else if strings.Contains(fileSummary.RelPath, constants.AlgSha512) && validator.calculateSha512 {
+		alg = constants.AlgSha512
+	} else if strings.Contains(fileSummary.RelPath, constants.AlgBlake2b) && validator.calculateBlake2b {
+		alg = constants.AlgBlake2b
 	} else if strings.Contains(fileSummary.RelPath, constants.AlgMd5) {
 		alg = constants.AlgMd5
+	} else if strings.Contains(fileSummary.RelPath, constants.AlgSha1) && validator.calculateSha1 {
+		alg = constants.AlgSha1
+	} else if strings.Contains(fileSummary.RelPath, constants.AlgCrc32c) && validator.calculateCrc32c {
+		alg = constants.AlgCrc32c
+	} else if strings.Contains(fileSummary.RelPath, constants.AlgXxh64) && validator.calculateXxh64 {
+		alg = constants.AlgXxh64
 	} else {
 		fmt.Fprintln(os.Stderr, "Not verifying checksums in", fileSummary.RelPath,
 			"- unsupported algorithm. Will still verify any md5 or sha256 checksums. "+
+				"To verify sha512, blake2b, sha1, crc32c or xxh64 manifests, add the algorithm to FixityAlgorithms. "+
 				"Bag ", validator.PathToBag)
 		return
 	}
 	re := regexp.MustCompile(`^(\S*)\s*(.*)`)
 	scanner := bufio.NewScanner(reader)
-	lineNum := 1
+	// seen maps the identifier of each file in the manifest
+	// to its first entry, so we can catch duplicates.
+	seen := make(map[string]manifestEntry)
+	lineNum := 0
 	for scanner.Scan() {
-		updateGenericFile := false
+		lineNum += 1
 		line := scanner.Text()
 		if strings.TrimSpace(line) == "" {
 			continue
@@ -622,10 +1393,17 @@ func (validator *Validator) parseManifest(reader io.Reader, fileSummary *fileuti
 		if re.MatchString(line) {
 			data := re.FindStringSubmatch(line)
 			digest := data[1]
-			filePath := data[2]
+			filePath := util.DecodeManifestPath(data[2])
+			if len(digest) != digestLengths[alg] {
+				validator.summary.AddError(
+					"Line %d of manifest %s has a %d-character digest for '%s', "+
+						"but %s digests have %d characters",
+					lineNum, fileSummary.RelPath, len(digest), filePath, alg, digestLengths[alg])
+				continue
+			}
 
 			gfIdentifier := fmt.Sprintf("%s/%s", validator.ObjIdentifier, filePath)
-			genericFile, err := validator.db.GetGenericFile(gfIdentifier)
+			genericFile, err := validator.findGenericFile(gfIdentifier)
 			if err != nil {
 				validator.summary.AddError("Error finding generic file '%s' in db: %v", gfIdentifier, err)
 			}
@@ -635,154 +1413,236 @@ func (validator *Validator) parseManifest(reader io.Reader, fileSummary *fileuti
 					filePath, fileSummary.RelPath)
 				continue
 			}
+			if !validator.checkDuplicateEntry(seen, fileSummary.RelPath,
+				manifestEntry{lineNum, filePath, digest}, genericFile.Identifier) {
+				continue
+			}
 
-			// If we got a digest from this line of the manifest,
-			// set it on the GenericFile and save the record back
-			// to the database.
-			if alg == constants.AlgMd5 {
-				genericFile.IngestManifestMd5 = digest
-				updateGenericFile = true
-			} else if alg == constants.AlgSha256 {
-				genericFile.IngestManifestSha256 = digest
-				updateGenericFile = true
+			// A payload manifest and a tag manifest of the same
+			// algorithm can both list a file. They have to agree.
+			manifestDigest := manifestDigestFor(genericFile, alg)
+			if *manifestDigest != "" && !strings.EqualFold(*manifestDigest, digest) {
+				validator.summary.AddError(
+					"Line %d of manifest %s lists '%s' with %s digest '%s', "+
+						"but another manifest lists it with digest '%s'",
+					lineNum, fileSummary.RelPath, filePath, alg, digest, *manifestDigest)
+				continue
 			}
-			if updateGenericFile {
-				err = validator.db.Save(gfIdentifier, genericFile)
-				if err != nil {
-					validator.summary.AddError("Error saving generic file '%s' to db: %v", gfIdentifier, err)
-				}
+
+			// Set the digest from this line of the manifest on
+			// the GenericFile and save the record back to the
+			// database.
+			*manifestDigest = digest
+			err = validator.db.Save(genericFile.Identifier, genericFile)
+			if err != nil {
+				validator.summary.AddError("Error saving generic file '%s' to db: %v", gfIdentifier, err)
 			}
 		} else {
 			validator.summary.AddError(fmt.Sprintf(
 				"Unable to parse data from line %d of manifest %s: %s",
 				lineNum, fileSummary.RelPath, line))
 		}
-		lineNum += 1
 	}
 }
 
-// verifyManifestPresent checks to see if at least one payload manifest
-// is present in the bag. If not, it adds an error message to the
-// WorkSummary.
-func (validator *Validator) verifyManifestPresent() {
-	validator.log(fmt.Sprintf("Verifying manifests present for %s", validator.PathToBag))
-	if len(validator.manifests) == 0 {
-		validator.summary.AddError("Bag contains no payload manifest.")
+// manifestDigestFor returns a pointer to the field of gf that holds
+// the manifest digest for the specified algorithm.
+func manifestDigestFor(gf *models.GenericFile, alg string) *string {
+	switch alg {
+	case constants.AlgMd5:
+		return &gf.IngestManifestMd5
+	case constants.AlgSha256:
+		return &gf.IngestManifestSha256
+	case constants.AlgSha512:
+		return &gf.IngestManifestSha512
+	case constants.AlgBlake2b:
+		return &gf.IngestManifestBlake2b
+	case constants.AlgSha1:
+		return &gf.IngestManifestSha1
+	case constants.AlgCrc32c:
+		return &gf.IngestManifestCrc32c
+	case constants.AlgXxh64:
+		return &gf.IngestManifestXxh64
 	}
+	return nil
 }
 
-// verifyTopLevelFolder ensures the top-level folder inside a tar file
-// has the same name as the bag. There should be exactly one top-level
-// folder whose name is the same as the bag. Anything else is an error.
-func (validator *Validator) verifyTopLevelFolder() {
-	validator.log(fmt.Sprintf("Verifying top-level folder for %s", validator.PathToBag))
-	obj, err := validator.getIntellectualObject()
-	if err != nil {
-		validator.summary.AddError("Can't get object: %v", err)
-		return
-	}
-	if obj.IngestTarFilePath == "" {
+// manifestEntry is one line of a manifest.
+type manifestEntry struct {
+	lineNum  int
+	filePath string
+	digest   string
+}
+
+// checkManifestAlgorithms adds a warning if all of the bag's payload
+// manifests are md5 manifests. That's valid, but md5 alone is too
+// weak to rely on for fixity.
+func (validator *Validator) checkManifestAlgorithms() {
+	if len(validator.manifests) == 0 {
 		return
 	}
-	baseName := path.Base(obj.IngestTarFilePath)
-	// Not sure why, but the Go path library uses hard-coded forward slashes.
-	// https://golang.org/src/path/path.go?s=4737:4766#L171
-	// That causes a problem on Windows.
-	if runtime.GOOS == "windows" {
-		parts := strings.Split(obj.IngestTarFilePath, "\\")
-		baseName = parts[len(parts)-1]
-	}
-	expectedDirName := TAR_SUFFIX.ReplaceAllString(baseName, "")
-	dirNames := obj.IngestTopLevelDirNames
-	if dirNames != nil {
-		for _, dirName := range dirNames {
-			if dirName != expectedDirName {
-				validator.summary.AddError(
-					"Tarred bag should untar to directory '%s', not '%s'",
-					expectedDirName, dirName)
-			}
+	for _, manifest := range validator.manifests {
+		if manifest != "manifest-md5.txt" {
+			return
 		}
 	}
+	validator.summary.AddWarning("Bag has only an md5 payload manifest. " +
+		"Please include a sha256 manifest as well.")
 }
 
-// verifyFileSpecs ensures required files are present and forbidden files
-// are not. This adds an error to the WorkSummary for any violations.
-func (validator *Validator) verifyFileSpecs() {
-	validator.log(fmt.Sprintf("Checking required/forbidden files for %s", validator.PathToBag))
-	for gfPath, fileSpec := range validator.BagValidationConfig.FileSpecs {
-		if fileSpec.Presence == REQUIRED && !util.StringListContains(validator.requiredFiles, gfPath) {
-			validator.summary.AddError("Required file '%s' is missing.", gfPath)
-		} else if fileSpec.Presence == FORBIDDEN && util.StringListContains(validator.forbiddenFiles, gfPath) {
-			validator.summary.AddError("Bag contains forbidden file '%s'.", gfPath)
-		}
+// checkDuplicateEntry records entry as the manifest's entry for the
+// file with the specified identifier, unless the manifest already
+// listed that file. If it did, and the digests differ, this adds an
+// error naming both lines. If the digests are the same, it adds a
+// warning. Two entries can refer to the same file even if their paths
+// differ, when the paths match after Unicode normalization. It returns
+// false if the validator should ignore this entry.
+func (validator *Validator) checkDuplicateEntry(seen map[string]manifestEntry, manifest string, entry manifestEntry, gfIdentifier string) bool {
+	first, isDuplicate := seen[gfIdentifier]
+	if !isDuplicate {
+		seen[gfIdentifier] = entry
+		return true
+	}
+	if !strings.EqualFold(first.digest, entry.digest) {
+		validator.summary.AddError(
+			"Manifest %s lists '%s' with digest '%s' on line %d and '%s' with digest '%s' on line %d",
+			manifest, first.filePath, first.digest, first.lineNum,
+			entry.filePath, entry.digest, entry.lineNum)
+	} else {
+		validator.summary.AddWarning(
+			"Manifest %s lists '%s' on line %d and '%s' on line %d, with the same digest",
+			manifest, first.filePath, first.lineNum, entry.filePath, entry.lineNum)
+	}
+	return false
+}
 
+// findGenericFile returns the GenericFile with the specified
+// identifier. If there's no exact match, it returns the file whose
+// identifier matches after Unicode normalization, unless the config's
+// UnicodeNormalization is "none". It returns nil if there's no match.
+func (validator *Validator) findGenericFile(gfIdentifier string) (*models.GenericFile, error) {
+	genericFile, err := validator.db.GetGenericFile(gfIdentifier)
+	if genericFile != nil || err != nil ||
+		validator.BagValidationConfig.UnicodeNormalization == NormalizeNone {
+		return genericFile, err
 	}
+	if validator.normalizedIdentifiers == nil {
+		validator.normalizedIdentifiers = make(map[string]string)
+		for _, identifier := range validator.db.FileIdentifiers() {
+			normalized := validator.BagValidationConfig.NormalizeFileName(identifier)
+			validator.normalizedIdentifiers[normalized] = identifier
+		}
+	}
+	normalized := validator.BagValidationConfig.NormalizeFileName(gfIdentifier)
+	identifier, found := validator.normalizedIdentifiers[normalized]
+	if !found {
+		return nil, nil
+	}
+	return validator.db.GetGenericFile(identifier)
 }
 
-// verifyTagSpecs ensures required tags are present and values are allowed.
-func (validator *Validator) verifyTagSpecs() {
-	validator.log(fmt.Sprintf("Verifying tags for %s", validator.PathToBag))
-	obj, err := validator.getIntellectualObject()
+// checkPayloadOxumBeforeReading compares the Payload-Oxum in
+// bag-info.txt with the number and sizes of the payload files listed
+// in the tar headers, zip directory or file system, before readBag
+// reads and hashes the payload. That lets us reject a truncated or
+// incomplete bag without the expensive read. It returns false and
+// adds an error to the WorkSummary if the Payload-Oxum is malformed
+// or doesn't match. It returns true if the bag has no Payload-Oxum,
+// or if we can't list the bag's files cheaply, leaving the check to
+// verifyPayloadOxum. That includes bags streamed from S3, which we
+// would have to download twice, and gzipped tar files, which we would
+// have to decompress twice.
+func (validator *Validator) checkPayloadOxumBeforeReading() bool {
+	if validator.openStream != nil || fileutil.IsGzippedTar(validator.PathToBag) {
+		return true
+	}
+	iterator, err := validator.getIterator()
 	if err != nil {
-		validator.summary.AddError("Cannot get object metadata from db: %v", err)
-		return
+		return true
 	}
-	for tagName, tagSpec := range validator.BagValidationConfig.TagSpecs {
-		tags := obj.FindTag(tagName)
-		if tagSpec.Presence == FORBIDDEN {
-			validator.summary.AddError("Forbidden tag '%s' found in file '%s'.",
-				tagName, tags[0].SourceFile)
-			continue
+	if archiveIterator, ok := iterator.(fileutil.ArchiveIterator); ok {
+		defer archiveIterator.Close()
+	}
+	oxum := ""
+	var byteCount, fileCount int64
+	for {
+		reader, fileSummary, err := iterator.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			// readBag will report this.
+			if reader != nil {
+				reader.Close()
+			}
+			return true
 		}
-		if tagSpec.Presence == REQUIRED {
-			validator.checkRequiredTag(tagName, tags, tagSpec)
+		if fileSummary.IsRegularFile {
+			if strings.HasPrefix(fileSummary.RelPath, "data/") {
+				byteCount += fileSummary.Size
+				fileCount += 1
+			} else if fileSummary.RelPath == "bag-info.txt" {
+				data, err := ioutil.ReadAll(reader)
+				if err != nil {
+					reader.Close()
+					return true
+				}
+				for _, tag := range models.ParseTagList(fileSummary.RelPath, data).Find("Payload-Oxum") {
+					oxum = tag.Value
+				}
+			}
 		}
-		if tags != nil && tagSpec.AllowedValues != nil && len(tagSpec.AllowedValues) > 0 {
-			validator.checkAllowedTagValue(tagName, tags, tagSpec)
+		if reader != nil {
+			reader.Close()
 		}
 	}
+	if oxum == "" {
+		return true
+	}
+	return validator.comparePayloadOxum(oxum, byteCount, fileCount)
 }
 
-// checkRequiredTag ensures that a required tag is present.
-// It adds and error to the WorkSummary if not.
-func (validator *Validator) checkRequiredTag(tagName string, tags []*models.Tag, tagSpec TagSpec) {
-	if tags == nil {
-		validator.summary.AddError("Required tag '%s' is missing.", tagName)
-		return
+// verifyPayloadOxum compares the Payload-Oxum in bag-info.txt, if there
+// is one, with the number of bytes and files we actually read from the
+// payload directory. The Payload-Oxum is optional, so this returns true
+// if it's missing. It returns false and adds an error to the WorkSummary
+// if the Payload-Oxum is malformed or doesn't match.
+func (validator *Validator) verifyPayloadOxum() bool {
+	validator.log(fmt.Sprintf("Verifying Payload-Oxum for %s", validator.PathToBag))
+	obj, err := validator.getIntellectualObject()
+	if err != nil {
+		validator.summary.AddError("Cannot get object metadata from db: %v", err)
+		return false
 	}
-	if !tagSpec.EmptyOK {
-		tagHasValue := false
-		for _, tag := range tags {
-			if tag.Value != "" {
-				tagHasValue = true
-				break
-			}
-		}
-		if !tagHasValue {
-			validator.summary.AddError("Value for tag '%s' is missing.", tagName)
+	var oxum *models.Tag
+	for _, tag := range obj.FindTag("Payload-Oxum") {
+		if tag.SourceFile == "bag-info.txt" {
+			oxum = tag
 		}
 	}
+	if oxum == nil {
+		return true
+	}
+	return validator.comparePayloadOxum(oxum.Value, validator.payloadByteCount,
+		validator.payloadFileCount)
 }
 
-// checkAllowedTagValue ensures that the value of a tag is one of a
-// set of values enumerated in the BagValidationConfig. It adds an
-// error to the WorkSummary if not.
-func (validator *Validator) checkAllowedTagValue(tagName string, tags []*models.Tag, tagSpec TagSpec) {
-	valueOk := false
-	lastValue := ""
-	for _, value := range tagSpec.AllowedValues {
-		for _, tag := range tags {
-			lcValue := strings.TrimSpace(strings.ToLower(value))
-			tagValue := strings.TrimSpace(strings.ToLower(tag.Value))
-			lastValue = tagValue
-			if lcValue == tagValue {
-				valueOk = true
-			}
-		}
+// comparePayloadOxum returns true if oxum describes a payload of
+// byteCount bytes in fileCount files. Otherwise, it adds an error to
+// the WorkSummary and returns false.
+func (validator *Validator) comparePayloadOxum(oxum string, byteCount, fileCount int64) bool {
+	oxumBytes, oxumFiles, err := util.ParsePayloadOxum(oxum)
+	if err != nil {
+		validator.summary.AddError("Payload-Oxum in bag-info.txt is invalid: %v", err)
+		return false
 	}
-	if !valueOk {
-		validator.summary.AddError("Tag '%s' has illegal value '%s'.", tagName, lastValue)
+	if oxumBytes != byteCount || oxumFiles != fileCount {
+		validator.summary.AddError(
+			"Payload-Oxum mismatch: bag-info.txt says payload has %d bytes in %d files, "+
+				"but bag has %d bytes in %d files. The bag may be incomplete or truncated.",
+			oxumBytes, oxumFiles, byteCount, fileCount)
+		return false
 	}
+	return true
 }
 
 // verifyGenericFiles verifies a number of attributes related to generic files,
@@ -806,43 +1666,49 @@ func (validator *Validator) verifyGenericFiles() {
 			validator.summary.AddError("Bag contains a fetch.txt file, but the profile does not allow it.")
 		}
 
-		// Md5 digests
-		if gf.IngestManifestMd5 != "" && gf.IngestManifestMd5 != gf.IngestMd5 {
-			validator.summary.AddError(
-				"Bad md5 digest for '%s': manifest says '%s', file digest is '%s'",
-				gf.OriginalPath(), gf.IngestManifestMd5, gf.IngestMd5)
-		} else {
-			gf.IngestMd5VerifiedAt = time.Now().UTC()
-		}
-		// Sha256 digests
-		if gf.IngestManifestSha256 != "" && gf.IngestManifestSha256 != gf.IngestSha256 {
-			validator.summary.AddError(
-				"Bad sha256 digest for '%s': manifest says '%s', file digest is '%s'",
-				gf.OriginalPath(), gf.IngestManifestSha256, gf.IngestSha256)
-		} else {
-			gf.IngestSha256VerifiedAt = time.Now().UTC()
+		// problems describes what's wrong with this file,
+		// for its validation event.
+		problems := make([]string, 0)
+
+		// We don't hash payload files when checking structure only.
+		if !validator.structureOnly || gf.IngestFileType != constants.PAYLOAD_FILE {
+			for _, alg := range validator.verifyDigests(gf) {
+				problems = append(problems, fmt.Sprintf("bad %s digest", alg))
+			}
 		}
-		// No manifest entry?
+		// No manifest entry? The crc32c and xxh64 manifests don't
+		// count, because they're not cryptographic.
 		if gf.IngestFileType == constants.PAYLOAD_FILE &&
-			gf.IngestManifestMd5 == "" && gf.IngestManifestSha256 == "" {
+			gf.IngestManifestMd5 == "" && gf.IngestManifestSha256 == "" &&
+			gf.IngestManifestSha512 == "" && gf.IngestManifestBlake2b == "" &&
+			gf.IngestManifestSha1 == "" {
 			validator.summary.AddError(
-				"File '%s' does not appear in any payload manifest (md5 or sha256)",
+				"File '%s' does not appear in any payload manifest (md5, sha256, sha512 or blake2b)",
 				gf.OriginalPath())
+			problems = append(problems, "not in any payload manifest")
 		}
 		// Make sure name is valid
-		if util.ContainsControlCharacter(gf.OriginalPath()) ||
-			util.LooksLikeEscapedControl(gf.OriginalPath()) {
+		if hasIllegalControlCharacter(gf.OriginalPath()) {
 			validator.summary.AddError(
-				"File name '%s' contains an illegal unicode control character",
+				"File name %q contains an illegal unicode control character",
 				gf.OriginalPath())
+			problems = append(problems, "invalid file name")
 		} else if validator.BagValidationConfig.FileNameRegex != nil {
+			nameIsValid := true
 			for _, pathComponent := range strings.Split(gf.OriginalPath(), "/") {
 				if !validator.BagValidationConfig.FileNameRegex.MatchString(pathComponent) {
 					validator.summary.AddError(
 						"Filename '%s' is not valid according to %s",
 						gf.OriginalPath(), detail)
+					nameIsValid = false
 				}
 			}
+			if !nameIsValid {
+				problems = append(problems, "invalid file name")
+			}
+		}
+		if validator.recordingPremisEvents() {
+			validator.buildPremisEvents(gf, problems)
 		}
 		err = validator.db.Save(gf.Identifier, gf)
 		if err != nil {
@@ -854,6 +1720,57 @@ func (validator *Validator) verifyGenericFiles() {
 			validator.log(fmt.Sprintf("Checked %d generic files so far for %s", count, validator.PathToBag))
 		}
 	}
+	validator.flushDB()
+}
+
+// verifyDigests adds an error for each digest of gf that does not
+// match the digest in the manifest, and marks the others verified.
+// Digests of payload files come from the payload manifests. Digests
+// of everything else come from the tag manifests, and the errors say
+// which tag manifest, because the BagIt spec requires tag files to
+// match their tag manifest entries as well. When pre-screening, this
+// checks only the fast digests of payload files. It returns the
+// algorithms whose digests did not match.
+func (validator *Validator) verifyDigests(gf *models.GenericFile) []string {
+	badAlgs := make([]string, 0)
+	// We don't record when we verified fast digests.
+	var unrecorded time.Time
+	digests := []struct {
+		alg            string
+		manifestDigest string
+		fileDigest     string
+		verifiedAt     *time.Time
+		fast           bool
+	}{
+		{constants.AlgMd5, gf.IngestManifestMd5, gf.IngestMd5, &gf.IngestMd5VerifiedAt, false},
+		{constants.AlgSha256, gf.IngestManifestSha256, gf.IngestSha256, &gf.IngestSha256VerifiedAt, false},
+		{constants.AlgSha512, gf.IngestManifestSha512, gf.IngestSha512, &gf.IngestSha512VerifiedAt, false},
+		{constants.AlgBlake2b, gf.IngestManifestBlake2b, gf.IngestBlake2b, &gf.IngestBlake2bVerifiedAt, false},
+		{constants.AlgSha1, gf.IngestManifestSha1, gf.IngestSha1, &gf.IngestSha1VerifiedAt, false},
+		{constants.AlgCrc32c, gf.IngestManifestCrc32c, gf.IngestCrc32c, &unrecorded, true},
+		{constants.AlgXxh64, gf.IngestManifestXxh64, gf.IngestXxh64, &unrecorded, true},
+	}
+	fastOnly := validator.preScreen && gf.IngestFileType == constants.PAYLOAD_FILE
+	for _, digest := range digests {
+		if fastOnly && !digest.fast {
+			continue
+		}
+		if digest.manifestDigest == "" || digest.manifestDigest == digest.fileDigest {
+			*digest.verifiedAt = time.Now().UTC()
+			continue
+		}
+		badAlgs = append(badAlgs, digest.alg)
+		if gf.IngestFileType == constants.PAYLOAD_FILE {
+			validator.summary.AddError(
+				"Bad %s digest for '%s': manifest says '%s', file digest is '%s'",
+				digest.alg, gf.OriginalPath(), digest.manifestDigest, digest.fileDigest)
+		} else {
+			validator.summary.AddError(
+				"Bad %s digest for tag file '%s': tagmanifest-%s.txt says '%s', file digest is '%s'",
+				digest.alg, gf.OriginalPath(), digest.alg, digest.manifestDigest, digest.fileDigest)
+		}
+	}
+	return badAlgs
 }
 
 // fileValidationDetail returns a specific description of the file name
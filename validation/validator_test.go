@@ -1,21 +1,30 @@
 package validation_test
 
 import (
+	"compress/gzip"
+	"crypto/sha1"
+	"fmt"
 	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/tarfile"
 	"github.com/APTrust/exchange/testhelper"
 	"github.com/APTrust/exchange/util"
+	"github.com/APTrust/exchange/util/fasthash"
 	"github.com/APTrust/exchange/util/fileutil"
 	"github.com/APTrust/exchange/util/storage"
+	"github.com/APTrust/exchange/util/testutil"
 	"github.com/APTrust/exchange/validation"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 // These are some common errors that we expect to encounter repeatedly
@@ -27,9 +36,9 @@ var err_2 = "File 'custom_tags/tag_file_xyz.pdf' in manifest 'tagmanifest-sha256
 var err_3 = "Value for tag 'Title' is missing."
 var err_4 = "Tag 'Access' has illegal value 'acksess'."
 var err_5 = "Bad sha256 digest for 'data/datastream-descMetadata': manifest says 'This-checksum-is-bad-on-purpose.-The-validator-should-catch-it!!', file digest is 'cf9cbce80062932e10ee9cd70ec05ebc24019deddfea4e54b8788decd28b4bc7'"
-var err_6 = "Bad md5 digest for 'custom_tags/tracked_tag_file.txt': manifest says '00000000000000000000000000000000', file digest is 'dafbffffc3ed28ef18363394935a2651'"
-var err_7 = "Bad sha256 digest for 'custom_tags/tracked_tag_file.txt': manifest says '0000000000000000000000000000000000000000000000000000000000000000', file digest is '3f2f50c5bde87b58d6132faee14d1a295d115338643c658df7fa147e2296ccdd'"
-var err_8 = "Tag 'Storage-Option' has illegal value 'cardboard-box'."
+var err_6 = "Bad md5 digest for tag file 'custom_tags/tracked_tag_file.txt': tagmanifest-md5.txt says '00000000000000000000000000000000', file digest is 'dafbffffc3ed28ef18363394935a2651'"
+var err_7 = "Bad sha256 digest for tag file 'custom_tags/tracked_tag_file.txt': tagmanifest-sha256.txt says '0000000000000000000000000000000000000000000000000000000000000000', file digest is '3f2f50c5bde87b58d6132faee14d1a295d115338643c658df7fa147e2296ccdd'"
+var err_8 = "Tag 'Storage-Option' has illegal value 'Cardboard-Box'."
 
 func getValidationConfig() (*validation.BagValidationConfig, error) {
 	configFilePath := path.Join("testdata", "json_objects", "bag_validation_config.json")
@@ -192,14 +201,14 @@ func TestValidator_FromTarFile_BagInvalid(t *testing.T) {
 	assert.True(t, summary.HasErrors())
 
 	// Check for specific errors
-	assert.True(t, util.StringListContains(summary.Errors, err_1))
-	assert.True(t, util.StringListContains(summary.Errors, err_2))
-	assert.True(t, util.StringListContains(summary.Errors, err_3))
-	assert.True(t, util.StringListContains(summary.Errors, err_4))
-	assert.True(t, util.StringListContains(summary.Errors, err_5))
-	assert.True(t, util.StringListContains(summary.Errors, err_6))
-	assert.True(t, util.StringListContains(summary.Errors, err_7))
-	assert.True(t, util.StringListContains(summary.Errors, err_8))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_1))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_2))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_3))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_4))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_5))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_6))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_7))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_8))
 }
 
 // Read a valid bag from a directory
@@ -237,14 +246,14 @@ func TestValidator_FromDirectory_BagInvalid_NoMeta(t *testing.T) {
 	assert.NotNil(t, summary)
 	assert.True(t, summary.HasErrors())
 	assert.Equal(t, 9, len(summary.Errors))
-	assert.True(t, util.StringListContains(summary.Errors, err_0))
-	assert.True(t, util.StringListContains(summary.Errors, err_1))
-	assert.True(t, util.StringListContains(summary.Errors, err_2))
-	assert.True(t, util.StringListContains(summary.Errors, err_3))
-	assert.True(t, util.StringListContains(summary.Errors, err_4))
-	assert.True(t, util.StringListContains(summary.Errors, err_5))
-	assert.True(t, util.StringListContains(summary.Errors, err_6))
-	assert.True(t, util.StringListContains(summary.Errors, err_7))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_0))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_1))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_2))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_3))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_4))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_5))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_6))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_7))
 }
 
 // Read an invalid bag from a directory, without tracking APTrust
@@ -265,14 +274,255 @@ func TestValidator_FromDirectory_BagInvalid(t *testing.T) {
 	assert.NotNil(t, summary)
 	assert.True(t, summary.HasErrors())
 	assert.Equal(t, 9, len(summary.Errors))
-	assert.True(t, util.StringListContains(summary.Errors, err_0))
-	assert.True(t, util.StringListContains(summary.Errors, err_1))
-	assert.True(t, util.StringListContains(summary.Errors, err_2))
-	assert.True(t, util.StringListContains(summary.Errors, err_3))
-	assert.True(t, util.StringListContains(summary.Errors, err_4))
-	assert.True(t, util.StringListContains(summary.Errors, err_5))
-	assert.True(t, util.StringListContains(summary.Errors, err_6))
-	assert.True(t, util.StringListContains(summary.Errors, err_7))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_0))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_1))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_2))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_3))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_4))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_5))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_6))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_7))
+}
+
+// Calculate checksums in parallel on a valid and an invalid untarred bag.
+func TestValidator_FromDirectory_ParallelChecksums(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.tagsample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	validator := getValidator(t, bagPath, true)
+	validator.ChecksumWorkers = 4
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+
+	tempDir, bagPath, err = testhelper.UntarTestBag("example.edu.tagsample_bad.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	validator = getValidator(t, bagPath, false)
+	validator.ChecksumWorkers = 4
+	defer deleteFile(validator.DBName())
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	assert.Equal(t, 9, len(summary.Errors))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_0))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_5))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_6))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_7))
+}
+
+func TestValidator_Progress(t *testing.T) {
+	validator := getValidator(t, "example.edu.tagsample_good.tar", true)
+	defer deleteFile(validator.DBName())
+	updates := make([]validation.ValidationProgress, 0)
+	validator.Progress = func(progress validation.ValidationProgress) {
+		updates = append(updates, progress)
+	}
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+	require.NotEmpty(t, updates)
+
+	// Each phase reports once when it starts, and once per file.
+	// The parsing phase covers only the four manifests and the three
+	// tag files that the config says to parse.
+	db, err := storage.NewBoltDB(validator.DBName())
+	require.Nil(t, err)
+	fileCount := int64(len(db.FileIdentifiers()))
+	db.Close()
+	var lastAdding, lastParsing validation.ValidationProgress
+	for _, progress := range updates {
+		if progress.Phase == validation.PhaseAddingFiles {
+			lastAdding = progress
+		} else if progress.Phase == validation.PhaseParsingFiles {
+			lastParsing = progress
+		}
+	}
+	assert.Equal(t, validation.PhaseAddingFiles, updates[0].Phase)
+	assert.EqualValues(t, 0, updates[0].FilesProcessed)
+	assert.Equal(t, validation.PhaseParsingFiles, updates[len(updates)-1].Phase)
+	assert.Equal(t, fileCount, lastAdding.FilesProcessed)
+	assert.EqualValues(t, 7, lastParsing.FilesProcessed)
+	assert.True(t, lastAdding.BytesHashed > 0)
+	assert.Equal(t, lastAdding.BytesHashed, lastParsing.BytesHashed)
+}
+
+func TestValidator_SpecialFiles(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.tagsample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	err = os.Symlink(filepath.Join(bagPath, "bagit.txt"), filepath.Join(bagPath, "data", "link.txt"))
+	require.Nil(t, err)
+
+	// By default, the validator skips symlinks and notes a warning.
+	validator := getValidator(t, bagPath, false)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+	assert.Equal(t, []string{"Skipped symlink 'data/link.txt'"}, validator.Warnings())
+
+	validator = getValidator(t, bagPath, false)
+	validator.BagValidationConfig.SpecialFilePolicy = &fileutil.SpecialFilePolicy{
+		Symlinks: fileutil.SpecialFileReject,
+	}
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	assert.True(t, summary.HasErrors())
+	assert.True(t, strings.Contains(summary.AllErrorsAsString(), "Bag contains symlink 'data/link.txt'"))
+}
+
+func TestValidator_Warnings(t *testing.T) {
+	// A bag with only an md5 manifest is valid, but gets a warning.
+	validator := validatorWithOptionalSpec(t, "example.edu.sample_good.tar")
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+	assert.Equal(t, []string{"Bag has only an md5 payload manifest. " +
+		"Please include a sha256 manifest as well."}, summary.Warnings)
+	assert.Equal(t, summary.Warnings, validator.Warnings())
+
+	// Junk files and deprecated tags get warnings too.
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.tagsample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	require.Nil(t, ioutil.WriteFile(filepath.Join(bagPath, ".DS_Store"), []byte("junk"), 0644))
+	conf := getConfig(t)
+	conf.TagSpecs["Bagging-Date"] = validation.TagSpec{
+		FilePath:   "bag-info.txt",
+		Presence:   validation.OPTIONAL,
+		Deprecated: true,
+	}
+	validator, err = validation.NewValidator(bagPath, conf, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+	require.Equal(t, 2, len(summary.Warnings), summary.AllWarningsAsString())
+	assert.Contains(t, summary.Warnings, "Bag contains '.DS_Store', which looks like a file "+
+		"created by the operating system. Consider removing it before bagging.")
+	assert.Contains(t, summary.Warnings, "Tag 'Bagging-Date' in bag-info.txt is deprecated, "+
+		"and may not be accepted in the future.")
+
+	// Calculating checksums in parallel shouldn't lose the
+	// junk file warning.
+	validator, err = validation.NewValidator(bagPath, conf, false)
+	require.Nil(t, err)
+	validator.ChecksumWorkers = 4
+	defer deleteFile(validator.DBName())
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+	assert.Contains(t, summary.Warnings, "Bag contains '.DS_Store', which looks like a file "+
+		"created by the operating system. Consider removing it before bagging.")
+}
+
+func TestValidator_MemoryDB(t *testing.T) {
+	validator := getValidator(t, "example.edu.tagsample_good.tar", false)
+	validator.UseMemoryDB = true
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+	assert.False(t, fileutil.FileExists(validator.DBName()))
+
+	// Bad bags should fail the same way in memory.
+	validator = getValidator(t, "example.edu.tagsample_bad.tar", false)
+	validator.UseMemoryDB = true
+	defer deleteFile(validator.DBName())
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_5))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_6))
+	assert.False(t, fileutil.FileExists(validator.DBName()))
+
+	// Memory DB is not used when preserving attributes,
+	// because callers read the .valdb file afterward.
+	validator = getValidator(t, "example.edu.tagsample_good.tar", true)
+	validator.UseMemoryDB = true
+	defer deleteFile(validator.DBName())
+	_, err = validator.Validate()
+	require.Nil(t, err)
+	assert.True(t, fileutil.FileExists(validator.DBName()))
+}
+
+func TestValidator_MemoryDBMaxFiles(t *testing.T) {
+	// Small bag stays in memory.
+	validator := getValidator(t, "example.edu.tagsample_good.tar", false)
+	validator.MemoryDBMaxFiles = validation.DEFAULT_MEMORY_DB_MAX_FILES
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+	assert.False(t, fileutil.FileExists(validator.DBName()))
+
+	// Bag with too many files moves to disk partway through.
+	validator = getValidator(t, "example.edu.tagsample_good.tar", false)
+	validator.MemoryDBMaxFiles = 3
+	defer deleteFile(validator.DBName())
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+	require.True(t, fileutil.FileExists(validator.DBName()))
+	db, err := storage.NewBoltDB(validator.DBName())
+	require.Nil(t, err)
+	assert.True(t, db.FileCount() > 3)
+	db.Close()
+
+	// Same when calculating checksums in parallel.
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.tagsample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	validator = getValidator(t, bagPath, false)
+	validator.ChecksumWorkers = 4
+	validator.MemoryDBMaxFiles = 3
+	defer deleteFile(validator.DBName())
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+	assert.True(t, fileutil.FileExists(validator.DBName()))
+}
+
+func TestValidator_DBBatchSize(t *testing.T) {
+	// Buffered and unbuffered writes should leave the same
+	// records in the .valdb file.
+	fileCounts := make([]int, 0)
+	for _, batchSize := range []int{0, 3, validation.DefaultDBBatchSize} {
+		validator := getValidator(t, "example.edu.tagsample_good.tar", true)
+		assert.Equal(t, validation.DefaultDBBatchSize, validator.DBBatchSize)
+		validator.DBBatchSize = batchSize
+		summary, err := validator.Validate()
+		require.Nil(t, err)
+		assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+
+		db, err := storage.NewBoltDB(validator.DBName())
+		require.Nil(t, err)
+		identifiers := db.FileIdentifiers()
+		fileCounts = append(fileCounts, len(identifiers))
+		for _, identifier := range identifiers {
+			gf, err := db.GetGenericFile(identifier)
+			require.Nil(t, err)
+			require.NotNil(t, gf)
+			assert.Equal(t, constants.StorageStandard, gf.StorageOption, identifier)
+			assert.NotEmpty(t, gf.IngestSha256, identifier)
+		}
+		db.Close()
+		deleteFile(validator.DBName())
+	}
+	assert.Equal(t, fileCounts[0], fileCounts[1])
+	assert.Equal(t, fileCounts[0], fileCounts[2])
 }
 
 // Read from a file that is not a directory or a valid tar file.
@@ -289,16 +539,16 @@ func TestValidator_BadFileFormat(t *testing.T) {
 	defer deleteFile(validator.DBName())
 	summary, err := validator.Validate()
 	assert.True(t, summary.HasErrors())
-	assert.Equal(t, 10, len(summary.Errors))
-	assert.True(t, strings.Contains(summary.Errors[0], "Error getting file iterator"))
-	assert.True(t, strings.Contains(summary.Errors[1], "is not a directory"))
-	assert.True(t, util.StringListContains(summary.Errors, "Required file 'tagmanifest-md5.txt' is missing."))
-	assert.True(t, util.StringListContains(summary.Errors, "Required file 'bagit.txt' is missing."))
-	assert.True(t, util.StringListContains(summary.Errors, "Required file 'bag-info.txt' is missing."))
-	assert.True(t, util.StringListContains(summary.Errors, "Required file 'aptrust-info.txt' is missing."))
-	assert.True(t, util.StringListContains(summary.Errors, "Required file 'manifest-md5.txt' is missing."))
-	assert.True(t, util.StringListContains(summary.Errors, "Required tag 'Access' is missing."))
-	assert.True(t, util.StringListContains(summary.Errors, "Required tag 'Title' is missing."))
+	assert.Equal(t, 9, len(summary.Errors))
+	assert.True(t, strings.Contains(summary.Errors[0].Message, "Error getting file iterator"))
+	assert.True(t, strings.Contains(summary.Errors[0].Message, "is not a directory"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required file 'tagmanifest-md5.txt' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required file 'bagit.txt' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required file 'bag-info.txt' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required file 'aptrust-info.txt' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required file 'manifest-md5.txt' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required tag 'Access' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required tag 'Title' is missing."))
 }
 
 // Make sure we catch all errors in an invalid bag.
@@ -311,14 +561,14 @@ func TestValidator_InvalidBag(t *testing.T) {
 	assert.NotNil(t, summary)
 	assert.True(t, summary.HasErrors())
 	assert.Equal(t, 9, len(summary.Errors))
-	assert.True(t, util.StringListContains(summary.Errors, err_0))
-	assert.True(t, util.StringListContains(summary.Errors, err_1))
-	assert.True(t, util.StringListContains(summary.Errors, err_2))
-	assert.True(t, util.StringListContains(summary.Errors, err_3))
-	assert.True(t, util.StringListContains(summary.Errors, err_4))
-	assert.True(t, util.StringListContains(summary.Errors, err_5))
-	assert.True(t, util.StringListContains(summary.Errors, err_6))
-	assert.True(t, util.StringListContains(summary.Errors, err_7))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_0))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_1))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_2))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_3))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_4))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_5))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_6))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), err_7))
 }
 
 // These good bags are from the old Bagman test suite. We have to make sure they
@@ -363,8 +613,8 @@ func TestValidator_BadAccess(t *testing.T) {
 	assert.NotNil(t, summary)
 	require.True(t, summary.HasErrors())
 	assert.Equal(t, 2, len(summary.Errors))
-	assert.True(t, util.StringListContains(summary.Errors, "Required file 'tagmanifest-md5.txt' is missing."))
-	assert.True(t, util.StringListContains(summary.Errors, "Tag 'Access' has illegal value 'hands off!'."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required file 'tagmanifest-md5.txt' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Tag 'Access' has illegal value 'Hands Off!'."))
 }
 
 func TestValidator_BadChecksums(t *testing.T) {
@@ -375,11 +625,52 @@ func TestValidator_BadChecksums(t *testing.T) {
 	assert.NotNil(t, summary)
 	require.True(t, summary.HasErrors())
 	assert.Equal(t, 5, len(summary.Errors))
-	assert.True(t, util.StringListContains(summary.Errors, "Required tag 'Access' is missing."))
-	assert.True(t, util.StringListContains(summary.Errors, "Bad md5 digest for 'data/datastream-DC': manifest says '44d85cf4810d6c6fe877BlahBlahBlah', file digest is '44d85cf4810d6c6fe87750117633e461'"))
-	assert.True(t, util.StringListContains(summary.Errors, "Bad md5 digest for 'data/datastream-MARC': manifest says '93e381dfa9ad0086dbe3BlahBlahBlah', file digest is '93e381dfa9ad0086dbe3b92e0324bae6'"))
-	assert.True(t, util.StringListContains(summary.Errors, "Bad md5 digest for 'data/datastream-RELS-EXT': manifest says 'ff731b9a1758618f6cc2BlahBlahBlah', file digest is 'ff731b9a1758618f6cc22538dede6174'"))
-	assert.True(t, util.StringListContains(summary.Errors, "Bad md5 digest for 'data/datastream-descMetadata': manifest says '4bd0ad5f85c00ce84a45BlahBlahBlah', file digest is '4bd0ad5f85c00ce84a455466b24c8960'"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required tag 'Access' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Bad md5 digest for 'data/datastream-DC': manifest says '44d85cf4810d6c6fe877BlahBlahBlah', file digest is '44d85cf4810d6c6fe87750117633e461'"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Bad md5 digest for 'data/datastream-MARC': manifest says '93e381dfa9ad0086dbe3BlahBlahBlah', file digest is '93e381dfa9ad0086dbe3b92e0324bae6'"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Bad md5 digest for 'data/datastream-RELS-EXT': manifest says 'ff731b9a1758618f6cc2BlahBlahBlah', file digest is 'ff731b9a1758618f6cc22538dede6174'"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Bad md5 digest for 'data/datastream-descMetadata': manifest says '4bd0ad5f85c00ce84a45BlahBlahBlah', file digest is '4bd0ad5f85c00ce84a455466b24c8960'"))
+}
+
+func TestValidator_StructureOnly(t *testing.T) {
+	// Structure-only validation doesn't check payload digests.
+	validator := validatorWithOptionalSpec(t, "example.edu.sample_bad_checksums.tar")
+	defer deleteFile(validator.DBName())
+	summary, err := validator.ValidateStructureOnly()
+	assert.Nil(t, err)
+	assert.NotNil(t, summary)
+	require.True(t, summary.HasErrors())
+	assert.Equal(t, 1, len(summary.Errors))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required tag 'Access' is missing."))
+
+	// It still catches structural problems.
+	validator = validatorWithOptionalSpec(t, "example.edu.sample_no_aptrust_info.tar")
+	defer deleteFile(validator.DBName())
+	summary, err = validator.ValidateStructureOnly()
+	assert.Nil(t, err)
+	require.True(t, summary.HasErrors())
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required file 'aptrust-info.txt' is missing."))
+
+	validator = validatorWithOptionalSpec(t, "example.edu.sample_missing_data_file.tar")
+	defer deleteFile(validator.DBName())
+	summary, err = validator.ValidateStructureOnly()
+	assert.Nil(t, err)
+	require.True(t, summary.HasErrors())
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "File 'data/datastream-DC' in manifest 'manifest-md5.txt' is missing from bag"))
+
+	// Payload files are not hashed.
+	validator = validatorWithOptionalSpec(t, "example.edu.sample_bad_checksums.tar")
+	defer deleteFile(validator.DBName())
+	validator.PreserveExtendedAttributes = true
+	summary, err = validator.ValidateStructureOnly()
+	assert.Nil(t, err)
+	db, err := storage.NewBoltDB(validator.DBName())
+	require.Nil(t, err)
+	gf, err := db.GetGenericFile("example.edu.sample_bad_checksums/data/datastream-DC")
+	require.Nil(t, err)
+	require.NotNil(t, gf)
+	assert.Empty(t, gf.IngestMd5)
+	db.Close()
 }
 
 func TestValidator_BadFileNames(t *testing.T) {
@@ -390,10 +681,10 @@ func TestValidator_BadFileNames(t *testing.T) {
 	assert.NotNil(t, summary)
 	require.True(t, summary.HasErrors())
 	assert.Equal(t, 4, len(summary.Errors))
-	assert.True(t, util.StringListContains(summary.Errors, "Filename 'data/-starts-with-dash' is not valid according to APTrust validation rules"))
-	assert.True(t, util.StringListContains(summary.Errors, "Filename 'data/contains#hash' is not valid according to APTrust validation rules"))
-	assert.True(t, util.StringListContains(summary.Errors, "Filename 'data/contains*star' is not valid according to APTrust validation rules"))
-	assert.True(t, util.StringListContains(summary.Errors, "Filename 'data/contains+plus' is not valid according to APTrust validation rules"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Filename 'data/-starts-with-dash' is not valid according to APTrust validation rules"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Filename 'data/contains#hash' is not valid according to APTrust validation rules"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Filename 'data/contains*star' is not valid according to APTrust validation rules"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Filename 'data/contains+plus' is not valid according to APTrust validation rules"))
 
 }
 
@@ -405,8 +696,8 @@ func TestValidator_MissingDataFile(t *testing.T) {
 	assert.NotNil(t, summary)
 	require.True(t, summary.HasErrors())
 	assert.Equal(t, 2, len(summary.Errors))
-	assert.True(t, util.StringListContains(summary.Errors, "File 'data/datastream-DC' in manifest 'manifest-md5.txt' is missing from bag"))
-	assert.True(t, util.StringListContains(summary.Errors, "Required tag 'Access' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "File 'data/datastream-DC' in manifest 'manifest-md5.txt' is missing from bag"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required tag 'Access' is missing."))
 }
 
 func TestValidator_NoAPTrustInfo(t *testing.T) {
@@ -418,9 +709,9 @@ func TestValidator_NoAPTrustInfo(t *testing.T) {
 	require.True(t, summary.HasErrors())
 
 	assert.Equal(t, 3, len(summary.Errors))
-	assert.True(t, util.StringListContains(summary.Errors, "Required file 'aptrust-info.txt' is missing."))
-	assert.True(t, util.StringListContains(summary.Errors, "Required tag 'Title' is missing."))
-	assert.True(t, util.StringListContains(summary.Errors, "Required tag 'Access' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required file 'aptrust-info.txt' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required tag 'Title' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required tag 'Access' is missing."))
 }
 
 func TestValidator_NoBagInfo(t *testing.T) {
@@ -431,8 +722,8 @@ func TestValidator_NoBagInfo(t *testing.T) {
 	assert.NotNil(t, summary)
 	require.True(t, summary.HasErrors())
 	assert.Equal(t, 2, len(summary.Errors))
-	assert.True(t, util.StringListContains(summary.Errors, "Required file 'bag-info.txt' is missing."))
-	assert.True(t, util.StringListContains(summary.Errors, "Required tag 'Access' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required file 'bag-info.txt' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required tag 'Access' is missing."))
 }
 
 func TestValidator_NoDataDir(t *testing.T) {
@@ -443,59 +734,486 @@ func TestValidator_NoDataDir(t *testing.T) {
 	assert.NotNil(t, summary)
 	require.True(t, summary.HasErrors())
 	assert.Equal(t, 4, len(summary.Errors))
-	assert.True(t, util.StringListContains(summary.Errors, "File 'data/datastream-DC' in manifest 'manifest-md5.txt' is missing from bag"))
-	assert.True(t, util.StringListContains(summary.Errors, "File 'data/datastream-descMetadata' in manifest 'manifest-md5.txt' is missing from bag"))
-	assert.True(t, util.StringListContains(summary.Errors, "File 'data/datastream-MARC' in manifest 'manifest-md5.txt' is missing from bag"))
-	assert.True(t, util.StringListContains(summary.Errors, "File 'data/datastream-RELS-EXT' in manifest 'manifest-md5.txt' is missing from bag"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "File 'data/datastream-DC' in manifest 'manifest-md5.txt' is missing from bag"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "File 'data/datastream-descMetadata' in manifest 'manifest-md5.txt' is missing from bag"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "File 'data/datastream-MARC' in manifest 'manifest-md5.txt' is missing from bag"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "File 'data/datastream-RELS-EXT' in manifest 'manifest-md5.txt' is missing from bag"))
+}
+
+func TestValidator_NoMd5Manifest(t *testing.T) {
+	validator := validatorWithOptionalSpec(t, "example.edu.sample_no_md5_manifest.tar")
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	assert.Nil(t, err)
+	assert.NotNil(t, summary)
+	require.True(t, summary.HasErrors())
+	assert.Equal(t, 6, len(summary.Errors))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Bag contains no payload manifest."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Required file 'manifest-md5.txt' is missing."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "File 'data/datastream-DC' does not appear in any payload manifest (md5, sha256, sha512 or blake2b)"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "File 'data/datastream-MARC' does not appear in any payload manifest (md5, sha256, sha512 or blake2b)"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "File 'data/datastream-RELS-EXT' does not appear in any payload manifest (md5, sha256, sha512 or blake2b)"))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "File 'data/datastream-descMetadata' does not appear in any payload manifest (md5, sha256, sha512 or blake2b)"))
+}
+
+func TestValidator_NoTitle(t *testing.T) {
+	validator := validatorWithOptionalSpec(t, "example.edu.sample_no_title.tar")
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	assert.Nil(t, err)
+	assert.NotNil(t, summary)
+	require.True(t, summary.HasErrors())
+	assert.Equal(t, 1, len(summary.Errors))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Value for tag 'Title' is missing."))
+}
+
+func TestValidator_WrongFolderName(t *testing.T) {
+	validator := validatorWithOptionalSpec(t, "example.edu.sample_wrong_folder_name.tar")
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	assert.Nil(t, err)
+	assert.NotNil(t, summary)
+	require.True(t, summary.HasErrors())
+	assert.Equal(t, 1, len(summary.Errors))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Tarred bag should untar to directory 'example.edu.sample_wrong_folder_name', not 'wrong_folder_name'"))
+}
+
+func TestValidator_IllegalControlCharacter(t *testing.T) {
+	validator := validatorWithOptionalSpec(t, "example.edu.sample_illegal_control.tar")
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	assert.Nil(t, err)
+	assert.NotNil(t, summary)
+	require.True(t, summary.HasErrors())
+	assert.True(t, summary.ErrorIsFatal)
+	assert.Equal(t, 1, len(summary.Errors))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "PATH_CONTROL_CHARACTER: File path 'example.edu.sample_illegal_control/data/datastream\\u007f.txt' contains a control character. Bags may not contain files like this."))
+}
+
+// BagIt spec section 2.1.3 says percent signs, carriage returns and
+// line feeds in manifest file paths are percent-encoded.
+func TestValidator_PercentEncodedManifestPaths(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	err = ioutil.WriteFile(filepath.Join(bagPath, "data", "100%.txt"), []byte("Hello"), 0644)
+	require.Nil(t, err)
+	manifest, err := os.OpenFile(filepath.Join(bagPath, "manifest-md5.txt"),
+		os.O_APPEND|os.O_WRONLY, 0644)
+	require.Nil(t, err)
+	_, err = manifest.WriteString("8b1a9953c4611296a827abf8c47804d7  data/100%25.txt\n")
+	require.Nil(t, err)
+	manifest.Close()
+
+	bagValidationConfig := getConfig(t)
+	bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+	validator, err := validation.NewValidator(bagPath, bagValidationConfig, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	assert.Nil(t, err)
+	require.NotNil(t, summary)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+}
+
+func TestValidator_UnicodeNormalization(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	// File name is NFD, as on macOS, but the manifest entry is NFC.
+	nfdName := "cafe\u0301.txt"
+	nfcName := "caf\u00e9.txt"
+	err = ioutil.WriteFile(filepath.Join(bagPath, "data", nfdName), []byte("Hello"), 0644)
+	require.Nil(t, err)
+	manifest, err := os.OpenFile(filepath.Join(bagPath, "manifest-md5.txt"),
+		os.O_APPEND|os.O_WRONLY, 0644)
+	require.Nil(t, err)
+	_, err = manifest.WriteString("8b1a9953c4611296a827abf8c47804d7  data/" + nfcName + "\n")
+	require.Nil(t, err)
+	manifest.Close()
+
+	for _, policy := range []string{"", validation.NormalizeNFC, validation.NormalizeNFD} {
+		bagValidationConfig := getConfig(t)
+		bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+		bagValidationConfig.FileNamePattern = "PERMISSIVE"
+		bagValidationConfig.UnicodeNormalization = policy
+		validator, err := validation.NewValidator(bagPath, bagValidationConfig, false)
+		require.Nil(t, err)
+		summary, err := validator.Validate()
+		deleteFile(validator.DBName())
+		assert.Nil(t, err)
+		require.NotNil(t, summary)
+		assert.False(t, summary.HasErrors(), "Policy '%s': %s", policy, summary.AllErrorsAsString())
+	}
+
+	bagValidationConfig := getConfig(t)
+	bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+	bagValidationConfig.UnicodeNormalization = validation.NormalizeNone
+	validator, err := validation.NewValidator(bagPath, bagValidationConfig, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	assert.Nil(t, err)
+	require.NotNil(t, summary)
+	assert.True(t, util.StringListContains(summary.ErrorMessages(),
+		"File 'data/"+nfcName+"' in manifest 'manifest-md5.txt' is missing from bag"))
+}
+
+func TestValidator_BadTagFileDigest(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.tagsample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	tagFile, err := os.OpenFile(filepath.Join(bagPath, "custom_tags", "tracked_file_custom.xml"),
+		os.O_APPEND|os.O_WRONLY, 0644)
+	require.Nil(t, err)
+	_, err = tagFile.WriteString("<!-- changed after bagging -->\n")
+	require.Nil(t, err)
+	tagFile.Close()
+
+	validator := getValidator(t, bagPath, false)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	assert.Nil(t, err)
+	require.NotNil(t, summary)
+	require.Equal(t, 2, len(summary.Errors), summary.AllErrorsAsString())
+	assert.True(t, strings.HasPrefix(summary.Errors[0].Message,
+		"Bad md5 digest for tag file 'custom_tags/tracked_file_custom.xml': "+
+			"tagmanifest-md5.txt says 'bb278da2170f85946c6b4b1501f7c43b', file digest is "))
+	assert.True(t, strings.HasPrefix(summary.Errors[1].Message,
+		"Bad sha256 digest for tag file 'custom_tags/tracked_file_custom.xml': "+
+			"tagmanifest-sha256.txt says "))
+}
+
+// writeSha512Manifest writes a manifest-sha512.txt covering every
+// payload file in the bag at bagPath. If corruptFile is not empty,
+// that file's digest will be wrong.
+func writeSha512Manifest(t *testing.T, bagPath, corruptFile string) {
+	writeDigestManifest(t, bagPath, constants.AlgSha512, corruptFile)
+}
+
+// writeDigestManifest writes manifest-<alg>.txt for the payload files
+// in bagPath, with a bad digest for corruptFile, if it's not empty.
+func writeDigestManifest(t *testing.T, bagPath, alg, corruptFile string) {
+	files, err := filepath.Glob(filepath.Join(bagPath, "data", "*"))
+	require.Nil(t, err)
+	require.NotEmpty(t, files)
+	lines := make([]string, 0)
+	for _, file := range files {
+		digest, err := fileutil.CalculateChecksum(file, alg)
+		require.Nil(t, err)
+		relPath := "data/" + filepath.Base(file)
+		if relPath == corruptFile {
+			digest = strings.Repeat("0", 128)
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s\n", digest, relPath))
+	}
+	err = ioutil.WriteFile(filepath.Join(bagPath, "manifest-"+alg+".txt"),
+		[]byte(strings.Join(lines, "")), 0644)
+	require.Nil(t, err)
+}
+
+func TestValidator_Sha512Manifest(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	writeSha512Manifest(t, bagPath, "")
+
+	bagValidationConfig := getConfig(t)
+	bagValidationConfig.FixityAlgorithms = append(bagValidationConfig.FixityAlgorithms, constants.AlgSha512)
+	bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+	validator, err := validation.NewValidator(bagPath, bagValidationConfig, true)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	assert.Nil(t, err)
+	require.NotNil(t, summary)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+
+	boltDB, err := storage.NewBoltDB(validator.DBName())
+	require.Nil(t, err)
+	defer boltDB.Close()
+	gf, err := boltDB.GetGenericFile("example.edu.sample_good/data/datastream-DC")
+	require.Nil(t, err)
+	require.NotNil(t, gf)
+	assert.Equal(t, 128, len(gf.IngestSha512))
+	assert.Equal(t, gf.IngestSha512, gf.IngestManifestSha512)
+	assert.False(t, gf.IngestSha512GeneratedAt.IsZero())
+}
+
+func TestValidator_Sha512ManifestNotConfigured(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	writeSha512Manifest(t, bagPath, "")
+
+	// The default config doesn't calculate sha512, so the validator
+	// should skip the sha512 manifest rather than fail every file.
+	bagValidationConfig := getConfig(t)
+	require.False(t, util.StringListContains(bagValidationConfig.FixityAlgorithms, constants.AlgSha512))
+	bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+	validator, err := validation.NewValidator(bagPath, bagValidationConfig, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	assert.Nil(t, err)
+	require.NotNil(t, summary)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+}
+
+func TestValidator_BadSha512Digest(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	writeSha512Manifest(t, bagPath, "data/datastream-DC")
+
+	bagValidationConfig := getConfig(t)
+	bagValidationConfig.FixityAlgorithms = append(bagValidationConfig.FixityAlgorithms, constants.AlgSha512)
+	bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+	validator, err := validation.NewValidator(bagPath, bagValidationConfig, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	assert.Nil(t, err)
+	require.NotNil(t, summary)
+	require.Equal(t, 1, len(summary.Errors), summary.AllErrorsAsString())
+	assert.True(t, strings.HasPrefix(summary.Errors[0].Message, "Bad sha512 digest for 'data/datastream-DC'"))
+}
+
+func TestValidator_Blake2bManifest(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	writeDigestManifest(t, bagPath, constants.AlgBlake2b, "")
+
+	bagValidationConfig := getConfig(t)
+	bagValidationConfig.FixityAlgorithms = append(bagValidationConfig.FixityAlgorithms, constants.AlgBlake2b)
+	bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+	validator, err := validation.NewValidator(bagPath, bagValidationConfig, true)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	assert.Nil(t, err)
+	require.NotNil(t, summary)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+
+	boltDB, err := storage.NewBoltDB(validator.DBName())
+	require.Nil(t, err)
+	defer boltDB.Close()
+	gf, err := boltDB.GetGenericFile("example.edu.sample_good/data/datastream-DC")
+	require.Nil(t, err)
+	require.NotNil(t, gf)
+	assert.Equal(t, 128, len(gf.IngestBlake2b))
+	assert.Equal(t, gf.IngestBlake2b, gf.IngestManifestBlake2b)
+	assert.False(t, gf.IngestBlake2bGeneratedAt.IsZero())
+	assert.False(t, gf.IngestBlake2bVerifiedAt.IsZero())
+}
+
+func TestValidator_BadBlake2bDigest(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	writeDigestManifest(t, bagPath, constants.AlgBlake2b, "data/datastream-DC")
+
+	bagValidationConfig := getConfig(t)
+	bagValidationConfig.FixityAlgorithms = append(bagValidationConfig.FixityAlgorithms, constants.AlgBlake2b)
+	bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+	validator, err := validation.NewValidator(bagPath, bagValidationConfig, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	assert.Nil(t, err)
+	require.NotNil(t, summary)
+	require.Equal(t, 1, len(summary.Errors), summary.AllErrorsAsString())
+	assert.True(t, strings.HasPrefix(summary.Errors[0].Message, "Bad blake2b digest for 'data/datastream-DC'"))
+}
+
+// validateWithPayloadOxum untars sample_good, adds the specified
+// Payload-Oxum to its bag-info.txt, and validates it.
+func validateWithPayloadOxum(t *testing.T, oxum string) *models.WorkSummary {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	bagInfo, err := os.OpenFile(filepath.Join(bagPath, "bag-info.txt"),
+		os.O_APPEND|os.O_WRONLY, 0644)
+	require.Nil(t, err)
+	_, err = bagInfo.WriteString(fmt.Sprintf("\nPayload-Oxum: %s\n", oxum))
+	require.Nil(t, err)
+	bagInfo.Close()
+
+	bagValidationConfig := getConfig(t)
+	bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+	validator, err := validation.NewValidator(bagPath, bagValidationConfig, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	require.NotNil(t, summary)
+	return summary
+}
+
+func TestValidator_PayloadOxum(t *testing.T) {
+	// The payload of sample_good has four files totalling 13,821 bytes.
+	summary := validateWithPayloadOxum(t, "13821.4")
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+
+	summary = validateWithPayloadOxum(t, "13822.4")
+	require.Equal(t, 1, len(summary.Errors), summary.AllErrorsAsString())
+	assert.Equal(t, "Payload-Oxum mismatch: bag-info.txt says payload has 13822 bytes in 4 files, "+
+		"but bag has 13821 bytes in 4 files. The bag may be incomplete or truncated.", summary.Errors[0].Message)
+
+	summary = validateWithPayloadOxum(t, "13821.5")
+	require.Equal(t, 1, len(summary.Errors), summary.AllErrorsAsString())
+	assert.True(t, strings.HasPrefix(summary.Errors[0].Message, "Payload-Oxum mismatch"))
+
+	summary = validateWithPayloadOxum(t, "forty.four")
+	require.Equal(t, 1, len(summary.Errors), summary.AllErrorsAsString())
+	assert.True(t, strings.HasPrefix(summary.Errors[0].Message, "Payload-Oxum in bag-info.txt is invalid"))
 }
 
-func TestValidator_NoMd5Manifest(t *testing.T) {
-	validator := validatorWithOptionalSpec(t, "example.edu.sample_no_md5_manifest.tar")
-	defer deleteFile(validator.DBName())
-	summary, err := validator.Validate()
-	assert.Nil(t, err)
-	assert.NotNil(t, summary)
-	require.True(t, summary.HasErrors())
-	assert.Equal(t, 6, len(summary.Errors))
-	assert.True(t, util.StringListContains(summary.Errors, "Bag contains no payload manifest."))
-	assert.True(t, util.StringListContains(summary.Errors, "Required file 'manifest-md5.txt' is missing."))
-	assert.True(t, util.StringListContains(summary.Errors, "File 'data/datastream-DC' does not appear in any payload manifest (md5 or sha256)"))
-	assert.True(t, util.StringListContains(summary.Errors, "File 'data/datastream-MARC' does not appear in any payload manifest (md5 or sha256)"))
-	assert.True(t, util.StringListContains(summary.Errors, "File 'data/datastream-RELS-EXT' does not appear in any payload manifest (md5 or sha256)"))
-	assert.True(t, util.StringListContains(summary.Errors, "File 'data/datastream-descMetadata' does not appear in any payload manifest (md5 or sha256)"))
+// tarBagWithBadOxum writes a tarred copy of the sample_good bag whose
+// Payload-Oxum says it has one more file than it does. It returns the
+// temp dir, which the caller should delete, and the path to the tar file.
+func tarBagWithBadOxum(t *testing.T) (string, string) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	bagInfo, err := os.OpenFile(filepath.Join(bagPath, "bag-info.txt"),
+		os.O_APPEND|os.O_WRONLY, 0644)
+	require.Nil(t, err)
+	_, err = bagInfo.WriteString("\nPayload-Oxum: 13821.5\n")
+	require.Nil(t, err)
+	bagInfo.Close()
+	tarPath := filepath.Join(tempDir, "example.edu.sample_good.tar")
+	writer := tarfile.NewWriter(tarPath)
+	require.Nil(t, writer.Open())
+	err = filepath.Walk(bagPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relPath, _ := filepath.Rel(tempDir, filePath)
+		return writer.AddToArchive(filePath, relPath)
+	})
+	require.Nil(t, err)
+	require.Nil(t, writer.Close())
+	return tempDir, tarPath
 }
 
-func TestValidator_NoTitle(t *testing.T) {
-	validator := validatorWithOptionalSpec(t, "example.edu.sample_no_title.tar")
+// A tarred bag whose Payload-Oxum doesn't match its tar headers
+// should fail before the validator reads or hashes any files.
+func TestValidator_PayloadOxumCheckedBeforeHashing(t *testing.T) {
+	tempDir, tarPath := tarBagWithBadOxum(t)
+	defer os.RemoveAll(tempDir)
+
+	bagValidationConfig := getConfig(t)
+	bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+	validator, err := validation.NewValidator(tarPath, bagValidationConfig, false)
+	require.Nil(t, err)
 	defer deleteFile(validator.DBName())
+	progressCalls := 0
+	validator.Progress = func(progress validation.ValidationProgress) { progressCalls++ }
 	summary, err := validator.Validate()
-	assert.Nil(t, err)
-	assert.NotNil(t, summary)
-	require.True(t, summary.HasErrors())
-	assert.Equal(t, 1, len(summary.Errors))
-	assert.True(t, util.StringListContains(summary.Errors, "Value for tag 'Title' is missing."))
+	require.Nil(t, err)
+	require.Equal(t, 1, len(summary.Errors), summary.AllErrorsAsString())
+	assert.Equal(t, "Payload-Oxum mismatch: bag-info.txt says payload has 13821 bytes in 5 files, "+
+		"but bag has 13821 bytes in 4 files. The bag may be incomplete or truncated.", summary.Errors[0].Message)
+	assert.Equal(t, 0, progressCalls)
 }
 
-func TestValidator_WrongFolderName(t *testing.T) {
-	validator := validatorWithOptionalSpec(t, "example.edu.sample_wrong_folder_name.tar")
+// A gzipped bag is decompressed only once, so its Payload-Oxum is
+// checked after the validator reads it.
+func TestValidator_GzippedPayloadOxumCheckedAfterReading(t *testing.T) {
+	tempDir, tarPath := tarBagWithBadOxum(t)
+	defer os.RemoveAll(tempDir)
+	src, err := os.Open(tarPath)
+	require.Nil(t, err)
+	defer src.Close()
+	gzPath := tarPath + ".gz"
+	dest, err := os.Create(gzPath)
+	require.Nil(t, err)
+	gzWriter := gzip.NewWriter(dest)
+	_, err = io.Copy(gzWriter, src)
+	require.Nil(t, err)
+	require.Nil(t, gzWriter.Close())
+	require.Nil(t, dest.Close())
+
+	bagValidationConfig := getConfig(t)
+	bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+	bagValidationConfig.AcceptSerialization = []string{"application/x-tar", "application/gzip"}
+	validator, err := validation.NewValidator(gzPath, bagValidationConfig, false)
+	require.Nil(t, err)
 	defer deleteFile(validator.DBName())
+	progressCalls := 0
+	validator.Progress = func(progress validation.ValidationProgress) { progressCalls++ }
 	summary, err := validator.Validate()
-	assert.Nil(t, err)
-	assert.NotNil(t, summary)
-	require.True(t, summary.HasErrors())
-	assert.Equal(t, 1, len(summary.Errors))
-	assert.True(t, util.StringListContains(summary.Errors, "Tarred bag should untar to directory 'example.edu.sample_wrong_folder_name', not 'wrong_folder_name'"))
+	require.Nil(t, err)
+	require.Equal(t, 1, len(summary.Errors), summary.AllErrorsAsString())
+	assert.Equal(t, "Payload-Oxum mismatch: bag-info.txt says payload has 13821 bytes in 5 files, "+
+		"but bag has 13821 bytes in 4 files. The bag may be incomplete or truncated.", summary.Errors[0].Message)
+	assert.True(t, progressCalls > 0)
 }
 
-func TestValidator_IllegalControlCharacter(t *testing.T) {
-	validator := validatorWithOptionalSpec(t, "example.edu.sample_illegal_control.tar")
+// Files whose names contain line feeds or carriage returns are
+// valid, as long as the manifest percent-encodes their names.
+func TestValidator_PercentEncodedNewlines(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	for _, fileName := range []string{"line\nbreak.txt", "carriage\rreturn.txt"} {
+		err = ioutil.WriteFile(filepath.Join(bagPath, "data", fileName), []byte("Hello"), 0644)
+		require.Nil(t, err)
+	}
+	manifest, err := os.OpenFile(filepath.Join(bagPath, "manifest-md5.txt"),
+		os.O_APPEND|os.O_WRONLY, 0644)
+	require.Nil(t, err)
+	_, err = manifest.WriteString("8b1a9953c4611296a827abf8c47804d7  data/line%0Abreak.txt\n" +
+		"8b1a9953c4611296a827abf8c47804d7  data/carriage%0dreturn.txt\n")
+	require.Nil(t, err)
+	manifest.Close()
+
+	bagValidationConfig := getConfig(t)
+	bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+	// APTrust's production config uses the permissive pattern. The
+	// strict APTRUST pattern in the test config rejects these names.
+	bagValidationConfig.FileNamePattern = "PERMISSIVE"
+	require.Nil(t, bagValidationConfig.CompileFileNameRegex())
+	validator, err := validation.NewValidator(bagPath, bagValidationConfig, false)
+	require.Nil(t, err)
 	defer deleteFile(validator.DBName())
 	summary, err := validator.Validate()
 	assert.Nil(t, err)
-	assert.NotNil(t, summary)
-	require.True(t, summary.HasErrors())
-	assert.Equal(t, 1, len(summary.Errors))
-	assert.True(t, util.StringListContains(summary.Errors, "File name 'data/datastream\\u007f.txt' contains an illegal unicode control character"))
+	require.NotNil(t, summary)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+	db, err := storage.NewBoltDB(validator.DBName())
+	require.Nil(t, err)
+	defer db.Close()
+	for _, identifier := range []string{
+		"example.edu.sample_good/data/line\nbreak.txt",
+		"example.edu.sample_good/data/carriage\rreturn.txt",
+	} {
+		gf, err := db.GetGenericFile(identifier)
+		require.Nil(t, err, identifier)
+		require.NotNil(t, gf, identifier)
+		assert.Equal(t, "8b1a9953c4611296a827abf8c47804d7", gf.IngestMd5, identifier)
+	}
 }
 
 var gfIdentifiers = []string{
@@ -539,6 +1257,19 @@ func TestValidator_SavesMinimumMetadata(t *testing.T) {
 	assert.Equal(t, "Charley Horse", obj.BagGroupIdentifier)
 	assert.Equal(t, 10, len(obj.IngestTags))
 
+	// We should be able to rebuild bag-info.txt exactly.
+	bagInfo := obj.TagList("bag-info.txt")
+	require.Equal(t, 6, len(bagInfo.Tags))
+	assert.Equal(t, "Source-Organization", bagInfo.Tags[0].Label)
+	assert.Equal(t, "Source-Organization: virginia.edu\n"+
+		"Bagging-Date: 2014-04-14T11:55:26.17-0400\n"+
+		"Bag-Count: 1 of 1\n"+
+		"Bag-Group-Identifier: Charley Horse\n"+
+		"Internal-Sender-Description: so much depends upon a red wheel barrow "+
+		"glazed with rain water beside the white chickens\n"+
+		"Internal-Sender-Identifier: uva-internal-id-0001\n",
+		string(bagInfo.Bytes()))
+
 	for _, identifier := range gfIdentifiers {
 		gf, err := boltDB.GetGenericFile(identifier)
 		require.Nil(t, err)
@@ -706,7 +1437,7 @@ func TestNewValidator_IllegalFetchTxt(t *testing.T) {
 	assert.Nil(t, err)
 	assert.NotNil(t, summary)
 	assert.True(t, summary.HasErrors())
-	assert.True(t, util.StringListContains(summary.Errors, "Bag contains a fetch.txt file, but the profile does not allow it."))
+	assert.True(t, util.StringListContains(summary.ErrorMessages(), "Bag contains a fetch.txt file, but the profile does not allow it."))
 
 }
 
@@ -752,4 +1483,518 @@ func TestNewValidator_SetIntelObjTagValue(t *testing.T) {
 	// Test that prior description from aptrust-info.txt/Description remains.
 	validator.SetIntelObjTagValue(obj, internalSenderDescription)
 	assert.Equal(t, description.Value, obj.Description)
+
+	embargoUntil := models.NewTag("aptrust-info.txt", "Embargo-Until", "2030-01-31")
+	rightsStatement := models.NewTag("aptrust-info.txt", "Rights-Statement", "http://rightsstatements.org/vocab/InC/1.0/")
+	validator.SetIntelObjTagValue(obj, embargoUntil)
+	validator.SetIntelObjTagValue(obj, rightsStatement)
+	assert.Equal(t, time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC), obj.EmbargoUntil)
+	assert.Equal(t, rightsStatement.Value, obj.RightsStatement)
+
+	// Invalid dates leave the object without an embargo.
+	// The validator reports them as errors.
+	embargoUntil.Value = "sometime"
+	validator.SetIntelObjTagValue(obj, embargoUntil)
+	assert.True(t, obj.EmbargoUntil.IsZero())
+}
+
+// gzipBag writes a gzipped copy of a tarred test bag to dir/gzName
+// and returns the path to the copy.
+func gzipBag(t *testing.T, bagName, dir, gzName string) string {
+	src, err := os.Open(getBagPath(t, bagName))
+	require.Nil(t, err)
+	defer src.Close()
+	gzPath := filepath.Join(dir, gzName)
+	dest, err := os.Create(gzPath)
+	require.Nil(t, err)
+	defer dest.Close()
+	gzWriter := gzip.NewWriter(dest)
+	_, err = io.Copy(gzWriter, src)
+	require.Nil(t, err)
+	require.Nil(t, gzWriter.Close())
+	return gzPath
+}
+
+func TestValidator_GzippedTar(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gzipped_bags")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	for _, gzName := range []string{"example.edu.tagsample_good.tar.gz", "example.edu.tagsample_good.tgz"} {
+		gzPath := gzipBag(t, "example.edu.tagsample_good.tar", tempDir, gzName)
+		conf := getConfig(t)
+		conf.AcceptSerialization = []string{"application/x-tar", "application/gzip"}
+		validator, err := validation.NewValidator(gzPath, conf, true)
+		require.Nil(t, err)
+		assert.Equal(t, "example.edu.tagsample_good", validator.ObjIdentifier)
+		assert.Equal(t, filepath.Join(tempDir, "example.edu.tagsample_good.valdb"), validator.DBName())
+		summary, err := validator.Validate()
+		require.Nil(t, err)
+		assert.False(t, summary.HasErrors(), gzName+": "+summary.AllErrorsAsString())
+
+		db, err := storage.NewBoltDB(validator.DBName())
+		require.Nil(t, err)
+		obj, err := db.GetIntellectualObject(validator.ObjIdentifier)
+		require.Nil(t, err)
+		require.NotNil(t, obj)
+		assert.Equal(t, gzPath, obj.IngestTarFilePath)
+		assert.Equal(t, []string{"example.edu.tagsample_good"}, obj.IngestTopLevelDirNames)
+		assert.True(t, len(db.FileIdentifiers()) > 0)
+		db.Close()
+		os.Remove(validator.DBName())
+	}
+
+	// Gzipped bags must be explicitly accepted.
+	gzPath := filepath.Join(tempDir, "example.edu.tagsample_good.tar.gz")
+	validator, err := validation.NewValidator(gzPath, getConfig(t), false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(summary.Errors))
+	assert.Contains(t, summary.Errors[0].Message, "Gzipped tarred bags are not accepted")
+}
+
+func TestValidator_GzippedTarStream(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "gzipped_bags")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	gzPath := gzipBag(t, "example.edu.tagsample_good.tar", tempDir, "example.edu.tagsample_good.tar.gz")
+	openStream := func() (io.ReadCloser, error) {
+		return os.Open(gzPath)
+	}
+	conf := getConfig(t)
+	conf.AcceptSerialization = []string{"application/x-tar", "application/gzip"}
+	validator, err := validation.NewStreamingValidator("example.edu.tagsample_good.tar.gz",
+		tempDir, openStream, conf, false)
+	require.Nil(t, err)
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+}
+
+func TestValidator_Zip(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "zipped_bags")
+	require.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	zipPath := filepath.Join(tempDir, "example.edu.tagsample_good.zip")
+	require.Nil(t, testutil.TarToZip(getBagPath(t, "example.edu.tagsample_good.tar"), zipPath))
+
+	conf := getConfig(t)
+	conf.AcceptSerialization = []string{"application/x-tar", "application/zip"}
+	validator, err := validation.NewValidator(zipPath, conf, true)
+	require.Nil(t, err)
+	assert.Equal(t, "example.edu.tagsample_good", validator.ObjIdentifier)
+	assert.Equal(t, filepath.Join(tempDir, "example.edu.tagsample_good.valdb"), validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+
+	db, err := storage.NewBoltDB(validator.DBName())
+	require.Nil(t, err)
+	obj, err := db.GetIntellectualObject(validator.ObjIdentifier)
+	require.Nil(t, err)
+	require.NotNil(t, obj)
+	assert.Equal(t, zipPath, obj.IngestTarFilePath)
+	assert.Equal(t, []string{"example.edu.tagsample_good"}, obj.IngestTopLevelDirNames)
+
+	// Zipped entries keep their mod times, and directory
+	// entries don't become GenericFiles.
+	tarValidator := getValidator(t, "example.edu.tagsample_good.tar", true)
+	defer deleteFile(tarValidator.DBName())
+	_, err = tarValidator.Validate()
+	require.Nil(t, err)
+	tarDB, err := storage.NewBoltDB(tarValidator.DBName())
+	require.Nil(t, err)
+	assert.Equal(t, tarDB.FileIdentifiers(), db.FileIdentifiers())
+	for _, gfIdentifier := range db.FileIdentifiers() {
+		zipFile, err := db.GetGenericFile(gfIdentifier)
+		require.Nil(t, err)
+		tarFile, err := tarDB.GetGenericFile(gfIdentifier)
+		require.Nil(t, err)
+		assert.True(t, zipFile.FileModified.Equal(tarFile.FileModified), gfIdentifier)
+		assert.Equal(t, tarFile.IngestMd5, zipFile.IngestMd5, gfIdentifier)
+	}
+	tarDB.Close()
+	db.Close()
+	os.Remove(validator.DBName())
+
+	// Zipped bags must be explicitly accepted.
+	validator, err = validation.NewValidator(zipPath, getConfig(t), false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(summary.Errors))
+	assert.Contains(t, summary.Errors[0].Message, "Zipped bags are not accepted")
+
+	// Zip files can't be streamed, because the
+	// central directory is at the end of the file.
+	_, err = validation.NewStreamingValidator("example.edu.tagsample_good.zip", tempDir,
+		func() (io.ReadCloser, error) { return os.Open(zipPath) }, conf, false)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "only tarred bags can be streamed")
+}
+
+func TestValidator_TagValuePattern(t *testing.T) {
+	conf := getConfig(t)
+	conf.TagSpecs["Bagging-Date"] = validation.TagSpec{
+		FilePath:     "bag-info.txt",
+		Presence:     validation.OPTIONAL,
+		ValuePattern: `^\d{4}/\d{2}/\d{2}$`,
+	}
+	validator, err := validation.NewValidator(getBagPath(t, "example.edu.tagsample_good.tar"), conf, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(summary.Errors), summary.AllErrorsAsString())
+	assert.True(t, strings.HasPrefix(summary.Errors[0].Message, "Tag 'Bagging-Date' has value '"))
+	assert.True(t, strings.HasSuffix(summary.Errors[0].Message, `which does not match pattern '^\d{4}/\d{2}/\d{2}$'.`))
+
+	conf.TagSpecs["Bagging-Date"] = validation.TagSpec{
+		FilePath:     "bag-info.txt",
+		Presence:     validation.OPTIONAL,
+		ValuePattern: `^\d{4}-\d{2}-\d{2}`,
+	}
+	validator, err = validation.NewValidator(getBagPath(t, "example.edu.tagsample_good.tar"), conf, false)
+	require.Nil(t, err)
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+}
+
+func TestValidator_MaxFileCountAndSize(t *testing.T) {
+	validator := validatorWithOptionalSpec(t, "example.edu.sample_good.tar")
+	defer deleteFile(validator.DBName())
+	validator.BagValidationConfig.MaxFileCount = 3
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(summary.Errors))
+	assert.True(t, summary.ErrorIsFatal)
+	assert.Equal(t, "Bag contains more than 3 files, which is the most a bag may contain.", summary.Errors[0].Message)
+
+	validator = validatorWithOptionalSpec(t, "example.edu.sample_good.tar")
+	defer deleteFile(validator.DBName())
+	validator.BagValidationConfig.MaxBagSize = 1000
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(summary.Errors))
+	assert.True(t, summary.ErrorIsFatal)
+	assert.Equal(t, "Bag is larger than 1000 bytes, which is the largest a bag may be.", summary.Errors[0].Message)
+
+	// Limits the bag is within don't cause errors.
+	validator = validatorWithOptionalSpec(t, "example.edu.sample_good.tar")
+	defer deleteFile(validator.DBName())
+	validator.BagValidationConfig.MaxFileCount = 1000
+	validator.BagValidationConfig.MaxBagSize = 100000000
+	summary, err = validator.Validate()
+	require.Nil(t, err)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+}
+
+func TestValidator_MaxFileCountUntarred(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	bagValidationConfig, err := getValidationConfig()
+	require.Nil(t, err)
+	bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+	bagValidationConfig.MaxFileCount = 3
+	validator, err := validation.NewValidator(bagPath, bagValidationConfig, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	validator.ChecksumWorkers = 4
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(summary.Errors))
+	assert.Equal(t, "Bag contains more than 3 files, which is the most a bag may contain.", summary.Errors[0].Message)
+}
+
+func TestValidator_Sha1Manifest(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	// Write a sha1 manifest for the payload.
+	payloadFiles, err := filepath.Glob(filepath.Join(bagPath, "data", "*"))
+	require.Nil(t, err)
+	manifest := ""
+	for _, payloadFile := range payloadFiles {
+		data, err := ioutil.ReadFile(payloadFile)
+		require.Nil(t, err)
+		manifest += fmt.Sprintf("%x  data/%s\n", sha1.Sum(data), filepath.Base(payloadFile))
+	}
+	manifestPath := filepath.Join(bagPath, "manifest-sha1.txt")
+	err = ioutil.WriteFile(manifestPath, []byte(manifest), 0644)
+	require.Nil(t, err)
+
+	validateSha1 := func(fixityAlgorithms ...string) *models.WorkSummary {
+		bagValidationConfig, err := getValidationConfig()
+		require.Nil(t, err)
+		bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+		bagValidationConfig.FileSpecs["manifest-sha1.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+		bagValidationConfig.FixityAlgorithms = fixityAlgorithms
+		validator, err := validation.NewValidator(bagPath, bagValidationConfig, false)
+		require.Nil(t, err)
+		defer deleteFile(validator.DBName())
+		summary, err := validator.Validate()
+		require.Nil(t, err)
+		return summary
+	}
+
+	summary := validateSha1("md5", "sha256", "sha1")
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+
+	// Corrupt one of the sha1 digests.
+	badManifest := "0000000000000000000000000000000000000000" + manifest[40:]
+	err = ioutil.WriteFile(manifestPath, []byte(badManifest), 0644)
+	require.Nil(t, err)
+	summary = validateSha1("md5", "sha256", "sha1")
+	require.Equal(t, 1, len(summary.Errors), summary.AllErrorsAsString())
+	assert.True(t, strings.HasPrefix(summary.Errors[0].Message, "Bad sha1 digest for 'data/"), summary.Errors[0].Message)
+
+	// Without sha1 in FixityAlgorithms, we ignore the sha1 manifest.
+	summary = validateSha1("md5", "sha256")
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+}
+
+func TestValidator_FastManifests(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	// Write crc32c and xxh64 manifests for the payload.
+	payloadFiles, err := filepath.Glob(filepath.Join(bagPath, "data", "*"))
+	require.Nil(t, err)
+	crc32cManifest := ""
+	xxh64Manifest := ""
+	for _, payloadFile := range payloadFiles {
+		data, err := ioutil.ReadFile(payloadFile)
+		require.Nil(t, err)
+		crc32cHash := fasthash.NewCRC32C()
+		crc32cHash.Write(data)
+		crc32cManifest += fmt.Sprintf("%x  data/%s\n", crc32cHash.Sum(nil), filepath.Base(payloadFile))
+		xxh64Hash := fasthash.NewXXH64()
+		xxh64Hash.Write(data)
+		xxh64Manifest += fmt.Sprintf("%x  data/%s\n", xxh64Hash.Sum(nil), filepath.Base(payloadFile))
+	}
+	crc32cPath := filepath.Join(bagPath, "manifest-crc32c.txt")
+	require.Nil(t, ioutil.WriteFile(crc32cPath, []byte(crc32cManifest), 0644))
+	xxh64Path := filepath.Join(bagPath, "manifest-xxh64.txt")
+	require.Nil(t, ioutil.WriteFile(xxh64Path, []byte(xxh64Manifest), 0644))
+
+	validate := func(preScreen bool) *models.WorkSummary {
+		bagValidationConfig, err := getValidationConfig()
+		require.Nil(t, err)
+		bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+		bagValidationConfig.FileSpecs["manifest-crc32c.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+		bagValidationConfig.FileSpecs["manifest-xxh64.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+		bagValidationConfig.FixityAlgorithms = []string{"md5", "sha256", "crc32c", "xxh64"}
+		validator, err := validation.NewValidator(bagPath, bagValidationConfig, false)
+		require.Nil(t, err)
+		defer deleteFile(validator.DBName())
+		var summary *models.WorkSummary
+		if preScreen {
+			summary, err = validator.PreScreen()
+		} else {
+			summary, err = validator.Validate()
+		}
+		require.Nil(t, err)
+		return summary
+	}
+
+	summary := validate(false)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+	summary = validate(true)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+
+	// Corrupt one of the xxh64 digests. Both checks catch it.
+	badManifest := "0000000000000000" + xxh64Manifest[16:]
+	require.Nil(t, ioutil.WriteFile(xxh64Path, []byte(badManifest), 0644))
+	for _, preScreen := range []bool{false, true} {
+		summary = validate(preScreen)
+		require.Equal(t, 1, len(summary.Errors), summary.AllErrorsAsString())
+		assert.True(t, strings.HasPrefix(summary.Errors[0].Message, "Bad xxh64 digest for 'data/"), summary.Errors[0].Message)
+	}
+	require.Nil(t, ioutil.WriteFile(xxh64Path, []byte(xxh64Manifest), 0644))
+
+	// Corrupt one of the md5 digests. PreScreen doesn't check
+	// payload md5 digests, so only Validate catches it.
+	md5Path := filepath.Join(bagPath, "manifest-md5.txt")
+	md5Manifest, err := ioutil.ReadFile(md5Path)
+	require.Nil(t, err)
+	badMd5 := append([]byte("00000000000000000000000000000000"), md5Manifest[32:]...)
+	require.Nil(t, ioutil.WriteFile(md5Path, badMd5, 0644))
+	summary = validate(true)
+	assert.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+	summary = validate(false)
+	assert.True(t, summary.HasErrors())
+}
+
+func TestValidator_PremisEvents(t *testing.T) {
+	validator := getValidator(t, "example.edu.tagsample_good.tar", true)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	require.False(t, summary.HasErrors(), summary.AllErrorsAsString())
+
+	db, err := storage.NewBoltDB(validator.DBName())
+	require.Nil(t, err)
+	defer db.Close()
+	payloadFiles := 0
+	for _, identifier := range db.FileIdentifiers() {
+		gf, err := db.GetGenericFile(identifier)
+		require.Nil(t, err)
+		validationEvents := gf.FindEventsByType(constants.EventValidation)
+		require.Equal(t, 1, len(validationEvents), identifier)
+		assert.Equal(t, constants.StatusSuccess, validationEvents[0].Outcome)
+		assert.Equal(t, gf.Identifier, validationEvents[0].GenericFileIdentifier)
+		if gf.IngestFileType != constants.PAYLOAD_FILE {
+			continue
+		}
+		payloadFiles++
+		// The config calculates md5 and sha256, and the
+		// bag has manifests for both.
+		digestEvents := gf.FindEventsByType(constants.EventDigestCalculation)
+		require.Equal(t, 2, len(digestEvents), identifier)
+		assert.Equal(t, "md5:"+gf.IngestMd5, digestEvents[0].OutcomeDetail)
+		assert.Equal(t, "sha256:"+gf.IngestSha256, digestEvents[1].OutcomeDetail)
+		fixityEvents := gf.FindEventsByType(constants.EventFixityCheck)
+		require.Equal(t, 2, len(fixityEvents), identifier)
+		for _, event := range fixityEvents {
+			assert.Equal(t, constants.StatusSuccess, event.Outcome)
+		}
+		assert.False(t, gf.LastFixityCheck.IsZero())
+	}
+	assert.NotZero(t, payloadFiles)
+}
+
+func TestValidator_PremisEventsInvalidBag(t *testing.T) {
+	validator := getValidator(t, "example.edu.tagsample_bad.tar", true)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+	require.True(t, summary.HasErrors())
+
+	db, err := storage.NewBoltDB(validator.DBName())
+	require.Nil(t, err)
+	defer db.Close()
+	failures := 0
+	for _, identifier := range db.FileIdentifiers() {
+		gf, err := db.GetGenericFile(identifier)
+		require.Nil(t, err)
+		for _, event := range gf.FindEventsByType(constants.EventValidation) {
+			if event.Outcome == constants.StatusFailed {
+				failures++
+				assert.NotEmpty(t, event.OutcomeDetail)
+			}
+		}
+	}
+	assert.NotZero(t, failures)
+}
+
+func TestValidator_NoPremisEventsWithoutExtendedAttributes(t *testing.T) {
+	validator := getValidator(t, "example.edu.tagsample_good.tar", false)
+	defer deleteFile(validator.DBName())
+	_, err := validator.Validate()
+	require.Nil(t, err)
+	db, err := storage.NewBoltDB(validator.DBName())
+	require.Nil(t, err)
+	defer db.Close()
+	require.NotEmpty(t, db.FileIdentifiers())
+	for _, identifier := range db.FileIdentifiers() {
+		gf, err := db.GetGenericFile(identifier)
+		require.Nil(t, err)
+		assert.Empty(t, gf.PremisEvents, identifier)
+	}
+}
+
+func TestValidator_DuplicateManifestEntries(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	manifestPath := filepath.Join(bagPath, "manifest-md5.txt")
+	data, err := ioutil.ReadFile(manifestPath)
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.True(t, len(lines) > 2)
+	firstDigest, firstPath := lines[0][:32], strings.TrimSpace(lines[0][32:])
+	secondPath := strings.TrimSpace(lines[1][32:])
+
+	// Repeat the first entry exactly, repeat the second with a
+	// different digest, and add an entry with a sha256-length digest.
+	conflicting := strings.Repeat("0", 32)
+	wrongLength := strings.Repeat("a", 64)
+	extra := []string{
+		firstDigest + "  " + firstPath,
+		conflicting + "  " + secondPath,
+		wrongLength + "  " + firstPath,
+	}
+	manifest := strings.Join(append(lines, extra...), "\n") + "\n"
+	require.Nil(t, ioutil.WriteFile(manifestPath, []byte(manifest), 0644))
+
+	bagValidationConfig, err := getValidationConfig()
+	require.Nil(t, err)
+	bagValidationConfig.FileSpecs["tagmanifest-md5.txt"] = validation.FileSpec{Presence: "OPTIONAL"}
+	validator, err := validation.NewValidator(bagPath, bagValidationConfig, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+
+	n := len(lines)
+	require.Equal(t, 2, len(summary.Errors), summary.AllErrorsAsString())
+	assert.Equal(t, fmt.Sprintf(
+		"Manifest manifest-md5.txt lists '%s' with digest '%s' on line 2 and '%s' with digest '%s' on line %d",
+		secondPath, lines[1][:32], secondPath, conflicting, n+2), summary.Errors[0].Message)
+	assert.Equal(t, fmt.Sprintf(
+		"Line %d of manifest manifest-md5.txt has a 64-character digest for '%s', but md5 digests have 32 characters",
+		n+3, firstPath), summary.Errors[1].Message)
+	assert.Contains(t, validator.Warnings(), fmt.Sprintf(
+		"Manifest manifest-md5.txt lists '%s' on line 1 and '%s' on line %d, with the same digest",
+		firstPath, firstPath, n+1))
+}
+
+func TestValidator_ConflictingManifests(t *testing.T) {
+	tempDir, bagPath, err := testhelper.UntarTestBag("example.edu.sample_good.tar")
+	require.Nil(t, err)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(bagPath, "manifest-md5.txt"))
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.True(t, len(lines) > 2)
+	firstDigest, firstPath := lines[0][:32], strings.TrimSpace(lines[0][32:])
+	secondDigest, secondPath := lines[1][:32], strings.TrimSpace(lines[1][32:])
+
+	// The tag manifest agrees with the payload manifest about the
+	// first file, and disagrees about the second.
+	conflicting := strings.Repeat("0", 32)
+	tagManifest := firstDigest + "  " + firstPath + "\n" +
+		conflicting + "  " + secondPath + "\n"
+	require.Nil(t, ioutil.WriteFile(filepath.Join(bagPath, "tagmanifest-md5.txt"),
+		[]byte(tagManifest), 0644))
+
+	bagValidationConfig, err := getValidationConfig()
+	require.Nil(t, err)
+	validator, err := validation.NewValidator(bagPath, bagValidationConfig, false)
+	require.Nil(t, err)
+	defer deleteFile(validator.DBName())
+	summary, err := validator.Validate()
+	require.Nil(t, err)
+
+	require.Equal(t, 1, len(summary.Errors), summary.AllErrorsAsString())
+	message := summary.Errors[0].Message
+	assert.Contains(t, message, fmt.Sprintf("lists '%s' with md5 digest", secondPath))
+	assert.Contains(t, message, "but another manifest lists it with digest")
+	assert.Contains(t, message, secondDigest)
+	assert.Contains(t, message, conflicting)
 }
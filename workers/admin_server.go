@@ -0,0 +1,291 @@
+package workers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util/logger"
+	"github.com/nsqio/nsq/nsqd"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ADMIN_TOKEN_ENV is the name of the environment variable that holds
+// the token clients must send to use a worker's admin API.
+const ADMIN_TOKEN_ENV = "EXCHANGE_ADMIN_TOKEN"
+
+// Pausable is the part of nsq.Consumer the admin API needs to
+// pause and resume consumption.
+type Pausable interface {
+	ChangeMaxInFlight(int)
+}
+
+// AdminStatus describes what a worker is doing right now.
+type AdminStatus struct {
+	// Worker is the name of the worker process, e.g. apt_restore.
+	Worker string
+	// Host is the name of the host the worker is running on.
+	Host string
+	// Pid is the worker's process id.
+	Pid int
+	// StartedAt is when the admin API started, which is roughly
+	// when the worker started.
+	StartedAt time.Time
+	// Paused is true if consumption from NSQ is paused.
+	Paused bool
+	// ActiveConfig is the config file the worker loaded.
+	ActiveConfig string
+	// ConfigFingerprint is the sha256 digest of the worker's config.
+	ConfigFingerprint string
+	// InFlightItems are the NSQ messages the worker is processing.
+	InFlightItems []models.InFlightItem
+	// NSQChannel contains the stats for the worker's NSQ channel,
+	// including its depth. This is nil if stats are not available.
+	NSQChannel *nsqd.ChannelStats
+	// NSQError describes why NSQChannel is nil.
+	NSQError string `json:",omitempty"`
+	// InternalChannels is the number of items waiting in each of the
+	// worker's internal go channels, such as PackageChannel.
+	InternalChannels map[string]int
+	// RecentErrors are the most recent errors in the worker's log.
+	RecentErrors []logger.LogEntry
+}
+
+// AdminServer serves a small HTTP API that lets ops see what a
+// worker is doing, and pause and resume its consumption from NSQ.
+// All requests must include the header "Authorization: Bearer <token>",
+// where token matches the EXCHANGE_ADMIN_TOKEN environment variable.
+//
+// GET  /status/ returns an AdminStatus as JSON.
+// POST /pause/  stops the worker from taking new messages from NSQ.
+//               Messages already in flight will finish.
+// POST /resume/ resumes taking messages from NSQ.
+// GET  /restore_readiness/?object=<identifier> returns a
+//               RestoreReadiness as JSON, describing whether the
+//               object's files are in S3 or must come from Glacier.
+// GET  /object_ledger/?object=<identifier> returns an ObjectLedger
+//               as JSON, describing the object's active WorkItems,
+//               where they are queued, and recent errors about it.
+type AdminServer struct {
+	Context      *context.Context
+	WorkerConfig *models.WorkerConfig
+	Consumer     Pausable
+	// Worker is the worker struct, such as an APTRestorer. The
+	// admin API reports the depth of its exported channels.
+	Worker    interface{}
+	StartedAt time.Time
+	// Readiness answers restore readiness queries.
+	Readiness *RestoreReadinessChecker
+	// Ledger answers object ledger queries.
+	Ledger    *ObjectLedgerBuilder
+	token     string
+	paused    bool
+	mutex     *sync.Mutex
+}
+
+// NewAdminServer returns a new AdminServer. Param token is the
+// token clients must send. It may not be empty.
+func NewAdminServer(_context *context.Context, workerConfig *models.WorkerConfig, consumer Pausable, worker interface{}, token string) *AdminServer {
+	return &AdminServer{
+		Context:      _context,
+		WorkerConfig: workerConfig,
+		Consumer:     consumer,
+		Worker:       worker,
+		StartedAt:    time.Now().UTC(),
+		Readiness:    NewRestoreReadinessChecker(_context),
+		Ledger:       NewObjectLedgerBuilder(_context),
+		token:        token,
+		mutex:        &sync.Mutex{},
+	}
+}
+
+// StartAdminServer starts the worker's admin API on
+// 127.0.0.1:workerConfig.AdminPort in a separate goroutine.
+// This is a no-op if AdminPort is zero. It returns an error
+// if the EXCHANGE_ADMIN_TOKEN environment variable is not set,
+// since we won't run the admin API without authentication.
+func StartAdminServer(_context *context.Context, workerConfig *models.WorkerConfig, consumer Pausable, worker interface{}) error {
+	if workerConfig.AdminPort == 0 {
+		return nil
+	}
+	token := os.Getenv(ADMIN_TOKEN_ENV)
+	if token == "" {
+		return fmt.Errorf("Not starting admin API because environment variable %s is not set",
+			ADMIN_TOKEN_ENV)
+	}
+	server := NewAdminServer(_context, workerConfig, consumer, worker, token)
+	listenAddr := fmt.Sprintf("127.0.0.1:%d", workerConfig.AdminPort)
+	_context.MessageLog.Info("Serving admin API at %s", listenAddr)
+	go func() {
+		err := http.ListenAndServe(listenAddr, server.Handler())
+		_context.MessageLog.Error("Admin API stopped: %v", err)
+	}()
+	return nil
+}
+
+// Handler returns the http.Handler for the admin API.
+func (server *AdminServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status/", server.authorize(http.MethodGet, server.handleStatus))
+	mux.HandleFunc("/pause/", server.authorize(http.MethodPost, server.handlePause))
+	mux.HandleFunc("/resume/", server.authorize(http.MethodPost, server.handleResume))
+	mux.HandleFunc("/restore_readiness/", server.authorize(http.MethodGet, server.handleRestoreReadiness))
+	mux.HandleFunc("/object_ledger/", server.authorize(http.MethodGet, server.handleObjectLedger))
+	return mux
+}
+
+// Paused returns true if consumption from NSQ is paused.
+func (server *AdminServer) Paused() bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.paused
+}
+
+// Status returns the worker's current status.
+func (server *AdminServer) Status() *AdminStatus {
+	hostname, _ := os.Hostname()
+	status := &AdminStatus{
+		Worker:            path.Base(os.Args[0]),
+		Host:              hostname,
+		Pid:               os.Getpid(),
+		StartedAt:         server.StartedAt,
+		Paused:            server.Paused(),
+		ActiveConfig:      server.Context.Config.ActiveConfig,
+		ConfigFingerprint: server.Context.Config.Fingerprint(),
+		InFlightItems:     server.Context.WorkerMetrics.InFlightItems(),
+		InternalChannels:  ChannelDepths(server.Worker),
+		RecentErrors:      logger.RecentErrors.Entries(),
+	}
+	stats, err := server.Context.NSQClient.GetStats()
+	if err != nil {
+		status.NSQError = err.Error()
+	} else {
+		status.NSQChannel = stats.ChannelStats(server.WorkerConfig.NsqTopic,
+			server.WorkerConfig.NsqChannel)
+		if status.NSQChannel == nil {
+			status.NSQError = fmt.Sprintf("NSQ has no channel %s/%s",
+				server.WorkerConfig.NsqTopic, server.WorkerConfig.NsqChannel)
+		}
+	}
+	return status
+}
+
+// ChannelDepths returns the number of items in each exported
+// channel field of worker, which should be a pointer to a struct.
+// It returns an empty map if worker has no channels.
+func ChannelDepths(worker interface{}) map[string]int {
+	depths := make(map[string]int)
+	value := reflect.ValueOf(worker)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return depths
+	}
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.PkgPath == "" && value.Field(i).Kind() == reflect.Chan && !value.Field(i).IsNil() {
+			depths[field.Name] = value.Field(i).Len()
+		}
+	}
+	return depths
+}
+
+// authorize wraps handler, rejecting requests that use the wrong
+// HTTP method or don't include the right token.
+func (server *AdminServer) authorize(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if server.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(server.token)) != 1 {
+			server.Context.MessageLog.Warning("[%s] Unauthorized admin request for %s",
+				r.RemoteAddr, r.URL.Path)
+			server.writeJson(w, http.StatusUnauthorized, map[string]string{"Error": "Unauthorized"})
+			return
+		}
+		if r.Method != method {
+			server.writeJson(w, http.StatusMethodNotAllowed,
+				map[string]string{"Error": fmt.Sprintf("Use %s for %s", method, r.URL.Path)})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (server *AdminServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	server.writeJson(w, http.StatusOK, server.Status())
+}
+
+func (server *AdminServer) handlePause(w http.ResponseWriter, r *http.Request) {
+	server.setPaused(true)
+	server.Context.MessageLog.Info("[%s] Paused consumption from %s/%s",
+		r.RemoteAddr, server.WorkerConfig.NsqTopic, server.WorkerConfig.NsqChannel)
+	server.writeJson(w, http.StatusOK, map[string]bool{"Paused": true})
+}
+
+func (server *AdminServer) handleResume(w http.ResponseWriter, r *http.Request) {
+	server.setPaused(false)
+	server.Context.MessageLog.Info("[%s] Resumed consumption from %s/%s",
+		r.RemoteAddr, server.WorkerConfig.NsqTopic, server.WorkerConfig.NsqChannel)
+	server.writeJson(w, http.StatusOK, map[string]bool{"Paused": false})
+}
+
+func (server *AdminServer) handleRestoreReadiness(w http.ResponseWriter, r *http.Request) {
+	objIdentifier := r.URL.Query().Get("object")
+	if objIdentifier == "" {
+		server.writeJson(w, http.StatusBadRequest,
+			map[string]string{"Error": "Param object is required"})
+		return
+	}
+	readiness, err := server.Readiness.Check(objIdentifier)
+	if err != nil {
+		server.Context.MessageLog.Warning("[%s] Restore readiness check for %s failed: %v",
+			r.RemoteAddr, objIdentifier, err)
+		server.writeJson(w, http.StatusInternalServerError, map[string]string{"Error": err.Error()})
+		return
+	}
+	server.writeJson(w, http.StatusOK, readiness)
+}
+
+func (server *AdminServer) handleObjectLedger(w http.ResponseWriter, r *http.Request) {
+	objIdentifier := r.URL.Query().Get("object")
+	if objIdentifier == "" {
+		server.writeJson(w, http.StatusBadRequest,
+			map[string]string{"Error": "Param object is required"})
+		return
+	}
+	ledger, err := server.Ledger.Build(objIdentifier)
+	if err != nil {
+		server.Context.MessageLog.Warning("[%s] Object ledger for %s failed: %v",
+			r.RemoteAddr, objIdentifier, err)
+		server.writeJson(w, http.StatusInternalServerError, map[string]string{"Error": err.Error()})
+		return
+	}
+	server.writeJson(w, http.StatusOK, ledger)
+}
+
+// setPaused pauses consumption by setting the consumer's max
+// in flight to zero, and resumes by restoring WorkerConfig.MaxInFlight.
+func (server *AdminServer) setPaused(paused bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if paused {
+		server.Consumer.ChangeMaxInFlight(0)
+	} else {
+		server.Consumer.ChangeMaxInFlight(server.WorkerConfig.MaxInFlight)
+	}
+	server.paused = paused
+}
+
+func (server *AdminServer) writeJson(w http.ResponseWriter, status int, data interface{}) {
+	jsonResponse, _ := json.Marshal(data)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(jsonResponse)
+}
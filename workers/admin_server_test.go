@@ -0,0 +1,100 @@
+package workers_test
+
+import (
+	"encoding/json"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/APTrust/exchange/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testConsumer struct {
+	maxInFlight int
+}
+
+func (c *testConsumer) ChangeMaxInFlight(n int) { c.maxInFlight = n }
+
+type testWorker struct {
+	WorkChannel chan int
+	DoneChannel chan int
+	Name        string
+	hidden      chan int
+}
+
+func adminRequest(t *testing.T, server *httptest.Server, method, path, token string) *http.Response {
+	req, err := http.NewRequest(method, server.URL+path, nil)
+	require.Nil(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	return resp
+}
+
+func TestAdminServer(t *testing.T) {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+	_context.NSQClient.URL = "http://127.0.0.1:1"
+	workerConfig := &models.WorkerConfig{
+		MaxInFlight: 20,
+		NsqTopic:    "restore_topic",
+		NsqChannel:  "restore_worker_chan",
+	}
+	consumer := &testConsumer{maxInFlight: 20}
+	worker := &testWorker{WorkChannel: make(chan int, 5)}
+	worker.WorkChannel <- 1
+	adminServer := workers.NewAdminServer(_context, workerConfig, consumer, worker, "secret")
+	server := httptest.NewServer(adminServer.Handler())
+	defer server.Close()
+
+	// Requests without the right token are rejected.
+	resp := adminRequest(t, server, http.MethodGet, "/status/", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = adminRequest(t, server, http.MethodGet, "/status/", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = adminRequest(t, server, http.MethodGet, "/status/", "secret")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	status := &workers.AdminStatus{}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(status))
+	resp.Body.Close()
+	assert.False(t, status.Paused)
+	assert.Equal(t, _context.Config.Fingerprint(), status.ConfigFingerprint)
+	assert.Equal(t, map[string]int{"WorkChannel": 1}, status.InternalChannels)
+	assert.Nil(t, status.NSQChannel)
+	assert.NotEmpty(t, status.NSQError)
+
+	// Pause and resume require POST.
+	resp = adminRequest(t, server, http.MethodGet, "/pause/", "secret")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.False(t, adminServer.Paused())
+
+	resp = adminRequest(t, server, http.MethodPost, "/pause/", "secret")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, adminServer.Paused())
+	assert.Equal(t, 0, consumer.maxInFlight)
+
+	resp = adminRequest(t, server, http.MethodPost, "/resume/", "secret")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.False(t, adminServer.Paused())
+	assert.Equal(t, 20, consumer.maxInFlight)
+}
+
+func TestChannelDepths(t *testing.T) {
+	worker := &testWorker{
+		WorkChannel: make(chan int, 5),
+		DoneChannel: make(chan int, 5),
+		hidden:      make(chan int, 5),
+	}
+	worker.DoneChannel <- 1
+	worker.DoneChannel <- 2
+	worker.hidden <- 1
+	depths := workers.ChannelDepths(worker)
+	assert.Equal(t, map[string]int{"WorkChannel": 0, "DoneChannel": 2}, depths)
+	assert.Empty(t, workers.ChannelDepths("not a struct"))
+}
@@ -11,7 +11,6 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"net/url"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -35,6 +34,9 @@ type APTBucketReader struct {
 	RecentIngestItems map[string]*models.WorkItem
 	stats             *stats.APTBucketReaderStats
 	statsEnabled      bool
+	// pendingBytes is the number of bytes, by institution identifier,
+	// queued for ingest but not yet counted in Institution.BytesUsed.
+	pendingBytes map[string]int64
 }
 
 // Creates a new bucket reader with the given context.
@@ -47,6 +49,7 @@ func NewAPTBucketReader(context *context.Context, enableStats bool) *APTBucketRe
 		Institutions:      make(map[string]*models.Institution),
 		RecentIngestItems: make(map[string]*models.WorkItem),
 		statsEnabled:      enableStats,
+		pendingBytes:      make(map[string]int64),
 	}
 
 	// Patch for https://trello.com/c/Ep4pKzZB
@@ -54,6 +57,15 @@ func NewAPTBucketReader(context *context.Context, enableStats bool) *APTBucketRe
 	if err != nil {
 		panic(fmt.Sprintf("Cannot cache bucket names from Pharos: %v", err))
 	}
+	if err = context.Config.EnsurePriorityDelays(); err != nil {
+		panic(err.Error())
+	}
+	if err = context.Config.EnsureInstitutionOverrides(); err != nil {
+		panic(err.Error())
+	}
+	if err = context.Config.EnsureQuotaExceededPolicy(); err != nil {
+		panic(err.Error())
+	}
 
 	if enableStats {
 		reader.stats = stats.NewAPTBucketReaderStats()
@@ -113,43 +125,29 @@ func (reader *APTBucketReader) cacheRecentIngestItems() error {
 	}
 	createdAfter := time.Now().Add(time.Duration(-1*hours) * time.Hour).UTC()
 	params := url.Values{}
-	params.Add("page", "1")
-	params.Add("per_page", "100") // change to 4 to test hasMoreResults loop
 	params.Add("item_action", constants.ActionIngest)
 	params.Add("created_after", createdAfter.Format(time.RFC3339))
-	hasMoreResults := true
-	for hasMoreResults {
-		resp := reader.Context.PharosClient.WorkItemList(params)
-		if resp.Error != nil {
-			if reader.stats != nil {
-				reader.stats.AddError(resp.Error.Error())
-			}
-			return resp.Error
+	iterator := reader.Context.PharosClient.WorkItemIterator(params, 100)
+	iterator.OnPage(func(resp *network.PharosResponse) {
+		reader.Context.MessageLog.Debug("%s", resp.Request.URL.String())
+	})
+	for iterator.Next() {
+		workItem := iterator.WorkItem()
+		hashKey := reader.makeHashKey(workItem.Name, workItem.ETag)
+		reader.RecentIngestItems[hashKey] = workItem
+		if reader.stats != nil {
+			reader.stats.AddWorkItem("WorkItemsCached", workItem)
 		}
-		if resp.Response.StatusCode != 200 {
+	}
+	if iterator.Err() != nil {
+		resp := iterator.Response()
+		if resp.Error == nil {
 			return reader.processPharosError(resp)
 		}
-		reader.Context.MessageLog.Debug("%s", resp.Request.URL.String())
-		for _, workItem := range resp.WorkItems() {
-			hashKey := reader.makeHashKey(workItem.Name, workItem.ETag)
-			reader.RecentIngestItems[hashKey] = workItem
-			if reader.stats != nil {
-				reader.stats.AddWorkItem("WorkItemsCached", workItem)
-			}
-		}
-		if resp.Next != nil {
-			pageNum, err := strconv.Atoi(params.Get("page"))
-			if err != nil {
-				msg := fmt.Sprintf("Page number '%s' doesn't look like a number!", params.Get("page"))
-				if reader.stats != nil {
-					reader.stats.AddError(msg)
-				}
-				return fmt.Errorf(msg)
-			}
-			params.Set("page", strconv.Itoa(pageNum+1))
-		} else {
-			hasMoreResults = false
+		if reader.stats != nil {
+			reader.stats.AddError(resp.Error.Error())
 		}
+		return resp.Error
 	}
 	reader.Context.MessageLog.Info("Loaded %d recent ingest WorkItems", len(reader.RecentIngestItems))
 	return nil
@@ -176,6 +174,13 @@ func (reader *APTBucketReader) readAllBuckets() {
 		for _, inst := range reader.Institutions {
 			bucketNames = append(bucketNames, inst.ReceivingBucket)
 		}
+		// Plus any receiving buckets that InstitutionOverrides
+		// assigns to an institution.
+		for bucket := range reader.Context.Config.ReceivingBucketOwners() {
+			if !util.StringListContains(bucketNames, bucket) {
+				bucketNames = append(bucketNames, bucket)
+			}
+		}
 	}
 	for _, bucketName := range bucketNames {
 		reader.processBucket(bucketName)
@@ -191,6 +196,7 @@ func (reader *APTBucketReader) processBucket(bucketName string) {
 		bucketName, MAX_KEYS)
 	keepFetching := true
 	for keepFetching {
+		toQueue := make([]*models.WorkItem, 0)
 		s3ObjList.GetList("")
 		if s3ObjList.ErrorMessage != "" {
 			if reader.stats != nil {
@@ -211,8 +217,8 @@ func (reader *APTBucketReader) processBucket(bucketName string) {
 				}
 				continue
 			}
-			// Skip non-tar files
-			if !strings.HasSuffix(*s3Object.Key, ".tar") {
+			// Skip non-tar files. Gzipped tar and zip files are ok.
+			if !util.IsSerializedBag(*s3Object.Key) {
 				msg := fmt.Sprintf("Ignoring non-tar file %s", *s3Object.Key)
 				reader.Context.MessageLog.Info(msg)
 				if reader.stats != nil {
@@ -224,22 +230,30 @@ func (reader *APTBucketReader) processBucket(bucketName string) {
 			if reader.stats != nil {
 				reader.stats.AddS3Item(fmt.Sprintf("%s/%s", bucketName, *s3Object.Key))
 			}
-			reader.processS3Object(s3Object, bucketName)
+			if workItem := reader.processS3Object(s3Object, bucketName); workItem != nil {
+				toQueue = append(toQueue, workItem)
+			}
 		}
+		reader.queueWorkItems(toQueue)
 		keepFetching = *s3ObjList.Response.IsTruncated
 	}
 }
 
-func (reader *APTBucketReader) processS3Object(s3Object *s3.Object, bucketName string) {
-	if reader.Context.Config.MaxFileSize > int64(0) && *s3Object.Size > reader.Context.Config.MaxFileSize {
+// processS3Object returns the WorkItem for s3Object if the bag needs
+// to be queued for ingest, or nil if it doesn't. If the bag has no
+// WorkItem, the WorkItem returned is a new one that hasn't been saved
+// to Pharos yet.
+func (reader *APTBucketReader) processS3Object(s3Object *s3.Object, bucketName string) *models.WorkItem {
+	maxBagSize := reader.Context.Config.ForInstitution(util.OwnerOf(bucketName)).MaxBagSize
+	if maxBagSize > int64(0) && *s3Object.Size > maxBagSize {
 		msg := fmt.Sprintf("Skipping %s/%s because size %d is greater than "+
 			"current max file size %d", bucketName, *s3Object.Key, *s3Object.Size,
-			reader.Context.Config.MaxFileSize)
+			maxBagSize)
 		if reader.stats != nil {
 			reader.stats.AddWarning(msg)
 		}
 		reader.Context.MessageLog.Debug(msg)
-		return
+		return nil
 	}
 	workItem, err := reader.findWorkItem(*s3Object.Key, *s3Object.ETag)
 	if err != nil {
@@ -251,15 +265,12 @@ func (reader *APTBucketReader) processS3Object(s3Object *s3.Object, bucketName s
 		if reader.stats != nil {
 			reader.stats.AddWarning(msg)
 		}
-		return
+		return nil
 	}
 	// A.D. added 2019-09-23: Requeue ingest if prior WorkItem was cancelled.
 	if workItem == nil || workItem.Status == constants.StatusCancelled {
-		workItem = reader.createWorkItem(bucketName, s3Object)
-		if workItem == nil {
-			// Error logged and statted at source.
-			return
-		}
+		// Error, if any, is logged and statted at source.
+		return reader.newWorkItem(bucketName, s3Object)
 	}
 	// Queue the item in NSQ if necessary. This will go into the fetch
 	// queue for ingest, so be sure we don't accidentally pick up any
@@ -267,9 +278,138 @@ func (reader *APTBucketReader) processS3Object(s3Object *s3.Object, bucketName s
 	if (workItem.QueuedAt == nil || workItem.QueuedAt.IsZero()) &&
 		workItem.Action == constants.ActionIngest &&
 		workItem.Stage == constants.StageReceive {
+		return workItem
+	}
+	return nil
+}
+
+// queueWorkItems checks each of workItems against its institution's
+// quota, creates the new ones, then adds the ones that have room in
+// the quota to NSQ, and marks them as queued. It checks quotas before
+// creating new items, so an item that's held or rejected never
+// exists in Pharos in a state apt_queue would queue. It saves the
+// new, held, and queued items to Pharos in batches, rather than one
+// request per item.
+func (reader *APTBucketReader) queueWorkItems(workItems []*models.WorkItem) {
+	newItems := make([]*models.WorkItem, 0)
+	readyItems := make([]*models.WorkItem, 0)
+	heldItems := make([]*models.WorkItem, 0)
+	for _, workItem := range workItems {
+		hasRoom, changed := reader.checkQuota(workItem)
+		if workItem.Id == 0 {
+			newItems = append(newItems, workItem)
+		} else if hasRoom {
+			readyItems = append(readyItems, workItem)
+		} else if changed {
+			heldItems = append(heldItems, workItem)
+		}
+	}
+	reader.saveHeldItems(heldItems)
+	for _, workItem := range reader.createWorkItems(newItems) {
+		if !workItem.IsHeldForQuota() && workItem.Status != constants.StatusFailed {
+			readyItems = append(readyItems, workItem)
+		}
+	}
+	for _, workItem := range readyItems {
 		reader.addToNSQ(workItem)
-		reader.markAsQueued(workItem)
 	}
+	reader.markAsQueued(readyItems)
+}
+
+// checkQuota returns true if the institution that owns workItem has
+// room in its storage quota for the bag. If not, it holds or rejects
+// the WorkItem, according to Config.QuotaExceededPolicy, and says why
+// in the WorkItem's Note. A held item has Retry set to false, so
+// apt_queue won't queue it. Once the institution has room, this
+// releases the hold. The second return value is true if this changed
+// the WorkItem. The caller must save it.
+func (reader *APTBucketReader) checkQuota(workItem *models.WorkItem) (bool, bool) {
+	institution := reader.Institutions[util.OwnerOf(workItem.Bucket)]
+	if institution == nil || !institution.HasQuota() {
+		return true, releaseQuotaHold(workItem)
+	}
+	if workItem.Status == constants.StatusFailed {
+		// We rejected this bag on an earlier run. The depositor
+		// has to upload it again.
+		return false, false
+	}
+	pending, cached := reader.pendingBytes[institution.Identifier]
+	if !cached {
+		pending = reader.bytesAwaitingIngest(institution)
+	}
+	err := institution.CheckQuota(workItem.Size, pending)
+	if err == nil {
+		reader.pendingBytes[institution.Identifier] = pending + workItem.Size
+		return true, releaseQuotaHold(workItem)
+	}
+	reader.pendingBytes[institution.Identifier] = pending
+
+	// The usage and pending bytes in err change from run to run, so
+	// the note starts with a reason that doesn't. That lets us tell
+	// whether we've already held the item.
+	reason := "Held for storage quota."
+	alreadyHeld := workItem.IsHeldForQuota() && strings.HasPrefix(workItem.Note, reason)
+	if reader.Context.Config.QuotaExceededPolicy == constants.QuotaExceededReject {
+		reason = "Rejected for storage quota."
+		workItem.Status = constants.StatusFailed
+		workItem.Outcome = "Storage quota exceeded"
+		alreadyHeld = false
+	} else {
+		workItem.Outcome = constants.QuotaHeldOutcome
+	}
+	workItem.Retry = false
+	note := reason + " " + err.Error()
+	msg := fmt.Sprintf("WorkItem %d (%s/%s): %s", workItem.Id, workItem.Bucket, workItem.Name, note)
+	reader.Context.MessageLog.Warning(msg)
+	if reader.stats != nil {
+		reader.stats.AddWarning(msg)
+	}
+	if alreadyHeld {
+		return false, false
+	}
+	workItem.Note = note
+	return false, true
+}
+
+// saveHeldItems saves the quota notes of existing WorkItems that
+// checkQuota has just held or rejected.
+func (reader *APTBucketReader) saveHeldItems(workItems []*models.WorkItem) {
+	_, errs := SaveWorkItems(reader.Context, workItems)
+	for _, err := range errs {
+		errMsg := fmt.Sprintf("Error saving quota note: %v", err)
+		reader.Context.MessageLog.Error(errMsg)
+		if reader.stats != nil {
+			reader.stats.AddError(errMsg)
+		}
+	}
+}
+
+// releaseQuotaHold clears the hold that checkQuota put on workItem,
+// if it has one, so the item can be queued. It returns true if it
+// changed the item. The caller must save it.
+func releaseQuotaHold(workItem *models.WorkItem) bool {
+	if !workItem.IsHeldForQuota() {
+		return false
+	}
+	workItem.Note = "Institution has room in its storage quota. Bag is queued for ingest."
+	workItem.Outcome = "Item is pending ingest"
+	workItem.Retry = true
+	return true
+}
+
+// bytesAwaitingIngest returns the total size of the institution's
+// recent ingest WorkItems that are queued and not yet finished.
+// Pharos doesn't count these in Institution.BytesUsed yet.
+func (reader *APTBucketReader) bytesAwaitingIngest(institution *models.Institution) int64 {
+	var total int64
+	for _, workItem := range reader.RecentIngestItems {
+		if workItem.InstitutionId == institution.Id &&
+			workItem.QueuedAt != nil && !workItem.QueuedAt.IsZero() &&
+			(workItem.Status == constants.StatusPending || workItem.Status == constants.StatusStarted) {
+			total += workItem.Size
+		}
+	}
+	return total
 }
 
 func (reader *APTBucketReader) findWorkItem(key, etag string) (*models.WorkItem, error) {
@@ -314,8 +454,9 @@ func (reader *APTBucketReader) findWorkItem(key, etag string) (*models.WorkItem,
 	return workItem, nil
 }
 
-func (reader *APTBucketReader) createWorkItem(bucket string, s3Object *s3.Object) *models.WorkItem {
-	// Create a WorkItem in Pharos
+// newWorkItem returns a new ingest WorkItem for s3Object, which has
+// not yet been saved to Pharos.
+func (reader *APTBucketReader) newWorkItem(bucket string, s3Object *s3.Object) *models.WorkItem {
 	institution := reader.Institutions[util.OwnerOf(bucket)]
 	if institution == nil {
 		errMsg := fmt.Sprintf("Cannot find institution record for item %s/%s. "+
@@ -342,35 +483,36 @@ func (reader *APTBucketReader) createWorkItem(bucket string, s3Object *s3.Object
 	workItem.Status = constants.StatusPending
 	workItem.Outcome = "Item is pending ingest"
 	workItem.Retry = true
-	resp := reader.Context.PharosClient.WorkItemSave(workItem)
+	if limit := reader.Context.Config.LowPriorityIngestSize; limit > 0 && workItem.Size > limit {
+		workItem.Priority = constants.PriorityLow
+	}
+	return workItem
+}
 
-	if resp.Error != nil {
-		errMsg := fmt.Sprintf("Error creating WorkItem for name '%s', etag '%s', time '%s': %v",
-			workItem.Name, workItem.ETag, workItem.BagDate, resp.Error)
-		reader.Context.MessageLog.Debug("%s", resp.Request.URL.String())
+// createWorkItems saves workItems, which are all new, to Pharos,
+// and returns the saved copies, which have ids.
+func (reader *APTBucketReader) createWorkItems(workItems []*models.WorkItem) []*models.WorkItem {
+	saved, errs := SaveWorkItems(reader.Context, workItems)
+	for _, err := range errs {
+		errMsg := fmt.Sprintf("Error creating WorkItem: %v", err)
 		reader.Context.MessageLog.Error(errMsg)
 		if reader.stats != nil {
 			reader.stats.AddError(errMsg)
 		}
-		return nil
 	}
-	if resp.Response.StatusCode != 201 {
-		reader.processPharosError(resp)
-		return nil
-	}
-
-	savedWorkItem := resp.WorkItem()
-	reader.Context.MessageLog.Debug("Created WorkItem with id %d for %s/%s in Pharos",
-		savedWorkItem.Id, bucket, *s3Object.Key)
-	if reader.stats != nil {
-		reader.stats.AddWorkItem("WorkItemsCreated", savedWorkItem)
+	for _, savedWorkItem := range saved {
+		reader.Context.MessageLog.Debug("Created WorkItem with id %d for %s/%s in Pharos",
+			savedWorkItem.Id, savedWorkItem.Bucket, savedWorkItem.Name)
+		if reader.stats != nil {
+			reader.stats.AddWorkItem("WorkItemsCreated", savedWorkItem)
+		}
 	}
-	return savedWorkItem
+	return saved
 }
 
 func (reader *APTBucketReader) addToNSQ(workItem *models.WorkItem) {
 	client := network.NewNSQClient(reader.Context.Config.NsqdHttpAddress)
-	err := client.Enqueue(reader.Context.Config.FetchWorker.NsqTopic, workItem.Id)
+	delay, err := EnqueueByPriority(reader.Context, client, reader.Context.Config.FetchWorker.NsqTopic, workItem)
 	if err != nil {
 		msg := fmt.Sprintf("Error sending WorkItem %d to NSQ: %v", workItem.Id, err)
 		if reader.stats != nil {
@@ -379,36 +521,34 @@ func (reader *APTBucketReader) addToNSQ(workItem *models.WorkItem) {
 		reader.Context.MessageLog.Error(msg)
 		return
 	}
-	reader.Context.MessageLog.Info("Added WorkItem id %d to NSQ (%s/%s)",
-		workItem.Id, workItem.Bucket, workItem.Name)
+	reader.Context.MessageLog.Info("Added WorkItem id %d to NSQ (%s/%s) with %s priority (delay %s)",
+		workItem.Id, workItem.Bucket, workItem.Name, workItem.EffectivePriority(), delay)
 	if reader.stats != nil {
 		reader.stats.AddWorkItem("WorkItemsQueued", workItem)
 	}
 	return
 }
 
-func (reader *APTBucketReader) markAsQueued(workItem *models.WorkItem) *models.WorkItem {
+// markAsQueued sets QueuedAt on the WorkItems we just added to NSQ,
+// and saves them to Pharos in batches.
+func (reader *APTBucketReader) markAsQueued(workItems []*models.WorkItem) {
 	utcNow := time.Now().UTC()
-	workItem.QueuedAt = &utcNow
-	resp := reader.Context.PharosClient.WorkItemSave(workItem)
-	if resp.Error != nil {
-		errMsg := fmt.Sprintf("Error setting QueuedAt for WorkItem with id %d: %v",
-			workItem.Id, resp.Error)
-		reader.Context.MessageLog.Debug("%s", resp.Request.URL.String())
+	for _, workItem := range workItems {
+		workItem.QueuedAt = &utcNow
+	}
+	saved, errs := SaveWorkItems(reader.Context, workItems)
+	for _, err := range errs {
+		errMsg := fmt.Sprintf("Error setting QueuedAt: %v", err)
 		reader.Context.MessageLog.Error(errMsg)
 		if reader.stats != nil {
 			reader.stats.AddError(errMsg)
 		}
-		return nil
-	}
-	if resp.Response.StatusCode != 200 {
-		reader.processPharosError(resp)
-		return nil
 	}
 	if reader.stats != nil {
-		reader.stats.AddWorkItem("WorkItemsMarkedAsQueued", workItem)
+		for _, workItem := range saved {
+			reader.stats.AddWorkItem("WorkItemsMarkedAsQueued", workItem)
+		}
 	}
-	return resp.WorkItem()
 }
 
 func (reader *APTBucketReader) processPharosError(resp *network.PharosResponse) error {
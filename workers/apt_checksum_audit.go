@@ -0,0 +1,67 @@
+package workers
+
+import (
+	"errors"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+)
+
+// APTChecksumAudit compares the checksums Pharos has on record for
+// GenericFiles with the md5 and sha256 digests stored in the metadata
+// of each file's object in long-term storage, and with the object's
+// ETag where that's a plain md5 digest. It issues only HEAD requests,
+// so it never downloads content. It prints one record for each
+// divergent file to STDOUT (or one for every file, if showAll is
+// true), and errors to STDERR.
+type APTChecksumAudit struct {
+	auditor *fileAuditor
+}
+
+// NewAPTChecksumAudit returns a new APTChecksumAudit.
+// Param context is a context.Context object.
+// Param institution limits the audit to files belonging to the
+// institution with that identifier. Leave it empty to audit files
+// from all institutions. Param identifierLike limits the audit to
+// files whose identifiers contain that string. Param format can be
+// "json", "csv" (comma-separated values) or "tsv" (tab-separated
+// values). Param limit is the maximum number of files to audit.
+// Set limit to zero to audit all files. Param sampleRate is the
+// fraction of files to audit, from greater than zero to 1.0. A
+// sampleRate of 0.05 audits about one in twenty files; 1.0 audits
+// them all. Param concurrency is the number of HEAD requests to
+// issue simultaneously. It defaults to 4. Max is 32.
+func NewAPTChecksumAudit(context *context.Context, institution, identifierLike, format string, limit int, sampleRate float64, concurrency int, showAll bool) (*APTChecksumAudit, error) {
+	auditor, err := newFileAuditor(context, institution, identifierLike,
+		format, limit, sampleRate, concurrency, showAll)
+	if err != nil {
+		return nil, err
+	}
+	return &APTChecksumAudit{auditor: auditor}, nil
+}
+
+// Run audits the files and returns the number of files checked,
+// the number of files whose records diverge, and an error if
+// it could not complete the audit. Check the STDERR log for
+// details if Run returns an error.
+func (audit *APTChecksumAudit) Run() (int, int, error) {
+	return audit.auditor.run(audit.auditOne)
+}
+
+// auditOne compares Pharos's checksums for a single file with the
+// metadata on the stored object.
+func (audit *APTChecksumAudit) auditOne(gf *models.GenericFile) (fileAuditResult, error) {
+	bucket, key, err := gf.StorageBucketAndKey()
+	if err != nil {
+		return nil, err
+	}
+	client, err := audit.auditor.headStoredFile(gf, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if client.ErrorMessage != "" {
+		return nil, errors.New(client.ErrorMessage)
+	}
+	result := models.NewChecksumAudit(gf)
+	result.Compare(client.StoredFile())
+	return result, nil
+}
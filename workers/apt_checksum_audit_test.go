@@ -0,0 +1,29 @@
+package workers_test
+
+import (
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/APTrust/exchange/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewAPTChecksumAudit(t *testing.T) {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+
+	audit, err := workers.NewAPTChecksumAudit(_context, "test.edu", "", "tsv", 100, 0.5, 4, false)
+	assert.Nil(t, err)
+	assert.NotNil(t, audit)
+
+	_, err = workers.NewAPTChecksumAudit(nil, "", "", "tsv", 100, 1.0, 4, false)
+	assert.NotNil(t, err)
+	_, err = workers.NewAPTChecksumAudit(_context, "", "", "xml", 100, 1.0, 4, false)
+	assert.NotNil(t, err)
+	_, err = workers.NewAPTChecksumAudit(_context, "", "", "json", 100, 0, 4, false)
+	assert.NotNil(t, err)
+	_, err = workers.NewAPTChecksumAudit(_context, "", "", "json", 100, 1.5, 4, false)
+	assert.NotNil(t, err)
+	_, err = workers.NewAPTChecksumAudit(_context, "", "", "csv", 100, 1.0, 33, false)
+	assert.NotNil(t, err)
+}
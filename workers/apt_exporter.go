@@ -0,0 +1,517 @@
+package workers
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"github.com/APTrust/exchange/util/fileutil"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EXPORT_INVENTORY_FILE is the name of the tag file in which
+// apt_export records the ExportInventory.
+const EXPORT_INVENTORY_FILE = "aptrust-export-inventory.json"
+
+// APTExporter builds an exit package of all of an institution's
+// active objects. It works in two passes, so that it can use
+// apt_restorer to rebuild and validate each bag:
+//
+// RequestRestores creates a Restore WorkItem for each active object
+// that has not been restored since RestoredSince.
+//
+// Assemble, which you run once those restorations have finished,
+// copies each restored bag from the restoration bucket to Target,
+// checks it against its restore receipt, and writes the tag files
+// and manifests that make Target a BagIt bag whose payload is the
+// restored bags. You can run Assemble again after fixing problems.
+// It does not copy bags that are already in a local Target.
+type APTExporter struct {
+	Context     *context.Context
+	Institution string
+	// Target is where Assemble writes the package. This is either
+	// "s3://bucket/optional/prefix" or the path to a local directory,
+	// such as an attached volume.
+	Target string
+	// RestoredSince is the earliest restoration Assemble will use.
+	// Set this to the time you ran RequestRestores.
+	RestoredSince time.Time
+	DryRun        bool
+	// Inventory describes the most recent run of Assemble.
+	Inventory *models.ExportInventory
+	target    exportTarget
+}
+
+// NewAPTExporter returns a new APTExporter for the institution with
+// the specified identifier. It returns an error if target is not
+// valid, or if the institution receives restored bags through Globus,
+// since those bags never reach a restoration bucket.
+func NewAPTExporter(_context *context.Context, institution, target string, restoredSince time.Time) (*APTExporter, error) {
+	if _context == nil {
+		return nil, fmt.Errorf("Param _context cannot be nil")
+	}
+	if institution == "" {
+		return nil, fmt.Errorf("Param institution cannot be empty")
+	}
+	if _, hasGlobus := _context.Config.GlobusDestinations[institution]; hasGlobus {
+		return nil, fmt.Errorf("Restorations for %s go to Globus, not to a restoration bucket. "+
+			"Remove its GlobusDestination before exporting.", institution)
+	}
+	exportTarget, err := newExportTarget(_context, target)
+	if err != nil {
+		return nil, err
+	}
+	return &APTExporter{
+		Context:       _context,
+		Institution:   institution,
+		Target:        target,
+		RestoredSince: restoredSince,
+		target:        exportTarget,
+	}, nil
+}
+
+// RequestRestores creates a Restore WorkItem for each of the
+// institution's active objects, unless the object already has a
+// restoration since RestoredSince that has not failed. It returns the
+// WorkItems it created. If DryRun is true, it returns the WorkItems
+// it would have created, without saving them.
+func (exporter *APTExporter) RequestRestores() ([]*models.WorkItem, error) {
+	workItems := make([]*models.WorkItem, 0)
+	err := exporter.eachObject(func(obj *models.IntellectualObject) error {
+		lastRestore, err := exporter.lastRestoreWorkItem(obj.Identifier, "")
+		if err != nil {
+			return err
+		}
+		if lastRestore != nil && lastRestore.Status != constants.StatusFailed &&
+			lastRestore.Status != constants.StatusCancelled {
+			exporter.Context.MessageLog.Info("Skipping %s, which has restore WorkItem %d (%s)",
+				obj.Identifier, lastRestore.Id, lastRestore.Status)
+			return nil
+		}
+		workItem, err := exporter.createRestoreWorkItem(obj)
+		if err != nil {
+			return err
+		}
+		workItems = append(workItems, workItem)
+		return nil
+	})
+	return workItems, err
+}
+
+// Assemble copies the restored bags into the package, and writes the
+// inventory. If every object was exported, it also writes the outer
+// bag's bagit.txt, bag-info.txt and manifests. It returns an error if
+// any object could not be exported. See Inventory for details.
+func (exporter *APTExporter) Assemble() (*models.ExportInventory, error) {
+	exporter.Inventory = models.NewExportInventory(exporter.Institution,
+		exporter.Target, exporter.RestoredSince)
+	err := exporter.eachObject(func(obj *models.IntellectualObject) error {
+		bag := exporter.exportBag(obj)
+		if bag.Error != "" {
+			exporter.Context.MessageLog.Error("Cannot export %s: %s", obj.Identifier, bag.Error)
+		}
+		exporter.Inventory.Add(bag)
+		return nil
+	})
+	if err != nil {
+		return exporter.Inventory, err
+	}
+	exporter.Inventory.FinishedAt = time.Now().UTC()
+	if exporter.DryRun {
+		return exporter.Inventory, nil
+	}
+	if err = exporter.writeTagFiles(); err != nil {
+		return exporter.Inventory, err
+	}
+	if !exporter.Inventory.Complete() {
+		return exporter.Inventory, fmt.Errorf("%d of %d objects could not be exported. "+
+			"See %s for details.", len(exporter.Inventory.Failed()),
+			len(exporter.Inventory.Bags), EXPORT_INVENTORY_FILE)
+	}
+	return exporter.Inventory, nil
+}
+
+// eachObject calls fn for each of the institution's active objects,
+// stopping at the first error.
+func (exporter *APTExporter) eachObject(fn func(obj *models.IntellectualObject) error) error {
+	params := url.Values{}
+	params.Set("state", "A")
+	params.Set("page", "1")
+	params.Set("per_page", "100")
+	for {
+		// IntellectualObjectList moves institution from the query
+		// into the URL path, so the next page's params don't have
+		// it. Without it, we'd export everyone's objects.
+		params.Set("institution", exporter.Institution)
+		resp := exporter.Context.PharosClient.IntellectualObjectList(params)
+		if resp.Error != nil {
+			return fmt.Errorf("Error getting objects for %s from Pharos: %v",
+				exporter.Institution, resp.Error)
+		}
+		for _, obj := range resp.IntellectualObjects() {
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+		if resp.HasNextPage() == false {
+			break
+		}
+		params = resp.ParamsForNextPage()
+	}
+	return nil
+}
+
+// lastRestoreWorkItem returns the most recent object-level Restore
+// WorkItem for the object created since RestoredSince, or nil if there
+// is none. If status is not empty, it returns only items with that
+// status.
+func (exporter *APTExporter) lastRestoreWorkItem(objIdentifier, status string) (*models.WorkItem, error) {
+	params := url.Values{}
+	params.Set("object_identifier", objIdentifier)
+	params.Set("item_action", constants.ActionRestore)
+	params.Set("created_after", exporter.RestoredSince.Format(time.RFC3339))
+	params.Set("sort", "date") // Sorts by date_processed desc
+	if status != "" {
+		params.Set("status", status)
+	}
+	params.Set("page", "1")
+	params.Set("per_page", "10")
+	resp := exporter.Context.PharosClient.WorkItemList(params)
+	if resp.Error != nil {
+		return nil, fmt.Errorf("Error getting restore WorkItems for %s from Pharos: %v",
+			objIdentifier, resp.Error)
+	}
+	// File restorations use the same action, but don't give us a bag.
+	for _, item := range resp.WorkItems() {
+		if item.GenericFileIdentifier == "" {
+			return item, nil
+		}
+	}
+	return nil, nil
+}
+
+// createRestoreWorkItem creates a Restore WorkItem for obj, or a
+// GlacierRestore WorkItem if obj is not in standard storage.
+func (exporter *APTExporter) createRestoreWorkItem(obj *models.IntellectualObject) (*models.WorkItem, error) {
+	params := url.Values{}
+	params.Set("object_identifier", obj.Identifier)
+	params.Set("item_action", constants.ActionIngest)
+	params.Set("status", constants.StatusSuccess)
+	params.Set("sort", "date") // Sorts by date_processed desc
+	params.Set("page", "1")
+	params.Set("per_page", "1")
+	resp := exporter.Context.PharosClient.WorkItemList(params)
+	if resp.Error != nil {
+		return nil, fmt.Errorf("Cannot find last ingest WorkItem for %s: %v", obj.Identifier, resp.Error)
+	}
+	lastIngestItem := resp.WorkItem()
+	if lastIngestItem == nil {
+		return nil, fmt.Errorf("Last ingest WorkItem is missing for %s", obj.Identifier)
+	}
+	action := constants.ActionRestore
+	if obj.StorageOption != "" && obj.StorageOption != constants.StorageStandard {
+		action = constants.ActionGlacierRestore
+	}
+	workItem := &models.WorkItem{
+		ObjectIdentifier: obj.Identifier,
+		Name:             lastIngestItem.Name,
+		Bucket:           lastIngestItem.Bucket,
+		ETag:             lastIngestItem.ETag,
+		Size:             obj.FileSize,
+		BagDate:          lastIngestItem.BagDate,
+		Date:             time.Now().UTC(),
+		InstitutionId:    lastIngestItem.InstitutionId,
+		User:             constants.APTrustSystemUser,
+		Action:           action,
+		Stage:            constants.StageRequested,
+		Status:           constants.StatusPending,
+		Outcome:          "Pending",
+		Retry:            true,
+		Note:             "Restoration for institutional exit package requested by system",
+		Priority:         constants.PriorityLow,
+	}
+	if exporter.DryRun {
+		return workItem, nil
+	}
+	exporter.Context.MessageLog.Info("Creating %s WorkItem for %s", action, obj.Identifier)
+	resp = exporter.Context.PharosClient.WorkItemSave(workItem)
+	if resp.Error != nil {
+		return nil, fmt.Errorf("Cannot create %s WorkItem for %s: %v", action, obj.Identifier, resp.Error)
+	}
+	return resp.WorkItem(), nil
+}
+
+// exportBag copies obj's most recent restored bag into the package,
+// and returns a description of what it copied. On failure, the
+// returned bag's Error says why.
+func (exporter *APTExporter) exportBag(obj *models.IntellectualObject) *models.ExportedBag {
+	receipt, err := exporter.restoreReceipt(obj)
+	bag := models.NewExportedBag(obj, receipt)
+	if err != nil {
+		bag.Error = err.Error()
+		return bag
+	}
+	if exporter.DryRun {
+		return bag
+	}
+	localPath := exporter.target.LocalPath(bag.Path)
+	if exporter.target.IsLocal() && fileutil.FileExists(localPath) {
+		digest, err := fileutil.CalculateChecksum(localPath, constants.AlgSha256)
+		if err == nil && digest == bag.Sha256 {
+			exporter.Context.MessageLog.Info("%s is already in the package", bag.Path)
+			bag.ExportedAt = time.Now().UTC()
+			return bag
+		}
+	}
+	if err = os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		bag.Error = err.Error()
+		return bag
+	}
+	exporter.Context.MessageLog.Info("Copying %s/%s to %s", receipt.Bucket, receipt.Key, localPath)
+	download := network.NewS3Download(
+		os.Getenv("AWS_ACCESS_KEY_ID"),
+		os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		constants.AWSVirginia,
+		receipt.Bucket,
+		receipt.Key,
+		localPath,
+		false,
+		true)
+	download.SessionPool = exporter.Context.S3SessionPool
+	download.Fetch()
+	if download.ErrorMessage != "" {
+		bag.Error = fmt.Sprintf("Error downloading %s/%s: %s", receipt.Bucket, receipt.Key, download.ErrorMessage)
+	} else if download.BytesCopied != receipt.Size || download.Sha256Digest != receipt.Sha256 {
+		bag.Error = fmt.Sprintf("Restored bag %s/%s does not match its receipt: "+
+			"got %d bytes with sha256 %s, expected %d bytes with sha256 %s",
+			receipt.Bucket, receipt.Key, download.BytesCopied, download.Sha256Digest,
+			receipt.Size, receipt.Sha256)
+	} else if err = exporter.target.Store(bag.Path); err != nil {
+		bag.Error = err.Error()
+	}
+	if bag.Error != "" {
+		os.Remove(localPath)
+		return bag
+	}
+	bag.ExportedAt = time.Now().UTC()
+	return bag
+}
+
+// restoreReceipt returns the receipt for obj's most recent successful
+// restoration since RestoredSince. If the restorer signs receipts,
+// the signature must be valid.
+func (exporter *APTExporter) restoreReceipt(obj *models.IntellectualObject) (*models.RestoreReceipt, error) {
+	workItem, err := exporter.lastRestoreWorkItem(obj.Identifier, constants.StatusSuccess)
+	if err != nil {
+		return nil, err
+	}
+	if workItem == nil {
+		return nil, fmt.Errorf("No successful restoration since %s",
+			exporter.RestoredSince.Format(time.RFC3339))
+	}
+	if workItem.WorkItemStateId == nil {
+		return nil, fmt.Errorf("Restore WorkItem %d has no saved state", workItem.Id)
+	}
+	resp := exporter.Context.StateStore().Get(*workItem.WorkItemStateId)
+	if resp.Error != nil {
+		return nil, fmt.Errorf("Cannot get state of restore WorkItem %d: %v", workItem.Id, resp.Error)
+	}
+	restoreState := &models.RestoreState{}
+	err = json.Unmarshal([]byte(resp.WorkItemState().State), restoreState)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse state of restore WorkItem %d: %v", workItem.Id, err)
+	}
+	receipt := restoreState.Receipt
+	if receipt == nil {
+		return nil, fmt.Errorf("Restore WorkItem %d has no receipt", workItem.Id)
+	}
+	signingKey := os.Getenv(RestoreReceiptKeyEnvVar)
+	if signingKey != "" && !receipt.HasValidSignature([]byte(signingKey)) {
+		return nil, fmt.Errorf("Receipt for restore WorkItem %d does not have a valid signature", workItem.Id)
+	}
+	return receipt, nil
+}
+
+// writeTagFiles writes the inventory into the package. If the package
+// is complete, it also writes the files that make it a valid bag.
+func (exporter *APTExporter) writeTagFiles() error {
+	inventory := exporter.Inventory
+	inventoryJson, err := inventory.ToJson()
+	if err != nil {
+		return err
+	}
+	tagFiles := make([]string, 0)
+	tagData := make(map[string][]byte)
+	addTagFile := func(name, content string) {
+		tagFiles = append(tagFiles, name)
+		tagData[name] = []byte(content)
+	}
+	addTagFile(EXPORT_INVENTORY_FILE, inventoryJson)
+	if inventory.Complete() {
+		addTagFile("bagit.txt", fmt.Sprintf("BagIt-Version: %s\nTag-File-Character-Encoding: %s\n",
+			exporter.Context.Config.BagItVersion, exporter.Context.Config.BagItEncoding))
+		bagInfo := &bytes.Buffer{}
+		fmt.Fprintln(bagInfo, "Source-Organization:", inventory.Institution)
+		fmt.Fprintln(bagInfo, "Bagging-Date:", inventory.FinishedAt.Format(time.RFC3339))
+		fmt.Fprintln(bagInfo, "Bag-Count:", "1 of 1")
+		fmt.Fprintln(bagInfo, "External-Description:", fmt.Sprintf(
+			"All active APTrust holdings of %s, each restored as a tarred bag", inventory.Institution))
+		fmt.Fprintln(bagInfo, "Payload-Oxum:", inventory.PayloadOxum())
+		addTagFile("bag-info.txt", bagInfo.String())
+		for _, algorithm := range []string{constants.AlgMd5, constants.AlgSha256} {
+			manifest, err := inventory.Manifest(algorithm)
+			if err != nil {
+				return err
+			}
+			addTagFile(fmt.Sprintf("manifest-%s.txt", algorithm), manifest)
+		}
+		for _, algorithm := range []string{constants.AlgMd5, constants.AlgSha256} {
+			tagManifest := &bytes.Buffer{}
+			for _, name := range tagFiles {
+				if strings.HasPrefix(name, "tagmanifest-") {
+					continue
+				}
+				fmt.Fprintf(tagManifest, "%s  %s\n", digestOf(algorithm, tagData[name]), name)
+			}
+			addTagFile(fmt.Sprintf("tagmanifest-%s.txt", algorithm), tagManifest.String())
+		}
+	}
+	for _, name := range tagFiles {
+		localPath := exporter.target.LocalPath(name)
+		if err = os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(localPath, tagData[name], 0644); err != nil {
+			return fmt.Errorf("Cannot write %s: %v", localPath, err)
+		}
+		if err = exporter.target.Store(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// digestOf returns the hex-encoded md5 or sha256 digest of data.
+func digestOf(algorithm string, data []byte) string {
+	if algorithm == constants.AlgMd5 {
+		sum := md5.Sum(data)
+		return hex.EncodeToString(sum[:])
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// exportTarget is where APTExporter writes the package. The exporter
+// writes each file to LocalPath, then calls Store to put it in the
+// target.
+type exportTarget interface {
+	// IsLocal returns true if files written to LocalPath are
+	// already in the target.
+	IsLocal() bool
+	// LocalPath returns the local path for the file at relPath
+	// within the package.
+	LocalPath(relPath string) string
+	// Store copies the file at LocalPath(relPath) into the target.
+	Store(relPath string) error
+}
+
+// newExportTarget returns the exportTarget described by target, which
+// is either "s3://bucket/optional/prefix" or an absolute path.
+func newExportTarget(_context *context.Context, target string) (exportTarget, error) {
+	if strings.HasPrefix(target, "s3://") {
+		parts := strings.SplitN(strings.TrimPrefix(target, "s3://"), "/", 2)
+		if parts[0] == "" {
+			return nil, fmt.Errorf("Target '%s' has no bucket name", target)
+		}
+		prefix := ""
+		if len(parts) == 2 {
+			prefix = strings.Trim(parts[1], "/")
+		}
+		return &s3ExportTarget{
+			context:    _context,
+			bucket:     parts[0],
+			prefix:     prefix,
+			stagingDir: filepath.Join(_context.Config.RestoreDirectory, "export", parts[0]),
+		}, nil
+	}
+	if !filepath.IsAbs(target) {
+		return nil, fmt.Errorf("Target '%s' must be an s3:// URL or an absolute path", target)
+	}
+	return &localExportTarget{dir: target}, nil
+}
+
+// localExportTarget writes the package to a local directory.
+type localExportTarget struct {
+	dir string
+}
+
+func (target *localExportTarget) IsLocal() bool {
+	return true
+}
+
+func (target *localExportTarget) LocalPath(relPath string) string {
+	return filepath.Join(target.dir, relPath)
+}
+
+func (target *localExportTarget) Store(relPath string) error {
+	return nil
+}
+
+// s3ExportTarget writes the package to an S3 bucket. It stages each
+// file in a local directory, and deletes the local copy after upload.
+type s3ExportTarget struct {
+	context    *context.Context
+	bucket     string
+	prefix     string
+	stagingDir string
+}
+
+func (target *s3ExportTarget) IsLocal() bool {
+	return false
+}
+
+func (target *s3ExportTarget) LocalPath(relPath string) string {
+	return filepath.Join(target.stagingDir, target.prefix, relPath)
+}
+
+func (target *s3ExportTarget) Store(relPath string) error {
+	localPath := target.LocalPath(relPath)
+	defer os.Remove(localPath)
+	key := relPath
+	if target.prefix != "" {
+		key = target.prefix + "/" + relPath
+	}
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	upload := network.NewS3Upload(
+		os.Getenv("AWS_ACCESS_KEY_ID"),
+		os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		constants.AWSVirginia,
+		target.bucket,
+		key,
+		"application/octet-stream")
+	upload.SessionPool = target.context.S3SessionPool
+	upload.SendWithSize(file, fileInfo.Size())
+	if upload.ErrorMessage != "" {
+		return fmt.Errorf("Error uploading %s to %s/%s: %s", localPath, target.bucket, key, upload.ErrorMessage)
+	}
+	return nil
+}
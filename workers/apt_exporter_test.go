@@ -0,0 +1,43 @@
+package workers_test
+
+import (
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/APTrust/exchange/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNewAPTExporter(t *testing.T) {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+	since := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	exporter, err := workers.NewAPTExporter(_context, "test.edu", "s3://aptrust.export/test.edu/", since)
+	require.Nil(t, err)
+	require.NotNil(t, exporter)
+	assert.Equal(t, "test.edu", exporter.Institution)
+	assert.Equal(t, since, exporter.RestoredSince)
+
+	_, err = workers.NewAPTExporter(_context, "test.edu", "/mnt/export", since)
+	assert.Nil(t, err)
+
+	_, err = workers.NewAPTExporter(nil, "test.edu", "/mnt/export", since)
+	assert.NotNil(t, err)
+	_, err = workers.NewAPTExporter(_context, "", "/mnt/export", since)
+	assert.NotNil(t, err)
+	_, err = workers.NewAPTExporter(_context, "test.edu", "relative/path", since)
+	assert.NotNil(t, err)
+	_, err = workers.NewAPTExporter(_context, "test.edu", "s3://", since)
+	assert.NotNil(t, err)
+
+	if _context.Config.GlobusDestinations == nil {
+		_context.Config.GlobusDestinations = make(map[string]*models.GlobusDestination)
+	}
+	_context.Config.GlobusDestinations["globus.edu"] = &models.GlobusDestination{}
+	defer delete(_context.Config.GlobusDestinations, "globus.edu")
+	_, err = workers.NewAPTExporter(_context, "globus.edu", "/mnt/export", since)
+	assert.NotNil(t, err)
+}
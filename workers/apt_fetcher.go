@@ -37,10 +37,15 @@ func NewAPTFetcher(_context *context.Context) *APTFetcher {
 	if err != nil {
 		panic(fmt.Sprintf("Cannot cache bucket names from Pharos: %v", err))
 	}
+	if err = _context.Config.EnsureInstitutionOverrides(); err != nil {
+		panic(fmt.Sprintf("Invalid institution overrides: %v", err))
+	}
 
 	// Load the config settings that describe how to validate
 	// APTrust bags. We'll exit here if the config can't be
-	// loaded or is invalid.
+	// loaded or is invalid, including when any institution's
+	// InstitutionChecks can't be built, so a bad config never
+	// gets as far as rejecting a depositor's bag.
 	fetcher.BagValidationConfig = LoadAPTrustBagValidationConfig(_context)
 
 	// Set up buffered channels
@@ -90,6 +95,12 @@ func (fetcher *APTFetcher) HandleMessage(message *nsq.Message) error {
 		return nil
 	}
 
+	// Make sure the WorkItem and receiving bucket belong to the
+	// same institution before we download anything.
+	if !AssertIngestInstitution(ingestState, fetcher.Context, ingestState.IngestManifest.FetchResult) {
+		return nil
+	}
+
 	// If we're still ingesting an older version of this bag,
 	// requeue this request with a delay of several hours.
 	// See https://trello.com/c/GLURkoKW
@@ -226,15 +237,39 @@ func (fetcher *APTFetcher) validate() {
 			ingestState.IngestManifest.BagPath,
 			fetcher.BagValidationConfig,
 			true) // true means preserve ingest attributes in db
+		institution := InstitutionOf(ingestState.WorkItem)
 		if err != nil {
 			// Could not create a BagValidator. Should this be fatal?
 			ingestState.IngestManifest.ValidateResult.AddError(err.Error())
+		} else if err = validator.AddInstitutionChecks(institution); err != nil {
+			// NewAPTFetcher checks the InstitutionChecks when it loads
+			// the config, so this shouldn't happen. If it does, the
+			// problem is our config, not the bag, so we don't validate
+			// the bag, and we try again later.
+			fetcher.Context.MessageLog.Error("Cannot set up validation checks for %s: %v",
+				institution, err)
+			ingestState.IngestManifest.FetchResult.AddError(
+				"Cannot set up the validation checks configured for %s: %v. "+
+					"This is a problem with the worker's config, not with the bag.",
+				institution, err)
+			ingestState.IngestManifest.FetchResult.ErrorIsFatal = false
+			ingestState.IngestManifest.FetchResult.Retry = true
 		} else {
 
 			// New hack to get more granular info about the validator
 			// is doing with very large bags. This need to be worked in
 			// to the validator constructor when we refactor.
 			validator.Logger = fetcher.Context.MessageLog
+			validator.Progress = ValidationProgressLogger(fetcher.Context,
+				ingestState.IngestManifest.BagPath, ingestState.TouchNSQ, time.Minute)
+			if fetcher.Context.Config.SiegfriedURL != "" {
+				validator.AddFileScanner(validation.NewFormatScanner(
+					network.NewSiegfriedClient(fetcher.Context.Config.SiegfriedURL)))
+			}
+			if override := fetcher.Context.Config.InstitutionOverrides[institution]; override != nil &&
+				len(override.AllowedStorageOptions) > 0 {
+				validator.AddCheck(validation.NewStorageOptionCheck(override.AllowedStorageOptions...))
+			}
 
 			// Here's where bag validation actually happens. There's a lot
 			// going on in this call, which can take anywhere from 2 seconds
@@ -259,7 +294,6 @@ func (fetcher *APTFetcher) validate() {
 				summary.AddError(err.Error())
 				summary.FinishedAt = time.Now().UTC()
 			}
-
 			// If the bag is invalid, that's a fatal error. We should not do
 			// any further processing on it.
 			if summary.HasErrors() {
@@ -431,7 +465,7 @@ func (fetcher *APTFetcher) downloadFile(ingestState *models.IngestState) (*model
 }
 
 func (fetcher *APTFetcher) getDownloader(ingestState *models.IngestState) *network.S3Download {
-	return network.NewS3Download(
+	downloader := network.NewS3Download(
 		os.Getenv("AWS_ACCESS_KEY_ID"),
 		os.Getenv("AWS_SECRET_ACCESS_KEY"),
 		constants.AWSVirginia,
@@ -441,6 +475,8 @@ func (fetcher *APTFetcher) getDownloader(ingestState *models.IngestState) *netwo
 		true,  // calculate md5 checksum on the entire tar file
 		false, // calculate sha256 checksum on the entire tar file
 	)
+	downloader.SessionPool = fetcher.Context.S3SessionPool
+	return downloader
 }
 
 func (fetcher *APTFetcher) tryDownload(downloader *network.S3Download, ingestState *models.IngestState, attemptNumber int) (bool, bool) {
@@ -109,6 +109,19 @@ func (deleter *APTFileDeleter) delete() {
 		deleteState.DeleteSummary.AttemptNumber += 1
 		deleteState.DeleteSummary.Start()
 
+		// Make sure the WorkItem and file belong to the same
+		// institution before we delete anything.
+		err := CheckInstitutionConsistency(deleteState.WorkItem,
+			deleteState.WorkItem.Bucket, deleteState.GenericFile.Identifier,
+			deleteState.GenericFile.IntellectualObjectIdentifier)
+		if err != nil {
+			deleteState.DeleteSummary.AddError(err.Error())
+			deleteState.DeleteSummary.ErrorIsFatal = true
+			deleteState.DeleteSummary.Finish()
+			deleter.PostProcessChannel <- deleteState
+			continue
+		}
+
 		fileUUID, err := deleteState.GenericFile.PreservationStorageFileName()
 		if err != nil {
 			deleteState.DeleteSummary.AddError(err.Error())
@@ -270,14 +283,16 @@ func (deleter *APTFileDeleter) finishWithError(deleteState *models.DeleteState)
 		deleteState.WorkItem.Status = constants.StatusFailed
 		deleteState.WorkItem.Retry = false
 		deleteState.WorkItem.NeedsAdminReview = true
+	} else {
+		// Non-fatal error gets a retry.
+		deleteState.WorkItem.Status = constants.StatusPending
+		deleteState.WorkItem.Stage = constants.StageRequested
 	}
 	deleteState.WorkItem.Date = time.Now().UTC()
 	deleteState.WorkItem.Note = note
 	deleteState.WorkItem.Node = ""
 	deleteState.WorkItem.Pid = 0
 	deleteState.WorkItem.StageStartedAt = nil
-	deleteState.WorkItem.Status = constants.StatusPending
-	deleteState.WorkItem.Stage = constants.StageRequested
 
 	deleter.saveWorkItem(deleteState)
 
@@ -321,20 +336,9 @@ func (deleter *APTFileDeleter) recordFileDeletionEvent(deleteState *models.Delet
 		deleteState.DeleteSummary.AddError(err.Error())
 		return
 	}
-	requestedBy := deleteState.WorkItem.User
-	instApprover := ""
-	if deleteState.WorkItem.InstitutionalApprover != nil {
-		instApprover = *deleteState.WorkItem.InstitutionalApprover
-	}
-	aptrustApprover := ""
-	if deleteState.WorkItem.APTrustApprover != nil {
-		aptrustApprover = *deleteState.WorkItem.APTrustApprover
-	}
-	timestamp := deleteState.DeletedFromPrimaryAt
-	if !deleteState.DeletedFromSecondaryAt.IsZero() {
-		timestamp = deleteState.DeletedFromSecondaryAt
-	}
-	event := models.NewEventFileDeletion(fileUUID, requestedBy, instApprover, aptrustApprover, timestamp)
+	requestedBy, instApprover, aptrustApprover := deleter.requestorAndApprovers(deleteState)
+	event := models.NewEventFileDeletion(fileUUID, requestedBy, instApprover,
+		aptrustApprover, deleter.deletionTimestamp(deleteState))
 	event.IntellectualObjectId = deleteState.GenericFile.IntellectualObjectId
 	event.IntellectualObjectIdentifier = deleteState.GenericFile.IntellectualObjectIdentifier
 	event.GenericFileId = deleteState.GenericFile.Id
@@ -408,21 +412,117 @@ func (deleter *APTFileDeleter) markObjectDeletedIfAppropriate(deleteState *model
 	}
 	files := resp.GenericFiles()
 
-	// All files have been deleted. Mark object deleted.
+	// All files have been deleted. Record the object-level deletion
+	// event and mark the object deleted. If either step fails, take
+	// the object out of RecentlyDeleted, so the next attempt can
+	// retry. That attempt won't save a second deletion event.
 	if len(files) == 0 && !deleter.RecentlyDeleted.Contains(objIdentifier) {
 		deleter.RecentlyDeleted.Add(objIdentifier)
+		deleter.recordObjectDeletionEvent(deleteState, obj)
+		if deleteState.DeleteSummary.HasErrors() {
+			deleter.RecentlyDeleted.Del(objIdentifier)
+			return
+		}
 		resp := deleter.Context.PharosClient.IntellectualObjectFinishDelete(objIdentifier)
 		if resp.Error != nil {
 			deleteState.DeleteSummary.AddError("Error marking %s as deleted: %v",
-				deleteState.GenericFile.Identifier, resp.Error)
+				objIdentifier, resp.Error)
+			deleter.RecentlyDeleted.Del(objIdentifier)
 		} else {
 			deleter.Context.MessageLog.Info(
-				"Marked IntellectualObject %s as deleted (no delete event no more active files)",
+				"Marked IntellectualObject %s as deleted (no more active files)",
 				objIdentifier)
 		}
 	}
 }
 
+// recordObjectDeletionEvent saves an object-level deletion event
+// to Pharos, unless an earlier attempt already saved one. We call
+// this only after confirming that the object has no remaining active
+// files.
+func (deleter *APTFileDeleter) recordObjectDeletionEvent(deleteState *models.DeleteState, obj *models.IntellectualObject) {
+	existing, err := deleter.findObjectDeletionEvent(obj.Identifier)
+	if err != nil {
+		deleteState.DeleteSummary.AddError("Error checking for deletion event "+
+			"for object '%s': %v", obj.Identifier, err)
+		return
+	}
+	if existing != nil {
+		deleter.Context.MessageLog.Info("Object %s already has deletion event %s",
+			obj.Identifier, existing.Identifier)
+		return
+	}
+	requestedBy, instApprover, aptrustApprover := deleter.requestorAndApprovers(deleteState)
+	event := models.NewEventObjectDeletion(obj.Identifier, requestedBy, instApprover,
+		aptrustApprover, deleter.deletionTimestamp(deleteState))
+	event.IntellectualObjectId = obj.Id
+	resp := deleter.Context.PharosClient.PremisEventSave(event)
+	if resp.Error != nil {
+		msg := fmt.Sprintf("Error saving deletion event for object '%s': %v",
+			obj.Identifier, resp.Error)
+		bytes, _ := resp.RawResponseData()
+		if bytes != nil {
+			msg += fmt.Sprintf(" - Pharos response: %s", string(bytes))
+		}
+		deleteState.DeleteSummary.AddError(msg)
+	} else {
+		deleter.Context.MessageLog.Info("Saved deletion event %s for object %s",
+			event.Identifier, obj.Identifier)
+	}
+}
+
+// findObjectDeletionEvent returns the object-level deletion event
+// for the object with the specified identifier, or nil if Pharos
+// doesn't have one. The deletion events of the object's files don't
+// count.
+func (deleter *APTFileDeleter) findObjectDeletionEvent(objIdentifier string) (*models.PremisEvent, error) {
+	params := url.Values{}
+	params.Set("object_identifier", objIdentifier)
+	params.Set("event_type", constants.EventDeletion)
+	params.Set("page", "1")
+	params.Set("per_page", "100")
+	for {
+		resp := deleter.Context.PharosClient.PremisEventList(params)
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		for _, event := range resp.PremisEvents() {
+			if event.GenericFileIdentifier == "" && event.GenericFileId == 0 {
+				return event, nil
+			}
+		}
+		if !resp.HasNextPage() {
+			return nil, nil
+		}
+		params = resp.ParamsForNextPage()
+	}
+}
+
+// requestorAndApprovers returns the user who requested the deletion,
+// the institutional approver, and the APTrust approver. Approvers
+// will be empty strings if they're not set on the WorkItem.
+func (deleter *APTFileDeleter) requestorAndApprovers(deleteState *models.DeleteState) (string, string, string) {
+	instApprover := ""
+	if deleteState.WorkItem.InstitutionalApprover != nil {
+		instApprover = *deleteState.WorkItem.InstitutionalApprover
+	}
+	aptrustApprover := ""
+	if deleteState.WorkItem.APTrustApprover != nil {
+		aptrustApprover = *deleteState.WorkItem.APTrustApprover
+	}
+	return deleteState.WorkItem.User, instApprover, aptrustApprover
+}
+
+// deletionTimestamp returns the time at which the file was deleted
+// from its last storage location.
+func (deleter *APTFileDeleter) deletionTimestamp(deleteState *models.DeleteState) time.Time {
+	timestamp := deleteState.DeletedFromPrimaryAt
+	if !deleteState.DeletedFromSecondaryAt.IsZero() {
+		timestamp = deleteState.DeletedFromSecondaryAt
+	}
+	return timestamp
+}
+
 func (deleter *APTFileDeleter) saveWorkItem(deleteState *models.DeleteState) {
 	msg := fmt.Sprintf("Marking WorkItem %d as %s/%s for object %s.",
 		deleteState.WorkItem.Id,
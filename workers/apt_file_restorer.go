@@ -7,7 +7,6 @@ import (
 	"github.com/APTrust/exchange/context"
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/network"
-	"github.com/APTrust/exchange/util"
 	"github.com/nsqio/go-nsq"
 	"os"
 	"time"
@@ -37,6 +36,14 @@ func NewAPTFileRestorer(_context *context.Context) *APTFileRestorer {
 	if err != nil {
 		panic(fmt.Sprintf("Cannot cache bucket names from Pharos: %v", err))
 	}
+	err = _context.Config.EnsureRestorationBuckets()
+	if err != nil {
+		panic(fmt.Sprintf("Invalid restoration bucket config: %v", err))
+	}
+	err = _context.Config.EnsureInstitutionOverrides()
+	if err != nil {
+		panic(fmt.Sprintf("Invalid institution overrides: %v", err))
+	}
 
 	// Set up buffered channels
 	workerBufferSize := _context.Config.FileRestoreWorker.Workers * 10
@@ -76,18 +83,49 @@ func (restorer *APTFileRestorer) restore() {
 		restoreState.RestoreSummary.AttemptNumber += 1
 		restoreState.RestoreSummary.Start()
 
+		// Don't restore files of objects under embargo without approval.
+		validate := restoreState.RestoreSummary.StartStage(constants.StageValidate)
+		reason := EmbargoCancelReason(restoreState.IntellectualObject,
+			restoreState.WorkItem, time.Now().UTC())
+		if reason != "" {
+			validate.AddError(reason)
+			validate.ErrorIsFatal = true
+			restoreState.RestoreSummary.FinishStage(constants.StageValidate)
+			restoreState.RestoreSummary.Finish()
+			restorer.PostProcessChannel <- restoreState
+			continue
+		}
+
+		// Make sure the WorkItem, file and restoration bucket all
+		// belong to the same institution before we copy anything.
+		restorationBucket := restorer.Context.Config.RestorationBucketFor(
+			restoreState.IntellectualObject.Institution, restoreState.GenericFile.StorageOption)
+		err := CheckInstitutionConsistency(restoreState.WorkItem, restorationBucket,
+			restoreState.IntellectualObject.Identifier, restoreState.GenericFile.Identifier)
+		if err != nil {
+			validate.AddError(err.Error())
+			validate.ErrorIsFatal = true
+			restoreState.RestoreSummary.FinishStage(constants.StageValidate)
+			restoreState.RestoreSummary.Finish()
+			restorer.PostProcessChannel <- restoreState
+			continue
+		}
+		restoreState.RestoreSummary.FinishStage(constants.StageValidate)
+
+		store := restoreState.RestoreSummary.StartStage(constants.StageStore)
 		if restorer.alreadyRestored(restoreState) {
-			restorationBucket := util.RestorationBucketFor(restoreState.IntellectualObject.Institution,
-				restorer.Context.Config.RestoreToTestBuckets)
 			restorer.Context.MessageLog.Info("File %s has already been restored to %s",
 				restoreState.GenericFile.Identifier, restorationBucket)
 		} else {
 			restoreState.NSQMessage.Touch()
-			restorer.copyToRestorationBucket(restoreState)
+			restorer.copyToRestorationBucket(restoreState, store)
 			restoreState.NSQMessage.Touch()
 		}
+		restoreState.RestoreSummary.FinishStage(constants.StageStore)
 
 		restoreState.RestoreSummary.Finish()
+		restorer.Context.MessageLog.Info("Restore stages for %s: %s",
+			restoreState.GenericFile.Identifier, restoreState.RestoreSummary.StageReport())
 		restorer.PostProcessChannel <- restoreState
 	}
 }
@@ -102,20 +140,29 @@ func (restorer *APTFileRestorer) postProcess() {
 	}
 }
 
-func (restorer *APTFileRestorer) copyToRestorationBucket(restoreState *models.FileRestoreState) {
+// copyToRestorationBucket copies the file to the restoration bucket,
+// adding any errors to summary.
+func (restorer *APTFileRestorer) copyToRestorationBucket(restoreState *models.FileRestoreState, summary *models.WorkSummary) {
 	sourceRegion, sourceBucket, err := restorer.Context.Config.StorageRegionAndBucketFor(restoreState.GenericFile.StorageOption)
 	if err != nil {
-		restoreState.RestoreSummary.AddError(err.Error())
+		summary.AddError(err.Error())
 		return
 	}
-	restorationBucket := util.RestorationBucketFor(restoreState.IntellectualObject.Institution,
-		restorer.Context.Config.RestoreToTestBuckets)
+	// Prefer the file's own record of where its primary copy is.
+	// Older files don't record their region, so for those, we go
+	// by the storage option.
+	primary := restoreState.GenericFile.GetStorageRecord(models.StorageRolePrimary)
+	if primary != nil && primary.Region != "" {
+		sourceRegion, sourceBucket = primary.Region, primary.Bucket
+	}
+	restorationBucket := restorer.Context.Config.RestorationBucketFor(
+		restoreState.IntellectualObject.Institution, restoreState.GenericFile.StorageOption)
 	// PT #159115778: Get a client for the S3 restoration region, since
 	// this is the region we're writing to.
 	restorationRegion := restorer.Context.Config.APTrustS3Region
 	fileUUID, err := restoreState.GenericFile.PreservationStorageFileName()
 	if err != nil {
-		restoreState.RestoreSummary.AddError("Error getting file UUID: %v", err)
+		summary.AddError("Error getting file UUID: %v", err)
 		return
 	}
 	restorer.Context.MessageLog.Info("Copying %s (%s) from %s to %s (%s)", restoreState.GenericFile.Identifier,
@@ -130,7 +177,7 @@ func (restorer *APTFileRestorer) copyToRestorationBucket(restoreState *models.Fi
 		restoreState.GenericFile.Identifier)
 	copier.Copy()
 	if copier.ErrorMessage != "" {
-		restoreState.RestoreSummary.AddError("Error copying to restoration bucket: %s",
+		summary.AddError("Error copying to restoration bucket: %s",
 			copier.ErrorMessage)
 		return
 	}
@@ -139,8 +186,8 @@ func (restorer *APTFileRestorer) copyToRestorationBucket(restoreState *models.Fi
 }
 
 func (restorer *APTFileRestorer) alreadyRestored(restoreState *models.FileRestoreState) bool {
-	restorationBucket := util.RestorationBucketFor(restoreState.IntellectualObject.Institution,
-		restorer.Context.Config.RestoreToTestBuckets)
+	restorationBucket := restorer.Context.Config.RestorationBucketFor(
+		restoreState.IntellectualObject.Institution, restoreState.GenericFile.StorageOption)
 	client := network.NewS3Head(
 		os.Getenv("AWS_ACCESS_KEY_ID"),
 		os.Getenv("AWS_SECRET_ACCESS_KEY"),
@@ -56,9 +56,10 @@ func NewAPTFixityChecker(_context *context.Context) *APTFixityChecker {
 }
 
 // HandleMessage handles a new message from NSQ. Unlike most other NSQ messages,
-// where the message.Body is a WorkItem.Id (int as string), messages in the
-// apt_fixity queue contain a GenericFile.Identifier. So the entire message body
-// will be something like "georgetown.edu/georgetown.edu.10822_707412".
+// which refer to a WorkItem, messages in the apt_fixity queue refer to a
+// GenericFile. The message body is a QueueMessage with a GenericFileIdentifier,
+// or, in legacy messages, the bare identifier, something like
+// "georgetown.edu/georgetown.edu.10822_707412".
 func (checker *APTFixityChecker) HandleMessage(message *nsq.Message) error {
 	fixityResult := checker.buildFixityResult(message)
 	if fixityResult.Error != nil {
@@ -120,20 +121,35 @@ func (checker *APTFixityChecker) checkFixity() {
 }
 
 // record records a PremisEvent in Pharos saying when this fixity check
-// was performed and whether it succeeded.
+// was performed and whether it succeeded. If the file has a blake2b
+// checksum, it records a second event for that.
 func (checker *APTFixityChecker) record() {
 	for fixityResult := range checker.RecordChannel {
-		// Create PREMIS event saying whether fixity event
-		// succeeded or failed.
-		event, err := models.NewEventGenericFileFixityCheck(
-			time.Now().UTC(),
-			constants.AlgSha256,
-			fixityResult.Sha256,
-			fixityResult.Sha256 == fixityResult.PharosSha256())
-		if err != nil {
-			fixityResult.Error = fmt.Errorf("Could not create Premis Event for %s: %v",
-				fixityResult.GenericFile.Identifier, err)
-		} else {
+		digests := []struct {
+			alg          string
+			digest       string
+			pharosDigest string
+			optional     bool
+		}{
+			{constants.AlgSha256, fixityResult.Sha256, fixityResult.PharosSha256(), false},
+			{constants.AlgBlake2b, fixityResult.Blake2b, fixityResult.PharosBlake2b(), true},
+		}
+		for _, digest := range digests {
+			if digest.optional && digest.pharosDigest == "" {
+				continue
+			}
+			// Create PREMIS event saying whether fixity event
+			// succeeded or failed.
+			event, err := models.NewEventGenericFileFixityCheck(
+				time.Now().UTC(),
+				digest.alg,
+				digest.digest,
+				digest.digest == digest.pharosDigest)
+			if err != nil {
+				fixityResult.Error = fmt.Errorf("Could not create %s Premis Event for %s: %v",
+					digest.alg, fixityResult.GenericFile.Identifier, err)
+				break
+			}
 			event.IntellectualObjectId = fixityResult.GenericFile.IntellectualObjectId
 			event.IntellectualObjectIdentifier = fixityResult.GenericFile.IntellectualObjectIdentifier
 			event.GenericFileId = fixityResult.GenericFile.Id
@@ -143,11 +159,11 @@ func (checker *APTFixityChecker) record() {
 				fixityResult.Error = fmt.Errorf("After completing fixity check for %s, "+
 					"could not save PremisEvent to Pharos: %v. Event data: %v",
 					fixityResult.GenericFile.Identifier, resp.Error, event)
-			} else {
-				checker.Context.MessageLog.Info("Completing fixity check for %s, "+
-					"and saved PremisEvent %s to Pharos",
-					fixityResult.GenericFile.Identifier, event.Identifier)
+				break
 			}
+			checker.Context.MessageLog.Info("Completing %s fixity check for %s, "+
+				"and saved PremisEvent %s to Pharos",
+				digest.alg, fixityResult.GenericFile.Identifier, event.Identifier)
 		}
 		checker.PostProcessChannel <- fixityResult
 	}
@@ -167,14 +183,19 @@ func (checker *APTFixityChecker) postProcess() {
 				fixityResult.NSQMessage.Requeue(1 * time.Minute)
 			}
 		} else {
-			if fixityResult.PharosSha256() == fixityResult.Sha256 {
+			if fixityResult.FixityMatches() {
 				checker.Context.MessageLog.Info("Fixity check complete for %s. Fixity %s matches.",
 					fixityResult.GenericFile.Identifier, fixityResult.Sha256)
-			} else {
+			} else if fixityResult.PharosSha256() != fixityResult.Sha256 {
 				checker.Context.MessageLog.Warning("Fixity check complete for %s. S3 fixity %s "+
 					"DOES NOT MATCH PHAROS FIXITY %s",
 					fixityResult.GenericFile.Identifier, fixityResult.Sha256,
 					fixityResult.PharosSha256())
+			} else {
+				checker.Context.MessageLog.Warning("Fixity check complete for %s. S3 blake2b "+
+					"fixity %s DOES NOT MATCH PHAROS FIXITY %s",
+					fixityResult.GenericFile.Identifier, fixityResult.Blake2b,
+					fixityResult.PharosBlake2b())
 			}
 			fixityResult.NSQMessage.Finish()
 		}
@@ -188,7 +209,8 @@ func (checker *APTFixityChecker) postProcess() {
 // we don't need to have the file on disk. We can calculate the
 // digest from the stream. We get the file from S3/Virginia, not
 // Glacier/Oregon! When this is done, the fixity value will be in
-// fixityResult.Sha256.
+// fixityResult.Sha256. If Pharos has a blake2b digest for the file,
+// we calculate that in the same pass and put it in fixityResult.Blake2b.
 func (checker *APTFixityChecker) getFixityValueOfS3File(fixityResult *models.FixityResult) {
 	bucket, key, err := fixityResult.BucketAndKey()
 	if err != nil {
@@ -197,15 +219,24 @@ func (checker *APTFixityChecker) getFixityValueOfS3File(fixityResult *models.Fix
 		fixityResult.ErrorIsFatal = true
 		return
 	}
+	// Files stored before we had StorageRecords don't know their
+	// region, but their primary copy is in Virginia.
+	region := constants.AWSVirginia
+	primary := fixityResult.GenericFile.GetStorageRecord(models.StorageRolePrimary)
+	if primary != nil && primary.Region != "" {
+		region = primary.Region
+	}
 	downloader := network.NewS3Download(
 		os.Getenv("AWS_ACCESS_KEY_ID"),
 		os.Getenv("AWS_SECRET_ACCESS_KEY"),
-		constants.AWSVirginia,
+		region,
 		bucket,      // should be S3 preservation bucket
 		key,         // s3 key to fetch
 		"/dev/null", // local path at which to save the s3 file
 		false,       // don't calculate md5 digest
 		true)        // do calculate sha256 digest
+	downloader.CalculateBlake2b = fixityResult.PharosBlake2b() != ""
+	downloader.SessionPool = checker.Context.S3SessionPool
 	downloader.Fetch()
 	if downloader.ErrorMessage != "" {
 		fixityResult.Error = fmt.Errorf("Error fetching file %s (%s/%s) from S3: %s",
@@ -218,6 +249,7 @@ func (checker *APTFixityChecker) getFixityValueOfS3File(fixityResult *models.Fix
 	}
 	fixityResult.S3FileExists = true
 	fixityResult.Sha256 = downloader.Sha256Digest
+	fixityResult.Blake2b = downloader.Blake2bDigest
 	return
 }
 
@@ -225,7 +257,13 @@ func (checker *APTFixityChecker) getFixityValueOfS3File(fixityResult *models.Fix
 // the fixity check process and its outcome.
 func (checker *APTFixityChecker) buildFixityResult(message *nsq.Message) *models.FixityResult {
 	fixityResult := models.NewFixityResult(message)
-	gfIdentifier := strings.TrimSpace(string(message.Body))
+	queueMessage, err := models.ParseNSQMessage(message)
+	if err != nil {
+		fixityResult.Error = err
+		fixityResult.ErrorIsFatal = true
+		return fixityResult
+	}
+	gfIdentifier := queueMessage.GenericFileIdentifier
 	// Get GenericFile with checksums (param includeRelations = true)
 	resp := checker.Context.PharosClient.GenericFileGet(gfIdentifier, true)
 	if resp.Error != nil {
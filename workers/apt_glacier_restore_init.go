@@ -8,8 +8,12 @@ import (
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/network"
 	"github.com/APTrust/exchange/util"
+	"github.com/APTrust/exchange/util/storage"
 	"github.com/nsqio/go-nsq"
+	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -21,13 +25,19 @@ import (
 // to consider adding "Bulk" as a retrieval option
 // for Glacier Deep Archive, because it's 8x cheaper.
 // See the Glacier sections of https://aws.amazon.com/s3/pricing/
-const RETRIEVAL_OPTION = "Standard"
+const RETRIEVAL_OPTION = constants.RestoreTierStandard
 
 // Keep the files in S3 up to 5 days, in case we're
 // having system problems and we need to attempt the
 // restore multiple times.
 const DAYS_TO_KEEP_IN_S3 = 5
 
+// Glacier Deep Archive retrievals take 12+ hours, and we recheck
+// them less often, so an object with many files can take days to
+// come entirely into S3. Keep those files around longer, so the
+// first ones don't expire before the last ones arrive.
+const DEEP_ARCHIVE_DAYS_TO_KEEP_IN_S3 = 10
+
 // After requesting a Glacier restoration, we need to recheck periodically
 // to see if the item has been restored to S3. Restoring from standard
 // Glacier storage typically takes 3-5 hours. Restoring from Glacier Deep
@@ -36,6 +46,20 @@ const DAYS_TO_KEEP_IN_S3 = 5
 const GLACIER_RECHECK_INTERVAL = 2 * time.Hour
 const GLACIER_DEEP_RECHECK_INTERVAL = 8 * time.Hour
 
+// We never recheck sooner than this, even if the typical retrieval
+// time says the files should already be in S3, so we don't keep
+// polling for files that are running late.
+const GLACIER_MIN_RECHECK_INTERVAL = 30 * time.Minute
+
+// When an institution has hit its limit of simultaneous Glacier
+// requests, we requeue with this interval to issue the deferred
+// requests once some of the earlier ones have completed.
+const GLACIER_DEFERRED_RECHECK_INTERVAL = 30 * time.Minute
+
+// When a run fails only with retryable errors, such as Pharos or S3
+// timeouts, we requeue with this interval and try again.
+const GLACIER_RETRYABLE_ERROR_INTERVAL = 5 * time.Minute
+
 // Requests that an object be restored from Glacier to S3. This is
 // the first step toward restoring a Glacier-only bag.
 type APTGlacierRestoreInit struct {
@@ -55,6 +79,12 @@ type APTGlacierRestoreInit struct {
 	// to a local test server. This should not be set in demo or
 	// production.
 	S3Url string
+	// RequestTracker counts the Glacier retrieval requests each
+	// institution has in flight, so we can honor
+	// Config.GlacierRestoreMaxRequestsPerInstitution. If
+	// Config.GlacierRestoreRequestDB is set, the counts are saved
+	// there and survive a restart.
+	RequestTracker *models.GlacierRequestTracker
 }
 
 func NewGlacierRestore(_context *context.Context) *APTGlacierRestoreInit {
@@ -68,6 +98,19 @@ func NewGlacierRestore(_context *context.Context) *APTGlacierRestoreInit {
 		panic(fmt.Sprintf("Cannot cache bucket names from Pharos: %v", err))
 	}
 
+	// If we can't open the request DB, for example because another
+	// apt_glacier_restore_init on this host holds its lock, count
+	// requests in memory rather than refusing to start.
+	restorer.RequestTracker, err = restorer.NewRequestTracker()
+	if err != nil {
+		_context.MessageLog.Warning("Cannot load Glacier request counts: %v. "+
+			"Keeping counts in memory only.", err)
+	}
+	if restorer.RequestTracker == nil {
+		restorer.RequestTracker = models.NewGlacierRequestTracker(
+			_context.Config.GlacierRestoreMaxRequestsPerInstitution)
+	}
+
 	// Set up buffered channels
 	restorerBufferSize := _context.Config.GlacierRestoreWorker.NetworkConnections * 4
 	workerBufferSize := _context.Config.GlacierRestoreWorker.Workers * 10
@@ -83,6 +126,47 @@ func NewGlacierRestore(_context *context.Context) *APTGlacierRestoreInit {
 	return restorer
 }
 
+// NewRequestTracker returns a GlacierRequestTracker for
+// Config.GlacierRestoreMaxRequestsPerInstitution. If
+// Config.GlacierRestoreRequestDB is set, the tracker starts with the
+// counts saved in that file by the last run, minus those of WorkItems
+// that stopped running while the worker was down, and saves its counts
+// there as they change. It creates the file's directory if necessary.
+func (restorer *APTGlacierRestoreInit) NewRequestTracker() (*models.GlacierRequestTracker, error) {
+	limit := restorer.Context.Config.GlacierRestoreMaxRequestsPerInstitution
+	dbPath := restorer.Context.Config.GlacierRestoreRequestDB
+	if dbPath == "" {
+		return models.NewGlacierRequestTracker(limit), nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("Cannot create directory for %s: %v", dbPath, err)
+	}
+	db, err := storage.NewBoltDB(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open %s: %v", dbPath, err)
+	}
+	tracker, err := models.NewPersistentGlacierRequestTracker(limit, db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Cannot read %s: %v", dbPath, err)
+	}
+	for workItemId, institution := range tracker.WorkItems() {
+		resp := restorer.Context.PharosClient.WorkItemGet(workItemId)
+		if resp.Response != nil && resp.Response.StatusCode == http.StatusNotFound {
+			tracker.Remove(institution, workItemId)
+		} else if resp.Error != nil {
+			// Keep counting it. Overestimating only delays requests.
+			restorer.Context.MessageLog.Warning("Cannot check status of WorkItem %d "+
+				"in Glacier request DB: %v", workItemId, resp.Error)
+		} else if resp.WorkItem() == nil || resp.WorkItem().Status != constants.StatusStarted {
+			tracker.Remove(institution, workItemId)
+		}
+	}
+	restorer.Context.MessageLog.Info("Loaded Glacier request counts for %d WorkItems from %s",
+		len(tracker.WorkItems()), dbPath)
+	return tracker, tracker.SaveError()
+}
+
 // This is the callback that NSQ workers use to handle messages from NSQ.
 func (restorer *APTGlacierRestoreInit) HandleMessage(message *nsq.Message) error {
 	message.DisableAutoResponse()
@@ -126,18 +210,30 @@ func (restorer *APTGlacierRestoreInit) RequestRestore() {
 		state.WorkSummary.Attempted = true
 		state.WorkSummary.AttemptNumber += 1
 		state.WorkSummary.Start()
+		state.ClearDeferred()
+		restorer.RequestTracker.SetInFlight(InstitutionOf(state.WorkItem),
+			state.WorkItem.Id, state.InFlightCount())
+
+		// Make sure the WorkItem's identifiers and institution agree
+		// before we ask Glacier to restore anything.
+		err := CheckInstitutionConsistency(state.WorkItem, "")
+		if err != nil {
+			state.WorkSummary.AddError(err.Error())
+			restorer.CleanupChannel <- state
+			continue
+		}
 
 		if state.WorkItem.GenericFileIdentifier != "" {
 			gf, err := restorer.GetGenericFile(state)
 			if err != nil {
-				state.WorkSummary.AddError(err.Error())
+				state.WorkSummary.AddWorkError(err)
 				restorer.CleanupChannel <- state
 				continue
 			}
 			state.GenericFile = gf
 			needsRestoreRequest, err := restorer.RestoreRequestNeeded(state, gf)
 			if err != nil {
-				state.WorkSummary.AddError(err.Error())
+				state.WorkSummary.AddWorkError(err)
 			}
 			if needsRestoreRequest {
 				restorer.RequestFile(state, gf)
@@ -153,14 +249,14 @@ func (restorer *APTGlacierRestoreInit) RequestRestore() {
 func (restorer *APTGlacierRestoreInit) RequestObject(state *models.GlacierRestoreState) {
 	obj, err := restorer.GetIntellectualObject(state)
 	if err != nil {
-		state.WorkSummary.AddError(err.Error())
+		state.WorkSummary.AddWorkError(err)
 		return
 	}
 	state.IntellectualObject = obj
 	for _, gf := range obj.GenericFiles {
 		needsRestoreRequest, err := restorer.RestoreRequestNeeded(state, gf)
 		if err != nil {
-			state.WorkSummary.AddError(err.Error())
+			state.WorkSummary.AddWorkError(err)
 			continue
 		}
 		if needsRestoreRequest {
@@ -179,45 +275,49 @@ func (restorer *APTGlacierRestoreInit) RestoreRequestNeeded(state *models.Glacie
 	if err != nil {
 		return needsRestoreRequest, err
 	}
+
+	glacierRestoreRequest := state.FindRequest(gf.Identifier)
+	if glacierRestoreRequest == nil {
+		details, err := restorer.GetRequestDetails(gf)
+		if err != nil {
+			state.WorkSummary.AddError(err.Error())
+			return true, err
+		}
+		glacierRestoreRequest = restorer.GetRequestRecord(state, gf, details)
+	}
+
 	s3Client.Head(fileUUID)
 
 	// Status 409: Conflict is an expected response.
 	// It means a restore request has already been initiated.
 	if s3Client.ErrorMessage != "" && !strings.Contains(s3Client.ErrorMessage, "Conflict") {
-		err = fmt.Errorf("S3 HEAD request for file %s (%s) returned error: %s",
+		err = models.NewWorkError(models.ErrS3, gf.Identifier, true,
+			"S3 HEAD request for file %s (%s) returned error: %s",
 			fileUUID, gf.Identifier, s3Client.ErrorMessage)
+		glacierRestoreRequest.RecordAttempt(err.Error(), time.Now().UTC())
 		return needsRestoreRequest, err
 	}
 	restoreRequestInfo, err := s3Client.GetRestoreRequestInfo()
 	if err != nil {
+		glacierRestoreRequest.RecordAttempt(err.Error(), time.Now().UTC())
 		return needsRestoreRequest, err
 	}
+	glacierRestoreRequest.RecordAttempt("", time.Now().UTC())
 
-	glacierRestoreRequest := state.FindRequest(gf.Identifier)
-	if glacierRestoreRequest == nil {
-		details, err := restorer.GetRequestDetails(gf)
-		if err != nil {
-			state.WorkSummary.AddError(err.Error())
-			return true, err
-		}
-		glacierRestoreRequest = restorer.GetRequestRecord(state, gf, details)
-	}
+	glacierRestoreRequest.RecordRestoreStatus(restoreRequestInfo.RequestInProgress,
+		restoreRequestInfo.S3ExpiryDate)
 
 	if restoreRequestInfo.RequestInProgress {
 		// Log and go on
 		restorer.Context.MessageLog.Info("Already in progress: %s (%s/%s)",
 			gf.Identifier, s3Client.BucketName, fileUUID)
-		glacierRestoreRequest.RequestAccepted = true
 		if glacierRestoreRequest.RequestedAt.IsZero() {
 			glacierRestoreRequest.RequestedAt = time.Now().UTC()
 		}
 	} else if restoreRequestInfo.RequestIsComplete {
-		// Log and update expiry date
-		glacierRestoreRequest.IsAvailableInS3 = true
-		glacierRestoreRequest.EstimatedDeletionFromS3 = restoreRequestInfo.S3ExpiryDate
-		restorer.Context.MessageLog.Info("Already restored to S3: %s (%s/%s)",
-			gf.Identifier, s3Client.BucketName, fileUUID)
-		glacierRestoreRequest.RequestAccepted = true
+		restorer.Context.MessageLog.Info("Already restored to S3: %s (%s/%s), expires %s",
+			gf.Identifier, s3Client.BucketName, fileUUID,
+			glacierRestoreRequest.RestoreExpiresAt.Format(time.RFC3339))
 		if glacierRestoreRequest.RequestedAt.IsZero() {
 			glacierRestoreRequest.RequestedAt = time.Now().UTC()
 		}
@@ -258,7 +358,8 @@ func (restorer *APTGlacierRestoreInit) GetIntellectualObject(state *models.Glaci
 	// Get object with files (second param) but no events (third param)
 	resp := restorer.Context.PharosClient.IntellectualObjectGet(state.WorkItem.ObjectIdentifier, true, false)
 	if resp.Error != nil {
-		return nil, resp.Error
+		return nil, models.NewWorkError(models.ErrPharos, state.WorkItem.ObjectIdentifier,
+			true, "%v", resp.Error)
 	}
 	obj := resp.IntellectualObject()
 	if obj == nil {
@@ -271,7 +372,8 @@ func (restorer *APTGlacierRestoreInit) GetIntellectualObject(state *models.Glaci
 func (restorer *APTGlacierRestoreInit) GetGenericFile(state *models.GlacierRestoreState) (*models.GenericFile, error) {
 	resp := restorer.Context.PharosClient.GenericFileGet(state.WorkItem.GenericFileIdentifier, false)
 	if resp.Error != nil {
-		return nil, resp.Error
+		return nil, models.NewWorkError(models.ErrPharos, state.WorkItem.GenericFileIdentifier,
+			true, "%v", resp.Error)
 	}
 	gf := resp.GenericFile()
 	if gf == nil {
@@ -284,7 +386,12 @@ func (restorer *APTGlacierRestoreInit) GetGenericFile(state *models.GlacierResto
 func (restorer *APTGlacierRestoreInit) Cleanup() {
 	for state := range restorer.CleanupChannel {
 		if state.WorkSummary.HasErrors() {
-			restorer.FinishWithError(state)
+			if state.WorkSummary.AllErrorsRetryable() &&
+				state.WorkSummary.AttemptNumber < restorer.Context.Config.GlacierRestoreWorker.MaxAttempts {
+				restorer.RequeueAfterRetryableErrors(state)
+			} else {
+				restorer.FinishWithError(state)
+			}
 		} else {
 			gfIdentifiers := state.GetFileIdentifiers()
 			report := state.GetReport(gfIdentifiers)
@@ -297,6 +404,13 @@ func (restorer *APTGlacierRestoreInit) Cleanup() {
 					restorer.CreateRestoreWorkItem(state)
 				}
 				state.NSQMessage.Finish()
+			} else if state.HasDeferredRequests() && len(report.RequestsNotAccepted) == 0 {
+				// We held back some requests because of the
+				// per-institution limit, and Glacier accepted all
+				// the ones we did issue. This is staged issuance,
+				// not failure, so it doesn't count as an attempt.
+				state.WorkSummary.AttemptNumber -= 1
+				restorer.RequeueForDeferredRequests(state)
 			} else if state.WorkSummary.AttemptNumber >= restorer.Context.Config.GlacierRestoreWorker.MaxAttempts {
 				restorer.FinishWithMaxAttemptsExceeded(state, report)
 			} else if report.AllRetrievalsInitiated() {
@@ -306,6 +420,16 @@ func (restorer *APTGlacierRestoreInit) Cleanup() {
 				restorer.RequeueForAdditionalRequests(state)
 			}
 		}
+		if state.WorkItem.Status == constants.StatusStarted {
+			restorer.RequestTracker.SetInFlight(InstitutionOf(state.WorkItem),
+				state.WorkItem.Id, state.InFlightCount())
+		} else {
+			restorer.RequestTracker.Remove(InstitutionOf(state.WorkItem), state.WorkItem.Id)
+		}
+		if err := restorer.RequestTracker.SaveError(); err != nil {
+			restorer.Context.MessageLog.Warning("Cannot save Glacier request counts "+
+				"to %s: %v", restorer.Context.Config.GlacierRestoreRequestDB, err)
+		}
 		restorer.SaveWorkItemState(state)
 		restorer.UpdateWorkItem(state)
 
@@ -367,7 +491,7 @@ func (restorer *APTGlacierRestoreInit) SaveWorkItemState(state *models.GlacierRe
 	}
 
 	restorer.Context.MessageLog.Info("Saving WorkItemState for WorkItem %d", state.WorkItem.Id)
-	resp := restorer.Context.PharosClient.WorkItemStateSave(workItemState)
+	resp := restorer.Context.StateStore().Save(workItemState)
 	if resp.Error != nil {
 		msg := fmt.Sprintf("Error saving WorkItemState for WorkItem %d: %v", state.WorkItem.Id, err)
 		restorer.Context.MessageLog.Error(msg)
@@ -419,6 +543,38 @@ func (restorer *APTGlacierRestoreInit) RequeueForAdditionalRequests(state *model
 	state.NSQMessage.RequeueWithoutBackoff(1 * time.Minute)
 }
 
+// RequeueForDeferredRequests: We call this when we held back some
+// Glacier restore requests because the institution already has as
+// many requests in flight as Config.GlacierRestoreMaxRequestsPerInstitution
+// allows. We'll issue the deferred requests on a later run, after
+// some of the earlier requests have completed.
+func (restorer *APTGlacierRestoreInit) RequeueForDeferredRequests(state *models.GlacierRestoreState) {
+	restorer.Context.MessageLog.Info("Requeueing WorkItem %d: %d Glacier restore requests "+
+		"deferred because institution is at its limit of %d simultaneous requests.",
+		state.WorkItem.Id, len(state.Deferred), restorer.RequestTracker.Limit())
+	state.WorkItem.Note = fmt.Sprintf("Requeued with %d Glacier restore requests "+
+		"waiting for earlier requests to complete.", len(state.Deferred))
+	state.WorkItem.Status = constants.StatusStarted
+	state.WorkItem.Retry = true
+	state.WorkItem.NeedsAdminReview = false
+	state.NSQMessage.RequeueWithoutBackoff(GLACIER_DEFERRED_RECHECK_INTERVAL)
+}
+
+// RequeueAfterRetryableErrors: We call this when all of the errors in
+// this run were retryable, such as Pharos and S3 timeouts, and we have
+// attempts left. Requests that Glacier accepted in this run are saved
+// with the WorkItemState, so the next run picks up where this one left off.
+func (restorer *APTGlacierRestoreInit) RequeueAfterRetryableErrors(state *models.GlacierRestoreState) {
+	errMessage := state.WorkSummary.AllErrorsAsString()
+	restorer.Context.MessageLog.Warning("Requeueing WorkItem %d after retryable errors: %s",
+		state.WorkItem.Id, errMessage)
+	state.WorkItem.Note = fmt.Sprintf("Requeued after retryable errors: %s", errMessage)
+	state.WorkItem.Status = constants.StatusStarted
+	state.WorkItem.Retry = true
+	state.WorkItem.NeedsAdminReview = false
+	state.NSQMessage.RequeueWithoutBackoff(GLACIER_RETRYABLE_ERROR_INTERVAL)
+}
+
 // requeueToCheckState: We call this when we know we've requested
 // Glacier-to-S3 restoration of all required files, and those requests
 // have all been accepted.
@@ -439,13 +595,67 @@ func (restorer *APTGlacierRestoreInit) RequeueToCheckState(state *models.Glacier
 		restorer.Context.MessageLog.Error("Error getting StorageOption for WorkItem %d. ",
 			state.WorkItem.Id)
 	}
-	recheckInterval := GLACIER_RECHECK_INTERVAL
+	recheckInterval := GlacierRecheckInterval(state, storageOption, time.Now().UTC())
+	restorer.Context.MessageLog.Info("Will check WorkItem %d (%s) again in %s.",
+		state.WorkItem.Id, storageOption, recheckInterval)
+	state.NSQMessage.RequeueWithoutBackoff(recheckInterval)
+}
+
+// GlacierRecheckInterval returns how long to wait before checking
+// whether the pending retrievals in state have reached S3. This is
+// the time until the first of them should arrive, based on when we
+// requested it and the typical retrieval time for its tier, but no
+// less than GLACIER_MIN_RECHECK_INTERVAL and no more than the usual
+// recheck interval for storageOption.
+func GlacierRecheckInterval(state *models.GlacierRestoreState, storageOption string, now time.Time) time.Duration {
+	maxInterval := GLACIER_RECHECK_INTERVAL
 	if util.IsGlacierDeepArchive(storageOption) {
-		recheckInterval = GLACIER_DEEP_RECHECK_INTERVAL
-		restorer.Context.MessageLog.Error("Setting longer polling interval because "+
-			"WorkItem %d is in Glacier Deep Archive.", state.WorkItem.Id)
+		maxInterval = GLACIER_DEEP_RECHECK_INTERVAL
 	}
-	state.NSQMessage.RequeueWithoutBackoff(recheckInterval)
+	var firstArrival time.Time
+	for _, req := range state.Requests {
+		if !req.IsPending() || req.RequestedAt.IsZero() {
+			continue
+		}
+		arrival := req.RequestedAt.Add(TypicalRetrievalTime(storageOption, req.RestoreTier))
+		if firstArrival.IsZero() || arrival.Before(firstArrival) {
+			firstArrival = arrival
+		}
+	}
+	if firstArrival.IsZero() {
+		return maxInterval
+	}
+	interval := firstArrival.Sub(now)
+	if interval < GLACIER_MIN_RECHECK_INTERVAL {
+		interval = GLACIER_MIN_RECHECK_INTERVAL
+	}
+	if interval > maxInterval {
+		interval = maxInterval
+	}
+	return interval
+}
+
+// TypicalRetrievalTime returns how long AWS says a retrieval with the
+// specified tier usually takes for the specified storage option. An
+// empty tier, which means someone else requested the retrieval, is
+// treated as Standard. See https://aws.amazon.com/s3/storage-classes/
+func TypicalRetrievalTime(storageOption, tier string) time.Duration {
+	deepArchive := util.IsGlacierDeepArchive(storageOption)
+	switch tier {
+	case constants.RestoreTierExpedited:
+		if !deepArchive {
+			return 5 * time.Minute
+		}
+	case constants.RestoreTierBulk:
+		if deepArchive {
+			return 48 * time.Hour
+		}
+		return 12 * time.Hour
+	}
+	if deepArchive {
+		return 12 * time.Hour
+	}
+	return 5 * time.Hour
 }
 
 // createRestoreWorkItem: We call this to create a normal WorkItem
@@ -497,7 +707,8 @@ func (restorer *APTGlacierRestoreInit) RequestAllFiles(state *models.GlacierRest
 		gfIdentifier := state.WorkItem.GenericFileIdentifier
 		resp := restorer.Context.PharosClient.GenericFileGet(gfIdentifier, false)
 		if resp.Error != nil {
-			state.WorkSummary.AddError("Error getting GenericFile %s from Pharos: %v", gfIdentifier, resp.Error)
+			state.WorkSummary.AddWorkError(models.NewWorkError(models.ErrPharos, gfIdentifier, true,
+				"Error getting GenericFile %s from Pharos: %v", gfIdentifier, resp.Error))
 			return
 		}
 		genericFile := resp.GenericFile()
@@ -543,7 +754,18 @@ func (restorer *APTGlacierRestoreInit) RequestFile(state *models.GlacierRestoreS
 				gf.Identifier, details["bucket"], details["fileUUID"],
 				glacierRestoreRequest.RequestedAt.Format(time.RFC3339))
 		}
+		institution := InstitutionOf(state.WorkItem)
+		if !restorer.RequestTracker.Reserve(institution, state.WorkItem.Id) {
+			restorer.Context.MessageLog.Info("Deferring Glacier retrieval of %s: "+
+				"%s has %d requests in flight.", gf.Identifier, institution,
+				restorer.RequestTracker.InFlight(institution))
+			state.DeferRequest(gf.Identifier)
+			return
+		}
 		restorer.InitializeRetrieval(state, gf, details, glacierRestoreRequest)
+		if !glacierRestoreRequest.RequestAccepted {
+			restorer.RequestTracker.Release(institution, state.WorkItem.Id)
+		}
 	}
 }
 
@@ -595,6 +817,7 @@ func (restorer *APTGlacierRestoreInit) InitializeRetrieval(state *models.Glacier
 	restorer.Context.MessageLog.Info("Requesting Glacier retrieval of %s at %s (%s)",
 		gf.Identifier, gf.URI, gf.StorageOption)
 
+	daysToKeep := DaysToKeepInS3(gf.StorageOption)
 	restoreClient := network.NewS3Restore(
 		restorer.Context.Config.GetAWSAccessKeyId(),
 		restorer.Context.Config.GetAWSSecretAccessKey(),
@@ -602,7 +825,7 @@ func (restorer *APTGlacierRestoreInit) InitializeRetrieval(state *models.Glacier
 		details["bucket"],
 		details["fileUUID"],
 		RETRIEVAL_OPTION,
-		DAYS_TO_KEEP_IN_S3)
+		int64(daysToKeep))
 	if restorer.S3Url != "" {
 		restorer.Context.MessageLog.Warning("Setting S3 URL to %s. This should happen only in testing!",
 			restorer.S3Url)
@@ -610,17 +833,30 @@ func (restorer *APTGlacierRestoreInit) InitializeRetrieval(state *models.Glacier
 		restoreClient.BucketName = constants.AWS_TEST_HACK_BUCKET_NAME
 	}
 	now := time.Now().UTC()
-	estimatedDeletionFromS3 := now.AddDate(0, 0, DAYS_TO_KEEP_IN_S3)
+	estimatedDeletionFromS3 := now.AddDate(0, 0, daysToKeep)
 	restoreClient.Restore()
 	if restoreClient.ErrorMessage != "" {
-		state.WorkSummary.AddError("Glacier retrieval request returned an error for %s at %s: %v",
+		workError := models.NewWorkError(models.ErrGlacier, gf.Identifier, true,
+			"Glacier retrieval request returned an error for %s at %s: %v",
 			gf.Identifier, gf.URI, restoreClient.ErrorMessage)
+		state.WorkSummary.AddWorkError(workError)
+		glacierRestoreRequest.RecordAttempt(workError.Error(), now)
+	} else if restoreClient.RequestRejectedServiceUnavailable {
+		// Not an error for the WorkSummary, since we'll requeue
+		// and ask again, but the file is stuck until Glacier
+		// accepts a request.
+		glacierRestoreRequest.RecordAttempt("Glacier rejected the retrieval "+
+			"request because the service is unavailable", now)
+	} else {
+		glacierRestoreRequest.RecordAttempt("", now)
 	}
 
 	// Update this info. It's a pointer, so it will be saved with GlacierRestoreState.
 	glacierRestoreRequest.RequestAccepted = restoreClient.RequestAccepted()
 	glacierRestoreRequest.RequestedAt = now
 	glacierRestoreRequest.EstimatedDeletionFromS3 = estimatedDeletionFromS3
+	glacierRestoreRequest.RestoreTier = RETRIEVAL_OPTION
+	glacierRestoreRequest.OngoingRequest = glacierRestoreRequest.RequestAccepted
 
 	// If we're requesting this now, it's because we think
 	// we haven't requested it yet. But if it's already in
@@ -630,6 +866,15 @@ func (restorer *APTGlacierRestoreInit) InitializeRetrieval(state *models.Glacier
 	glacierRestoreRequest.SomeoneElseRequested = restoreClient.RestoreAlreadyInProgress
 }
 
+// DaysToKeepInS3 returns the number of days a file with the specified
+// storage option should stay in S3 after we retrieve it from Glacier.
+func DaysToKeepInS3(storageOption string) int {
+	if util.IsGlacierDeepArchive(storageOption) {
+		return DEEP_ARCHIVE_DAYS_TO_KEEP_IN_S3
+	}
+	return DAYS_TO_KEEP_IN_S3
+}
+
 // PT #158734805: Check to make sure no existing restore
 // request exists before we create a new one. If a pending
 // restore request exists for this same item, we don't
@@ -6,6 +6,7 @@ import (
 	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/network"
+	"github.com/APTrust/exchange/util/storage"
 	"github.com/APTrust/exchange/util/testutil"
 	"github.com/APTrust/exchange/workers"
 	"github.com/stretchr/testify/assert"
@@ -137,6 +138,80 @@ func TestNewGlacierRestore(t *testing.T) {
 	assert.NotNil(t, glacierRestore.CleanupChannel)
 }
 
+func TestNewRequestTracker(t *testing.T) {
+	worker := getGlacierRestoreWorker(t)
+	require.NotNil(t, worker)
+	assert.Equal(t, 0, len(worker.RequestTracker.WorkItems()))
+
+	// Counts saved by the last run: WorkItem 1 is still running,
+	// WorkItem 2 finished while the worker was down, and WorkItem 3
+	// no longer exists.
+	tempFile, err := ioutil.TempFile("", "glacier_requests")
+	require.Nil(t, err)
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+	db, err := storage.NewBoltDB(tempFile.Name())
+	require.Nil(t, err)
+	err = db.SaveGlacierRequestCounts(map[string]map[int]int{
+		"test.edu":    {1: 4, 2: 3},
+		"example.edu": {3: 1},
+	})
+	require.Nil(t, err)
+	db.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		workItem := testutil.MakeWorkItem()
+		switch r.URL.Path {
+		case "/api/v2/items/1/":
+			workItem.Status = constants.StatusStarted
+		case "/api/v2/items/2/":
+			workItem.Status = constants.StatusSuccess
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, _ := json.Marshal(workItem)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	worker.Context.Config.GlacierRestoreMaxRequestsPerInstitution = 5
+	worker.Context.Config.GlacierRestoreRequestDB = tempFile.Name()
+	worker.Context.PharosClient = getPharosClientForTest(server.URL)
+	tracker, err := worker.NewRequestTracker()
+	require.Nil(t, err)
+	assert.Equal(t, 5, tracker.Limit())
+	assert.Equal(t, map[int]string{1: "test.edu"}, tracker.WorkItems())
+	assert.Equal(t, 4, tracker.InFlight("test.edu"))
+	assert.Equal(t, 0, tracker.InFlight("example.edu"))
+}
+
+// If another process holds the lock on the request DB,
+// NewGlacierRestore should count requests in memory, not panic.
+func TestNewGlacierRestoreWithLockedRequestDB(t *testing.T) {
+	tempFile, err := ioutil.TempFile("", "glacier_requests")
+	require.Nil(t, err)
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+	db, err := storage.NewBoltDB(tempFile.Name())
+	require.Nil(t, err)
+	defer db.Close()
+
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+	if !testutil.ShouldRunIntegrationTests() {
+		_context.PharosClient = getPharosClientForTest(pharosTestServer.URL)
+	}
+	_context.Config.GlacierRestoreMaxRequestsPerInstitution = 5
+	_context.Config.GlacierRestoreRequestDB = tempFile.Name()
+	glacierRestore := workers.NewGlacierRestore(_context)
+	require.NotNil(t, glacierRestore)
+	require.NotNil(t, glacierRestore.RequestTracker)
+	assert.Equal(t, 5, glacierRestore.RequestTracker.Limit())
+	assert.Equal(t, 0, len(glacierRestore.RequestTracker.WorkItems()))
+}
+
 func TestGetGlacierRestoreState(t *testing.T) {
 	worker, state := getTestComponents(t, "object")
 
@@ -205,6 +280,8 @@ func TestRestoreRequestNeeded(t *testing.T) {
 	requestNeeded, err := worker.RestoreRequestNeeded(state, gf)
 	require.Nil(t, err)
 	assert.True(t, requestNeeded)
+	assert.Equal(t, 1, state.FindRequest(gf.Identifier).AttemptCount)
+	assert.Empty(t, state.FindRequest(gf.Identifier).ErrorMessage)
 
 	// Make sure the GlacierRestore worker created a
 	// GlacierRestoreRequest record for this file.
@@ -239,6 +316,8 @@ func TestRestoreRequestNeeded(t *testing.T) {
 	// Request must have been accepted, because the restore is in progress.
 	assert.True(t, glacierRestoreRequest.RequestAccepted)
 	assert.False(t, glacierRestoreRequest.IsAvailableInS3)
+	assert.True(t, glacierRestoreRequest.OngoingRequest)
+	assert.True(t, glacierRestoreRequest.RestoreExpiresAt.IsZero())
 	assert.False(t, glacierRestoreRequest.SomeoneElseRequested)
 	assert.False(t, glacierRestoreRequest.RequestedAt.IsZero())
 	assert.WithinDuration(t, time.Now().UTC(), glacierRestoreRequest.LastChecked, 10*time.Second)
@@ -261,9 +340,11 @@ func TestRestoreRequestNeeded(t *testing.T) {
 	// Request must have been accepted, because the restore is in progress.
 	assert.True(t, glacierRestoreRequest.RequestAccepted)
 	assert.True(t, glacierRestoreRequest.IsAvailableInS3)
+	assert.False(t, glacierRestoreRequest.OngoingRequest)
+	assert.False(t, glacierRestoreRequest.RestoreExpiresAt.IsZero())
 	assert.False(t, glacierRestoreRequest.SomeoneElseRequested)
 	assert.False(t, glacierRestoreRequest.RequestedAt.IsZero())
-	assert.False(t, glacierRestoreRequest.EstimatedDeletionFromS3.IsZero())
+	assert.Equal(t, glacierRestoreRequest.RestoreExpiresAt, glacierRestoreRequest.EstimatedDeletionFromS3)
 	assert.WithinDuration(t, time.Now().UTC(), glacierRestoreRequest.LastChecked, 10*time.Second)
 }
 
@@ -298,6 +379,27 @@ func TestGetS3HeadClient(t *testing.T) {
 	require.NotNil(t, client)
 	assert.Equal(t, worker.Context.Config.GlacierRegionVA, client.AWSRegion)
 	assert.Equal(t, worker.Context.Config.GlacierBucketVA, client.BucketName)
+
+	// Glacier Deep Archive OH
+	client, err = worker.GetS3HeadClient(constants.StorageGlacierDeepOH)
+	require.Nil(t, err)
+	require.NotNil(t, client)
+	assert.Equal(t, worker.Context.Config.GlacierRegionOH, client.AWSRegion)
+	assert.Equal(t, worker.Context.Config.GlacierDeepBucketOH, client.BucketName)
+
+	// Glacier Deep Archive OR
+	client, err = worker.GetS3HeadClient(constants.StorageGlacierDeepOR)
+	require.Nil(t, err)
+	require.NotNil(t, client)
+	assert.Equal(t, worker.Context.Config.GlacierRegionOR, client.AWSRegion)
+	assert.Equal(t, worker.Context.Config.GlacierDeepBucketOR, client.BucketName)
+
+	// Glacier Deep Archive VA
+	client, err = worker.GetS3HeadClient(constants.StorageGlacierDeepVA)
+	require.Nil(t, err)
+	require.NotNil(t, client)
+	assert.Equal(t, worker.Context.Config.GlacierRegionVA, client.AWSRegion)
+	assert.Equal(t, worker.Context.Config.GlacierDeepBucketVA, client.BucketName)
 }
 
 func TestGetIntellectualObject(t *testing.T) {
@@ -389,6 +491,35 @@ func TestRequeueForAdditionalRequests(t *testing.T) {
 	assert.False(t, state.WorkItem.NeedsAdminReview)
 }
 
+func TestRequeueForDeferredRequests(t *testing.T) {
+	worker, state := getTestComponents(t, "object")
+	delegate := testutil.NewNSQTestDelegate()
+	state.NSQMessage.Delegate = delegate
+	state.DeferRequest("test.edu/bag/file1.txt")
+	worker.RequeueForDeferredRequests(state)
+	assert.Equal(t, "requeue", delegate.Operation)
+	assert.Equal(t, 30*time.Minute, delegate.Delay)
+	assert.Equal(t, "Requeued with 1 Glacier restore requests waiting for earlier requests to complete.", state.WorkItem.Note)
+	assert.Equal(t, constants.StatusStarted, state.WorkItem.Status)
+	assert.True(t, state.WorkItem.Retry)
+	assert.False(t, state.WorkItem.NeedsAdminReview)
+}
+
+func TestRequeueAfterRetryableErrors(t *testing.T) {
+	worker, state := getTestComponents(t, "object")
+	delegate := testutil.NewNSQTestDelegate()
+	state.NSQMessage.Delegate = delegate
+	state.WorkSummary.AddWorkError(models.NewWorkError(models.ErrS3,
+		"test.edu/bag/file1.txt", true, "Connection reset"))
+	worker.RequeueAfterRetryableErrors(state)
+	assert.Equal(t, "requeue", delegate.Operation)
+	assert.Equal(t, workers.GLACIER_RETRYABLE_ERROR_INTERVAL, delegate.Delay)
+	assert.Equal(t, "Requeued after retryable errors: Connection reset", state.WorkItem.Note)
+	assert.Equal(t, constants.StatusStarted, state.WorkItem.Status)
+	assert.True(t, state.WorkItem.Retry)
+	assert.False(t, state.WorkItem.NeedsAdminReview)
+}
+
 func TestRequeueToCheckState(t *testing.T) {
 	worker, state := getTestComponents(t, "object")
 	delegate := testutil.NewNSQTestDelegate()
@@ -467,6 +598,9 @@ func TestRequestFile(t *testing.T) {
 	assert.True(t, glacierRestoreRequest.LastChecked.IsZero())
 	assert.False(t, glacierRestoreRequest.RequestAccepted)
 	assert.False(t, glacierRestoreRequest.IsAvailableInS3)
+	assert.Equal(t, 1, glacierRestoreRequest.AttemptCount)
+	assert.Contains(t, glacierRestoreRequest.ErrorMessage, "Glacier rejected the retrieval request")
+	assert.Equal(t, timeOfFirstRequest, glacierRestoreRequest.LastErrorAt)
 
 	// Now accept the request and make sure the request record
 	// was properly updated.
@@ -477,6 +611,9 @@ func TestRequestFile(t *testing.T) {
 	assert.True(t, glacierRestoreRequest.LastChecked.IsZero())
 	assert.True(t, glacierRestoreRequest.RequestedAt.After(timeOfFirstRequest))
 	assert.False(t, glacierRestoreRequest.IsAvailableInS3)
+	assert.Equal(t, 2, glacierRestoreRequest.AttemptCount)
+	assert.Empty(t, glacierRestoreRequest.ErrorMessage)
+	assert.Equal(t, timeOfFirstRequest, glacierRestoreRequest.LastErrorAt)
 
 	// Make sure LastChecked is updated when we do a status check
 	// via S3 Head on a file whose restoration request was accepted by Glacier.
@@ -488,6 +625,37 @@ func TestRequestFile(t *testing.T) {
 	assert.False(t, glacierRestoreRequest.IsAvailableInS3)
 }
 
+func TestRequestFileDeferredAtInstitutionLimit(t *testing.T) {
+	worker, state := getTestComponents(t, "file")
+	delegate := testutil.NewNSQTestDelegate()
+	state.NSQMessage.Delegate = delegate
+
+	gf, err := worker.GetGenericFile(state)
+	assert.Nil(t, err)
+	require.NotNil(t, gf)
+
+	// Another WorkItem from the same institution has used up
+	// the only available slot, so this request must wait.
+	worker.RequestTracker = models.NewGlacierRequestTracker(1)
+	worker.RequestTracker.SetInFlight(workers.InstitutionOf(state.WorkItem), state.WorkItem.Id+1, 1)
+	DescribeRestoreStateAs = NotStartedAcceptNow
+	worker.RequestFile(state, gf)
+	assert.True(t, state.HasDeferredRequests())
+	assert.Equal(t, []string{gf.Identifier}, state.Deferred)
+	glacierRestoreRequest := worker.GetRequestRecord(state, gf, make(map[string]string))
+	require.NotNil(t, glacierRestoreRequest)
+	assert.True(t, glacierRestoreRequest.RequestedAt.IsZero())
+
+	// Once the slot frees up, the request goes through.
+	worker.RequestTracker.Remove(workers.InstitutionOf(state.WorkItem), state.WorkItem.Id+1)
+	state.ClearDeferred()
+	worker.RequestFile(state, gf)
+	assert.False(t, state.HasDeferredRequests())
+	glacierRestoreRequest = worker.GetRequestRecord(state, gf, make(map[string]string))
+	assert.True(t, glacierRestoreRequest.RequestAccepted)
+	assert.Equal(t, 1, worker.RequestTracker.InFlight(workers.InstitutionOf(state.WorkItem)))
+}
+
 func TestGetRequestDetails(t *testing.T) {
 	worker, state := getTestComponents(t, "file")
 	require.Nil(t, state.GenericFile)
@@ -531,6 +699,22 @@ func TestGetRequestDetails(t *testing.T) {
 	assert.Equal(t, worker.Context.Config.GlacierRegionVA, details["region"])
 	assert.Equal(t, worker.Context.Config.GlacierBucketVA, details["bucket"])
 
+	// Glacier Deep Archive, in each region
+	deepArchive := map[string][]string{
+		constants.StorageGlacierDeepOH: {worker.Context.Config.GlacierRegionOH, worker.Context.Config.GlacierDeepBucketOH},
+		constants.StorageGlacierDeepOR: {worker.Context.Config.GlacierRegionOR, worker.Context.Config.GlacierDeepBucketOR},
+		constants.StorageGlacierDeepVA: {worker.Context.Config.GlacierRegionVA, worker.Context.Config.GlacierDeepBucketVA},
+	}
+	for storageOption, regionAndBucket := range deepArchive {
+		gf.StorageOption = storageOption
+		details, err = worker.GetRequestDetails(gf)
+		require.Nil(t, err, storageOption)
+		require.NotNil(t, details, storageOption)
+		assert.Equal(t, fileUUID, details["fileUUID"], storageOption)
+		assert.Equal(t, regionAndBucket[0], details["region"], storageOption)
+		assert.Equal(t, regionAndBucket[1], details["bucket"], storageOption)
+	}
+
 	// Standard storage
 	gf.StorageOption = constants.StorageStandard
 	details, err = worker.GetRequestDetails(gf)
@@ -547,6 +731,58 @@ func TestGetRequestDetails(t *testing.T) {
 	require.Nil(t, details)
 }
 
+func TestDaysToKeepInS3(t *testing.T) {
+	assert.Equal(t, workers.DAYS_TO_KEEP_IN_S3, workers.DaysToKeepInS3(constants.StorageStandard))
+	for _, option := range constants.GlacierStandardOptions {
+		assert.Equal(t, workers.DAYS_TO_KEEP_IN_S3, workers.DaysToKeepInS3(option))
+	}
+	for _, option := range constants.GlacierDeepOptions {
+		assert.Equal(t, workers.DEEP_ARCHIVE_DAYS_TO_KEEP_IN_S3, workers.DaysToKeepInS3(option))
+	}
+}
+
+func TestTypicalRetrievalTime(t *testing.T) {
+	assert.Equal(t, 5*time.Minute, workers.TypicalRetrievalTime(constants.StorageGlacierOH, constants.RestoreTierExpedited))
+	assert.Equal(t, 5*time.Hour, workers.TypicalRetrievalTime(constants.StorageGlacierOH, constants.RestoreTierStandard))
+	assert.Equal(t, 5*time.Hour, workers.TypicalRetrievalTime(constants.StorageGlacierOH, ""))
+	assert.Equal(t, 12*time.Hour, workers.TypicalRetrievalTime(constants.StorageGlacierOH, constants.RestoreTierBulk))
+	// Deep Archive has no Expedited tier.
+	assert.Equal(t, 12*time.Hour, workers.TypicalRetrievalTime(constants.StorageGlacierDeepOH, constants.RestoreTierExpedited))
+	assert.Equal(t, 12*time.Hour, workers.TypicalRetrievalTime(constants.StorageGlacierDeepOH, constants.RestoreTierStandard))
+	assert.Equal(t, 48*time.Hour, workers.TypicalRetrievalTime(constants.StorageGlacierDeepOH, constants.RestoreTierBulk))
+}
+
+func TestGlacierRecheckInterval(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	state := models.NewGlacierRestoreState(testutil.MakeNsqMessage("42"), testutil.MakeWorkItem())
+
+	// Nothing pending: the usual interval.
+	assert.Equal(t, workers.GLACIER_RECHECK_INTERVAL, workers.GlacierRecheckInterval(state, constants.StorageGlacierOH, now))
+	assert.Equal(t, workers.GLACIER_DEEP_RECHECK_INTERVAL, workers.GlacierRecheckInterval(state, constants.StorageGlacierDeepOH, now))
+
+	// Standard tier requested 4 hours ago should arrive in an hour.
+	request := &models.GlacierRestoreRequest{
+		RequestAccepted: true,
+		OngoingRequest:  true,
+		RestoreTier:     constants.RestoreTierStandard,
+		RequestedAt:     now.Add(-4 * time.Hour),
+	}
+	state.Requests = append(state.Requests, request)
+	assert.Equal(t, time.Hour, workers.GlacierRecheckInterval(state, constants.StorageGlacierOH, now))
+
+	// Just requested: no longer than the usual interval.
+	request.RequestedAt = now
+	assert.Equal(t, workers.GLACIER_RECHECK_INTERVAL, workers.GlacierRecheckInterval(state, constants.StorageGlacierOH, now))
+
+	// Running late: no sooner than the minimum.
+	request.RequestedAt = now.Add(-6 * time.Hour)
+	assert.Equal(t, workers.GLACIER_MIN_RECHECK_INTERVAL, workers.GlacierRecheckInterval(state, constants.StorageGlacierOH, now))
+
+	// Files already in S3 don't count.
+	request.RecordRestoreStatus(false, now.Add(24*time.Hour))
+	assert.Equal(t, workers.GLACIER_RECHECK_INTERVAL, workers.GlacierRecheckInterval(state, constants.StorageGlacierOH, now))
+}
+
 func TestGetRequestRecord(t *testing.T) {
 	worker, state := getTestComponents(t, "file")
 	require.Nil(t, state.GenericFile)
@@ -1021,12 +1257,11 @@ func workItemStatePutHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func intellectualObjectGetHandler(w http.ResponseWriter, r *http.Request) {
-	obj := testutil.MakeIntellectualObject(12, 0, 0, 0)
-	obj.StorageOption = constants.StorageGlacierOH
-	for i, gf := range obj.GenericFiles {
-		gf.Identifier = fmt.Sprintf("%s/file_%d.txt", obj.Identifier, i)
-		gf.StorageOption = constants.StorageGlacierOH
-	}
+	obj := testutil.NewObjectBuilder().
+		WithStorageOption(constants.StorageGlacierOH).
+		WithFileCount(12).
+		WithFileNames("file_%d.txt").
+		Build()
 	objJson, _ := json.Marshal(obj)
 	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprintln(w, string(objJson))
@@ -37,6 +37,9 @@ func NewAPTQueue(_context *context.Context, topic string, enableStats, dryRun bo
 	if err != nil {
 		panic(fmt.Sprintf("Cannot cache bucket names from Pharos: %v", err))
 	}
+	if err = _context.Config.EnsurePriorityDelays(); err != nil {
+		panic(err.Error())
+	}
 
 	nsqClient := network.NewNSQClient(_context.Config.NsqdHttpAddress)
 	aptQueue := &APTQueue{
@@ -71,11 +74,13 @@ func (aptQueue *APTQueue) Run() {
 				"Error getting WorkItem list from Pharos: %s",
 				resp.Error)
 		}
+		queued := make([]*models.WorkItem, 0)
 		for _, item := range resp.WorkItems() {
 			if aptQueue.addToNSQ(item) {
-				aptQueue.markAsQueued(item)
+				queued = append(queued, item)
 			}
 		}
+		aptQueue.markAsQueued(queued)
 		if resp.HasNextPage() == false {
 			break
 		}
@@ -91,6 +96,15 @@ func (aptQueue *APTQueue) addToNSQ(workItem *models.WorkItem) bool {
 	if workItem.GenericFileIdentifier != "" {
 		identifier = workItem.GenericFileIdentifier
 	}
+	if workItem.IsHeldForQuota() || !workItem.Retry {
+		// Pharos should have filtered these out, but the bucket
+		// reader's quota check depends on our skipping them.
+		aptQueue.Context.MessageLog.Info(
+			"Skipping WorkItem id %d - %s (%s/%s/%s) because it is not "+
+				"marked for retry: %s", workItem.Id, identifier, workItem.Action,
+			workItem.Stage, workItem.Status, workItem.Outcome)
+		return false
+	}
 	topic := aptQueue.getNSQTopic(workItem)
 	if aptQueue.topic != "" && topic != aptQueue.topic {
 		aptQueue.Context.MessageLog.Info(
@@ -113,53 +127,46 @@ func (aptQueue *APTQueue) addToNSQ(workItem *models.WorkItem) bool {
 			workItem.Stage, workItem.Status, topic)
 		return false
 	}
-	err := aptQueue.NSQClient.Enqueue(topic, workItem.Id)
+	delay, err := EnqueueByPriority(aptQueue.Context, aptQueue.NSQClient, topic, workItem)
 	if err != nil {
 		aptQueue.recordError("Error sending WorkItem %d %s (%s/%s/%s) - to %s: %v",
 			workItem.Id, identifier, workItem.Action,
 			workItem.Stage, workItem.Status, topic, err)
 		return false
 	}
-	aptQueue.Context.MessageLog.Info("Added WorkItem id %d - %s (%s/%s/%s) - to %s",
-		workItem.Id, identifier, workItem.Action, workItem.Stage, workItem.Status, topic)
+	aptQueue.Context.MessageLog.Info("Added WorkItem id %d - %s (%s/%s/%s) - to %s "+
+		"with %s priority (delay %s)",
+		workItem.Id, identifier, workItem.Action, workItem.Stage, workItem.Status, topic,
+		workItem.EffectivePriority(), delay)
 	if aptQueue.stats != nil {
 		aptQueue.stats.AddWorkItem(topic, workItem)
 	}
 	return true
 }
 
-func (aptQueue *APTQueue) markAsQueued(workItem *models.WorkItem) *models.WorkItem {
+// markAsQueued sets QueuedAt on the items we just added to NSQ,
+// and saves them to Pharos in batches, so apt_queue doesn't add
+// them again.
+func (aptQueue *APTQueue) markAsQueued(workItems []*models.WorkItem) {
+	if len(workItems) == 0 {
+		return
+	}
 	utcNow := time.Now().UTC()
-	workItem.Date = utcNow
-	workItem.QueuedAt = &utcNow
-	resp := aptQueue.Context.PharosClient.WorkItemSave(workItem)
-	if resp.Error != nil {
-		aptQueue.recordError("Error setting QueuedAt for WorkItem with id %d: %v",
-			workItem.Id, resp.Error)
-		return nil
-	}
-	if resp.Response.StatusCode != 200 {
-		aptQueue.processPharosError(resp)
-		return nil
-	}
-	aptQueue.Context.MessageLog.Info("Marked WorkItem id %d (%s/%s/%s) as queued in Pharos",
-		workItem.Id, workItem.Action, workItem.Stage, workItem.Status)
-	if aptQueue.stats != nil {
-		aptQueue.stats.AddItemMarkedAsQueued(workItem)
+	for _, workItem := range workItems {
+		workItem.Date = utcNow
+		workItem.QueuedAt = &utcNow
+	}
+	saved, errs := SaveWorkItemStatuses(aptQueue.Context, workItems)
+	for _, err := range errs {
+		aptQueue.recordError("Error setting QueuedAt: %v", err)
+	}
+	for _, workItem := range saved {
+		aptQueue.Context.MessageLog.Info("Marked WorkItem id %d (%s/%s/%s) as queued in Pharos",
+			workItem.Id, workItem.Action, workItem.Stage, workItem.Status)
+		if aptQueue.stats != nil {
+			aptQueue.stats.AddItemMarkedAsQueued(workItem)
+		}
 	}
-	return resp.WorkItem()
-}
-
-func (aptQueue *APTQueue) processPharosError(resp *network.PharosResponse) {
-	respBody := ""
-	bytesRead, aptQueuer := resp.RawResponseData()
-	if aptQueuer == nil {
-		respBody = string(bytesRead)
-	} else {
-		respBody = fmt.Sprintf("[Could not read response body: %v]", aptQueuer)
-	}
-	aptQueue.recordError("%s %s returned status code %d. Response body: %s",
-		resp.Request.Method, resp.Request.URL, resp.Response.StatusCode, respBody)
 }
 
 func (aptQueue *APTQueue) recordError(format string, a ...interface{}) {
@@ -171,30 +178,38 @@ func (aptQueue *APTQueue) recordError(format string, a ...interface{}) {
 }
 
 func (aptQueue *APTQueue) getNSQTopic(workItem *models.WorkItem) string {
-	config := aptQueue.Context.Config
-	topic := UNKNOWN_TOPIC
+	workerConfig := WorkerConfigFor(aptQueue.Context.Config, workItem)
+	if workerConfig == nil {
+		return UNKNOWN_TOPIC
+	}
+	return workerConfig.NsqTopic
+}
+
+// WorkerConfigFor returns the config of the worker that handles
+// workItem at its current action and stage, or nil if no worker
+// takes items like it from NSQ.
+func WorkerConfigFor(config *models.Config, workItem *models.WorkItem) *models.WorkerConfig {
 	if workItem.Action == constants.ActionIngest {
 		if workItem.Stage == constants.StageReceive {
-			topic = config.FetchWorker.NsqTopic
+			return &config.FetchWorker
 		} else if workItem.Stage == constants.StageStore {
-			topic = config.StoreWorker.NsqTopic
+			return &config.StoreWorker
 		} else if workItem.Stage == constants.StageRecord {
-			topic = config.RecordWorker.NsqTopic
+			return &config.RecordWorker
 		}
 	} else if workItem.Action == constants.ActionFixityCheck {
-		topic = config.FixityWorker.NsqTopic
+		return &config.FixityWorker
 	} else if workItem.Action == constants.ActionRestore {
 		if workItem.GenericFileIdentifier != "" {
-			topic = config.FileRestoreWorker.NsqTopic
-		} else {
-			topic = config.RestoreWorker.NsqTopic
+			return &config.FileRestoreWorker
 		}
+		return &config.RestoreWorker
 	} else if workItem.Action == constants.ActionGlacierRestore {
-		topic = config.GlacierRestoreWorker.NsqTopic
+		return &config.GlacierRestoreWorker
 	} else if workItem.Action == constants.ActionDelete {
-		topic = config.FileDeleteWorker.NsqTopic
+		return &config.FileDeleteWorker
 	}
-	return topic
+	return nil
 }
 
 func (aptQueue *APTQueue) GetStats() *stats.APTQueueStats {
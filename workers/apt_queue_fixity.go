@@ -8,7 +8,6 @@ import (
 	"github.com/APTrust/exchange/network"
 	"github.com/APTrust/exchange/util"
 	"net/url"
-	"strconv"
 	"time"
 )
 
@@ -65,9 +64,7 @@ func (aptQueue *APTQueueFixity) Run() {
 	params := url.Values{}
 	itemsAdded := 0
 	params.Set("not_checked_since", sinceWhen.Format(time.RFC3339))
-	params.Set("per_page", strconv.Itoa(perPage))
 	params.Set("storage_option", constants.StorageStandard)
-	params.Set("page", "1")
 	params.Set("sort", "last_fixity_check") // takes advantage of SQL index
 	if aptQueue.identifierLike != "" {
 		params.Set("identifier_like", aptQueue.identifierLike)
@@ -75,28 +72,24 @@ func (aptQueue *APTQueueFixity) Run() {
 			"Queuing only files whose identifier contains %s",
 			aptQueue.identifierLike)
 	}
-	for {
-		resp := aptQueue.Context.PharosClient.GenericFileList(params)
+	iterator := aptQueue.Context.PharosClient.GenericFileIterator(params, perPage)
+	iterator.OnPage(func(resp *network.PharosResponse) {
 		aptQueue.Context.MessageLog.Info("GET %s", resp.Request.URL)
-		if resp.Error != nil {
-			aptQueue.Context.MessageLog.Error(
-				"Error getting GenericFile list from Pharos: %s",
-				resp.Error)
+	})
+	for itemsAdded < aptQueue.maxFiles && iterator.Next() {
+		if aptQueue.addToNSQ(iterator.GenericFile()) {
+			itemsAdded += 1
 		}
-		for _, gf := range resp.GenericFiles() {
-			if aptQueue.addToNSQ(gf) {
-				itemsAdded += 1
-			}
-		}
-		if resp.HasNextPage() == false || itemsAdded >= aptQueue.maxFiles {
-			break
-		}
-		params = resp.ParamsForNextPage()
+	}
+	if iterator.Err() != nil {
+		aptQueue.Context.MessageLog.Error(
+			"Error getting GenericFile list from Pharos: %s",
+			iterator.Err())
 	}
 }
 
 func (aptQueue *APTQueueFixity) addToNSQ(gf *models.GenericFile) bool {
-	err := aptQueue.NSQClient.EnqueueString(aptQueue.nsqTopic, gf.Identifier)
+	err := aptQueue.NSQClient.EnqueueMessage(aptQueue.nsqTopic, models.NewFixityQueueMessage(gf.Identifier))
 	if err != nil {
 		aptQueue.Context.MessageLog.Error("Error sending '%s' to %s: %v",
 			gf.Identifier, aptQueue.nsqTopic, err)
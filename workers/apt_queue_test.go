@@ -0,0 +1,93 @@
+package workers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/APTrust/exchange/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// queueTestHandler serves the Pharos requests apt_queue makes. It
+// lists workItems, regardless of the query, so we can check that
+// apt_queue itself skips the ones it should.
+type queueTestHandler struct {
+	workItems []*models.WorkItem
+}
+
+func (h *queueTestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if strings.Contains(r.URL.Path, "/institutions/") {
+		institutionListHandler(w, r)
+		return
+	}
+	results := h.workItems
+	if strings.Contains(r.URL.Path, "update_batch") {
+		results = make([]*models.WorkItem, 0)
+		json.NewDecoder(r.Body).Decode(&results)
+	}
+	data := map[string]interface{}{"count": len(results), "results": results}
+	listJson, _ := json.Marshal(data)
+	fmt.Fprintln(w, string(listJson))
+}
+
+func TestAPTQueueSkipsItemsHeldForQuota(t *testing.T) {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+
+	ready := testutil.MakeWorkItem()
+	ready.Id = 1
+	ready.Action = constants.ActionIngest
+	ready.Stage = constants.StageReceive
+	ready.Status = constants.StatusPending
+	ready.Retry = true
+	ready.QueuedAt = nil
+	held := testutil.MakeWorkItem()
+	held.Id = 2
+	held.Action = constants.ActionIngest
+	held.Stage = constants.StageReceive
+	held.Status = constants.StatusPending
+	held.Outcome = constants.QuotaHeldOutcome
+	held.Retry = false
+	held.QueuedAt = nil
+	assert.True(t, held.IsHeldForQuota())
+	assert.False(t, ready.IsHeldForQuota())
+
+	pharosServer := httptest.NewServer(&queueTestHandler{
+		workItems: []*models.WorkItem{ready, held},
+	})
+	defer pharosServer.Close()
+	_context.PharosClient, err = network.NewPharosClient(pharosServer.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	mutex := &sync.Mutex{}
+	published := make([]string, 0)
+	nsqServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mutex.Lock()
+		published = append(published, string(body))
+		mutex.Unlock()
+		fmt.Fprint(w, "OK")
+	}))
+	defer nsqServer.Close()
+	_context.Config.NsqdHttpAddress = nsqServer.URL
+
+	aptQueue := workers.NewAPTQueue(_context, "", true, false)
+	aptQueue.Run()
+
+	require.Equal(t, 1, len(published))
+	message, err := models.ParseQueueMessage([]byte(published[0]))
+	require.Nil(t, err)
+	assert.Equal(t, ready.Id, message.WorkItemId)
+	assert.Empty(t, aptQueue.GetStats().Errors)
+}
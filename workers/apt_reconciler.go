@@ -0,0 +1,277 @@
+package workers
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// APTReconciler checks that every active GenericFile Pharos has on
+// record for an institution has objects of the right size in
+// long-term storage: one at the file's URI, and for files with the
+// Standard storage option, a replica in the Glacier replication
+// bucket. It issues only HEAD requests, so it never downloads content.
+//
+// For each problem it finds, it creates a WorkItem with action
+// Reconcile that needs admin review, unless an unresolved WorkItem
+// already describes the same problem. It prints one line for each
+// problem to STDOUT, and errors to STDERR. Files we can't check
+// because of network or Pharos errors are errors, not problems.
+type APTReconciler struct {
+	context       *context.Context
+	institution   string
+	concurrency   int
+	dryRun        bool
+	institutionId int
+	checked       int
+	discrepancies int
+	errOccurred   bool
+	mutex         *sync.Mutex
+}
+
+// NewAPTReconciler returns a new APTReconciler.
+// Param context is a context.Context object.
+// Param institution is the identifier of the institution whose
+// files we'll check. E.g. "virginia.edu". Param concurrency is the
+// number of files to check simultaneously. It defaults to 4. Max
+// is 32. If param dryRun is true, the reconciler prints the problems
+// it finds without creating WorkItems.
+func NewAPTReconciler(context *context.Context, institution string, concurrency int, dryRun bool) (*APTReconciler, error) {
+	if context == nil {
+		return nil, fmt.Errorf("Param context cannot be nil")
+	}
+	if institution == "" {
+		return nil, fmt.Errorf("Param institution cannot be empty")
+	}
+	if concurrency > 32 {
+		return nil, fmt.Errorf("Param concurrency can be no higher than 32")
+	}
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	return &APTReconciler{
+		context:     context,
+		institution: institution,
+		concurrency: concurrency,
+		dryRun:      dryRun,
+		mutex:       &sync.Mutex{},
+	}, nil
+}
+
+// Run checks the institution's files and returns the number of files
+// checked, the number of problems found, and an error if it could
+// not complete the job. Check the STDERR log for details if Run
+// returns an error.
+func (reconciler *APTReconciler) Run() (int, int, error) {
+	resp := reconciler.context.PharosClient.InstitutionGet(reconciler.institution)
+	if resp.Error != nil {
+		return 0, 0, fmt.Errorf("Error getting institution %s from Pharos: %v",
+			reconciler.institution, resp.Error)
+	}
+	institution := resp.Institution()
+	if institution == nil {
+		return 0, 0, fmt.Errorf("Pharos has no institution %s", reconciler.institution)
+	}
+	reconciler.institutionId = institution.Id
+
+	params := url.Values{}
+	params.Set("institution_identifier", reconciler.institution)
+	params.Set("state", "A")
+	params.Set("per_page", "100")
+	params.Set("page", "1")
+	for {
+		resp := reconciler.context.PharosClient.GenericFileList(params)
+		if resp.Error != nil {
+			fmt.Fprintln(os.Stderr, "Error getting GenericFile list from Pharos:", resp.Error)
+			return reconciler.checked, reconciler.discrepancies, resp.Error
+		}
+		reconciler.reconcileBatch(resp.GenericFiles())
+		if resp.HasNextPage() == false {
+			break
+		}
+		params = resp.ParamsForNextPage()
+	}
+	var err error
+	if reconciler.errOccurred {
+		err = fmt.Errorf("One or more files could not be checked. See STDERR for details.")
+	}
+	return reconciler.checked, reconciler.discrepancies, err
+}
+
+// reconcileBatch checks a batch of files, running up to
+// reconciler.concurrency checks at once.
+func (reconciler *APTReconciler) reconcileBatch(files []*models.GenericFile) {
+	wg := sync.WaitGroup{}
+	semaphore := make(chan struct{}, reconciler.concurrency)
+	for _, gf := range files {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(gf *models.GenericFile) {
+			defer wg.Done()
+			reconciler.reconcileOne(gf)
+			<-semaphore
+		}(gf)
+	}
+	wg.Wait()
+}
+
+// reconcileOne checks the stored copies of a single file.
+func (reconciler *APTReconciler) reconcileOne(gf *models.GenericFile) {
+	bucket, key, err := gf.StorageBucketAndKey()
+	if err != nil {
+		reconciler.addDiscrepancy(gf, "", err.Error(), "")
+		reconciler.countChecked()
+		return
+	}
+	storageOption := gf.StorageOption
+	if storageOption == "" {
+		storageOption = constants.StorageStandard
+	}
+	region, _, err := reconciler.context.Config.StorageRegionAndBucketFor(storageOption)
+	if err != nil {
+		reconciler.printError(gf.Identifier, err.Error())
+		return
+	}
+	reconciler.checkCopy(gf, region, bucket, key)
+	if storageOption == constants.StorageStandard {
+		reconciler.checkCopy(gf, reconciler.context.Config.APTrustGlacierRegion,
+			reconciler.context.Config.ReplicationBucket, key)
+	}
+	reconciler.countChecked()
+}
+
+// checkCopy checks that the object at bucket/key exists and is
+// the same size as gf.
+func (reconciler *APTReconciler) checkCopy(gf *models.GenericFile, region, bucket, key string) {
+	client := network.NewS3Head(
+		os.Getenv("AWS_ACCESS_KEY_ID"),
+		os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		region, bucket)
+	client.SessionPool = reconciler.context.S3SessionPool
+	client.Head(key)
+	if client.StatusCode == http.StatusNotFound {
+		reconciler.addDiscrepancy(gf, bucket,
+			fmt.Sprintf("Object %s is missing from %s", key, bucket), "")
+		return
+	}
+	if client.ErrorMessage != "" {
+		reconciler.printError(gf.Identifier, client.ErrorMessage)
+		return
+	}
+	storedFile := client.StoredFile()
+	if storedFile.Size != gf.Size {
+		reconciler.addDiscrepancy(gf, bucket,
+			fmt.Sprintf("Object %s in %s is %d bytes, but Pharos says %d",
+				key, bucket, storedFile.Size, gf.Size),
+			storedFile.ETag)
+	}
+}
+
+// addDiscrepancy prints a problem with gf and, unless this is a dry
+// run or an unresolved WorkItem already describes it, records it in
+// a new WorkItem.
+func (reconciler *APTReconciler) addDiscrepancy(gf *models.GenericFile, bucket, problem, etag string) {
+	workItemId := 0
+	if !reconciler.dryRun {
+		workItem := NewReconciliationWorkItem(gf, reconciler.institutionId, bucket, problem, etag)
+		existing, err := reconciler.findWorkItem(workItem)
+		if err != nil {
+			reconciler.printError(gf.Identifier, err.Error())
+			return
+		}
+		if existing != nil {
+			workItemId = existing.Id
+		} else {
+			resp := reconciler.context.PharosClient.WorkItemSave(workItem)
+			if resp.Error != nil {
+				reconciler.printError(gf.Identifier,
+					fmt.Sprintf("Error creating WorkItem for '%s': %v", problem, resp.Error))
+				return
+			}
+			workItemId = resp.WorkItem().Id
+		}
+	}
+	reconciler.mutex.Lock()
+	defer reconciler.mutex.Unlock()
+	reconciler.discrepancies += 1
+	fmt.Printf("%s\t%s\t%d\n", gf.Identifier, problem, workItemId)
+}
+
+// findWorkItem returns the unresolved Reconcile WorkItem that
+// describes the same problem with the same file as workItem,
+// or nil if there isn't one.
+func (reconciler *APTReconciler) findWorkItem(workItem *models.WorkItem) (*models.WorkItem, error) {
+	params := url.Values{}
+	params.Set("item_action", constants.ActionReconcile)
+	params.Set("generic_file_identifier", workItem.GenericFileIdentifier)
+	params.Set("page", "1")
+	params.Set("per_page", "100")
+	for {
+		resp := reconciler.context.PharosClient.WorkItemList(params)
+		if resp.Error != nil {
+			return nil, fmt.Errorf("Error getting WorkItem list from Pharos: %v", resp.Error)
+		}
+		for _, item := range resp.WorkItems() {
+			if item.GenericFileIdentifier == workItem.GenericFileIdentifier &&
+				item.Note == workItem.Note && item.NeedsAdminReview {
+				return item, nil
+			}
+		}
+		if resp.HasNextPage() == false {
+			break
+		}
+		params = resp.ParamsForNextPage()
+	}
+	return nil, nil
+}
+
+func (reconciler *APTReconciler) countChecked() {
+	reconciler.mutex.Lock()
+	defer reconciler.mutex.Unlock()
+	reconciler.checked += 1
+}
+
+// printError prints an error for a file we could not check.
+func (reconciler *APTReconciler) printError(gfIdentifier, message string) {
+	reconciler.mutex.Lock()
+	defer reconciler.mutex.Unlock()
+	fmt.Fprintln(os.Stderr, "[", gfIdentifier, "]", message)
+	reconciler.errOccurred = true
+}
+
+// NewReconciliationWorkItem returns a new WorkItem describing a
+// problem with the stored copy of gf in bucket. The WorkItem needs
+// admin review, and apt_queue will never queue it. Param etag is
+// the ETag of the stored copy, if it exists.
+func NewReconciliationWorkItem(gf *models.GenericFile, institutionId int, bucket, problem, etag string) *models.WorkItem {
+	name := gf.URI
+	if _, key, err := gf.StorageBucketAndKey(); err == nil {
+		name = key
+	}
+	return &models.WorkItem{
+		Name:                  name,
+		Bucket:                bucket,
+		ETag:                  etag,
+		Size:                  gf.Size,
+		BagDate:               gf.FileModified,
+		InstitutionId:         institutionId,
+		ObjectIdentifier:      gf.IntellectualObjectIdentifier,
+		GenericFileIdentifier: gf.Identifier,
+		User:                  constants.APTrustSystemUser,
+		Date:                  time.Now().UTC(),
+		Note:                  problem,
+		Action:                constants.ActionReconcile,
+		Stage:                 constants.StageResolve,
+		Status:                constants.StatusFailed,
+		Outcome:               "Pharos record does not match storage",
+		Retry:                 false,
+		NeedsAdminReview:      true,
+	}
+}
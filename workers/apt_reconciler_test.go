@@ -0,0 +1,59 @@
+package workers_test
+
+import (
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/APTrust/exchange/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNewAPTReconciler(t *testing.T) {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+
+	reconciler, err := workers.NewAPTReconciler(_context, "test.edu", 4, false)
+	assert.Nil(t, err)
+	assert.NotNil(t, reconciler)
+	reconciler, err = workers.NewAPTReconciler(_context, "test.edu", 0, true)
+	assert.Nil(t, err)
+	assert.NotNil(t, reconciler)
+
+	_, err = workers.NewAPTReconciler(nil, "test.edu", 4, false)
+	assert.NotNil(t, err)
+	_, err = workers.NewAPTReconciler(_context, "", 4, false)
+	assert.NotNil(t, err)
+	_, err = workers.NewAPTReconciler(_context, "test.edu", 33, false)
+	assert.NotNil(t, err)
+}
+
+func TestNewReconciliationWorkItem(t *testing.T) {
+	gf := testutil.MakeGenericFile(0, 0, "test.edu/bag")
+	gf.URI = "https://s3.amazonaws.com/aptrust.test.preservation/52df25e3-8c7b-4a2a-9c55-2b0b6b1b2a9e"
+	gf.FileModified = time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	problem := "Object 52df25e3-8c7b-4a2a-9c55-2b0b6b1b2a9e is missing from aptrust.test.preservation"
+	workItem := workers.NewReconciliationWorkItem(gf, 12, "aptrust.test.preservation", problem, "")
+	assert.Equal(t, "52df25e3-8c7b-4a2a-9c55-2b0b6b1b2a9e", workItem.Name)
+	assert.Equal(t, "aptrust.test.preservation", workItem.Bucket)
+	assert.Equal(t, "", workItem.ETag)
+	assert.Equal(t, gf.Size, workItem.Size)
+	assert.Equal(t, gf.FileModified, workItem.BagDate)
+	assert.Equal(t, 12, workItem.InstitutionId)
+	assert.Equal(t, gf.IntellectualObjectIdentifier, workItem.ObjectIdentifier)
+	assert.Equal(t, gf.Identifier, workItem.GenericFileIdentifier)
+	assert.Equal(t, constants.APTrustSystemUser, workItem.User)
+	assert.Equal(t, problem, workItem.Note)
+	assert.Equal(t, constants.ActionReconcile, workItem.Action)
+	assert.Equal(t, constants.StageResolve, workItem.Stage)
+	assert.Equal(t, constants.StatusFailed, workItem.Status)
+	assert.False(t, workItem.Retry)
+	assert.True(t, workItem.NeedsAdminReview)
+	assert.False(t, workItem.Date.IsZero())
+
+	// Files with bad URIs still get a WorkItem.
+	gf.URI = "not a url"
+	workItem = workers.NewReconciliationWorkItem(gf, 12, "", "GenericFile URI 'not a url' is invalid", "")
+	assert.Equal(t, "not a url", workItem.Name)
+}
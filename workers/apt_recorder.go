@@ -8,18 +8,12 @@ import (
 	"github.com/APTrust/exchange/network"
 	"github.com/APTrust/exchange/util/storage"
 	"github.com/nsqio/go-nsq"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 )
 
-const (
-	// GENERIC_FILE_BATCH_SIZE describes how many generic files
-	// we should batch into a single HTTP POST when recording a
-	// new IntellectualObject.
-	GENERIC_FILE_BATCH_SIZE = 100
-)
-
 // Records ingest data (objects, files and events) in Pharos
 type APTRecorder struct {
 	Context        *context.Context
@@ -74,6 +68,12 @@ func (recorder *APTRecorder) HandleMessage(message *nsq.Message) error {
 	// whatever may have failed on the last run.
 	ingestState.IngestManifest.RecordResult.ClearErrors()
 
+	// Make sure the WorkItem, object and bucket all belong to
+	// the same institution before we write anything.
+	if !AssertIngestInstitution(ingestState, recorder.Context, ingestState.IngestManifest.RecordResult) {
+		return nil
+	}
+
 	// Tell Pharos that we've started to record this item.
 	err = MarkWorkItemStarted(ingestState, recorder.Context,
 		constants.StageRecord, "Recording object, file and event metadata in Pharos.")
@@ -135,6 +135,7 @@ func (recorder *APTRecorder) cleanup() {
 			}
 
 			MarkWorkItemSucceeded(ingestState, recorder.Context, constants.StageCleanup)
+			recorder.sendIngestReceipt(ingestState)
 			ingestState.FinishNSQ()
 		}
 
@@ -206,12 +207,130 @@ func (recorder *APTRecorder) saveAllPharosData(ingestState *models.IngestState)
 	}
 
 	recorder.saveFiles(ingestState, obj, db)
+	if ingestState.IngestManifest.RecordResult.HasErrors() {
+		return
+	}
+	recorder.verifyRecordedFiles(ingestState, obj, db)
+	if ingestState.IngestManifest.RecordResult.HasErrors() {
+		return
+	}
+	recorder.buildIngestReceipt(ingestState, obj, db)
+}
+
+// buildIngestReceipt builds the depositor's ingest receipt, if their
+// institution gets receipts. We send it after cleanup, once the
+// ingest has succeeded.
+func (recorder *APTRecorder) buildIngestReceipt(ingestState *models.IngestState, obj *models.IntellectualObject, db *storage.BoltDB) {
+	if !recorder.Context.Config.SendsIngestReceiptTo(obj.Institution) {
+		return
+	}
+	if ingestState.IngestManifest.Receipt != nil && ingestState.IngestManifest.Receipt.WasSent() {
+		return
+	}
+	receipt := models.NewIngestReceipt(obj, ingestState.WorkItem.Id, ingestState.WorkItem.User)
+	for _, gfIdentifier := range db.FileIdentifiers() {
+		gf, err := db.GetGenericFile(gfIdentifier)
+		if err != nil {
+			ingestState.IngestManifest.RecordResult.AddError(err.Error())
+			return
+		}
+		receipt.AddFile(gf)
+	}
+	ingestState.IngestManifest.Receipt = receipt
+}
+
+// sendIngestReceipt asks Pharos to email the ingest receipt to the
+// depositor. The bag is already safely ingested, so if Pharos can't
+// send the receipt, we log a warning rather than failing the WorkItem.
+func (recorder *APTRecorder) sendIngestReceipt(ingestState *models.IngestState) {
+	receipt := ingestState.IngestManifest.Receipt
+	if receipt == nil || receipt.WasSent() {
+		return
+	}
+	resp := recorder.Context.PharosClient.IngestReceiptSend(receipt)
+	if resp.Error != nil {
+		recorder.Context.MessageLog.Warning(
+			"Error sending ingest receipt for WorkItem %d (%s) to %s: %v",
+			receipt.WorkItemId, receipt.ObjectIdentifier, receipt.Recipient, resp.Error)
+		return
+	}
+	receipt.SentAt = time.Now().UTC()
+	recorder.Context.MessageLog.Info("Sent ingest receipt for WorkItem %d (%s) to %s",
+		receipt.WorkItemId, receipt.ObjectIdentifier, receipt.Recipient)
+}
+
+// verifyRecordedFiles is the last step of recording. It makes sure
+// that Pharos has an active record for every payload file in the
+// validated bag. Pharos may report success on a batch save without
+// saving every file, and before we had this check, the missing files
+// came to light only when a depositor tried to restore the object.
+// Missing files are not a fatal error, since recording again may
+// fix them, but if they're still missing after the last attempt,
+// the WorkItem fails instead of going on to cleanup.
+func (recorder *APTRecorder) verifyRecordedFiles(ingestState *models.IngestState, obj *models.IntellectualObject, db *storage.BoltDB) {
+	expected := make([]string, 0)
+	for _, gfIdentifier := range db.FileIdentifiers() {
+		gf, err := db.GetGenericFile(gfIdentifier)
+		if err != nil {
+			ingestState.IngestManifest.RecordResult.AddError(err.Error())
+			return
+		}
+		if gf.IngestFileType == constants.PAYLOAD_FILE {
+			expected = append(expected, gf.Identifier)
+		}
+	}
+	recorded := make([]*models.GenericFile, 0)
+	params := url.Values{}
+	params.Set("intellectual_object_identifier", obj.Identifier)
+	params.Set("state", "A")
+	params.Set("per_page", "100")
+	params.Set("page", "1")
+	for {
+		resp := recorder.Context.PharosClient.GenericFileList(params)
+		if resp.Error != nil {
+			ingestState.IngestManifest.RecordResult.AddError(
+				"Cannot get files for %s from Pharos to verify the ingest: %v",
+				obj.Identifier, resp.Error)
+			return
+		}
+		recorded = append(recorded, resp.GenericFiles()...)
+		if resp.HasNextPage() == false {
+			break
+		}
+		params = resp.ParamsForNextPage()
+	}
+	missing := UnrecordedFiles(expected, recorded)
+	if len(missing) > 0 {
+		recorder.logMissingFiles(ingestState, len(expected), missing)
+		return
+	}
+	recorder.Context.MessageLog.Info("Verified that Pharos has all %d payload files of %s",
+		len(expected), obj.Identifier)
+}
+
+// UnrecordedFiles returns the identifiers in expected that
+// do not belong to any of the recorded GenericFiles.
+func UnrecordedFiles(expected []string, recorded []*models.GenericFile) []string {
+	recordedIdentifiers := make(map[string]bool, len(recorded))
+	for _, gf := range recorded {
+		if gf != nil {
+			recordedIdentifiers[gf.Identifier] = true
+		}
+	}
+	missing := make([]string, 0)
+	for _, identifier := range expected {
+		if !recordedIdentifiers[identifier] {
+			missing = append(missing, identifier)
+		}
+	}
+	return missing
 }
 
 func (recorder *APTRecorder) saveFiles(ingestState *models.IngestState, obj *models.IntellectualObject, db *storage.BoltDB) {
+	batchSize := recorder.batchSize()
 	offset := 0
 	for {
-		batch := db.FileIdentifierBatch(offset, GENERIC_FILE_BATCH_SIZE)
+		batch := db.FileIdentifierBatch(offset, batchSize)
 		newFiles := make([]*models.GenericFile, 0)
 		existingFiles := make([]*models.GenericFile, 0)
 		for _, gfIdentifier := range batch {
@@ -227,6 +346,13 @@ func (recorder *APTRecorder) saveFiles(ingestState *models.IngestState, obj *mod
 			recorder.buildGenericFileChecksums(gf, ingestState)
 			recorder.buildGenericFileEvents(gf, ingestState)
 
+			// Pharos fills in defaults for these if we leave them
+			// empty, and its fixity scheduling depends on both.
+			if gf.StorageOption == "" {
+				gf.StorageOption = obj.StorageOption
+			}
+			gf.UpdateLastFixityCheck()
+
 			// Prior to 2019-10-14, files with non-zero ids where
 			// IngestPreviousVersionExists was false were incorrectly
 			// being put into newFiles. Non-zero Id with no previous
@@ -254,7 +380,7 @@ func (recorder *APTRecorder) saveFiles(ingestState *models.IngestState, obj *mod
 		recorder.saveGenericFilesInBoltDB(ingestState, db, existingFiles)
 
 		offset += len(batch)
-		if len(batch) < GENERIC_FILE_BATCH_SIZE {
+		if len(batch) < batchSize {
 			break
 		}
 	}
@@ -290,10 +416,22 @@ func (recorder *APTRecorder) saveIntellectualObject(ingestState *models.IngestSt
 			"Pharos returned nil IntellectualObject after save.")
 		return
 	}
-	// For obj saved in BoltDB
-	obj.Id = savedObject.Id
-	obj.CreatedAt = savedObject.CreatedAt
-	obj.UpdatedAt = savedObject.UpdatedAt
+	// For obj saved in BoltDB. Merge picks up the Id and timestamps
+	// Pharos assigned, and tells us if Pharos changed anything else.
+	report, err := obj.Merge(savedObject)
+	if err != nil {
+		ingestState.IngestManifest.RecordResult.AddError(
+			"Cannot merge saved IntellectualObject: %v", err)
+		return
+	}
+	for _, conflict := range report.Conflicts {
+		recorder.Context.MessageLog.Warning("Pharos record for %s differs from ours: %s",
+			obj.Identifier, conflict.String())
+	}
+	for _, mergeErr := range report.Errors {
+		recorder.Context.MessageLog.Warning("Merging saved IntellectualObject %s: %v",
+			obj.Identifier, mergeErr)
+	}
 
 	// For logging, since we log from ingestState below
 	ingestState.IngestManifest.Object.Id = savedObject.Id
@@ -303,40 +441,32 @@ func (recorder *APTRecorder) saveIntellectualObject(ingestState *models.IngestSt
 	recorder.savePremisEventsForObject(ingestState, obj)
 }
 
-// createGenericFiles creates new GenericFile records in Pharos
+// createGenericFiles creates new GenericFile records in Pharos in a
+// single request. saveFiles calls this with one batch of files at a
+// time. If some files are not saved, this adds one error to the
+// RecordResult listing all of them, so a failed batch doesn't use up
+// the WorkSummary's error limit.
 func (recorder *APTRecorder) createGenericFiles(ingestState *models.IngestState, files []*models.GenericFile) {
 	if len(files) == 0 {
 		return
 	}
-	fileMap := make(map[string]*models.GenericFile, len(files))
-	identifiers := make([]string, len(files))
-	for i, gf := range files {
-		fileMap[gf.Identifier] = gf
-		identifiers[i] = gf.Identifier
-	}
-	resp := recorder.Context.PharosClient.GenericFileSaveBatch(files)
-	if resp.Error != nil {
-		body, _ := resp.RawResponseData()
-		recorder.Context.MessageLog.Error(
-			"Pharos returned this after attempt to save batch of GenericFiles:\n%s",
-			string(body))
-		recorder.Context.MessageLog.Error(
-			"File identifiers in failed batch:\b%s", strings.Join(identifiers, ", "))
-		ingestState.IngestManifest.RecordResult.AddError(resp.Error.Error())
-	}
-	// We may have managed to save some files despite the error.
-	// If so, record what was saved.
-	for _, savedFile := range resp.GenericFiles() {
-		if savedFile == nil {
-			// PT #157398417
-			// This happens after GenericFileSaveBatch returns an error.
-			recorder.Context.MessageLog.Warning("Nil GenericFile from resp.GenericFiles()")
-			continue
-		}
-		gf := fileMap[savedFile.Identifier]
-		if gf == nil {
-			ingestState.IngestManifest.RecordResult.AddError("After save, could not find file '%s' "+
-				"in batch.", savedFile.Identifier)
+	results := recorder.Context.PharosClient.GenericFileSaveBatchResults(files)
+	var firstError error
+	failed := make([]string, 0)
+	for _, result := range results {
+		if result.Error != nil {
+			if firstError == nil {
+				firstError = result.Error
+				if result.Response != nil {
+					body, _ := result.Response.RawResponseData()
+					recorder.Context.MessageLog.Error(
+						"Pharos returned this after attempt to save batch of GenericFiles:\n%s",
+						string(body))
+				}
+			}
+			recorder.Context.MessageLog.Error("Error creating '%s': %v",
+				result.GenericFile.Identifier, result.Error)
+			failed = append(failed, result.GenericFile.Identifier)
 			continue
 		}
 		// Merge attributes set by Pharos into our GenericFile record.
@@ -344,11 +474,25 @@ func (recorder *APTRecorder) createGenericFiles(ingestState *models.IngestState,
 		// and all of its Checksums and PremisEvents. This also
 		// propagates the new GenericFile.Id down to the PremisEvents
 		// and Checksums.
-		errors := gf.MergeAttributes(savedFile)
+		errors := result.GenericFile.MergeAttributes(result.Saved)
 		for _, err := range errors {
 			ingestState.IngestManifest.RecordResult.AddError(err.Error())
 		}
 	}
+	if len(failed) > 0 {
+		ingestState.IngestManifest.RecordResult.AddError(
+			"Error creating %d of %d GenericFiles in batch: %v. Files not created: %s",
+			len(failed), len(files), firstError, strings.Join(failed, ", "))
+	}
+}
+
+// batchSize returns the number of GenericFiles saveFiles reads from
+// the valdb and sends to Pharos in each batch.
+func (recorder *APTRecorder) batchSize() int {
+	if recorder.Context.Config.RecordWorker.PharosBatchSize > 0 {
+		return recorder.Context.Config.RecordWorker.PharosBatchSize
+	}
+	return network.DefaultGenericFileBatchSize
 }
 
 // updateGenericFiles updates existing GenericFile records in Pharos
@@ -610,6 +754,22 @@ func (recorder *APTRecorder) logNoNeedToSave(ingestState *models.IngestState) {
 		ingestState.IngestManifest.Object.Id)
 }
 
+func (recorder *APTRecorder) logMissingFiles(ingestState *models.IngestState, expectedCount int, missing []string) {
+	// The list of missing files can be long. Put all of it in the
+	// log, and the first few in the WorkItem note.
+	recorder.Context.MessageLog.Error(
+		"After recording WorkItem %d (%s/%s), Pharos is missing %d of %d payload files: %s",
+		ingestState.WorkItem.Id, ingestState.WorkItem.Bucket, ingestState.WorkItem.Name,
+		len(missing), expectedCount, strings.Join(missing, ", "))
+	shown := missing
+	if len(shown) > 10 {
+		shown = shown[:10]
+	}
+	ingestState.IngestManifest.RecordResult.AddError(
+		"Pharos is missing %d of %d payload files after recording, including %s",
+		len(missing), expectedCount, strings.Join(shown, ", "))
+}
+
 func (recorder *APTRecorder) logMissingId(ingestState *models.IngestState, gf *models.GenericFile) {
 	msg := fmt.Sprintf("GenericFile %s has a previous version, but its Id is missing.",
 		gf.Identifier)
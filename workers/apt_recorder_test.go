@@ -1,6 +1,7 @@
 package workers_test
 
 import (
+	"github.com/APTrust/exchange/models"
 	"github.com/APTrust/exchange/util/testutil"
 	"github.com/APTrust/exchange/workers"
 	"github.com/stretchr/testify/assert"
@@ -44,3 +45,22 @@ func TestCloneWithoutSavedChildren(t *testing.T) {
 	assert.Equal(t, 0, len(secondClone.Checksums))
 
 }
+
+func TestUnrecordedFiles(t *testing.T) {
+	expected := []string{
+		"test.edu/bag/data/file1.txt",
+		"test.edu/bag/data/file2.txt",
+		"test.edu/bag/data/file3.txt",
+	}
+	recorded := []*models.GenericFile{
+		{Identifier: "test.edu/bag/data/file1.txt"},
+		{Identifier: "test.edu/bag/data/file3.txt"},
+		// Files from earlier versions of the bag don't matter.
+		{Identifier: "test.edu/bag/data/old_file.txt"},
+		nil,
+	}
+	assert.Equal(t, []string{"test.edu/bag/data/file2.txt"}, workers.UnrecordedFiles(expected, recorded))
+
+	recorded = append(recorded, &models.GenericFile{Identifier: "test.edu/bag/data/file2.txt"})
+	assert.Empty(t, workers.UnrecordedFiles(expected, recorded))
+}
@@ -1,6 +1,7 @@
 package workers
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/APTrust/exchange/constants"
@@ -47,6 +48,13 @@ type APTRestorer struct {
 	// config directory. It describes what constitutes a valid
 	// APTrust bag.
 	BagValidationConfig *validation.BagValidationConfig
+	// CheckpointInterval is the minimum time between saves of
+	// a RestoreState's Checkpoint to Pharos during packaging.
+	CheckpointInterval time.Duration
+	// Globus delivers bags to depositors who receive them through
+	// Globus instead of S3. It's nil if Config.GlobusDestinations
+	// is empty.
+	Globus *GlobusDelivery
 }
 
 func NewAPTRestorer(_context *context.Context) *APTRestorer {
@@ -59,9 +67,35 @@ func NewAPTRestorer(_context *context.Context) *APTRestorer {
 	if err != nil {
 		panic(fmt.Sprintf("Cannot cache bucket names from Pharos: %v", err))
 	}
+	err = _context.Config.EnsureRestorationBuckets()
+	if err != nil {
+		panic(fmt.Sprintf("Invalid restoration bucket config: %v", err))
+	}
+	err = _context.Config.EnsureInstitutionOverrides()
+	if err != nil {
+		panic(fmt.Sprintf("Invalid institution overrides: %v", err))
+	}
 
 	restorer.BagValidationConfig = LoadAPTrustBagValidationConfig(restorer.Context)
 
+	restorer.CheckpointInterval = 5 * time.Minute
+	if _context.Config.RestoreCheckpointInterval != "" {
+		interval, err := time.ParseDuration(_context.Config.RestoreCheckpointInterval)
+		if err != nil {
+			panic(fmt.Sprintf("Invalid RestoreCheckpointInterval '%s': %v",
+				_context.Config.RestoreCheckpointInterval, err))
+		}
+		restorer.CheckpointInterval = interval
+	}
+
+	if len(_context.Config.GlobusDestinations) > 0 {
+		err = _context.Config.EnsureGlobusConfig()
+		if err != nil {
+			panic(fmt.Sprintf("Invalid Globus config: %v", err))
+		}
+		restorer.Globus = NewGlobusDelivery(_context.Config)
+	}
+
 	// Set up buffered channels
 	workerBufferSize := _context.Config.RestoreWorker.Workers * 10
 	restorer.PackageChannel = make(chan *models.RestoreState, workerBufferSize)
@@ -107,6 +141,13 @@ func (restorer *APTRestorer) HandleMessage(message *nsq.Message) error {
 	// that we're still working on this item.
 	message.DisableAutoResponse()
 
+	// Make sure the WorkItem, object and restoration bucket all
+	// belong to the same institution before we restore anything.
+	if !restorer.assertInstitution(restoreState) {
+		restorer.PostProcessChannel <- restoreState
+		return nil
+	}
+
 	// Tell Pharos that we're building the bag: constants.StagePackage, constants.StatusStarted
 	restorer.Context.MessageLog.Info("Marking %s as started", restoreState.WorkItem.ObjectIdentifier)
 	restorer.markWorkItemStarted(restoreState)
@@ -148,6 +189,17 @@ func (restorer *APTRestorer) buildBag() {
 		restoreState.PackageSummary.AttemptNumber += 1
 		restoreState.PackageSummary.Start()
 
+		// Don't restore objects under embargo without approval.
+		reason := EmbargoCancelReason(restoreState.IntellectualObject,
+			restoreState.WorkItem, time.Now().UTC())
+		if reason != "" {
+			restoreState.CancelReason = reason
+			restoreState.PackageSummary.AddError(reason)
+			restoreState.PackageSummary.ErrorIsFatal = true
+			restorer.PostProcessChannel <- restoreState
+			continue
+		}
+
 		// Download all of the IntellectualObject's files to the
 		// local bag directory.
 		restorer.fetchAllFiles(restoreState)
@@ -155,23 +207,38 @@ func (restorer *APTRestorer) buildBag() {
 			restorer.PostProcessChannel <- restoreState
 			continue
 		}
+		if restoreState.HasExpiredGlacierFiles() {
+			restorer.requeueForGlacierRetrieval(restoreState)
+			continue
+		}
 		restoreState.TouchNSQ()
 
-		// Write info files and  md5 and sha256 manifests
-		restorer.writeAPTrustInfoFile(restoreState)
-		restorer.writeBagitFile(restoreState)
-		restorer.writeBagInfoFile(restoreState)
-		restorer.WritePremisEventFile(restoreState)
-		restorer.writeManifest(constants.PAYLOAD_MANIFEST, constants.AlgMd5, restoreState)
-		restorer.writeManifest(constants.PAYLOAD_MANIFEST, constants.AlgSha256, restoreState)
-		restorer.writeManifest(constants.TAG_MANIFEST, constants.AlgMd5, restoreState)
-		restorer.writeManifest(constants.TAG_MANIFEST, constants.AlgSha256, restoreState)
-
-		// Now that the heavy work is done, see if any errors
-		// occured anywhere along the line.
-		if restoreState.PackageSummary.HasErrors() {
-			restorer.PostProcessChannel <- restoreState
-			continue
+		// Write info files and  md5 and sha256 manifests, unless
+		// we wrote them on a prior run. In that case, some of them
+		// may already be in the partial tar file, and regenerating
+		// them would change Bagging-Date and the manifest digests.
+		if restorer.tagFilesWereWritten(restoreState) {
+			restorer.Context.MessageLog.Info("Tag files and manifests for %s were "+
+				"written on a prior run", restoreState.WorkItem.ObjectIdentifier)
+		} else {
+			restoreState.Checkpoint.ResetTar()
+			restorer.writeAPTrustInfoFile(restoreState)
+			restorer.writeBagitFile(restoreState)
+			restorer.writeBagInfoFile(restoreState)
+			restorer.WritePremisEventFile(restoreState)
+			restorer.writeManifest(constants.PAYLOAD_MANIFEST, constants.AlgMd5, restoreState)
+			restorer.writeManifest(constants.PAYLOAD_MANIFEST, constants.AlgSha256, restoreState)
+			restorer.writeManifest(constants.TAG_MANIFEST, constants.AlgMd5, restoreState)
+			restorer.writeManifest(constants.TAG_MANIFEST, constants.AlgSha256, restoreState)
+
+			// Now that the heavy work is done, see if any errors
+			// occured anywhere along the line.
+			if restoreState.PackageSummary.HasErrors() {
+				restorer.PostProcessChannel <- restoreState
+				continue
+			}
+			restoreState.Checkpoint.TagFilesWritten = true
+			restorer.saveCheckpoint(restoreState, true)
 		}
 		restoreState.TouchNSQ()
 
@@ -185,6 +252,7 @@ func (restorer *APTRestorer) buildBag() {
 
 		// Done with packaging. On to validation...
 		restoreState.PackageSummary.Finish()
+		restorer.saveCheckpoint(restoreState, true)
 		restorer.Context.MessageLog.Info("Putting %s into the validation channel",
 			restoreState.WorkItem.ObjectIdentifier)
 		restorer.ValidateChannel <- restoreState
@@ -207,6 +275,8 @@ func (restorer *APTRestorer) validateBag() {
 			// Validation can take a long time for large bags.
 			restorer.Context.MessageLog.Info("Validating %s", restoreState.LocalTarFile)
 			validator.ObjIdentifier = restoreState.WorkItem.ObjectIdentifier
+			validator.Progress = ValidationProgressLogger(restorer.Context,
+				restoreState.LocalTarFile, restoreState.TouchNSQ, time.Minute)
 			summary, err := validator.Validate()
 			restorer.Context.MessageLog.Info("Finished validating %s", restoreState.LocalTarFile)
 			if err != nil {
@@ -216,8 +286,8 @@ func (restorer *APTRestorer) validateBag() {
 				summary.AddError(err.Error())
 				summary.FinishedAt = time.Now().UTC()
 			} else if summary != nil && summary.HasErrors() {
-				for _, errMsg := range summary.Errors {
-					restoreState.ValidateSummary.AddError("Validation error: %s", errMsg)
+				for _, workError := range summary.Errors {
+					restoreState.ValidateSummary.AddError("Validation error: %s", workError.Message)
 				}
 				restoreState.ValidateSummary = summary
 			}
@@ -242,7 +312,7 @@ func (restorer *APTRestorer) copyToRestorationBucket() {
 		restoreState.CopySummary.Attempted = true
 		restoreState.CopySummary.AttemptNumber += 1
 		restoreState.CopySummary.Start()
-		restorer.uploadBag(restoreState)
+		restorer.deliverBag(restoreState)
 		restoreState.CopySummary.Finish()
 		restorer.PostProcessChannel <- restoreState
 	}
@@ -387,6 +457,7 @@ func (restorer *APTRestorer) deleteFile(restoreState *models.RestoreState, filen
 		} else {
 			if filename == restoreState.LocalTarFile {
 				restoreState.TarFileDeletedAt = time.Now().UTC()
+				restoreState.Checkpoint.ResetTar()
 			}
 		}
 	}
@@ -402,14 +473,68 @@ func (restorer *APTRestorer) deleteBagDir(restoreState *models.RestoreState) {
 			restorer.Context.MessageLog.Error(message)
 		} else {
 			restoreState.BagDirDeletedAt = time.Now().UTC()
+			restoreState.Checkpoint.Reset()
+		}
+	}
+}
+
+// deliverBag sends the restored bag to the depositor's Globus
+// collection, if the depositor has one, or else to the depositor's
+// restoration bucket.
+func (restorer *APTRestorer) deliverBag(restoreState *models.RestoreState) {
+	if restorer.Globus != nil {
+		destination := restorer.Globus.DestinationFor(restoreState.IntellectualObject.Institution)
+		if destination != nil {
+			restorer.deliverToGlobus(restoreState, destination)
+			return
+		}
+	}
+	restorer.uploadBag(restoreState)
+}
+
+// deliverToGlobus transfers the restored bag to the depositor's Globus
+// collection and waits for the transfer to finish. If a prior attempt
+// started a transfer, this waits for that one instead of starting
+// another. The receipt goes into the RestoreState only, since the
+// depositor has no restoration bucket to put it in.
+func (restorer *APTRestorer) deliverToGlobus(restoreState *models.RestoreState, destination *models.GlobusDestination) {
+	if restoreState.GlobusTaskId != "" {
+		restorer.Context.MessageLog.Info("Checking on Globus transfer task %s for %s",
+			restoreState.GlobusTaskId, restoreState.IntellectualObject.Identifier)
+	} else {
+		err := restorer.Globus.Submit(restoreState, destination)
+		if err != nil {
+			restoreState.CopySummary.AddError("Cannot start Globus transfer of %s: %v",
+				restoreState.LocalTarFile, err)
+			return
 		}
+		restorer.Context.MessageLog.Info("Started Globus transfer task %s for %s to %s",
+			restoreState.GlobusTaskId, restoreState.IntellectualObject.Identifier,
+			restorer.Globus.DestinationUrl(destination, restoreState))
+		// Save the task id, so we don't start another
+		// transfer if the worker restarts.
+		restorer.saveWorkItemState(restoreState)
+	}
+	err := restorer.Globus.Wait(restoreState, destination, restoreState.TouchNSQ)
+	if err != nil {
+		restoreState.CopySummary.AddError("Globus delivery of %s failed: %v",
+			restoreState.LocalTarFile, err)
+		return
+	}
+	receipt := restorer.buildReceipt(restoreState, destination.EndpointId,
+		restorer.Globus.DestinationPath(destination, restoreState))
+	if receipt != nil {
+		restoreState.Receipt = receipt
+		restorer.Context.MessageLog.Info("Receipt for %s: size %d, sha256 %s",
+			restoreState.IntellectualObject.Identifier, receipt.Size, receipt.Sha256)
 	}
 }
 
 func (restorer *APTRestorer) uploadBag(restoreState *models.RestoreState) {
-	// Each institution has its own restoration bucket.
-	restorationBucket := util.RestorationBucketFor(restoreState.IntellectualObject.Institution,
-		restorer.Context.Config.RestoreToTestBuckets)
+	// Each institution has its own restoration bucket, and may have
+	// a separate one for content in Glacier.
+	restorationBucket := restorer.Context.Config.RestorationBucketFor(
+		restoreState.IntellectualObject.Institution, restoreState.IntellectualObject.StorageOption)
 	s3Key := fmt.Sprintf("%s.tar", restoreState.IntellectualObject.BagName)
 	restorer.Context.MessageLog.Info("Uploading %s to %s/%s",
 		restoreState.LocalTarFile, restorationBucket, s3Key)
@@ -420,6 +545,7 @@ func (restorer *APTRestorer) uploadBag(restoreState *models.RestoreState) {
 		restorationBucket,
 		s3Key,
 		"application/x-tar")
+	upload.SessionPool = restorer.Context.S3SessionPool
 
 	// Open a reader for the tarred bag.
 	reader, err := os.Open(restoreState.LocalTarFile)
@@ -441,6 +567,58 @@ func (restorer *APTRestorer) uploadBag(restoreState *models.RestoreState) {
 	}
 	restoreState.RestoredToUrl = upload.Response.Location
 	restoreState.CopiedToRestorationAt = time.Now().UTC()
+	restorer.uploadReceipt(restoreState, restorationBucket, s3Key)
+}
+
+// uploadReceipt puts a receipt for the bag we just delivered into
+// the restoration bucket, next to the bag, and into the RestoreState,
+// which we save in Pharos.
+func (restorer *APTRestorer) uploadReceipt(restoreState *models.RestoreState, restorationBucket, s3Key string) {
+	receipt := restorer.buildReceipt(restoreState, restorationBucket, s3Key)
+	if receipt == nil {
+		return
+	}
+	data, err := json.MarshalIndent(receipt, "", "  ")
+	if err != nil {
+		restoreState.CopySummary.AddError("Cannot serialize receipt for %s: %v",
+			restoreState.LocalTarFile, err)
+		return
+	}
+	upload := network.NewS3Upload(
+		os.Getenv("AWS_ACCESS_KEY_ID"),
+		os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		constants.AWSVirginia,
+		restorationBucket,
+		s3Key+models.RestoreReceiptSuffix,
+		"application/json")
+	upload.SessionPool = restorer.Context.S3SessionPool
+	upload.Send(bytes.NewReader(data))
+	if upload.ErrorMessage != "" {
+		restoreState.CopySummary.AddError("Error uploading receipt for %s: %s",
+			restoreState.LocalTarFile, upload.ErrorMessage)
+		return
+	}
+	restoreState.Receipt = receipt
+	restorer.Context.MessageLog.Info("Receipt for %s: size %d, sha256 %s",
+		restoreState.IntellectualObject.Identifier, receipt.Size, receipt.Sha256)
+}
+
+// buildReceipt returns a receipt for the bag we delivered to
+// bucket/key, signed if RESTORE_RECEIPT_KEY is set. On error, it adds
+// the error to the CopySummary and returns nil.
+func (restorer *APTRestorer) buildReceipt(restoreState *models.RestoreState, bucket, key string) *models.RestoreReceipt {
+	signingKey := os.Getenv(RestoreReceiptKeyEnvVar)
+	if signingKey == "" {
+		restorer.Context.MessageLog.Warning("%s is not set, so receipt for %s will not be signed",
+			RestoreReceiptKeyEnvVar, restoreState.IntellectualObject.Identifier)
+	}
+	receipt, err := NewRestoreReceipt(restoreState, bucket, key, []byte(signingKey))
+	if err != nil {
+		restoreState.CopySummary.AddError("Cannot create receipt for %s: %v",
+			restoreState.LocalTarFile, err)
+		return nil
+	}
+	return receipt
 }
 
 // buildState builds the RestoreState object, which keeps track of which
@@ -458,7 +636,7 @@ func (restorer *APTRestorer) buildState(message *nsq.Message) (*models.RestoreSt
 	// Get the saved state of this item, if there is one.
 	if workItem.WorkItemStateId != nil {
 		restorer.Context.MessageLog.Info("Asking Pharos for WorkItemState %d", *workItem.WorkItemStateId)
-		resp := restorer.Context.PharosClient.WorkItemStateGet(*workItem.WorkItemStateId)
+		resp := restorer.Context.StateStore().Get(*workItem.WorkItemStateId)
 		if resp.Error != nil {
 			restorer.Context.MessageLog.Warning("Could not retrieve WorkItemState with id %d: %v",
 				*workItem.WorkItemStateId, resp.Error)
@@ -477,6 +655,10 @@ func (restorer *APTRestorer) buildState(message *nsq.Message) (*models.RestoreSt
 			restoreState.LocalTarFile = savedState.LocalTarFile
 			restoreState.RestoredToUrl = savedState.RestoredToUrl
 			restoreState.CopiedToRestorationAt = savedState.CopiedToRestorationAt
+			restoreState.GlobusTaskId = savedState.GlobusTaskId
+			if savedState.Checkpoint != nil {
+				restoreState.Checkpoint = savedState.Checkpoint
+			}
 			restorer.Context.MessageLog.Info("Got WorkItemState %d", *workItem.WorkItemStateId)
 		}
 	}
@@ -506,6 +688,25 @@ func (restorer *APTRestorer) buildState(message *nsq.Message) (*models.RestoreSt
 	return restoreState, nil
 }
 
+// assertInstitution returns false if the WorkItem, the object and the
+// restoration bucket don't all belong to the same institution. In that
+// case, it adds a fatal error to the most recent summary and flags the
+// WorkItem for admin review.
+func (restorer *APTRestorer) assertInstitution(restoreState *models.RestoreState) bool {
+	restorationBucket := restorer.Context.Config.RestorationBucketFor(
+		restoreState.IntellectualObject.Institution, restoreState.IntellectualObject.StorageOption)
+	err := CheckInstitutionConsistency(restoreState.WorkItem, restorationBucket,
+		restoreState.IntellectualObject.Identifier)
+	if err == nil {
+		return true
+	}
+	summary := restoreState.MostRecentSummary()
+	summary.AddError(err.Error())
+	summary.ErrorIsFatal = true
+	restoreState.WorkItem.NeedsAdminReview = true
+	return false
+}
+
 // markWorkItemStarted tells Pharos that we're starting work on this.
 func (restorer *APTRestorer) markWorkItemStarted(restoreState *models.RestoreState) {
 	now := time.Now().UTC()
@@ -563,13 +764,54 @@ func (restorer *APTRestorer) saveWorkItemState(restoreState *models.RestoreState
 	if restoreState.WorkItem.WorkItemStateId != nil {
 		workItemState.Id = *restoreState.WorkItem.WorkItemStateId
 	}
-	resp := restorer.Context.PharosClient.WorkItemStateSave(workItemState)
+	resp := restorer.Context.StateStore().Save(workItemState)
 	if resp.Error != nil {
 		restorer.Context.MessageLog.Warning(
 			"Error saving WorkItemState for object %s: %v",
 			restoreState.IntellectualObject.Identifier,
 			resp.Error)
+	} else if restoreState.WorkItem.WorkItemStateId == nil && resp.WorkItemState() != nil {
+		// Remember the id, so later saves update this record
+		// instead of creating new ones.
+		savedId := resp.WorkItemState().Id
+		restoreState.WorkItem.WorkItemStateId = &savedId
+	}
+}
+
+// saveCheckpoint saves the RestoreState, including its Checkpoint,
+// to Pharos if CheckpointInterval has passed since the last save,
+// or if force is true. Packaging a multi-terabyte bag can take
+// many hours, and this lets a restarted worker resume from the
+// last checkpoint.
+func (restorer *APTRestorer) saveCheckpoint(restoreState *models.RestoreState, force bool) {
+	checkpoint := restoreState.Checkpoint
+	if !force && time.Since(checkpoint.SavedAt) < restorer.CheckpointInterval {
+		return
 	}
+	checkpoint.SavedAt = time.Now().UTC()
+	restorer.Context.MessageLog.Info("Checkpoint for %s: %d files (%d bytes) "+
+		"downloaded, tag files written: %t, %d files (%d bytes) in tar file",
+		restoreState.WorkItem.ObjectIdentifier, checkpoint.FilesDownloaded,
+		checkpoint.BytesDownloaded, checkpoint.TagFilesWritten,
+		checkpoint.TarFilesWritten, checkpoint.TarBytesWritten)
+	restorer.saveWorkItemState(restoreState)
+}
+
+// tagFilesWereWritten returns true if the Checkpoint says we wrote
+// the tag files and manifests on a prior run and they're still on disk.
+func (restorer *APTRestorer) tagFilesWereWritten(restoreState *models.RestoreState) bool {
+	if !restoreState.Checkpoint.TagFilesWritten {
+		return false
+	}
+	for _, manifestType := range []string{constants.PAYLOAD_MANIFEST, constants.TAG_MANIFEST} {
+		for _, algorithm := range []string{constants.AlgMd5, constants.AlgSha256} {
+			if !fileutil.FileExists(restorer.getManifestPath(manifestType, algorithm, restoreState)) {
+				restoreState.Checkpoint.TagFilesWritten = false
+				return false
+			}
+		}
+	}
+	return true
 }
 
 // Log a message saying which channel we're putting this into.
@@ -589,10 +831,27 @@ func (restorer *APTRestorer) tarBag(restoreState *models.RestoreState) {
 		return
 	}
 
-	// Set up our tar writer...
+	// Set up our tar writer. If a prior run got partway through
+	// tarring this bag, pick up after the last checkpointed file.
 	restoreState.LocalTarFile = fmt.Sprintf("%s.tar", restoreState.LocalBagDir)
 	tarWriter := tarfile.NewWriter(restoreState.LocalTarFile)
-	err = tarWriter.Open()
+	checkpoint := restoreState.Checkpoint
+	resumed := false
+	if checkpoint.TarFilesWritten > 0 && checkpoint.TarFilesWritten <= len(files) {
+		err = tarWriter.OpenAt(checkpoint.TarBytesWritten)
+		if err == nil {
+			resumed = true
+			restorer.Context.MessageLog.Info("Resuming tar of %s after %d files (%d bytes)",
+				restoreState.LocalBagDir, checkpoint.TarFilesWritten, checkpoint.TarBytesWritten)
+		} else {
+			restorer.Context.MessageLog.Warning("Cannot resume tar file %s, "+
+				"so starting over: %v", restoreState.LocalTarFile, err)
+		}
+	}
+	if !resumed {
+		checkpoint.ResetTar()
+		err = tarWriter.Open()
+	}
 	if err != nil {
 		restoreState.PackageSummary.AddError("Error creating tar file %s for bag %s: %v",
 			restoreState.LocalTarFile, restoreState.IntellectualObject.Identifier, err)
@@ -600,7 +859,7 @@ func (restorer *APTRestorer) tarBag(restoreState *models.RestoreState) {
 	}
 
 	// ... and start filling it up.
-	for _, filePath := range files {
+	for _, filePath := range files[checkpoint.TarFilesWritten:] {
 		// We want to transform filePath to pathWithinArchive, like so:
 		// /mnt/aptrust/restore/ncsu.edu/bag123/bagit.txt -> bag123/bagit.txt
 		pathInBag := strings.Split(filePath, restoreState.IntellectualObject.Identifier)[1]
@@ -612,6 +871,9 @@ func (restorer *APTRestorer) tarBag(restoreState *models.RestoreState) {
 			tarWriter.Close()
 			return
 		}
+		checkpoint.TarFilesWritten += 1
+		checkpoint.TarBytesWritten = tarWriter.Offset()
+		restorer.saveCheckpoint(restoreState, false)
 	}
 	tarWriter.Close()
 }
@@ -660,6 +922,13 @@ func (restorer *APTRestorer) writeAPTrustInfoFile(restoreState *models.RestoreSt
 	fmt.Fprintln(aptInfoFile, "Access:", strings.Title(restoreState.IntellectualObject.Access))
 	fmt.Fprintln(aptInfoFile, "Description:", restoreState.IntellectualObject.Description)
 	fmt.Fprintln(aptInfoFile, "Storage-Option:", restoreState.IntellectualObject.StorageOption)
+	if !restoreState.IntellectualObject.EmbargoUntil.IsZero() {
+		fmt.Fprintln(aptInfoFile, "Embargo-Until:",
+			restoreState.IntellectualObject.EmbargoUntil.Format(constants.EmbargoDateFormat))
+	}
+	if restoreState.IntellectualObject.RightsStatement != "" {
+		fmt.Fprintln(aptInfoFile, "Rights-Statement:", restoreState.IntellectualObject.RightsStatement)
+	}
 	aptInfoFile.Close()
 	restorer.addFile(restoreState, aptInfoPath, "aptrust-info.txt")
 }
@@ -697,6 +966,9 @@ func (restorer *APTRestorer) writeBagInfoFile(restoreState *models.RestoreState)
 	fmt.Fprintln(bagInfoFile, "Bag-Group-Identifier:", restoreState.IntellectualObject.BagGroupIdentifier)
 	fmt.Fprintln(bagInfoFile, "Internal-Sender-Description:", restoreState.IntellectualObject.Description)
 	fmt.Fprintln(bagInfoFile, "Internal-Sender-Identifier:", restoreState.IntellectualObject.AltIdentifier)
+	if restoreState.IntellectualObject.BagItProfileIdentifier != "" {
+		fmt.Fprintln(bagInfoFile, "BagIt-Profile-Identifier:", restoreState.IntellectualObject.BagItProfileIdentifier)
+	}
 
 	byteCount, fileCount := restoreState.IntellectualObject.PayloadBytesAndFiles()
 	payloadOxum := fmt.Sprintf("%d.%d", byteCount, fileCount)
@@ -794,7 +1066,8 @@ func (restorer *APTRestorer) writeManifest(manifestType, algorithm string, resto
 				algorithm, gf.OriginalPath())
 			return
 		}
-		_, err := fmt.Fprintln(manifestFile, checksum.Digest, gf.OriginalPath())
+		_, err := fmt.Fprintln(manifestFile, checksum.Digest,
+			util.EncodeManifestPath(gf.OriginalPath()))
 		if err != nil {
 			restoreState.PackageSummary.AddError("Error writing checksum for file %s "+
 				"to manifest %s: %v", gf.OriginalPath(), manifestPath, err)
@@ -880,10 +1153,16 @@ func (restorer *APTRestorer) fetchAllFiles(restoreState *models.RestoreState) {
 		"",   // local path at which to save the s3 file - set below
 		true, // calculate md5 for manifest
 		true) // calculate sha256 for manifest and fixity verification
+	downloader.SessionPool = restorer.Context.S3SessionPool
 
 	// Fetch all of the files from S3 to our local bag dir.
 	restorer.Context.MessageLog.Info("Starting fetch. Object %s has %d saved (active) files",
 		restoreState.IntellectualObject.Identifier, activeFileCount)
+	restoreState.Checkpoint.FilesDownloaded = 0
+	restoreState.Checkpoint.BytesDownloaded = 0
+	restoreState.ExpiredGlacierFiles = make([]string, 0)
+	isGlacierOnly := restoreState.IntellectualObject.StorageOption != constants.StorageStandard
+	expiredFiles := make([]*models.GenericFile, 0)
 	downloaded := 0
 	alreadyOnDisk := 0
 	for _, gf := range restoreState.IntellectualObject.GenericFiles {
@@ -936,6 +1215,8 @@ func (restorer *APTRestorer) fetchAllFiles(restoreState *models.RestoreState) {
 				"so we won't download it again. Will verify checksum in validation step.",
 				downloader.LocalPath, fileStat.Size())
 			alreadyOnDisk += 1
+			restoreState.Checkpoint.FilesDownloaded = downloaded + alreadyOnDisk
+			restoreState.Checkpoint.BytesDownloaded += gf.Size
 			continue
 		}
 
@@ -944,6 +1225,18 @@ func (restorer *APTRestorer) fetchAllFiles(restoreState *models.RestoreState) {
 		restorer.Context.MessageLog.Info("Downloading %s (%s) to %s", gf.Identifier,
 			s3KeyName, downloader.LocalPath)
 		downloader.Fetch()
+		if downloader.ErrorMessage != "" && isGlacierOnly &&
+			restorer.glacierCopyExpired(region, bucket, s3KeyName) {
+			// Glacier moved this file into S3 for us, but the
+			// temporary copy expired while the restoration sat
+			// in the queue. Get the rest, then ask for this
+			// one again.
+			restorer.Context.MessageLog.Warning("Temporary S3 copy of %s (%s) has expired",
+				gf.Identifier, s3KeyName)
+			restoreState.ExpiredGlacierFiles = append(restoreState.ExpiredGlacierFiles, gf.Identifier)
+			expiredFiles = append(expiredFiles, gf)
+			continue
+		}
 		if downloader.ErrorMessage != "" {
 			msg := fmt.Sprintf("Error fetching %s from S3: %s", gf.Identifier, downloader.ErrorMessage)
 			restorer.Context.MessageLog.Error(msg)
@@ -962,6 +1255,9 @@ func (restorer *APTRestorer) fetchAllFiles(restoreState *models.RestoreState) {
 			break
 		}
 		downloaded += 1
+		restoreState.Checkpoint.FilesDownloaded = downloaded + alreadyOnDisk
+		restoreState.Checkpoint.BytesDownloaded += gf.Size
+		restorer.saveCheckpoint(restoreState, false)
 
 		// Touch NSQ every now and then, so we don't time out.
 		if downloaded%10 == 0 {
@@ -969,6 +1265,11 @@ func (restorer *APTRestorer) fetchAllFiles(restoreState *models.RestoreState) {
 		}
 	}
 
+	if restoreState.HasExpiredGlacierFiles() {
+		restorer.requestGlacierRetrieval(restoreState, expiredFiles, region, bucket)
+		return
+	}
+
 	// Final status report for logging and troubleshooting.
 	totalFilesPresent := downloaded + alreadyOnDisk
 	if totalFilesPresent == activeFileCount {
@@ -985,6 +1286,83 @@ func (restorer *APTRestorer) fetchAllFiles(restoreState *models.RestoreState) {
 	}
 }
 
+// glacierCopyExpired returns true if the Glacier-only file with the
+// specified key has no restored copy in S3. That's the case when the
+// copy that apt_glacier_restore_init requested has passed its expiry
+// date, or when we've asked for it again and Glacier hasn't finished.
+func (restorer *APTRestorer) glacierCopyExpired(region, bucket, key string) bool {
+	headClient := network.NewS3Head(
+		os.Getenv("AWS_ACCESS_KEY_ID"),
+		os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		region,
+		bucket)
+	headClient.Head(key)
+	if headClient.ErrorMessage != "" {
+		return false
+	}
+	restoreRequestInfo, err := headClient.GetRestoreRequestInfo()
+	if err != nil {
+		return false
+	}
+	return !restoreRequestInfo.RequestIsComplete
+}
+
+// requestGlacierRetrieval asks Glacier to move the expired files back
+// into S3. A file whose retrieval is already in progress counts as
+// requested. If Glacier doesn't accept a request, we record an error,
+// and the usual retry logic will bring us back here.
+func (restorer *APTRestorer) requestGlacierRetrieval(restoreState *models.RestoreState, expiredFiles []*models.GenericFile, region, bucket string) {
+	for _, gf := range expiredFiles {
+		s3KeyName, err := gf.PreservationStorageFileName()
+		if err != nil {
+			restoreState.PackageSummary.AddError("File %s: %v", gf.Identifier, err)
+			continue
+		}
+		restorer.Context.MessageLog.Info("Requesting Glacier retrieval of expired file %s (%s/%s)",
+			gf.Identifier, bucket, s3KeyName)
+		restoreClient := network.NewS3Restore(
+			os.Getenv("AWS_ACCESS_KEY_ID"),
+			os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			region,
+			bucket,
+			s3KeyName,
+			RETRIEVAL_OPTION,
+			DAYS_TO_KEEP_IN_S3)
+		restoreClient.Restore()
+		if !restoreClient.RequestAccepted() && !restoreClient.RestoreAlreadyInProgress {
+			restoreState.PackageSummary.AddError("Glacier retrieval request for expired file %s "+
+				"was not accepted: %s", gf.Identifier, restoreClient.ErrorMessage)
+		}
+	}
+}
+
+// requeueForGlacierRetrieval puts a restoration back in the queue
+// to wait for Glacier to retrieve the files whose temporary S3
+// copies expired. This is a wait, not a failure, so it doesn't count
+// as an attempt. The bag directory stays on disk, so the next run
+// downloads only the files we're missing.
+func (restorer *APTRestorer) requeueForGlacierRetrieval(restoreState *models.RestoreState) {
+	restoreState.PackageSummary.AttemptNumber -= 1
+	note := fmt.Sprintf("Requeued to wait for Glacier to retrieve %d files "+
+		"whose temporary S3 copies expired.", len(restoreState.ExpiredGlacierFiles))
+	restorer.Context.MessageLog.Info("WorkItem %d (%s): %s", restoreState.WorkItem.Id,
+		restoreState.WorkItem.ObjectIdentifier, note)
+	restoreState.WorkItem.Note = note
+	// Don't revert status to Pending, or apt_queue may queue
+	// this again while we're waiting.
+	restoreState.WorkItem.Status = constants.StatusStarted
+	restoreState.WorkItem.Retry = true
+	restoreState.WorkItem.Node = ""
+	restoreState.WorkItem.Pid = 0
+	restorer.saveWorkItem(restoreState)
+	restorer.saveWorkItemState(restoreState)
+	recheckInterval := GLACIER_RECHECK_INTERVAL
+	if util.IsGlacierDeepArchive(restoreState.IntellectualObject.StorageOption) {
+		recheckInterval = GLACIER_DEEP_RECHECK_INTERVAL
+	}
+	restoreState.NSQMessage.RequeueWithoutBackoff(recheckInterval)
+}
+
 // WritePremisEventFile: dump all PREMIS events to a file inside the restored
 // bag, so users can see which files have been deleted or overwritten
 // during the bag's time in APTrust.
@@ -16,6 +16,8 @@ type APTSpotTestRestore struct {
 	NotRestoredSince time.Time
 	MaxSize          int64
 	DryRun           bool
+	// Report describes the most recent run.
+	Report *models.SpotTestReport
 }
 
 // NewAPTSpotTestRestore creates a new restore spot test worker.
@@ -48,8 +50,17 @@ func NewAPTSpotTestRestore(_context *context.Context, maxSize int64, createdBefo
 // the specified date and notRestoredSince the specified date).
 // It creates a Restore WorkItem for each bag, and returns the WorkItems
 // it created. The caller can get the WorkItem.Id and object identifier from there.
+// Run also sets restoreTest.Report, which describes what it chose for each
+// institution, and why.
 func (restoreTest *APTSpotTestRestore) Run() ([]*models.WorkItem, error) {
 	restoreTest.logFacts()
+	criteria := models.SpotTestCriteria{
+		MaxSize:          restoreTest.MaxSize,
+		CreatedBefore:    restoreTest.CreatedBefore,
+		NotRestoredSince: restoreTest.NotRestoredSince,
+	}
+	restoreTest.Report = models.NewSpotTestReport(criteria, restoreTest.DryRun)
+	defer restoreTest.Report.Finish()
 	workItems := make([]*models.WorkItem, 0)
 	institutions, err := restoreTest.GetInstitutions()
 	if err != nil {
@@ -64,18 +75,22 @@ func (restoreTest *APTSpotTestRestore) Run() ([]*models.WorkItem, error) {
 		obj, err := restoreTest.GetObjectFor(inst.Identifier)
 		if err != nil {
 			restoreTest.Context.MessageLog.Error(err.Error())
+			restoreTest.Report.AddSelection(inst.Identifier, nil, nil, err)
 			continue
 		}
 		if obj == nil {
 			restoreTest.Context.MessageLog.Info("No suitable objects for %s", inst.Identifier)
+			restoreTest.Report.AddSelection(inst.Identifier, nil, nil, nil)
 			continue
 		}
 		workItem, err := restoreTest.CreateWorkItem(obj)
 		if err != nil {
 			restoreTest.Context.MessageLog.Error("Error creating new Restore WorkItem for %s: %v",
 				obj.Identifier, err)
+			restoreTest.Report.AddSelection(inst.Identifier, obj, nil, err)
 			continue
 		}
+		restoreTest.Report.AddSelection(inst.Identifier, obj, workItem, nil)
 		workItems = append(workItems, workItem)
 	}
 
@@ -84,6 +84,18 @@ func TestSpotRun(t *testing.T) {
 	require.Nil(t, err)
 	require.NotNil(t, items)
 	assert.Equal(t, 4, len(items))
+
+	report := worker.Report
+	require.NotNil(t, report)
+	assert.EqualValues(t, 1000000, report.Criteria.MaxSize)
+	assert.Equal(t, testutil.TEST_TIMESTAMP, report.Criteria.CreatedBefore)
+	assert.False(t, report.FinishedAt.IsZero())
+	require.Equal(t, 4, len(report.Selections))
+	assert.Equal(t, 4, report.Count(models.SpotTestQueued))
+	for i, selection := range report.Selections {
+		assert.Equal(t, items[i].ObjectIdentifier, selection.ObjectIdentifier)
+		assert.Equal(t, items[i].Id, selection.WorkItemId)
+	}
 }
 
 func spotInstitutionGetHandler(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,97 @@
+package workers
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"net/url"
+	"os"
+	"time"
+)
+
+// APTStarvationMonitor finds WorkItems that have sat in Pending status
+// longer than Config.StarvationThresholds allows, and asks Pharos to
+// alert APTrust admins. Items like these have not failed, so nothing
+// else reports them. This is meant to run as a cron job, and it alerts
+// on every run until the starved items move.
+type APTStarvationMonitor struct {
+	Context *context.Context
+	DryRun  bool
+	// Report describes the most recent run.
+	Report *models.StarvationReport
+}
+
+// NewAPTStarvationMonitor returns a new APTStarvationMonitor. It
+// panics if the config's StarvationThresholds are not valid.
+func NewAPTStarvationMonitor(_context *context.Context, dryRun bool) *APTStarvationMonitor {
+	if err := _context.Config.EnsureStarvationThresholds(); err != nil {
+		panic(err.Error())
+	}
+	// Patch for https://trello.com/c/Ep4pKzZB
+	err := CacheBucketNames(_context)
+	if err != nil {
+		panic(fmt.Sprintf("Cannot cache bucket names from Pharos: %v", err))
+	}
+	return &APTStarvationMonitor{
+		Context: _context,
+		DryRun:  dryRun,
+	}
+}
+
+// Run tracks the oldest Pending WorkItem for each monitored action and
+// institution, and sends an alert to Pharos if any of them are older
+// than their threshold. It returns the starved queues. If DryRun is
+// true, it logs the starved queues without sending an alert.
+func (monitor *APTStarvationMonitor) Run() ([]*models.PendingQueue, error) {
+	hostname, _ := os.Hostname()
+	monitor.Report = models.NewStarvationReport(hostname, time.Now().UTC())
+	for _, action := range constants.ActionTypes {
+		if monitor.Context.Config.StarvationThreshold(action) == 0 {
+			continue
+		}
+		if err := monitor.trackPendingItems(action); err != nil {
+			return nil, err
+		}
+	}
+	starved := monitor.Report.FindStarved(monitor.Context.Config.StarvationThreshold)
+	for _, queue := range starved {
+		monitor.Context.MessageLog.Warning("%d pending %s items for %s. Oldest is "+
+			"WorkItem %d (%s), pending for %s, which exceeds the %s threshold.",
+			queue.PendingCount, queue.Action, queue.Institution,
+			queue.OldestWorkItemId, queue.OldestName, queue.Age, queue.Threshold)
+	}
+	if len(starved) == 0 || monitor.DryRun {
+		return starved, nil
+	}
+	resp := monitor.Context.PharosClient.QueueStarvationAlertSend(monitor.Report)
+	if resp.Error != nil {
+		return starved, fmt.Errorf("Error sending starvation alert to Pharos: %v", resp.Error)
+	}
+	monitor.Context.MessageLog.Info("Sent starvation alert for %d queues to Pharos", len(starved))
+	return starved, nil
+}
+
+// trackPendingItems adds all of the Pending WorkItems for action to
+// the report.
+func (monitor *APTStarvationMonitor) trackPendingItems(action string) error {
+	params := url.Values{}
+	params.Set("item_action", action)
+	params.Set("status", constants.StatusPending)
+	params.Set("page", "1")
+	params.Set("per_page", "100")
+	for {
+		resp := monitor.Context.PharosClient.WorkItemList(params)
+		if resp.Error != nil {
+			return fmt.Errorf("Error getting pending %s items from Pharos: %v", action, resp.Error)
+		}
+		for _, item := range resp.WorkItems() {
+			monitor.Report.Track(InstitutionOf(item), item)
+		}
+		if resp.HasNextPage() == false {
+			break
+		}
+		params = resp.ParamsForNextPage()
+	}
+	return nil
+}
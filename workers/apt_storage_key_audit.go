@@ -0,0 +1,86 @@
+package workers
+
+import (
+	"errors"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"net/http"
+	"sync"
+)
+
+// APTStorageKeyAudit checks that each GenericFile's key in preservation
+// storage belongs to that file alone. It reports files whose key is
+// also claimed by another file in Pharos, and files whose stored object
+// has bag metadata naming some other file. It issues only HEAD requests,
+// so it never downloads content. It prints one record for each file
+// with a problem to STDOUT (or one for every file, if showAll is true),
+// and errors to STDERR.
+//
+// Duplicate keys are found among the files this audit reads, so run it
+// without institution, identifierLike or limit to audit the whole
+// namespace.
+type APTStorageKeyAudit struct {
+	auditor *fileAuditor
+	keys    map[string]string
+	mutex   *sync.Mutex
+}
+
+// NewAPTStorageKeyAudit returns a new APTStorageKeyAudit. Params are
+// the same as for NewAPTChecksumAudit, except that there is no sample
+// rate, since we can only find duplicate keys among files we read.
+func NewAPTStorageKeyAudit(context *context.Context, institution, identifierLike, format string, limit int, concurrency int, showAll bool) (*APTStorageKeyAudit, error) {
+	auditor, err := newFileAuditor(context, institution, identifierLike,
+		format, limit, 1.0, concurrency, showAll)
+	if err != nil {
+		return nil, err
+	}
+	return &APTStorageKeyAudit{
+		auditor: auditor,
+		keys:    make(map[string]string),
+		mutex:   &sync.Mutex{},
+	}, nil
+}
+
+// Run audits the files and returns the number of files checked,
+// the number of files with key problems, and an error if it could
+// not complete the audit. Check the STDERR log for details if Run
+// returns an error.
+func (audit *APTStorageKeyAudit) Run() (int, int, error) {
+	return audit.auditor.run(audit.auditOne)
+}
+
+// auditOne checks whether another file we've seen uses the same key
+// as gf, and whether the object stored under that key belongs to gf.
+func (audit *APTStorageKeyAudit) auditOne(gf *models.GenericFile) (fileAuditResult, error) {
+	bucket, key, err := gf.StorageBucketAndKey()
+	if err != nil {
+		return nil, err
+	}
+	result := models.NewStorageKeyAudit(gf, bucket, key)
+	if claimant := audit.claimKey(key, gf.Identifier); claimant != "" {
+		result.AddClaimant(claimant)
+	}
+	client, err := audit.auditor.headStoredFile(gf, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if client.ErrorMessage != "" && client.StatusCode != http.StatusNotFound {
+		return nil, errors.New(client.ErrorMessage)
+	}
+	result.Compare(gf, client.StoredFile())
+	return result, nil
+}
+
+// claimKey records that the file with identifier gfIdentifier uses
+// key. It returns the identifier of the file that claimed the key
+// before, or an empty string if no other file has.
+func (audit *APTStorageKeyAudit) claimKey(key, gfIdentifier string) string {
+	audit.mutex.Lock()
+	defer audit.mutex.Unlock()
+	claimant, exists := audit.keys[key]
+	if exists && claimant != gfIdentifier {
+		return claimant
+	}
+	audit.keys[key] = gfIdentifier
+	return ""
+}
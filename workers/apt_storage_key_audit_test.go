@@ -0,0 +1,25 @@
+package workers_test
+
+import (
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/APTrust/exchange/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewAPTStorageKeyAudit(t *testing.T) {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+
+	audit, err := workers.NewAPTStorageKeyAudit(_context, "test.edu", "", "tsv", 100, 4, false)
+	assert.Nil(t, err)
+	assert.NotNil(t, audit)
+
+	_, err = workers.NewAPTStorageKeyAudit(nil, "", "", "tsv", 100, 4, false)
+	assert.NotNil(t, err)
+	_, err = workers.NewAPTStorageKeyAudit(_context, "", "", "xml", 100, 4, false)
+	assert.NotNil(t, err)
+	_, err = workers.NewAPTStorageKeyAudit(_context, "", "", "csv", 100, 33, false)
+	assert.NotNil(t, err)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/APTrust/exchange/util"
 	"github.com/APTrust/exchange/util/fileutil"
 	"github.com/APTrust/exchange/util/storage"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/nsqio/go-nsq"
 	"io"
@@ -16,6 +17,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -96,6 +98,12 @@ func (storer *APTStorer) HandleMessage(message *nsq.Message) error {
 	// whatever may have failed on the last run.
 	ingestState.IngestManifest.StoreResult.ClearErrors()
 
+	// Make sure the WorkItem, object and bucket all belong to
+	// the same institution before we write anything.
+	if !AssertIngestInstitution(ingestState, storer.Context, ingestState.IngestManifest.StoreResult) {
+		return nil
+	}
+
 	// Tell Pharos that we've started to store this item.
 	err = MarkWorkItemStarted(ingestState, storer.Context,
 		constants.StageStore, "Files are being copied to long-term storage.")
@@ -178,8 +186,17 @@ func (storer *APTStorer) store() {
 		//
 		// testdata/unit_test_bags/updated/example.edu.sample_glacier_oh.tar
 		// testdata/unit_test_bags/updated/example.edu.tagsample_good.tar
-		existingStorageOption, err := storer.setStorageOption(db, objIdentifier)
-		if err != nil {
+		existingStorageOption, err := storer.setStorageOption(db, objIdentifier,
+			ingestState.IngestManifest.StoreResult)
+		if _, isChangeError := err.(*StorageOptionChangeError); isChangeError {
+			// Bag is not acceptable. Retrying won't help.
+			ingestState.IngestManifest.StoreResult.AddError(err.Error())
+			ingestState.IngestManifest.StoreResult.ErrorIsFatal = true
+			ingestState.IngestManifest.StoreResult.Retry = false
+			ingestState.IngestManifest.StoreResult.Finish()
+			storer.CleanupChannel <- ingestState
+			continue
+		} else if err != nil {
 			msg := fmt.Sprintf("While trying to get original storage option, "+
 				"error looking up IntellectualObject in Pharos or BoltDB: %v", err)
 			ingestState.IngestManifest.StoreResult.AddError(msg)
@@ -240,6 +257,9 @@ func (storer *APTStorer) store() {
 				if existingStorageOption != "" {
 					storageSummaries[i].GenericFile.StorageOption = existingStorageOption
 				}
+				if ingestState.WorkItem != nil {
+					storageSummaries[i].WorkItemId = ingestState.WorkItem.Id
+				}
 
 				go func(storageSummary *models.StorageSummary) {
 					defer wg.Done()
@@ -258,8 +278,8 @@ func (storer *APTStorer) store() {
 			// we have to manually copy over any errors that may have
 			// occurred.
 			for _, storageSummary := range storageSummaries {
-				for _, errMsg := range storageSummary.StoreResult.Errors {
-					ingestState.IngestManifest.StoreResult.AddError(errMsg)
+				for _, workError := range storageSummary.StoreResult.Errors {
+					ingestState.IngestManifest.StoreResult.AddWorkError(workError)
 				}
 				if storageSummary.StoreResult.ErrorIsFatal {
 					ingestState.IngestManifest.StoreResult.ErrorIsFatal = true
@@ -327,7 +347,7 @@ func (storer *APTStorer) record() {
 			MarkWorkItemFailed(ingestState, storer.Context)
 		} else if ingestState.IngestManifest.StoreResult.HasErrors() {
 			timeout := 30000 // thirty seconds
-			if strings.Contains(ingestState.IngestManifest.StoreResult.Errors[0], "[High Resource Bag]") {
+			if strings.Contains(ingestState.IngestManifest.StoreResult.Errors[0].Message, "[High Resource Bag]") {
 				storer.Context.MessageLog.Info("Setting long timeout for high resource bag %s", objIdentifier)
 				timeout = RESOURCE_REQUEUE_TIMEOUT
 			}
@@ -406,6 +426,12 @@ func (storer *APTStorer) saveFile(db *storage.BoltDB, storageSummary *models.Sto
 		}
 	}
 
+	// New files get new UUIDs. Make sure no other file is using this one
+	// before we write to it.
+	if gf.IngestNeedsSave && !gf.IngestPreviousVersionExists && !storer.storageKeyIsFree(storageSummary) {
+		return
+	}
+
 	// Now copy to storage only if the file has changed.
 	if gf.IngestNeedsSave {
 		storer.Context.MessageLog.Info("File %s needs save", gf.Identifier)
@@ -426,6 +452,12 @@ func (storer *APTStorer) saveFile(db *storage.BoltDB, storageSummary *models.Sto
 				storer.Context.MessageLog.Info("Skipping upload of %s because it was stored at %s at %s", gf.Identifier, gf.IngestStorageURL, gf.IngestStoredAt.Format(time.RFC3339))
 			}
 		}
+		// New versions of existing files keep the original storage
+		// option, so only new files start a history.
+		if !gf.IngestPreviousVersionExists && !storageSummary.StoreResult.HasErrors() {
+			gf.RecordStorageOption(gf.StorageOption, "Stored at ingest",
+				storageSummary.WorkItemId, time.Now().UTC())
+		}
 		// Don't do cleanup until both copies are saved.
 		defer storer.cleanupTempFile(gf)
 	} else {
@@ -543,8 +575,12 @@ func (storer *APTStorer) getUuidOfExistingFile(gfIdentifier string) (string, err
 // getPharosObjectStorageOption returns the StorageOption of the
 // IntellectualObject from Pharos. If this object was previously ingested,
 // we need to store it in the same place as the original ingest. Otherwise,
-// we'd have multiple versions in multiple places.
-func (storer *APTStorer) setStorageOption(db *storage.BoltDB, objIdentifier string) (string, error) {
+// we'd have multiple versions in multiple places. If the bag asks for a
+// different StorageOption than the original, this applies the
+// Config.StorageOptionChangePolicy, returning a StorageOptionChangeError
+// if the policy says to reject the bag. If the policy says to keep the
+// original StorageOption, this adds a warning to storeResult.
+func (storer *APTStorer) setStorageOption(db *storage.BoltDB, objIdentifier string, storeResult *models.WorkSummary) (string, error) {
 	storer.Context.MessageLog.Info("Checking Pharos for original storage type of object %s",
 		objIdentifier)
 	resp := storer.Context.PharosClient.IntellectualObjectGet(objIdentifier, false, false)
@@ -583,15 +619,58 @@ func (storer *APTStorer) setStorageOption(db *storage.BoltDB, objIdentifier stri
 	// Force the StorageOption of the item we're ingesting to match the
 	// existing (non-deleted) object in Pharos.
 	if obj.StorageOption != existingObject.StorageOption {
-		storer.Context.MessageLog.Info("Changing StorageOption %s on object %s to %s "+
-			"to match StorageOption of existing object in Pharos.",
-			obj.StorageOption, objIdentifier, existingObject.StorageOption)
+		err = CheckStorageOptionChange(storer.Context.Config.StorageOptionChangePolicy,
+			objIdentifier, obj.StorageOption, existingObject.StorageOption)
+		if err != nil {
+			storer.Context.MessageLog.Warning(err.Error())
+			return "", err
+		}
+		// Record this on the WorkItem, so the depositor can see
+		// that we ignored the bag's Storage-Option.
+		msg := fmt.Sprintf("Bag has Storage-Option '%s', but %s was originally "+
+			"ingested with Storage-Option '%s'. This version was stored with "+
+			"'%s', since we cannot change the storage option of an existing object.",
+			obj.StorageOption, objIdentifier, existingObject.StorageOption,
+			existingObject.StorageOption)
+		storer.Context.MessageLog.Warning(msg)
+		storeResult.AddWarning("%s", msg)
 		obj.StorageOption = existingObject.StorageOption
 		db.Save(objIdentifier, obj)
 	}
 	return existingObject.StorageOption, nil
 }
 
+// StorageOptionChangeError describes a re-ingested bag whose
+// Storage-Option differs from that of the existing object.
+type StorageOptionChangeError struct {
+	ObjIdentifier string
+	Requested     string
+	Existing      string
+}
+
+func (err *StorageOptionChangeError) Error() string {
+	return fmt.Sprintf("Bag for %s has Storage-Option '%s', but this object "+
+		"was originally ingested with Storage-Option '%s'. We cannot change the "+
+		"storage option of an existing object. To re-deposit this object, set "+
+		"Storage-Option to '%s' in the bag, or delete the existing object first.",
+		err.ObjIdentifier, err.Requested, err.Existing, err.Existing)
+}
+
+// CheckStorageOptionChange returns a StorageOptionChangeError if policy
+// says to reject re-ingested bags whose Storage-Option (requested)
+// differs from that of the existing object (existing). An empty policy
+// means constants.StorageOptionChangeReject.
+func CheckStorageOptionChange(policy, objIdentifier, requested, existing string) error {
+	if requested == existing || policy == constants.StorageOptionChangeKeep {
+		return nil
+	}
+	return &StorageOptionChangeError{
+		ObjIdentifier: objIdentifier,
+		Requested:     requested,
+		Existing:      existing,
+	}
+}
+
 // Copy the GenericFile to long-term storage in S3 or Glacier
 func (storer *APTStorer) copyToLongTermStorage(storageSummary *models.StorageSummary, sendWhere string) {
 	gf := storageSummary.GenericFile
@@ -691,12 +770,31 @@ func (storer *APTStorer) doUpload(storageSummary *models.StorageSummary, sendWhe
 				storer.Context.MessageLog.Warning(errMsg + " Will retry.")
 			}
 		}
-		uploadSucceeded := (s3Obj != nil && *s3Obj.Size == gf.Size && uploader.ErrorMessage == "")
+		// The size can be right when the content is not. Compare
+		// the ETag S3 calculated to the one we expect, so we don't
+		// trust a corrupt copy.
+		contentVerified := false
+		if s3Obj != nil && *s3Obj.Size == gf.Size && uploader.ErrorMessage == "" {
+			err := storer.verifyETag(storageSummary, aws.StringValue(s3Obj.ETag), uploader.PartSize())
+			if err != nil {
+				errMsg := fmt.Sprintf("%s copy of %s (%s) failed verification: %v",
+					sendWhere, gf.IngestUUID, gf.Identifier, err)
+				if attemptNumber == MAX_UPLOAD_ATTEMPTS {
+					storageSummary.StoreResult.AddError(errMsg)
+					storer.deleteUnverifiedCopy(uploader.AWSRegion, *uploader.UploadInput.Bucket, gf)
+				} else {
+					storer.Context.MessageLog.Warning(errMsg + " Will retry.")
+				}
+			} else {
+				contentVerified = true
+			}
+		}
+		uploadSucceeded := (s3Obj != nil && *s3Obj.Size == gf.Size && contentVerified && uploader.ErrorMessage == "")
 
 		if uploadSucceeded {
 			storer.Context.MessageLog.Info("Stored %s in %s after %d attempts",
 				gf.Identifier, sendWhere, attemptNumber)
-			storer.markFileAsStored(gf, sendWhere, uploader.Response.Location)
+			storer.markFileAsStored(gf, sendWhere, uploader.AWSRegion, uploader.Response.Location)
 			return // Upload succeeded
 		} else if uploader.ErrorMessage != "" {
 			storer.Context.MessageLog.Error("Upload error for %s: %s",
@@ -874,6 +972,78 @@ func (storer *APTStorer) uuidPresent(storageSummary *models.StorageSummary) bool
 	return true
 }
 
+// storageKeyIsFree returns true if no other file is stored under
+// gf.IngestUUID. It asks the preservation bucket for the file's storage
+// option whether an object with that key exists, and asks Pharos whether
+// any file's URI ends with that key. On a collision, or if we can't tell,
+// this adds a fatal error, so we never overwrite another file.
+func (storer *APTStorer) storageKeyIsFree(storageSummary *models.StorageSummary) bool {
+	gf := storageSummary.GenericFile
+	if !storer.uuidPresent(storageSummary) {
+		return false
+	}
+	storageOption := gf.StorageOption
+	if storageOption == "" {
+		storageOption = constants.StorageStandard
+	}
+	region, bucket, err := storer.Context.Config.StorageRegionAndBucketFor(storageOption)
+	if err != nil {
+		storageSummary.StoreResult.AddError(err.Error())
+		storageSummary.StoreResult.ErrorIsFatal = true
+		return false
+	}
+	client := network.NewS3Head(
+		os.Getenv("AWS_ACCESS_KEY_ID"),
+		os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		region, bucket)
+	client.SessionPool = storer.Context.S3SessionPool
+	client.Head(gf.IngestUUID)
+	if client.ErrorMessage != "" && client.StatusCode != http.StatusNotFound {
+		storageSummary.StoreResult.AddError("Cannot tell whether storage key %s for %s "+
+			"is in use in %s: %s", gf.IngestUUID, gf.Identifier, bucket, client.ErrorMessage)
+		storageSummary.StoreResult.ErrorIsFatal = true
+		return false
+	}
+	params := url.Values{}
+	params.Set("uri_like", gf.IngestUUID)
+	resp := storer.Context.PharosClient.GenericFileList(params)
+	if resp.Error != nil {
+		storageSummary.StoreResult.AddError("Cannot ask Pharos whether storage key %s for %s "+
+			"is in use: %v", gf.IngestUUID, gf.Identifier, resp.Error)
+		storageSummary.StoreResult.ErrorIsFatal = true
+		return false
+	}
+	err = CheckStorageKey(gf, client.StoredFile(), resp.GenericFiles())
+	if err != nil {
+		storer.Context.MessageLog.Error(err.Error())
+		storageSummary.StoreResult.AddError(err.Error())
+		storageSummary.StoreResult.ErrorIsFatal = true
+		return false
+	}
+	return true
+}
+
+// CheckStorageKey returns an error if gf.IngestUUID, the key under which
+// we're about to store gf, already belongs to another file. Param storedFile
+// describes the object already stored under that key, or is nil if there
+// is none. That object is fine if its metadata says it's gf, which happens
+// when we retry a store that failed partway. Param pharosFiles are files
+// Pharos returned for the key. Only those whose URI ends with the key count.
+func CheckStorageKey(gf *models.GenericFile, storedFile *models.StoredFile, pharosFiles []*models.GenericFile) error {
+	for _, other := range pharosFiles {
+		if other.Identifier != gf.Identifier && strings.HasSuffix(other.URI, "/"+gf.IngestUUID) {
+			return fmt.Errorf("Storage key %s for %s is already used by %s (%s)",
+				gf.IngestUUID, gf.Identifier, other.Identifier, other.URI)
+		}
+	}
+	if storedFile != nil && !models.StoredFileBelongsTo(storedFile, gf) {
+		return fmt.Errorf("Storage key %s for %s is already used in %s by %s/%s",
+			gf.IngestUUID, gf.Identifier, storedFile.Bucket,
+			storedFile.BagName, storedFile.PathInBag)
+	}
+	return nil
+}
+
 // Initializes the uploader object with connection data and metadata
 // for this specific GenericFile.
 func (storer *APTStorer) initUploader(storageSummary *models.StorageSummary, sendWhere string) *network.S3Upload {
@@ -905,6 +1075,7 @@ func (storer *APTStorer) initUploader(storageSummary *models.StorageSummary, sen
 		gf.IngestUUID,
 		gf.FileFormat,
 	)
+	uploader.SessionPool = storer.Context.S3SessionPool
 	instIdentifier, err := gf.InstitutionIdentifier()
 	if err != nil {
 		storageSummary.StoreResult.AddError("Error setting institution in S3 metadata: %v. "+
@@ -912,20 +1083,26 @@ func (storer *APTStorer) initUploader(storageSummary *models.StorageSummary, sen
 	}
 	uploader.AddMetadata("institution", instIdentifier)
 	uploader.AddMetadata("bag", gf.IntellectualObjectIdentifier)
-	uploader.AddMetadata("bagpath", gf.OriginalPath())
+	// Percent-encode the path, as in a manifest, because S3
+	// metadata can't contain line feeds or carriage returns.
+	uploader.AddMetadata("bagpath", util.EncodeManifestPath(gf.OriginalPath()))
 	uploader.AddMetadata("md5", gf.IngestMd5)
 	uploader.AddMetadata("sha256", gf.IngestSha256)
+	// blake2b is optional, so it's not in assertRequiredMetadata.
+	if gf.IngestBlake2b != "" {
+		uploader.AddMetadata("blake2b", gf.IngestBlake2b)
+	}
 	return uploader
 }
 
-// Returns a reader that can read the file from within the tar archive.
-// The S3 uploader uses this reader to stream data to S3 and Glacier.
-func (storer *APTStorer) getReadCloser(storageSummary *models.StorageSummary) (*fileutil.TarFileIterator, io.ReadCloser) {
+// Returns a reader that can read the file from within the tar or zip
+// archive. The S3 uploader uses this reader to stream data to S3 and Glacier.
+func (storer *APTStorer) getReadCloser(storageSummary *models.StorageSummary) (fileutil.ArchiveIterator, io.ReadCloser) {
 	gf := storageSummary.GenericFile
 	tarFilePath := storageSummary.TarFilePath
-	tfi, err := fileutil.NewTarFileIterator(storageSummary.TarFilePath)
+	tfi, err := fileutil.NewArchiveIterator(storageSummary.TarFilePath)
 	if err != nil {
-		msg := fmt.Sprintf("Can't get TarFileIterator for %s: %v", tarFilePath, err)
+		msg := fmt.Sprintf("Can't get archive iterator for %s: %v", tarFilePath, err)
 		storer.Context.MessageLog.Error(msg)
 		storageSummary.StoreResult.AddError(msg)
 		return nil, nil
@@ -935,6 +1112,7 @@ func (storer *APTStorer) getReadCloser(storageSummary *models.StorageSummary) (*
 		msg := fmt.Sprintf("Can't get original path for %s: %s", gf.Identifier, err.Error())
 		storer.Context.MessageLog.Error(msg)
 		storageSummary.StoreResult.AddError(msg)
+		tfi.Close()
 		return nil, nil
 	}
 	readCloser, err := tfi.Find(origPathWithBagName)
@@ -945,6 +1123,7 @@ func (storer *APTStorer) getReadCloser(storageSummary *models.StorageSummary) (*
 		if readCloser != nil {
 			readCloser.Close()
 		}
+		tfi.Close()
 		return nil, nil
 	}
 	return tfi, readCloser
@@ -966,13 +1145,14 @@ func (storer *APTStorer) assertRequiredMetadata(storageSummary *models.StorageSu
 	return allKeysPresent
 }
 
-func (storer *APTStorer) markFileAsStored(gf *models.GenericFile, sendWhere, storageUrl string) {
+func (storer *APTStorer) markFileAsStored(gf *models.GenericFile, sendWhere, region, storageUrl string) {
 	// For new Glacier-only storage, condition if sendWhere != "glacier"
 	// covers S3, Glacier-OH, Glacier-OR, and Glacier-VA
 	if sendWhere != "glacier" {
 		gf.IngestStoredAt = time.Now().UTC()
 		gf.IngestStorageURL = storageUrl
 		gf.URI = storageUrl
+		storer.recordStorage(gf, models.StorageRolePrimary, region, storageUrl, gf.IngestStoredAt)
 		events := gf.FindEventsByType(constants.EventIdentifierAssignment)
 		var event *models.PremisEvent
 		for i := range events {
@@ -989,6 +1169,7 @@ func (storer *APTStorer) markFileAsStored(gf *models.GenericFile, sendWhere, sto
 	} else if sendWhere == "glacier" {
 		gf.IngestReplicatedAt = time.Now().UTC()
 		gf.IngestReplicationURL = storageUrl
+		storer.recordStorage(gf, models.StorageRoleReplica, region, storageUrl, gf.IngestReplicatedAt)
 		events := gf.FindEventsByType(constants.EventReplication)
 		if events != nil && len(events) > 0 {
 			events[0].DateTime = time.Now().UTC()
@@ -996,6 +1177,117 @@ func (storer *APTStorer) markFileAsStored(gf *models.GenericFile, sendWhere, sto
 	}
 }
 
+// recordStorage adds a StorageRecord for the copy of gf we just
+// stored at storageUrl. We've already verified the copy's size and
+// ETag, so it's verified as of when we stored it.
+func (storer *APTStorer) recordStorage(gf *models.GenericFile, role, region, storageUrl string, storedAt time.Time) {
+	record, err := models.NewStorageRecordFromURL(role, region, storageUrl, storedAt)
+	if err != nil {
+		storer.Context.MessageLog.Warning("Cannot record %s copy of %s: %v",
+			role, gf.Identifier, err)
+		return
+	}
+	record.VerifiedAt = storedAt
+	gf.SetStorageRecord(record)
+}
+
+// verifyETag returns an error if etag, which S3 assigned to the copy
+// of the GenericFile we just uploaded, shows that the copy differs from
+// the original. A single-part ETag is the md5 of the copy. For a
+// multipart ETag, we calculate the ETag we expect from the original,
+// using the large-file temp copy if there is one, or the tar file if
+// there isn't. If S3 split the file into a different number of parts
+// than we expect, we can't calculate the ETag, so we rely on the size
+// check alone.
+func (storer *APTStorer) verifyETag(storageSummary *models.StorageSummary, etag string, partSize int64) error {
+	gf := storageSummary.GenericFile
+	digest, parts, err := network.ParseS3ETag(etag)
+	if err != nil {
+		return err
+	}
+	if parts == 0 {
+		if digest != strings.ToLower(gf.IngestMd5) {
+			return fmt.Errorf("ETag %s does not match md5 %s", digest, gf.IngestMd5)
+		}
+		return nil
+	}
+	expectedParts := network.S3PartCount(gf.Size, partSize)
+	if parts != expectedParts {
+		storer.Context.MessageLog.Warning("Cannot verify ETag %s of %s: expected %d parts, "+
+			"S3 reports %d. Relying on size alone.", etag, gf.Identifier, expectedParts, parts)
+		return nil
+	}
+	reader, err := storer.getVerificationReader(storageSummary)
+	if err != nil {
+		return fmt.Errorf("Cannot read original to verify ETag: %v", err)
+	}
+	defer reader.Close()
+	expected, err := network.MultipartETag(reader, partSize)
+	if err != nil {
+		return fmt.Errorf("Cannot calculate ETag: %v", err)
+	}
+	if digest+"-"+strconv.Itoa(parts) != expected {
+		return fmt.Errorf("ETag %s-%d does not match expected ETag %s", digest, parts, expected)
+	}
+	return nil
+}
+
+// getVerificationReader returns a reader for the original of the
+// GenericFile in storageSummary, so we can check our copies against it.
+func (storer *APTStorer) getVerificationReader(storageSummary *models.StorageSummary) (io.ReadCloser, error) {
+	gf := storageSummary.GenericFile
+	if gf.Size > constants.S3LargeFileSize && fileutil.FileExists(storer.getTempFilePath(gf)) {
+		return os.Open(storer.getTempFilePath(gf))
+	}
+	iterator, err := fileutil.NewArchiveIterator(storageSummary.TarFilePath)
+	if err != nil {
+		return nil, err
+	}
+	origPathWithBagName, err := gf.OriginalPathWithBagName()
+	if err != nil {
+		iterator.Close()
+		return nil, err
+	}
+	reader, err := iterator.Find(origPathWithBagName)
+	if err != nil {
+		iterator.Close()
+		return nil, err
+	}
+	return &iteratorReadCloser{ReadCloser: reader, iterator: iterator}, nil
+}
+
+// iteratorReadCloser closes the archive iterator
+// along with the reader it returned.
+type iteratorReadCloser struct {
+	io.ReadCloser
+	iterator fileutil.ArchiveIterator
+}
+
+func (r *iteratorReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.iterator.Close()
+	return err
+}
+
+// deleteUnverifiedCopy deletes a copy of gf that failed verification
+// on our last upload attempt, so nothing mistakes it for a good copy.
+// The file is not marked as stored or replicated, so when the WorkItem
+// is retried, saveFile uploads it again.
+func (storer *APTStorer) deleteUnverifiedCopy(region, bucket string, gf *models.GenericFile) {
+	client := network.NewS3ObjectDelete(
+		os.Getenv("AWS_ACCESS_KEY_ID"),
+		os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		region, bucket, []string{gf.IngestUUID})
+	client.DeleteList()
+	if client.ErrorMessage != "" {
+		storer.Context.MessageLog.Error("Could not delete unverified copy of %s at %s/%s: %s",
+			gf.Identifier, bucket, gf.IngestUUID, client.ErrorMessage)
+	} else {
+		storer.Context.MessageLog.Warning("Deleted unverified copy of %s at %s/%s",
+			gf.Identifier, bucket, gf.IngestUUID)
+	}
+}
+
 // PT #143660373: S3 zero-size file bug.
 func (storer *APTStorer) getS3FileDetail(region, bucket, fileUUID string) *s3.Object {
 	s3Client := network.NewS3ObjectList(
@@ -0,0 +1,80 @@
+package workers_test
+
+import (
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestCheckStorageOptionChange(t *testing.T) {
+	objIdentifier := "test.edu/bag"
+
+	// No change is always OK.
+	for _, policy := range []string{"", constants.StorageOptionChangeKeep, constants.StorageOptionChangeReject} {
+		assert.Nil(t, workers.CheckStorageOptionChange(policy, objIdentifier,
+			constants.StorageStandard, constants.StorageStandard))
+	}
+
+	// The "keep" policy allows the change, and the storer forces
+	// the new version into the original storage option.
+	assert.Nil(t, workers.CheckStorageOptionChange(constants.StorageOptionChangeKeep,
+		objIdentifier, constants.StorageGlacierOH, constants.StorageStandard))
+
+	// The "reject" policy, which is the default, rejects the bag.
+	for _, policy := range []string{"", constants.StorageOptionChangeReject} {
+		err := workers.CheckStorageOptionChange(policy,
+			objIdentifier, constants.StorageGlacierOH, constants.StorageStandard)
+		require.NotNil(t, err, policy)
+		changeErr, ok := err.(*workers.StorageOptionChangeError)
+		require.True(t, ok, policy)
+		assert.Equal(t, objIdentifier, changeErr.ObjIdentifier)
+		assert.Equal(t, constants.StorageGlacierOH, changeErr.Requested)
+		assert.Equal(t, constants.StorageStandard, changeErr.Existing)
+		assert.True(t, strings.Contains(err.Error(), "originally ingested with Storage-Option 'Standard'"))
+	}
+}
+
+func TestCheckStorageKey(t *testing.T) {
+	key := "c4de8b2a-6b3a-4a4e-9b5e-2c1a2b3c4d5e"
+	gf := &models.GenericFile{
+		Identifier:                   "test.edu/bag/data/file.txt",
+		IntellectualObjectIdentifier: "test.edu/bag",
+		IngestUUID:                   key,
+	}
+
+	// Nothing stored, and Pharos knows no other file with this key.
+	assert.Nil(t, workers.CheckStorageKey(gf, nil, nil))
+
+	// Stored on a previous attempt to store this same file.
+	ours := &models.StoredFile{BagName: "test.edu/bag", PathInBag: "data/file.txt"}
+	assert.Nil(t, workers.CheckStorageKey(gf, ours, nil))
+
+	// Another file's object is stored under this key.
+	theirs := &models.StoredFile{Bucket: "preservation", BagName: "test.edu/bag2", PathInBag: "data/x.txt"}
+	err := workers.CheckStorageKey(gf, theirs, nil)
+	require.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "test.edu/bag2/data/x.txt"))
+
+	// Pharos has another file with this key. Files whose URIs
+	// merely contain the key don't count.
+	other := &models.GenericFile{
+		Identifier: "test.edu/bag2/data/x.txt",
+		URI:        "https://s3.amazonaws.com/preservation/" + key,
+	}
+	lookalike := &models.GenericFile{
+		Identifier: "test.edu/bag3/data/y.txt",
+		URI:        "https://s3.amazonaws.com/preservation/" + key + "-2",
+	}
+	assert.Nil(t, workers.CheckStorageKey(gf, nil, []*models.GenericFile{lookalike}))
+	err = workers.CheckStorageKey(gf, nil, []*models.GenericFile{lookalike, other})
+	require.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "already used by test.edu/bag2/data/x.txt"))
+
+	// Pharos returning this same file is fine.
+	self := &models.GenericFile{Identifier: gf.Identifier, URI: other.URI}
+	assert.Nil(t, workers.CheckStorageKey(gf, nil, []*models.GenericFile{self}))
+}
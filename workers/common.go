@@ -6,6 +6,7 @@ import (
 	"github.com/APTrust/exchange/constants"
 	"github.com/APTrust/exchange/context"
 	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
 	"github.com/APTrust/exchange/util"
 	"github.com/APTrust/exchange/util/fileutil"
 	"github.com/APTrust/exchange/validation"
@@ -15,15 +16,22 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 )
 
-var TAR_SUFFIX = regexp.MustCompile("\\.tar$")
+var TAR_SUFFIX = util.SerializedBagSuffix
+
+// WORK_ITEM_BATCH_SIZE is the number of WorkItems SaveWorkItemStatuses
+// and SaveWorkItems save in a single request to Pharos.
+const WORK_ITEM_BATCH_SIZE = 100
 
 func CacheBucketNames(_context *context.Context) error {
+	for _, prefix := range _context.Config.RestorationBucketPrefixes {
+		if !util.StringListContains(util.RestoreBucketPrefixes, prefix) {
+			util.RestoreBucketPrefixes = append(util.RestoreBucketPrefixes, prefix)
+		}
+	}
 	params := url.Values{}
 	params.Add("page", "1")
 	params.Add("per_page", "100")
@@ -35,6 +43,16 @@ func CacheBucketNames(_context *context.Context) error {
 		util.OwnerOfReceivingBucket[inst.ReceivingBucket] = inst.Identifier
 		util.OwnerOfRestoreBucket[inst.RestoreBucket] = inst.Identifier
 		util.RestoreBucketFor[inst.Identifier] = inst.RestoreBucket
+		util.InstitutionIdentifierFor[inst.Id] = inst.Identifier
+	}
+	// Overrides in the config trump what Pharos says.
+	for bucket, institution := range _context.Config.ReceivingBucketOwners() {
+		util.OwnerOfReceivingBucket[bucket] = institution
+	}
+	for institution, override := range _context.Config.InstitutionOverrides {
+		if override != nil && override.RestoreBucket != "" {
+			util.OwnerOfRestoreBucket[override.RestoreBucket] = institution
+		}
 	}
 	_context.MessageLog.Info(
 		"Loaded %d bucket names for institutions", len(resp.Institutions()))
@@ -131,15 +149,26 @@ func GetIngestState(message *nsq.Message, _context *context.Context, initIfEmpty
 	return ingestState, err
 }
 
-// GetWorkItem returns the WorkItem with the specified Id from Pharos,
-// or nil.
+// GetWorkItem returns the WorkItem whose Id is in the NSQ message
+// body from Pharos, or nil. The body may be a QueueMessage or a legacy
+// bare WorkItem.Id. If the QueueMessage specifies an action, it must
+// match the WorkItem's action.
 func GetWorkItem(message *nsq.Message, _context *context.Context) (*models.WorkItem, error) {
 	msgBody := strings.TrimSpace(string(message.Body))
 	_context.MessageLog.Info("NSQ Message body: '%s'", msgBody)
-	workItemId, err := strconv.Atoi(string(msgBody))
-	if err != nil || workItemId == 0 {
+	queueMessage, err := models.ParseNSQMessage(message)
+	if err != nil {
 		return nil, fmt.Errorf("Could not get WorkItemId from NSQ message body: %v", err)
 	}
+	workItemId := queueMessage.WorkItemId
+	if workItemId == 0 {
+		return nil, fmt.Errorf("Could not get WorkItemId from NSQ message body")
+	}
+	if !queueMessage.IsLegacy() {
+		_context.MessageLog.Info("WorkItem %d, trace %s, attempt %d, spent %s in queue",
+			workItemId, queueMessage.TraceId, queueMessage.Attempt,
+			queueMessage.TimeInQueue().String())
+	}
 	resp := _context.PharosClient.WorkItemGet(workItemId)
 	if resp.Error != nil {
 		return nil, fmt.Errorf("Error getting WorkItem %d from Pharos: %v", workItemId, resp.Error)
@@ -148,6 +177,10 @@ func GetWorkItem(message *nsq.Message, _context *context.Context) (*models.WorkI
 	if workItem == nil {
 		return nil, fmt.Errorf("Pharos returned nil for WorkItem %d", workItemId)
 	}
+	if queueMessage.Action != "" && queueMessage.Action != workItem.Action {
+		return nil, fmt.Errorf("NSQ message for WorkItem %d says action is %s, but WorkItem action is %s",
+			workItemId, queueMessage.Action, workItem.Action)
+	}
 	return workItem, nil
 }
 
@@ -162,7 +195,7 @@ func GetWorkItemState(workItem *models.WorkItem, _context *context.Context, init
 	if workItem.WorkItemStateId != nil {
 		workItemStateId = *workItem.WorkItemStateId
 	}
-	resp := _context.PharosClient.WorkItemStateGet(workItemStateId)
+	resp := _context.StateStore().Get(workItemStateId)
 	if resp.Response.StatusCode == http.StatusNotFound {
 		if initIfEmpty {
 			// Record has not been created yet, so build a new one now.
@@ -236,7 +269,7 @@ func RecordWorkItemState(ingestState *models.IngestState, _context *context.Cont
 		// over to Pharos, so the next worker in the chain (the save worker)
 		// can access it.
 		// LogJson(ingestState, _context.JsonLog)
-		resp := _context.PharosClient.WorkItemStateSave(ingestState.WorkItemState)
+		resp := _context.StateStore().Save(ingestState.WorkItemState)
 		if resp.Error != nil {
 			// Could not send a copy of the WorkItemState to Pharos.
 			// That means subsequent workers won't have the info they
@@ -279,6 +312,58 @@ func LoadAPTrustBagValidationConfig(_context *context.Context) *validation.BagVa
 	return bagValidationConfig
 }
 
+// EmbargoCancelReason returns a note explaining why we won't restore
+// an object, or one of its files, for workItem, if the object is under
+// embargo at time now and no APTrust admin approved the restoration.
+// Otherwise, it returns an empty string.
+func EmbargoCancelReason(obj *models.IntellectualObject, workItem *models.WorkItem, now time.Time) string {
+	if !obj.IsEmbargoed(now) || workItem.EmbargoApprover != nil {
+		return ""
+	}
+	return fmt.Sprintf("System cancelled restoration because %s is under embargo "+
+		"until %s. An APTrust admin must approve restorations of embargoed items.",
+		obj.Identifier, obj.EmbargoUntil.Format(constants.EmbargoDateFormat))
+}
+
+// RestoreReceiptKeyEnvVar is the environment variable that holds
+// the key apt_restorer uses to sign restore receipts.
+const RestoreReceiptKeyEnvVar = "RESTORE_RECEIPT_KEY"
+
+// NewRestoreReceipt returns a receipt for the delivery of the tarred
+// bag at restoreState.LocalTarFile to bucket/key, with checksums
+// calculated from the tar file. If signingKey is not empty, the
+// receipt is signed with it.
+func NewRestoreReceipt(restoreState *models.RestoreState, bucket, key string, signingKey []byte) (*models.RestoreReceipt, error) {
+	fileInfo, err := os.Stat(restoreState.LocalTarFile)
+	if err != nil {
+		return nil, err
+	}
+	md5Digest, err := fileutil.CalculateChecksum(restoreState.LocalTarFile, constants.AlgMd5)
+	if err != nil {
+		return nil, err
+	}
+	sha256Digest, err := fileutil.CalculateChecksum(restoreState.LocalTarFile, constants.AlgSha256)
+	if err != nil {
+		return nil, err
+	}
+	receipt := &models.RestoreReceipt{
+		ObjectIdentifier: restoreState.IntellectualObject.Identifier,
+		WorkItemId:       restoreState.WorkItem.Id,
+		Bucket:           bucket,
+		Key:              key,
+		Destination:      restoreState.RestoredToUrl,
+		Size:             fileInfo.Size(),
+		Md5:              md5Digest,
+		Sha256:           sha256Digest,
+		FileCount:        len(restoreState.IntellectualObject.GenericFiles),
+		DeliveredAt:      restoreState.CopiedToRestorationAt.UTC(),
+	}
+	if len(signingKey) > 0 {
+		err = receipt.Sign(signingKey)
+	}
+	return receipt, err
+}
+
 // MarkWorkItemFailed tells Pharos that this item failed processing
 // due to a fatal error or too many unsuccessful attempts.
 func MarkWorkItemFailed(ingestState *models.IngestState, _context *context.Context) error {
@@ -381,6 +466,14 @@ func MarkWorkItemSucceeded(ingestState *models.IngestState, _context *context.Co
 			ingestState.WorkItem.Bucket, ingestState.WorkItem.Name)
 		ingestState.WorkItem.Note = fmt.Sprintf("Item is ready for %s", nextStage)
 	}
+	if validateResult := ingestState.IngestManifest.ValidateResult; validateResult != nil && validateResult.HasWarnings() {
+		ingestState.WorkItem.Note += ". Validation warnings: " +
+			strings.Join(validateResult.WarningMessages(), " ")
+	}
+	if storeResult := ingestState.IngestManifest.StoreResult; storeResult != nil && storeResult.HasWarnings() {
+		ingestState.WorkItem.Note += ". Storage warnings: " +
+			strings.Join(storeResult.WarningMessages(), " ")
+	}
 	ingestState.WorkItem.Date = time.Now().UTC()
 	ingestState.WorkItem.Node = ""
 	ingestState.WorkItem.Pid = 0
@@ -406,9 +499,9 @@ func MarkWorkItemSucceeded(ingestState *models.IngestState, _context *context.Co
 // PushToQueue pushes the WorkItem in ingestState into the specified
 // NSQ topic.
 func PushToQueue(ingestState *models.IngestState, _context *context.Context, queueTopic string) {
-	err := _context.NSQClient.Enqueue(
+	err := _context.NSQClient.EnqueueWorkItem(
 		queueTopic,
-		ingestState.WorkItem.Id)
+		ingestState.WorkItem)
 	if err != nil {
 		msg := fmt.Sprintf("Error adding WorkItem %d (%s/%s) to NSQ record topic: %v",
 			ingestState.WorkItem.Id, ingestState.WorkItem.Bucket,
@@ -421,6 +514,14 @@ func PushToQueue(ingestState *models.IngestState, _context *context.Context, que
 	}
 }
 
+// EnqueueByPriority adds workItem to the specified NSQ topic, asking
+// NSQ to hold it back for the delay that Config.PriorityDelays assigns
+// to the item's priority. It returns the delay it used.
+func EnqueueByPriority(_context *context.Context, client *network.NSQClient, topic string, workItem *models.WorkItem) (time.Duration, error) {
+	delay := _context.Config.PriorityDelay(workItem.EffectivePriority())
+	return delay, client.EnqueueWorkItemDeferred(topic, workItem, delay)
+}
+
 // LogJson dumps the WorkItemState.State into the JSON log, surrounded by
 // markers that make it easy to find. This log gets big.
 func LogJson(ingestState *models.IngestState, jsonLog *log.Logger) {
@@ -459,7 +560,7 @@ func DeleteFileFromStaging(pathToFile string, _context *context.Context) {
 		} else {
 			_context.MessageLog.Info("Deleted %s", pathToFile)
 		}
-		if _context.Config.UseVolumeService && strings.HasSuffix(pathToFile, ".tar") {
+		if _context.Config.UseVolumeService && util.IsSerializedBag(pathToFile) {
 			err = _context.VolumeClient.Release(pathToFile)
 			if err != nil {
 				_context.MessageLog.Warning(err.Error())
@@ -470,6 +571,298 @@ func DeleteFileFromStaging(pathToFile string, _context *context.Context) {
 	}
 }
 
+// ValidationProgressLogger returns a Progress callback for the Validator
+// that logs progress in validating the bag at bagPath, and calls touch
+// to tell NSQ we're still working on the message. It does these things
+// when the validator starts a new phase, and otherwise no more often
+// than once per interval. Validating a large bag can take hours, and
+// without this, the worker appears to be hung.
+func ValidationProgressLogger(_context *context.Context, bagPath string, touch func(), interval time.Duration) func(validation.ValidationProgress) {
+	lastPhase := ""
+	lastLogged := time.Time{}
+	return func(progress validation.ValidationProgress) {
+		if progress.Phase == lastPhase && time.Since(lastLogged) < interval {
+			return
+		}
+		lastPhase = progress.Phase
+		lastLogged = time.Now()
+		_context.MessageLog.Info("Validating %s: %s, %d files processed, %d bytes hashed",
+			bagPath, progress.Phase, progress.FilesProcessed, progress.BytesHashed)
+		touch()
+	}
+}
+
+// InstitutionOf returns the identifier of the institution that owns
+// the WorkItem, based on its object identifier (e.g. "test.edu/bag"
+// belongs to "test.edu"). Falls back to the owner of the receiving
+// bucket for items that have no object identifier yet.
+func InstitutionOf(workItem *models.WorkItem) string {
+	if workItem.ObjectIdentifier != "" {
+		return strings.Split(workItem.ObjectIdentifier, "/")[0]
+	}
+	return util.OwnerOf(workItem.Bucket)
+}
+
+// CheckInstitutionConsistency returns an error if the institution that
+// owns workItem according to its InstitutionId, the institutions implied
+// by its object and file identifiers and by identifiers, and the owner
+// of bucket do not all agree. Workers call this before they write or
+// delete anything, so a bad WorkItem can't move one depositor's data
+// into another's bucket or delete another depositor's files. Values
+// that are empty or unknown are not checked. The InstitutionId is
+// checked only after CacheBucketNames has loaded the institutions.
+func CheckInstitutionConsistency(workItem *models.WorkItem, bucket string, identifiers ...string) error {
+	sources := make([]string, 0)
+	owners := make([]string, 0)
+	if inst, ok := util.InstitutionIdentifierFor[workItem.InstitutionId]; ok {
+		sources = append(sources, fmt.Sprintf("InstitutionId %d", workItem.InstitutionId))
+		owners = append(owners, inst)
+	}
+	allIdentifiers := append([]string{workItem.ObjectIdentifier,
+		workItem.GenericFileIdentifier}, identifiers...)
+	for _, identifier := range allIdentifiers {
+		if identifier != "" && strings.Contains(identifier, "/") {
+			sources = append(sources, fmt.Sprintf("identifier %s", identifier))
+			owners = append(owners, strings.Split(identifier, "/")[0])
+		}
+	}
+	if bucket != "" && util.OwnerOf(bucket) != "" {
+		sources = append(sources, fmt.Sprintf("bucket %s", bucket))
+		owners = append(owners, util.OwnerOf(bucket))
+	}
+	for i := 1; i < len(owners); i++ {
+		if owners[i] != owners[0] {
+			return fmt.Errorf("Institution mismatch for WorkItem %d: %s belongs to %s, "+
+				"but %s belongs to %s. Nothing was written or deleted. "+
+				"An administrator must review this item.",
+				workItem.Id, sources[0], owners[0], sources[i], owners[i])
+		}
+	}
+	return nil
+}
+
+// AssertIngestInstitution checks that the institutions implied by the
+// ingest WorkItem, its object and its receiving bucket agree. If they
+// don't, it adds a fatal error to summary, marks the WorkItem failed
+// and in need of admin review, finishes the NSQ message, and returns
+// false. Ingest workers call this before they start work on an item.
+func AssertIngestInstitution(ingestState *models.IngestState, _context *context.Context, summary *models.WorkSummary) bool {
+	objIdentifier := ""
+	if ingestState.IngestManifest.Object != nil {
+		objIdentifier = ingestState.IngestManifest.Object.Identifier
+	}
+	err := CheckInstitutionConsistency(ingestState.WorkItem,
+		ingestState.WorkItem.Bucket, objIdentifier)
+	if err == nil {
+		return true
+	}
+	_context.MessageLog.Error(err.Error())
+	summary.AddError(err.Error())
+	summary.ErrorIsFatal = true
+	MarkWorkItemFailed(ingestState, _context)
+	ingestState.FinishNSQ()
+	return false
+}
+
+// ReconcileStartedWorkItems finds WorkItems for the specified action
+// that Pharos says are Started on this host by a worker process that
+// is no longer running, as happens when a node crashes or a worker is
+// killed. If stages are specified, it considers only items in those
+// stages, since ingest workers on one host share an action. It resets
+// each item to Pending with QueuedAt cleared, so apt_queue will start
+// it over, then pushes items that have a WorkItemState right back into
+// queueTopic, so they can resume. Workers should call this once at
+// startup, before they begin consuming from NSQ. Returns the number of
+// items reconciled.
+func ReconcileStartedWorkItems(_context *context.Context, queueTopic, action string, stages ...string) (int, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return 0, fmt.Errorf("Cannot reconcile started WorkItems: %v", err)
+	}
+	params := url.Values{}
+	params.Set("item_action", action)
+	params.Set("status", constants.StatusStarted)
+	params.Set("node", hostname)
+	params.Set("page", "1")
+	params.Set("per_page", "100")
+	orphans := make([]*models.WorkItem, 0)
+	for {
+		resp := _context.PharosClient.WorkItemList(params)
+		if resp.Error != nil {
+			return 0, fmt.Errorf("Error getting started WorkItems from Pharos: %v", resp.Error)
+		}
+		for _, item := range resp.WorkItems() {
+			// Pharos may not filter on node, so check here too.
+			if item.IsOrphanedOnThisNode() && (len(stages) == 0 || util.StringListContains(stages, item.Stage)) {
+				orphans = append(orphans, item)
+			}
+		}
+		if resp.HasNextPage() == false {
+			break
+		}
+		params = resp.ParamsForNextPage()
+	}
+	// Update the orphans after we're done paging, so our own
+	// changes don't shift items across page boundaries.
+	for _, item := range orphans {
+		resetStartedWorkItem(item, hostname)
+	}
+	reconciledItems, errs := SaveWorkItemStatuses(_context, orphans)
+	for _, err := range errs {
+		_context.MessageLog.Error("Could not reconcile orphaned WorkItem: %v", err)
+	}
+	requeued := make([]*models.WorkItem, 0)
+	for _, item := range reconciledItems {
+		_context.MessageLog.Info("WorkItem %d: %s", item.Id, item.Note)
+		hasState := item.WorkItemStateId != nil && *item.WorkItemStateId != 0
+		if !hasState || queueTopic == "" {
+			continue
+		}
+		err := _context.NSQClient.EnqueueWorkItem(queueTopic, item)
+		if err != nil {
+			// Not fatal. QueuedAt is nil, so apt_queue will get it.
+			_context.MessageLog.Warning("Could not requeue WorkItem %d to %s: %v",
+				item.Id, queueTopic, err)
+			continue
+		}
+		utcNow := time.Now().UTC()
+		item.QueuedAt = &utcNow
+		requeued = append(requeued, item)
+	}
+	if len(requeued) > 0 {
+		_, errs = SaveWorkItemStatuses(_context, requeued)
+		for _, err := range errs {
+			_context.MessageLog.Warning("Requeued WorkItem to %s, but could not "+
+				"set QueuedAt: %v", queueTopic, err)
+		}
+	}
+	_context.MessageLog.Info("Reconciled %d of %d orphaned %s WorkItems on %s",
+		len(reconciledItems), len(orphans), action, hostname)
+	return len(reconciledItems), nil
+}
+
+// resetStartedWorkItem resets a single orphaned WorkItem to Pending,
+// with a note saying whether it will resume from saved state. The
+// caller must save the item.
+func resetStartedWorkItem(item *models.WorkItem, hostname string) {
+	hasState := item.WorkItemStateId != nil && *item.WorkItemStateId != 0
+	stalePid := item.Pid
+	item.Date = time.Now().UTC()
+	item.Node = ""
+	item.Pid = 0
+	item.StageStartedAt = nil
+	item.Status = constants.StatusPending
+	item.Retry = true
+	item.QueuedAt = nil
+	if hasState {
+		item.Note = fmt.Sprintf("Worker (pid %d) on %s stopped before finishing %s. "+
+			"Resuming from saved state.", stalePid, hostname, item.Stage)
+	} else {
+		item.Note = fmt.Sprintf("Worker (pid %d) on %s stopped before finishing %s. "+
+			"No saved state. Item reset to pending.", stalePid, hostname, item.Stage)
+	}
+}
+
+// EnablePharosCache turns on the context's Pharos client cache, if
+// workerConfig.PharosCacheTTL is set.
+func EnablePharosCache(_context *context.Context, workerConfig *models.WorkerConfig) {
+	if workerConfig.PharosCacheTTL == "" {
+		return
+	}
+	ttl, err := time.ParseDuration(workerConfig.PharosCacheTTL)
+	if err != nil {
+		_context.MessageLog.Warning("Not caching Pharos records: bad PharosCacheTTL '%s': %v",
+			workerConfig.PharosCacheTTL, err)
+		return
+	}
+	_context.PharosClient.EnableCache(ttl)
+	_context.MessageLog.Info("Caching Pharos institution and object records for %s", ttl.String())
+}
+
+// SaveWorkItemStatuses saves the status of each of the specified
+// WorkItems to Pharos, WORK_ITEM_BATCH_SIZE items per request, rather
+// than one request per item. If Pharos rejects a batch, we fall back
+// to saving that batch's items one at a time, so one bad item doesn't
+// keep the others from being saved. Returns the copies Pharos returned
+// of the items that were saved, and an error for each item that wasn't.
+func SaveWorkItemStatuses(_context *context.Context, items []*models.WorkItem) ([]*models.WorkItem, []error) {
+	return saveWorkItemsInBatches(_context, items, "update",
+		_context.PharosClient.WorkItemStatusUpdateBatch)
+}
+
+// SaveWorkItems saves each of the specified WorkItems to Pharos,
+// creating those with an Id of zero and updating the rest. Like
+// SaveWorkItemStatuses, it sends WORK_ITEM_BATCH_SIZE items per
+// request, and falls back to saving a rejected batch's items one at a
+// time. But if a batch with new items fails with a 5xx response or a
+// connection error, Pharos may have created them anyway, so this
+// reports each item in the batch as failed rather than creating them
+// again. The caller should try those items again later, after
+// checking whether they exist. Returns the copies Pharos returned of
+// the items that were saved, which include the ids of new items, and
+// an error for each item that wasn't.
+func SaveWorkItems(_context *context.Context, items []*models.WorkItem) ([]*models.WorkItem, []error) {
+	return saveWorkItemsInBatches(_context, items, "save",
+		_context.PharosClient.WorkItemBatchSave)
+}
+
+// saveWorkItemsInBatches saves items with saveBatch,
+// WORK_ITEM_BATCH_SIZE items at a time, and saves the items in any
+// batch that fails one at a time with WorkItemSave, unless that could
+// create duplicates. See SaveWorkItems. Param operation describes
+// saveBatch in log messages. Returns the copies Pharos returned of
+// the items that were saved, and an error for each item that wasn't.
+func saveWorkItemsInBatches(_context *context.Context, items []*models.WorkItem, operation string, saveBatch func([]*models.WorkItem) *network.PharosResponse) ([]*models.WorkItem, []error) {
+	saved := make([]*models.WorkItem, 0)
+	errs := make([]error, 0)
+	for start := 0; start < len(items); start += WORK_ITEM_BATCH_SIZE {
+		end := start + WORK_ITEM_BATCH_SIZE
+		if end > len(items) {
+			end = len(items)
+		}
+		batch := items[start:end]
+		resp := saveBatch(batch)
+		if resp.Error == nil {
+			saved = append(saved, resp.WorkItems()...)
+			continue
+		}
+		rejected := resp.Response != nil && resp.Response.StatusCode >= 400 &&
+			resp.Response.StatusCode < 500
+		if !rejected && hasNewWorkItems(batch) {
+			_context.MessageLog.Warning("Batch %s of %d WorkItems failed, and Pharos "+
+				"may have created the new ones anyway. Not saving them one at a "+
+				"time. Error: %v", operation, len(batch), resp.Error)
+			for _, item := range batch {
+				errs = append(errs, fmt.Errorf("WorkItem %d (%s/%s): %v",
+					item.Id, item.Bucket, item.Name, resp.Error))
+			}
+			continue
+		}
+		_context.MessageLog.Warning("Batch %s of %d WorkItems failed. "+
+			"Saving them one at a time. Error: %v", operation, len(batch), resp.Error)
+		for _, item := range batch {
+			resp = _context.PharosClient.WorkItemSave(item)
+			if resp.Error != nil {
+				errs = append(errs, fmt.Errorf("WorkItem %d (%s/%s): %v",
+					item.Id, item.Bucket, item.Name, resp.Error))
+				continue
+			}
+			saved = append(saved, resp.WorkItem())
+		}
+	}
+	return saved, errs
+}
+
+// hasNewWorkItems returns true if any of items has an Id of zero.
+func hasNewWorkItems(items []*models.WorkItem) bool {
+	for _, item := range items {
+		if item.Id == 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // SetupIngestState sets up the IngestState object that the
 // workers use during the ingest process.
 func SetupIngestState(message *nsq.Message, _context *context.Context) (*models.IngestState, error) {
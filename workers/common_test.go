@@ -0,0 +1,288 @@
+package workers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"github.com/APTrust/exchange/util"
+	"github.com/APTrust/exchange/util/fileutil"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/APTrust/exchange/validation"
+	"github.com/APTrust/exchange/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidationProgressLogger(t *testing.T) {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+	touches := 0
+	touch := func() { touches++ }
+	progressLogger := workers.ValidationProgressLogger(_context, "/mnt/bag.tar", touch, time.Hour)
+
+	// Should log and touch at the start of each phase, but otherwise
+	// no more than once per interval.
+	progressLogger(validation.ValidationProgress{Phase: validation.PhaseAddingFiles})
+	for i := int64(1); i <= 10; i++ {
+		progressLogger(validation.ValidationProgress{Phase: validation.PhaseAddingFiles, FilesProcessed: i})
+	}
+	assert.Equal(t, 1, touches)
+	progressLogger(validation.ValidationProgress{Phase: validation.PhaseParsingFiles})
+	progressLogger(validation.ValidationProgress{Phase: validation.PhaseParsingFiles, FilesProcessed: 1})
+	assert.Equal(t, 2, touches)
+
+	progressLogger = workers.ValidationProgressLogger(_context, "/mnt/bag.tar", touch, 0)
+	progressLogger(validation.ValidationProgress{Phase: validation.PhaseAddingFiles, FilesProcessed: 1})
+	progressLogger(validation.ValidationProgress{Phase: validation.PhaseAddingFiles, FilesProcessed: 2})
+	assert.Equal(t, 4, touches)
+}
+
+func TestEmbargoCancelReason(t *testing.T) {
+	obj := models.NewIntellectualObject()
+	obj.Identifier = "test.edu/bag"
+	workItem := &models.WorkItem{}
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Empty(t, workers.EmbargoCancelReason(obj, workItem, now))
+
+	obj.EmbargoUntil = time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "System cancelled restoration because test.edu/bag is under embargo "+
+		"until 2030-01-31. An APTrust admin must approve restorations of embargoed items.",
+		workers.EmbargoCancelReason(obj, workItem, now))
+	assert.Empty(t, workers.EmbargoCancelReason(obj, workItem, obj.EmbargoUntil))
+
+	approver := "admin@aptrust.org"
+	workItem.EmbargoApprover = &approver
+	assert.Empty(t, workers.EmbargoCancelReason(obj, workItem, now))
+}
+
+func TestNewRestoreReceipt(t *testing.T) {
+	tarFile, err := ioutil.TempFile("", "restore_receipt_test.tar")
+	require.Nil(t, err)
+	defer os.Remove(tarFile.Name())
+	_, err = tarFile.WriteString("Not really a tar file")
+	require.Nil(t, err)
+	tarFile.Close()
+
+	restoreState := models.NewRestoreState(testutil.MakeNsqMessage("999"))
+	restoreState.WorkItem = &models.WorkItem{Id: 999}
+	restoreState.IntellectualObject = testutil.MakeIntellectualObject(3, 0, 0, 0)
+	restoreState.LocalTarFile = tarFile.Name()
+	restoreState.RestoredToUrl = "https://s3.amazonaws.com/aptrust.restore.test.edu/bag.tar"
+	restoreState.CopiedToRestorationAt = time.Now()
+
+	receipt, err := workers.NewRestoreReceipt(restoreState, "aptrust.restore.test.edu", "bag.tar", nil)
+	require.Nil(t, err)
+	assert.Equal(t, restoreState.IntellectualObject.Identifier, receipt.ObjectIdentifier)
+	assert.Equal(t, 999, receipt.WorkItemId)
+	assert.Equal(t, "aptrust.restore.test.edu", receipt.Bucket)
+	assert.Equal(t, "bag.tar", receipt.Key)
+	assert.Equal(t, restoreState.RestoredToUrl, receipt.Destination)
+	assert.EqualValues(t, 21, receipt.Size)
+	md5Digest, _ := fileutil.CalculateChecksum(tarFile.Name(), constants.AlgMd5)
+	sha256Digest, _ := fileutil.CalculateChecksum(tarFile.Name(), constants.AlgSha256)
+	assert.Equal(t, md5Digest, receipt.Md5)
+	assert.Equal(t, sha256Digest, receipt.Sha256)
+	assert.Equal(t, 3, receipt.FileCount)
+	assert.Equal(t, time.UTC, receipt.DeliveredAt.Location())
+	assert.Empty(t, receipt.Signature)
+
+	receipt, err = workers.NewRestoreReceipt(restoreState, "aptrust.restore.test.edu", "bag.tar", []byte("secret"))
+	require.Nil(t, err)
+	assert.True(t, receipt.HasValidSignature([]byte("secret")))
+
+	restoreState.LocalTarFile = "/no/such/file.tar"
+	_, err = workers.NewRestoreReceipt(restoreState, "aptrust.restore.test.edu", "bag.tar", nil)
+	assert.NotNil(t, err)
+}
+
+func TestCheckInstitutionConsistency(t *testing.T) {
+	util.InstitutionIdentifierFor[9001] = "test.edu"
+	defer delete(util.InstitutionIdentifierFor, 9001)
+
+	workItem := &models.WorkItem{
+		Id:               33,
+		InstitutionId:    9001,
+		ObjectIdentifier: "test.edu/bag",
+		Bucket:           "aptrust.receiving.test.test.edu",
+	}
+	assert.Nil(t, workers.CheckInstitutionConsistency(workItem, workItem.Bucket))
+	assert.Nil(t, workers.CheckInstitutionConsistency(workItem,
+		"aptrust.restore.test.test.edu", "test.edu/bag/data/file.txt"))
+
+	// Bucket belongs to someone else
+	err := workers.CheckInstitutionConsistency(workItem, "aptrust.restore.example.edu")
+	require.NotNil(t, err)
+	assert.Equal(t, "Institution mismatch for WorkItem 33: InstitutionId 9001 belongs "+
+		"to test.edu, but bucket aptrust.restore.example.edu belongs to example.edu. "+
+		"Nothing was written or deleted. An administrator must review this item.", err.Error())
+
+	// File identifier belongs to someone else
+	workItem.GenericFileIdentifier = "example.edu/bag/data/file.txt"
+	err = workers.CheckInstitutionConsistency(workItem, "")
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "identifier example.edu/bag/data/file.txt belongs to example.edu")
+	workItem.GenericFileIdentifier = ""
+
+	// InstitutionId belongs to someone else
+	workItem.InstitutionId = 9002
+	util.InstitutionIdentifierFor[9002] = "example.edu"
+	defer delete(util.InstitutionIdentifierFor, 9002)
+	err = workers.CheckInstitutionConsistency(workItem, workItem.Bucket)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "identifier test.edu/bag belongs to test.edu")
+
+	// Unknown InstitutionId and no object identifier: nothing to compare
+	workItem.InstitutionId = 12345
+	workItem.ObjectIdentifier = ""
+	assert.Nil(t, workers.CheckInstitutionConsistency(workItem, workItem.Bucket))
+}
+
+// workItemStatusHandler accepts batch updates and saves unless
+// batchFails is true, in which case it responds with batchStatus, or
+// 404 if that's not set. It accepts single WorkItem updates for
+// every item except those with id 13.
+type workItemStatusHandler struct {
+	batchFails    bool
+	batchStatus   int
+	batchRequests int
+	itemRequests  int
+}
+
+func (h *workItemStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if strings.Contains(r.URL.Path, "update_batch") || strings.Contains(r.URL.Path, "save_batch") {
+		h.batchRequests += 1
+		if h.batchFails {
+			status := http.StatusNotFound
+			if h.batchStatus != 0 {
+				status = h.batchStatus
+			}
+			w.WriteHeader(status)
+			fmt.Fprintln(w, `{"status":"not found"}`)
+			return
+		}
+		batch := make([]*models.WorkItem, 0)
+		json.NewDecoder(r.Body).Decode(&batch)
+		data := map[string]interface{}{"count": len(batch), "results": batch}
+		listJson, _ := json.Marshal(data)
+		fmt.Fprintln(w, string(listJson))
+		return
+	}
+	h.itemRequests += 1
+	item := &models.WorkItem{}
+	json.NewDecoder(r.Body).Decode(item)
+	if strings.HasSuffix(r.URL.Path, "/13/") {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintln(w, `{"status":"error"}`)
+		return
+	}
+	itemJson, _ := json.Marshal(item)
+	fmt.Fprintln(w, string(itemJson))
+}
+
+func makeWorkItemsForStatusTest(count int) []*models.WorkItem {
+	items := make([]*models.WorkItem, count)
+	for i := range items {
+		items[i] = testutil.MakeWorkItem()
+		items[i].Id = i + 1
+		items[i].Status = constants.StatusPending
+	}
+	return items
+}
+
+func TestSaveWorkItemStatuses(t *testing.T) {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+	handler := &workItemStatusHandler{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	_context.PharosClient, err = network.NewPharosClient(server.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	// Items should go in batches of WORK_ITEM_BATCH_SIZE.
+	items := makeWorkItemsForStatusTest(workers.WORK_ITEM_BATCH_SIZE + 5)
+	saved, errs := workers.SaveWorkItemStatuses(_context, items)
+	assert.Empty(t, errs)
+	assert.Equal(t, len(items), len(saved))
+	assert.Equal(t, 2, handler.batchRequests)
+	assert.Equal(t, 0, handler.itemRequests)
+
+	// Nothing to save, no requests.
+	handler.batchRequests = 0
+	saved, errs = workers.SaveWorkItemStatuses(_context, []*models.WorkItem{})
+	assert.Empty(t, errs)
+	assert.Empty(t, saved)
+	assert.Equal(t, 0, handler.batchRequests)
+
+	// If the batch fails, we should save items one at a time,
+	// and report the ones that fail.
+	handler.batchFails = true
+	items = makeWorkItemsForStatusTest(20)
+	saved, errs = workers.SaveWorkItemStatuses(_context, items)
+	assert.Equal(t, 1, handler.batchRequests)
+	assert.Equal(t, 20, handler.itemRequests)
+	assert.Equal(t, 19, len(saved))
+	require.Equal(t, 1, len(errs))
+	assert.True(t, strings.HasPrefix(errs[0].Error(), "WorkItem 13 "))
+
+	// Saved items are the copies Pharos returned, not the originals.
+	handler.batchFails = false
+	items = makeWorkItemsForStatusTest(3)
+	saved, errs = workers.SaveWorkItemStatuses(_context, items)
+	assert.Empty(t, errs)
+	require.Equal(t, 3, len(saved))
+	for i, item := range saved {
+		assert.False(t, item == items[i])
+		assert.Equal(t, items[i].Id, item.Id)
+		assert.Equal(t, constants.StatusPending, item.Status)
+	}
+}
+
+func TestSaveWorkItemsWithNewItems(t *testing.T) {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+	handler := &workItemStatusHandler{batchFails: true, batchStatus: http.StatusBadGateway}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	_context.PharosClient, err = network.NewPharosClient(server.URL, "v2", "user", "key")
+	require.Nil(t, err)
+
+	// Pharos may have created the new items before the batch
+	// failed, so we must not create them again one at a time.
+	items := makeWorkItemsForStatusTest(3)
+	items[1].Id = 0
+	saved, errs := workers.SaveWorkItems(_context, items)
+	assert.Empty(t, saved)
+	assert.Equal(t, 3, len(errs))
+	assert.Equal(t, 1, handler.batchRequests)
+	assert.Equal(t, 0, handler.itemRequests)
+
+	// If Pharos rejected the batch, it created nothing, so we can
+	// save the items one at a time.
+	handler.batchStatus = http.StatusUnprocessableEntity
+	handler.batchRequests = 0
+	saved, errs = workers.SaveWorkItems(_context, items)
+	assert.Empty(t, errs)
+	assert.Equal(t, 3, len(saved))
+	assert.Equal(t, 1, handler.batchRequests)
+	assert.Equal(t, 3, handler.itemRequests)
+
+	// A batch of existing items is safe to save one at a time
+	// after any failure.
+	handler.batchStatus = http.StatusBadGateway
+	handler.itemRequests = 0
+	items[1].Id = 2
+	saved, errs = workers.SaveWorkItems(_context, items)
+	assert.Empty(t, errs)
+	assert.Equal(t, 3, len(saved))
+	assert.Equal(t, 3, handler.itemRequests)
+}
@@ -0,0 +1,205 @@
+package workers
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"github.com/APTrust/exchange/util"
+	"math/rand"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// fileAuditResult is the outcome of auditing one GenericFile.
+type fileAuditResult interface {
+	HasProblems() bool
+	ToJson() (string, error)
+	ToCSV(delimiter rune) (string, error)
+}
+
+// fileAuditCheck audits one GenericFile. It returns an error if it
+// could not complete the check.
+type fileAuditCheck func(gf *models.GenericFile) (fileAuditResult, error)
+
+// fileAuditor runs a fileAuditCheck on each active GenericFile in
+// Pharos that matches its filters, running up to concurrency checks
+// at once. It prints one record for each file with a problem to
+// STDOUT (or one for every file, if showAll is true), and errors to
+// STDERR. APTChecksumAudit and APTStorageKeyAudit are built on it.
+type fileAuditor struct {
+	context        *context.Context
+	institution    string
+	identifierLike string
+	format         string
+	csvDelimiter   rune
+	limit          int
+	sampleRate     float64
+	concurrency    int
+	showAll        bool
+	checked        int
+	problems       int
+	errOccurred    bool
+	mutex          *sync.Mutex
+}
+
+// newFileAuditor returns a new fileAuditor. See NewAPTChecksumAudit
+// for a description of the params.
+func newFileAuditor(context *context.Context, institution, identifierLike, format string, limit int, sampleRate float64, concurrency int, showAll bool) (*fileAuditor, error) {
+	if context == nil {
+		return nil, fmt.Errorf("Param context cannot be nil")
+	}
+	if format != "json" && format != "csv" && format != "tsv" {
+		return nil, fmt.Errorf("Param format must be json, csv, or tsv")
+	}
+	if sampleRate <= 0 || sampleRate > 1 {
+		return nil, fmt.Errorf("Param sampleRate must be greater than zero and no more than 1.0")
+	}
+	if concurrency > 32 {
+		return nil, fmt.Errorf("Param concurrency can be no higher than 32")
+	}
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	delimiter := ','
+	if format == "tsv" {
+		delimiter = '\t'
+	}
+	return &fileAuditor{
+		context:        context,
+		institution:    institution,
+		identifierLike: identifierLike,
+		format:         format,
+		csvDelimiter:   delimiter,
+		limit:          limit,
+		sampleRate:     sampleRate,
+		concurrency:    concurrency,
+		showAll:        showAll,
+		mutex:          &sync.Mutex{},
+	}, nil
+}
+
+// run checks the files and returns the number of files checked, the
+// number of files with problems, and an error if it could not
+// complete the audit.
+func (auditor *fileAuditor) run(check fileAuditCheck) (int, int, error) {
+	perPage := 100
+	if auditor.limit > 0 {
+		perPage = util.Min(perPage, auditor.limit)
+	}
+	params := url.Values{}
+	params.Set("include_relations", "true")
+	params.Set("state", "A")
+	if auditor.institution != "" {
+		params.Set("institution_identifier", auditor.institution)
+	}
+	if auditor.identifierLike != "" {
+		params.Set("identifier_like", auditor.identifierLike)
+	}
+	iterator := auditor.context.PharosClient.GenericFileIterator(params, perPage)
+	batch := make([]*models.GenericFile, 0, perPage)
+	selected := 0
+	for iterator.Next() {
+		if auditor.sampleRate < 1 && rand.Float64() >= auditor.sampleRate {
+			continue
+		}
+		batch = append(batch, iterator.GenericFile())
+		selected += 1
+		if len(batch) == perPage {
+			auditor.auditBatch(batch, check)
+			batch = make([]*models.GenericFile, 0, perPage)
+		}
+		if auditor.limit > 0 && selected >= auditor.limit {
+			break
+		}
+	}
+	auditor.auditBatch(batch, check)
+	if iterator.Err() != nil {
+		fmt.Fprintln(os.Stderr, "Error getting GenericFile list from Pharos:", iterator.Err())
+		return auditor.checked, auditor.problems, iterator.Err()
+	}
+	var err error
+	if auditor.errOccurred {
+		err = fmt.Errorf("One or more files could not be audited. See STDERR for details.")
+	}
+	return auditor.checked, auditor.problems, err
+}
+
+// auditBatch checks a batch of files, running up to
+// auditor.concurrency checks at once.
+func (auditor *fileAuditor) auditBatch(files []*models.GenericFile, check fileAuditCheck) {
+	wg := sync.WaitGroup{}
+	semaphore := make(chan struct{}, auditor.concurrency)
+	for _, gf := range files {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(gf *models.GenericFile) {
+			defer wg.Done()
+			result, err := check(gf)
+			if err != nil {
+				auditor.printError(gf.Identifier, err.Error())
+			} else {
+				auditor.printResult(gf.Identifier, result)
+			}
+			<-semaphore
+		}(gf)
+	}
+	wg.Wait()
+}
+
+// headStoredFile issues a HEAD request for key in bucket, in the
+// region where gf's storage option keeps files. It returns an error
+// only if it can't tell which region that is. Check the returned
+// client's ErrorMessage for errors from S3.
+func (auditor *fileAuditor) headStoredFile(gf *models.GenericFile, bucket, key string) (*network.S3Head, error) {
+	storageOption := gf.StorageOption
+	if storageOption == "" {
+		storageOption = constants.StorageStandard
+	}
+	region, _, err := auditor.context.Config.StorageRegionAndBucketFor(storageOption)
+	if err != nil {
+		return nil, err
+	}
+	client := network.NewS3Head(
+		os.Getenv("AWS_ACCESS_KEY_ID"),
+		os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		region, bucket)
+	client.SessionPool = auditor.context.S3SessionPool
+	client.Head(key)
+	return client, nil
+}
+
+// printResult records the result of one file's audit, and prints
+// it if it found a problem or if we're showing all results.
+func (auditor *fileAuditor) printResult(gfIdentifier string, result fileAuditResult) {
+	strRecord := ""
+	var err error
+	if auditor.format == "json" {
+		strRecord, err = result.ToJson()
+		strRecord += "\n"
+	} else {
+		strRecord, err = result.ToCSV(auditor.csvDelimiter)
+	}
+	auditor.mutex.Lock()
+	defer auditor.mutex.Unlock()
+	auditor.checked += 1
+	if result.HasProblems() {
+		auditor.problems += 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "[", gfIdentifier, "]", err.Error())
+		auditor.errOccurred = true
+	} else if result.HasProblems() || auditor.showAll {
+		fmt.Print(strRecord)
+	}
+}
+
+// printError prints an error for a file we could not audit.
+func (auditor *fileAuditor) printError(gfIdentifier, message string) {
+	auditor.mutex.Lock()
+	defer auditor.mutex.Unlock()
+	fmt.Fprintln(os.Stderr, "[", gfIdentifier, "]", message)
+	auditor.errOccurred = true
+}
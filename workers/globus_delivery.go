@@ -0,0 +1,135 @@
+package workers
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Environment variables that hold the Globus Auth client credentials
+// apt_restorer uses to submit Globus transfers.
+const (
+	GlobusClientIdEnvVar     = "GLOBUS_CLIENT_ID"
+	GlobusClientSecretEnvVar = "GLOBUS_CLIENT_SECRET"
+)
+
+// GlobusDelivery delivers restored bags to depositors' Globus
+// collections, for depositors who would rather not pull their bags
+// out of S3. See Config.GlobusDestinations.
+type GlobusDelivery struct {
+	Config *models.Config
+	Client *network.GlobusTransferClient
+	// PollInterval is how long to wait between checks on a
+	// transfer's progress.
+	PollInterval time.Duration
+	// Deadline is how long Globus has to finish a transfer before
+	// it gives up. We submit a new transfer on the next attempt.
+	Deadline time.Duration
+}
+
+// NewGlobusDelivery returns a GlobusDelivery that authenticates with
+// the credentials in the GLOBUS_CLIENT_ID and GLOBUS_CLIENT_SECRET
+// environment variables.
+func NewGlobusDelivery(config *models.Config) *GlobusDelivery {
+	return &GlobusDelivery{
+		Config: config,
+		Client: network.NewGlobusTransferClient(
+			os.Getenv(GlobusClientIdEnvVar),
+			os.Getenv(GlobusClientSecretEnvVar)),
+		PollInterval: 30 * time.Second,
+		Deadline:     24 * time.Hour,
+	}
+}
+
+// DestinationFor returns the Globus collection to which the specified
+// institution's bags should go, or nil if the institution's bags go
+// to its S3 restoration bucket.
+func (delivery *GlobusDelivery) DestinationFor(institution string) *models.GlobusDestination {
+	return delivery.Config.GlobusDestinations[institution]
+}
+
+// SourcePath returns the path of localTarFile in our Globus collection,
+// which shares Config.RestoreDirectory as Config.GlobusSourcePath.
+func (delivery *GlobusDelivery) SourcePath(localTarFile string) (string, error) {
+	relPath, err := filepath.Rel(delivery.Config.RestoreDirectory, localTarFile)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return "", fmt.Errorf("Tar file %s is not in RestoreDirectory %s, so Globus can't reach it",
+			localTarFile, delivery.Config.RestoreDirectory)
+	}
+	return path.Join(delivery.Config.GlobusSourcePath, filepath.ToSlash(relPath)), nil
+}
+
+// DestinationPath returns the path in destination at which the
+// restored bag will arrive.
+func (delivery *GlobusDelivery) DestinationPath(destination *models.GlobusDestination, restoreState *models.RestoreState) string {
+	return path.Join("/", destination.Path, restoreState.IntellectualObject.BagName+".tar")
+}
+
+// DestinationUrl returns a URL describing where the restored bag
+// will arrive, for RestoreState.RestoredToUrl.
+func (delivery *GlobusDelivery) DestinationUrl(destination *models.GlobusDestination, restoreState *models.RestoreState) string {
+	return fmt.Sprintf("globus://%s%s", destination.EndpointId,
+		delivery.DestinationPath(destination, restoreState))
+}
+
+// Submit starts a Globus transfer of the restored bag to destination,
+// and records the transfer task id in restoreState.GlobusTaskId. It
+// does nothing if restoreState already has a task id from a prior
+// attempt.
+func (delivery *GlobusDelivery) Submit(restoreState *models.RestoreState, destination *models.GlobusDestination) error {
+	if restoreState.GlobusTaskId != "" {
+		return nil
+	}
+	sourcePath, err := delivery.SourcePath(restoreState.LocalTarFile)
+	if err != nil {
+		return err
+	}
+	taskId, err := delivery.Client.SubmitTransfer(
+		delivery.Config.GlobusSourceEndpoint,
+		sourcePath,
+		destination.EndpointId,
+		delivery.DestinationPath(destination, restoreState),
+		fmt.Sprintf("APTrust restoration of %s", restoreState.IntellectualObject.Identifier),
+		time.Now().Add(delivery.Deadline))
+	if err != nil {
+		return err
+	}
+	restoreState.GlobusTaskId = taskId
+	return nil
+}
+
+// Wait checks on the transfer task in restoreState.GlobusTaskId every
+// PollInterval, calling touch each time, until the task succeeds or
+// fails. When it succeeds, Wait sets RestoredToUrl and
+// CopiedToRestorationAt. When it fails, Wait clears GlobusTaskId,
+// so the next attempt submits a new transfer, and returns an error.
+// Wait also returns an error if the task is inactive, which means it
+// needs someone to fix the collection or its credentials.
+func (delivery *GlobusDelivery) Wait(restoreState *models.RestoreState, destination *models.GlobusDestination, touch func()) error {
+	for {
+		task, err := delivery.Client.TaskStatus(restoreState.GlobusTaskId)
+		if err != nil {
+			return err
+		}
+		switch task.Status {
+		case network.GlobusTaskSucceeded:
+			restoreState.RestoredToUrl = delivery.DestinationUrl(destination, restoreState)
+			restoreState.CopiedToRestorationAt = time.Now().UTC()
+			return nil
+		case network.GlobusTaskFailed:
+			taskId := restoreState.GlobusTaskId
+			restoreState.GlobusTaskId = ""
+			return fmt.Errorf("Globus transfer task %s failed: %s", taskId, task.NiceStatus)
+		case network.GlobusTaskInactive:
+			return fmt.Errorf("Globus transfer task %s is inactive: %s", restoreState.GlobusTaskId,
+				task.NiceStatus)
+		}
+		touch()
+		time.Sleep(delivery.PollInterval)
+	}
+}
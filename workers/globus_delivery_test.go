@@ -0,0 +1,117 @@
+package workers_test
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"github.com/APTrust/exchange/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestGlobusDelivery(serverUrl string) *workers.GlobusDelivery {
+	config := &models.Config{
+		RestoreDirectory:     "/mnt/restore",
+		GlobusSourceEndpoint: "source-ep",
+		GlobusSourcePath:     "/aptrust/restore",
+		GlobusDestinations: map[string]*models.GlobusDestination{
+			"test.edu": {EndpointId: "dest-ep", Path: "incoming"},
+		},
+	}
+	delivery := workers.NewGlobusDelivery(config)
+	delivery.Client = network.NewGlobusTransferClient("id", "secret")
+	delivery.Client.AuthURL = serverUrl
+	delivery.Client.TransferURL = serverUrl
+	delivery.PollInterval = time.Millisecond
+	return delivery
+}
+
+func newTestGlobusRestoreState() *models.RestoreState {
+	restoreState := models.NewRestoreState(nil)
+	restoreState.IntellectualObject = &models.IntellectualObject{
+		Identifier: "test.edu/bag",
+		BagName:    "bag",
+	}
+	restoreState.LocalTarFile = "/mnt/restore/test.edu/bag.tar"
+	return restoreState
+}
+
+func TestGlobusDeliveryPaths(t *testing.T) {
+	delivery := newTestGlobusDelivery("http://localhost")
+	assert.Nil(t, delivery.DestinationFor("example.edu"))
+	destination := delivery.DestinationFor("test.edu")
+	require.NotNil(t, destination)
+
+	restoreState := newTestGlobusRestoreState()
+	sourcePath, err := delivery.SourcePath(restoreState.LocalTarFile)
+	require.Nil(t, err)
+	assert.Equal(t, "/aptrust/restore/test.edu/bag.tar", sourcePath)
+	_, err = delivery.SourcePath("/tmp/bag.tar")
+	assert.NotNil(t, err)
+
+	assert.Equal(t, "/incoming/bag.tar", delivery.DestinationPath(destination, restoreState))
+	assert.Equal(t, "globus://dest-ep/incoming/bag.tar", delivery.DestinationUrl(destination, restoreState))
+}
+
+func TestGlobusDeliverySubmitAndWait(t *testing.T) {
+	statuses := []string{network.GlobusTaskActive, network.GlobusTaskActive, network.GlobusTaskSucceeded}
+	submissions := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/oauth2/token":
+			fmt.Fprint(w, `{"access_token": "token", "expires_in": 3600}`)
+		case "/submission_id":
+			fmt.Fprint(w, `{"value": "sub-1"}`)
+		case "/transfer":
+			submissions += 1
+			fmt.Fprint(w, `{"code": "Accepted", "task_id": "task-1"}`)
+		case "/task/task-1":
+			status := statuses[0]
+			statuses = statuses[1:]
+			fmt.Fprintf(w, `{"task_id": "task-1", "status": "%s"}`, status)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	delivery := newTestGlobusDelivery(server.URL)
+	destination := delivery.DestinationFor("test.edu")
+	restoreState := newTestGlobusRestoreState()
+	require.Nil(t, delivery.Submit(restoreState, destination))
+	assert.Equal(t, "task-1", restoreState.GlobusTaskId)
+
+	// Submitting again should not start another transfer.
+	require.Nil(t, delivery.Submit(restoreState, destination))
+	assert.Equal(t, 1, submissions)
+
+	touches := 0
+	require.Nil(t, delivery.Wait(restoreState, destination, func() { touches++ }))
+	assert.Equal(t, 2, touches)
+	assert.Equal(t, "globus://dest-ep/incoming/bag.tar", restoreState.RestoredToUrl)
+	assert.False(t, restoreState.CopiedToRestorationAt.IsZero())
+}
+
+func TestGlobusDeliveryWaitFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/oauth2/token" {
+			fmt.Fprint(w, `{"access_token": "token", "expires_in": 3600}`)
+			return
+		}
+		fmt.Fprint(w, `{"task_id": "task-1", "status": "FAILED", "nice_status": "PERMISSION_DENIED"}`)
+	}))
+	defer server.Close()
+
+	delivery := newTestGlobusDelivery(server.URL)
+	restoreState := newTestGlobusRestoreState()
+	restoreState.GlobusTaskId = "task-1"
+	err := delivery.Wait(restoreState, delivery.DestinationFor("test.edu"), func() {})
+	require.NotNil(t, err)
+	assert.Equal(t, "Globus transfer task task-1 failed: PERMISSION_DENIED", err.Error())
+	assert.Empty(t, restoreState.GlobusTaskId)
+	assert.Empty(t, restoreState.RestoredToUrl)
+}
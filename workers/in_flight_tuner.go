@@ -0,0 +1,183 @@
+package workers
+
+import (
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"sync"
+	"time"
+)
+
+// DEFAULT_AUTOTUNE_INTERVAL is how often an InFlightTuner adjusts
+// max in flight if WorkerConfig.AutotuneInterval is not set.
+const DEFAULT_AUTOTUNE_INTERVAL = 5 * time.Minute
+
+// InFlightTuner adjusts a worker's NSQ max in flight as the worker
+// runs, so ops don't have to hand-tune MaxInFlight after every
+// hardware change. The worker's goroutines can process only
+// WorkerConfig.Workers items at a time. The rest wait their turn,
+// and NSQ requeues any that wait longer than the message timeout.
+// So the tuner allows as many items in flight as the workers can
+// get through in half the message timeout, at the recent average
+// processing time, within the configured bounds. It drops to
+// MinInFlight while the staging volume is short of space.
+//
+// InFlightTuner implements Pausable, so the admin API can pause and
+// resume the worker through it. The tuner doesn't change max in
+// flight while the worker is paused, and resuming restores the
+// tuned value, not the configured one.
+type InFlightTuner struct {
+	Context      *context.Context
+	WorkerConfig *models.WorkerConfig
+	Consumer     Pausable
+	// FreeSpace returns the number of bytes free in the staging
+	// directory. It's a variable so tests can fake it.
+	FreeSpace func(dir string) (uint64, error)
+	current   int
+	paused    bool
+	mutex     *sync.Mutex
+}
+
+// NewInFlightTuner returns a new InFlightTuner that starts at
+// workerConfig.MaxInFlight.
+func NewInFlightTuner(_context *context.Context, workerConfig *models.WorkerConfig, consumer Pausable) *InFlightTuner {
+	return &InFlightTuner{
+		Context:      _context,
+		WorkerConfig: workerConfig,
+		Consumer:     consumer,
+		FreeSpace:    stagingFreeSpace,
+		current:      workerConfig.MaxInFlight,
+		mutex:        &sync.Mutex{},
+	}
+}
+
+// StartInFlightTuner starts adjusting the consumer's max in flight
+// every WorkerConfig.AutotuneInterval, if WorkerConfig.AutotuneMaxInFlight
+// is true. It returns the Pausable the admin API should use, which is
+// the tuner if autotuning is on, or consumer if it's off.
+func StartInFlightTuner(_context *context.Context, workerConfig *models.WorkerConfig, consumer Pausable) Pausable {
+	if !workerConfig.AutotuneMaxInFlight {
+		return consumer
+	}
+	interval := DEFAULT_AUTOTUNE_INTERVAL
+	if workerConfig.AutotuneInterval != "" {
+		var err error
+		interval, err = time.ParseDuration(workerConfig.AutotuneInterval)
+		if err != nil {
+			_context.MessageLog.Warning("Not autotuning max in flight: bad AutotuneInterval '%s': %v",
+				workerConfig.AutotuneInterval, err)
+			return consumer
+		}
+	}
+	tuner := NewInFlightTuner(_context, workerConfig, consumer)
+	_context.MessageLog.Info("Autotuning max in flight between %d and %d every %s",
+		tuner.min(), tuner.max(), interval.String())
+	go func() {
+		for range time.Tick(interval) {
+			tuner.Tune()
+		}
+	}()
+	return tuner
+}
+
+// Current returns the tuned max in flight.
+func (tuner *InFlightTuner) Current() int {
+	tuner.mutex.Lock()
+	defer tuner.mutex.Unlock()
+	return tuner.current
+}
+
+// ChangeMaxInFlight pauses the worker if maxInFlight is zero, and
+// otherwise resumes it at the tuned max in flight. The admin API
+// calls this.
+func (tuner *InFlightTuner) ChangeMaxInFlight(maxInFlight int) {
+	tuner.mutex.Lock()
+	defer tuner.mutex.Unlock()
+	tuner.paused = (maxInFlight == 0)
+	if tuner.paused {
+		tuner.Consumer.ChangeMaxInFlight(0)
+	} else {
+		tuner.Consumer.ChangeMaxInFlight(tuner.current)
+	}
+}
+
+// Tune computes a new max in flight from the worker's recent metrics
+// and the free staging space, and applies it to the consumer unless
+// the worker is paused. It returns the new value.
+func (tuner *InFlightTuner) Tune() int {
+	target := tuner.target()
+	tuner.mutex.Lock()
+	defer tuner.mutex.Unlock()
+	// Move halfway to the target, so one slow or fast item
+	// doesn't swing the value from one bound to the other.
+	next := tuner.current + (target-tuner.current)/2
+	if next == tuner.current && target != tuner.current {
+		next = target
+	}
+	if next != tuner.current {
+		tuner.Context.MessageLog.Info("Changing max in flight for %s/%s from %d to %d",
+			tuner.WorkerConfig.NsqTopic, tuner.WorkerConfig.NsqChannel, tuner.current, next)
+		tuner.current = next
+		if !tuner.paused {
+			tuner.Consumer.ChangeMaxInFlight(next)
+		}
+	}
+	return tuner.current
+}
+
+// target returns the max in flight we'd like to reach.
+func (tuner *InFlightTuner) target() int {
+	workerConfig := tuner.WorkerConfig
+	if workerConfig.StagingDirectory != "" && workerConfig.MinFreeStagingSpace > 0 {
+		free, err := tuner.FreeSpace(workerConfig.StagingDirectory)
+		if err != nil {
+			tuner.Context.MessageLog.Warning("Cannot get free space in %s: %v",
+				workerConfig.StagingDirectory, err)
+		} else if free < workerConfig.MinFreeStagingSpace {
+			tuner.Context.MessageLog.Warning("Only %d bytes free in %s. Reducing max in flight.",
+				free, workerConfig.StagingDirectory)
+			return tuner.min()
+		}
+	}
+	processingTime := tuner.Context.WorkerMetrics.RecentProcessingTime()
+	timeout, err := time.ParseDuration(workerConfig.MessageTimeout)
+	if processingTime == 0 || err != nil || timeout == 0 {
+		// Nothing to go on yet.
+		return tuner.Current()
+	}
+	workers := workerConfig.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	target := int(int64(workers) * int64(timeout/2) / int64(processingTime))
+	if target < tuner.min() {
+		return tuner.min()
+	}
+	if target > tuner.max() {
+		return tuner.max()
+	}
+	return target
+}
+
+func (tuner *InFlightTuner) min() int {
+	if tuner.WorkerConfig.MinInFlight < 1 {
+		return 1
+	}
+	return tuner.WorkerConfig.MinInFlight
+}
+
+func (tuner *InFlightTuner) max() int {
+	ceiling := tuner.WorkerConfig.MaxInFlightCeiling
+	if ceiling < 1 {
+		ceiling = tuner.WorkerConfig.MaxInFlight
+	}
+	if ceiling < tuner.min() {
+		return tuner.min()
+	}
+	return ceiling
+}
+
+// stagingFreeSpace returns the number of bytes free on the
+// volume that holds dir.
+func stagingFreeSpace(dir string) (uint64, error) {
+	return models.NewVolume(dir).AvailableSpace()
+}
@@ -0,0 +1,103 @@
+package workers_test
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/APTrust/exchange/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStartInFlightTuner(t *testing.T) {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+	consumer := &testConsumer{maxInFlight: 20}
+	workerConfig := &models.WorkerConfig{MaxInFlight: 20}
+	assert.Equal(t, consumer, workers.StartInFlightTuner(_context, workerConfig, consumer))
+
+	workerConfig.AutotuneMaxInFlight = true
+	workerConfig.AutotuneInterval = "no good"
+	assert.Equal(t, consumer, workers.StartInFlightTuner(_context, workerConfig, consumer))
+
+	workerConfig.AutotuneInterval = "1h"
+	_, isTuner := workers.StartInFlightTuner(_context, workerConfig, consumer).(*workers.InFlightTuner)
+	assert.True(t, isTuner)
+}
+
+func TestInFlightTuner_Tune(t *testing.T) {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+	_context.WorkerMetrics = models.NewWorkerMetrics()
+	workerConfig := &models.WorkerConfig{
+		MaxInFlight:        10,
+		MinInFlight:        2,
+		MaxInFlightCeiling: 40,
+		Workers:            4,
+		MessageTimeout:     "60m",
+	}
+	consumer := &testConsumer{maxInFlight: 10}
+	tuner := workers.NewInFlightTuner(_context, workerConfig, consumer)
+
+	// No metrics yet, so no change.
+	assert.Equal(t, 10, tuner.Tune())
+	assert.Equal(t, 10, consumer.maxInFlight)
+
+	// Items take a minute. Four workers can get through 120
+	// items in half an hour, so we head for the ceiling of 40.
+	_context.WorkerMetrics.MessageStarted()
+	_context.WorkerMetrics.MessageFinished(time.Minute)
+	assert.Equal(t, 25, tuner.Tune())
+	assert.Equal(t, 25, consumer.maxInFlight)
+	assert.Equal(t, 32, tuner.Tune())
+	for i := 0; i < 5; i++ {
+		tuner.Tune()
+	}
+	assert.Equal(t, 40, tuner.Current())
+
+	// Pausing sets max in flight to zero, and tuning doesn't
+	// change that. Resuming restores the tuned value.
+	tuner.ChangeMaxInFlight(0)
+	assert.Equal(t, 0, consumer.maxInFlight)
+	tuner.FreeSpace = func(dir string) (uint64, error) { return 100, nil }
+	workerConfig.StagingDirectory = "/mnt/staging"
+	workerConfig.MinFreeStagingSpace = 1000
+	assert.Equal(t, 21, tuner.Tune())
+	assert.Equal(t, 0, consumer.maxInFlight)
+	tuner.ChangeMaxInFlight(10)
+	assert.Equal(t, 21, consumer.maxInFlight)
+
+	// Low staging space takes us down to the minimum.
+	for i := 0; i < 6; i++ {
+		tuner.Tune()
+	}
+	assert.Equal(t, 2, tuner.Current())
+
+	// If we can't check staging space, we go by processing time.
+	tuner.FreeSpace = func(dir string) (uint64, error) { return 0, fmt.Errorf("oops") }
+	assert.Equal(t, 21, tuner.Tune())
+}
+
+func TestInFlightTuner_SlowItems(t *testing.T) {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+	_context.WorkerMetrics = models.NewWorkerMetrics()
+	workerConfig := &models.WorkerConfig{
+		MaxInFlight:    20,
+		Workers:        2,
+		MessageTimeout: "180m",
+	}
+	consumer := &testConsumer{maxInFlight: 20}
+	tuner := workers.NewInFlightTuner(_context, workerConfig, consumer)
+
+	// Items take 45 minutes. Two workers can get through
+	// four of them in half of the three-hour timeout.
+	_context.WorkerMetrics.MessageStarted()
+	_context.WorkerMetrics.MessageFinished(45 * time.Minute)
+	for i := 0; i < 6; i++ {
+		tuner.Tune()
+	}
+	assert.Equal(t, 4, tuner.Current())
+}
@@ -0,0 +1,214 @@
+package workers
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/util"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LookupdConnector is the part of nsq.Consumer that LookupdMonitor
+// uses to add and remove lookup daemons.
+type LookupdConnector interface {
+	ConnectToNSQLookupd(addr string) error
+	DisconnectFromNSQLookupd(addr string) error
+}
+
+// LookupdMonitor keeps an NSQ consumer connected to the healthy
+// nsqlookupd instances in a list. The consumer queries its lookup
+// daemons in turn, so a dead lookup daemon that stays on the list
+// costs the consumer a failed query every other poll, and a consumer
+// connected to only one dead lookup daemon finds no new producers at
+// all. The monitor pings each lookup daemon, drops the ones that fail
+// and adds them back when they recover.
+//
+// The monitor never drops the last lookup daemon the consumer is
+// connected to. If every lookup daemon is down, the consumer keeps
+// polling the last one, and the monitor adds the others back as soon
+// as they recover.
+type LookupdMonitor struct {
+	// Addresses are the lookup daemons the consumer should use,
+	// as host:port or as full HTTP(S) URLs.
+	Addresses []string
+
+	// Client is the HTTP client that pings the lookup daemons.
+	Client *http.Client
+
+	// Refresh, if it's set, returns the current addresses of the
+	// lookup daemons. Check calls it to update Addresses, so the
+	// monitor follows lookup daemons that move. See
+	// context.Context.RefreshServices.
+	Refresh func() []string
+
+	context   *context.Context
+	connector LookupdConnector
+	connected map[string]bool
+	mutex     sync.Mutex
+}
+
+// NewLookupdMonitor returns a LookupdMonitor that connects
+// connector to the healthy lookup daemons in addresses.
+func NewLookupdMonitor(_context *context.Context, connector LookupdConnector, addresses []string) *LookupdMonitor {
+	return &LookupdMonitor{
+		Addresses: addresses,
+		Client:    &http.Client{Timeout: 5 * time.Second},
+		context:   _context,
+		connector: connector,
+		connected: make(map[string]bool),
+	}
+}
+
+// Ping returns an error if the lookup daemon at addr does
+// not respond OK to a request for its /ping endpoint.
+func (monitor *LookupdMonitor) Ping(addr string) error {
+	url := addr
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = "http://" + url
+	}
+	resp, err := monitor.Client.Get(strings.TrimSuffix(url, "/") + "/ping")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Ping returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Check pings each lookup daemon, connects the consumer to the ones
+// that respond, and disconnects it from the ones that don't. If the
+// consumer isn't connected to anything yet, and no lookup daemon
+// responds, Check connects to all of them, so the consumer can start
+// consuming as soon as any one of them comes up. It returns an error
+// only if the consumer ends up connected to no lookup daemon at all.
+func (monitor *LookupdMonitor) Check() error {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	if monitor.Refresh != nil {
+		if addresses := monitor.Refresh(); len(addresses) > 0 {
+			monitor.Addresses = addresses
+		}
+	}
+	healthy := make([]string, 0)
+	unhealthy := make([]string, 0)
+	for _, addr := range monitor.Addresses {
+		if err := monitor.Ping(addr); err != nil {
+			monitor.context.MessageLog.Warning("NSQLookupd at %s failed health check: %v", addr, err)
+			unhealthy = append(unhealthy, addr)
+		} else {
+			healthy = append(healthy, addr)
+		}
+	}
+	if len(healthy) == 0 && len(monitor.connected) == 0 {
+		healthy = unhealthy
+		unhealthy = nil
+	}
+	for _, addr := range healthy {
+		monitor.connect(addr)
+	}
+	for _, addr := range unhealthy {
+		monitor.disconnect(addr)
+	}
+	// Drop lookup daemons that are no longer in the list.
+	for addr := range monitor.connected {
+		if !util.StringListContains(monitor.Addresses, addr) {
+			monitor.disconnect(addr)
+		}
+	}
+	if len(monitor.connected) == 0 {
+		return fmt.Errorf("Could not connect to any NSQLookupd at %s",
+			strings.Join(monitor.Addresses, ", "))
+	}
+	return nil
+}
+
+// Connected returns the addresses of the lookup daemons
+// the consumer is currently connected to.
+func (monitor *LookupdMonitor) Connected() []string {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	addresses := make([]string, 0)
+	for _, addr := range monitor.Addresses {
+		if monitor.connected[addr] {
+			addresses = append(addresses, addr)
+		}
+	}
+	return addresses
+}
+
+func (monitor *LookupdMonitor) connect(addr string) {
+	if monitor.connected[addr] {
+		return
+	}
+	err := monitor.connector.ConnectToNSQLookupd(addr)
+	if err != nil {
+		monitor.context.MessageLog.Error("Cannot connect to NSQLookupd at %s: %v", addr, err)
+		return
+	}
+	monitor.connected[addr] = true
+	monitor.context.MessageLog.Info("Connected to NSQLookupd at %s", addr)
+}
+
+func (monitor *LookupdMonitor) disconnect(addr string) {
+	if !monitor.connected[addr] {
+		return
+	}
+	if len(monitor.connected) == 1 {
+		monitor.context.MessageLog.Warning("Staying connected to NSQLookupd at %s, "+
+			"because no other NSQLookupd is available", addr)
+		return
+	}
+	err := monitor.connector.DisconnectFromNSQLookupd(addr)
+	if err != nil {
+		monitor.context.MessageLog.Error("Cannot disconnect from NSQLookupd at %s: %v", addr, err)
+		return
+	}
+	delete(monitor.connected, addr)
+	monitor.context.MessageLog.Info("Disconnected from NSQLookupd at %s", addr)
+}
+
+// ConnectToLookupds connects consumer to the healthy lookup daemons in
+// Config.NsqLookupd and Config.NsqLookupds. If there is more than one
+// lookup daemon, it starts a goroutine that checks their health every
+// Config.NsqLookupdCheckInterval, so the consumer fails over to the
+// healthy ones. If the config uses service discovery, the goroutine
+// also follows the lookup daemons as they move. Call this after
+// adding the consumer's handlers. It returns an error if there are no
+// lookup daemons in the config, if the check interval is invalid, or
+// if the consumer cannot connect to any lookup daemon.
+func ConnectToLookupds(_context *context.Context, consumer LookupdConnector) (*LookupdMonitor, error) {
+	config := _context.Config
+	addresses := _context.NsqLookupdAddresses()
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("Config has no NsqLookupd")
+	}
+	interval := 30 * time.Second
+	if config.NsqLookupdCheckInterval != "" {
+		var err error
+		interval, err = time.ParseDuration(config.NsqLookupdCheckInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+	monitor := NewLookupdMonitor(_context, consumer, addresses)
+	if _context.ServiceResolver != nil {
+		monitor.Refresh = _context.NsqLookupdAddresses
+	}
+	if err := monitor.Check(); err != nil {
+		return nil, err
+	}
+	if len(addresses) > 1 || monitor.Refresh != nil {
+		_context.MessageLog.Info("Checking health of NSQLookupds %s every %s",
+			strings.Join(addresses, ", "), interval.String())
+		go func() {
+			for range time.Tick(interval) {
+				monitor.Check()
+			}
+		}()
+	}
+	return monitor, nil
+}
@@ -0,0 +1,191 @@
+package workers_test
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/APTrust/exchange/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testConnector records lookupd connections the way nsq.Consumer
+// does, refusing to disconnect from its only lookupd.
+type testConnector struct {
+	connected []string
+}
+
+func (c *testConnector) ConnectToNSQLookupd(addr string) error {
+	for _, x := range c.connected {
+		if x == addr {
+			return nil
+		}
+	}
+	c.connected = append(c.connected, addr)
+	return nil
+}
+
+func (c *testConnector) DisconnectFromNSQLookupd(addr string) error {
+	for i, x := range c.connected {
+		if x == addr {
+			if len(c.connected) == 1 {
+				return fmt.Errorf("cannot disconnect from only remaining nsqlookupd")
+			}
+			c.connected = append(c.connected[:i], c.connected[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("not connected")
+}
+
+// testLookupd is an nsqlookupd /ping endpoint that we can take down.
+type testLookupd struct {
+	server *httptest.Server
+	down   bool
+	mutex  sync.Mutex
+}
+
+func newTestLookupd() *testLookupd {
+	lookupd := &testLookupd{}
+	lookupd.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookupd.mutex.Lock()
+		defer lookupd.mutex.Unlock()
+		if lookupd.down || r.URL.Path != "/ping" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, "OK")
+	}))
+	return lookupd
+}
+
+func (lookupd *testLookupd) setDown(down bool) {
+	lookupd.mutex.Lock()
+	defer lookupd.mutex.Unlock()
+	lookupd.down = down
+}
+
+// addr returns the lookupd's address as host:port, like
+// the addresses in Config.NsqLookupd.
+func (lookupd *testLookupd) addr() string {
+	return strings.TrimPrefix(lookupd.server.URL, "http://")
+}
+
+func TestLookupdMonitor_Failover(t *testing.T) {
+	_context, err := testutil.GetContext("test.json")
+	require.Nil(t, err)
+	lookupd1 := newTestLookupd()
+	defer lookupd1.server.Close()
+	lookupd2 := newTestLookupd()
+	defer lookupd2.server.Close()
+	addresses := []string{lookupd1.addr(), lookupd2.server.URL}
+
+	connector := &testConnector{}
+	monitor := workers.NewLookupdMonitor(_context, connector, addresses)
+	require.Nil(t, monitor.Check())
+	assert.Equal(t, addresses, monitor.Connected())
+	assert.Equal(t, addresses, connector.connected)
+
+	// Losing one lookupd drops it from the consumer.
+	lookupd1.setDown(true)
+	require.Nil(t, monitor.Check())
+	assert.Equal(t, addresses[1:], monitor.Connected())
+	assert.Equal(t, addresses[1:], connector.connected)
+
+	// Losing them all keeps the last one.
+	lookupd2.setDown(true)
+	require.Nil(t, monitor.Check())
+	assert.Equal(t, addresses[1:], monitor.Connected())
+	assert.Equal(t, addresses[1:], connector.connected)
+
+	// When one recovers, the consumer switches to it.
+	lookupd1.setDown(false)
+	require.Nil(t, monitor.Check())
+	assert.Equal(t, addresses[:1], monitor.Connected())
+	assert.Equal(t, addresses[:1], connector.connected)
+
+	// When both are up, the consumer uses both.
+	lookupd2.setDown(false)
+	require.Nil(t, monitor.Check())
+	assert.Equal(t, addresses, monitor.Connected())
+	assert.ElementsMatch(t, addresses, connector.connected)
+}
+
+func TestLookupdMonitor_AllDownAtStart(t *testing.T) {
+	_context, err := testutil.GetContext("test.json")
+	require.Nil(t, err)
+	lookupd1 := newTestLookupd()
+	defer lookupd1.server.Close()
+	lookupd2 := newTestLookupd()
+	defer lookupd2.server.Close()
+	lookupd1.setDown(true)
+	lookupd2.setDown(true)
+	addresses := []string{lookupd1.addr(), lookupd2.addr()}
+
+	// With nothing healthy, connect to everything, so the
+	// consumer picks up whichever lookupd comes up first.
+	connector := &testConnector{}
+	monitor := workers.NewLookupdMonitor(_context, connector, addresses)
+	require.Nil(t, monitor.Check())
+	assert.Equal(t, addresses, monitor.Connected())
+
+	// Once one is up, drop the other.
+	lookupd2.setDown(false)
+	require.Nil(t, monitor.Check())
+	assert.Equal(t, addresses[1:], monitor.Connected())
+	assert.Equal(t, addresses[1:], connector.connected)
+}
+
+func TestLookupdMonitor_Refresh(t *testing.T) {
+	_context, err := testutil.GetContext("test.json")
+	require.Nil(t, err)
+	lookupd1 := newTestLookupd()
+	defer lookupd1.server.Close()
+	lookupd2 := newTestLookupd()
+	defer lookupd2.server.Close()
+
+	// The lookupd moves from one address to another.
+	current := []string{lookupd1.addr()}
+	connector := &testConnector{}
+	monitor := workers.NewLookupdMonitor(_context, connector, current)
+	monitor.Refresh = func() []string { return current }
+	require.Nil(t, monitor.Check())
+	assert.Equal(t, []string{lookupd1.addr()}, connector.connected)
+
+	current = []string{lookupd2.addr()}
+	require.Nil(t, monitor.Check())
+	assert.Equal(t, current, monitor.Connected())
+	assert.Equal(t, current, connector.connected)
+}
+
+func TestConnectToLookupds(t *testing.T) {
+	_context, err := testutil.GetContext("test.json")
+	require.Nil(t, err)
+	lookupd1 := newTestLookupd()
+	defer lookupd1.server.Close()
+	lookupd2 := newTestLookupd()
+	defer lookupd2.server.Close()
+	lookupd2.setDown(true)
+
+	_context.Config.NsqLookupd = lookupd1.addr()
+	_context.Config.NsqLookupds = []string{lookupd2.addr()}
+	_context.Config.NsqLookupdCheckInterval = "1h"
+	connector := &testConnector{}
+	monitor, err := workers.ConnectToLookupds(_context, connector)
+	require.Nil(t, err)
+	require.NotNil(t, monitor)
+	assert.Equal(t, []string{lookupd1.addr()}, connector.connected)
+
+	_context.Config.NsqLookupdCheckInterval = "every so often"
+	_, err = workers.ConnectToLookupds(_context, &testConnector{})
+	assert.NotNil(t, err)
+
+	_context.Config.NsqLookupd = ""
+	_context.Config.NsqLookupds = nil
+	_, err = workers.ConnectToLookupds(_context, &testConnector{})
+	assert.NotNil(t, err)
+}
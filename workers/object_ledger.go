@@ -0,0 +1,164 @@
+package workers
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util/logger"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ObjectLedger describes where an object is in processing: its
+// active WorkItems, who is working on them, when their state was
+// last saved, how much is queued ahead of them, and recent errors
+// that mention the object.
+type ObjectLedger struct {
+	ObjectIdentifier string
+	CheckedAt        time.Time
+	// ActiveWorkItems are the object's pending and started WorkItems.
+	ActiveWorkItems []*LedgerWorkItem
+	// NSQError describes why we could not get queue stats from NSQ.
+	NSQError string `json:",omitempty"`
+	// RecentErrors are the errors in this worker's log that
+	// mention the object.
+	RecentErrors []logger.LogEntry
+}
+
+// LedgerWorkItem is one active WorkItem in an ObjectLedger.
+// WorkItem.Node and WorkItem.Pid tell which worker has the item.
+type LedgerWorkItem struct {
+	WorkItem *models.WorkItem
+	// InFlightHere is true if the worker serving the ledger is
+	// processing this item right now.
+	InFlightHere bool
+	// StateSavedAt is when the item's WorkItemState was last saved,
+	// or nil if it has none.
+	StateSavedAt *time.Time
+	StateError   string `json:",omitempty"`
+	// NsqTopic and NsqChannel are the queue the item belongs in
+	// at its current action and stage.
+	NsqTopic   string
+	NsqChannel string
+	// QueueDepth is the number of messages waiting in the item's
+	// channel, or -1 if unknown. NSQ doesn't tell us where a message
+	// sits in its queue, so this is an upper bound on the number of
+	// items ahead of this one.
+	QueueDepth    int64
+	QueueInFlight int
+}
+
+// ObjectLedgerBuilder builds ObjectLedgers from Pharos, NSQ and
+// this worker's own metrics and logs.
+type ObjectLedgerBuilder struct {
+	Context *context.Context
+}
+
+// NewObjectLedgerBuilder returns a new ObjectLedgerBuilder.
+func NewObjectLedgerBuilder(_context *context.Context) *ObjectLedgerBuilder {
+	return &ObjectLedgerBuilder{Context: _context}
+}
+
+// Build returns the ObjectLedger for the object with the specified
+// identifier. It returns an error only if Pharos can't tell us about
+// the object's WorkItems. Problems getting state or queue info are
+// recorded in the ledger.
+func (builder *ObjectLedgerBuilder) Build(objIdentifier string) (*ObjectLedger, error) {
+	params := url.Values{}
+	params.Set("object_identifier", objIdentifier)
+	params.Set("page", "1")
+	params.Set("per_page", "100")
+	resp := builder.Context.PharosClient.WorkItemList(params)
+	if resp.Error != nil {
+		return nil, fmt.Errorf("Cannot get WorkItems for %s from Pharos: %v",
+			objIdentifier, resp.Error)
+	}
+	ledger := &ObjectLedger{
+		ObjectIdentifier: objIdentifier,
+		CheckedAt:        time.Now().UTC(),
+		ActiveWorkItems:  make([]*LedgerWorkItem, 0),
+		RecentErrors:     make([]logger.LogEntry, 0),
+	}
+	inFlight := builder.inFlightWorkItemIds()
+	for _, workItem := range resp.WorkItems() {
+		if workItem.Status != constants.StatusPending && workItem.Status != constants.StatusStarted {
+			continue
+		}
+		ledger.ActiveWorkItems = append(ledger.ActiveWorkItems, &LedgerWorkItem{
+			WorkItem:     workItem,
+			InFlightHere: inFlight[workItem.Id],
+			QueueDepth:   -1,
+		})
+	}
+	for _, item := range ledger.ActiveWorkItems {
+		builder.setStateSavedAt(item)
+	}
+	builder.setQueueStats(ledger)
+	for _, entry := range logger.RecentErrors.Entries() {
+		if strings.Contains(entry.Message, objIdentifier) {
+			ledger.RecentErrors = append(ledger.RecentErrors, entry)
+		}
+	}
+	return ledger, nil
+}
+
+// inFlightWorkItemIds returns the ids of the WorkItems this worker
+// is processing now. Messages that don't name a WorkItem, such as
+// fixity checks by file identifier, are skipped.
+func (builder *ObjectLedgerBuilder) inFlightWorkItemIds() map[int]bool {
+	ids := make(map[int]bool)
+	for _, item := range builder.Context.WorkerMetrics.InFlightItems() {
+		queueMessage, err := models.ParseQueueMessage([]byte(item.Body))
+		if err == nil && queueMessage.WorkItemId != 0 {
+			ids[queueMessage.WorkItemId] = true
+		}
+	}
+	return ids
+}
+
+// setStateSavedAt sets item.StateSavedAt from the item's WorkItemState.
+func (builder *ObjectLedgerBuilder) setStateSavedAt(item *LedgerWorkItem) {
+	if item.WorkItem.WorkItemStateId == nil {
+		return
+	}
+	resp := builder.Context.PharosClient.WorkItemStateGet(*item.WorkItem.WorkItemStateId)
+	if resp.Error != nil {
+		item.StateError = resp.Error.Error()
+		return
+	}
+	state := resp.WorkItemState()
+	if state != nil {
+		savedAt := state.UpdatedAt
+		item.StateSavedAt = &savedAt
+	}
+}
+
+// setQueueStats sets the topic, channel and queue stats of each
+// active WorkItem. It asks NSQ for stats only once.
+func (builder *ObjectLedgerBuilder) setQueueStats(ledger *ObjectLedger) {
+	if len(ledger.ActiveWorkItems) == 0 {
+		return
+	}
+	stats, err := builder.Context.NSQClient.GetStats()
+	if err != nil {
+		ledger.NSQError = err.Error()
+	}
+	for _, item := range ledger.ActiveWorkItems {
+		workerConfig := WorkerConfigFor(builder.Context.Config, item.WorkItem)
+		if workerConfig == nil {
+			continue
+		}
+		item.NsqTopic = workerConfig.NsqTopic
+		item.NsqChannel = workerConfig.NsqChannel
+		if stats == nil {
+			continue
+		}
+		channelStats := stats.ChannelStats(item.NsqTopic, item.NsqChannel)
+		if channelStats != nil {
+			item.QueueDepth = channelStats.Depth
+			item.QueueInFlight = channelStats.InFlightCount
+		}
+	}
+}
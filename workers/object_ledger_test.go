@@ -0,0 +1,149 @@
+package workers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/APTrust/exchange/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ledgerStateSavedAt = time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+
+// ledgerPharosServer serves three WorkItems for test.edu/ledger_bag,
+// one of which is finished, and a WorkItemState for the others.
+func ledgerPharosServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data []byte
+		if strings.Contains(r.URL.Path, "/item_state/") {
+			state := testutil.MakeWorkItemState()
+			state.UpdatedAt = ledgerStateSavedAt
+			data, _ = json.Marshal(state)
+		} else {
+			items := make([]*models.WorkItem, 3)
+			for i := range items {
+				stateId := 100 + i
+				items[i] = testutil.MakeWorkItem()
+				items[i].Id = i + 1
+				items[i].ObjectIdentifier = "test.edu/ledger_bag"
+				items[i].WorkItemStateId = &stateId
+			}
+			items[0].Action = constants.ActionIngest
+			items[0].Stage = constants.StageStore
+			items[0].Status = constants.StatusStarted
+			items[0].Node = "10.0.0.1"
+			items[1].Action = constants.ActionFixityCheck
+			items[1].Status = constants.StatusPending
+			items[1].WorkItemStateId = nil
+			items[2].Status = constants.StatusSuccess
+			list := map[string]interface{}{
+				"count":    len(items),
+				"next":     nil,
+				"previous": nil,
+				"results":  items,
+			}
+			data, _ = json.Marshal(list)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+}
+
+// ledgerNSQServer serves NSQ stats showing 7 items queued
+// for the store worker.
+func ledgerNSQServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"topics":[{"topic_name":"apt_store_topic","channels":[`+
+			`{"channel_name":"apt_store_channel","depth":7,"in_flight_count":2}]}]}`)
+	}))
+}
+
+func getLedgerBuilder(t *testing.T, pharosURL, nsqURL string) *workers.ObjectLedgerBuilder {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+	_context.PharosClient = getPharosClientForTest(pharosURL)
+	_context.NSQClient.URL = nsqURL
+	return workers.NewObjectLedgerBuilder(_context)
+}
+
+func TestObjectLedgerBuilder(t *testing.T) {
+	pharosServer := ledgerPharosServer()
+	defer pharosServer.Close()
+	nsqServer := ledgerNSQServer()
+	defer nsqServer.Close()
+	builder := getLedgerBuilder(t, pharosServer.URL, nsqServer.URL)
+	builder.Context.Config.StoreWorker.NsqTopic = "apt_store_topic"
+	builder.Context.Config.StoreWorker.NsqChannel = "apt_store_channel"
+	builder.Context.WorkerMetrics.ItemStarted(&models.InFlightItem{
+		MessageId: "0001",
+		Body:      `{"version":1,"work_item_id":1}`,
+	})
+
+	ledger, err := builder.Build("test.edu/ledger_bag")
+	require.Nil(t, err)
+	assert.Equal(t, "test.edu/ledger_bag", ledger.ObjectIdentifier)
+	assert.Empty(t, ledger.NSQError)
+	require.Equal(t, 2, len(ledger.ActiveWorkItems))
+
+	stored := ledger.ActiveWorkItems[0]
+	assert.Equal(t, 1, stored.WorkItem.Id)
+	assert.Equal(t, "10.0.0.1", stored.WorkItem.Node)
+	assert.True(t, stored.InFlightHere)
+	require.NotNil(t, stored.StateSavedAt)
+	assert.True(t, ledgerStateSavedAt.Equal(*stored.StateSavedAt))
+	assert.Equal(t, "apt_store_topic", stored.NsqTopic)
+	assert.Equal(t, int64(7), stored.QueueDepth)
+	assert.Equal(t, 2, stored.QueueInFlight)
+
+	// The fixity item has no saved state, and NSQ has no
+	// stats for its channel.
+	fixity := ledger.ActiveWorkItems[1]
+	assert.False(t, fixity.InFlightHere)
+	assert.Nil(t, fixity.StateSavedAt)
+	assert.Equal(t, builder.Context.Config.FixityWorker.NsqTopic, fixity.NsqTopic)
+	assert.Equal(t, int64(-1), fixity.QueueDepth)
+}
+
+func TestObjectLedgerBuilder_NSQDown(t *testing.T) {
+	pharosServer := ledgerPharosServer()
+	defer pharosServer.Close()
+	builder := getLedgerBuilder(t, pharosServer.URL, "http://127.0.0.1:1")
+	ledger, err := builder.Build("test.edu/ledger_bag")
+	require.Nil(t, err)
+	assert.NotEmpty(t, ledger.NSQError)
+	require.Equal(t, 2, len(ledger.ActiveWorkItems))
+	assert.Equal(t, int64(-1), ledger.ActiveWorkItems[0].QueueDepth)
+}
+
+func TestAdminServer_ObjectLedger(t *testing.T) {
+	pharosServer := ledgerPharosServer()
+	defer pharosServer.Close()
+	builder := getLedgerBuilder(t, pharosServer.URL, "http://127.0.0.1:1")
+	adminServer := workers.NewAdminServer(builder.Context, &models.WorkerConfig{},
+		&testConsumer{}, nil, "secret")
+	server := httptest.NewServer(adminServer.Handler())
+	defer server.Close()
+
+	resp := adminRequest(t, server, http.MethodGet, "/object_ledger/", "secret")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = adminRequest(t, server, http.MethodGet,
+		"/object_ledger/?object=test.edu%2Fledger_bag", "secret")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	ledger := &workers.ObjectLedger{}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(ledger))
+	assert.Equal(t, "test.edu/ledger_bag", ledger.ObjectIdentifier)
+	assert.Equal(t, 2, len(ledger.ActiveWorkItems))
+	assert.WithinDuration(t, time.Now(), ledger.CheckedAt, time.Minute)
+}
@@ -0,0 +1,239 @@
+package workers
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// PHAROS_HEALTH_WINDOW is the number of recent probes a
+// PharosBackpressure considers when judging Pharos' health.
+const PHAROS_HEALTH_WINDOW = 10
+
+// Defaults for the WorkerConfig.Pharos* settings.
+const (
+	DEFAULT_PHAROS_HEALTH_INTERVAL = 30 * time.Second
+	DEFAULT_PHAROS_SLOW_LATENCY    = 2 * time.Second
+	DEFAULT_PHAROS_MAX_ERROR_RATE  = 0.5
+)
+
+// Values for PharosBackpressure.Health.
+const (
+	// PharosHealthy means we take as many messages as we're asked to.
+	PharosHealthy = "Healthy"
+	// PharosSlow means we take no more than MinInFlight messages.
+	PharosSlow = "Slow"
+	// PharosDown means we take no messages at all.
+	PharosDown = "Down"
+)
+
+// PharosBackpressure slows or stops a worker's consumption from NSQ
+// while Pharos is slow or failing. Items that can't record their
+// progress in Pharos fail and need reprocessing, so when Pharos is
+// struggling, it's better to leave messages in the queue until it
+// recovers.
+//
+// PharosBackpressure implements Pausable, and sits between the NSQ
+// consumer and whatever sets its max in flight, such as an
+// InFlightTuner or the admin API. It passes along the max in flight
+// it's asked for, capped at MinInFlight while Pharos is slow, and at
+// zero while Pharos is down. When Pharos recovers, it restores the
+// max in flight it was last asked for.
+type PharosBackpressure struct {
+	Context      *context.Context
+	WorkerConfig *models.WorkerConfig
+	Consumer     Pausable
+	// Probe sends one request to Pharos and returns an error if it
+	// fails. It's a variable so tests can fake it.
+	Probe func() error
+	// SlowLatency is the average probe time above which Pharos is slow.
+	SlowLatency time.Duration
+	// MaxErrorRate is the fraction of failed probes at which
+	// Pharos is down.
+	MaxErrorRate float64
+	latencies    []time.Duration
+	failures     []bool
+	requested    int
+	health       string
+	mutex        *sync.Mutex
+}
+
+// NewPharosBackpressure returns a new PharosBackpressure that considers
+// Pharos healthy until its first probe, and uses the default thresholds.
+func NewPharosBackpressure(_context *context.Context, workerConfig *models.WorkerConfig, consumer Pausable) *PharosBackpressure {
+	backpressure := &PharosBackpressure{
+		Context:      _context,
+		WorkerConfig: workerConfig,
+		Consumer:     consumer,
+		SlowLatency:  DEFAULT_PHAROS_SLOW_LATENCY,
+		MaxErrorRate: DEFAULT_PHAROS_MAX_ERROR_RATE,
+		latencies:    make([]time.Duration, 0, PHAROS_HEALTH_WINDOW),
+		failures:     make([]bool, 0, PHAROS_HEALTH_WINDOW),
+		requested:    workerConfig.MaxInFlight,
+		health:       PharosHealthy,
+		mutex:        &sync.Mutex{},
+	}
+	backpressure.Probe = backpressure.probePharos
+	return backpressure
+}
+
+// StartPharosBackpressure starts probing Pharos every
+// WorkerConfig.PharosHealthInterval, if WorkerConfig.PharosBackpressure
+// is true. It returns the Pausable that should set the consumer's max
+// in flight from now on, which is the PharosBackpressure if it's on,
+// or consumer if it's off.
+func StartPharosBackpressure(_context *context.Context, workerConfig *models.WorkerConfig, consumer Pausable) Pausable {
+	if !workerConfig.PharosBackpressure {
+		return consumer
+	}
+	backpressure := NewPharosBackpressure(_context, workerConfig, consumer)
+	interval, err := backpressure.configure()
+	if err != nil {
+		_context.MessageLog.Warning("Not applying Pharos backpressure: %v", err)
+		return consumer
+	}
+	_context.MessageLog.Info("Probing Pharos every %s. Slowing when probes average "+
+		"more than %s, and stopping when %.0f%% of them fail.",
+		interval.String(), backpressure.SlowLatency.String(), backpressure.MaxErrorRate*100)
+	go func() {
+		for range time.Tick(interval) {
+			backpressure.Check()
+		}
+	}()
+	return backpressure
+}
+
+// configure sets the thresholds from WorkerConfig, and returns the
+// probe interval.
+func (backpressure *PharosBackpressure) configure() (time.Duration, error) {
+	workerConfig := backpressure.WorkerConfig
+	interval := DEFAULT_PHAROS_HEALTH_INTERVAL
+	var err error
+	if workerConfig.PharosHealthInterval != "" {
+		interval, err = time.ParseDuration(workerConfig.PharosHealthInterval)
+		if err != nil {
+			return 0, fmt.Errorf("bad PharosHealthInterval '%s': %v",
+				workerConfig.PharosHealthInterval, err)
+		}
+	}
+	if workerConfig.PharosSlowLatency != "" {
+		backpressure.SlowLatency, err = time.ParseDuration(workerConfig.PharosSlowLatency)
+		if err != nil {
+			return 0, fmt.Errorf("bad PharosSlowLatency '%s': %v",
+				workerConfig.PharosSlowLatency, err)
+		}
+	}
+	if workerConfig.PharosMaxErrorRate > 0 {
+		backpressure.MaxErrorRate = workerConfig.PharosMaxErrorRate
+	}
+	return interval, nil
+}
+
+// Health returns PharosHealthy, PharosSlow or PharosDown, as of
+// the last probe.
+func (backpressure *PharosBackpressure) Health() string {
+	backpressure.mutex.Lock()
+	defer backpressure.mutex.Unlock()
+	return backpressure.health
+}
+
+// ChangeMaxInFlight sets the max in flight we'd like the consumer to
+// have. The consumer gets that value, capped according to Pharos'
+// health. The admin API and the InFlightTuner call this.
+func (backpressure *PharosBackpressure) ChangeMaxInFlight(maxInFlight int) {
+	backpressure.mutex.Lock()
+	defer backpressure.mutex.Unlock()
+	backpressure.requested = maxInFlight
+	backpressure.Consumer.ChangeMaxInFlight(backpressure.allowed())
+}
+
+// Check probes Pharos, updates Health from the recent probes, and
+// changes the consumer's max in flight if Health changed. It returns
+// the new Health.
+func (backpressure *PharosBackpressure) Check() string {
+	start := time.Now()
+	err := backpressure.Probe()
+	latency := time.Since(start)
+	if err != nil {
+		backpressure.Context.MessageLog.Warning("Pharos health probe failed: %v", err)
+	}
+
+	backpressure.mutex.Lock()
+	defer backpressure.mutex.Unlock()
+	if len(backpressure.latencies) == PHAROS_HEALTH_WINDOW {
+		backpressure.latencies = backpressure.latencies[1:]
+		backpressure.failures = backpressure.failures[1:]
+	}
+	backpressure.latencies = append(backpressure.latencies, latency)
+	backpressure.failures = append(backpressure.failures, err != nil)
+
+	health := backpressure.assess()
+	if health != backpressure.health {
+		message := fmt.Sprintf("Pharos health changed from %s to %s for %s/%s",
+			backpressure.health, health, backpressure.WorkerConfig.NsqTopic,
+			backpressure.WorkerConfig.NsqChannel)
+		if health == PharosHealthy {
+			backpressure.Context.MessageLog.Info(message)
+		} else {
+			backpressure.Context.MessageLog.Warning(message)
+		}
+		backpressure.health = health
+		backpressure.Consumer.ChangeMaxInFlight(backpressure.allowed())
+	}
+	return health
+}
+
+// assess returns Pharos' health according to the recent probes.
+// Caller must hold the mutex.
+func (backpressure *PharosBackpressure) assess() string {
+	if len(backpressure.latencies) == 0 {
+		return PharosHealthy
+	}
+	failed := 0
+	var total time.Duration
+	for i, latency := range backpressure.latencies {
+		total += latency
+		if backpressure.failures[i] {
+			failed += 1
+		}
+	}
+	errorRate := float64(failed) / float64(len(backpressure.failures))
+	averageLatency := total / time.Duration(len(backpressure.latencies))
+	if errorRate >= backpressure.MaxErrorRate {
+		return PharosDown
+	}
+	if failed > 0 || averageLatency > backpressure.SlowLatency {
+		return PharosSlow
+	}
+	return PharosHealthy
+}
+
+// allowed returns the max in flight the consumer should have, given
+// what we were asked for and Pharos' health. Caller must hold the mutex.
+func (backpressure *PharosBackpressure) allowed() int {
+	switch backpressure.health {
+	case PharosDown:
+		return 0
+	case PharosSlow:
+		slow := backpressure.WorkerConfig.MinInFlight
+		if slow < 1 {
+			slow = 1
+		}
+		if backpressure.requested < slow {
+			return backpressure.requested
+		}
+		return slow
+	}
+	return backpressure.requested
+}
+
+// probePharos asks Pharos for a single institution, which is about
+// the cheapest request it answers.
+func (backpressure *PharosBackpressure) probePharos() error {
+	params := url.Values{}
+	params.Set("per_page", "1")
+	return backpressure.Context.PharosClient.InstitutionList(params).Error
+}
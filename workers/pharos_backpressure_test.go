@@ -0,0 +1,104 @@
+package workers_test
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/APTrust/exchange/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStartPharosBackpressure(t *testing.T) {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+	consumer := &testConsumer{maxInFlight: 20}
+	workerConfig := &models.WorkerConfig{MaxInFlight: 20}
+	assert.Equal(t, consumer, workers.StartPharosBackpressure(_context, workerConfig, consumer))
+
+	workerConfig.PharosBackpressure = true
+	workerConfig.PharosSlowLatency = "no good"
+	assert.Equal(t, consumer, workers.StartPharosBackpressure(_context, workerConfig, consumer))
+
+	workerConfig.PharosSlowLatency = "5s"
+	workerConfig.PharosHealthInterval = "1h"
+	workerConfig.PharosMaxErrorRate = 0.8
+	backpressure, isBackpressure := workers.StartPharosBackpressure(_context,
+		workerConfig, consumer).(*workers.PharosBackpressure)
+	require.True(t, isBackpressure)
+	assert.Equal(t, 5*time.Second, backpressure.SlowLatency)
+	assert.Equal(t, 0.8, backpressure.MaxErrorRate)
+}
+
+func TestPharosBackpressure_Check(t *testing.T) {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+	workerConfig := &models.WorkerConfig{MaxInFlight: 20, MinInFlight: 2}
+	consumer := &testConsumer{maxInFlight: 20}
+	backpressure := workers.NewPharosBackpressure(_context, workerConfig, consumer)
+	var probeErr error
+	backpressure.Probe = func() error { return probeErr }
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, workers.PharosHealthy, backpressure.Check())
+	}
+	assert.Equal(t, 20, consumer.maxInFlight)
+
+	// Any failure in the window slows us to MinInFlight.
+	probeErr = fmt.Errorf("502 Bad Gateway")
+	assert.Equal(t, workers.PharosSlow, backpressure.Check())
+	assert.Equal(t, 2, consumer.maxInFlight)
+	assert.Equal(t, workers.PharosSlow, backpressure.Check())
+
+	// Once half the probes fail, we stop.
+	assert.Equal(t, workers.PharosDown, backpressure.Check())
+	assert.Equal(t, 0, consumer.maxInFlight)
+
+	// The tuner or admin API can't override that, but we
+	// remember what they asked for.
+	backpressure.ChangeMaxInFlight(30)
+	assert.Equal(t, 0, consumer.maxInFlight)
+
+	// We stay slow until good probes push all of the
+	// failures out of the window.
+	probeErr = nil
+	for i := 0; i < workers.PHAROS_HEALTH_WINDOW-1; i++ {
+		assert.Equal(t, workers.PharosSlow, backpressure.Check())
+	}
+	assert.Equal(t, 2, consumer.maxInFlight)
+	assert.Equal(t, workers.PharosHealthy, backpressure.Check())
+	assert.Equal(t, 30, consumer.maxInFlight)
+
+	// Slow responses also slow us down.
+	backpressure.SlowLatency = time.Nanosecond
+	backpressure.Probe = func() error { time.Sleep(time.Millisecond); return nil }
+	assert.Equal(t, workers.PharosSlow, backpressure.Check())
+	assert.Equal(t, 2, consumer.maxInFlight)
+
+	// A pause from the admin API gets through.
+	backpressure.ChangeMaxInFlight(0)
+	assert.Equal(t, 0, consumer.maxInFlight)
+}
+
+func TestPharosBackpressure_Probe(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"count":1,"next":null,"previous":null,"results":[]}`)
+	}))
+	defer server.Close()
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+	_context.PharosClient = getPharosClientForTest(server.URL)
+	consumer := &testConsumer{maxInFlight: 10}
+	backpressure := workers.NewPharosBackpressure(_context,
+		&models.WorkerConfig{MaxInFlight: 10}, consumer)
+
+	assert.Nil(t, backpressure.Probe())
+	status = http.StatusServiceUnavailable
+	assert.NotNil(t, backpressure.Probe())
+}
@@ -0,0 +1,85 @@
+package workers
+
+import (
+	"fmt"
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"strings"
+	"time"
+)
+
+// RestoreReadinessChecker tells whether an object's files are in S3,
+// so we can tell depositors how long a restore will take before they
+// ask for one. It asks Pharos for the object's files, and asks S3
+// about the Glacier retrieval status of each Glacier file.
+type RestoreReadinessChecker struct {
+	Context *context.Context
+	// S3Url is a custom URL for the S3 client. As with
+	// APTGlacierRestoreInit.S3Url, this is for testing only.
+	S3Url string
+}
+
+// NewRestoreReadinessChecker returns a new RestoreReadinessChecker.
+func NewRestoreReadinessChecker(_context *context.Context) *RestoreReadinessChecker {
+	return &RestoreReadinessChecker{Context: _context}
+}
+
+// Check returns the restore readiness of the object with the specified
+// identifier. This sends a HEAD request to S3 for every Glacier file
+// in the object, so it can take a while for objects with many files.
+func (checker *RestoreReadinessChecker) Check(objIdentifier string) (*models.RestoreReadiness, error) {
+	resp := checker.Context.PharosClient.IntellectualObjectGet(objIdentifier, true, false)
+	if resp.Error != nil {
+		return nil, fmt.Errorf("Cannot get %s from Pharos: %v", objIdentifier, resp.Error)
+	}
+	obj := resp.IntellectualObject()
+	if obj == nil {
+		return nil, fmt.Errorf("Pharos returned nil for IntellectualObject %s", objIdentifier)
+	}
+	readiness := models.NewRestoreReadiness(obj.Identifier, time.Now())
+	for _, gf := range obj.GenericFiles {
+		if gf.State == "D" {
+			continue
+		}
+		if gf.StorageOption == constants.StorageStandard {
+			readiness.AddFile(gf.StorageOption, true, false)
+			continue
+		}
+		restoreInfo, err := checker.retrievalStatus(gf)
+		if err != nil {
+			return nil, err
+		}
+		readiness.AddFile(gf.StorageOption, restoreInfo.RequestIsComplete,
+			restoreInfo.RequestInProgress)
+	}
+	return readiness, nil
+}
+
+// retrievalStatus asks S3 whether gf has been retrieved from Glacier.
+func (checker *RestoreReadinessChecker) retrievalStatus(gf *models.GenericFile) (*network.RestoreRequestInfo, error) {
+	region, bucket, err := checker.Context.Config.StorageRegionAndBucketFor(gf.StorageOption)
+	if err != nil {
+		return nil, err
+	}
+	fileUUID, err := gf.PreservationStorageFileName()
+	if err != nil {
+		return nil, fmt.Errorf("File %s: %v", gf.Identifier, err)
+	}
+	client := network.NewS3Head(
+		checker.Context.Config.GetAWSAccessKeyId(),
+		checker.Context.Config.GetAWSSecretAccessKey(),
+		region,
+		bucket)
+	if checker.S3Url != "" {
+		client.SetSessionEndpoint(strings.Replace(checker.S3Url, constants.AWS_TEST_HACK_IP_PREFIX, "", 1))
+		client.BucketName = constants.AWS_TEST_HACK_BUCKET_NAME
+	}
+	client.Head(fileUUID)
+	if client.ErrorMessage != "" {
+		return nil, fmt.Errorf("S3 HEAD request for file %s (%s) returned error: %s",
+			fileUUID, gf.Identifier, client.ErrorMessage)
+	}
+	return client.GetRestoreRequestInfo()
+}
@@ -0,0 +1,104 @@
+package workers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/APTrust/exchange/constants"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"github.com/APTrust/exchange/util/testutil"
+	"github.com/APTrust/exchange/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readinessPharosServer returns a Pharos test server that serves
+// an object whose files all have the specified storage option.
+func readinessPharosServer(storageOption string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		obj := testutil.MakeIntellectualObject(3, 0, 0, 0)
+		obj.Identifier = "test.edu/glacier_bag"
+		obj.StorageOption = storageOption
+		for _, gf := range obj.GenericFiles {
+			gf.State = "A"
+			gf.StorageOption = storageOption
+		}
+		obj.GenericFiles[2].State = "D"
+		data, _ := json.Marshal(obj)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+}
+
+func getReadinessChecker(t *testing.T, pharosURL, s3URL string) *workers.RestoreReadinessChecker {
+	_context, err := testutil.GetContext("integration.json")
+	require.Nil(t, err)
+	_context.PharosClient = getPharosClientForTest(pharosURL)
+	checker := workers.NewRestoreReadinessChecker(_context)
+	checker.S3Url = s3URL
+	return checker
+}
+
+func TestRestoreReadinessChecker(t *testing.T) {
+	cases := []struct {
+		storageOption string
+		s3Handler     http.HandlerFunc
+		status        string
+	}{
+		{constants.StorageStandard, nil, models.RestoreReady},
+		{constants.StorageGlacierOH, network.S3HeadRestoreCompletedHandler, models.RestoreReady},
+		{constants.StorageGlacierOH, network.S3HeadRestoreInProgressHandler, models.RestorePartiallyRestored},
+		{constants.StorageGlacierDeepOR, network.S3HeadHandler, models.RestoreNeedsGlacierRetrieval},
+	}
+	for _, c := range cases {
+		pharosServer := readinessPharosServer(c.storageOption)
+		s3URL := ""
+		if c.s3Handler != nil {
+			s3Server := httptest.NewServer(c.s3Handler)
+			defer s3Server.Close()
+			s3URL = s3Server.URL
+		}
+		checker := getReadinessChecker(t, pharosServer.URL, s3URL)
+		readiness, err := checker.Check("test.edu/glacier_bag")
+		pharosServer.Close()
+		require.Nil(t, err, c.storageOption)
+		assert.Equal(t, c.status, readiness.Status, c.storageOption)
+		assert.Equal(t, "test.edu/glacier_bag", readiness.ObjectIdentifier)
+		// Deleted files don't count.
+		assert.Equal(t, 2, readiness.FileCount)
+		if c.status == models.RestoreReady {
+			assert.True(t, readiness.EstimatedReadyAt.IsZero())
+		} else {
+			expected := readiness.CheckedAt.Add(models.GlacierRetrievalTimeFor(c.storageOption))
+			assert.True(t, expected.Equal(readiness.EstimatedReadyAt))
+		}
+	}
+}
+
+func TestAdminServer_RestoreReadiness(t *testing.T) {
+	pharosServer := readinessPharosServer(constants.StorageStandard)
+	defer pharosServer.Close()
+	checker := getReadinessChecker(t, pharosServer.URL, "")
+	adminServer := workers.NewAdminServer(checker.Context, &models.WorkerConfig{},
+		&testConsumer{}, nil, "secret")
+	server := httptest.NewServer(adminServer.Handler())
+	defer server.Close()
+
+	resp := adminRequest(t, server, http.MethodGet, "/restore_readiness/", "secret")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = adminRequest(t, server, http.MethodGet,
+		"/restore_readiness/?object=test.edu%2Fglacier_bag", "secret")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	readiness := &models.RestoreReadiness{}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(readiness))
+	assert.Equal(t, models.RestoreReady, readiness.Status)
+	assert.Equal(t, 2, readiness.ReadyCount)
+	assert.WithinDuration(t, time.Now(), readiness.CheckedAt, time.Minute)
+}
@@ -0,0 +1,149 @@
+package workers
+
+import (
+	"github.com/APTrust/exchange/context"
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/network"
+	"github.com/nsqio/go-nsq"
+	"github.com/nsqio/nsq/nsqd"
+	"os"
+	"time"
+)
+
+// meteredHandler wraps an NSQ message handler, recording in
+// WorkerMetrics when each message starts, and when it's finished
+// or requeued. Our workers process messages asynchronously and
+// finish them long after HandleMessage returns, so we time each
+// message by intercepting its delegate.
+type meteredHandler struct {
+	handler nsq.Handler
+	metrics *models.WorkerMetrics
+}
+
+// NewMeteredHandler returns an nsq.Handler that passes messages to
+// handler, recording each message's processing time in metrics.
+func NewMeteredHandler(handler nsq.Handler, metrics *models.WorkerMetrics) nsq.Handler {
+	return &meteredHandler{
+		handler: handler,
+		metrics: metrics,
+	}
+}
+
+// HandleMessage records the start of message processing, and
+// passes the message to the wrapped handler.
+func (h *meteredHandler) HandleMessage(message *nsq.Message) error {
+	h.metrics.MessageStarted()
+	item := &models.InFlightItem{
+		MessageId: string(message.ID[:]),
+		Body:      string(message.Body),
+		Attempts:  message.Attempts,
+		StartedAt: time.Now().UTC(),
+	}
+	h.metrics.ItemStarted(item)
+	message.Delegate = &meteredDelegate{
+		delegate:  message.Delegate,
+		metrics:   h.metrics,
+		item:      item,
+		startedAt: time.Now(),
+	}
+	return h.handler.HandleMessage(message)
+}
+
+// meteredDelegate records the time it took to process a message
+// when the message is finished or requeued, and then passes the
+// call along to the message's original delegate.
+type meteredDelegate struct {
+	delegate  nsq.MessageDelegate
+	metrics   *models.WorkerMetrics
+	item      *models.InFlightItem
+	startedAt time.Time
+}
+
+func (d *meteredDelegate) OnFinish(message *nsq.Message) {
+	d.metrics.MessageFinished(time.Since(d.startedAt))
+	d.metrics.ItemDone(d.item)
+	d.delegate.OnFinish(message)
+}
+
+func (d *meteredDelegate) OnRequeue(message *nsq.Message, delay time.Duration, backoff bool) {
+	d.metrics.MessageRequeued(time.Since(d.startedAt))
+	d.metrics.ItemDone(d.item)
+	d.delegate.OnRequeue(message, delay, backoff)
+}
+
+func (d *meteredDelegate) OnTouch(message *nsq.Message) {
+	d.delegate.OnTouch(message)
+}
+
+// StartCloudWatchMetrics starts a goroutine that periodically publishes
+// the context's WorkerMetrics, along with the depth of the worker's NSQ
+// channel, to CloudWatch. Metrics carry Topic, Channel and Host
+// dimensions. This is a no-op if Config.CloudWatchMetricsNamespace
+// is empty. It returns an error if it can't set up the publisher.
+func StartCloudWatchMetrics(_context *context.Context, workerConfig *models.WorkerConfig) error {
+	config := _context.Config
+	if config.CloudWatchMetricsNamespace == "" {
+		return nil
+	}
+	interval := time.Minute
+	if config.CloudWatchMetricsInterval != "" {
+		var err error
+		interval, err = time.ParseDuration(config.CloudWatchMetricsInterval)
+		if err != nil {
+			return err
+		}
+	}
+	region := config.CloudWatchMetricsRegion
+	if region == "" {
+		region = config.APTrustS3Region
+	}
+	hostname, _ := os.Hostname()
+	publisher, err := network.NewCloudWatchPublisher(region, config.CloudWatchMetricsNamespace,
+		map[string]string{
+			"Topic":   workerConfig.NsqTopic,
+			"Channel": workerConfig.NsqChannel,
+			"Host":    hostname,
+		})
+	if err != nil {
+		return err
+	}
+	_context.MessageLog.Info("Publishing metrics to CloudWatch namespace %s in %s every %s",
+		config.CloudWatchMetricsNamespace, region, interval.String())
+	go func() {
+		for range time.Tick(interval) {
+			PublishWorkerMetrics(_context, publisher, workerConfig)
+		}
+	}()
+	return nil
+}
+
+// PublishWorkerMetrics publishes the context's WorkerMetrics for the
+// current interval, and the stats of the worker's NSQ channel, to
+// CloudWatch, along with the context's PharosMetrics. If NSQ stats
+// are not available, this publishes the WorkerMetrics alone. It logs
+// any errors.
+func PublishWorkerMetrics(_context *context.Context, publisher *network.CloudWatchPublisher, workerConfig *models.WorkerConfig) {
+	snapshot := _context.WorkerMetrics.Snapshot()
+	var queue *nsqd.ChannelStats
+	stats, err := _context.NSQClient.GetStats()
+	if err != nil {
+		_context.MessageLog.Warning("Cannot get NSQ stats for CloudWatch metrics: %v", err)
+	} else {
+		queue = stats.ChannelStats(workerConfig.NsqTopic, workerConfig.NsqChannel)
+	}
+	err = publisher.Publish(snapshot, queue)
+	if err != nil {
+		_context.MessageLog.Warning("Cannot publish CloudWatch metrics: %v", err)
+	}
+	pharosSnapshot := _context.PharosMetrics.Snapshot()
+	for _, endpoint := range pharosSnapshot {
+		_context.MessageLog.Debug("Pharos %s %s: %d requests, %d client errors, "+
+			"%d server errors, average %s, max %s", endpoint.Method, endpoint.Endpoint,
+			endpoint.Requests, endpoint.ClientErrors, endpoint.ServerErrors,
+			endpoint.AverageTime().String(), endpoint.MaxTime.String())
+	}
+	err = publisher.PublishPharosMetrics(pharosSnapshot)
+	if err != nil {
+		_context.MessageLog.Warning("Cannot publish Pharos metrics to CloudWatch: %v", err)
+	}
+}
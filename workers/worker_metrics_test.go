@@ -0,0 +1,70 @@
+package workers_test
+
+import (
+	"github.com/APTrust/exchange/models"
+	"github.com/APTrust/exchange/workers"
+	"github.com/nsqio/go-nsq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type testMessageDelegate struct {
+	finished int
+	requeued int
+}
+
+func (d *testMessageDelegate) OnFinish(m *nsq.Message)                           { d.finished++ }
+func (d *testMessageDelegate) OnRequeue(m *nsq.Message, t time.Duration, b bool) { d.requeued++ }
+func (d *testMessageDelegate) OnTouch(m *nsq.Message)                            {}
+
+type testMessageHandler struct {
+	messages []*nsq.Message
+}
+
+func (h *testMessageHandler) HandleMessage(message *nsq.Message) error {
+	message.DisableAutoResponse()
+	h.messages = append(h.messages, message)
+	return nil
+}
+
+func newTestMessage(delegate nsq.MessageDelegate) *nsq.Message {
+	message := nsq.NewMessage(nsq.MessageID{'1'}, []byte("1234"))
+	message.Delegate = delegate
+	return message
+}
+
+func TestMeteredHandler(t *testing.T) {
+	metrics := models.NewWorkerMetrics()
+	delegate := &testMessageDelegate{}
+	handler := &testMessageHandler{}
+	meteredHandler := workers.NewMeteredHandler(handler, metrics)
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, meteredHandler.HandleMessage(newTestMessage(delegate)))
+	}
+	assert.Equal(t, 3, len(handler.messages))
+	snapshot := metrics.Snapshot()
+	assert.EqualValues(t, 3, snapshot.InFlight)
+	assert.EqualValues(t, 0, snapshot.Finished)
+
+	// Workers finish and requeue messages after HandleMessage returns.
+	handler.messages[0].Finish()
+	handler.messages[1].Requeue(time.Minute)
+	handler.messages[2].Touch()
+
+	// The original delegate should still get the calls.
+	assert.Equal(t, 1, delegate.finished)
+	assert.Equal(t, 1, delegate.requeued)
+
+	snapshot = metrics.Snapshot()
+	assert.EqualValues(t, 1, snapshot.InFlight)
+	assert.EqualValues(t, 1, snapshot.Finished)
+	assert.EqualValues(t, 1, snapshot.Requeued)
+	assert.True(t, snapshot.MaxProcessingTime > 0)
+
+	// Only the touched message is still in flight.
+	items := metrics.InFlightItems()
+	assert.Equal(t, 1, len(items))
+	assert.Equal(t, "1234", items[0].Body)
+}